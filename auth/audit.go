@@ -0,0 +1,195 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-gost/core/common/ctxvalue"
+	"github.com/go-gost/core/logger"
+	"github.com/go-gost/core/recorder"
+)
+
+const (
+	defaultAuditTimeout   = 5 * time.Second
+	defaultAuditQueueSize = 128
+)
+
+// AuditRecord is the record of an authentication attempt, the password is never recorded.
+type AuditRecord struct {
+	Time     time.Time `json:"time"`
+	Auther   string    `json:"auther,omitempty"`
+	Service  string    `json:"service,omitempty"`
+	Username string    `json:"username"`
+	ClientIP string    `json:"clientIP,omitempty"`
+	TraceID  string    `json:"tid,omitempty"`
+	ID       string    `json:"id,omitempty"`
+	Success  bool      `json:"success"`
+}
+
+type AuditOptions struct {
+	// Name is the name of the inner authenticator in the records.
+	Name    string
+	Timeout time.Duration
+	// QueueSize is the maximum number of the records waiting for the recorder, the excess records are dropped.
+	QueueSize int
+	Logger    logger.Logger
+}
+
+type AuditOption func(opts *AuditOptions)
+
+func NameAuditOption(name string) AuditOption {
+	return func(opts *AuditOptions) {
+		opts.Name = name
+	}
+}
+
+func TimeoutAuditOption(timeout time.Duration) AuditOption {
+	return func(opts *AuditOptions) {
+		opts.Timeout = timeout
+	}
+}
+
+func QueueSizeAuditOption(n int) AuditOption {
+	return func(opts *AuditOptions) {
+		opts.QueueSize = n
+	}
+}
+
+func LoggerAuditOption(logger logger.Logger) AuditOption {
+	return func(opts *AuditOptions) {
+		opts.Logger = logger
+	}
+}
+
+type auditEntry struct {
+	ctx context.Context
+	b   []byte
+}
+
+type auditedAuthenticator struct {
+	auther    Authenticator
+	recorder  recorder.Recorder
+	options   AuditOptions
+	records   chan auditEntry
+	dropped   atomic.Int64
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewAuditedAuthenticator wraps the authenticator inner to emit an AuditRecord in JSON to rec
+// for each authentication attempt. Recording is asynchronous and best-effort by a bounded queue,
+// it never blocks or changes the authentication result, the records are dropped when the queue is full.
+// The returned authenticator implements io.Closer to stop the recording,
+// and the method Dropped() int64 returning the number of the dropped records.
+func NewAuditedAuthenticator(inner Authenticator, rec recorder.Recorder, opts ...AuditOption) Authenticator {
+	options := AuditOptions{
+		Timeout:   defaultAuditTimeout,
+		QueueSize: defaultAuditQueueSize,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+	if options.QueueSize <= 0 {
+		options.QueueSize = defaultAuditQueueSize
+	}
+
+	p := &auditedAuthenticator{
+		auther:   inner,
+		recorder: rec,
+		options:  options,
+		records:  make(chan auditEntry, options.QueueSize),
+		done:     make(chan struct{}),
+	}
+	if inner != nil && rec != nil {
+		go p.run()
+	}
+	return p
+}
+
+func (p *auditedAuthenticator) run() {
+	for {
+		select {
+		case entry := <-p.records:
+			select {
+			case <-p.done:
+				return
+			default:
+			}
+			ctx, cancel := context.WithTimeout(entry.ctx, p.options.Timeout)
+			if err := p.recorder.Record(ctx, entry.b); err != nil && p.options.Logger != nil {
+				p.options.Logger.Warnf("auth audit: %v", err)
+			}
+			cancel()
+		case <-p.done:
+			return
+		}
+	}
+}
+
+func (p *auditedAuthenticator) Authenticate(ctx context.Context, user, password string, opts ...Option) (id string, ok bool) {
+	if p.auther == nil {
+		return "", true
+	}
+
+	id, ok = p.auther.Authenticate(ctx, user, password, opts...)
+	if p.recorder == nil {
+		return
+	}
+	select {
+	case <-p.done:
+		return
+	default:
+	}
+
+	var options Options
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+
+	clientIP := ctxvalue.ClientAddrFromContext(ctx)
+	if host, _, err := net.SplitHostPort(clientIP); err == nil {
+		clientIP = host
+	}
+	b, err := json.Marshal(&AuditRecord{
+		Time:     time.Now(),
+		Auther:   p.options.Name,
+		Service:  options.Service,
+		Username: user,
+		ClientIP: clientIP,
+		TraceID:  ctxvalue.TraceIDFromContext(ctx),
+		ID:       id,
+		Success:  ok,
+	})
+	if err != nil {
+		return
+	}
+
+	select {
+	case p.records <- auditEntry{ctx: context.WithoutCancel(ctx), b: b}:
+	default:
+		p.dropped.Add(1)
+	}
+
+	return
+}
+
+// Dropped returns the number of the records dropped due to the full queue.
+func (p *auditedAuthenticator) Dropped() int64 {
+	return p.dropped.Load()
+}
+
+// Close stops the recording, the records still in the queue are discarded.
+func (p *auditedAuthenticator) Close() error {
+	p.closeOnce.Do(func() {
+		close(p.done)
+	})
+	return nil
+}
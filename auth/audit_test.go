@@ -0,0 +1,179 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-gost/core/common/ctxvalue"
+	"github.com/go-gost/core/recorder"
+)
+
+// chanRecorder sends the records to a channel and returns err.
+type chanRecorder struct {
+	records chan []byte
+	err     error
+}
+
+func (r *chanRecorder) Record(ctx context.Context, b []byte, opts ...recorder.RecordOption) error {
+	r.records <- b
+	return r.err
+}
+
+// userAuthenticator accepts the users with the password.
+type userAuthenticator map[string]string
+
+func (a userAuthenticator) Authenticate(ctx context.Context, user, password string, opts ...Option) (string, bool) {
+	if p, ok := a[user]; ok && p == password {
+		return "id-" + user, true
+	}
+	return "", false
+}
+
+func nextRecord(t *testing.T, r *chanRecorder) AuditRecord {
+	t.Helper()
+	select {
+	case b := <-r.records:
+		var rec AuditRecord
+		if err := json.Unmarshal(b, &rec); err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(b), "secret") {
+			t.Fatalf("the password is recorded: %s", b)
+		}
+		return rec
+	case <-time.After(time.Second):
+		t.Fatal("no audit record")
+	}
+	return AuditRecord{}
+}
+
+func TestAuditedAuthenticator(t *testing.T) {
+	r := &chanRecorder{records: make(chan []byte, 1)}
+	auther := NewAuditedAuthenticator(userAuthenticator{"alice": "secret"}, r, NameAuditOption("users"))
+
+	ctx := ctxvalue.ContextWithClientAddr(context.Background(), "192.0.2.1:12345")
+	ctx = ctxvalue.ContextWithTraceID(ctx, "trace-1")
+	start := time.Now()
+
+	id, ok := auther.Authenticate(ctx, "alice", "secret", WithService("socks5"))
+	if !ok || id != "id-alice" {
+		t.Fatalf("expected success, got %q %v", id, ok)
+	}
+	rec := nextRecord(t, r)
+	if !rec.Success || rec.Username != "alice" || rec.ClientIP != "192.0.2.1" || rec.Auther != "users" ||
+		rec.Service != "socks5" || rec.TraceID != "trace-1" || rec.ID != "id-alice" || rec.Time.Before(start.Truncate(time.Second)) {
+		t.Fatalf("unexpected success record %+v", rec)
+	}
+
+	if _, ok := auther.Authenticate(ctx, "alice", "wrong"); ok {
+		t.Fatal("expected failure")
+	}
+	rec = nextRecord(t, r)
+	if rec.Success || rec.Username != "alice" || rec.ClientIP != "192.0.2.1" || rec.ID != "" {
+		t.Fatalf("unexpected failure record %+v", rec)
+	}
+}
+
+func TestAuditedAuthenticatorRecorderError(t *testing.T) {
+	r := &chanRecorder{records: make(chan []byte, 1), err: errors.New("recorder down")}
+	auther := NewAuditedAuthenticator(userAuthenticator{"alice": "secret"}, r)
+
+	if _, ok := auther.Authenticate(context.Background(), "alice", "secret"); !ok {
+		t.Fatal("recording failure should not break the authentication")
+	}
+	nextRecord(t, r)
+	if _, ok := auther.Authenticate(context.Background(), "bob", "secret"); ok {
+		t.Fatal("recording failure should not change the result")
+	}
+	nextRecord(t, r)
+}
+
+func TestAuditedAuthenticatorBlockingRecorder(t *testing.T) {
+	// the recorder never returns.
+	r := &chanRecorder{records: make(chan []byte)}
+	auther := NewAuditedAuthenticator(userAuthenticator{"alice": "secret"}, r)
+
+	done := make(chan bool)
+	go func() {
+		_, ok := auther.Authenticate(context.Background(), "alice", "secret")
+		done <- ok
+	}()
+	select {
+	case ok := <-done:
+		if !ok {
+			t.Fatal("expected success")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("authentication is blocked by the recorder")
+	}
+}
+
+// gateRecorder signals started on each record and blocks until the gate is opened.
+type gateRecorder struct {
+	started chan struct{}
+	gate    chan struct{}
+}
+
+func (r *gateRecorder) Record(ctx context.Context, b []byte, opts ...recorder.RecordOption) error {
+	r.started <- struct{}{}
+	<-r.gate
+	return nil
+}
+
+func TestAuditedAuthenticatorQueue(t *testing.T) {
+	r := &gateRecorder{started: make(chan struct{}, 16), gate: make(chan struct{})}
+	auther := NewAuditedAuthenticator(userAuthenticator{"alice": "secret"}, r, QueueSizeAuditOption(2))
+	defer auther.(io.Closer).Close()
+
+	// the recorder is busy with the first record.
+	auther.Authenticate(context.Background(), "alice", "secret")
+	select {
+	case <-r.started:
+	case <-time.After(time.Second):
+		t.Fatal("the record is not delivered")
+	}
+
+	// the credential stuffing fills the queue, the excess is dropped without blocking.
+	for i := 0; i < 10; i++ {
+		if _, ok := auther.Authenticate(context.Background(), "alice", "wrong"); ok {
+			t.Fatal("expected failure")
+		}
+	}
+	dropped := auther.(interface{ Dropped() int64 }).Dropped()
+	if dropped != 8 {
+		t.Fatalf("expected 8 dropped records, got %d", dropped)
+	}
+
+	// the queued records are delivered in turn.
+	close(r.gate)
+	for i := 0; i < 2; i++ {
+		select {
+		case <-r.started:
+		case <-time.After(time.Second):
+			t.Fatalf("the queued record %d is not delivered", i)
+		}
+	}
+}
+
+func TestAuditedAuthenticatorClose(t *testing.T) {
+	r := &chanRecorder{records: make(chan []byte, 1)}
+	auther := NewAuditedAuthenticator(userAuthenticator{"alice": "secret"}, r)
+	c := auther.(io.Closer)
+	c.Close()
+	c.Close()
+
+	// the authentication still works after the recording is stopped.
+	if _, ok := auther.Authenticate(context.Background(), "alice", "secret"); !ok {
+		t.Fatal("expected success")
+	}
+	select {
+	case b := <-r.records:
+		t.Fatalf("unexpected record after close %s", b)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/go-gost/core/common/ctxvalue"
+	"github.com/go-gost/core/observer"
+)
+
+type observedAuthenticator struct {
+	inner    Authenticator
+	notifier *observer.DenyNotifier
+}
+
+// ObservedAuthenticator wraps the authenticator inner and notifies a deny event for each failed authentication.
+func ObservedAuthenticator(inner Authenticator, n *observer.DenyNotifier) Authenticator {
+	if inner == nil || n == nil {
+		return inner
+	}
+	return &observedAuthenticator{
+		inner:    inner,
+		notifier: n,
+	}
+}
+
+func (a *observedAuthenticator) Authenticate(ctx context.Context, user, password string, opts ...Option) (string, bool) {
+	id, ok := a.inner.Authenticate(ctx, user, password, opts...)
+	if ok {
+		return id, ok
+	}
+
+	var options Options
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+	a.notifier.Notify(observer.DenyEvent{
+		Reason:  observer.DenyAuth,
+		Service: options.Service,
+		Client:  ctxvalue.ClientAddrFromContext(ctx),
+		TraceID: ctxvalue.TraceIDFromContext(ctx),
+		User:    user,
+	})
+	return id, ok
+}
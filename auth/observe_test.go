@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-gost/core/common/ctxvalue"
+	"github.com/go-gost/core/observer"
+)
+
+// denyObserver sends the deny events to a channel.
+type denyObserver chan observer.DenyEvent
+
+func (o denyObserver) Observe(ctx context.Context, events []observer.Event, opts ...observer.Option) error {
+	for _, ev := range events {
+		if de, ok := ev.(observer.DenyEvent); ok {
+			o <- de
+		}
+	}
+	return nil
+}
+
+func TestObservedAuthenticator(t *testing.T) {
+	o := make(denyObserver, 10)
+	n := observer.NewDenyNotifier(o, 0)
+	defer n.Close()
+
+	auther := ObservedAuthenticator(userAuthenticator{"alice": "secret"}, n)
+	ctx := ctxvalue.ContextWithClientAddr(context.Background(), "192.0.2.1:12345")
+
+	if id, ok := auther.Authenticate(ctx, "alice", "secret", WithService("socks5")); !ok || id != "id-alice" {
+		t.Fatalf("unexpected result %q %v", id, ok)
+	}
+	if _, ok := auther.Authenticate(ctx, "bob", "wrong", WithService("socks5")); ok {
+		t.Fatal("bob should not pass")
+	}
+
+	select {
+	case ev := <-o:
+		if ev.Reason != observer.DenyAuth || ev.User != "bob" || ev.Service != "socks5" || ev.Client != "192.0.2.1:12345" {
+			t.Fatalf("unexpected deny event %+v", ev)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no deny event")
+	}
+
+	// only the failure is notified.
+	select {
+	case ev := <-o:
+		t.Fatalf("unexpected deny event %+v", ev)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
@@ -0,0 +1,92 @@
+//go:build linux
+
+// Package pam provides an authenticator delegating to the host's PAM stack.
+//
+// The PAM binding requires cgo and libpam, it is enabled with the build tag 'pam',
+// otherwise all authentications are denied with ErrUnsupported.
+package pam
+
+import (
+	"context"
+	"errors"
+
+	"github.com/go-gost/core/auth"
+	"github.com/go-gost/core/logger"
+)
+
+const (
+	defaultService = "login"
+)
+
+var (
+	ErrUnsupported = errors.New("pam: unsupported, build with cgo and tag 'pam'")
+)
+
+// AuthFunc verifies the username and password with PAM service.
+type AuthFunc func(service, user, password string) error
+
+type Options struct {
+	// Service is the PAM service name, which selects the config in /etc/pam.d, default is 'login'.
+	Service  string
+	AuthFunc AuthFunc
+	Logger   logger.Logger
+}
+
+type Option func(opts *Options)
+
+func ServiceOption(service string) Option {
+	return func(opts *Options) {
+		opts.Service = service
+	}
+}
+
+// AuthFuncOption replaces the PAM binding, it is mainly used for testing.
+func AuthFuncOption(fn AuthFunc) Option {
+	return func(opts *Options) {
+		opts.AuthFunc = fn
+	}
+}
+
+func LoggerOption(logger logger.Logger) Option {
+	return func(opts *Options) {
+		opts.Logger = logger
+	}
+}
+
+type pamAuthenticator struct {
+	options Options
+}
+
+// NewAuthenticator creates an authenticator which verifies username and password via PAM.
+func NewAuthenticator(opts ...Option) auth.Authenticator {
+	var options Options
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+	if options.Service == "" {
+		options.Service = defaultService
+	}
+	if options.AuthFunc == nil {
+		options.AuthFunc = pamAuthenticate
+	}
+
+	return &pamAuthenticator{
+		options: options,
+	}
+}
+
+func (p *pamAuthenticator) Authenticate(ctx context.Context, user, password string, opts ...auth.Option) (id string, ok bool) {
+	if user == "" {
+		return
+	}
+
+	if err := p.options.AuthFunc(p.options.Service, user, password); err != nil {
+		if p.options.Logger != nil {
+			p.options.Logger.Debugf("pam: %s: %v", user, err)
+		}
+		return
+	}
+	return user, true
+}
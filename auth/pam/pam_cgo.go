@@ -0,0 +1,87 @@
+//go:build linux && cgo && pam
+
+package pam
+
+/*
+#cgo LDFLAGS: -lpam
+#include <security/pam_appl.h>
+#include <stdlib.h>
+#include <string.h>
+
+// gost_pam_conv answers all the prompts with the password passed in appdata.
+static int gost_pam_conv(int n, const struct pam_message **msg, struct pam_response **resp, void *appdata) {
+	struct pam_response *r;
+	int i;
+
+	if (n <= 0 || n > PAM_MAX_NUM_MSG)
+		return PAM_CONV_ERR;
+
+	r = calloc(n, sizeof(struct pam_response));
+	if (r == NULL)
+		return PAM_BUF_ERR;
+
+	for (i = 0; i < n; i++) {
+		switch (msg[i]->msg_style) {
+		case PAM_PROMPT_ECHO_OFF:
+		case PAM_PROMPT_ECHO_ON:
+			r[i].resp = strdup((const char *)appdata);
+			if (r[i].resp == NULL)
+				goto fail;
+			break;
+		case PAM_ERROR_MSG:
+		case PAM_TEXT_INFO:
+			break;
+		default:
+			goto fail;
+		}
+	}
+	*resp = r;
+	return PAM_SUCCESS;
+
+fail:
+	for (i = 0; i < n; i++)
+		free(r[i].resp);
+	free(r);
+	return PAM_CONV_ERR;
+}
+
+static int gost_pam_authenticate(const char *service, const char *user, const char *password) {
+	struct pam_conv conv = { gost_pam_conv, (void *)password };
+	pam_handle_t *h = NULL;
+	int ret;
+
+	ret = pam_start(service, user, &conv, &h);
+	if (ret != PAM_SUCCESS)
+		return ret;
+
+	ret = pam_authenticate(h, PAM_SILENT | PAM_DISALLOW_NULL_AUTHTOK);
+	if (ret == PAM_SUCCESS)
+		ret = pam_acct_mgmt(h, PAM_SILENT);
+
+	pam_end(h, ret);
+	return ret;
+}
+*/
+import "C"
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+func pamAuthenticate(service, user, password string) error {
+	cservice := C.CString(service)
+	defer C.free(unsafe.Pointer(cservice))
+	cuser := C.CString(user)
+	defer C.free(unsafe.Pointer(cuser))
+	cpassword := C.CString(password)
+	defer func() {
+		C.memset(unsafe.Pointer(cpassword), 0, C.size_t(len(password)))
+		C.free(unsafe.Pointer(cpassword))
+	}()
+
+	if ret := C.gost_pam_authenticate(cservice, cuser, cpassword); ret != C.PAM_SUCCESS {
+		return fmt.Errorf("pam: %s", C.GoString(C.pam_strerror(nil, ret)))
+	}
+	return nil
+}
@@ -0,0 +1,7 @@
+//go:build linux && !(cgo && pam)
+
+package pam
+
+func pamAuthenticate(service, user, password string) error {
+	return ErrUnsupported
+}
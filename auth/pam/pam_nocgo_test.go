@@ -0,0 +1,18 @@
+//go:build linux && !(cgo && pam)
+
+package pam
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestAuthenticatorUnsupported(t *testing.T) {
+	if err := pamAuthenticate(defaultService, "root", ""); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("expected ErrUnsupported, got %v", err)
+	}
+	if _, ok := NewAuthenticator().Authenticate(context.Background(), "root", "secret"); ok {
+		t.Fatal("authentication should be denied without the PAM binding")
+	}
+}
@@ -0,0 +1,59 @@
+//go:build linux
+
+package pam
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// mockPAM is a PAM service backed by a fixed set of accounts.
+type mockPAM struct {
+	service  string
+	accounts map[string]string
+	calls    int
+}
+
+func (m *mockPAM) authenticate(service, user, password string) error {
+	m.calls++
+	if service != m.service {
+		return errors.New("pam: no such service")
+	}
+	if p, ok := m.accounts[user]; !ok || p != password {
+		return errors.New("pam: authentication failure")
+	}
+	return nil
+}
+
+func TestAuthenticator(t *testing.T) {
+	m := &mockPAM{service: "gost", accounts: map[string]string{"alice": "secret"}}
+	au := NewAuthenticator(ServiceOption("gost"), AuthFuncOption(m.authenticate))
+
+	if id, ok := au.Authenticate(context.Background(), "alice", "secret"); !ok || id != "alice" {
+		t.Fatalf("expected success, got %q %v", id, ok)
+	}
+	for _, tc := range []struct{ user, password string }{
+		{"alice", "wrong"},
+		{"bob", "secret"},
+	} {
+		if _, ok := au.Authenticate(context.Background(), tc.user, tc.password); ok {
+			t.Errorf("%s/%s should be denied", tc.user, tc.password)
+		}
+	}
+
+	// empty user never reaches PAM.
+	calls := m.calls
+	if _, ok := au.Authenticate(context.Background(), "", "secret"); ok || m.calls != calls {
+		t.Fatal("empty user should be denied without PAM")
+	}
+}
+
+func TestAuthenticatorDefaultService(t *testing.T) {
+	m := &mockPAM{service: defaultService, accounts: map[string]string{"alice": "secret"}}
+	au := NewAuthenticator(AuthFuncOption(m.authenticate))
+
+	if _, ok := au.Authenticate(context.Background(), "alice", "secret"); !ok {
+		t.Fatal("expected the default service")
+	}
+}
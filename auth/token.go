@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	ErrTokenNotFound = errors.New("token not found")
+)
+
+// Token is a time-limited token minted out-of-band, e.g. for a share link.
+type Token struct {
+	// ID is the identity the token authenticates as.
+	ID      string
+	Expires time.Time
+	// Uses is the remaining number of uses, a token is single-use if Uses is 1.
+	Uses int
+}
+
+// TokenStore stores the tokens. It can be backed by the memory or by an external store such as Redis.
+type TokenStore interface {
+	// Set stores the token with the secret.
+	Set(ctx context.Context, secret string, token Token) error
+	// Use atomically consumes one use of the token with the secret and returns the token,
+	// the token is removed when it is used up or expired.
+	// ErrTokenNotFound is returned if there is no such valid token.
+	Use(ctx context.Context, secret string) (Token, error)
+}
+
+type memoryTokenStore struct {
+	tokens map[string]Token
+	mu     sync.Mutex
+}
+
+// MemoryTokenStore is a TokenStore in the memory.
+func MemoryTokenStore() TokenStore {
+	return &memoryTokenStore{
+		tokens: make(map[string]Token),
+	}
+}
+
+func (s *memoryTokenStore) Set(ctx context.Context, secret string, token Token) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for k, t := range s.tokens {
+		if !t.Expires.IsZero() && !now.Before(t.Expires) {
+			delete(s.tokens, k)
+		}
+	}
+	s.tokens[secret] = token
+	return nil
+}
+
+func (s *memoryTokenStore) Use(ctx context.Context, secret string) (Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.tokens[secret]
+	if !ok {
+		return Token{}, ErrTokenNotFound
+	}
+	if !token.Expires.IsZero() && !time.Now().Before(token.Expires) {
+		delete(s.tokens, secret)
+		return Token{}, ErrTokenNotFound
+	}
+
+	if token.Uses <= 1 {
+		delete(s.tokens, secret)
+	} else {
+		token.Uses--
+		s.tokens[secret] = token
+	}
+	return token, nil
+}
+
+type tokenAuthenticator struct {
+	store TokenStore
+}
+
+// TokenAuthenticator authenticates with the one-time tokens in the store, the token is passed as the password,
+// the username is ignored. A token is invalidated after it is used up.
+func TokenAuthenticator(store TokenStore) Authenticator {
+	return &tokenAuthenticator{
+		store: store,
+	}
+}
+
+func (a *tokenAuthenticator) Authenticate(ctx context.Context, user, password string, opts ...Option) (string, bool) {
+	if a.store == nil || password == "" {
+		return "", false
+	}
+
+	token, err := a.store.Use(ctx, password)
+	if err != nil {
+		return "", false
+	}
+	if !token.Expires.IsZero() && !time.Now().Before(token.Expires) {
+		return "", false
+	}
+	return token.ID, true
+}
@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTokenAuthenticatorOnce(t *testing.T) {
+	store := MemoryTokenStore()
+	store.Set(context.Background(), "s3cret", Token{ID: "share-1", Expires: time.Now().Add(time.Hour), Uses: 1})
+	auther := TokenAuthenticator(store)
+
+	if id, ok := auther.Authenticate(context.Background(), "anyone", "s3cret"); !ok || id != "share-1" {
+		t.Fatalf("the token should authenticate once, got %q %v", id, ok)
+	}
+	// reuse is rejected.
+	if _, ok := auther.Authenticate(context.Background(), "anyone", "s3cret"); ok {
+		t.Fatal("the used token should be rejected")
+	}
+	if _, err := store.Use(context.Background(), "s3cret"); !errors.Is(err, ErrTokenNotFound) {
+		t.Fatalf("the used token should be removed, got %v", err)
+	}
+}
+
+func TestTokenAuthenticatorUses(t *testing.T) {
+	store := MemoryTokenStore()
+	store.Set(context.Background(), "s3cret", Token{ID: "share-1", Uses: 3})
+	auther := TokenAuthenticator(store)
+
+	for i := 0; i < 3; i++ {
+		if _, ok := auther.Authenticate(context.Background(), "", "s3cret"); !ok {
+			t.Fatalf("use %d should pass", i)
+		}
+	}
+	if _, ok := auther.Authenticate(context.Background(), "", "s3cret"); ok {
+		t.Fatal("the token should be used up")
+	}
+}
+
+func TestTokenAuthenticatorExpired(t *testing.T) {
+	store := MemoryTokenStore()
+	store.Set(context.Background(), "old", Token{ID: "share-1", Expires: time.Now().Add(-time.Second), Uses: 1})
+	store.Set(context.Background(), "soon", Token{ID: "share-2", Expires: time.Now().Add(20 * time.Millisecond), Uses: 1})
+	auther := TokenAuthenticator(store)
+
+	if _, ok := auther.Authenticate(context.Background(), "", "old"); ok {
+		t.Fatal("the expired token should be rejected")
+	}
+	time.Sleep(30 * time.Millisecond)
+	if _, ok := auther.Authenticate(context.Background(), "", "soon"); ok {
+		t.Fatal("the token should be rejected after it expires")
+	}
+
+	if _, ok := auther.Authenticate(context.Background(), "", ""); ok {
+		t.Fatal("the empty token should be rejected")
+	}
+	if _, ok := TokenAuthenticator(nil).Authenticate(context.Background(), "", "old"); ok {
+		t.Fatal("no token should pass without store")
+	}
+}
+
+func TestTokenAuthenticatorConcurrent(t *testing.T) {
+	store := MemoryTokenStore()
+	store.Set(context.Background(), "s3cret", Token{ID: "share-1", Uses: 1})
+	auther := TokenAuthenticator(store)
+
+	// the single-use token is consumed atomically.
+	var wg sync.WaitGroup
+	var passed atomic.Int64
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, ok := auther.Authenticate(context.Background(), "", "s3cret"); ok {
+				passed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := passed.Load(); n != 1 {
+		t.Fatalf("expected exactly one use, got %d", n)
+	}
+}
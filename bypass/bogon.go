@@ -0,0 +1,56 @@
+package bypass
+
+import (
+	"context"
+	"net"
+
+	"github.com/go-gost/core/resolver"
+)
+
+type bogonBypass struct {
+	resolver resolver.Resolver
+	filter   resolver.IPFilter
+}
+
+// BogonBypass is a bypass which contains the hostnames resolving exclusively to
+// bogon or denied addresses (as judged by resolver.NewIPFilter with opts),
+// so that the proxy can not be used to reach the internal services.
+// IP addresses and the names failed to resolve are not contained.
+//
+// Combined with resolver.FilterResolver using the same options, the names with mixed answers
+// are allowed and dialed with the acceptable addresses only.
+func BogonBypass(r resolver.Resolver, opts ...resolver.FilterOption) Bypass {
+	return &bogonBypass{
+		resolver: r,
+		filter:   resolver.NewIPFilter(opts...),
+	}
+}
+
+func (p *bogonBypass) IsWhitelist() bool {
+	return false
+}
+
+func (p *bogonBypass) Contains(ctx context.Context, network, addr string, opts ...Option) bool {
+	if p.resolver == nil {
+		return false
+	}
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	if host == "" || net.ParseIP(host) != nil {
+		return false
+	}
+
+	ips, err := p.resolver.Resolve(ctx, "ip", host)
+	if err != nil || len(ips) == 0 {
+		return false
+	}
+	for _, ip := range ips {
+		if p.filter.Allowed(ip) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,88 @@
+package bypass
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/go-gost/core/resolver"
+)
+
+// mapResolver answers the names from the map, unknown names are not found.
+type mapResolver map[string][]string
+
+func (r mapResolver) Resolve(ctx context.Context, network, host string, opts ...resolver.Option) ([]net.IP, error) {
+	addrs, ok := r[host]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	var ips []net.IP
+	for _, addr := range addrs {
+		ips = append(ips, net.ParseIP(addr))
+	}
+	return ips, nil
+}
+
+var bogonResolver = mapResolver{
+	"internal.example.com": {"10.0.0.5", "192.168.1.1"},
+	"mixed.example.com":    {"10.0.0.5", "93.184.216.34"},
+	"public.example.com":   {"93.184.216.34"},
+}
+
+func TestBogonBypass(t *testing.T) {
+	bp := BogonBypass(bogonResolver)
+
+	for _, tc := range []struct {
+		addr    string
+		blocked bool
+	}{
+		{"internal.example.com:80", true},
+		{"internal.example.com", true},
+		{"mixed.example.com:443", false},
+		{"public.example.com:443", false},
+		// not resolvable.
+		{"missing.example.com:80", false},
+		// IP addresses are not resolved.
+		{"10.0.0.5:80", false},
+	} {
+		if got := bp.Contains(context.Background(), "tcp", tc.addr); got != tc.blocked {
+			t.Errorf("%s: blocked %v, want %v", tc.addr, got, tc.blocked)
+		}
+	}
+}
+
+func TestBogonBypassMixedStripped(t *testing.T) {
+	opts := []resolver.FilterOption{}
+	bp := BogonBypass(bogonResolver, opts...)
+	r := resolver.FilterResolver(bogonResolver, opts...)
+
+	if bp.Contains(context.Background(), "tcp", "mixed.example.com:443") {
+		t.Fatal("mixed answer should be allowed")
+	}
+	ips, err := r.Resolve(context.Background(), "ip", "mixed.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ips) != 1 || !ips[0].Equal(net.ParseIP("93.184.216.34")) {
+		t.Fatalf("private IPs should be stripped, got %v", ips)
+	}
+
+	_, err = r.Resolve(context.Background(), "ip", "internal.example.com")
+	var dnsErr *net.DNSError
+	if !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
+
+func TestBogonBypassAllow(t *testing.T) {
+	_, allow, _ := net.ParseCIDR("10.0.0.0/24")
+	bp := BogonBypass(bogonResolver, resolver.AllowFilterOption(allow))
+
+	if bp.Contains(context.Background(), "tcp", "internal.example.com:80") {
+		t.Fatal("allowed range should not be blocked")
+	}
+	if BogonBypass(nil).Contains(context.Background(), "tcp", "internal.example.com:80") {
+		t.Fatal("nil resolver should not block")
+	}
+}
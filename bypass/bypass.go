@@ -8,6 +8,8 @@ type Options struct {
 	Service string
 	Host    string
 	Path    string
+	SNI     string
+	JA3     string
 }
 
 type Option func(opts *Options)
@@ -30,6 +32,18 @@ func WithPathOption(path string) Option {
 	}
 }
 
+func WithSNIOption(sni string) Option {
+	return func(opts *Options) {
+		opts.SNI = sni
+	}
+}
+
+func WithJA3Option(ja3 string) Option {
+	return func(opts *Options) {
+		opts.JA3 = ja3
+	}
+}
+
 // Bypass is a filter of address (IP or domain).
 type Bypass interface {
 	// Contains reports whether the bypass includes addr.
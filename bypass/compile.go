@@ -0,0 +1,257 @@
+package bypass
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"regexp"
+	"strings"
+)
+
+// domainTrie is a suffix trie of the domain names by the labels from the top level.
+type domainTrie struct {
+	children map[string]*domainTrie
+	// self matches the domain of the node, sub matches its subdomains.
+	self bool
+	sub  bool
+	// wildcards are the wildcard rules whose labels after the last wildcard are the domain of the node.
+	wildcards []wildcardRule
+}
+
+func (t *domainTrie) insert(domain string, self, sub bool) {
+	labels := strings.Split(domain, ".")
+	node := t
+	for i := len(labels) - 1; i >= 0; i-- {
+		node = node.child(labels[i], true)
+	}
+	node.self = node.self || self
+	node.sub = node.sub || sub
+}
+
+// child returns the child of the label, it is created if create is true.
+func (t *domainTrie) child(label string, create bool) *domainTrie {
+	child := t.children[label]
+	if child == nil && create {
+		if t.children == nil {
+			t.children = make(map[string]*domainTrie)
+		}
+		child = &domainTrie{}
+		t.children[label] = child
+	}
+	return child
+}
+
+// insertWildcard indexes the wildcard by its literal labels from the top level,
+// so only the wildcards sharing the suffix with the host are evaluated.
+func (t *domainTrie) insertWildcard(pattern string) {
+	labels := strings.Split(pattern, ".")
+	node := t
+	for i := len(labels) - 1; i >= 0 && !strings.Contains(labels[i], "*"); i-- {
+		node = node.child(labels[i], true)
+	}
+	node.wildcards = append(node.wildcards, labels)
+}
+
+func (t *domainTrie) matchWildcard(host string) bool {
+	var labels []string
+	node := t
+	for rest := host; node != nil; {
+		if len(node.wildcards) > 0 {
+			if labels == nil {
+				labels = strings.Split(host, ".")
+			}
+			for _, w := range node.wildcards {
+				if w.match(labels) {
+					return true
+				}
+			}
+		}
+		if rest == "" {
+			break
+		}
+		i := strings.LastIndexByte(rest, '.')
+		node = node.children[rest[i+1:]]
+		if i < 0 {
+			rest = ""
+		} else {
+			rest = rest[:i]
+		}
+	}
+	return false
+}
+
+func (t *domainTrie) match(host string) bool {
+	node := t
+	for rest := host; ; {
+		i := strings.LastIndexByte(rest, '.')
+		node = node.children[rest[i+1:]]
+		if node == nil {
+			return false
+		}
+		if i < 0 {
+			return node.self
+		}
+		// more labels remain, host is a subdomain of the node.
+		if node.sub {
+			return true
+		}
+		rest = rest[:i]
+	}
+}
+
+// wildcardRule is a wildcard rule split into the labels.
+type wildcardRule []string
+
+func (r wildcardRule) match(labels []string) bool {
+	if len(r) != len(labels) {
+		return false
+	}
+	for i := range r {
+		if !globLabel(r[i], labels[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// globLabel matches the label s against pattern, in which * matches any characters.
+func globLabel(pattern, s string) bool {
+	i := strings.IndexByte(pattern, '*')
+	if i < 0 {
+		return pattern == s
+	}
+	if !strings.HasPrefix(s, pattern[:i]) {
+		return false
+	}
+	s, pattern = s[i:], pattern[i+1:]
+	for {
+		j := strings.IndexByte(pattern, '*')
+		if j < 0 {
+			return len(s) >= len(pattern) && strings.HasSuffix(s, pattern)
+		}
+		k := strings.Index(s, pattern[:j])
+		if k < 0 {
+			return false
+		}
+		s, pattern = s[k+j:], pattern[j+1:]
+	}
+}
+
+// RuleSet is a compiled set of the rules for the fast matching of the large rule sets.
+// The rules are partitioned by type: the exact names in a hash map, the domain suffixes in a suffix trie,
+// the IPs and CIDRs in a CIDRMatcher, the wildcards in the suffix trie by their literal labels,
+// and the regular expressions in a combined alternation, which are evaluated in this order, the cheapest first.
+//
+// A rule is one of:
+//   - an exact domain, e.g. example.com;
+//   - a domain suffix, e.g. *.example.com for the subdomains, or .example.com for the domain and its subdomains;
+//   - an IP address or a CIDR, e.g. 192.168.0.0/16;
+//   - a wildcard, e.g. api-*.example.com, in which * matches any characters within a label;
+//   - a regular expression prefixed with '~', e.g. ~^cdn[0-9]+\.example\.com$, which is matched case-insensitively.
+//
+// Unlike RuleBypass, the negations are not supported.
+type RuleSet struct {
+	exact   map[string]struct{}
+	domains *domainTrie
+	cidrs   *CIDRMatcher
+	regex   *regexp.Regexp
+}
+
+// CompileRules compiles the rules into a RuleSet, an error is returned for an invalid regular expression.
+func CompileRules(rules []string) (*RuleSet, error) {
+	set := &RuleSet{
+		exact:   make(map[string]struct{}),
+		domains: &domainTrie{},
+	}
+
+	var prefixes []netip.Prefix
+	var patterns []string
+	for _, s := range rules {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+
+		if expr, ok := strings.CutPrefix(s, "~"); ok {
+			if _, err := regexp.Compile(expr); err != nil {
+				return nil, fmt.Errorf("bypass: rule %q: %w", s, err)
+			}
+			patterns = append(patterns, expr)
+			continue
+		}
+
+		pattern := strings.TrimSuffix(strings.ToLower(s), ".")
+		if prefix, err := netip.ParsePrefix(pattern); err == nil {
+			prefixes = append(prefixes, prefix)
+			continue
+		}
+		if addr, err := netip.ParseAddr(pattern); err == nil {
+			addr = addr.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(pattern, "*.") && !strings.Contains(pattern[2:], "*"):
+			set.domains.insert(pattern[2:], false, true)
+		case strings.HasPrefix(pattern, ".") && !strings.Contains(pattern, "*"):
+			set.domains.insert(pattern[1:], true, true)
+		case strings.Contains(pattern, "*"):
+			set.domains.insertWildcard(pattern)
+		default:
+			set.exact[pattern] = struct{}{}
+		}
+	}
+
+	set.cidrs = NewCIDRMatcher(prefixes)
+	if len(patterns) > 0 {
+		re, err := regexp.Compile("(?i)(?:" + strings.Join(patterns, ")|(?:") + ")")
+		if err != nil {
+			return nil, fmt.Errorf("bypass: rules: %w", err)
+		}
+		set.regex = re
+	}
+	return set, nil
+}
+
+// Match reports whether the address (a host or host:port) matches any of the rules.
+func (s *RuleSet) Match(addr string) bool {
+	host, ip := ruleTarget(addr)
+	if ip.IsValid() {
+		if s.cidrs.Match(ip) {
+			return true
+		}
+	} else {
+		if _, ok := s.exact[host]; ok {
+			return true
+		}
+		if s.domains.match(host) {
+			return true
+		}
+	}
+	if s.domains.matchWildcard(host) {
+		return true
+	}
+	return s.regex != nil && s.regex.MatchString(host)
+}
+
+type ruleSetBypass struct {
+	set       *RuleSet
+	whitelist bool
+}
+
+// RuleSetBypass is a bypass of the compiled rules, see CompileRules.
+func RuleSetBypass(set *RuleSet, whitelist bool) Bypass {
+	return &ruleSetBypass{
+		set:       set,
+		whitelist: whitelist,
+	}
+}
+
+func (p *ruleSetBypass) IsWhitelist() bool {
+	return p.whitelist
+}
+
+func (p *ruleSetBypass) Contains(ctx context.Context, network, addr string, opts ...Option) bool {
+	return p.set.Match(addr) != p.whitelist
+}
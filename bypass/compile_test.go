@@ -0,0 +1,269 @@
+package bypass
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/netip"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// naiveRegex caches the compiled regular expressions of naiveMatch.
+var naiveRegex = make(map[string]*regexp.Regexp)
+
+// naiveMatch evaluates the rules one by one, it is the reference of RuleSet.Match.
+func naiveMatch(rules []string, addr string) bool {
+	host, ip := ruleTarget(addr)
+	for _, rule := range rules {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		if expr, ok := strings.CutPrefix(rule, "~"); ok {
+			re := naiveRegex[expr]
+			if re == nil {
+				re = regexp.MustCompile("(?i)" + expr)
+				naiveRegex[expr] = re
+			}
+			if re.MatchString(host) {
+				return true
+			}
+			continue
+		}
+
+		pattern := strings.TrimSuffix(strings.ToLower(rule), ".")
+		if prefix, err := netip.ParsePrefix(pattern); err == nil {
+			if ip.IsValid() && prefix.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		if a, err := netip.ParseAddr(pattern); err == nil {
+			if ip.IsValid() && a.Unmap() == ip {
+				return true
+			}
+			continue
+		}
+
+		// the wildcards apply to the IP hosts as well, as the regular expressions do.
+		if strings.Contains(pattern, "*") && !isDomainSuffix(pattern) {
+			if wildcardMatch(pattern, host) {
+				return true
+			}
+			continue
+		}
+		if ip.IsValid() {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(pattern, "*."):
+			if strings.HasSuffix(host, pattern[1:]) {
+				return true
+			}
+		case strings.HasPrefix(pattern, "."):
+			if host == pattern[1:] || strings.HasSuffix(host, pattern) {
+				return true
+			}
+		case host == pattern:
+			return true
+		}
+	}
+	return false
+}
+
+// isDomainSuffix reports whether pattern is a suffix rule, e.g. *.example.com.
+func isDomainSuffix(pattern string) bool {
+	return strings.HasPrefix(pattern, "*.") && !strings.Contains(pattern[2:], "*")
+}
+
+// wildcardMatch matches s against pattern, in which * matches any characters except the dot.
+func wildcardMatch(pattern, s string) bool {
+	if pattern == "" {
+		return s == ""
+	}
+	if pattern[0] == '*' {
+		for i := 0; i <= len(s); i++ {
+			if wildcardMatch(pattern[1:], s[i:]) {
+				return true
+			}
+			if i < len(s) && s[i] == '.' {
+				break
+			}
+		}
+		return false
+	}
+	return s != "" && pattern[0] == s[0] && wildcardMatch(pattern[1:], s[1:])
+}
+
+func TestRuleSet(t *testing.T) {
+	rules := []string{
+		"example.com",
+		"*.sub.example.org",
+		".corp.example.net",
+		"10.0.0.0/8",
+		"192.0.2.1",
+		"2001:db8::/32",
+		"api-*.example.io",
+		`~^cdn[0-9]+\.example\.dev$`,
+		" ",
+	}
+	set, err := CompileRules(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		addr  string
+		match bool
+	}{
+		// exact.
+		{"example.com:443", true},
+		{"EXAMPLE.com.", true},
+		{"www.example.com", false},
+		// the subdomains only.
+		{"a.sub.example.org", true},
+		{"a.b.sub.example.org:80", true},
+		{"sub.example.org", false},
+		// the domain and its subdomains.
+		{"corp.example.net", true},
+		{"mail.corp.example.net", true},
+		{"xcorp.example.net", false},
+		// the IPs and CIDRs.
+		{"10.1.2.3:80", true},
+		{"[::ffff:10.1.2.3]:80", true},
+		{"192.0.2.1", true},
+		{"192.0.2.2", false},
+		{"[2001:db8::1]:443", true},
+		// the wildcard within a label.
+		{"api-eu.example.io", true},
+		{"API-.example.io", true},
+		{"api-eu.x.example.io", false},
+		// the regular expression, case-insensitively.
+		{"cdn12.example.dev", true},
+		{"CDN1.Example.DEV", true},
+		{"cdn.example.dev", false},
+		{"example.org", false},
+	} {
+		if got := set.Match(tc.addr); got != tc.match {
+			t.Errorf("%s: got %v, want %v", tc.addr, got, tc.match)
+		}
+		if naive := naiveMatch(rules, tc.addr); naive != tc.match {
+			t.Errorf("%s: the naive evaluator got %v, want %v", tc.addr, naive, tc.match)
+		}
+	}
+
+	if _, err := CompileRules([]string{"~[unclosed"}); err == nil {
+		t.Fatal("expected error of the invalid regular expression")
+	}
+}
+
+func TestRuleSetBypass(t *testing.T) {
+	set, err := CompileRules([]string{".example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bp := RuleSetBypass(set, false)
+	if !bp.Contains(context.Background(), "tcp", "www.example.com:443") || bp.Contains(context.Background(), "tcp", "example.org:443") {
+		t.Fatal("unexpected bypass result")
+	}
+	wl := RuleSetBypass(set, true)
+	if !wl.IsWhitelist() || wl.Contains(context.Background(), "tcp", "www.example.com:443") || !wl.Contains(context.Background(), "tcp", "example.org:443") {
+		t.Fatal("unexpected whitelist result")
+	}
+}
+
+// largeRules generates n mixed rules and the sample addresses hitting and missing each type.
+func largeRules(n int) (rules, addrs []string) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < n; i++ {
+		switch i % 10 {
+		case 0, 1, 2:
+			rules = append(rules, fmt.Sprintf("host%d.example%d.com", i, i%97))
+		case 3, 4:
+			rules = append(rules, fmt.Sprintf("*.svc%d.internal", i))
+		case 5, 6:
+			rules = append(rules, fmt.Sprintf(".corp%d.net", i))
+		case 7:
+			rules = append(rules, fmt.Sprintf("10.%d.%d.0/24", i/256%256, i%256))
+		case 8:
+			rules = append(rules, fmt.Sprintf("api-*.region%d.example", i))
+		case 9:
+			if i%500 == 9 {
+				rules = append(rules, fmt.Sprintf(`~^cdn[0-9]+\.zone%d\.org$`, i))
+			} else {
+				rules = append(rules, fmt.Sprintf("2001:db8:%x::/48", i))
+			}
+		}
+	}
+
+	for i := 0; i < 300; i++ {
+		k := r.Intn(n + n/10)
+		addrs = append(addrs,
+			fmt.Sprintf("host%d.example%d.com:443", k, k%97),
+			fmt.Sprintf("a.svc%d.internal", k),
+			fmt.Sprintf("svc%d.internal", k),
+			fmt.Sprintf("corp%d.net", k),
+			fmt.Sprintf("x.y.corp%d.net", k),
+			fmt.Sprintf("10.%d.%d.%d:80", k/256%256, k%256, r.Intn(256)),
+			fmt.Sprintf("api-%d.region%d.example", r.Intn(100), k),
+			fmt.Sprintf("cdn%d.zone%d.org", r.Intn(100), k),
+			fmt.Sprintf("[2001:db8:%x::1]:443", k),
+		)
+	}
+	return
+}
+
+func TestRuleSetNaive(t *testing.T) {
+	rules, addrs := largeRules(2000)
+	set, err := CompileRules(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	matched := 0
+	for _, addr := range addrs {
+		got, want := set.Match(addr), naiveMatch(rules, addr)
+		if got != want {
+			t.Fatalf("%s: got %v, the naive evaluator got %v", addr, got, want)
+		}
+		if got {
+			matched++
+		}
+	}
+	// both the hits and the misses are covered.
+	if matched == 0 || matched == len(addrs) {
+		t.Fatalf("%d of %d addresses matched", matched, len(addrs))
+	}
+}
+
+func BenchmarkRuleSetMatch(b *testing.B) {
+	rules, addrs := largeRules(50000)
+	set, err := CompileRules(rules)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		set.Match(addrs[i%len(addrs)])
+	}
+}
+
+func BenchmarkRuleBypassMatch(b *testing.B) {
+	rules, addrs := largeRules(50000)
+	var plain []string
+	for _, rule := range rules {
+		// RuleBypass has no regular expressions.
+		if !strings.HasPrefix(rule, "~") {
+			plain = append(plain, rule)
+		}
+	}
+	bp := RuleBypass(plain, false)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		bp.Contains(context.Background(), "tcp", addrs[i%len(addrs)])
+	}
+}
@@ -0,0 +1,38 @@
+package bypass
+
+import (
+	"github.com/go-gost/core/reload"
+)
+
+// RuleDiff is the difference between two bypass rule sets.
+type RuleDiff struct {
+	Added   []string
+	Removed []string
+}
+
+// IsEmpty reports whether the rule sets are the same.
+func (d *RuleDiff) IsEmpty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0
+}
+
+// Diff reports the rules (patterns) added and removed from old to new, the order of rules is ignored.
+func Diff(old, new []string) RuleDiff {
+	c := reload.Diff(toSet(old), toSet(new), nil)
+
+	var d RuleDiff
+	for _, v := range c.Added {
+		d.Added = append(d.Added, v.Key)
+	}
+	for _, v := range c.Removed {
+		d.Removed = append(d.Removed, v.Key)
+	}
+	return d
+}
+
+func toSet(rules []string) map[string]struct{} {
+	m := make(map[string]struct{}, len(rules))
+	for _, r := range rules {
+		m[r] = struct{}{}
+	}
+	return m
+}
@@ -0,0 +1,30 @@
+package bypass
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	d := Diff(
+		[]string{"*.example.com", "10.0.0.0/8", "192.168.1.1"},
+		[]string{"192.168.1.1", "*.example.org", "10.0.0.0/8", "172.16.0.0/12"},
+	)
+	if !slices.Equal(d.Added, []string{"*.example.org", "172.16.0.0/12"}) {
+		t.Fatalf("unexpected additions %v", d.Added)
+	}
+	if !slices.Equal(d.Removed, []string{"*.example.com"}) {
+		t.Fatalf("unexpected removals %v", d.Removed)
+	}
+}
+
+func TestDiffNoop(t *testing.T) {
+	// the order and duplicates of the rules are ignored.
+	d := Diff([]string{"a.com", "b.com"}, []string{"b.com", "a.com", "a.com"})
+	if !d.IsEmpty() {
+		t.Fatalf("expected empty diff, got %+v", d)
+	}
+	if d := Diff(nil, nil); !d.IsEmpty() {
+		t.Fatalf("expected empty diff, got %+v", d)
+	}
+}
@@ -0,0 +1,88 @@
+package bypass
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"strings"
+)
+
+// RuleTrace is the evaluation result of a rule in an Explanation.
+type RuleTrace struct {
+	Rule    string `json:"rule"`
+	Matched bool   `json:"matched"`
+}
+
+// Explanation is the decision trace of a bypass for an address.
+type Explanation struct {
+	Network string `json:"network"`
+	Addr    string `json:"addr"`
+	// Rules are the evaluated rules in order, it is empty if the bypass does not expose its rules.
+	Rules []RuleTrace `json:"rules,omitempty"`
+	// Rule is the winning rule, if any.
+	Rule      string `json:"rule,omitempty"`
+	Whitelist bool   `json:"whitelist"`
+	// Bypassed is the final action, true means the address is bypassed (see Bypass.Contains).
+	Bypassed bool `json:"bypassed"`
+}
+
+// Explainer is a Bypass which can explain its decision for an address.
+type Explainer interface {
+	Explain(ctx context.Context, network, addr string, opts ...Option) Explanation
+}
+
+// Explain returns the decision trace of bp for the sample address, e.g. to validate the rule changes.
+// It evaluates the rules only, but a bypass doing lookups (e.g. PTRBypass, BogonBypass) may still query the resolver.
+// If bp is not an Explainer, the trace has the final action and the winning rule if bp is a RuleMatcher.
+func Explain(ctx context.Context, bp Bypass, network, addr string, opts ...Option) Explanation {
+	if bp == nil {
+		return Explanation{Network: network, Addr: addr}
+	}
+	if e, ok := bp.(Explainer); ok {
+		return e.Explain(ctx, network, addr, opts...)
+	}
+
+	ex := Explanation{
+		Network:   network,
+		Addr:      addr,
+		Whitelist: bp.IsWhitelist(),
+		Bypassed:  bp.Contains(ctx, network, addr, opts...),
+	}
+	if m, ok := bp.(RuleMatcher); ok {
+		ex.Rule, _ = m.MatchRule(ctx, network, addr, opts...)
+	}
+	return ex
+}
+
+// Explain implements Explainer, all the rules are listed with whether they match addr.
+func (p *ruleBypass) Explain(ctx context.Context, network, addr string, opts ...Option) Explanation {
+	host, ip := ruleTarget(addr)
+
+	ex := Explanation{
+		Network:   network,
+		Addr:      addr,
+		Rules:     make([]RuleTrace, 0, len(p.rules)),
+		Whitelist: p.whitelist,
+	}
+	for i := range p.rules {
+		r := &p.rules[i]
+		_, ok := r.specificity(host, ip)
+		ex.Rules = append(ex.Rules, RuleTrace{Rule: r.rule.String(), Matched: ok})
+	}
+	if winner := p.match(addr); winner != nil {
+		ex.Rule = winner.rule.String()
+	}
+	ex.Bypassed = p.Contains(ctx, network, addr, opts...)
+	return ex
+}
+
+// ruleTarget returns the normalized host and IP address of addr for the rule matching.
+func ruleTarget(addr string) (string, netip.Addr) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	ip, _ := netip.ParseAddr(host)
+	return host, ip.Unmap()
+}
@@ -0,0 +1,76 @@
+package bypass
+
+import (
+	"context"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	bp := RuleBypass([]string{".example.com", "!api.example.com", "10.0.0.0/8", "*.internal"}, false)
+
+	for _, tc := range []struct {
+		addr     string
+		matched  []bool
+		rule     string
+		bypassed bool
+	}{
+		// both the domain rules match, the more specific allow wins.
+		{"api.example.com:443", []bool{true, true, false, false}, "!api.example.com", false},
+		{"www.example.com:443", []bool{true, false, false, false}, ".example.com", true},
+		{"[::ffff:10.1.2.3]:80", []bool{false, false, true, false}, "10.0.0.0/8", true},
+		{"db.internal", []bool{false, false, false, true}, "*.internal", true},
+		{"example.org:80", []bool{false, false, false, false}, "", false},
+	} {
+		ex := Explain(context.Background(), bp, "tcp", tc.addr)
+		if ex.Network != "tcp" || ex.Addr != tc.addr || ex.Whitelist {
+			t.Fatalf("%s: unexpected explanation %+v", tc.addr, ex)
+		}
+		if len(ex.Rules) != len(tc.matched) {
+			t.Fatalf("%s: expected all the rules listed, got %+v", tc.addr, ex.Rules)
+		}
+		for i, rt := range ex.Rules {
+			if rt.Matched != tc.matched[i] {
+				t.Errorf("%s: rule %s matched %v, want %v", tc.addr, rt.Rule, rt.Matched, tc.matched[i])
+			}
+		}
+		if ex.Rule != tc.rule || ex.Bypassed != tc.bypassed {
+			t.Errorf("%s: got rule %q bypassed %v, want %q %v", tc.addr, ex.Rule, ex.Bypassed, tc.rule, tc.bypassed)
+		}
+	}
+	if rules := Explain(context.Background(), bp, "tcp", "a").Rules; rules[1].Rule != "!api.example.com" {
+		t.Fatalf("the negated rule should be listed as is, got %q", rules[1].Rule)
+	}
+}
+
+func TestExplainWhitelist(t *testing.T) {
+	bp := RuleBypass([]string{".example.com"}, true)
+
+	// the matched address is allowed by the whitelist.
+	ex := Explain(context.Background(), bp, "tcp", "www.example.com:443")
+	if !ex.Whitelist || ex.Rule != ".example.com" || ex.Bypassed {
+		t.Fatalf("unexpected explanation %+v", ex)
+	}
+	ex = Explain(context.Background(), bp, "tcp", "example.org:443")
+	if ex.Rule != "" || !ex.Bypassed {
+		t.Fatalf("unexpected explanation %+v", ex)
+	}
+}
+
+func TestExplainOpaque(t *testing.T) {
+	// the bypass not exposing the rules has the final action and the winning rule.
+	bp := InternalBypass(RuleBypass([]string{".example.com", "!api.example.com"}, false))
+	ex := Explain(context.Background(), bp, "tcp", "www.example.com:443")
+	if len(ex.Rules) != 0 || ex.Rule != ".example.com" || !ex.Bypassed {
+		t.Fatalf("unexpected explanation %+v", ex)
+	}
+
+	cidr := CIDRBypass(nil, true)
+	ex = Explain(context.Background(), cidr, "tcp", "192.0.2.1:80")
+	if !ex.Whitelist || !ex.Bypassed || ex.Rule != "" {
+		t.Fatalf("unexpected explanation %+v", ex)
+	}
+
+	if ex := Explain(context.Background(), nil, "udp", "192.0.2.1:53"); ex.Bypassed || ex.Addr != "192.0.2.1:53" {
+		t.Fatalf("unexpected explanation of nil bypass %+v", ex)
+	}
+}
@@ -0,0 +1,39 @@
+package bypass
+
+import (
+	"context"
+
+	"github.com/go-gost/core/common/ctxvalue"
+)
+
+type internalBypass struct {
+	bypass Bypass
+}
+
+// InternalBypass wraps the egress bypass bp, the internal traffic flagged in the context
+// (see ctxvalue.ContextWithInternal) skips it, while the external traffic is subject to bp.
+// So the internal service-to-service traffic does not need the duplicated rules.
+func InternalBypass(bp Bypass) Bypass {
+	return &internalBypass{
+		bypass: bp,
+	}
+}
+
+func (p *internalBypass) IsWhitelist() bool {
+	return p.bypass.IsWhitelist()
+}
+
+func (p *internalBypass) Contains(ctx context.Context, network, addr string, opts ...Option) bool {
+	if ctxvalue.InternalFromContext(ctx) {
+		return false
+	}
+	return p.bypass.Contains(ctx, network, addr, opts...)
+}
+
+// MatchRule implements RuleMatcher if the wrapped bypass does.
+func (p *internalBypass) MatchRule(ctx context.Context, network, addr string, opts ...Option) (string, bool) {
+	if m, ok := p.bypass.(RuleMatcher); ok && !ctxvalue.InternalFromContext(ctx) {
+		return m.MatchRule(ctx, network, addr, opts...)
+	}
+	return "", false
+}
@@ -0,0 +1,55 @@
+package bypass
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-gost/core/common/ctxvalue"
+)
+
+func TestInternalBypass(t *testing.T) {
+	egress := RuleBypass([]string{".example.com", "10.0.0.0/8"}, false)
+	bp := InternalBypass(egress)
+	internal := ctxvalue.ContextWithInternal(context.Background(), true)
+	external := context.Background()
+
+	for _, addr := range []string{"api.example.com:443", "10.1.2.3:80"} {
+		// the unflagged request is subject to the egress rules.
+		if !bp.Contains(external, "tcp", addr) {
+			t.Errorf("%s: the external request should be bypassed", addr)
+		}
+		if rule, ok := bp.(RuleMatcher).MatchRule(external, "tcp", addr); !ok || rule == "" {
+			t.Errorf("%s: expected the matched rule for the external request", addr)
+		}
+		// the internal request skips them.
+		if bp.Contains(internal, "tcp", addr) {
+			t.Errorf("%s: the internal request should skip the egress rules", addr)
+		}
+		if _, ok := bp.(RuleMatcher).MatchRule(internal, "tcp", addr); ok {
+			t.Errorf("%s: no rule should match the internal request", addr)
+		}
+	}
+
+	// the flag set to false is external.
+	if !bp.Contains(ctxvalue.ContextWithInternal(context.Background(), false), "tcp", "api.example.com:443") {
+		t.Fatal("the request flagged not internal should be bypassed")
+	}
+	if bp.Contains(external, "tcp", "example.org:443") {
+		t.Fatal("the unmatched address should not be bypassed")
+	}
+}
+
+func TestInternalBypassWhitelist(t *testing.T) {
+	bp := InternalBypass(RuleBypass([]string{".example.com"}, true))
+	if !bp.IsWhitelist() {
+		t.Fatal("the whitelist should be kept")
+	}
+
+	// the internal request is allowed to the address out of the whitelist.
+	if !bp.Contains(context.Background(), "tcp", "example.org:443") {
+		t.Fatal("the external request out of the whitelist should be bypassed")
+	}
+	if bp.Contains(ctxvalue.ContextWithInternal(context.Background(), true), "tcp", "example.org:443") {
+		t.Fatal("the internal request should skip the whitelist")
+	}
+}
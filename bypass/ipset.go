@@ -0,0 +1,350 @@
+package bypass
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"os"
+	"sort"
+	"strings"
+)
+
+var (
+	errMappedPrefix = errors.New("IPv4-mapped prefix shorter than 96 bits")
+)
+
+// IPSetFormat is the format of an IP set file.
+type IPSetFormat int
+
+const (
+	// IPSetFormatAuto detects the format for each line.
+	IPSetFormatAuto IPSetFormat = iota
+	// IPSetFormatPlain is the newline-delimited IPs or CIDRs.
+	IPSetFormatPlain
+	// IPSetFormatIPSet is the output of `ipset save`, the entries are in the lines `add <set> <entry> [options]`.
+	IPSetFormatIPSet
+	// IPSetFormatNFT is the output of `nft list set`, the entries are in the `elements = { ... }` block.
+	IPSetFormatNFT
+	// IPSetFormatDnsmasq is the dnsmasq configuration, the domains are in the lines `ipset=/<domain>/.../<set>`
+	// or `nftset=/<domain>/.../<set>`, the domains and their subdomains are matched.
+	IPSetFormatDnsmasq
+)
+
+// ParseIPSet parses the IP set in the format from r into prefixes.
+// The comments starting with '#' and blank lines are ignored, as well as the lines
+// carrying no address (e.g. the `create` lines of ipset or the dnsmasq `ipset=/domain/set` lines).
+// The IP ranges (a-b) are converted into the covering prefixes.
+// The domains of the dnsmasq lines are returned by ParseIPSetDomains.
+func ParseIPSet(r io.Reader, format IPSetFormat) ([]netip.Prefix, error) {
+	prefixes, _, err := ParseIPSetDomains(r, format)
+	return prefixes, err
+}
+
+// ParseIPSetDomains is like ParseIPSet, the domains of the dnsmasq `ipset=` and `nftset=` lines are also returned
+// in the IPSetFormatDnsmasq and IPSetFormatAuto formats.
+func ParseIPSetDomains(r io.Reader, format IPSetFormat) (prefixes []netip.Prefix, domains []string, err error) {
+	inElements := false
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		var entries []string
+		// the unknown lines are skipped in auto mode, e.g. the table and set declarations of nft.
+		lenient := false
+		switch {
+		case inElements || ((format == IPSetFormatNFT || format == IPSetFormatAuto) && strings.HasPrefix(line, "elements")):
+			if !inElements {
+				i := strings.IndexByte(line, '{')
+				if i < 0 {
+					return nil, nil, fmt.Errorf("line %d: invalid elements", n)
+				}
+				line = line[i+1:]
+				inElements = true
+			}
+			if i := strings.IndexByte(line, '}'); i >= 0 {
+				line = line[:i]
+				inElements = false
+			}
+			entries = strings.FieldsFunc(line, func(r rune) bool {
+				return r == ',' || r == ' ' || r == '\t'
+			})
+		case format == IPSetFormatNFT:
+			continue
+		case (format == IPSetFormatIPSet || format == IPSetFormatAuto) && strings.HasPrefix(line, "add "):
+			fields := strings.Fields(line)
+			if len(fields) < 3 {
+				return nil, nil, fmt.Errorf("line %d: invalid entry", n)
+			}
+			entries = fields[2:3]
+		case format == IPSetFormatIPSet:
+			continue
+		case (format == IPSetFormatDnsmasq || format == IPSetFormatAuto) &&
+			(strings.HasPrefix(line, "ipset=") || strings.HasPrefix(line, "nftset=")):
+			ds, err := parseDnsmasqSet(line)
+			if err != nil {
+				return nil, nil, fmt.Errorf("line %d: %w", n, err)
+			}
+			domains = append(domains, ds...)
+			continue
+		case format == IPSetFormatDnsmasq:
+			continue
+		default:
+			if strings.HasPrefix(line, "create ") || strings.Contains(line, "=") {
+				// not an IP entry, e.g. the ipset create lines or the dnsmasq domain mappings.
+				continue
+			}
+			entries = strings.Fields(line)[:1]
+			lenient = format == IPSetFormatAuto
+		}
+
+		for _, entry := range entries {
+			ps, err := parseIPSetEntry(entry)
+			if err != nil {
+				// the invalid addresses are not skipped as the lines carrying no address.
+				if lenient && !errors.Is(err, errMappedPrefix) {
+					continue
+				}
+				return nil, nil, fmt.Errorf("line %d: %w", n, err)
+			}
+			prefixes = append(prefixes, ps...)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	return prefixes, domains, nil
+}
+
+// parseDnsmasqSet parses the domains of the dnsmasq line `ipset=/<domain>/.../<set>`,
+// the domain # (all the domains) is not supported.
+func parseDnsmasqSet(line string) ([]string, error) {
+	_, v, _ := strings.Cut(line, "=")
+	fields := strings.Split(v, "/")
+	if len(fields) < 3 || fields[0] != "" {
+		return nil, fmt.Errorf("invalid dnsmasq set %q", line)
+	}
+
+	var domains []string
+	for _, domain := range fields[1 : len(fields)-1] {
+		domain = strings.Trim(strings.ToLower(strings.TrimSpace(domain)), ".")
+		if domain == "" {
+			continue
+		}
+		domains = append(domains, domain)
+	}
+	if len(domains) == 0 {
+		return nil, fmt.Errorf("invalid dnsmasq set %q: no domain", line)
+	}
+	return domains, nil
+}
+
+func parseIPSetEntry(s string) ([]netip.Prefix, error) {
+	if from, to, ok := strings.Cut(s, "-"); ok {
+		start, err := netip.ParseAddr(strings.TrimSpace(from))
+		if err != nil {
+			return nil, err
+		}
+		end, err := netip.ParseAddr(strings.TrimSpace(to))
+		if err != nil {
+			return nil, err
+		}
+		return rangePrefixes(start.Unmap(), end.Unmap())
+	}
+
+	if strings.Contains(s, "/") {
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return nil, err
+		}
+		if p.Addr().Is4In6() {
+			if p.Bits() < 96 {
+				return nil, fmt.Errorf("%s: %w", s, errMappedPrefix)
+			}
+			p = netip.PrefixFrom(p.Addr().Unmap(), p.Bits()-96)
+		}
+		return []netip.Prefix{p.Masked()}, nil
+	}
+
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return nil, err
+	}
+	addr = addr.Unmap()
+	return []netip.Prefix{netip.PrefixFrom(addr, addr.BitLen())}, nil
+}
+
+// rangePrefixes returns the minimal prefixes covering the addresses from start to end inclusive.
+func rangePrefixes(start, end netip.Addr) ([]netip.Prefix, error) {
+	if start.BitLen() != end.BitLen() || end.Less(start) {
+		return nil, fmt.Errorf("invalid range %s-%s", start, end)
+	}
+
+	var prefixes []netip.Prefix
+	for {
+		bits := start.BitLen()
+		for b := 0; b <= start.BitLen(); b++ {
+			p := netip.PrefixFrom(start, b).Masked()
+			if p.Addr() == start && !end.Less(lastAddr(p)) {
+				bits = b
+				break
+			}
+		}
+		p := netip.PrefixFrom(start, bits)
+		prefixes = append(prefixes, p)
+
+		last := lastAddr(p)
+		if last == end {
+			return prefixes, nil
+		}
+		start = last.Next()
+	}
+}
+
+func lastAddr(p netip.Prefix) netip.Addr {
+	b := p.Addr().As16()
+	offset := 0
+	if p.Addr().Is4() {
+		offset = 96
+	}
+	for i := p.Bits() + offset; i < 128; i++ {
+		b[i/8] |= 0x80 >> (i % 8)
+	}
+	addr := netip.AddrFrom16(b)
+	if p.Addr().Is4() {
+		addr = addr.Unmap()
+	}
+	return addr
+}
+
+// CIDRMatcher matches the IPs against a set of prefixes,
+// the lookup costs one map access per distinct prefix length.
+type CIDRMatcher struct {
+	// sets are the masked prefixes grouped by the prefix length, in the descending order of the length.
+	sets []cidrSet
+}
+
+type cidrSet struct {
+	bits     int
+	is4      bool
+	prefixes map[netip.Prefix]struct{}
+}
+
+func NewCIDRMatcher(prefixes []netip.Prefix) *CIDRMatcher {
+	index := make(map[[2]int]*cidrSet)
+	for _, p := range prefixes {
+		if !p.IsValid() {
+			continue
+		}
+		p = p.Masked()
+		k := [2]int{p.Bits(), 6}
+		if p.Addr().Is4() {
+			k[1] = 4
+		}
+		set := index[k]
+		if set == nil {
+			set = &cidrSet{
+				bits:     p.Bits(),
+				is4:      p.Addr().Is4(),
+				prefixes: make(map[netip.Prefix]struct{}),
+			}
+			index[k] = set
+		}
+		set.prefixes[p] = struct{}{}
+	}
+
+	m := &CIDRMatcher{}
+	for _, set := range index {
+		m.sets = append(m.sets, *set)
+	}
+	sort.Slice(m.sets, func(i, j int) bool {
+		return m.sets[i].bits > m.sets[j].bits
+	})
+	return m
+}
+
+func (m *CIDRMatcher) Match(addr netip.Addr) bool {
+	if m == nil || !addr.IsValid() {
+		return false
+	}
+	addr = addr.Unmap()
+	for i := range m.sets {
+		set := &m.sets[i]
+		if set.is4 != addr.Is4() {
+			continue
+		}
+		p, _ := addr.Prefix(set.bits)
+		if _, ok := set.prefixes[p]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+type cidrBypass struct {
+	matcher   *CIDRMatcher
+	whitelist bool
+}
+
+// CIDRBypass is a bypass of the IP addresses in the prefixes, the domain names are never matched.
+// In whitelist mode, the addresses not in the prefixes are contained.
+func CIDRBypass(prefixes []netip.Prefix, whitelist bool) Bypass {
+	return &cidrBypass{
+		matcher:   NewCIDRMatcher(prefixes),
+		whitelist: whitelist,
+	}
+}
+
+// LoadIPSetBypass loads the IP set file in the format and creates a CIDRBypass from it.
+// If the file has the dnsmasq domains, a RuleSetBypass of the prefixes and the domains (with their subdomains) is created.
+func LoadIPSetBypass(path string, format IPSetFormat, whitelist bool) (Bypass, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	prefixes, domains, err := ParseIPSetDomains(f, format)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(domains) == 0 {
+		return CIDRBypass(prefixes, whitelist), nil
+	}
+
+	set := &RuleSet{
+		exact:   make(map[string]struct{}),
+		domains: &domainTrie{},
+		cidrs:   NewCIDRMatcher(prefixes),
+	}
+	for _, domain := range domains {
+		set.domains.insert(domain, true, true)
+	}
+	return RuleSetBypass(set, whitelist), nil
+}
+
+func (p *cidrBypass) IsWhitelist() bool {
+	return p.whitelist
+}
+
+func (p *cidrBypass) Contains(ctx context.Context, network, addr string, opts ...Option) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	ip, err := netip.ParseAddr(host)
+	matched := err == nil && p.matcher.Match(ip)
+	return matched != p.whitelist
+}
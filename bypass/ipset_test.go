@@ -0,0 +1,241 @@
+package bypass
+
+import (
+	"context"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const plainIPSet = `
+# private ranges
+10.0.0.0/8
+192.168.1.1   # a single host
+
+2001:db8::/32
+172.16.0.1-172.16.0.6
+::ffff:198.51.100.0/120
+`
+
+const dnsmasqIPSet = `
+# dnsmasq.conf
+ipset=/example.com/proxy
+ipset=/example.org/proxy
+
+create proxy hash:net family inet hashsize 1024 maxelem 65536
+add proxy 10.0.0.0/8
+add proxy 192.168.1.1 timeout 0
+add proxy 2001:db8::/32
+`
+
+const dnsmasqConf = `
+# dnsmasq.conf
+server=/example.net/192.0.2.53
+ipset=/example.com/.Example.ORG./proxy,proxy6
+nftset=/example.io/4#inet#filter#proxy
+`
+
+const nftIPSet = `
+table inet filter {
+	set proxy {
+		type ipv4_addr
+		flags interval
+		elements = { 10.0.0.0/8, 192.168.1.1,
+			     2001:db8::/32 }
+	}
+}
+`
+
+func TestParseIPSet(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		data   string
+		format IPSetFormat
+		want   []string
+	}{
+		{"plain", plainIPSet, IPSetFormatPlain, []string{
+			"10.0.0.0/8", "192.168.1.1/32", "2001:db8::/32",
+			"172.16.0.1/32", "172.16.0.2/31", "172.16.0.4/31", "172.16.0.6/32",
+			"198.51.100.0/24",
+		}},
+		{"ipset", dnsmasqIPSet, IPSetFormatIPSet, []string{"10.0.0.0/8", "192.168.1.1/32", "2001:db8::/32"}},
+		{"nft", nftIPSet, IPSetFormatNFT, []string{"10.0.0.0/8", "192.168.1.1/32", "2001:db8::/32"}},
+		{"auto ipset", dnsmasqIPSet, IPSetFormatAuto, []string{"10.0.0.0/8", "192.168.1.1/32", "2001:db8::/32"}},
+		{"auto nft", nftIPSet, IPSetFormatAuto, []string{"10.0.0.0/8", "192.168.1.1/32", "2001:db8::/32"}},
+	} {
+		prefixes, err := ParseIPSet(strings.NewReader(tc.data), tc.format)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		var got []string
+		for _, p := range prefixes {
+			got = append(got, p.String())
+		}
+		if strings.Join(got, " ") != strings.Join(tc.want, " ") {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestParseIPSetInvalid(t *testing.T) {
+	for _, tc := range []struct {
+		data   string
+		format IPSetFormat
+	}{
+		{"10.0.0.0/33", IPSetFormatPlain},
+		{"192.168.1.9-192.168.1.1", IPSetFormatPlain},
+		{"add proxy", IPSetFormatIPSet},
+		{"elements = 10.0.0.1", IPSetFormatNFT},
+		{"elements = { 10.0.0.300 }", IPSetFormatAuto},
+		// the IPv4-mapped prefix shorter than 96 bits has no IPv4 equivalent.
+		{"::ffff:10.0.0.0/80", IPSetFormatPlain},
+		{"::ffff:10.0.0.0/80", IPSetFormatAuto},
+	} {
+		if _, err := ParseIPSet(strings.NewReader(tc.data), tc.format); err == nil {
+			t.Errorf("%q in format %d: expected error", tc.data, tc.format)
+		}
+	}
+
+	for _, data := range []string{"ipset=example.com/proxy", "ipset=/proxy", "ipset=/#/proxy", "ipset=//proxy"} {
+		if _, _, err := ParseIPSetDomains(strings.NewReader(data), IPSetFormatDnsmasq); err == nil {
+			t.Errorf("%q: expected error", data)
+		}
+	}
+
+	// the lines carrying no address are skipped in auto mode.
+	if ps, err := ParseIPSet(strings.NewReader("server=8.8.8.8\nnot-an-ip"), IPSetFormatAuto); err != nil || len(ps) != 0 {
+		t.Fatalf("expected no prefix, got %v %v", ps, err)
+	}
+}
+
+func TestParseIPSetDomains(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		data     string
+		format   IPSetFormat
+		prefixes int
+		domains  []string
+	}{
+		{"dnsmasq", dnsmasqConf, IPSetFormatDnsmasq, 0, []string{"example.com", "example.org", "example.io"}},
+		{"auto dnsmasq", dnsmasqConf, IPSetFormatAuto, 0, []string{"example.com", "example.org", "example.io"}},
+		{"auto mixed", dnsmasqIPSet, IPSetFormatAuto, 3, []string{"example.com", "example.org"}},
+		// the domains are not in the ipset dumps.
+		{"ipset", dnsmasqIPSet, IPSetFormatIPSet, 3, nil},
+	} {
+		prefixes, domains, err := ParseIPSetDomains(strings.NewReader(tc.data), tc.format)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if len(prefixes) != tc.prefixes || strings.Join(domains, " ") != strings.Join(tc.domains, " ") {
+			t.Errorf("%s: got %v %v, want %d prefixes and %v", tc.name, prefixes, domains, tc.prefixes, tc.domains)
+		}
+	}
+}
+
+func TestLoadIPSetBypassDnsmasq(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dnsmasq.conf")
+	if err := os.WriteFile(path, []byte(dnsmasqConf+dnsmasqIPSet), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	bp, err := LoadIPSetBypass(path, IPSetFormatAuto, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		addr  string
+		match bool
+	}{
+		{"example.com:443", true},
+		{"www.example.com:443", true},
+		{"api.example.org", true},
+		{"example.io:80", true},
+		{"example.net:80", false},
+		{"notexample.com:443", false},
+		{"10.1.2.3:80", true},
+		{"[2001:db8::1]:443", true},
+		{"11.0.0.1:80", false},
+	} {
+		if got := bp.Contains(context.Background(), "tcp", tc.addr); got != tc.match {
+			t.Errorf("%s: got %v, want %v", tc.addr, got, tc.match)
+		}
+	}
+}
+
+func TestCIDRBypass(t *testing.T) {
+	prefixes, err := ParseIPSet(strings.NewReader(plainIPSet), IPSetFormatAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bp := CIDRBypass(prefixes, false)
+
+	for _, tc := range []struct {
+		addr     string
+		contains bool
+	}{
+		{"10.1.2.3:80", true},
+		{"192.168.1.1:443", true},
+		{"192.168.1.2:443", false},
+		{"[2001:db8::1]:443", true},
+		{"[::ffff:10.0.0.1]:80", true},
+		{"172.16.0.5", true},
+		{"172.16.0.7", false},
+		{"198.51.100.200:53", true},
+		{"8.8.8.8:53", false},
+		{"example.com:80", false},
+	} {
+		if got := bp.Contains(context.Background(), "tcp", tc.addr); got != tc.contains {
+			t.Errorf("%s: got %v, want %v", tc.addr, got, tc.contains)
+		}
+	}
+
+	// whitelist
+	wl := CIDRBypass(prefixes, true)
+	if wl.Contains(context.Background(), "tcp", "10.1.2.3:80") || !wl.Contains(context.Background(), "tcp", "8.8.8.8:53") {
+		t.Fatal("unexpected whitelist result")
+	}
+}
+
+func TestCIDRMatcher(t *testing.T) {
+	m := NewCIDRMatcher([]netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("10.1.1.1/16"),
+		netip.MustParsePrefix("::/0"),
+		{},
+	})
+	for _, tc := range []struct {
+		addr  string
+		match bool
+	}{
+		{"10.255.0.1", true},
+		{"11.0.0.1", false},
+		{"2001:db8::1", true},
+	} {
+		if got := m.Match(netip.MustParseAddr(tc.addr)); got != tc.match {
+			t.Errorf("%s: got %v, want %v", tc.addr, got, tc.match)
+		}
+	}
+	if m.Match(netip.Addr{}) {
+		t.Fatal("the invalid address should not match")
+	}
+}
+
+func TestLoadIPSetBypass(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "proxy.nft")
+	if err := os.WriteFile(path, []byte(nftIPSet), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	bp, err := LoadIPSetBypass(path, IPSetFormatNFT, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bp.Contains(context.Background(), "tcp", "10.0.0.1:80") || bp.Contains(context.Background(), "tcp", "11.0.0.1:80") {
+		t.Fatal("unexpected bypass result of the loaded set")
+	}
+
+	if _, err := LoadIPSetBypass(filepath.Join(t.TempDir(), "missing"), IPSetFormatAuto, false); err == nil {
+		t.Fatal("expected error of a missing file")
+	}
+}
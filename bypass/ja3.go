@@ -0,0 +1,51 @@
+package bypass
+
+import (
+	"context"
+	"strings"
+
+	"github.com/go-gost/core/common/ctxvalue"
+)
+
+type ja3Bypass struct {
+	fingerprints map[string]struct{}
+	whitelist    bool
+}
+
+// JA3Bypass is a bypass of the TLS clients by the JA3 fingerprint hashes, e.g. to block the known bot stacks.
+// The fingerprint is taken from the JA3 option or the context (see ctxvalue.ContextWithJA3),
+// the connections without a fingerprint are not matched.
+func JA3Bypass(whitelist bool, fingerprints ...string) Bypass {
+	m := make(map[string]struct{}, len(fingerprints))
+	for _, s := range fingerprints {
+		m[strings.ToLower(s)] = struct{}{}
+	}
+	return &ja3Bypass{
+		fingerprints: m,
+		whitelist:    whitelist,
+	}
+}
+
+func (p *ja3Bypass) IsWhitelist() bool {
+	return p.whitelist
+}
+
+func (p *ja3Bypass) Contains(ctx context.Context, network, addr string, opts ...Option) bool {
+	var options Options
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+
+	ja3 := options.JA3
+	if ja3 == "" {
+		ja3 = ctxvalue.JA3FromContext(ctx)
+	}
+	if ja3 == "" {
+		return false
+	}
+
+	_, ok := p.fingerprints[strings.ToLower(ja3)]
+	return ok != p.whitelist
+}
@@ -0,0 +1,42 @@
+package bypass
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-gost/core/common/ctxvalue"
+)
+
+const (
+	botJA3   = "0f375cda5fca92c20fe61d8590c24346"
+	otherJA3 = "e7d705a3286e19ea42f587b344ee6865"
+)
+
+func TestJA3Bypass(t *testing.T) {
+	bp := JA3Bypass(false, "0F375CDA5FCA92C20FE61D8590C24346")
+
+	if !bp.Contains(context.Background(), "tcp", "example.com:443", WithJA3Option(botJA3)) {
+		t.Error("the bot fingerprint should be contained")
+	}
+	if bp.Contains(context.Background(), "tcp", "example.com:443", WithJA3Option(otherJA3)) {
+		t.Error("the other fingerprint should not be contained")
+	}
+
+	// the fingerprint from the context.
+	ctx := ctxvalue.ContextWithJA3(context.Background(), "0F375cda5fca92c20fe61d8590c24346")
+	if !bp.Contains(ctx, "tcp", "example.com:443") {
+		t.Error("the fingerprint from the context should be contained")
+	}
+
+	// no fingerprint, not matched in either mode.
+	if bp.Contains(context.Background(), "tcp", "example.com:443") {
+		t.Error("the connection without fingerprint should not be contained")
+	}
+	wl := JA3Bypass(true, botJA3)
+	if wl.Contains(context.Background(), "tcp", "example.com:443") {
+		t.Error("the connection without fingerprint should not be contained by the whitelist")
+	}
+	if wl.Contains(ctx, "tcp", "example.com:443") || !wl.Contains(context.Background(), "tcp", "example.com:443", WithJA3Option(otherJA3)) {
+		t.Error("unexpected whitelist result")
+	}
+}
@@ -0,0 +1,67 @@
+package bypass
+
+import (
+	"context"
+
+	"github.com/go-gost/core/common/ctxvalue"
+	"github.com/go-gost/core/observer"
+)
+
+// RuleMatcher is a Bypass which can report the rule matching the address.
+type RuleMatcher interface {
+	MatchRule(ctx context.Context, network, addr string, opts ...Option) (rule string, ok bool)
+}
+
+type observedBypass struct {
+	Bypass
+	notifier *observer.DenyNotifier
+}
+
+// ObservedBypass wraps the bypass bp and notifies a deny event for each contained address.
+// The reason is DenyWhitelist for a whitelist, DenyRebinding for the BogonBypass, otherwise DenyBypass.
+// The matched rule is reported if bp is a RuleMatcher.
+func ObservedBypass(bp Bypass, n *observer.DenyNotifier) Bypass {
+	if bp == nil || n == nil {
+		return bp
+	}
+	return &observedBypass{
+		Bypass:   bp,
+		notifier: n,
+	}
+}
+
+func (p *observedBypass) Contains(ctx context.Context, network, addr string, opts ...Option) bool {
+	if !p.Bypass.Contains(ctx, network, addr, opts...) {
+		return false
+	}
+
+	var options Options
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+
+	reason := observer.DenyBypass
+	if _, ok := p.Bypass.(*bogonBypass); ok {
+		reason = observer.DenyRebinding
+	} else if p.Bypass.IsWhitelist() {
+		reason = observer.DenyWhitelist
+	}
+
+	var rule string
+	if m, ok := p.Bypass.(RuleMatcher); ok {
+		rule, _ = m.MatchRule(ctx, network, addr, opts...)
+	}
+
+	p.notifier.Notify(observer.DenyEvent{
+		Reason:  reason,
+		Service: options.Service,
+		Client:  ctxvalue.ClientAddrFromContext(ctx),
+		TraceID: ctxvalue.TraceIDFromContext(ctx),
+		Network: network,
+		Addr:    addr,
+		Rule:    rule,
+	})
+	return true
+}
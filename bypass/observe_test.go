@@ -0,0 +1,93 @@
+package bypass
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-gost/core/common/ctxvalue"
+	"github.com/go-gost/core/observer"
+)
+
+// denyObserver sends the deny events to a channel.
+type denyObserver chan observer.DenyEvent
+
+func (o denyObserver) Observe(ctx context.Context, events []observer.Event, opts ...observer.Option) error {
+	for _, ev := range events {
+		if de, ok := ev.(observer.DenyEvent); ok {
+			o <- de
+		}
+	}
+	return nil
+}
+
+func nextDeny(t *testing.T, o denyObserver) observer.DenyEvent {
+	t.Helper()
+	select {
+	case ev := <-o:
+		return ev
+	case <-time.After(time.Second):
+		t.Fatal("no deny event")
+	}
+	return observer.DenyEvent{}
+}
+
+func noDeny(t *testing.T, o denyObserver) {
+	t.Helper()
+	select {
+	case ev := <-o:
+		t.Fatalf("unexpected deny event %+v", ev)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestObservedBypass(t *testing.T) {
+	o := make(denyObserver, 10)
+	n := observer.NewDenyNotifier(o, 0)
+	defer n.Close()
+
+	ctx := ctxvalue.ContextWithClientAddr(context.Background(), "192.0.2.1:12345")
+	ctx = ctxvalue.ContextWithTraceID(ctx, "trace-1")
+
+	for _, tc := range []struct {
+		name   string
+		bp     Bypass
+		addr   string
+		reason observer.DenyReason
+		rule   string
+	}{
+		{"bypass", RuleBypass([]string{"*.example.com", "10.0.0.0/8"}, false), "www.example.com:443", observer.DenyBypass, "*.example.com"},
+		{"whitelist", RuleBypass([]string{"*.example.org"}, true), "www.example.com:443", observer.DenyWhitelist, ""},
+		{"rebinding", BogonBypass(bogonResolver), "internal.example.com:80", observer.DenyRebinding, ""},
+		{"internal", InternalBypass(RuleBypass([]string{"10.0.0.0/8"}, false)), "10.1.2.3:80", observer.DenyBypass, "10.0.0.0/8"},
+	} {
+		bp := ObservedBypass(tc.bp, n)
+		if !bp.Contains(ctx, "tcp", tc.addr, WithService("svc")) {
+			t.Fatalf("%s: %s should be contained", tc.name, tc.addr)
+		}
+		ev := nextDeny(t, o)
+		if ev.Reason != tc.reason || ev.Rule != tc.rule {
+			t.Errorf("%s: unexpected reason %q rule %q", tc.name, ev.Reason, ev.Rule)
+		}
+		if ev.Addr != tc.addr || ev.Network != "tcp" || ev.Service != "svc" || ev.Client != "192.0.2.1:12345" || ev.TraceID != "trace-1" {
+			t.Errorf("%s: unexpected event %+v", tc.name, ev)
+		}
+	}
+
+	// the allowed addresses produce no event.
+	bp := ObservedBypass(RuleBypass([]string{"*.example.com"}, false), n)
+	if bp.Contains(ctx, "tcp", "example.org:443") {
+		t.Fatal("example.org should not be contained")
+	}
+	noDeny(t, o)
+}
+
+func TestObservedBypassNil(t *testing.T) {
+	bp := RuleBypass(nil, false)
+	if ObservedBypass(bp, nil) != bp {
+		t.Fatal("the bypass should not be wrapped without notifier")
+	}
+	if ObservedBypass(nil, observer.NewDenyNotifier(nil, 1)) != nil {
+		t.Fatal("nil bypass should be kept")
+	}
+}
@@ -0,0 +1,149 @@
+package bypass
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultPTRTTL     = 5 * time.Minute
+	defaultPTRTimeout = 2 * time.Second
+	maxPTRCacheSize   = 4096
+)
+
+// PTRLookuper performs the reverse DNS lookups, it is implemented by *net.Resolver.
+type PTRLookuper interface {
+	LookupAddr(ctx context.Context, addr string) (names []string, err error)
+}
+
+type PTROptions struct {
+	// TTL is the duration the lookup results are cached for.
+	TTL time.Duration
+	// Timeout bounds each lookup.
+	Timeout time.Duration
+}
+
+type PTROption func(opts *PTROptions)
+
+func TTLPTROption(ttl time.Duration) PTROption {
+	return func(opts *PTROptions) {
+		opts.TTL = ttl
+	}
+}
+
+func TimeoutPTROption(timeout time.Duration) PTROption {
+	return func(opts *PTROptions) {
+		opts.Timeout = timeout
+	}
+}
+
+type ptrEntry struct {
+	names   []string
+	expires time.Time
+}
+
+type ptrBypass struct {
+	bypass   Bypass
+	lookuper PTRLookuper
+	options  PTROptions
+	cache    map[string]ptrEntry
+	mu       sync.Mutex
+}
+
+// PTRBypass wraps the bypass bp to match the IP destinations by their reverse DNS names against the domain rules of bp,
+// e.g. bypassing anything reverse-resolving to *.amazonaws.com. The lookups are cached and time-bounded,
+// an IP failing the lookup is not contained (fail-open). The domain destinations are matched by bp as is.
+func PTRBypass(bp Bypass, l PTRLookuper, opts ...PTROption) Bypass {
+	options := PTROptions{
+		TTL:     defaultPTRTTL,
+		Timeout: defaultPTRTimeout,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+	if l == nil {
+		l = net.DefaultResolver
+	}
+
+	return &ptrBypass{
+		bypass:   bp,
+		lookuper: l,
+		options:  options,
+		cache:    make(map[string]ptrEntry),
+	}
+}
+
+func (p *ptrBypass) IsWhitelist() bool {
+	return p.bypass.IsWhitelist()
+}
+
+func (p *ptrBypass) Contains(ctx context.Context, network, addr string, opts ...Option) bool {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	if net.ParseIP(host) == nil {
+		return p.bypass.Contains(ctx, network, addr, opts...)
+	}
+
+	names := p.lookup(ctx, host)
+	if len(names) == 0 {
+		return false
+	}
+	for _, name := range names {
+		target := strings.TrimSuffix(name, ".")
+		if port != "" {
+			target = net.JoinHostPort(target, port)
+		}
+		contained := p.bypass.Contains(ctx, network, target, opts...)
+		// in a whitelist, an address is allowed if any of its names is listed.
+		if p.bypass.IsWhitelist() != contained {
+			return contained
+		}
+	}
+	return p.bypass.IsWhitelist()
+}
+
+func (p *ptrBypass) lookup(ctx context.Context, ip string) []string {
+	now := time.Now()
+
+	p.mu.Lock()
+	if e, ok := p.cache[ip]; ok && now.Before(e.expires) {
+		p.mu.Unlock()
+		return e.names
+	}
+	p.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, p.options.Timeout)
+	defer cancel()
+
+	names, err := p.lookuper.LookupAddr(ctx, ip)
+	if err != nil && ctx.Err() != nil {
+		// do not cache the timeouts, the lookup is retried next time.
+		return nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.cache) >= maxPTRCacheSize {
+		for k, e := range p.cache {
+			if !now.Before(e.expires) {
+				delete(p.cache, k)
+			}
+		}
+		if len(p.cache) >= maxPTRCacheSize {
+			clear(p.cache)
+		}
+	}
+	p.cache[ip] = ptrEntry{
+		names:   names,
+		expires: now.Add(p.options.TTL),
+	}
+	return names
+}
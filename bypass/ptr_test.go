@@ -0,0 +1,145 @@
+package bypass
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// ptrResolver answers the PTR names of the IPs, the IPs not in names fail, and counts the lookups.
+type ptrResolver struct {
+	names map[string][]string
+	// slow is the IP whose lookup blocks until the context is done.
+	slow  string
+	count map[string]int
+	mu    sync.Mutex
+}
+
+func (r *ptrResolver) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	r.mu.Lock()
+	if r.count == nil {
+		r.count = make(map[string]int)
+	}
+	r.count[addr]++
+	r.mu.Unlock()
+
+	if addr == r.slow {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	if names, ok := r.names[addr]; ok {
+		return names, nil
+	}
+	return nil, errors.New("lookup: server misbehaving")
+}
+
+func (r *ptrResolver) lookups(addr string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.count[addr]
+}
+
+func newPTRResolver() *ptrResolver {
+	return &ptrResolver{
+		names: map[string][]string{
+			"198.51.100.1": {"ec2-198-51-100-1.compute-1.amazonaws.com."},
+			"198.51.100.2": {"host.example.org."},
+			"2001:db8::1":  {"host.example.org.", "s3.us-east-1.AMAZONAWS.com."},
+		},
+		slow: "198.51.100.9",
+	}
+}
+
+func TestPTRBypass(t *testing.T) {
+	r := newPTRResolver()
+	bp := PTRBypass(RuleBypass([]string{"*.amazonaws.com"}, false), r, TimeoutPTROption(50*time.Millisecond))
+
+	for _, tc := range []struct {
+		addr     string
+		contains bool
+	}{
+		// the domain rule matches the IP by its PTR name.
+		{"198.51.100.1:443", true},
+		{"198.51.100.2:443", false},
+		{"[2001:db8::1]:443", true},
+		{"198.51.100.1", true},
+		// the lookup failure fails open.
+		{"198.51.100.3:443", false},
+		// the domain destination is matched as is.
+		{"s3.amazonaws.com:443", true},
+		{"example.org:443", false},
+	} {
+		if got := bp.Contains(context.Background(), "tcp", tc.addr); got != tc.contains {
+			t.Errorf("%s: got %v, want %v", tc.addr, got, tc.contains)
+		}
+	}
+
+	// the lookups are cached, the failures as well.
+	for i := 0; i < 3; i++ {
+		bp.Contains(context.Background(), "tcp", "198.51.100.1:443")
+		bp.Contains(context.Background(), "tcp", "198.51.100.3:443")
+	}
+	if n, m := r.lookups("198.51.100.1"), r.lookups("198.51.100.3"); n != 1 || m != 1 {
+		t.Fatalf("the lookups should be cached, got %d %d", n, m)
+	}
+	if n := r.lookups("s3.amazonaws.com"); n != 0 {
+		t.Fatalf("the domain should not be looked up, got %d", n)
+	}
+}
+
+func TestPTRBypassTimeout(t *testing.T) {
+	r := newPTRResolver()
+	bp := PTRBypass(RuleBypass([]string{"*.amazonaws.com"}, false), r, TimeoutPTROption(20*time.Millisecond))
+
+	// the lookup is time-bounded and fails open, the timeout is not cached.
+	for i := 0; i < 2; i++ {
+		start := time.Now()
+		if bp.Contains(context.Background(), "tcp", "198.51.100.9:443") {
+			t.Fatal("the timed out lookup should fail open")
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Fatalf("the lookup is not bounded, took %s", elapsed)
+		}
+	}
+	if n := r.lookups("198.51.100.9"); n != 2 {
+		t.Fatalf("the timeout should not be cached, got %d lookups", n)
+	}
+}
+
+func TestPTRBypassTTL(t *testing.T) {
+	r := newPTRResolver()
+	bp := PTRBypass(RuleBypass([]string{"*.amazonaws.com"}, false), r, TTLPTROption(20*time.Millisecond))
+
+	bp.Contains(context.Background(), "tcp", "198.51.100.1:443")
+	time.Sleep(30 * time.Millisecond)
+	if !bp.Contains(context.Background(), "tcp", "198.51.100.1:443") {
+		t.Fatal("the IP should be matched after the cache expires")
+	}
+	if n := r.lookups("198.51.100.1"); n != 2 {
+		t.Fatalf("the expired entry should be looked up again, got %d lookups", n)
+	}
+}
+
+func TestPTRBypassWhitelist(t *testing.T) {
+	bp := PTRBypass(RuleBypass([]string{"*.amazonaws.com"}, true), newPTRResolver())
+	if !bp.IsWhitelist() {
+		t.Fatal("expected whitelist")
+	}
+
+	for _, tc := range []struct {
+		addr     string
+		contains bool
+	}{
+		{"198.51.100.1:443", false},
+		{"198.51.100.2:443", true},
+		// allowed if any of the names is listed.
+		{"[2001:db8::1]:443", false},
+		{"198.51.100.3:443", false},
+	} {
+		if got := bp.Contains(context.Background(), "tcp", tc.addr); got != tc.contains {
+			t.Errorf("%s: got %v, want %v", tc.addr, got, tc.contains)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package bypass
+
+import (
+	"context"
+	"net/netip"
+	"strings"
+)
+
+// Rule is a bypass rule.
+//
+// The pattern is one of:
+//   - an IP address or a CIDR, e.g. 192.168.0.0/16;
+//   - a domain name, e.g. example.com, which matches the name exactly;
+//   - a wildcard domain, e.g. *.example.com, which matches the subdomains of example.com;
+//   - a domain with the leading dot, e.g. .example.com, which matches example.com and its subdomains.
+//
+// A rule prefixed with '!' is a negation, which excludes the matched addresses from the list.
+type Rule struct {
+	Pattern string
+	Negate  bool
+}
+
+func (r Rule) String() string {
+	if r.Negate {
+		return "!" + r.Pattern
+	}
+	return r.Pattern
+}
+
+func ParseRule(s string) Rule {
+	s = strings.TrimSpace(s)
+	if v, ok := strings.CutPrefix(s, "!"); ok {
+		return Rule{Pattern: strings.TrimSpace(v), Negate: true}
+	}
+	return Rule{Pattern: s}
+}
+
+type compiledRule struct {
+	rule   Rule
+	prefix netip.Prefix
+	// domain is the lower-cased domain without the wildcard part.
+	domain string
+	// suffix is set if the rule matches the subdomains, exact is set if it matches the domain itself.
+	suffix bool
+	exact  bool
+}
+
+// specificity returns the precedence of the rule matching the target, the higher wins.
+// A domain match is more specific with more labels, and an exact match beats a suffix match of the same domain.
+// An IP match is more specific with a longer prefix.
+func (r *compiledRule) specificity(host string, addr netip.Addr) (int, bool) {
+	if r.prefix.IsValid() {
+		if !addr.IsValid() || !r.prefix.Contains(addr) {
+			return 0, false
+		}
+		return r.prefix.Bits(), true
+	}
+	if addr.IsValid() {
+		return 0, false
+	}
+
+	labels := strings.Count(r.domain, ".") + 1
+	if r.exact && host == r.domain {
+		return 2*labels + 1, true
+	}
+	if r.suffix && strings.HasSuffix(host, "."+r.domain) {
+		return 2 * labels, true
+	}
+	return 0, false
+}
+
+type ruleBypass struct {
+	rules     []compiledRule
+	whitelist bool
+}
+
+// RuleBypass is a bypass of the rules (see Rule) with the deterministic precedence:
+// among the rules matching an address, the most specific one wins regardless of the order,
+// and a negation wins over a normal rule at the equal specificity.
+// The address is in the list if the winning rule is not a negation.
+// The winning rule is reported by MatchRule (see RuleMatcher).
+func RuleBypass(rules []string, whitelist bool) Bypass {
+	p := &ruleBypass{
+		whitelist: whitelist,
+	}
+	for _, s := range rules {
+		rule := ParseRule(s)
+		if rule.Pattern == "" {
+			continue
+		}
+		p.rules = append(p.rules, compileRule(rule))
+	}
+	return p
+}
+
+func compileRule(rule Rule) compiledRule {
+	cr := compiledRule{rule: rule}
+	pattern := strings.ToLower(rule.Pattern)
+
+	if prefix, err := netip.ParsePrefix(pattern); err == nil {
+		cr.prefix = prefix.Masked()
+		return cr
+	}
+	if addr, err := netip.ParseAddr(pattern); err == nil {
+		addr = addr.Unmap()
+		cr.prefix = netip.PrefixFrom(addr, addr.BitLen())
+		return cr
+	}
+
+	switch {
+	case strings.HasPrefix(pattern, "*."):
+		cr.domain = pattern[2:]
+		cr.suffix = true
+	case strings.HasPrefix(pattern, "."):
+		cr.domain = pattern[1:]
+		cr.suffix = true
+		cr.exact = true
+	default:
+		cr.domain = pattern
+		cr.exact = true
+	}
+	return cr
+}
+
+func (p *ruleBypass) IsWhitelist() bool {
+	return p.whitelist
+}
+
+func (p *ruleBypass) Contains(ctx context.Context, network, addr string, opts ...Option) bool {
+	winner := p.match(addr)
+	listed := winner != nil && !winner.rule.Negate
+	return listed != p.whitelist
+}
+
+// MatchRule implements RuleMatcher, it returns the winning rule for addr.
+func (p *ruleBypass) MatchRule(ctx context.Context, network, addr string, opts ...Option) (string, bool) {
+	if winner := p.match(addr); winner != nil {
+		return winner.rule.String(), true
+	}
+	return "", false
+}
+
+func (p *ruleBypass) match(addr string) *compiledRule {
+	host, ip := ruleTarget(addr)
+
+	var winner *compiledRule
+	best := -1
+	for i := range p.rules {
+		r := &p.rules[i]
+		n, ok := r.specificity(host, ip)
+		if !ok {
+			continue
+		}
+		if n > best || (n == best && r.rule.Negate && !winner.rule.Negate) {
+			winner, best = r, n
+		}
+	}
+	return winner
+}
@@ -0,0 +1,84 @@
+package bypass
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRuleBypassPrecedence(t *testing.T) {
+	for _, tc := range []struct {
+		rules []string
+		addr  string
+		in    bool
+		rule  string
+	}{
+		// the more specific allow wins over the block of the overlapping domains, in either order.
+		{[]string{".example.com", "!api.example.com"}, "api.example.com:443", false, "!api.example.com"},
+		{[]string{"!api.example.com", ".example.com"}, "api.example.com:443", false, "!api.example.com"},
+		{[]string{".example.com", "!api.example.com"}, "www.example.com:443", true, ".example.com"},
+		{[]string{".example.com", "!api.example.com"}, "Example.COM.", true, ".example.com"},
+		// the more specific block wins over the allow.
+		{[]string{"!*.example.com", "secure.example.com"}, "secure.example.com:443", true, "secure.example.com"},
+		{[]string{"!*.example.com", "secure.example.com"}, "a.secure.example.com:443", false, "!*.example.com"},
+		// the exact match beats the suffix match of the same domain.
+		{[]string{"example.com", "!*.example.com"}, "example.com:80", true, "example.com"},
+		{[]string{"!example.com", ".example.com"}, "example.com:80", false, "!example.com"},
+		// the allow overrides the block at the equal specificity.
+		{[]string{"example.com", "!example.com"}, "example.com:80", false, "!example.com"},
+		{[]string{"!example.com", "example.com"}, "example.com:80", false, "!example.com"},
+		// the longer prefix wins.
+		{[]string{"10.0.0.0/8", "!10.1.0.0/16", "10.1.2.3"}, "10.1.2.3:80", true, "10.1.2.3"},
+		{[]string{"10.1.2.3", "!10.1.0.0/16", "10.0.0.0/8"}, "10.1.9.9:80", false, "!10.1.0.0/16"},
+		{[]string{"10.0.0.0/8", "!10.1.0.0/16"}, "10.2.0.1:80", true, "10.0.0.0/8"},
+		{[]string{"10.0.0.0/8", "!10.1.0.0/16", "10.1.2.3"}, "[::ffff:10.1.2.3]:80", true, "10.1.2.3"},
+		// the domain rules do not match the IPs.
+		{[]string{".example.com"}, "192.0.2.1:80", false, ""},
+		{[]string{"*.example.com"}, "example.com:80", false, ""},
+	} {
+		bp := RuleBypass(tc.rules, false)
+		if in := bp.Contains(context.Background(), "tcp", tc.addr); in != tc.in {
+			t.Errorf("%v %s: got %v, want %v", tc.rules, tc.addr, in, tc.in)
+		}
+		rule, ok := bp.(RuleMatcher).MatchRule(context.Background(), "tcp", tc.addr)
+		if rule != tc.rule || ok != (tc.rule != "") {
+			t.Errorf("%v %s: the winning rule is %q %v, want %q", tc.rules, tc.addr, rule, ok, tc.rule)
+		}
+	}
+}
+
+func TestRuleBypassWhitelist(t *testing.T) {
+	bp := RuleBypass([]string{" .example.com ", "! api.example.com", ""}, true)
+	if !bp.IsWhitelist() {
+		t.Fatal("expected whitelist")
+	}
+	for _, tc := range []struct {
+		addr     string
+		contains bool
+	}{
+		{"www.example.com:443", false},
+		{"api.example.com:443", true},
+		{"example.org:443", true},
+	} {
+		if got := bp.Contains(context.Background(), "tcp", tc.addr); got != tc.contains {
+			t.Errorf("%s: got %v, want %v", tc.addr, got, tc.contains)
+		}
+	}
+
+	if ex := Explain(context.Background(), bp, "tcp", "api.example.com:443"); ex.Rule != "!api.example.com" {
+		t.Fatalf("unexpected explained rule %q", ex.Rule)
+	}
+}
+
+func TestParseRule(t *testing.T) {
+	for _, tc := range []struct {
+		s    string
+		rule Rule
+	}{
+		{"example.com", Rule{Pattern: "example.com"}},
+		{" !  *.example.com ", Rule{Pattern: "*.example.com", Negate: true}},
+	} {
+		if rule := ParseRule(tc.s); rule != tc.rule {
+			t.Errorf("%q: got %+v, want %+v", tc.s, rule, tc.rule)
+		}
+	}
+}
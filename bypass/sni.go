@@ -0,0 +1,48 @@
+package bypass
+
+import (
+	"context"
+	"net"
+
+	"github.com/go-gost/core/common/ctxvalue"
+)
+
+type sniBypass struct {
+	bypass Bypass
+}
+
+// SNIBypass wraps the bypass bp to match the rules against the TLS server name instead of the
+// requested host, which may be just an IP for the TLS pass-through connections.
+// The server name is taken from the SNI option or the context (see ctxvalue.ContextWithSNI),
+// if it is absent, the requested address is matched as is.
+func SNIBypass(bp Bypass) Bypass {
+	return &sniBypass{
+		bypass: bp,
+	}
+}
+
+func (p *sniBypass) IsWhitelist() bool {
+	return p.bypass.IsWhitelist()
+}
+
+func (p *sniBypass) Contains(ctx context.Context, network, addr string, opts ...Option) bool {
+	var options Options
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+
+	sni := options.SNI
+	if sni == "" {
+		sni = ctxvalue.SNIFromContext(ctx)
+	}
+	if sni != "" {
+		if _, port, err := net.SplitHostPort(addr); err == nil {
+			addr = net.JoinHostPort(sni, port)
+		} else {
+			addr = sni
+		}
+	}
+	return p.bypass.Contains(ctx, network, addr, opts...)
+}
@@ -0,0 +1,40 @@
+package bypass
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-gost/core/common/ctxvalue"
+)
+
+func TestSNIBypass(t *testing.T) {
+	bp := SNIBypass(RuleBypass([]string{"*.example.com", "10.0.0.0/8"}, false))
+
+	// the rules are matched against the SNI instead of the requested IP.
+	ctx := ctxvalue.ContextWithSNI(context.Background(), "api.example.com")
+	if !bp.Contains(ctx, "tcp", "203.0.113.1:443") {
+		t.Fatal("the SNI from the context should be matched")
+	}
+	if !bp.Contains(context.Background(), "tcp", "203.0.113.1:443", WithSNIOption("api.example.com")) {
+		t.Fatal("the SNI from the option should be matched")
+	}
+	if bp.Contains(ctxvalue.ContextWithSNI(context.Background(), "www.example.org"), "tcp", "10.0.0.1:443") {
+		t.Fatal("the SNI should be matched in place of the requested IP")
+	}
+
+	// the option takes precedence over the context.
+	if bp.Contains(ctx, "tcp", "203.0.113.1:443", WithSNIOption("www.example.org")) {
+		t.Fatal("the SNI option should take precedence")
+	}
+
+	// no SNI, the requested address is matched.
+	if !bp.Contains(context.Background(), "tcp", "10.0.0.1:443") {
+		t.Fatal("the address should be matched without SNI")
+	}
+	if bp.Contains(context.Background(), "tcp", "203.0.113.1:443") {
+		t.Fatal("unexpected match without SNI")
+	}
+	if !bp.Contains(ctx, "tcp", "203.0.113.1") {
+		t.Fatal("the SNI should be matched for the address without port")
+	}
+}
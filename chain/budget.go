@@ -0,0 +1,129 @@
+package chain
+
+import (
+	"sync"
+	"time"
+
+	"github.com/go-gost/core/common/clock"
+)
+
+// WindowFunc returns the start of the budget window containing t.
+type WindowFunc func(t time.Time) time.Time
+
+// DailyWindow is the budget window of a day in the location of t.
+func DailyWindow(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+// MonthlyWindow is the budget window of a calendar month in the location of t.
+func MonthlyWindow(t time.Time) time.Time {
+	y, m, _ := t.Date()
+	return time.Date(y, m, 1, 0, 0, 0, 0, t.Location())
+}
+
+// BudgetNodeSettings sets a cumulative byte budget of the node for metered upstreams,
+// the node is excluded from the selection (see selector.BudgetFilter) after the budget is used up,
+// until the window resets.
+type BudgetNodeSettings struct {
+	// Bytes is the budget in each window.
+	Bytes int64
+	// Window is the budget window, default is DailyWindow.
+	Window WindowFunc
+	Clock  clock.Clock
+}
+
+func BudgetNodeOption(settings *BudgetNodeSettings) NodeOption {
+	return func(o *NodeOptions) {
+		o.Budget = settings
+	}
+}
+
+type byteBudget struct {
+	used  int64
+	start time.Time
+	mu    sync.Mutex
+}
+
+func (node *Node) budgetSettings() (*BudgetNodeSettings, WindowFunc, clock.Clock) {
+	settings := node.options.Budget
+	if settings == nil || settings.Bytes <= 0 || node.budget == nil {
+		return nil, nil, nil
+	}
+	window := settings.Window
+	if window == nil {
+		window = DailyWindow
+	}
+	return settings, window, clock.OrDefault(settings.Clock)
+}
+
+// roll starts a new window if the current one is over, it is called with the lock held.
+func (b *byteBudget) roll(window WindowFunc, now time.Time) {
+	if start := window(now); !start.Equal(b.start) {
+		b.start = start
+		b.used = 0
+	}
+}
+
+// AddEgressBytes accounts n bytes sent through the node into the budget, e.g. from the relay stats.
+func (node *Node) AddEgressBytes(n int64) {
+	_, window, clk := node.budgetSettings()
+	if window == nil || n <= 0 {
+		return
+	}
+
+	b := node.budget
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.roll(window, clk.Now())
+	b.used += n
+}
+
+// BudgetExceeded implements selector.Budgeted interface, it reports whether the budget of the current window is used up.
+func (node *Node) BudgetExceeded() bool {
+	settings, window, clk := node.budgetSettings()
+	if settings == nil {
+		return false
+	}
+
+	b := node.budget
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.roll(window, clk.Now())
+	return b.used >= settings.Bytes
+}
+
+// BudgetUsage returns the bytes used in the current window and the start of the window.
+func (node *Node) BudgetUsage() (used int64, start time.Time) {
+	_, window, clk := node.budgetSettings()
+	if window == nil {
+		return
+	}
+
+	b := node.budget
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.roll(window, clk.Now())
+	return b.used, b.start
+}
+
+// RestoreBudget restores the usage of the window starting at start, e.g. from the persisted state,
+// the usage of a past window is ignored.
+func (node *Node) RestoreBudget(used int64, start time.Time) {
+	_, window, clk := node.budgetSettings()
+	if window == nil {
+		return
+	}
+
+	b := node.budget
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.roll(window, clk.Now())
+	if start.Equal(b.start) {
+		b.used = used
+	}
+}
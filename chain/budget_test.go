@@ -0,0 +1,90 @@
+package chain
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-gost/core/common/clock"
+	"github.com/go-gost/core/selector"
+)
+
+func TestNodeBudget(t *testing.T) {
+	c := clock.NewFakeClock(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	metered := NewNode("metered", "192.0.2.1:80", BudgetNodeOption(&BudgetNodeSettings{Bytes: 1000, Clock: c}))
+	other := NewNode("other", "192.0.2.2:80")
+	filter := selector.BudgetFilter[*Node]()
+
+	metered.AddEgressBytes(600)
+	other.AddEgressBytes(1 << 30)
+	if vs := filter.Filter(context.Background(), metered, other); len(vs) != 2 {
+		t.Fatalf("expected both nodes within the budget, got %d", len(vs))
+	}
+
+	// the node over budget is excluded.
+	metered.AddEgressBytes(400)
+	if vs := filter.Filter(context.Background(), metered, other); len(vs) != 1 || vs[0] != other {
+		t.Fatalf("the node over budget should be excluded, got %d nodes", len(vs))
+	}
+	if used, start := metered.BudgetUsage(); used != 1000 || !start.Equal(time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected usage %d of window %s", used, start)
+	}
+
+	// the node returns after the window resets.
+	c.Advance(11*time.Hour + 59*time.Minute)
+	if !metered.BudgetExceeded() {
+		t.Fatal("the budget should be used up before the window resets")
+	}
+	c.Advance(time.Minute)
+	if vs := filter.Filter(context.Background(), metered, other); len(vs) != 2 {
+		t.Fatalf("the node should return after the window resets, got %d nodes", len(vs))
+	}
+	if used, _ := metered.BudgetUsage(); used != 0 {
+		t.Fatalf("expected no usage in the new window, got %d", used)
+	}
+}
+
+func TestNodeBudgetMonthly(t *testing.T) {
+	c := clock.NewFakeClock(time.Date(2024, 2, 10, 0, 0, 0, 0, time.UTC))
+	node := NewNode("a", "192.0.2.1:80", BudgetNodeOption(&BudgetNodeSettings{Bytes: 100, Window: MonthlyWindow, Clock: c}))
+
+	node.AddEgressBytes(100)
+	c.Advance(19 * 24 * time.Hour)
+	if !node.BudgetExceeded() {
+		t.Fatal("the budget should be used up within the month")
+	}
+	c.Advance(24 * time.Hour)
+	if node.BudgetExceeded() {
+		t.Fatal("the budget should reset in the next month")
+	}
+}
+
+func TestNodeBudgetRestore(t *testing.T) {
+	c := clock.NewFakeClock(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	settings := &BudgetNodeSettings{Bytes: 1000, Clock: c}
+	node := NewNode("a", "192.0.2.1:80", BudgetNodeOption(settings))
+
+	// the usage of a past window is ignored.
+	node.RestoreBudget(1000, time.Date(2024, 4, 30, 0, 0, 0, 0, time.UTC))
+	if node.BudgetExceeded() {
+		t.Fatal("the usage of a past window should be ignored")
+	}
+	node.RestoreBudget(1000, time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC))
+	if !node.BudgetExceeded() {
+		t.Fatal("the usage of the current window should be restored")
+	}
+
+	// the usage is kept across the merge of the node updates.
+	nodes := MergeNodes([]*Node{node}, []*Node{NewNode("a", "192.0.2.1:80", BudgetNodeOption(settings))})
+	if !nodes[0].BudgetExceeded() {
+		t.Fatal("the usage should be kept across the merge")
+	}
+
+	// without budget nothing is accounted.
+	node = NewNode("b", "192.0.2.2:80")
+	node.AddEgressBytes(100)
+	node.RestoreBudget(100, time.Now())
+	if used, start := node.BudgetUsage(); used != 0 || !start.IsZero() || node.BudgetExceeded() {
+		t.Fatalf("unexpected usage %d of window %s without budget", used, start)
+	}
+}
@@ -0,0 +1,55 @@
+package chain
+
+import (
+	"context"
+	"net"
+	"syscall"
+	"testing"
+)
+
+func getTOS(t *testing.T, conn net.Conn) int {
+	t.Helper()
+	rc, err := conn.(syscall.Conn).SyscallConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tos int
+	var serr error
+	rc.Control(func(fd uintptr) {
+		tos, serr = syscall.GetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_TOS)
+	})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	return tos
+}
+
+func TestNodeDialDSCP(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	addr := ln.Addr().String()
+
+	for _, tc := range []struct {
+		node *Node
+		tos  int
+	}{
+		{NewNode("a", addr, DSCPNodeOption(46)), 46 << 2},
+		// the custom dial function.
+		{NewNode("b", addr, DSCPNodeOption(10), DialFuncNodeOption(func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return net.Dial(network, addr)
+		})), 10 << 2},
+		{NewNode("c", addr), 0},
+	} {
+		conn, err := tc.node.Dial(context.Background(), "tcp4")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tos := getTOS(t, conn); tos != tc.tos {
+			t.Errorf("%s: TOS is %#x, want %#x", tc.node.Name, tos, tc.tos)
+		}
+		conn.Close()
+	}
+}
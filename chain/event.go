@@ -0,0 +1,169 @@
+package chain
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-gost/core/observer"
+	"github.com/go-gost/core/recorder"
+	"github.com/go-gost/core/selector"
+)
+
+const (
+	defaultNodeEventBufferSize = 64
+)
+
+type NodeEventKind string
+
+const (
+	NodeAdded     NodeEventKind = "added"
+	NodeRemoved   NodeEventKind = "removed"
+	NodeMarked    NodeEventKind = "marked"
+	NodeRecovered NodeEventKind = "recovered"
+	NodeDrained   NodeEventKind = "drained"
+	// The circuit breaker transitions of the node, see CircuitNodeOption.
+	NodeCircuitOpened   NodeEventKind = "circuit.opened"
+	NodeCircuitHalfOpen NodeEventKind = "circuit.halfopen"
+	NodeCircuitClosed   NodeEventKind = "circuit.closed"
+)
+
+var circuitEventKinds = map[selector.CircuitState]NodeEventKind{
+	selector.CircuitOpen:     NodeCircuitOpened,
+	selector.CircuitHalfOpen: NodeCircuitHalfOpen,
+	selector.CircuitClosed:   NodeCircuitClosed,
+}
+
+// NodeEvent is a lifecycle event of a node, it implements observer.Event interface.
+type NodeEvent struct {
+	Kind NodeEventKind `json:"kind"`
+	Node string        `json:"node"`
+	Addr string        `json:"addr"`
+	Time time.Time     `json:"time"`
+}
+
+func (e *NodeEvent) Type() observer.EventType {
+	return observer.EventNode
+}
+
+// NodeEventBus delivers the node events to the subscribers for external controllers.
+// Publishing never blocks: if a subscriber lags and its buffer is full, the event is dropped for it.
+type NodeEventBus struct {
+	subscribers map[*subscriber]struct{}
+	mu          sync.RWMutex
+	dropped     atomic.Int64
+}
+
+type subscriber struct {
+	c chan NodeEvent
+}
+
+func NewNodeEventBus() *NodeEventBus {
+	return &NodeEventBus{
+		subscribers: make(map[*subscriber]struct{}),
+	}
+}
+
+// Subscribe returns a channel receiving the events with buffer size,
+// the cancel function unsubscribes and closes the channel.
+func (b *NodeEventBus) Subscribe(size int) (events <-chan NodeEvent, cancel func()) {
+	if size <= 0 {
+		size = defaultNodeEventBufferSize
+	}
+	s := &subscriber{c: make(chan NodeEvent, size)}
+
+	b.mu.Lock()
+	b.subscribers[s] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return s.c, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers, s)
+			b.mu.Unlock()
+			close(s.c)
+		})
+	}
+}
+
+// Publish sends the event of kind for node to all the subscribers.
+func (b *NodeEventBus) Publish(kind NodeEventKind, node *Node) {
+	if b == nil || node == nil {
+		return
+	}
+
+	ev := NodeEvent{
+		Kind: kind,
+		Node: node.Name,
+		Addr: node.Addr,
+		Time: time.Now(),
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for s := range b.subscribers {
+		select {
+		case s.c <- ev:
+		default:
+			b.dropped.Add(1)
+		}
+	}
+}
+
+// Dropped returns the number of events dropped for the lagging subscribers.
+func (b *NodeEventBus) Dropped() int64 {
+	return b.dropped.Load()
+}
+
+// eventMarker publishes the marked and recovered events on the marker state transitions.
+type eventMarker struct {
+	selector.Marker
+	bus  *NodeEventBus
+	node *Node
+}
+
+func (m *eventMarker) Mark() {
+	healthy := m.Marker.Count() == 0
+	m.Marker.Mark()
+	// only the transition from healthy is published, not each of the following failures.
+	if healthy && m.Marker.Count() > 0 {
+		m.bus.Publish(NodeMarked, m.node)
+	}
+}
+
+func (m *eventMarker) Reset() {
+	failed := m.Marker.Count() > 0
+	m.Marker.Reset()
+	if failed {
+		m.bus.Publish(NodeRecovered, m.node)
+	}
+}
+
+func (m *eventMarker) Restore(count int64, t time.Time) {
+	if r, ok := m.Marker.(selector.Restorable); ok {
+		r.Restore(count, t)
+	}
+}
+
+// RecordNodeEvents records the events from bus into the recorder r as JSON lines, e.g. the circuit breaker transitions.
+// It blocks until ctx is done.
+func RecordNodeEvents(ctx context.Context, bus *NodeEventBus, r recorder.Recorder) {
+	events, cancel := bus.Subscribe(0)
+	defer cancel()
+
+	for {
+		select {
+		case ev := <-events:
+			b, err := json.Marshal(ev)
+			if err != nil {
+				continue
+			}
+			r.Record(ctx, append(b, '\n'))
+		case <-ctx.Done():
+			return
+		}
+	}
+}
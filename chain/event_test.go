@@ -0,0 +1,222 @@
+package chain
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-gost/core/recorder"
+	"github.com/go-gost/core/selector"
+)
+
+func nextEvent(t *testing.T, events <-chan NodeEvent, kind NodeEventKind, node string) {
+	t.Helper()
+	select {
+	case ev := <-events:
+		if ev.Kind != kind || ev.Node != node || ev.Time.IsZero() {
+			t.Fatalf("expected %s of %s, got %+v", kind, node, ev)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("no %s event of %s", kind, node)
+	}
+}
+
+func noEvent(t *testing.T, events <-chan NodeEvent) {
+	t.Helper()
+	select {
+	case ev := <-events:
+		t.Fatalf("unexpected event %+v", ev)
+	default:
+	}
+}
+
+func TestNodeEvents(t *testing.T) {
+	bus := NewNodeEventBus()
+	events, cancel := bus.Subscribe(0)
+	defer cancel()
+
+	a := NewNode("a", "127.0.0.1:80", EventsNodeOption(bus))
+	b := NewNode("b", "127.0.0.1:81", EventsNodeOption(bus))
+	nodes := MergeNodes(nil, []*Node{a, b})
+	nextEvent(t, events, NodeAdded, "a")
+	nextEvent(t, events, NodeAdded, "b")
+
+	nodes[0].Marker().Mark()
+	nextEvent(t, events, NodeMarked, "a")
+	// the following failures are not published.
+	nodes[0].Marker().Mark()
+	nodes[0].Marker().Mark()
+	noEvent(t, events)
+	nodes[0].Marker().Reset()
+	nextEvent(t, events, NodeRecovered, "a")
+	// no recovery without failure.
+	nodes[0].Marker().Reset()
+	noEvent(t, events)
+
+	nodes[1].Drain(true)
+	nextEvent(t, events, NodeDrained, "b")
+	nodes[1].Drain(true)
+	noEvent(t, events)
+
+	// the unchanged node is neither added nor removed.
+	nodes = MergeNodes(nodes, []*Node{NewNode("a", "127.0.0.1:80", EventsNodeOption(bus))})
+	nextEvent(t, events, NodeRemoved, "b")
+	noEvent(t, events)
+	if len(nodes) != 1 {
+		t.Fatalf("expected 1 node, got %d", len(nodes))
+	}
+}
+
+func TestNodeEventBusSlowConsumer(t *testing.T) {
+	bus := NewNodeEventBus()
+	slow, cancel := bus.Subscribe(1)
+	defer cancel()
+	fast, cancelFast := bus.Subscribe(200)
+	defer cancelFast()
+
+	node := NewNode("a", "127.0.0.1:80", EventsNodeOption(bus))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 50; i++ {
+			node.Marker().Mark()
+			node.Marker().Reset()
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the lagging subscriber blocks the publisher")
+	}
+
+	if n := bus.Dropped(); n != 99 {
+		t.Fatalf("expected 99 dropped events, got %d", n)
+	}
+	if len(slow) != 1 || len(fast) != 100 {
+		t.Fatalf("unexpected buffered events %d and %d", len(slow), len(fast))
+	}
+}
+
+func TestNodeEventBusCancel(t *testing.T) {
+	bus := NewNodeEventBus()
+	events, cancel := bus.Subscribe(0)
+	cancel()
+	cancel()
+
+	bus.Publish(NodeMarked, NewNode("a", "127.0.0.1:80"))
+	if _, ok := <-events; ok {
+		t.Fatal("the channel should be closed")
+	}
+
+	// nil bus is a no-op.
+	var nilBus *NodeEventBus
+	nilBus.Publish(NodeMarked, NewNode("a", "127.0.0.1:80"))
+}
+
+// circuitEvents returns the circuit breaker events in events within the timeout.
+func circuitEvents(events <-chan NodeEvent, n int) []NodeEventKind {
+	var kinds []NodeEventKind
+	timeout := time.After(time.Second)
+	for len(kinds) < n {
+		select {
+		case ev := <-events:
+			switch ev.Kind {
+			case NodeCircuitOpened, NodeCircuitHalfOpen, NodeCircuitClosed:
+				kinds = append(kinds, ev.Kind)
+			}
+		case <-timeout:
+			return kinds
+		}
+	}
+	return kinds
+}
+
+func TestNodeCircuit(t *testing.T) {
+	bus := NewNodeEventBus()
+	events, cancel := bus.Subscribe(0)
+	defer cancel()
+
+	node := NewNode("a", "127.0.0.1:80", EventsNodeOption(bus),
+		CircuitNodeOption(&CircuitNodeSettings{Threshold: 2, OpenTimeout: 20 * time.Millisecond}))
+
+	if s, ok := node.CircuitStats(); !ok || s.State != selector.CircuitClosed {
+		t.Fatalf("expected a closed circuit, got %+v %v", s, ok)
+	}
+
+	node.Marker().Mark()
+	node.Marker().Mark()
+	ns := node.Snapshot()
+	if ns.Circuit == nil || ns.Circuit.State != selector.CircuitOpen || ns.Circuit.Trips != 1 || ns.Alive {
+		t.Fatalf("expected an open circuit in the snapshot, %+v", ns.Circuit)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if s, _ := node.CircuitStats(); s.State != selector.CircuitHalfOpen || s.OpenDuration < 20*time.Millisecond {
+		t.Fatalf("expected a half-open circuit, got %+v", s)
+	}
+
+	// the trial succeeds.
+	node.Marker().Reset()
+	if s, _ := node.CircuitStats(); s.State != selector.CircuitClosed || s.Trips != 1 {
+		t.Fatalf("expected a closed circuit, got %+v", s)
+	}
+
+	kinds := circuitEvents(events, 3)
+	want := []NodeEventKind{NodeCircuitOpened, NodeCircuitHalfOpen, NodeCircuitClosed}
+	if len(kinds) != len(want) || kinds[0] != want[0] || kinds[1] != want[1] || kinds[2] != want[2] {
+		t.Fatalf("got circuit events %v, want %v", kinds, want)
+	}
+
+	if _, ok := NewNode("b", "127.0.0.1:80").CircuitStats(); ok {
+		t.Fatal("the node without circuit breaker should have no circuit stats")
+	}
+}
+
+// lineRecorder sends the records to a channel.
+type lineRecorder chan string
+
+func (r lineRecorder) Record(ctx context.Context, b []byte, opts ...recorder.RecordOption) error {
+	r <- string(b)
+	return nil
+}
+
+func TestRecordNodeEvents(t *testing.T) {
+	bus := NewNodeEventBus()
+	r := make(lineRecorder, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		RecordNodeEvents(ctx, bus, r)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	node := NewNode("a", "127.0.0.1:80", EventsNodeOption(bus),
+		CircuitNodeOption(&CircuitNodeSettings{Threshold: 1, OpenTimeout: time.Minute}))
+
+	// the subscription may not be ready yet.
+	deadline := time.After(time.Second)
+	for {
+		node.Marker().Mark()
+		node.Marker().Reset()
+		select {
+		case line := <-r:
+			var ev NodeEvent
+			if err := json.Unmarshal([]byte(line), &ev); err != nil {
+				t.Fatalf("invalid record %q: %v", line, err)
+			}
+			if ev.Node != "a" || ev.Addr != "127.0.0.1:80" || ev.Kind == "" || !strings.HasSuffix(line, "\n") {
+				t.Fatalf("unexpected record %q", line)
+			}
+			return
+		case <-deadline:
+			t.Fatal("no event is recorded")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package chain
+
+import (
+	"expvar"
+	"sync"
+)
+
+var (
+	expvarMu sync.Mutex
+)
+
+const (
+	// ExpvarName is the default expvar name of the node states, served at /debug/vars.
+	ExpvarName = "gost.nodes"
+)
+
+// PublishExpvar publishes the snapshot of s under the expvar name (ExpvarName if empty) for the environments
+// without Prometheus. The snapshot is taken on each read from the same node counters as the metrics,
+// so they never diverge. If the name is published already, it is not replaced and false is returned.
+func PublishExpvar(name string, s Snapshotter) bool {
+	if name == "" {
+		name = ExpvarName
+	}
+	expvarMu.Lock()
+	defer expvarMu.Unlock()
+
+	if s == nil || expvar.Get(name) != nil {
+		return false
+	}
+
+	expvar.Publish(name, expvar.Func(func() any {
+		return s.Snapshot()
+	}))
+	return true
+}
+
+// SnapshotterFunc is an adapter to use a function as Snapshotter, e.g. returning SnapshotNodes of the current node list.
+type SnapshotterFunc func() Snapshot
+
+func (f SnapshotterFunc) Snapshot() Snapshot {
+	return f()
+}
@@ -0,0 +1,77 @@
+package chain
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// expvarRuns makes the published names unique across the test runs, the expvars are never unpublished.
+var expvarRuns atomic.Int64
+
+func readExpvar(t *testing.T, name string) map[string]any {
+	t.Helper()
+	v := expvar.Get(name)
+	if v == nil {
+		t.Fatalf("%s is not published", name)
+	}
+	var m map[string]any
+	if err := json.Unmarshal([]byte(v.String()), &m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestPublishExpvar(t *testing.T) {
+	a := NewNode("a", "192.0.2.1:80")
+	b := NewNode("b", "192.0.2.2:80")
+	nodes := []*Node{a, b}
+	s := SnapshotterFunc(func() Snapshot {
+		return SnapshotNodes(nodes)
+	})
+
+	name := fmt.Sprintf("test.chain.nodes.%d", expvarRuns.Add(1))
+	if !PublishExpvar(name, s) {
+		t.Fatal("the var is not published")
+	}
+	// the published name is not replaced.
+	if PublishExpvar(name, s) || PublishExpvar("test.chain.nil", nil) {
+		t.Fatal("the var should not be published again")
+	}
+
+	m := readExpvar(t, name)
+	if _, ok := m["time"]; !ok {
+		t.Fatalf("missing time in %v", m)
+	}
+	list, _ := m["nodes"].([]any)
+	if len(list) != 2 {
+		t.Fatalf("expected 2 nodes, got %v", m["nodes"])
+	}
+	for _, key := range []string{"name", "addr", "alive", "activeConns", "latency", "failCount", "weight"} {
+		if _, ok := list[0].(map[string]any)[key]; !ok {
+			t.Errorf("missing %s in %v", key, list[0])
+		}
+	}
+
+	// the output reflects the current state.
+	a.IncActiveConns()
+	a.SetLatency(20 * time.Millisecond)
+	b.Marker().Mark()
+	list, _ = readExpvar(t, name)["nodes"].([]any)
+	na, nb := list[0].(map[string]any), list[1].(map[string]any)
+	if na["activeConns"] != float64(1) || na["latency"] != float64(20*time.Millisecond) {
+		t.Fatalf("the counters are not current: %v", na)
+	}
+	if nb["failCount"] != float64(1) || nb["alive"] != false {
+		t.Fatalf("the marker state is not current: %v", nb)
+	}
+
+	// the default name, it may be published by the previous run.
+	PublishExpvar("", s)
+	if expvar.Get(ExpvarName) == nil {
+		t.Fatalf("the var is not published as %s", ExpvarName)
+	}
+}
@@ -0,0 +1,76 @@
+package chain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync/atomic"
+)
+
+// ProtocolDialFunc connects to the node with the transport protocol.
+type ProtocolDialFunc func(ctx context.Context, protocol string) (net.Conn, error)
+
+type protocolState struct {
+	// preferred is the index plus one of the last working protocol, 0 means none.
+	preferred atomic.Int32
+}
+
+// Protocols returns the transport protocols of the node in the order to try,
+// the last working one is moved to the front.
+func (node *Node) Protocols() []string {
+	protocols := node.options.Protocols
+	if len(protocols) == 0 || node.protocol == nil {
+		return protocols
+	}
+
+	i := int(node.protocol.preferred.Load()) - 1
+	if i <= 0 || i >= len(protocols) {
+		return protocols
+	}
+
+	result := make([]string, 0, len(protocols))
+	result = append(result, protocols[i])
+	result = append(result, protocols[:i]...)
+	result = append(result, protocols[i+1:]...)
+	return result
+}
+
+// DialProtocols connects to the node by trying the protocols set by ProtocolFallbackNodeOption in order,
+// falling back to the next one on failure, and records the working protocol to try it first next time.
+// If no protocol is set, dial is called once with an empty protocol.
+// The returned string is the protocol of the connection.
+func (node *Node) DialProtocols(ctx context.Context, dial ProtocolDialFunc) (net.Conn, string, error) {
+	protocols := node.Protocols()
+	if len(protocols) == 0 {
+		conn, err := dial(ctx, "")
+		return conn, "", err
+	}
+
+	var errs []error
+	for _, protocol := range protocols {
+		conn, err := dial(ctx, protocol)
+		if err == nil {
+			node.setPreferredProtocol(protocol)
+			return conn, protocol, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", protocol, err))
+
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, "", errors.Join(errs...)
+}
+
+func (node *Node) setPreferredProtocol(protocol string) {
+	if node.protocol == nil {
+		return
+	}
+	for i, p := range node.options.Protocols {
+		if p == protocol {
+			node.protocol.preferred.Store(int32(i + 1))
+			return
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package chain
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+)
+
+// protocolDialer fails the blocked protocols and records the tried ones.
+type protocolDialer struct {
+	blocked map[string]bool
+	tried   []string
+}
+
+func (d *protocolDialer) dial(ctx context.Context, protocol string) (net.Conn, error) {
+	d.tried = append(d.tried, protocol)
+	if d.blocked[protocol] {
+		return nil, errors.New("blocked")
+	}
+	c1, c2 := net.Pipe()
+	c2.Close()
+	return c1, nil
+}
+
+func TestNodeDialProtocolsFallback(t *testing.T) {
+	node := NewNode("a", "127.0.0.1:443", ProtocolFallbackNodeOption([]string{"quic", "tls", "tcp"}))
+	d := &protocolDialer{blocked: map[string]bool{"quic": true}}
+
+	conn, protocol, err := node.DialProtocols(context.Background(), d.dial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	if protocol != "tls" {
+		t.Fatalf("expected the fallback to tls, got %q", protocol)
+	}
+	if len(d.tried) != 2 || d.tried[0] != "quic" || d.tried[1] != "tls" {
+		t.Fatalf("unexpected tried protocols %v", d.tried)
+	}
+
+	// the working protocol is preferred subsequently.
+	if ps := node.Protocols(); len(ps) != 3 || ps[0] != "tls" || ps[1] != "quic" || ps[2] != "tcp" {
+		t.Fatalf("unexpected protocol order %v", ps)
+	}
+	d.tried = nil
+	conn, protocol, err = node.DialProtocols(context.Background(), d.dial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	if protocol != "tls" || len(d.tried) != 1 {
+		t.Fatalf("expected tls to be tried first, tried %v", d.tried)
+	}
+
+	// the first protocol is recovered.
+	d.blocked = map[string]bool{"tls": true}
+	d.tried = nil
+	if _, protocol, _ = node.DialProtocols(context.Background(), d.dial); protocol != "quic" {
+		t.Fatalf("expected the fallback to quic, got %q", protocol)
+	}
+	if ps := node.Protocols(); ps[0] != "quic" || ps[1] != "tls" {
+		t.Fatalf("unexpected protocol order %v", ps)
+	}
+}
+
+func TestNodeDialProtocolsFailed(t *testing.T) {
+	node := NewNode("a", "127.0.0.1:443", ProtocolFallbackNodeOption([]string{"quic", "tcp"}))
+	d := &protocolDialer{blocked: map[string]bool{"quic": true, "tcp": true}}
+
+	_, _, err := node.DialProtocols(context.Background(), d.dial)
+	if err == nil || len(d.tried) != 2 {
+		t.Fatalf("expected all the protocols to fail, tried %v, got %v", d.tried, err)
+	}
+	if ps := node.Protocols(); ps[0] != "quic" {
+		t.Fatalf("the order should not change on failure, got %v", ps)
+	}
+}
+
+func TestNodeDialProtocolsNone(t *testing.T) {
+	node := NewNode("a", "127.0.0.1:443")
+	d := &protocolDialer{}
+
+	conn, protocol, err := node.DialProtocols(context.Background(), d.dial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	if protocol != "" || len(d.tried) != 1 || d.tried[0] != "" {
+		t.Fatalf("expected one dial without protocol, tried %v", d.tried)
+	}
+}
@@ -0,0 +1,92 @@
+package chain
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-gost/core/selector"
+)
+
+var (
+	ErrGroupBusy = errors.New("node group: too many connections")
+)
+
+// NodeGroup is a set of nodes sharing a connection limit and a health state,
+// e.g. the nodes of the different ports on one physical host.
+type NodeGroup struct {
+	Name string
+	// MaxConns is the maximum number of active connections of all the nodes in the group, 0 means unlimited.
+	MaxConns    int
+	activeConns int64
+	marker      selector.Marker
+}
+
+func NewNodeGroup(name string, maxConns int) *NodeGroup {
+	return &NodeGroup{
+		Name:     name,
+		MaxConns: maxConns,
+		marker:   selector.NewFailMarker(),
+	}
+}
+
+// GroupNodeOption adds the node to the group, see Node.Acquire for the shared connection limit.
+// Marking the group marks all its nodes as failed in the selection.
+func GroupNodeOption(g *NodeGroup) NodeOption {
+	return func(o *NodeOptions) {
+		o.Group = g
+	}
+}
+
+// Marker returns the marker of the group health, the failures marked through it count for all the nodes.
+func (g *NodeGroup) Marker() selector.Marker {
+	return g.marker
+}
+
+func (g *NodeGroup) ActiveConns() int64 {
+	return atomic.LoadInt64(&g.activeConns)
+}
+
+func (g *NodeGroup) acquire() bool {
+	if g.MaxConns <= 0 {
+		atomic.AddInt64(&g.activeConns, 1)
+		return true
+	}
+	for {
+		n := atomic.LoadInt64(&g.activeConns)
+		if n >= int64(g.MaxConns) {
+			return false
+		}
+		if atomic.CompareAndSwapInt64(&g.activeConns, n, n+1) {
+			return true
+		}
+	}
+}
+
+func (g *NodeGroup) release() {
+	atomic.AddInt64(&g.activeConns, -1)
+}
+
+// groupMarker combines the node marker with the group marker, the failures of the group count for the node.
+// Marking and resetting only affect the node.
+type groupMarker struct {
+	selector.Marker
+	group *NodeGroup
+}
+
+func (m *groupMarker) Count() int64 {
+	return max(m.Marker.Count(), m.group.marker.Count())
+}
+
+func (m *groupMarker) Time() time.Time {
+	if m.group.marker.Count() > m.Marker.Count() {
+		return m.group.marker.Time()
+	}
+	return m.Marker.Time()
+}
+
+func (m *groupMarker) Restore(count int64, t time.Time) {
+	if r, ok := m.Marker.(selector.Restorable); ok {
+		r.Restore(count, t)
+	}
+}
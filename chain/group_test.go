@@ -0,0 +1,94 @@
+package chain
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-gost/core/selector"
+)
+
+func TestNodeGroupMaxConns(t *testing.T) {
+	g := NewNodeGroup("host-1", 3)
+	a := NewNode("a", "192.0.2.1:8001", GroupNodeOption(g), MaxConnsNodeOption(10))
+	b := NewNode("b", "192.0.2.1:8002", GroupNodeOption(g))
+
+	var releases []func()
+	for _, node := range []*Node{a, b, a} {
+		release, err := node.Acquire(context.Background())
+		if err != nil {
+			t.Fatalf("%s: %v", node.Name, err)
+		}
+		releases = append(releases, release)
+	}
+
+	// the shared limit caps the total conns across the nodes.
+	for _, node := range []*Node{a, b} {
+		if _, err := node.Acquire(context.Background()); !errors.Is(err, ErrGroupBusy) {
+			t.Fatalf("%s: expected ErrGroupBusy, got %v", node.Name, err)
+		}
+	}
+	if n := g.ActiveConns(); n != 3 {
+		t.Fatalf("expected 3 active conns in the group, got %d", n)
+	}
+	if a.ActiveConns() != 2 || b.ActiveConns() != 1 {
+		t.Fatalf("unexpected active conns %d %d", a.ActiveConns(), b.ActiveConns())
+	}
+
+	releases[1]()
+	release, err := a.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("expected a slot after release, got %v", err)
+	}
+	release()
+	if n := g.ActiveConns(); n != 2 {
+		t.Fatalf("expected 2 active conns in the group, got %d", n)
+	}
+}
+
+func TestNodeGroupNodeBusy(t *testing.T) {
+	g := NewNodeGroup("host-1", 10)
+	a := NewNode("a", "192.0.2.1:8001", GroupNodeOption(g), MaxConnsNodeOption(1))
+
+	release, err := a.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	// the group slot is returned if the node is busy.
+	if _, err := a.Acquire(context.Background()); !errors.Is(err, ErrNodeBusy) {
+		t.Fatalf("expected ErrNodeBusy, got %v", err)
+	}
+	if n := g.ActiveConns(); n != 1 {
+		t.Fatalf("expected 1 active conn in the group, got %d", n)
+	}
+}
+
+func TestNodeGroupHealth(t *testing.T) {
+	g := NewNodeGroup("host-1", 0)
+	bus := NewNodeEventBus()
+	a := NewNode("a", "192.0.2.1:8001", GroupNodeOption(g))
+	b := NewNode("b", "192.0.2.1:8002", GroupNodeOption(g), EventsNodeOption(bus))
+	c := NewNode("c", "192.0.2.2:8001")
+	filter := selector.FailFilter[*Node](1, 0)
+
+	// marking the group down excludes all its members.
+	g.Marker().Mark()
+	if vs := filter.Filter(context.Background(), a, b, c); len(vs) != 1 || vs[0] != c {
+		t.Fatalf("expected only the node out of the group, got %d nodes", len(vs))
+	}
+	if ns := b.Snapshot(); ns.Alive {
+		t.Fatalf("the member of the down group should not be alive, %+v", ns)
+	}
+
+	// the node failure does not affect the group.
+	g.Marker().Reset()
+	a.Marker().Mark()
+	if vs := filter.Filter(context.Background(), a, b, c); len(vs) != 2 || vs[0] != b {
+		t.Fatalf("expected b and c, got %d nodes", len(vs))
+	}
+	if g.Marker().Count() != 0 {
+		t.Fatal("the node failure should not mark the group")
+	}
+}
@@ -0,0 +1,87 @@
+package chain
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-gost/core/common/ctxvalue"
+	"github.com/go-gost/core/metadata"
+)
+
+// The node metadata keys toggling the header injections of HTTPNodeSettings at runtime,
+// the value is a boolean, e.g. "http.inject_xff" = "false". An absent or invalid toggle keeps the injection enabled.
+const (
+	// MDKeyInjectXFF toggles the X-Forwarded-For injection.
+	MDKeyInjectXFF = "http.inject_xff"
+	// MDKeyInjectHeaderPrefix is the prefix of the toggles of the RequestHeader entries,
+	// followed by the lower-cased header name, e.g. "http.inject.x-real-ip".
+	MDKeyInjectHeaderPrefix = "http.inject."
+)
+
+// ApplyHTTPHeader applies the header settings of the node HTTPNodeSettings to the request req:
+// the inbound headers are stripped by the allow and deny lists first, then the header injections are applied,
+// the metadata toggles of the node are consulted first, see MDKeyInjectXFF and MDKeyInjectHeaderPrefix.
+// The client address for X-Forwarded-For is from ctx, see ctxvalue.ContextWithClientAddr.
+func (node *Node) ApplyHTTPHeader(ctx context.Context, req *http.Request) {
+	settings := node.options.HTTP
+	if settings == nil || req == nil {
+		return
+	}
+	md := node.options.Metadata
+
+	if settings.Host != "" {
+		req.Host = settings.Host
+	}
+
+	if len(settings.AllowRequestHeader) > 0 {
+		allowed := make(map[string]struct{}, len(settings.AllowRequestHeader))
+		for _, k := range settings.AllowRequestHeader {
+			allowed[http.CanonicalHeaderKey(k)] = struct{}{}
+		}
+		for k := range req.Header {
+			if _, ok := allowed[http.CanonicalHeaderKey(k)]; !ok {
+				req.Header.Del(k)
+			}
+		}
+	}
+	for _, k := range settings.StripRequestHeader {
+		req.Header.Del(k)
+	}
+
+	for k, v := range settings.RequestHeader {
+		if injectEnabled(md, MDKeyInjectHeaderPrefix+strings.ToLower(k)) {
+			req.Header.Set(k, v)
+		}
+	}
+
+	if settings.XForwardedFor && injectEnabled(md, MDKeyInjectXFF) {
+		if addr := ctxvalue.ClientAddrFromContext(ctx); addr != "" {
+			host, _, err := net.SplitHostPort(addr)
+			if err != nil {
+				host = addr
+			}
+			if prior := req.Header.Get("X-Forwarded-For"); prior != "" {
+				host = prior + ", " + host
+			}
+			req.Header.Set("X-Forwarded-For", host)
+		}
+	}
+}
+
+func injectEnabled(md metadata.Metadata, key string) bool {
+	if md == nil || !md.IsExists(key) {
+		return true
+	}
+	switch v := md.Get(key).(type) {
+	case bool:
+		return v
+	case string:
+		b, err := strconv.ParseBool(strings.TrimSpace(v))
+		return err != nil || b
+	default:
+		return true
+	}
+}
@@ -0,0 +1,168 @@
+package chain
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/go-gost/core/common/ctxvalue"
+)
+
+// headerRequest applies the header settings of node to a new request from the client 192.0.2.1.
+func headerRequest(t *testing.T, node *Node, header http.Header) *http.Request {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, vs := range header {
+		req.Header[k] = vs
+	}
+	ctx := ctxvalue.ContextWithClientAddr(context.Background(), "192.0.2.1:12345")
+	node.ApplyHTTPHeader(ctx, req)
+	return req
+}
+
+func headerSettings() *HTTPNodeSettings {
+	return &HTTPNodeSettings{
+		RequestHeader: map[string]string{
+			"X-Real-IP": "198.51.100.1",
+			"X-Env":     "prod",
+		},
+		XForwardedFor: true,
+	}
+}
+
+func TestNodeHTTPHeader(t *testing.T) {
+	node := NewNode("a", "192.0.2.10:80", HTTPNodeOption(headerSettings()))
+	req := headerRequest(t, node, http.Header{"X-Forwarded-For": {"10.0.0.1"}})
+
+	if v := req.Header.Get("X-Forwarded-For"); v != "10.0.0.1, 192.0.2.1" {
+		t.Fatalf("unexpected X-Forwarded-For %q", v)
+	}
+	if req.Header.Get("X-Real-IP") != "198.51.100.1" || req.Header.Get("X-Env") != "prod" {
+		t.Fatalf("unexpected header %v", req.Header)
+	}
+}
+
+func TestNodeHTTPHeaderToggle(t *testing.T) {
+	for _, tc := range []struct {
+		md      mapMetadata
+		xff     bool
+		realIP  bool
+		comment string
+	}{
+		{mapMetadata{MDKeyInjectXFF: "false"}, false, true, "xff disabled"},
+		{mapMetadata{MDKeyInjectXFF: false}, false, true, "xff disabled by bool"},
+		{mapMetadata{"http.inject.x-real-ip": "false"}, true, false, "header disabled"},
+		{mapMetadata{MDKeyInjectXFF: "true", "http.inject.x-real-ip": " 0 "}, true, false, "header disabled by 0"},
+		// the invalid toggle keeps the injection.
+		{mapMetadata{MDKeyInjectXFF: "maybe", "http.inject.x-real-ip": 0}, true, true, "invalid"},
+		{mapMetadata{}, true, true, "no toggle"},
+	} {
+		node := NewNode("a", "192.0.2.10:80", HTTPNodeOption(headerSettings()), MetadataNodeOption(tc.md))
+		req := headerRequest(t, node, nil)
+
+		if xff := req.Header.Get("X-Forwarded-For") != ""; xff != tc.xff {
+			t.Errorf("%s: X-Forwarded-For injected %v, want %v", tc.comment, xff, tc.xff)
+		}
+		if realIP := req.Header.Get("X-Real-IP") != ""; realIP != tc.realIP {
+			t.Errorf("%s: X-Real-IP injected %v, want %v", tc.comment, realIP, tc.realIP)
+		}
+		// the others are still applied.
+		if req.Header.Get("X-Env") != "prod" {
+			t.Errorf("%s: the untoggled header is not applied", tc.comment)
+		}
+	}
+}
+
+func TestNodeHTTPHeaderToggleRuntime(t *testing.T) {
+	md := mapMetadata{}
+	node := NewNode("a", "192.0.2.10:80", HTTPNodeOption(headerSettings()), MetadataNodeOption(md))
+
+	if req := headerRequest(t, node, nil); req.Header.Get("X-Forwarded-For") == "" {
+		t.Fatal("expected X-Forwarded-For")
+	}
+	// flipped without rebuilding the settings.
+	md.Set(MDKeyInjectXFF, "false")
+	if req := headerRequest(t, node, nil); req.Header.Get("X-Forwarded-For") != "" || req.Header.Get("X-Real-IP") == "" {
+		t.Fatalf("unexpected header after the toggle %v", req.Header)
+	}
+
+	// no settings, nothing is applied.
+	node = NewNode("b", "192.0.2.11:80", MetadataNodeOption(md))
+	if req := headerRequest(t, node, nil); len(req.Header) != 0 {
+		t.Fatalf("unexpected header %v", req.Header)
+	}
+}
+
+func TestNodeHTTPHeaderStrip(t *testing.T) {
+	settings := headerSettings()
+	settings.StripRequestHeader = []string{"cookie", "X-Internal-Token", "X-Env"}
+	node := NewNode("a", "192.0.2.10:80", HTTPNodeOption(settings))
+	req := headerRequest(t, node, http.Header{
+		"Cookie":           {"session=1"},
+		"X-Internal-Token": {"secret"},
+		"X-Env":            {"dev"},
+		"Accept":           {"*/*"},
+	})
+
+	if req.Header.Get("Cookie") != "" || req.Header.Get("X-Internal-Token") != "" {
+		t.Fatalf("the denied headers are not stripped %v", req.Header)
+	}
+	if req.Header.Get("Accept") != "*/*" {
+		t.Fatalf("the other header is stripped %v", req.Header)
+	}
+	// the set headers are applied after stripping, even the denied one.
+	if req.Header.Get("X-Env") != "prod" || req.Header.Get("X-Real-IP") != "198.51.100.1" {
+		t.Fatalf("the set headers are not applied %v", req.Header)
+	}
+}
+
+func TestNodeHTTPHeaderAllow(t *testing.T) {
+	settings := headerSettings()
+	settings.AllowRequestHeader = []string{"accept", "User-Agent"}
+	node := NewNode("a", "192.0.2.10:80", HTTPNodeOption(settings))
+	req := headerRequest(t, node, http.Header{
+		"Accept":          {"*/*"},
+		"User-Agent":      {"curl"},
+		"Cookie":          {"session=1"},
+		"X-Env":           {"dev"},
+		"X-Forwarded-For": {"10.0.0.1"},
+	})
+
+	if req.Header.Get("Accept") != "*/*" || req.Header.Get("User-Agent") != "curl" {
+		t.Fatalf("the allowed headers are dropped %v", req.Header)
+	}
+	if req.Header.Get("Cookie") != "" {
+		t.Fatalf("the header out of the allow list is forwarded %v", req.Header)
+	}
+	// the set headers are applied outside the allow list,
+	// the prior X-Forwarded-For is dropped before the injection.
+	if req.Header.Get("X-Env") != "prod" || req.Header.Get("X-Real-IP") != "198.51.100.1" {
+		t.Fatalf("the set headers are not applied %v", req.Header)
+	}
+	if v := req.Header.Get("X-Forwarded-For"); v != "192.0.2.1" {
+		t.Fatalf("unexpected X-Forwarded-For %q", v)
+	}
+	if len(req.Header) != 5 {
+		t.Fatalf("unexpected forwarded headers %v", req.Header)
+	}
+}
+
+func TestNodeHTTPHeaderAllowStrip(t *testing.T) {
+	// the deny list is applied to the allowed headers as well.
+	node := NewNode("a", "192.0.2.10:80", HTTPNodeOption(&HTTPNodeSettings{
+		AllowRequestHeader: []string{"Accept", "Cookie"},
+		StripRequestHeader: []string{"Cookie"},
+	}))
+	req := headerRequest(t, node, http.Header{
+		"Accept": {"*/*"},
+		"Cookie": {"session=1"},
+		"X-Env":  {"dev"},
+	})
+	if len(req.Header) != 1 || req.Header.Get("Accept") != "*/*" {
+		t.Fatalf("unexpected forwarded headers %v", req.Header)
+	}
+}
@@ -0,0 +1,102 @@
+package chain
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// nodeStats is the runtime counters of a node, which are kept across the merges of the node updates.
+type nodeStats struct {
+	// mu makes Node.Snapshot a consistent read of the counters and the marker:
+	// the updates hold the read lock so they do not block each other, and the snapshot holds the write lock.
+	mu          sync.RWMutex
+	activeConns int64
+	latency     int64
+	inflight    int64
+	draining    int32
+	// the phase timings of the last connection, see Node.Establish.
+	connectTime   int64
+	handshakeTime int64
+	firstByteTime int64
+}
+
+// add adds delta to the counter p of s, see nodeStats.mu.
+func (s *nodeStats) add(p *int64, delta int64) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	atomic.AddInt64(p, delta)
+}
+
+// store stores v into the counter p of s, see nodeStats.mu.
+func (s *nodeStats) store(p *int64, v int64) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	atomic.StoreInt64(p, v)
+}
+
+func (s *nodeStats) copy() *nodeStats {
+	return &nodeStats{
+		activeConns:   atomic.LoadInt64(&s.activeConns),
+		latency:       atomic.LoadInt64(&s.latency),
+		inflight:      atomic.LoadInt64(&s.inflight),
+		draining:      atomic.LoadInt32(&s.draining),
+		connectTime:   atomic.LoadInt64(&s.connectTime),
+		handshakeTime: atomic.LoadInt64(&s.handshakeTime),
+		firstByteTime: atomic.LoadInt64(&s.firstByteTime),
+	}
+}
+
+// MergeNodes reconciles the nodes re-listed from the service discovery with the current nodes,
+// instead of replacing the node set wholesale. A node in updates matching a current node by name and address
+// takes its static options (e.g. metadata and priority) from updates, while the runtime state
+// (active connections, latency, marker, drain state, join time, queue and warm connections)
+// is carried over from the current node, so the connections in progress are accounted correctly.
+// The nodes not in updates are removed and the new ones are added, the result is in the order of updates.
+// NodeAdded and NodeRemoved are published to the event buses of the added and removed nodes (see EventsNodeOption).
+func MergeNodes(nodes []*Node, updates []*Node) []*Node {
+	type key struct {
+		name string
+		addr string
+	}
+
+	current := make(map[key]*Node, len(nodes))
+	for _, node := range nodes {
+		if node != nil {
+			current[key{node.Name, node.Addr}] = node
+		}
+	}
+
+	kept := make(map[key]struct{}, len(nodes))
+	result := make([]*Node, 0, len(updates))
+	for _, node := range updates {
+		if node == nil {
+			continue
+		}
+		k := key{node.Name, node.Addr}
+		old := current[k]
+		if old == nil {
+			result = append(result, node)
+			node.options.Events.Publish(NodeAdded, node)
+			continue
+		}
+		kept[k] = struct{}{}
+
+		merged := node.Copy()
+		merged.stats = old.stats
+		merged.marker = old.marker
+		merged.joinTime = old.joinTime
+		merged.addrCache = old.addrCache
+		merged.connQueue = old.connQueue
+		merged.protocol = old.protocol
+		merged.budget = old.budget
+		merged.warm = old.warm
+		result = append(result, merged)
+	}
+
+	for k, node := range current {
+		if _, ok := kept[k]; !ok {
+			node.options.Events.Publish(NodeRemoved, node)
+		}
+	}
+	return result
+}
@@ -0,0 +1,86 @@
+package chain
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-gost/core/selector"
+)
+
+func TestMergeNodes(t *testing.T) {
+	bus := NewNodeEventBus()
+	a := NewNode("a", "192.0.2.1:80", PriorityNodeOption(1), MetadataNodeOption(mapMetadata{"v": 1}), EventsNodeOption(bus))
+	b := NewNode("b", "192.0.2.2:80", EventsNodeOption(bus))
+	c := NewNode("c", "192.0.2.3:80", EventsNodeOption(bus))
+	nodes := []*Node{a, b, c}
+
+	release, err := a.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.SetLatency(30 * time.Millisecond)
+	a.AddInflightBytes(1024)
+	a.Marker().Mark()
+	b.Drain(true)
+
+	events, cancel := bus.Subscribe(16)
+	defer cancel()
+
+	// the re-list changes the options of a, keeps b, adds d and drops c.
+	d := NewNode("d", "192.0.2.4:80", EventsNodeOption(bus))
+	nodes = MergeNodes(nodes, []*Node{
+		d,
+		NewNode("a", "192.0.2.1:80", PriorityNodeOption(5), MetadataNodeOption(mapMetadata{"v": 2}), EventsNodeOption(bus)),
+		NewNode("b", "192.0.2.2:80", EventsNodeOption(bus)),
+	})
+	nextEvent(t, events, NodeAdded, "d")
+	nextEvent(t, events, NodeRemoved, "c")
+	noEvent(t, events)
+
+	if len(nodes) != 3 || nodes[0] != d || nodes[1].Name != "a" || nodes[2].Name != "b" {
+		t.Fatalf("unexpected merged nodes %v", nodes)
+	}
+	ma, mb := nodes[1], nodes[2]
+
+	// the static options are updated.
+	if ma.Weight() != 5 || ma.Metadata().Get("v") != 2 {
+		t.Fatalf("the options are not updated, weight %d metadata %v", ma.Weight(), ma.Metadata().Get("v"))
+	}
+	// the runtime state is preserved.
+	if ma.ActiveConns() != 1 || ma.Latency() != 30*time.Millisecond || ma.InflightBytes() != 1024 {
+		t.Fatalf("the counters are not preserved: conns %d latency %s inflight %d",
+			ma.ActiveConns(), ma.Latency(), ma.InflightBytes())
+	}
+	if ma.Marker().Count() != 1 || !ma.JoinTime().Equal(a.JoinTime()) {
+		t.Fatal("the marker or the join time is not preserved")
+	}
+	if !mb.IsDraining() {
+		t.Fatal("the drain state is not preserved")
+	}
+
+	// the conn acquired before the merge is released on the merged node.
+	release()
+	if ma.ActiveConns() != 0 {
+		t.Fatalf("expected no active conns after release, got %d", ma.ActiveConns())
+	}
+	filter := selector.Pipeline[*Node](selector.FailFilter[*Node](1, 0), selector.DrainFilter[*Node]())
+	if vs := filter.Filter(context.Background(), nodes...); len(vs) != 1 || vs[0] != d {
+		t.Fatalf("the marked and draining nodes should be excluded, got %d nodes", len(vs))
+	}
+}
+
+func TestMergeNodesAddress(t *testing.T) {
+	a := NewNode("a", "192.0.2.1:80")
+	a.SetLatency(time.Second)
+
+	// the node with the same name but another address is a new node.
+	nodes := MergeNodes([]*Node{a}, []*Node{NewNode("a", "192.0.2.9:80"), nil})
+	if len(nodes) != 1 || nodes[0].Latency() != 0 {
+		t.Fatalf("unexpected merged nodes %v", nodes)
+	}
+
+	if nodes := MergeNodes(nodes, nil); len(nodes) != 0 {
+		t.Fatalf("expected no node, got %v", nodes)
+	}
+}
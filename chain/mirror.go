@@ -0,0 +1,52 @@
+package chain
+
+import (
+	"context"
+	"math/rand"
+	"net"
+	"time"
+
+	xnet "github.com/go-gost/core/common/net"
+)
+
+// MirrorNodeSettings configures mirroring a sampled fraction of the traffic to a shadow node.
+// The response from the shadow node is discarded.
+type MirrorNodeSettings struct {
+	Node *Node
+	// Rate is the sample rate in range [0, 1].
+	Rate float64
+	// Timeout is the timeout for dialing and writing to the shadow node, see Node.MirrorConn.
+	Timeout time.Duration
+}
+
+// Sample reports whether the current request should be mirrored.
+func (s *MirrorNodeSettings) Sample() bool {
+	if s == nil || s.Node == nil || s.Rate <= 0 {
+		return false
+	}
+	if s.Rate >= 1 {
+		return true
+	}
+	return rand.Float64() < s.Rate
+}
+
+// MirrorConn wraps the connection conn to the node to copy the data written to it to the shadow node
+// of the MirrorNodeSettings (see MirrorNodeOption), if the connection is sampled.
+// The shadow node is established asynchronously within the Timeout, so conn is never delayed or failed by the shadow.
+// If the connection is not sampled, conn is returned as is.
+func (node *Node) MirrorConn(network string, conn net.Conn) net.Conn {
+	s := node.options.Mirror
+	if !s.Sample() {
+		return conn
+	}
+
+	return xnet.MirrorDialConn(conn, func() (net.Conn, error) {
+		ctx := context.Background()
+		if s.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, s.Timeout)
+			defer cancel()
+		}
+		return s.Node.Establish(ctx, network)
+	}, s.Timeout)
+}
@@ -0,0 +1,168 @@
+package chain
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMirrorNodeSettingsSample(t *testing.T) {
+	shadow := NewNode("shadow", "127.0.0.1:0")
+
+	for _, rate := range []float64{0.1, 0.5} {
+		s := &MirrorNodeSettings{Node: shadow, Rate: rate}
+		const n = 20000
+		var sampled int
+		for i := 0; i < n; i++ {
+			if s.Sample() {
+				sampled++
+			}
+		}
+		if got := float64(sampled) / n; math.Abs(got-rate) > 0.02 {
+			t.Errorf("rate %.2f: sampled %.3f", rate, got)
+		}
+	}
+
+	for _, s := range []*MirrorNodeSettings{
+		nil,
+		{Rate: 1},
+		{Node: shadow, Rate: 0},
+	} {
+		if s.Sample() {
+			t.Errorf("%+v should not sample", s)
+		}
+	}
+	if s := (&MirrorNodeSettings{Node: shadow, Rate: 1}); !s.Sample() {
+		t.Error("rate 1 should always sample")
+	}
+}
+
+// serveTCP serves the connections by handle until the test ends.
+func serveTCP(t *testing.T, handle func(net.Conn)) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				handle(conn)
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// shadowServer records the data received by each connection.
+type shadowServer struct {
+	received map[string]bool
+	mu       sync.Mutex
+}
+
+func (s *shadowServer) handle(conn net.Conn) {
+	b, _ := io.ReadAll(conn)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.received[string(b)] = true
+}
+
+func (s *shadowServer) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.received)
+}
+
+// mirrorRequests sends n requests through the mirrored connections to node and checks the primary responses.
+func mirrorRequests(t *testing.T, node *Node, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		conn, err := node.Dial(context.Background(), "tcp")
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn = node.MirrorConn("tcp", conn)
+
+		req := fmt.Sprintf("request-%03d", i)
+		if _, err := conn.Write([]byte(req)); err != nil {
+			t.Fatal(err)
+		}
+		b := make([]byte, len(req))
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		if _, err := io.ReadFull(conn, b); err != nil || string(b) != req {
+			t.Fatalf("the primary response is changed: %q %v", b, err)
+		}
+		conn.Close()
+	}
+}
+
+func TestNodeMirrorConn(t *testing.T) {
+	primary := serveTCP(t, func(conn net.Conn) { io.Copy(conn, conn) })
+	shadow := &shadowServer{received: make(map[string]bool)}
+	shadowAddr := serveTCP(t, shadow.handle)
+
+	// all the connections are mirrored.
+	node := NewNode("primary", primary, MirrorNodeOption(&MirrorNodeSettings{
+		Node:    NewNode("shadow", shadowAddr),
+		Rate:    1,
+		Timeout: time.Second,
+	}))
+	mirrorRequests(t, node, 20)
+	waitFor(t, "the requests are not mirrored", func() bool { return shadow.len() == 20 })
+	for i := 0; i < 20; i++ {
+		if req := fmt.Sprintf("request-%03d", i); !shadow.received[req] {
+			t.Fatalf("the shadow does not receive %s", req)
+		}
+	}
+
+	// the sampled fraction is mirrored.
+	shadow.received = make(map[string]bool)
+	node = NewNode("primary", primary, MirrorNodeOption(&MirrorNodeSettings{
+		Node:    NewNode("shadow", shadowAddr),
+		Rate:    0.25,
+		Timeout: time.Second,
+	}))
+	const n = 400
+	mirrorRequests(t, node, n)
+	// wait until the shadow is idle.
+	for last := -1; last != shadow.len(); time.Sleep(100 * time.Millisecond) {
+		last = shadow.len()
+	}
+	if got := float64(shadow.len()) / n; math.Abs(got-0.25) > 0.1 {
+		t.Fatalf("mirrored %.3f of the requests, want 0.25", got)
+	}
+
+	// not mirrored without the settings.
+	if conn, _ := net.Pipe(); NewNode("a", primary).MirrorConn("tcp", conn) != conn {
+		t.Fatal("the connection should not be wrapped")
+	}
+}
+
+func TestNodeMirrorConnShadowDown(t *testing.T) {
+	primary := serveTCP(t, func(conn net.Conn) { io.Copy(conn, conn) })
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	down := ln.Addr().String()
+	ln.Close()
+
+	// the unreachable shadow never fails the primary.
+	node := NewNode("primary", primary, MirrorNodeOption(&MirrorNodeSettings{
+		Node:    NewNode("shadow", down),
+		Rate:    1,
+		Timeout: 100 * time.Millisecond,
+	}))
+	mirrorRequests(t, node, 5)
+}
@@ -1,19 +1,32 @@
 package chain
 
 import (
+	"container/list"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
 	"regexp"
+	"strings"
 	"sync/atomic"
 	"time"
 
 	"github.com/go-gost/core/auth"
 	"github.com/go-gost/core/bypass"
+	xnet "github.com/go-gost/core/common/net"
 	"github.com/go-gost/core/hosts"
 	"github.com/go-gost/core/metadata"
+	"github.com/go-gost/core/observer"
 	"github.com/go-gost/core/resolver"
 	"github.com/go-gost/core/routing"
 	"github.com/go-gost/core/selector"
 )
 
+const (
+	// LabelProtocols is the node label of the supported request protocols, see Node.SupportsProtocol.
+	LabelProtocols = "protocols"
+)
+
 type NodeFilterSettings struct {
 	Protocol string
 	Host     string
@@ -38,6 +51,13 @@ type HTTPNodeSettings struct {
 	Auther              auth.Authenticator
 	RewriteURL          []HTTPURLRewriteSetting
 	RewriteResponseBody []HTTPBodyRewriteSettings
+	// XForwardedFor appends the client address to the X-Forwarded-For header of the requests.
+	XForwardedFor bool
+	// StripRequestHeader is the deny list of the inbound request headers removed before forwarding, e.g. Cookie.
+	StripRequestHeader []string
+	// AllowRequestHeader is the allow list of the inbound request headers, the others are removed before forwarding.
+	// An empty list allows all the headers.
+	AllowRequestHeader []string
 }
 
 type TLSNodeSettings struct {
@@ -48,6 +68,29 @@ type TLSNodeSettings struct {
 		MaxVersion   string
 		CipherSuites []string
 		ALPN         []string
+		// SessionTicketsDisabled disables the TLS session resumption.
+		SessionTicketsDisabled bool
+		// SessionCacheSize is the capacity of the client session cache shared by the connections to the node.
+		SessionCacheSize int
+	}
+	// SessionCache is the client session cache for session resumption,
+	// it is created by NewNode if SessionCacheSize is set.
+	SessionCache tls.ClientSessionCache
+}
+
+// ApplySession applies the session resumption settings to the client config cfg.
+func (s *TLSNodeSettings) ApplySession(cfg *tls.Config) {
+	if s == nil || cfg == nil {
+		return
+	}
+
+	if s.Options.SessionTicketsDisabled {
+		cfg.SessionTicketsDisabled = true
+		cfg.ClientSessionCache = nil
+		return
+	}
+	if s.SessionCache != nil {
+		cfg.ClientSessionCache = s.SessionCache
 	}
 }
 
@@ -63,6 +106,27 @@ type NodeOptions struct {
 	Metadata   metadata.Metadata
 	Matcher    routing.Matcher
 	Priority   int
+	DSCP       int
+	Mirror     *MirrorNodeSettings
+	Schema     *metadata.Schema
+	PreResolve *PreResolveSettings
+	Labels     map[string]string
+	Tier       int
+	MaxConns   int
+	Events     *NodeEventBus
+	Queue      *QueueNodeSettings
+	Protocols  []string
+	Circuit    *CircuitNodeSettings
+	Group      *NodeGroup
+	Budget     *BudgetNodeSettings
+	DialFunc   DialFunc
+	Warm       *WarmNodeSettings
+	// Observer receives the connection phase timings of the node, see Node.Establish.
+	Observer observer.Observer
+	// Marker is the base failure marker of the node, default is selector.NewFailMarker.
+	Marker selector.Marker
+	// ConnectTimeout is the timeout of the dial and handshake to the node, see Node.ConnectContext.
+	ConnectTimeout time.Duration
 }
 
 type NodeOption func(*NodeOptions)
@@ -133,13 +197,132 @@ func PriorityNodeOption(priority int) NodeOption {
 	}
 }
 
+// DSCPNodeOption sets the DSCP value of the connections to the node, see Node.Dial.
+func DSCPNodeOption(dscp int) NodeOption {
+	return func(o *NodeOptions) {
+		o.DSCP = dscp
+	}
+}
+
+func MirrorNodeOption(mirror *MirrorNodeSettings) NodeOption {
+	return func(o *NodeOptions) {
+		o.Mirror = mirror
+	}
+}
+
+// MetadataSchemaNodeOption sets the schema to validate the node metadata, see NewValidNode.
+func MetadataSchemaNodeOption(schema *metadata.Schema) NodeOption {
+	return func(o *NodeOptions) {
+		o.Schema = schema
+	}
+}
+
+func PreResolveNodeOption(settings *PreResolveSettings) NodeOption {
+	return func(o *NodeOptions) {
+		o.PreResolve = settings
+	}
+}
+
+func LabelsNodeOption(labels map[string]string) NodeOption {
+	return func(o *NodeOptions) {
+		o.Labels = labels
+	}
+}
+
+// TierNodeOption sets the failover tier of the node, lower tier is preferred, see selector.TieredStrategy.
+func TierNodeOption(tier int) NodeOption {
+	return func(o *NodeOptions) {
+		o.Tier = tier
+	}
+}
+
+// MaxConnsNodeOption sets the maximum number of active connections of the node, 0 means unlimited.
+func MaxConnsNodeOption(n int) NodeOption {
+	return func(o *NodeOptions) {
+		o.MaxConns = n
+	}
+}
+
+// EventsNodeOption sets the event bus to publish the marked, recovered and drained events of the node.
+func EventsNodeOption(bus *NodeEventBus) NodeOption {
+	return func(o *NodeOptions) {
+		o.Events = bus
+	}
+}
+
+func QueueNodeOption(settings *QueueNodeSettings) NodeOption {
+	return func(o *NodeOptions) {
+		o.Queue = settings
+	}
+}
+
+// ProtocolFallbackNodeOption sets the ordered list of the transport protocols to try
+// when connecting to the node, see Node.DialProtocols.
+func ProtocolFallbackNodeOption(protocols []string) NodeOption {
+	return func(o *NodeOptions) {
+		o.Protocols = protocols
+	}
+}
+
+// ConnectTimeoutNodeOption sets the timeout of the connection establishment to the node,
+// independent of the overall request deadline.
+func ConnectTimeoutNodeOption(timeout time.Duration) NodeOption {
+	return func(o *NodeOptions) {
+		o.ConnectTimeout = timeout
+	}
+}
+
+// CircuitNodeSettings enables the circuit breaker of the node, see selector.NewCircuitMarker.
+type CircuitNodeSettings struct {
+	// Threshold is the number of consecutive failures to open the circuit.
+	Threshold int
+	// OpenTimeout is the duration the circuit stays open before a trial.
+	OpenTimeout time.Duration
+}
+
+func CircuitNodeOption(settings *CircuitNodeSettings) NodeOption {
+	return func(o *NodeOptions) {
+		o.Circuit = settings
+	}
+}
+
+func ObserverNodeOption(observer observer.Observer) NodeOption {
+	return func(o *NodeOptions) {
+		o.Observer = observer
+	}
+}
+
+// MarkerNodeOption sets the base failure marker of the node, e.g. selector.NewErrorRateMarker
+// for the success rate weighting (see selector.SuccessRateStrategy). It takes precedence over CircuitNodeOption.
+func MarkerNodeOption(m selector.Marker) NodeOption {
+	return func(o *NodeOptions) {
+		o.Marker = m
+	}
+}
+
+// DialFunc is a custom dial function of a node.
+type DialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// DialFuncNodeOption sets a custom dial function to connect to the node, which is preferred over the transport,
+// e.g. for the exotic transports or the in-process pipes in tests. See Node.Dial.
+func DialFuncNodeOption(fn DialFunc) NodeOption {
+	return func(o *NodeOptions) {
+		o.DialFunc = fn
+	}
+}
+
 type Node struct {
-	Name        string
-	Addr        string
-	marker      selector.Marker
-	options     NodeOptions
-	activeConns int64
-	latency     int64
+	Name      string
+	Addr      string
+	marker    selector.Marker
+	options   NodeOptions
+	stats     *nodeStats
+	joinTime  time.Time
+	addrCache *addrCache
+	connQueue *connQueue
+	protocol  *protocolState
+	budget    *byteBudget
+	warm      *warmPool
 }
 
 func NewNode(name string, addr string, opts ...NodeOption) *Node {
@@ -150,12 +333,64 @@ func NewNode(name string, addr string, opts ...NodeOption) *Node {
 		}
 	}
 
-	return &Node{
-		Name:    name,
-		Addr:    addr,
-		marker:  selector.NewFailMarker(),
-		options: options,
+	if ts := options.TLS; ts != nil && ts.SessionCache == nil &&
+		!ts.Options.SessionTicketsDisabled && ts.Options.SessionCacheSize > 0 {
+		ts.SessionCache = tls.NewLRUClientSessionCache(ts.Options.SessionCacheSize)
+	}
+
+	node := &Node{
+		Name:      name,
+		Addr:      addr,
+		marker:    selector.NewFailMarker(),
+		options:   options,
+		stats:     &nodeStats{},
+		joinTime:  time.Now(),
+		addrCache: &addrCache{},
+		connQueue: &connQueue{waiters: list.New()},
+		protocol:  &protocolState{},
+		budget:    &byteBudget{},
+		warm:      newWarmPool(),
 	}
+	if settings := options.Circuit; settings != nil {
+		node.marker = selector.NewCircuitMarker(
+			selector.ThresholdCircuitOption(settings.Threshold),
+			selector.OpenTimeoutCircuitOption(settings.OpenTimeout),
+			selector.StateChangeCircuitOption(func(from, to selector.CircuitState) {
+				options.Events.Publish(circuitEventKinds[to], node)
+			}),
+		)
+	}
+	if options.Marker != nil {
+		node.marker = options.Marker
+	}
+	node.marker = &lockedMarker{
+		Marker: node.marker,
+		stats:  node.stats,
+	}
+	if options.Group != nil {
+		node.marker = &groupMarker{
+			Marker: node.marker,
+			group:  options.Group,
+		}
+	}
+	if options.Events != nil {
+		node.marker = &eventMarker{
+			Marker: node.marker,
+			bus:    options.Events,
+			node:   node,
+		}
+	}
+	return node
+}
+
+// NewValidNode is like NewNode, but also validates the node metadata against the schema
+// set by MetadataSchemaNodeOption, so a misconfigured node is rejected at construction.
+func NewValidNode(name string, addr string, opts ...NodeOption) (*Node, error) {
+	node := NewNode(name, addr, opts...)
+	if err := node.options.Schema.Validate(node.options.Metadata); err != nil {
+		return nil, fmt.Errorf("node %s: %w", name, err)
+	}
+	return node, nil
 }
 
 func (node *Node) Options() *NodeOptions {
@@ -175,25 +410,145 @@ func (node *Node) Marker() selector.Marker {
 func (node *Node) Copy() *Node {
 	n := &Node{}
 	*n = *node
+	if node.stats != nil {
+		n.stats = node.stats.copy()
+	}
 	return n
 }
 
 func (node *Node) ActiveConns() int64 {
-	return atomic.LoadInt64(&node.activeConns)
+	return atomic.LoadInt64(&node.stats.activeConns)
 }
 
 func (node *Node) IncActiveConns() {
-	atomic.AddInt64(&node.activeConns, 1)
+	node.stats.add(&node.stats.activeConns, 1)
 }
 
 func (node *Node) DecActiveConns() {
-	atomic.AddInt64(&node.activeConns, -1)
+	node.stats.add(&node.stats.activeConns, -1)
 }
 
 func (node *Node) Latency() time.Duration {
-	return time.Duration(atomic.LoadInt64(&node.latency))
+	return time.Duration(atomic.LoadInt64(&node.stats.latency))
 }
 
 func (node *Node) SetLatency(d time.Duration) {
-	atomic.StoreInt64(&node.latency, int64(d))
+	node.stats.store(&node.stats.latency, int64(d))
+}
+
+// InflightBytes returns the bytes relayed on the active connections of the node, see AddInflightBytes.
+func (node *Node) InflightBytes() int64 {
+	return atomic.LoadInt64(&node.stats.inflight)
+}
+
+// AddInflightBytes adds n to the in-flight bytes of the node, it is fed by the relay (see xnet.InflightRelayOption).
+func (node *Node) AddInflightBytes(n int64) {
+	node.stats.add(&node.stats.inflight, n)
+}
+
+// Weight implements selector.Weighted interface, the weight is derived from the node priority.
+func (node *Node) Weight() int {
+	return node.options.Priority
+}
+
+// JoinTime implements selector.Joinable interface.
+func (node *Node) JoinTime() time.Time {
+	return node.joinTime
+}
+
+// Drain marks the node as draining or not, a draining node should not be selected
+// for new connections while the existing connections are kept.
+func (node *Node) Drain(b bool) {
+	var v int32
+	if b {
+		v = 1
+	}
+	node.stats.mu.RLock()
+	changed := atomic.SwapInt32(&node.stats.draining, v) != v
+	node.stats.mu.RUnlock()
+	if changed && b {
+		node.options.Events.Publish(NodeDrained, node)
+	}
+}
+
+func (node *Node) IsDraining() bool {
+	return atomic.LoadInt32(&node.stats.draining) == 1
+}
+
+// Labels implements selector.Labeled interface.
+func (node *Node) Labels() map[string]string {
+	return node.options.Labels
+}
+
+// SupportsProtocol implements selector.ProtocolCapable interface, the supported request protocols
+// are the comma-separated list in the label LabelProtocols, e.g. "tcp,tls". A node without the label supports any protocol.
+func (node *Node) SupportsProtocol(protocol string) bool {
+	v, ok := node.options.Labels[LabelProtocols]
+	if !ok {
+		return true
+	}
+	for _, s := range strings.Split(v, ",") {
+		if strings.EqualFold(strings.TrimSpace(s), protocol) {
+			return true
+		}
+	}
+	return false
+}
+
+// Tier implements selector.Tiered interface.
+func (node *Node) Tier() int {
+	return node.options.Tier
+}
+
+// Key implements selector.Keyed interface.
+func (node *Node) Key() string {
+	return node.Name
+}
+
+// ConnectContext returns the context for the dial and handshake to the node,
+// which is done after the ConnectTimeout or the deadline of ctx, whichever is earlier.
+// If ConnectTimeout is not set, ctx is used as is.
+// The context should only be used for the connection establishment, not for the subsequent transfer.
+func (node *Node) ConnectContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if node.options.ConnectTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, node.options.ConnectTimeout)
+}
+
+// Dial connects to the node address by the custom dial function if it is set (see DialFuncNodeOption),
+// otherwise by the transport. The dial is bounded by the ConnectTimeout (see Node.ConnectContext).
+// The DSCP value of the node is set on the socket, see DSCPNodeOption.
+func (node *Node) Dial(ctx context.Context, network string) (net.Conn, error) {
+	ctx, cancel := node.ConnectContext(ctx)
+	defer cancel()
+
+	return node.dial(ctx, network)
+}
+
+func (node *Node) dial(ctx context.Context, network string) (net.Conn, error) {
+	var conn net.Conn
+	var err error
+	switch {
+	case node.options.DialFunc != nil:
+		conn, err = node.options.DialFunc(ctx, network, node.Addr)
+	case node.options.Transport != nil:
+		conn, err = node.options.Transport.Dial(ctx, node.Addr)
+	default:
+		var d net.Dialer
+		if dscp := node.options.DSCP; dscp > 0 {
+			d.Control = xnet.DSCPControl(dscp)
+		}
+		return d.DialContext(ctx, network, node.Addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// the custom dialers may not be the sockets, see xnet.SetDSCP.
+	if err := xnet.SetDSCP(conn, node.options.DSCP); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
 }
@@ -0,0 +1,353 @@
+package chain
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-gost/core/metadata"
+	"github.com/go-gost/core/selector"
+)
+
+// mapMetadata is a metadata.Metadata backed by a map.
+type mapMetadata map[string]any
+
+func (m mapMetadata) IsExists(key string) bool {
+	_, ok := m[key]
+	return ok
+}
+
+func (m mapMetadata) Set(key string, value any) {
+	m[key] = value
+}
+
+func (m mapMetadata) Get(key string) any {
+	return m[key]
+}
+
+func TestNewValidNode(t *testing.T) {
+	schema := &metadata.Schema{
+		Fields: []metadata.Field{
+			{Key: "weight", Type: metadata.IntType, Required: true},
+		},
+	}
+
+	node, err := NewValidNode("a", "127.0.0.1:80",
+		MetadataSchemaNodeOption(schema), MetadataNodeOption(mapMetadata{"weight": "2"}))
+	if err != nil || node == nil {
+		t.Fatalf("expected a valid node, got %v", err)
+	}
+
+	_, err = NewValidNode("b", "127.0.0.1:80",
+		MetadataSchemaNodeOption(schema), MetadataNodeOption(mapMetadata{"weigth": "2"}))
+	if !errors.Is(err, metadata.ErrMissingKey) {
+		t.Fatalf("expected ErrMissingKey, got %v", err)
+	}
+
+	if _, err := NewValidNode("c", "127.0.0.1:80"); err != nil {
+		t.Fatalf("node without schema should be valid: %v", err)
+	}
+}
+
+// tlsServer serves TLS with a self-signed certificate, it writes a byte on each connection
+// after the handshake and reports whether the session is resumed.
+func tlsServer(t *testing.T) (addr string, resumed chan bool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		DNSNames:     []string{"example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	resumed = make(chan bool, 16)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				tc := conn.(*tls.Conn)
+				if err := tc.Handshake(); err != nil {
+					return
+				}
+				resumed <- tc.ConnectionState().DidResume
+				tc.Write([]byte{1})
+				io.Copy(io.Discard, tc)
+			}()
+		}
+	}()
+	return ln.Addr().String(), resumed
+}
+
+// tlsConnect connects to addr with the TLS settings of node, and reports whether the session is resumed.
+func tlsConnect(t *testing.T, node *Node, addr string) bool {
+	t.Helper()
+	cfg := &tls.Config{InsecureSkipVerify: true, ServerName: "example.com"}
+	node.options.TLS.ApplySession(cfg)
+
+	conn, err := tls.Dial("tcp", addr, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// read the session tickets sent after the handshake.
+	if _, err := io.ReadFull(conn, make([]byte, 1)); err != nil {
+		t.Fatal(err)
+	}
+	return conn.ConnectionState().DidResume
+}
+
+func TestNodeTLSSessionResumption(t *testing.T) {
+	addr, resumed := tlsServer(t)
+
+	settings := &TLSNodeSettings{}
+	settings.Options.SessionCacheSize = 8
+	node := NewNode("a", addr, TLSNodeOption(settings))
+	if settings.SessionCache == nil {
+		t.Fatal("session cache should be created for the node")
+	}
+
+	if tlsConnect(t, node, addr) || <-resumed {
+		t.Fatal("the first handshake should be full")
+	}
+	if !tlsConnect(t, node, addr) || !<-resumed {
+		t.Fatal("the second handshake should resume")
+	}
+
+	// the cache is shared by the concurrent dials.
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cfg := &tls.Config{InsecureSkipVerify: true, ServerName: "example.com"}
+			settings.ApplySession(cfg)
+			if conn, err := tls.Dial("tcp", addr, cfg); err == nil {
+				io.ReadFull(conn, make([]byte, 1))
+				conn.Close()
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestNodeTLSSessionResumptionDisabled(t *testing.T) {
+	addr, resumed := tlsServer(t)
+
+	settings := &TLSNodeSettings{}
+	settings.Options.SessionCacheSize = 8
+	settings.Options.SessionTicketsDisabled = true
+	node := NewNode("a", addr, TLSNodeOption(settings))
+	if settings.SessionCache != nil {
+		t.Fatal("session cache should not be created if disabled")
+	}
+
+	for i := 0; i < 2; i++ {
+		if tlsConnect(t, node, addr) || <-resumed {
+			t.Fatalf("handshake %d should be full", i)
+		}
+	}
+}
+
+// blockingDial blocks until ctx is done.
+func blockingDial(ctx context.Context, network, addr string) (net.Conn, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+// handshakeTransport dials by net.Pipe and blocks the handshake until ctx is done.
+type handshakeTransport struct {
+	Transporter
+}
+
+func (tr *handshakeTransport) Dial(ctx context.Context, addr string) (net.Conn, error) {
+	c1, c2 := net.Pipe()
+	c2.Close()
+	return c1, nil
+}
+
+func (tr *handshakeTransport) Handshake(ctx context.Context, conn net.Conn) (net.Conn, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestNodeConnectTimeout(t *testing.T) {
+	// the overall deadline is much larger than the connect timeout.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	node := NewNode("a", "192.0.2.1:443", DialFuncNodeOption(blockingDial), ConnectTimeoutNodeOption(50*time.Millisecond))
+	start := time.Now()
+	if _, err := node.Dial(ctx, "tcp"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the dial to be abandoned, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("the dial is abandoned after %s", elapsed)
+	}
+	if ctx.Err() != nil {
+		t.Fatal("the request context should not be done")
+	}
+
+	// the handshake is bounded as well.
+	node = NewNode("b", "192.0.2.1:443", TransportNodeOption(&handshakeTransport{}), ConnectTimeoutNodeOption(50*time.Millisecond))
+	if _, err := node.Establish(ctx, "tcp"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the handshake to be abandoned, got %v", err)
+	}
+
+	// the warm connection falls back to Establish.
+	node = NewNode("c", "192.0.2.1:443", DialFuncNodeOption(blockingDial), ConnectTimeoutNodeOption(50*time.Millisecond))
+	if _, _, err := node.WarmConn(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the warm connection to be abandoned, got %v", err)
+	}
+}
+
+func TestNodeConnectTimeoutUnset(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	// the deadline of the context is used.
+	node := NewNode("a", "192.0.2.1:443", DialFuncNodeOption(blockingDial))
+	if _, err := node.Dial(ctx, "tcp"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the context deadline, got %v", err)
+	}
+
+	// the connect context is never later than the request context.
+	node = NewNode("b", "192.0.2.1:443", ConnectTimeoutNodeOption(time.Hour))
+	cctx, ccancel := node.ConnectContext(ctx)
+	defer ccancel()
+	want, _ := ctx.Deadline()
+	if got, _ := cctx.Deadline(); !got.Equal(want) {
+		t.Fatalf("the connect deadline should be the earlier request deadline, got %s", got)
+	}
+}
+
+func TestNodeInflightSelection(t *testing.T) {
+	a, b := NewNode("a", "127.0.0.1:80"), NewNode("b", "127.0.0.1:81")
+	s := selector.InflightStrategy[*Node]()
+
+	// a carries one huge transfer, b carries more connections of small ones.
+	a.IncActiveConns()
+	a.AddInflightBytes(1 << 30)
+	for i := 0; i < 10; i++ {
+		b.IncActiveConns()
+		b.AddInflightBytes(1024)
+	}
+	for i := 0; i < 10; i++ {
+		if v := s.Apply(context.Background(), a, b); v != b {
+			t.Fatalf("the node with less in-flight bytes should be selected, got %s", v.Name)
+		}
+	}
+	if ns := a.Snapshot(); ns.Inflight != 1<<30 {
+		t.Fatalf("unexpected in-flight bytes in snapshot %d", ns.Inflight)
+	}
+
+	// the relay releases the bytes when it is done.
+	a.AddInflightBytes(-(1 << 30))
+	if v := s.Apply(context.Background(), a, b); v != a {
+		t.Fatalf("expected a after its transfer is done, got %s", v.Name)
+	}
+}
+
+// pipeTransport dials by net.Pipe and counts the dials.
+type pipeTransport struct {
+	Transporter
+	dials atomic.Int64
+}
+
+func (tr *pipeTransport) Dial(ctx context.Context, addr string) (net.Conn, error) {
+	tr.dials.Add(1)
+	c1, c2 := net.Pipe()
+	go func() {
+		c2.Write([]byte("tr"))
+		c2.Close()
+	}()
+	return c1, nil
+}
+
+func TestNodeDialFunc(t *testing.T) {
+	tr := &pipeTransport{}
+	var network, addr string
+	node := NewNode("a", "svc.bus:1", TransportNodeOption(tr), DialFuncNodeOption(func(ctx context.Context, nw, a string) (net.Conn, error) {
+		network, addr = nw, a
+		c1, c2 := net.Pipe()
+		go func() {
+			c2.Write([]byte("fn"))
+			c2.Close()
+		}()
+		return c1, nil
+	}))
+
+	// the custom dial function is preferred over the transport.
+	conn, err := node.Dial(context.Background(), "udp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := io.ReadAll(conn)
+	conn.Close()
+	if string(b) != "fn" || network != "udp" || addr != "svc.bus:1" || tr.dials.Load() != 0 {
+		t.Fatalf("the custom dial function is not used: %q %s %s, %d transport dials", b, network, addr, tr.dials.Load())
+	}
+
+	// without the dial function the transport is used.
+	node = NewNode("b", "svc.bus:1", TransportNodeOption(tr))
+	if conn, err = node.Dial(context.Background(), "tcp"); err != nil {
+		t.Fatal(err)
+	}
+	b, _ = io.ReadAll(conn)
+	conn.Close()
+	if string(b) != "tr" || tr.dials.Load() != 1 {
+		t.Fatalf("the transport is not used: %q, %d transport dials", b, tr.dials.Load())
+	}
+
+	// without both the address is dialed directly.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	node = NewNode("c", ln.Addr().String())
+	conn, err = node.Dial(context.Background(), "tcp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+
+	// the dial error is returned as is.
+	errDial := errors.New("bus is down")
+	node = NewNode("d", "svc.bus:1", DialFuncNodeOption(func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return nil, errDial
+	}))
+	if _, err := node.Dial(context.Background(), "tcp"); !errors.Is(err, errDial) {
+		t.Fatalf("expected the dial error, got %v", err)
+	}
+}
@@ -0,0 +1,137 @@
+package chain
+
+import (
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-gost/core/observer"
+)
+
+// PhaseTimings is the latency of each phase of a connection to a node,
+// which distinguishes the slow handshakes from the slow upstreams.
+type PhaseTimings struct {
+	// Connect is the time of the dial to the node.
+	Connect time.Duration `json:"connect"`
+	// Handshake is the time of the transport handshake, e.g. TLS.
+	Handshake time.Duration `json:"handshake"`
+	// FirstByte is the time from the first write (or the connection establishment if the peer speaks first)
+	// to the first byte read from the connection.
+	FirstByte time.Duration `json:"firstByte"`
+}
+
+// PhaseEvent reports the phase timings of a connection, it implements observer.Event interface.
+type PhaseEvent struct {
+	Node    string       `json:"node"`
+	Addr    string       `json:"addr"`
+	Timings PhaseTimings `json:"timings"`
+}
+
+func (e *PhaseEvent) Type() observer.EventType {
+	return observer.EventPhase
+}
+
+// PhaseTimings returns the phase timings of the last connection to the node.
+func (node *Node) PhaseTimings() PhaseTimings {
+	return PhaseTimings{
+		Connect:   time.Duration(atomic.LoadInt64(&node.stats.connectTime)),
+		Handshake: time.Duration(atomic.LoadInt64(&node.stats.handshakeTime)),
+		FirstByte: time.Duration(atomic.LoadInt64(&node.stats.firstByteTime)),
+	}
+}
+
+// Establish dials the node (see Node.Dial) and does the transport handshake if the transport is set,
+// the time of each phase is measured. The node latency is set to the sum of the connect and handshake time.
+// The returned connection measures the time to the first byte, then the timings are reported to
+// the observer set by ObserverNodeOption.
+// Both the dial and the handshake are bounded by the ConnectTimeout (see Node.ConnectContext).
+func (node *Node) Establish(ctx context.Context, network string) (net.Conn, error) {
+	ctx, cancel := node.ConnectContext(ctx)
+	defer cancel()
+
+	start := time.Now()
+	conn, err := node.dial(ctx, network)
+	if err != nil {
+		return nil, err
+	}
+	connected := time.Now()
+
+	var timings PhaseTimings
+	timings.Connect = connected.Sub(start)
+	node.stats.store(&node.stats.connectTime, int64(timings.Connect))
+
+	if tr := node.options.Transport; tr != nil {
+		cc, err := tr.Handshake(ctx, conn)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = cc
+		timings.Handshake = time.Since(connected)
+	}
+	node.stats.store(&node.stats.handshakeTime, int64(timings.Handshake))
+	node.SetLatency(timings.Connect + timings.Handshake)
+
+	return &phaseConn{
+		Conn:    conn,
+		node:    node,
+		timings: timings,
+		start:   time.Now(),
+	}, nil
+}
+
+// phaseConn measures the time to the first byte of the connection.
+type phaseConn struct {
+	net.Conn
+	node    *Node
+	timings PhaseTimings
+	start   time.Time
+	written atomic.Bool
+	once    sync.Once
+	mu      sync.Mutex
+}
+
+func (c *phaseConn) Write(b []byte) (int, error) {
+	if !c.written.Load() && c.written.CompareAndSwap(false, true) {
+		c.mu.Lock()
+		c.start = time.Now()
+		c.mu.Unlock()
+	}
+	return c.Conn.Write(b)
+}
+
+func (c *phaseConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.once.Do(func() {
+			c.mu.Lock()
+			c.timings.FirstByte = time.Since(c.start)
+			c.mu.Unlock()
+			c.node.stats.store(&c.node.stats.firstByteTime, int64(c.timings.FirstByte))
+			c.report()
+		})
+	}
+	return n, err
+}
+
+func (c *phaseConn) Close() error {
+	// the connection closed without any byte read is reported without the first byte time.
+	c.once.Do(c.report)
+	return c.Conn.Close()
+}
+
+func (c *phaseConn) report() {
+	o := c.node.options.Observer
+	if o == nil {
+		return
+	}
+	ev := &PhaseEvent{
+		Node:    c.node.Name,
+		Addr:    c.node.Addr,
+		Timings: c.timings,
+	}
+	// the observer must not block the data transfer.
+	go o.Observe(context.Background(), []observer.Event{ev})
+}
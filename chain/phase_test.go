@@ -0,0 +1,145 @@
+package chain
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-gost/core/observer"
+)
+
+// phaseTransport is a fake transport with the controlled delay of each phase,
+// the peer answers the first request after the firstByte delay.
+type phaseTransport struct {
+	Transporter
+	connect   time.Duration
+	handshake time.Duration
+	firstByte time.Duration
+}
+
+func (tr *phaseTransport) Dial(ctx context.Context, addr string) (net.Conn, error) {
+	time.Sleep(tr.connect)
+
+	c1, c2 := net.Pipe()
+	go func() {
+		defer c2.Close()
+		buf := make([]byte, 64)
+		if _, err := c2.Read(buf); err != nil {
+			return
+		}
+		time.Sleep(tr.firstByte)
+		c2.Write([]byte("response"))
+		io.Copy(io.Discard, c2)
+	}()
+	return c1, nil
+}
+
+func (tr *phaseTransport) Handshake(ctx context.Context, conn net.Conn) (net.Conn, error) {
+	time.Sleep(tr.handshake)
+	return conn, nil
+}
+
+// chanObserver sends the observed events to a channel.
+type chanObserver chan observer.Event
+
+func (o chanObserver) Observe(ctx context.Context, events []observer.Event, opts ...observer.Option) error {
+	for _, ev := range events {
+		o <- ev
+	}
+	return nil
+}
+
+// assertPhase checks that the phase d is measured as the delay want, which is attributed to no other phase.
+func assertPhase(t *testing.T, phase string, d, want time.Duration) {
+	t.Helper()
+	if d < want || d > want+40*time.Millisecond {
+		t.Fatalf("%s is %s, want %s", phase, d, want)
+	}
+}
+
+func TestNodePhaseTimings(t *testing.T) {
+	tr := &phaseTransport{
+		connect:   20 * time.Millisecond,
+		handshake: 80 * time.Millisecond,
+		firstByte: 140 * time.Millisecond,
+	}
+	events := make(chanObserver, 1)
+	node := NewNode("a", "192.0.2.1:443", TransportNodeOption(tr), ObserverNodeOption(events))
+
+	conn, err := node.Establish(context.Background(), "tcp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	timings := node.PhaseTimings()
+	assertPhase(t, "connect", timings.Connect, tr.connect)
+	assertPhase(t, "handshake", timings.Handshake, tr.handshake)
+	if timings.FirstByte != 0 {
+		t.Fatalf("the first byte is not read yet, got %s", timings.FirstByte)
+	}
+	if node.Latency() != timings.Connect+timings.Handshake {
+		t.Fatalf("the latency %s should be the connect and handshake time", node.Latency())
+	}
+
+	// the time to the first byte is measured from the first write.
+	time.Sleep(50 * time.Millisecond)
+	if _, err := conn.Write([]byte("request")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 64)
+	if _, err := conn.Read(buf); err != nil {
+		t.Fatal(err)
+	}
+	timings = node.PhaseTimings()
+	assertPhase(t, "first byte", timings.FirstByte, tr.firstByte)
+	if ns := node.Snapshot(); ns.Phases != timings {
+		t.Fatalf("unexpected phases of the snapshot %+v", ns.Phases)
+	}
+
+	select {
+	case ev := <-events:
+		pe, ok := ev.(*PhaseEvent)
+		if !ok || ev.Type() != observer.EventPhase || pe.Node != "a" || pe.Addr != "192.0.2.1:443" || pe.Timings != timings {
+			t.Fatalf("unexpected event %+v", ev)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the phase timings are not reported")
+	}
+}
+
+func TestNodePhaseTimingsNoRead(t *testing.T) {
+	events := make(chanObserver, 2)
+	dial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return (&phaseTransport{connect: 10 * time.Millisecond}).Dial(ctx, addr)
+	}
+	node := NewNode("a", "192.0.2.1:80", DialFuncNodeOption(dial), ObserverNodeOption(events))
+
+	conn, err := node.Establish(context.Background(), "tcp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// without the transport handshake the handshake time is zero.
+	if timings := node.PhaseTimings(); timings.Handshake != 0 {
+		t.Fatalf("unexpected handshake time %s", timings.Handshake)
+	}
+
+	// the connection closed without any byte read is reported once, without the first byte time.
+	conn.Close()
+	conn.Close()
+	select {
+	case ev := <-events:
+		if pe := ev.(*PhaseEvent); pe.Timings.FirstByte != 0 || pe.Timings.Connect < 10*time.Millisecond {
+			t.Fatalf("unexpected timings %+v", pe.Timings)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the phase timings are not reported on close")
+	}
+	select {
+	case ev := <-events:
+		t.Fatalf("unexpected event %+v", ev)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,74 @@
+package chain
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-gost/core/common/ctxvalue"
+	"github.com/go-gost/core/selector"
+)
+
+func TestNodeSupportsProtocol(t *testing.T) {
+	node := NewNode("a", "192.0.2.1:443", LabelsNodeOption(map[string]string{LabelProtocols: "tcp, TLS"}))
+	for _, tc := range []struct {
+		protocol string
+		want     bool
+	}{
+		{"tcp", true},
+		{"tls", true},
+		{"udp", false},
+	} {
+		if got := node.SupportsProtocol(tc.protocol); got != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.protocol, got, tc.want)
+		}
+	}
+
+	// the node without the label supports any protocol.
+	if !NewNode("b", "192.0.2.2:443").SupportsProtocol("udp") {
+		t.Fatal("the node without the label should support any protocol")
+	}
+}
+
+func TestProtocolFilter(t *testing.T) {
+	tcp := NewNode("tcp", "192.0.2.1:443", LabelsNodeOption(map[string]string{LabelProtocols: "tcp,tls"}))
+	udp := NewNode("udp", "192.0.2.2:443", LabelsNodeOption(map[string]string{LabelProtocols: "udp"}))
+	anyp := NewNode("any", "192.0.2.3:443")
+	sel := selector.TracedSelector[*Node](
+		selector.WeightedStrategy[*Node](selector.RandStrategyOption(selector.NewRand(1))),
+		[]selector.Filter[*Node]{selector.ProtocolFilter[*Node]()})
+
+	// a UDP request never selects a TCP-only node.
+	ctx := ctxvalue.ContextWithProtocol(context.Background(), "udp")
+	for i := 0; i < 100; i++ {
+		if v := sel.Select(ctx, tcp, udp, anyp); v == tcp {
+			t.Fatalf("selection %d: the UDP request selected the TCP-only node", i)
+		}
+	}
+	ctx = ctxvalue.ContextWithProtocol(context.Background(), "tls")
+	for i := 0; i < 100; i++ {
+		if v := sel.Select(ctx, tcp, udp); v != tcp {
+			t.Fatalf("selection %d: the TLS request selected %v", i, v.Name)
+		}
+	}
+
+	// the request without protocol selects from all.
+	seen := make(map[*Node]bool)
+	for i := 0; i < 100; i++ {
+		seen[sel.Select(context.Background(), tcp, udp)] = true
+	}
+	if len(seen) != 2 {
+		t.Fatalf("expected both nodes selected, got %d", len(seen))
+	}
+
+	// no compatible node.
+	ctx = ctxvalue.ContextWithProtocol(context.Background(), "udp")
+	if v := sel.Select(ctx, tcp); v != nil {
+		t.Fatalf("expected no selection, got %s", v.Name)
+	}
+	if _, err := Do(ctx, sel, []*Node{tcp}, func(ctx context.Context, node *Node) error {
+		t.Fatalf("the request is sent to the incompatible node %s", node.Name)
+		return nil
+	}); err != ErrNoNode {
+		t.Fatalf("expected ErrNoNode, got %v", err)
+	}
+}
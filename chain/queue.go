@@ -0,0 +1,143 @@
+package chain
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNodeBusy     = errors.New("node: too many connections")
+	ErrQueueFull    = errors.New("node: queue is full")
+	ErrQueueTimeout = errors.New("node: queue wait timeout")
+)
+
+// QueueNodeSettings configures queueing the requests to a node which reaches MaxConns,
+// instead of falling back to other nodes immediately.
+type QueueNodeSettings struct {
+	// Size is the maximum number of queued requests, the requests exceeding it fail with ErrQueueFull.
+	Size int
+	// MaxWait is the maximum time a request waits in the queue, 0 means waiting until the context is done.
+	MaxWait time.Duration
+}
+
+type connQueue struct {
+	waiters *list.List
+	mu      sync.Mutex
+}
+
+// Acquire takes a connection slot of the node, the returned release function must be called when the connection is done.
+// If the node reaches MaxConns, the request waits in a FIFO queue if QueueNodeSettings is set,
+// otherwise ErrNodeBusy is returned. ErrQueueFull and ErrQueueTimeout mean the caller should select another node.
+// If the node is in a group, the slot is also taken from the group, ErrGroupBusy is returned if the group is full.
+func (node *Node) Acquire(ctx context.Context) (release func(), err error) {
+	g := node.options.Group
+	if g == nil {
+		return node.acquire(ctx)
+	}
+
+	if !g.acquire() {
+		return nil, ErrGroupBusy
+	}
+	nodeRelease, err := node.acquire(ctx)
+	if err != nil {
+		g.release()
+		return nil, err
+	}
+	return func() {
+		nodeRelease()
+		g.release()
+	}, nil
+}
+
+func (node *Node) acquire(ctx context.Context) (release func(), err error) {
+	maxConns := int64(node.options.MaxConns)
+	if maxConns <= 0 || node.connQueue == nil {
+		node.IncActiveConns()
+		return node.release, nil
+	}
+
+	q := node.connQueue
+	q.mu.Lock()
+	if q.waiters.Len() == 0 && node.ActiveConns() < maxConns {
+		node.IncActiveConns()
+		q.mu.Unlock()
+		return node.release, nil
+	}
+
+	settings := node.options.Queue
+	if settings == nil {
+		q.mu.Unlock()
+		return nil, ErrNodeBusy
+	}
+	if q.waiters.Len() >= settings.Size {
+		q.mu.Unlock()
+		return nil, ErrQueueFull
+	}
+
+	// the slot is handed over by release through the channel.
+	ready := make(chan struct{})
+	elem := q.waiters.PushBack(ready)
+	q.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if settings.MaxWait > 0 {
+		t := time.NewTimer(settings.MaxWait)
+		defer t.Stop()
+		timeout = t.C
+	}
+
+	select {
+	case <-ready:
+		return node.release, nil
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-timeout:
+		err = ErrQueueTimeout
+	}
+
+	q.mu.Lock()
+	select {
+	case <-ready:
+		// the slot was handed over just now, give it back.
+		q.mu.Unlock()
+		node.release()
+		return nil, err
+	default:
+	}
+	q.waiters.Remove(elem)
+	q.mu.Unlock()
+	return nil, err
+}
+
+func (node *Node) release() {
+	q := node.connQueue
+	if node.options.MaxConns <= 0 || q == nil {
+		node.DecActiveConns()
+		return
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if front := q.waiters.Front(); front != nil {
+		// hand the slot to the first waiter, the active connections count is unchanged.
+		q.waiters.Remove(front)
+		close(front.Value.(chan struct{}))
+		return
+	}
+	node.DecActiveConns()
+}
+
+// QueueLen returns the number of the requests waiting in the node queue.
+func (node *Node) QueueLen() int {
+	q := node.connQueue
+	if q == nil {
+		return 0
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.waiters.Len()
+}
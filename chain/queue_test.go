@@ -0,0 +1,137 @@
+package chain
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// waitQueueLen waits until the node queue has n requests.
+func waitQueueLen(t *testing.T, node *Node, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for node.QueueLen() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d queued requests, got %d", n, node.QueueLen())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestNodeQueueOrder(t *testing.T) {
+	node := NewNode("a", "127.0.0.1:80", MaxConnsNodeOption(1),
+		QueueNodeOption(&QueueNodeSettings{Size: 3}))
+
+	release, err := node.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	order := make(chan int, 3)
+	releases := make(chan func(), 3)
+	for i := 0; i < 3; i++ {
+		go func(i int) {
+			release, err := node.Acquire(context.Background())
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			order <- i
+			releases <- release
+		}(i)
+		// enqueue in order.
+		waitQueueLen(t, node, i+1)
+	}
+
+	// an over-queue request fails fast so the caller falls back to another node.
+	if _, err := node.Acquire(context.Background()); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("expected ErrQueueFull, got %v", err)
+	}
+
+	// the queue drains in FIFO order as the slots are released.
+	release()
+	for i := 0; i < 3; i++ {
+		select {
+		case got := <-order:
+			if got != i {
+				t.Fatalf("request %d is dequeued at position %d", got, i)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("request %d is not dequeued", i)
+		}
+		if n := node.ActiveConns(); n != 1 {
+			t.Fatalf("the slot should be handed over, active conns %d", n)
+		}
+		(<-releases)()
+	}
+	if n := node.ActiveConns(); n != 0 {
+		t.Fatalf("expected no active conns, got %d", n)
+	}
+}
+
+func TestNodeQueueTimeout(t *testing.T) {
+	node := NewNode("a", "127.0.0.1:80", MaxConnsNodeOption(1),
+		QueueNodeOption(&QueueNodeSettings{Size: 1, MaxWait: 20 * time.Millisecond}))
+
+	release, err := node.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	if _, err := node.Acquire(context.Background()); !errors.Is(err, ErrQueueTimeout) {
+		t.Fatalf("expected ErrQueueTimeout, got %v", err)
+	}
+	if node.QueueLen() != 0 {
+		t.Fatal("the timed out request should leave the queue")
+	}
+}
+
+func TestNodeQueueContext(t *testing.T) {
+	node := NewNode("a", "127.0.0.1:80", MaxConnsNodeOption(1),
+		QueueNodeOption(&QueueNodeSettings{Size: 1}))
+
+	release, err := node.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	if _, err := node.Acquire(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if node.QueueLen() != 0 {
+		t.Fatal("the cancelled request should leave the queue")
+	}
+
+	release()
+	if n := node.ActiveConns(); n != 0 {
+		t.Fatalf("expected no active conns, got %d", n)
+	}
+}
+
+func TestNodeQueueDisabled(t *testing.T) {
+	node := NewNode("a", "127.0.0.1:80", MaxConnsNodeOption(1))
+
+	release, err := node.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := node.Acquire(context.Background()); !errors.Is(err, ErrNodeBusy) {
+		t.Fatalf("expected ErrNodeBusy without queue, got %v", err)
+	}
+	release()
+
+	// unlimited
+	node = NewNode("b", "127.0.0.1:80")
+	for i := 0; i < 10; i++ {
+		if _, err := node.Acquire(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := node.ActiveConns(); n != 10 {
+		t.Fatalf("expected 10 active conns, got %d", n)
+	}
+}
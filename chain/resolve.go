@@ -0,0 +1,85 @@
+package chain
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+// PreResolveSettings configures the resolution of the node address in advance,
+// the resolved IPs are cached on the node so the dialer can connect by IP.
+type PreResolveSettings struct {
+	// TTL is the duration the resolved IPs are valid for.
+	TTL time.Duration
+	// Grace is the duration after TTL the last good IPs are still used if the re-resolution fails.
+	Grace time.Duration
+}
+
+type addrCache struct {
+	ips     []net.IP
+	expires time.Time
+	mu      sync.Mutex
+}
+
+// ResolveAddrs returns the node addresses with the host part resolved to IPs.
+// The result is cached for the TTL in the PreResolve settings,
+// if the node address is an IP or pre-resolving is disabled, the address is returned as is.
+func (node *Node) ResolveAddrs(ctx context.Context) ([]string, error) {
+	settings := node.options.PreResolve
+	if settings == nil || settings.TTL <= 0 || node.addrCache == nil {
+		return []string{node.Addr}, nil
+	}
+
+	host, port, err := net.SplitHostPort(node.Addr)
+	if err != nil {
+		return nil, err
+	}
+	if net.ParseIP(host) != nil {
+		return []string{node.Addr}, nil
+	}
+
+	ips, err := node.addrCache.resolve(ctx, host, settings, node.lookupIP)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		addrs = append(addrs, net.JoinHostPort(ip.String(), port))
+	}
+	return addrs, nil
+}
+
+func (node *Node) lookupIP(ctx context.Context, host string) ([]net.IP, error) {
+	if r := node.options.Resolver; r != nil {
+		return r.Resolve(ctx, "ip", host)
+	}
+	return net.DefaultResolver.LookupIP(ctx, "ip", host)
+}
+
+func (c *addrCache) resolve(ctx context.Context, host string, settings *PreResolveSettings, lookup func(context.Context, string) ([]net.IP, error)) ([]net.IP, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if len(c.ips) > 0 && now.Before(c.expires) {
+		return c.ips, nil
+	}
+
+	ips, err := lookup(ctx, host)
+	if err == nil && len(ips) == 0 {
+		err = &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	if err != nil {
+		// keep using the last good IPs within the grace window.
+		if len(c.ips) > 0 && now.Before(c.expires.Add(settings.Grace)) {
+			return c.ips, nil
+		}
+		return nil, err
+	}
+
+	c.ips = ips
+	c.expires = now.Add(settings.TTL)
+	return ips, nil
+}
@@ -0,0 +1,111 @@
+package chain
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-gost/core/resolver"
+)
+
+// countResolver is a resolver.Resolver returning ips or err and counting the lookups.
+type countResolver struct {
+	ips   []net.IP
+	err   error
+	count atomic.Int64
+}
+
+func (r *countResolver) Resolve(ctx context.Context, network, host string, opts ...resolver.Option) ([]net.IP, error) {
+	r.count.Add(1)
+	return r.ips, r.err
+}
+
+// lookup is the lookup function of addrCache.resolve.
+func (r *countResolver) lookup(ctx context.Context, host string) ([]net.IP, error) {
+	return r.Resolve(ctx, "ip", host)
+}
+
+func TestNodeResolveAddrsCached(t *testing.T) {
+	r := &countResolver{ips: []net.IP{net.ParseIP("192.0.2.1"), net.ParseIP("192.0.2.2")}}
+	node := NewNode("a", "example.com:443",
+		ResoloverNodeOption(r), PreResolveNodeOption(&PreResolveSettings{TTL: time.Hour}))
+
+	for i := 0; i < 10; i++ {
+		addrs, err := node.ResolveAddrs(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(addrs) != 2 || addrs[0] != "192.0.2.1:443" || addrs[1] != "192.0.2.2:443" {
+			t.Fatalf("unexpected addrs %v", addrs)
+		}
+	}
+	if n := r.count.Load(); n != 1 {
+		t.Fatalf("the IPs should be resolved once, got %d lookups", n)
+	}
+}
+
+func TestNodeResolveAddrsAsIs(t *testing.T) {
+	r := &countResolver{ips: []net.IP{net.ParseIP("192.0.2.1")}}
+	for _, node := range []*Node{
+		NewNode("ip", "198.51.100.1:443", ResoloverNodeOption(r), PreResolveNodeOption(&PreResolveSettings{TTL: time.Hour})),
+		NewNode("disabled", "example.com:443", ResoloverNodeOption(r)),
+	} {
+		addrs, err := node.ResolveAddrs(context.Background())
+		if err != nil || len(addrs) != 1 || addrs[0] != node.Addr {
+			t.Fatalf("%s: expected the address as is, got %v %v", node.Name, addrs, err)
+		}
+	}
+	if n := r.count.Load(); n != 0 {
+		t.Fatalf("expected no lookup, got %d", n)
+	}
+}
+
+func TestAddrCacheExpiry(t *testing.T) {
+	r := &countResolver{ips: []net.IP{net.ParseIP("192.0.2.1")}}
+	settings := &PreResolveSettings{TTL: 20 * time.Millisecond, Grace: time.Hour}
+	var c addrCache
+
+	if _, err := c.resolve(context.Background(), "example.com", settings, r.lookup); err != nil {
+		t.Fatal(err)
+	}
+	c.resolve(context.Background(), "example.com", settings, r.lookup)
+	if n := r.count.Load(); n != 1 {
+		t.Fatalf("expected 1 lookup before expiry, got %d", n)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	r.ips = []net.IP{net.ParseIP("192.0.2.2")}
+	ips, err := c.resolve(context.Background(), "example.com", settings, r.lookup)
+	if err != nil || len(ips) != 1 || !ips[0].Equal(net.ParseIP("192.0.2.2")) {
+		t.Fatalf("expected re-resolution after expiry, got %v %v", ips, err)
+	}
+	if n := r.count.Load(); n != 2 {
+		t.Fatalf("expected 2 lookups after expiry, got %d", n)
+	}
+}
+
+func TestAddrCacheGrace(t *testing.T) {
+	r := &countResolver{ips: []net.IP{net.ParseIP("192.0.2.1")}}
+	settings := &PreResolveSettings{TTL: 10 * time.Millisecond, Grace: 50 * time.Millisecond}
+	var c addrCache
+
+	if _, err := c.resolve(context.Background(), "example.com", settings, r.lookup); err != nil {
+		t.Fatal(err)
+	}
+
+	// the last good IPs are kept within the grace window.
+	r.err = errors.New("lookup failed")
+	time.Sleep(20 * time.Millisecond)
+	ips, err := c.resolve(context.Background(), "example.com", settings, r.lookup)
+	if err != nil || len(ips) != 1 || !ips[0].Equal(net.ParseIP("192.0.2.1")) {
+		t.Fatalf("expected the last good IPs, got %v %v", ips, err)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if _, err := c.resolve(context.Background(), "example.com", settings, r.lookup); err == nil {
+		t.Fatal("expected error after the grace window")
+	}
+}
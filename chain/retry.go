@@ -0,0 +1,152 @@
+package chain
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/go-gost/core/selector"
+)
+
+var (
+	ErrNoNode = errors.New("node: no node available")
+)
+
+// RequestFunc sends a request through node, it should return when ctx is done.
+type RequestFunc func(ctx context.Context, node *Node) error
+
+type RetryOptions struct {
+	// Attempts is the maximum number of distinct nodes tried for a request, including the hedged ones, default is 1.
+	Attempts int
+	// HedgeDelay issues a hedged request to another node if the first one has not completed within the delay.
+	// It takes effect only for the idempotent requests.
+	HedgeDelay time.Duration
+	// Idempotent asserts that the request can be sent more than once without side effects.
+	// It must be set by the caller, the non-idempotent requests are never hedged.
+	Idempotent bool
+	// Retryable reports whether a failed request can be retried on another node.
+	// If it is nil, the idempotent requests are retried on any error and the non-idempotent ones are not retried.
+	Retryable func(err error) bool
+}
+
+type RetryOption func(opts *RetryOptions)
+
+func AttemptsRetryOption(n int) RetryOption {
+	return func(opts *RetryOptions) {
+		opts.Attempts = n
+	}
+}
+
+func HedgeRetryOption(delay time.Duration) RetryOption {
+	return func(opts *RetryOptions) {
+		opts.HedgeDelay = delay
+	}
+}
+
+func IdempotentRetryOption(idempotent bool) RetryOption {
+	return func(opts *RetryOptions) {
+		opts.Idempotent = idempotent
+	}
+}
+
+func RetryableRetryOption(fn func(err error) bool) RetryOption {
+	return func(opts *RetryOptions) {
+		opts.Retryable = fn
+	}
+}
+
+type requestResult struct {
+	node *Node
+	err  error
+}
+
+// Do sends the request fn through a node selected by sel from nodes, and retries on the failure
+// or hedges on the slow response across the distinct nodes within the attempts budget.
+// The first successful attempt wins and the others are cancelled, the winning node is returned.
+// If sel is nil, the nodes are tried in order.
+func Do(ctx context.Context, sel selector.Selector[*Node], nodes []*Node, fn RequestFunc, opts ...RetryOption) (*Node, error) {
+	var options RetryOptions
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+	attempts := options.Attempts
+	if attempts <= 0 {
+		attempts = 1
+	}
+	retryable := options.Retryable
+	if retryable == nil {
+		retryable = func(error) bool { return options.Idempotent }
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	tried := make(map[*Node]struct{})
+	// the buffer never blocks the attempts sending the results after return.
+	results := make(chan requestResult, attempts)
+	pending := 0
+	start := func() bool {
+		if len(tried) >= attempts {
+			return false
+		}
+		node := nextNode(ctx, sel, nodes, tried)
+		if node == nil {
+			return false
+		}
+		tried[node] = struct{}{}
+		pending++
+		go func() {
+			results <- requestResult{node: node, err: fn(ctx, node)}
+		}()
+		return true
+	}
+
+	if !start() {
+		return nil, ErrNoNode
+	}
+
+	var hedge <-chan time.Time
+	if options.Idempotent && options.HedgeDelay > 0 && attempts > 1 {
+		t := time.NewTimer(options.HedgeDelay)
+		defer t.Stop()
+		hedge = t.C
+	}
+
+	var err error
+	for pending > 0 {
+		select {
+		case res := <-results:
+			pending--
+			if res.err == nil {
+				return res.node, nil
+			}
+			err = res.err
+			if ctx.Err() == nil && retryable(res.err) {
+				start()
+			}
+		case <-hedge:
+			start()
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return nil, err
+}
+
+func nextNode(ctx context.Context, sel selector.Selector[*Node], nodes []*Node, tried map[*Node]struct{}) *Node {
+	candidates := make([]*Node, 0, len(nodes))
+	for _, node := range nodes {
+		if _, ok := tried[node]; !ok && node != nil {
+			candidates = append(candidates, node)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	if sel == nil {
+		return candidates[0]
+	}
+	return sel.Select(ctx, candidates...)
+}
@@ -0,0 +1,161 @@
+package chain
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// requestLog records the nodes requested in order.
+type requestLog struct {
+	mu    sync.Mutex
+	names []string
+}
+
+func (l *requestLog) add(node *Node) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.names = append(l.names, node.Name)
+}
+
+func (l *requestLog) get() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.names...)
+}
+
+// lastSelector selects the last candidate.
+type lastSelector struct{}
+
+func (lastSelector) Select(ctx context.Context, vs ...*Node) *Node {
+	if len(vs) == 0 {
+		return nil
+	}
+	return vs[len(vs)-1]
+}
+
+func retryNodes() []*Node {
+	return []*Node{
+		NewNode("a", "192.0.2.1:80"),
+		NewNode("b", "192.0.2.2:80"),
+		NewNode("c", "192.0.2.3:80"),
+	}
+}
+
+func TestDoRetry(t *testing.T) {
+	nodes := retryNodes()
+	var log requestLog
+	errFailed := errors.New("failed")
+
+	// the failed requests are retried on the distinct nodes.
+	node, err := Do(context.Background(), nil, nodes, func(ctx context.Context, node *Node) error {
+		log.add(node)
+		if node.Name != "c" {
+			return errFailed
+		}
+		return nil
+	}, AttemptsRetryOption(3), IdempotentRetryOption(true))
+	if err != nil || node != nodes[2] {
+		t.Fatalf("unexpected result %v %v", node, err)
+	}
+	if names := log.get(); len(names) != 3 || names[0] != "a" || names[1] != "b" || names[2] != "c" {
+		t.Fatalf("unexpected requests %v", names)
+	}
+
+	// the attempts budget is respected.
+	log = requestLog{}
+	_, err = Do(context.Background(), lastSelector{}, nodes, func(ctx context.Context, node *Node) error {
+		log.add(node)
+		return errFailed
+	}, AttemptsRetryOption(2), IdempotentRetryOption(true))
+	if err != errFailed {
+		t.Fatalf("expected the last error, got %v", err)
+	}
+	if names := log.get(); len(names) != 2 || names[0] != "c" || names[1] != "b" {
+		t.Fatalf("unexpected requests by the selector %v", names)
+	}
+}
+
+func TestDoRetryable(t *testing.T) {
+	nodes := retryNodes()
+	errFatal := errors.New("fatal")
+	var log requestLog
+	fn := func(ctx context.Context, node *Node) error {
+		log.add(node)
+		return errFatal
+	}
+
+	// the non-idempotent requests are not retried by default.
+	if _, err := Do(context.Background(), nil, nodes, fn, AttemptsRetryOption(3)); err != errFatal || len(log.get()) != 1 {
+		t.Fatalf("unexpected result %v, requests %v", err, log.get())
+	}
+
+	// the caller decides which errors are retryable.
+	log = requestLog{}
+	retryable := RetryableRetryOption(func(err error) bool { return err != errFatal })
+	if _, err := Do(context.Background(), nil, nodes, fn, AttemptsRetryOption(3), IdempotentRetryOption(true), retryable); err != errFatal || len(log.get()) != 1 {
+		t.Fatalf("unexpected result %v, requests %v", err, log.get())
+	}
+
+	if _, err := Do(context.Background(), nil, nil, fn); err != ErrNoNode {
+		t.Fatalf("expected ErrNoNode, got %v", err)
+	}
+}
+
+// slowRequest blocks the request on node a until ctx is done, and answers on the others at once.
+func slowRequest(log *requestLog, cancelled chan<- string) RequestFunc {
+	return func(ctx context.Context, node *Node) error {
+		log.add(node)
+		if node.Name == "a" {
+			<-ctx.Done()
+			cancelled <- node.Name
+			return ctx.Err()
+		}
+		return nil
+	}
+}
+
+func TestDoHedge(t *testing.T) {
+	nodes := retryNodes()
+	var log requestLog
+	cancelled := make(chan string, 1)
+
+	start := time.Now()
+	node, err := Do(context.Background(), nil, nodes, slowRequest(&log, cancelled),
+		AttemptsRetryOption(2), HedgeRetryOption(20*time.Millisecond), IdempotentRetryOption(true))
+	if err != nil || node != nodes[1] {
+		t.Fatalf("expected the hedged node to win, got %v %v", node, err)
+	}
+	if d := time.Since(start); d < 20*time.Millisecond {
+		t.Fatalf("the hedge is fired before the delay, %s", d)
+	}
+	if names := log.get(); len(names) != 2 {
+		t.Fatalf("unexpected requests %v", names)
+	}
+
+	// the slow attempt is cancelled once the hedged one wins.
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("the slow attempt is not cancelled")
+	}
+}
+
+func TestDoNoHedgeNonIdempotent(t *testing.T) {
+	nodes := retryNodes()
+	var log requestLog
+	cancelled := make(chan string, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err := Do(ctx, nil, nodes, slowRequest(&log, cancelled),
+		AttemptsRetryOption(3), HedgeRetryOption(10*time.Millisecond))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the deadline error, got %v", err)
+	}
+	if names := log.get(); len(names) != 1 || names[0] != "a" {
+		t.Fatalf("the non-idempotent request should not be hedged, got %v", names)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"net"
 	"time"
 
+	"github.com/go-gost/core/common/backoff"
 	"github.com/go-gost/core/hosts"
 	"github.com/go-gost/core/logger"
 	"github.com/go-gost/core/recorder"
@@ -16,7 +17,9 @@ type SockOpts struct {
 }
 
 type RouterOptions struct {
-	Retries    int
+	Retries int
+	// Backoff is the interval between the retries, nil means retrying immediately.
+	Backoff    backoff.Backoff
 	Timeout    time.Duration
 	IfceName   string
 	Netns      string
@@ -60,6 +63,13 @@ func RetriesRouterOption(retries int) RouterOption {
 	}
 }
 
+// BackoffRouterOption sets the backoff between the retries, see backoff.Retry.
+func BackoffRouterOption(b backoff.Backoff) RouterOption {
+	return func(o *RouterOptions) {
+		o.Backoff = b
+	}
+}
+
 func ChainRouterOption(chain Chainer) RouterOption {
 	return func(o *RouterOptions) {
 		o.Chain = chain
@@ -0,0 +1,31 @@
+package chain
+
+import (
+	"github.com/go-gost/core/routing"
+)
+
+// ScopeNodes returns the nodes in the scope of the first matcher matching req,
+// the selector then runs over the returned subset.
+// If no matcher matches or the matched one has no scope, all the nodes are returned.
+func ScopeNodes(req *routing.Request, matchers []routing.Matcher, nodes []*Node) []*Node {
+	for _, m := range matchers {
+		if m == nil || !m.Match(req) {
+			continue
+		}
+
+		scoper, ok := m.(routing.Scoper)
+		if !ok {
+			return nodes
+		}
+		sel := scoper.Scope()
+
+		var scoped []*Node
+		for _, node := range nodes {
+			if node != nil && sel.Matches(node.options.Labels) {
+				scoped = append(scoped, node)
+			}
+		}
+		return scoped
+	}
+	return nodes
+}
@@ -0,0 +1,54 @@
+package chain
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/go-gost/core/routing"
+	"github.com/go-gost/core/selector"
+)
+
+// hostMatcher is a routing.Matcher matching the request host.
+type hostMatcher string
+
+func (m hostMatcher) Match(req *routing.Request) bool {
+	return req.Host == string(m)
+}
+
+func TestScopeNodes(t *testing.T) {
+	var nodes []*Node
+	for _, pool := range []string{"a", "b"} {
+		for i := 0; i < 3; i++ {
+			nodes = append(nodes, NewNode(fmt.Sprintf("%s%d", pool, i), "127.0.0.1:80",
+				LabelsNodeOption(map[string]string{"pool": pool})))
+		}
+	}
+	matchers := []routing.Matcher{
+		routing.ScopedMatcher(hostMatcher("a.example.com"), routing.LabelSelector{"pool": "a"}),
+		routing.ScopedMatcher(hostMatcher("b.example.com"), routing.LabelSelector{"pool": "b"}),
+	}
+	strategy := selector.WeightedStrategy[*Node](selector.RandStrategyOption(selector.NewRand(1)))
+
+	for host, pool := range map[string]string{"a.example.com": "a", "b.example.com": "b"} {
+		scoped := ScopeNodes(&routing.Request{Host: host}, matchers, nodes)
+		if len(scoped) != 3 {
+			t.Fatalf("%s: expected 3 nodes in pool %s, got %d", host, pool, len(scoped))
+		}
+		for i := 0; i < 100; i++ {
+			node := strategy.Apply(context.Background(), scoped...)
+			if node.Labels()["pool"] != pool {
+				t.Fatalf("%s: selected node %s out of pool %s", host, node.Name, pool)
+			}
+		}
+	}
+
+	// all the nodes without matched scope.
+	if scoped := ScopeNodes(&routing.Request{Host: "c.example.com"}, matchers, nodes); len(scoped) != len(nodes) {
+		t.Fatalf("expected all the nodes, got %d", len(scoped))
+	}
+	if scoped := ScopeNodes(&routing.Request{Host: "c.example.com"},
+		[]routing.Matcher{hostMatcher("c.example.com")}, nodes); len(scoped) != len(nodes) {
+		t.Fatalf("expected all the nodes for the matcher without scope, got %d", len(scoped))
+	}
+}
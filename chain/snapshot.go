@@ -0,0 +1,220 @@
+package chain
+
+import (
+	"slices"
+	"time"
+
+	"github.com/go-gost/core/selector"
+)
+
+// NodeSnapshot is a point-in-time view of the node state for debugging.
+type NodeSnapshot struct {
+	Name        string        `json:"name"`
+	Addr        string        `json:"addr"`
+	Alive       bool          `json:"alive"`
+	Draining    bool          `json:"draining"`
+	FailCount   int64         `json:"failCount"`
+	FailTime    time.Time     `json:"failTime,omitempty"`
+	ActiveConns int64         `json:"activeConns"`
+	MaxConns    int           `json:"maxConns,omitempty"`
+	Inflight    int64         `json:"inflightBytes"`
+	Latency     time.Duration `json:"latency"`
+	Weight      int           `json:"weight"`
+	Phases      PhaseTimings  `json:"phases"`
+	// Circuit is the circuit breaker state if the node has one.
+	Circuit *selector.CircuitStats `json:"circuit,omitempty"`
+}
+
+// SnapshotOptions is the liveness criteria of the node snapshot,
+// they should be the same as the selector.FailFilter of the node selection.
+type SnapshotOptions struct {
+	MaxFails    int
+	FailTimeout time.Duration
+}
+
+type SnapshotOption func(opts *SnapshotOptions)
+
+// FailSnapshotOption sets the liveness criteria of the snapshot to that of selector.FailFilter(maxFails, failTimeout).
+func FailSnapshotOption(maxFails int, failTimeout time.Duration) SnapshotOption {
+	return func(opts *SnapshotOptions) {
+		opts.MaxFails = maxFails
+		opts.FailTimeout = failTimeout
+	}
+}
+
+// Snapshot returns the current state of the node.
+// The counters and the marker state are read together, so they are consistent with each other.
+// The node is Alive by the criteria of selector.FailFilter, see FailSnapshotOption.
+func (node *Node) Snapshot(opts ...SnapshotOption) NodeSnapshot {
+	var options SnapshotOptions
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+
+	node.stats.mu.Lock()
+	defer node.stats.mu.Unlock()
+
+	now := time.Now()
+	ns := NodeSnapshot{
+		Name:        node.Name,
+		Addr:        node.Addr,
+		Draining:    node.IsDraining(),
+		ActiveConns: node.ActiveConns(),
+		MaxConns:    node.options.MaxConns,
+		Inflight:    node.InflightBytes(),
+		Latency:     node.Latency(),
+		Weight:      node.Weight(),
+		Phases:      node.PhaseTimings(),
+		Alive:       selector.IsAlive(node.marker, options.MaxFails, options.FailTimeout, now),
+	}
+	if node.marker != nil {
+		ns.FailCount = node.marker.Count()
+		if ns.FailCount > 0 {
+			ns.FailTime = node.marker.Time()
+		}
+	}
+	if stats, ok := node.CircuitStats(); ok {
+		ns.Circuit = &stats
+	}
+	return ns
+}
+
+// lockedMarker updates the node marker under the read lock of the node stats, see nodeStats.mu.
+type lockedMarker struct {
+	selector.Marker
+	stats *nodeStats
+}
+
+func (m *lockedMarker) Mark() {
+	m.stats.mu.RLock()
+	defer m.stats.mu.RUnlock()
+	m.Marker.Mark()
+}
+
+func (m *lockedMarker) Reset() {
+	m.stats.mu.RLock()
+	defer m.stats.mu.RUnlock()
+	m.Marker.Reset()
+}
+
+func (m *lockedMarker) Restore(count int64, t time.Time) {
+	if r, ok := m.Marker.(selector.Restorable); ok {
+		m.stats.mu.RLock()
+		defer m.stats.mu.RUnlock()
+		r.Restore(count, t)
+	}
+}
+
+// CircuitStats returns the circuit breaker state of the node, ok is false if the node has no circuit breaker.
+func (node *Node) CircuitStats() (stats selector.CircuitStats, ok bool) {
+	m := node.marker
+	for {
+		switch v := m.(type) {
+		case selector.Breaker:
+			return v.CircuitStats(), true
+		case *eventMarker:
+			m = v.Marker
+		case *groupMarker:
+			m = v.Marker
+		case *lockedMarker:
+			m = v.Marker
+		default:
+			return
+		}
+	}
+}
+
+// SuccessRate implements selector.SuccessRater interface, the rate is from the rolling window of
+// the error rate marker (see selector.NewErrorRateMarker), ok is false if the node has no such marker or too few outcomes.
+func (node *Node) SuccessRate() (rate float64, ok bool) {
+	m := node.marker
+	for {
+		switch v := m.(type) {
+		case selector.SuccessRater:
+			return v.SuccessRate()
+		case *eventMarker:
+			m = v.Marker
+		case *groupMarker:
+			m = v.Marker
+		case *lockedMarker:
+			m = v.Marker
+		default:
+			return
+		}
+	}
+}
+
+// Snapshot is a point-in-time view of the selector state.
+type Snapshot struct {
+	Time  time.Time      `json:"time"`
+	Nodes []NodeSnapshot `json:"nodes"`
+}
+
+// Snapshotter is implemented by the node set or selector which can export its state,
+// the nodes in the snapshot should be read from a consistent node list.
+type Snapshotter interface {
+	Snapshot() Snapshot
+}
+
+// SnapshotNodes returns a snapshot of the nodes, opts are applied to each node snapshot.
+func SnapshotNodes(nodes []*Node, opts ...SnapshotOption) Snapshot {
+	s := Snapshot{
+		Time:  time.Now(),
+		Nodes: make([]NodeSnapshot, 0, len(nodes)),
+	}
+	for _, node := range nodes {
+		if node != nil {
+			s.Nodes = append(s.Nodes, node.Snapshot(opts...))
+		}
+	}
+	return s
+}
+
+// Utilization is the aggregate load of a node set, it can be used as an autoscaling signal.
+type Utilization struct {
+	Nodes int `json:"nodes"`
+	// AvgConnRatio is the average ratio of active connections to MaxConns,
+	// the nodes without MaxConns are not counted.
+	AvgConnRatio float64 `json:"avgConnRatio"`
+	// MaxConnRatio is the maximum ratio of active connections to MaxConns.
+	MaxConnRatio float64 `json:"maxConnRatio"`
+	// P95Latency is the 95th percentile of the node latencies.
+	P95Latency time.Duration `json:"p95Latency"`
+}
+
+// Utilization computes the aggregate utilization from the snapshot,
+// so the figures are consistent with each other.
+func (s *Snapshot) Utilization() Utilization {
+	u := Utilization{
+		Nodes: len(s.Nodes),
+	}
+
+	var n int
+	var latencies []time.Duration
+	for i := range s.Nodes {
+		ns := &s.Nodes[i]
+		if ns.MaxConns > 0 {
+			ratio := float64(ns.ActiveConns) / float64(ns.MaxConns)
+			u.AvgConnRatio += ratio
+			u.MaxConnRatio = max(u.MaxConnRatio, ratio)
+			n++
+		}
+		if ns.Latency > 0 {
+			latencies = append(latencies, ns.Latency)
+		}
+	}
+	if n > 0 {
+		u.AvgConnRatio /= float64(n)
+	}
+
+	if len(latencies) > 0 {
+		slices.Sort(latencies)
+		// nearest-rank percentile
+		idx := (len(latencies)*95 + 99) / 100
+		u.P95Latency = latencies[idx-1]
+	}
+
+	return u
+}
@@ -0,0 +1,183 @@
+package chain
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-gost/core/selector"
+)
+
+func TestNodeSnapshot(t *testing.T) {
+	node := NewNode("a", "127.0.0.1:80")
+	node.IncActiveConns()
+	node.AddInflightBytes(100)
+	node.SetLatency(time.Millisecond)
+
+	ns := node.Snapshot()
+	if ns.Name != "a" || ns.Addr != "127.0.0.1:80" || !ns.Alive || ns.Draining {
+		t.Fatalf("unexpected snapshot %+v", ns)
+	}
+	if ns.ActiveConns != 1 || ns.Inflight != 100 || ns.Latency != time.Millisecond {
+		t.Fatalf("unexpected counters %+v", ns)
+	}
+
+	node.Marker().Mark()
+	node.Drain(true)
+	ns = node.Snapshot()
+	if ns.Alive || !ns.Draining || ns.FailCount != 1 || ns.FailTime.IsZero() {
+		t.Fatalf("unexpected snapshot of the marked node %+v", ns)
+	}
+
+	node.Marker().Reset()
+	if ns := node.Snapshot(); !ns.Alive || ns.FailCount != 0 || !ns.FailTime.IsZero() {
+		t.Fatalf("unexpected snapshot of the recovered node %+v", ns)
+	}
+}
+
+func TestNodeSnapshotAlive(t *testing.T) {
+	node := NewNode("a", "127.0.0.1:80")
+	node.Marker().Mark()
+
+	// the liveness follows the fail filter.
+	filter := selector.FailFilter[*Node](2, 0)
+	if alive := len(filter.Filter(context.Background(), node)) == 1; !alive {
+		t.Fatal("node should pass the filter with one failure")
+	}
+	if ns := node.Snapshot(FailSnapshotOption(2, 0)); !ns.Alive {
+		t.Fatalf("node should be alive with maxFails 2, %+v", ns)
+	}
+
+	node.Marker().Mark()
+	if ns := node.Snapshot(FailSnapshotOption(2, 0)); ns.Alive {
+		t.Fatalf("node should not be alive with maxFails 2, %+v", ns)
+	}
+
+	// the failures out of the fail timeout are not counted.
+	time.Sleep(10 * time.Millisecond)
+	if ns := node.Snapshot(FailSnapshotOption(2, time.Millisecond)); !ns.Alive {
+		t.Fatalf("node should be alive after the fail timeout, %+v", ns)
+	}
+
+	s := SnapshotNodes([]*Node{node, nil}, FailSnapshotOption(3, 0))
+	if len(s.Nodes) != 1 || !s.Nodes[0].Alive {
+		t.Fatalf("unexpected snapshot %+v", s)
+	}
+}
+
+func TestNodeSnapshotConsistent(t *testing.T) {
+	node := NewNode("a", "127.0.0.1:80", EventsNodeOption(NewNodeEventBus()))
+
+	const n = 10000
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		// every connection is followed by a failure.
+		for i := 0; i < n; i++ {
+			node.IncActiveConns()
+			node.Marker().Mark()
+		}
+	}()
+
+	for {
+		ns := node.Snapshot()
+		if d := ns.ActiveConns - ns.FailCount; d < 0 || d > 1 {
+			t.Fatalf("torn snapshot: active conns %d, fail count %d", ns.ActiveConns, ns.FailCount)
+		}
+		if ns.FailCount == n {
+			break
+		}
+	}
+	wg.Wait()
+}
+
+func TestSnapshotUtilization(t *testing.T) {
+	var nodes []*Node
+	for i, load := range []struct {
+		maxConns, active int
+		latency          time.Duration
+	}{
+		{maxConns: 10, active: 5, latency: 10 * time.Millisecond},
+		{maxConns: 10, active: 10, latency: 20 * time.Millisecond},
+		{maxConns: 20, active: 0, latency: 30 * time.Millisecond},
+		// unlimited, not counted in the ratios.
+		{maxConns: 0, active: 100, latency: 400 * time.Millisecond},
+	} {
+		node := NewNode(fmt.Sprintf("node%d", i), "127.0.0.1:80", MaxConnsNodeOption(load.maxConns))
+		for j := 0; j < load.active; j++ {
+			node.IncActiveConns()
+		}
+		node.SetLatency(load.latency)
+		nodes = append(nodes, node)
+	}
+
+	s := SnapshotNodes(nodes)
+	u := s.Utilization()
+	if u.Nodes != 4 {
+		t.Fatalf("expected 4 nodes, got %d", u.Nodes)
+	}
+	if math.Abs(u.AvgConnRatio-0.5) > 1e-9 || u.MaxConnRatio != 1 {
+		t.Fatalf("unexpected conn ratios %+v", u)
+	}
+	if u.P95Latency != 400*time.Millisecond {
+		t.Fatalf("unexpected p95 latency %s", u.P95Latency)
+	}
+
+	// the figures come from the snapshot, not the live nodes.
+	nodes[0].IncActiveConns()
+	if got := s.Utilization(); got != u {
+		t.Fatalf("utilization changed with the live nodes: %+v", got)
+	}
+}
+
+func TestSnapshotUtilizationP95(t *testing.T) {
+	var nodes []*Node
+	for i := 1; i <= 100; i++ {
+		node := NewNode(fmt.Sprintf("node%d", i), "127.0.0.1:80")
+		node.SetLatency(time.Duration(i) * time.Millisecond)
+		nodes = append(nodes, node)
+	}
+	// no latency yet.
+	nodes = append(nodes, NewNode("new", "127.0.0.1:80"))
+
+	s := SnapshotNodes(nodes)
+	u := s.Utilization()
+	if u.P95Latency != 95*time.Millisecond || u.AvgConnRatio != 0 || u.MaxConnRatio != 0 {
+		t.Fatalf("unexpected utilization %+v", u)
+	}
+
+	empty := SnapshotNodes(nil)
+	if u := empty.Utilization(); u != (Utilization{}) {
+		t.Fatalf("unexpected utilization of no node %+v", u)
+	}
+}
+
+func TestNodeSuccessRate(t *testing.T) {
+	bus := NewNodeEventBus()
+	node := NewNode("a", "127.0.0.1:80",
+		MarkerNodeOption(selector.NewErrorRateMarker(selector.MinRequestsErrorRateOption(4))),
+		EventsNodeOption(bus))
+
+	if _, ok := node.SuccessRate(); ok {
+		t.Fatal("the success rate should not be known without outcomes")
+	}
+	// the outcomes are fed through the wrapped node marker.
+	for _, fail := range []bool{false, true, false, false} {
+		if fail {
+			node.Marker().Mark()
+		} else {
+			node.Marker().Reset()
+		}
+	}
+	if rate, ok := node.SuccessRate(); !ok || rate != 0.75 {
+		t.Fatalf("unexpected success rate %v %v", rate, ok)
+	}
+
+	if _, ok := NewNode("b", "127.0.0.1:81").SuccessRate(); ok {
+		t.Fatal("the node without the error rate marker has no success rate")
+	}
+}
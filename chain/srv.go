@@ -0,0 +1,28 @@
+package chain
+
+import (
+	"net"
+	"strconv"
+	"strings"
+)
+
+// NodesFromSRV builds the nodes from the SRV records, the node for each target is named "name-target:port".
+// The SRV priority is mapped to the failover tier (see TierNodeOption), and the SRV weight is mapped to the node priority,
+// which is the selection weight, so the lower priority targets are preferred and balanced by weight.
+// The node options opts are applied to every node before the SRV settings.
+func NodesFromSRV(name string, srvs []*net.SRV, opts ...NodeOption) []*Node {
+	nodes := make([]*Node, 0, len(srvs))
+	for _, srv := range srvs {
+		if srv == nil || srv.Target == "" || srv.Target == "." {
+			continue
+		}
+
+		addr := net.JoinHostPort(strings.TrimSuffix(srv.Target, "."), strconv.Itoa(int(srv.Port)))
+		nodeOpts := append(opts[:len(opts):len(opts)],
+			TierNodeOption(int(srv.Priority)),
+			PriorityNodeOption(int(srv.Weight)),
+		)
+		nodes = append(nodes, NewNode(name+"-"+addr, addr, nodeOpts...))
+	}
+	return nodes
+}
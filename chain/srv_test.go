@@ -0,0 +1,43 @@
+package chain
+
+import (
+	"net"
+	"testing"
+)
+
+func TestNodesFromSRV(t *testing.T) {
+	srvs := []*net.SRV{
+		{Target: "a.example.com.", Port: 8080, Priority: 10, Weight: 60},
+		{Target: "b.example.com.", Port: 8081, Priority: 10, Weight: 30},
+		{Target: ".", Port: 80},
+		nil,
+		{Target: "c.example.com", Port: 8080, Priority: 20, Weight: 0},
+	}
+	nodes := NodesFromSRV("svc", srvs, LabelsNodeOption(map[string]string{"zone": "a"}))
+
+	for i, want := range []struct {
+		name   string
+		addr   string
+		tier   int
+		weight int
+	}{
+		{"svc-a.example.com:8080", "a.example.com:8080", 10, 60},
+		{"svc-b.example.com:8081", "b.example.com:8081", 10, 30},
+		{"svc-c.example.com:8080", "c.example.com:8080", 20, 0},
+	} {
+		if i >= len(nodes) {
+			t.Fatalf("expected 3 nodes, got %d", len(nodes))
+		}
+		node := nodes[i]
+		if node.Name != want.name || node.Addr != want.addr || node.Tier() != want.tier || node.Options().Priority != want.weight {
+			t.Errorf("node %d: got %s %s tier %d priority %d, want %+v",
+				i, node.Name, node.Addr, node.Tier(), node.Options().Priority, want)
+		}
+		if node.Labels()["zone"] != "a" {
+			t.Errorf("node %d: the common options are not applied", i)
+		}
+	}
+	if len(nodes) != 3 {
+		t.Fatalf("expected 3 nodes, got %d", len(nodes))
+	}
+}
@@ -0,0 +1,88 @@
+package chain
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+
+	"github.com/go-gost/core/resolver"
+)
+
+// DialTarget is a destination resolved for dialing: the connection is made to the IP,
+// while the TLS server name and the HTTP Host keep the original hostname.
+type DialTarget struct {
+	// Addr is the address to dial, the host part is an IP if it is resolved.
+	Addr string
+	// Host is the original address with the hostname.
+	Host string
+	// ServerName is the original hostname for the TLS SNI, it is empty if the original host is an IP.
+	ServerName string
+}
+
+// ResolveTarget resolves the host of addr by the resolver r and returns the target with the first IP,
+// if addr is an IP or r is nil, the address is dialed as is.
+func ResolveTarget(ctx context.Context, r resolver.Resolver, network, addr string) (DialTarget, error) {
+	target := DialTarget{
+		Addr: addr,
+		Host: addr,
+	}
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return target, err
+	}
+	if net.ParseIP(host) != nil {
+		return target, nil
+	}
+	target.ServerName = host
+	if r == nil {
+		return target, nil
+	}
+
+	ipNetwork := "ip"
+	switch network {
+	case "tcp4", "udp4":
+		ipNetwork = "ip4"
+	case "tcp6", "udp6":
+		ipNetwork = "ip6"
+	}
+	ips, err := r.Resolve(ctx, ipNetwork, host)
+	if err != nil {
+		return target, err
+	}
+	if len(ips) == 0 {
+		return target, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	target.Addr = net.JoinHostPort(ips[0].String(), port)
+	return target, nil
+}
+
+// ApplyTLS sets the server name of the client config cfg to the original hostname,
+// unless it is set explicitly, e.g. by TLSNodeSettings.ServerName.
+func (t DialTarget) ApplyTLS(cfg *tls.Config) {
+	if cfg != nil && cfg.ServerName == "" {
+		cfg.ServerName = t.ServerName
+	}
+}
+
+// ApplyHTTP sets the Host of the request to the original address,
+// unless it is set explicitly, e.g. by HTTPNodeSettings.Host.
+func (t DialTarget) ApplyHTTP(req *http.Request) {
+	if req != nil && req.Host == "" {
+		req.Host = t.Host
+	}
+}
+
+type dialTargetKey struct{}
+
+// ContextWithDialTarget returns a context carrying the target, so the TLS and HTTP layers
+// of the nodes down the chain can preserve the original hostname.
+func ContextWithDialTarget(ctx context.Context, target DialTarget) context.Context {
+	return context.WithValue(ctx, dialTargetKey{}, target)
+}
+
+func DialTargetFromContext(ctx context.Context) (DialTarget, bool) {
+	v, ok := ctx.Value(dialTargetKey{}).(DialTarget)
+	return v, ok
+}
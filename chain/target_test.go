@@ -0,0 +1,140 @@
+package chain
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-gost/core/resolver"
+)
+
+// networkResolver answers with ips and records the network of the last query.
+type networkResolver struct {
+	ips     []net.IP
+	network string
+}
+
+func (r *networkResolver) Resolve(ctx context.Context, network, host string, opts ...resolver.Option) ([]net.IP, error) {
+	r.network = network
+	return r.ips, nil
+}
+
+func TestResolveTargetSNIAndHost(t *testing.T) {
+	sni := make(chan string, 1)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Host)
+	}))
+	srv.TLS = &tls.Config{
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			sni <- hello.ServerName
+			return nil, nil
+		},
+	}
+	srv.StartTLS()
+	defer srv.Close()
+
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	addr := net.JoinHostPort("example.com", port)
+	target, err := ResolveTarget(context.Background(),
+		&networkResolver{ips: []net.IP{net.ParseIP("127.0.0.1"), net.ParseIP("127.0.0.2")}}, "tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := net.JoinHostPort("127.0.0.1", port); target.Addr != want {
+		t.Fatalf("the dial target is %s, want %s", target.Addr, want)
+	}
+
+	// the target flows through the context to the TLS and HTTP layers.
+	ctx := ContextWithDialTarget(context.Background(), target)
+	target, ok := DialTargetFromContext(ctx)
+	if !ok {
+		t.Fatal("the target is not carried by the context")
+	}
+
+	conn, err := net.Dial("tcp", target.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	cfg := &tls.Config{InsecureSkipVerify: true}
+	target.ApplyTLS(cfg)
+	tc := tls.Client(conn, cfg)
+
+	req, _ := http.NewRequest(http.MethodGet, "https://"+target.Addr+"/", nil)
+	req.Host = ""
+	target.ApplyHTTP(req)
+	if err := req.Write(tc); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(tc), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	host, _ := io.ReadAll(resp.Body)
+
+	if s := <-sni; s != "example.com" {
+		t.Fatalf("the SNI is %q, want the original hostname", s)
+	}
+	if string(host) != addr {
+		t.Fatalf("the Host is %q, want %q", host, addr)
+	}
+}
+
+func TestResolveTargetExplicit(t *testing.T) {
+	target := DialTarget{Addr: "192.0.2.1:443", Host: "example.com:443", ServerName: "example.com"}
+
+	// the explicit server name and Host of the node settings are kept.
+	cfg := &tls.Config{ServerName: "front.example.org"}
+	target.ApplyTLS(cfg)
+	if cfg.ServerName != "front.example.org" {
+		t.Fatalf("the explicit server name is overwritten by %q", cfg.ServerName)
+	}
+	req, _ := http.NewRequest(http.MethodGet, "http://192.0.2.1/", nil)
+	req.Host = "front.example.org"
+	target.ApplyHTTP(req)
+	if req.Host != "front.example.org" {
+		t.Fatalf("the explicit Host is overwritten by %q", req.Host)
+	}
+	target.ApplyTLS(nil)
+	target.ApplyHTTP(nil)
+}
+
+func TestResolveTarget(t *testing.T) {
+	r := &networkResolver{ips: []net.IP{net.ParseIP("2001:db8::1")}}
+
+	// the IP is dialed as is without SNI.
+	target, err := ResolveTarget(context.Background(), r, "tcp", "192.0.2.1:443")
+	if err != nil || target.Addr != "192.0.2.1:443" || target.ServerName != "" || r.network != "" {
+		t.Fatalf("unexpected target %+v %v", target, err)
+	}
+
+	// without resolver the hostname is dialed.
+	target, err = ResolveTarget(context.Background(), nil, "tcp", "example.com:443")
+	if err != nil || target.Addr != "example.com:443" || target.ServerName != "example.com" {
+		t.Fatalf("unexpected target %+v %v", target, err)
+	}
+
+	target, err = ResolveTarget(context.Background(), r, "tcp6", "example.com:443")
+	if err != nil || target.Addr != "[2001:db8::1]:443" || r.network != "ip6" {
+		t.Fatalf("unexpected target %+v %v of network %s", target, err, r.network)
+	}
+
+	var dnsErr *net.DNSError
+	if _, err := ResolveTarget(context.Background(), &networkResolver{}, "tcp", "example.com:443"); !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+	if _, err := ResolveTarget(context.Background(), r, "tcp", "example.com"); err == nil {
+		t.Fatal("expected error of the address without port")
+	}
+	if _, ok := DialTargetFromContext(context.Background()); ok {
+		t.Fatal("unexpected target in the empty context")
+	}
+}
@@ -0,0 +1,166 @@
+package chain
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	defaultWarmTTL = 30 * time.Second
+)
+
+// WarmNodeSettings configures a pool of the pre-established connections to the node,
+// so the first request does not pay the connect and handshake latency. See Node.RunWarmPool.
+type WarmNodeSettings struct {
+	// Size is the number of the warm connections kept in the pool.
+	Size int
+	// TTL is the age the idle warm connections are discarded at, default is 30s.
+	TTL time.Duration
+}
+
+func WarmNodeOption(settings *WarmNodeSettings) NodeOption {
+	return func(o *NodeOptions) {
+		o.Warm = settings
+	}
+}
+
+type warmConn struct {
+	conn    net.Conn
+	created time.Time
+}
+
+type warmPool struct {
+	conns  []warmConn
+	refill chan struct{}
+	mu     sync.Mutex
+}
+
+func newWarmPool() *warmPool {
+	return &warmPool{
+		refill: make(chan struct{}, 1),
+	}
+}
+
+func (node *Node) warmSettings() (size int, ttl time.Duration) {
+	settings := node.options.Warm
+	if settings == nil || settings.Size <= 0 {
+		return 0, 0
+	}
+	ttl = settings.TTL
+	if ttl <= 0 {
+		ttl = defaultWarmTTL
+	}
+	return settings.Size, ttl
+}
+
+// WarmConn returns a warm connection from the pool if any, otherwise it establishes a new one (see Node.Establish).
+// warm reports whether the connection is from the pool, the pool is refilled in the background after a take.
+func (node *Node) WarmConn(ctx context.Context) (conn net.Conn, warm bool, err error) {
+	if size, ttl := node.warmSettings(); size > 0 {
+		if conn := node.takeWarm(ttl); conn != nil {
+			return conn, true, nil
+		}
+	}
+
+	conn, err = node.Establish(ctx, node.warmNetwork())
+	return conn, false, err
+}
+
+func (node *Node) takeWarm(ttl time.Duration) net.Conn {
+	p := node.warm
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var conn net.Conn
+	for len(p.conns) > 0 && conn == nil {
+		c := p.conns[0]
+		p.conns = p.conns[1:]
+		if time.Since(c.created) >= ttl {
+			c.conn.Close()
+			continue
+		}
+		conn = c.conn
+	}
+
+	select {
+	case p.refill <- struct{}{}:
+	default:
+	}
+	return conn
+}
+
+// WarmLen returns the number of the warm connections in the pool.
+func (node *Node) WarmLen() int {
+	p := node.warm
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.conns)
+}
+
+// RunWarmPool keeps the warm connection pool of the node filled up to the Size (see WarmNodeOption),
+// the stale connections are reaped after the TTL. It blocks until ctx is done, then the warm connections are closed.
+func (node *Node) RunWarmPool(ctx context.Context) {
+	size, ttl := node.warmSettings()
+	if size <= 0 {
+		return
+	}
+	p := node.warm
+
+	ticker := time.NewTicker(max(ttl/2, time.Millisecond))
+	defer ticker.Stop()
+
+	for {
+		node.fillWarm(ctx, size, ttl)
+
+		select {
+		case <-p.refill:
+		case <-ticker.C:
+		case <-ctx.Done():
+			p.mu.Lock()
+			for _, c := range p.conns {
+				c.conn.Close()
+			}
+			p.conns = nil
+			p.mu.Unlock()
+			return
+		}
+	}
+}
+
+// fillWarm reaps the stale connections and establishes the missing ones, it stops on the first failure.
+func (node *Node) fillWarm(ctx context.Context, size int, ttl time.Duration) {
+	p := node.warm
+
+	p.mu.Lock()
+	live := p.conns[:0]
+	for _, c := range p.conns {
+		if time.Since(c.created) >= ttl {
+			c.conn.Close()
+			continue
+		}
+		live = append(live, c)
+	}
+	p.conns = live
+	missing := size - len(p.conns)
+	p.mu.Unlock()
+
+	for i := 0; i < missing && ctx.Err() == nil; i++ {
+		conn, err := node.Establish(ctx, node.warmNetwork())
+		if err != nil {
+			return
+		}
+
+		p.mu.Lock()
+		p.conns = append(p.conns, warmConn{conn: conn, created: time.Now()})
+		p.mu.Unlock()
+	}
+}
+
+func (node *Node) warmNetwork() string {
+	if node.options.Network != "" {
+		return node.options.Network
+	}
+	return "tcp"
+}
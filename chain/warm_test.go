@@ -0,0 +1,135 @@
+package chain
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// pipeDialer dials by net.Pipe and keeps the peer ends of the dialed conns.
+type pipeDialer struct {
+	mu    sync.Mutex
+	peers []net.Conn
+}
+
+func (d *pipeDialer) Dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	c1, c2 := net.Pipe()
+	d.mu.Lock()
+	d.peers = append(d.peers, c2)
+	d.mu.Unlock()
+	return c1, nil
+}
+
+func (d *pipeDialer) dials() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.peers)
+}
+
+// closed reports whether the conn of the i-th dial is closed.
+func (d *pipeDialer) closed(i int) bool {
+	d.mu.Lock()
+	peer := d.peers[i]
+	d.mu.Unlock()
+
+	peer.SetReadDeadline(time.Now().Add(10 * time.Millisecond))
+	_, err := peer.Read(make([]byte, 1))
+	return err == io.EOF
+}
+
+// waitFor waits until cond is true.
+func waitFor(t *testing.T, msg string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal(msg)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestNodeWarmPool(t *testing.T) {
+	d := &pipeDialer{}
+	node := NewNode("a", "192.0.2.1:443", DialFuncNodeOption(d.Dial), WarmNodeOption(&WarmNodeSettings{Size: 2, TTL: time.Hour}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		node.RunWarmPool(ctx)
+	}()
+	waitFor(t, "the pool is not filled", func() bool { return node.WarmLen() == 2 })
+
+	// the request grabs a warm conn, no dial is observed.
+	conn, warm, err := node.WarmConn(context.Background())
+	if err != nil || !warm || conn == nil {
+		t.Fatalf("expected a warm conn, got %v %v", warm, err)
+	}
+	if n := d.dials(); n != 2 {
+		t.Fatalf("expected no dial for the warm conn, got %d dials", n)
+	}
+	defer conn.Close()
+
+	// the pool is refilled after the consumption.
+	waitFor(t, "the pool is not refilled", func() bool { return node.WarmLen() == 2 && d.dials() == 3 })
+
+	// the warm conns are closed when the pool stops.
+	cancel()
+	<-done
+	if node.WarmLen() != 0 || !d.closed(1) || !d.closed(2) {
+		t.Fatalf("the warm conns should be closed, %d left", node.WarmLen())
+	}
+	if d.closed(0) {
+		t.Fatal("the conn in use should not be closed")
+	}
+}
+
+func TestNodeWarmPoolTTL(t *testing.T) {
+	d := &pipeDialer{}
+	node := NewNode("a", "192.0.2.1:443", DialFuncNodeOption(d.Dial), WarmNodeOption(&WarmNodeSettings{Size: 1, TTL: 50 * time.Millisecond}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go node.RunWarmPool(ctx)
+	waitFor(t, "the pool is not filled", func() bool { return node.WarmLen() == 1 })
+
+	// the stale conn is reaped and replaced.
+	waitFor(t, "the stale conn is not replaced", func() bool { return d.dials() >= 2 && node.WarmLen() == 1 })
+	if !d.closed(0) {
+		t.Fatal("the stale conn should be closed")
+	}
+}
+
+func TestNodeWarmPoolStale(t *testing.T) {
+	d := &pipeDialer{}
+	node := NewNode("a", "192.0.2.1:443", DialFuncNodeOption(d.Dial), WarmNodeOption(&WarmNodeSettings{Size: 1, TTL: 20 * time.Millisecond}))
+
+	// the pool is filled but not maintained, the stale conn is never handed out.
+	node.fillWarm(context.Background(), 1, time.Hour)
+	time.Sleep(30 * time.Millisecond)
+	conn, warm, err := node.WarmConn(context.Background())
+	if err != nil || warm {
+		t.Fatalf("expected a new conn instead of the stale one, got %v %v", warm, err)
+	}
+	defer conn.Close()
+	if d.dials() != 2 || !d.closed(0) {
+		t.Fatalf("the stale conn should be discarded, %d dials", d.dials())
+	}
+}
+
+func TestNodeWarmPoolDisabled(t *testing.T) {
+	d := &pipeDialer{}
+	node := NewNode("a", "192.0.2.1:443", DialFuncNodeOption(d.Dial))
+
+	// RunWarmPool returns at once without the settings.
+	node.RunWarmPool(context.Background())
+	conn, warm, err := node.WarmConn(context.Background())
+	if err != nil || warm || d.dials() != 1 {
+		t.Fatalf("expected a dialed conn, got %v %v with %d dials", warm, err, d.dials())
+	}
+	conn.Close()
+}
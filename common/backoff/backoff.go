@@ -0,0 +1,159 @@
+package backoff
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	// maxInterval is the float64 of math.MaxInt64, which is 2^63.
+	maxInterval = float64(math.MaxInt64)
+)
+
+// Backoff is a strategy of the interval to wait before the next retry.
+type Backoff interface {
+	// NextInterval returns the interval before retry attempt, attempt starts from 1.
+	NextInterval(attempt int) time.Duration
+	// Reset resets the internal state of the backoff after a success.
+	Reset()
+}
+
+type constantBackoff struct {
+	interval time.Duration
+}
+
+// Constant returns a backoff waiting for the same interval between retries.
+func Constant(interval time.Duration) Backoff {
+	return &constantBackoff{interval: interval}
+}
+
+func (b *constantBackoff) NextInterval(attempt int) time.Duration {
+	return b.interval
+}
+
+func (b *constantBackoff) Reset() {}
+
+type Options struct {
+	// Max is the upper bound of the interval, zero means no limit.
+	Max time.Duration
+	// Jitter is the random factor in range [0, 1], the interval is randomized
+	// in range [interval * (1 - Jitter), interval].
+	Jitter float64
+	// Multiplier is the growth factor of the exponential backoff, default is 2.
+	Multiplier float64
+}
+
+type Option func(opts *Options)
+
+func MaxOption(max time.Duration) Option {
+	return func(opts *Options) {
+		opts.Max = max
+	}
+}
+
+func JitterOption(jitter float64) Option {
+	return func(opts *Options) {
+		opts.Jitter = jitter
+	}
+}
+
+func MultiplierOption(multiplier float64) Option {
+	return func(opts *Options) {
+		opts.Multiplier = multiplier
+	}
+}
+
+type exponentialBackoff struct {
+	base    time.Duration
+	options Options
+	rand    *rand.Rand
+	mu      sync.Mutex
+}
+
+// Exponential returns a backoff whose interval grows exponentially from base: base * Multiplier^(attempt-1).
+func Exponential(base time.Duration, opts ...Option) Backoff {
+	var options Options
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+	if options.Multiplier <= 1 {
+		options.Multiplier = 2
+	}
+	options.Jitter = min(max(options.Jitter, 0), 1)
+
+	return &exponentialBackoff{
+		base:    base,
+		options: options,
+		rand:    rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func (b *exponentialBackoff) NextInterval(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+
+	d := float64(b.base) * math.Pow(b.options.Multiplier, float64(attempt-1))
+	if b.options.Max > 0 && d > float64(b.options.Max) {
+		d = float64(b.options.Max)
+	}
+	d = min(d, maxInterval)
+
+	if b.options.Jitter > 0 {
+		b.mu.Lock()
+		r := b.rand.Float64()
+		b.mu.Unlock()
+		d -= d * b.options.Jitter * r
+	}
+	// float64(math.MaxInt64) rounds up to 2^63, which overflows time.Duration.
+	if d >= maxInterval {
+		return math.MaxInt64
+	}
+	return time.Duration(d)
+}
+
+func (b *exponentialBackoff) Reset() {}
+
+type fibonacciBackoff struct {
+	base    time.Duration
+	options Options
+}
+
+// Fibonacci returns a backoff whose interval follows the fibonacci sequence: base, base, 2*base, 3*base, 5*base...
+func Fibonacci(base time.Duration, opts ...Option) Backoff {
+	var options Options
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+	return &fibonacciBackoff{
+		base:    base,
+		options: options,
+	}
+}
+
+func (b *fibonacciBackoff) NextInterval(attempt int) time.Duration {
+	a, c := int64(0), int64(1)
+	for i := 1; i < attempt; i++ {
+		a, c = c, a+c
+		if c > math.MaxInt64/2 {
+			break
+		}
+	}
+
+	d := time.Duration(math.MaxInt64)
+	if b.base <= 0 || c <= math.MaxInt64/int64(b.base) {
+		d = time.Duration(c) * b.base
+	}
+	if b.options.Max > 0 && d > b.options.Max {
+		d = b.options.Max
+	}
+	return d
+}
+
+func (b *fibonacciBackoff) Reset() {}
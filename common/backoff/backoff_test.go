@@ -0,0 +1,111 @@
+package backoff
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestConstant(t *testing.T) {
+	b := Constant(time.Second)
+	for attempt := 1; attempt <= 5; attempt++ {
+		if d := b.NextInterval(attempt); d != time.Second {
+			t.Fatalf("attempt %d: %s", attempt, d)
+		}
+	}
+}
+
+func TestExponential(t *testing.T) {
+	b := Exponential(100 * time.Millisecond)
+	want := []time.Duration{100, 200, 400, 800, 1600}
+	for i, w := range want {
+		if d := b.NextInterval(i + 1); d != w*time.Millisecond {
+			t.Fatalf("attempt %d: got %s, want %s", i+1, d, w*time.Millisecond)
+		}
+	}
+	if d := b.NextInterval(0); d != 100*time.Millisecond {
+		t.Fatalf("attempt 0 should be the first attempt, got %s", d)
+	}
+
+	b = Exponential(100*time.Millisecond, MultiplierOption(3))
+	if d := b.NextInterval(3); d != 900*time.Millisecond {
+		t.Fatalf("multiplier 3: got %s", d)
+	}
+}
+
+func TestExponentialCap(t *testing.T) {
+	b := Exponential(100*time.Millisecond, MaxOption(time.Second))
+	for attempt := 5; attempt <= 100; attempt++ {
+		if d := b.NextInterval(attempt); d != time.Second {
+			t.Fatalf("attempt %d: got %s, want the cap", attempt, d)
+		}
+	}
+}
+
+func TestExponentialOverflow(t *testing.T) {
+	for _, b := range []Backoff{
+		Exponential(time.Second),
+		Exponential(time.Second, JitterOption(0.5)),
+	} {
+		for _, attempt := range []int{40, 64, 100, 1000, math.MaxInt32} {
+			if d := b.NextInterval(attempt); d <= 0 {
+				t.Fatalf("attempt %d: interval overflows to %d", attempt, d)
+			}
+		}
+	}
+	if d := Exponential(time.Second).NextInterval(1000); d != math.MaxInt64 {
+		t.Fatalf("interval should saturate, got %d", d)
+	}
+}
+
+func TestExponentialJitter(t *testing.T) {
+	b := Exponential(time.Second, JitterOption(0.5), MaxOption(8*time.Second))
+	for i := 0; i < 1000; i++ {
+		attempt := i%5 + 1
+		base := min(time.Second<<(attempt-1), 8*time.Second)
+		d := b.NextInterval(attempt)
+		if d < base/2 || d > base {
+			t.Fatalf("attempt %d: %s out of [%s, %s]", attempt, d, base/2, base)
+		}
+	}
+
+	// the jitter is clamped to [0, 1].
+	b = Exponential(time.Second, JitterOption(2))
+	for i := 0; i < 100; i++ {
+		if d := b.NextInterval(1); d < 0 || d > time.Second {
+			t.Fatalf("jitter out of bounds: %s", d)
+		}
+	}
+}
+
+func TestFibonacci(t *testing.T) {
+	b := Fibonacci(time.Second)
+	want := []time.Duration{1, 1, 2, 3, 5, 8, 13}
+	for i, w := range want {
+		if d := b.NextInterval(i + 1); d != w*time.Second {
+			t.Fatalf("attempt %d: got %s, want %s", i+1, d, w*time.Second)
+		}
+	}
+}
+
+func TestFibonacciCap(t *testing.T) {
+	b := Fibonacci(time.Second, MaxOption(10*time.Second))
+	if d := b.NextInterval(7); d != 10*time.Second {
+		t.Fatalf("got %s, want the cap", d)
+	}
+	if d := b.NextInterval(1000); d != 10*time.Second {
+		t.Fatalf("got %s, want the cap", d)
+	}
+}
+
+func TestFibonacciOverflow(t *testing.T) {
+	b := Fibonacci(time.Second)
+	for _, attempt := range []int{50, 92, 100, 1000} {
+		if d := b.NextInterval(attempt); d <= 0 {
+			t.Fatalf("attempt %d: interval overflows to %d", attempt, d)
+		}
+	}
+	if d := b.NextInterval(1000); d != math.MaxInt64 {
+		t.Fatalf("interval should saturate, got %d", d)
+	}
+}
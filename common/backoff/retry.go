@@ -0,0 +1,37 @@
+package backoff
+
+import (
+	"context"
+	"time"
+)
+
+// Retry calls fn until it succeeds or it has been retried retries times,
+// waiting for the interval given by b before each retry, a nil b retries immediately.
+// It stops when ctx is done, and b is reset after a success.
+// The error of the last attempt is returned.
+func Retry(ctx context.Context, retries int, b Backoff, fn func(ctx context.Context) error) (err error) {
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 && b != nil {
+			if interval := b.NextInterval(attempt); interval > 0 {
+				t := time.NewTimer(interval)
+				select {
+				case <-t.C:
+				case <-ctx.Done():
+					t.Stop()
+					return ctx.Err()
+				}
+			}
+		}
+
+		if err = fn(ctx); err == nil {
+			if b != nil {
+				b.Reset()
+			}
+			return nil
+		}
+		if ctx.Err() != nil {
+			return
+		}
+	}
+	return
+}
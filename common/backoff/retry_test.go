@@ -0,0 +1,74 @@
+package backoff
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// recordBackoff records the attempts and resets.
+type recordBackoff struct {
+	attempts []int
+	resets   int
+}
+
+func (b *recordBackoff) NextInterval(attempt int) time.Duration {
+	b.attempts = append(b.attempts, attempt)
+	return time.Millisecond
+}
+
+func (b *recordBackoff) Reset() {
+	b.resets++
+}
+
+func TestRetry(t *testing.T) {
+	errFail := errors.New("fail")
+	b := &recordBackoff{}
+
+	var calls int
+	err := Retry(context.Background(), 3, b, func(ctx context.Context) error {
+		calls++
+		if calls < 3 {
+			return errFail
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Fatalf("expected success on the third call, got %v after %d", err, calls)
+	}
+	if len(b.attempts) != 2 || b.attempts[0] != 1 || b.attempts[1] != 2 || b.resets != 1 {
+		t.Fatalf("unexpected backoff usage %v, resets %d", b.attempts, b.resets)
+	}
+}
+
+func TestRetryExhausted(t *testing.T) {
+	errFail := errors.New("fail")
+
+	var calls int
+	err := Retry(context.Background(), 2, nil, func(ctx context.Context) error {
+		calls++
+		return errFail
+	})
+	if !errors.Is(err, errFail) || calls != 3 {
+		t.Fatalf("expected the last error after 3 calls, got %v after %d", err, calls)
+	}
+}
+
+func TestRetryContext(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	var calls int
+	start := time.Now()
+	err := Retry(ctx, 10, Constant(time.Hour), func(ctx context.Context) error {
+		calls++
+		return errors.New("fail")
+	})
+	if !errors.Is(err, context.DeadlineExceeded) || calls != 1 {
+		t.Fatalf("expected the context error after 1 call, got %v after %d", err, calls)
+	}
+	if time.Since(start) > time.Second {
+		t.Fatal("retry does not stop on the context")
+	}
+}
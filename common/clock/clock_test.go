@@ -0,0 +1,100 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func fired(t Timer) bool {
+	select {
+	case <-t.C():
+		return true
+	default:
+		return false
+	}
+}
+
+func TestFakeClock(t *testing.T) {
+	start := time.Unix(1000, 0)
+	c := NewFakeClock(start)
+	if !c.Now().Equal(start) {
+		t.Fatalf("unexpected now %s", c.Now())
+	}
+
+	c.Advance(time.Minute)
+	if d := c.Since(start); d != time.Minute {
+		t.Fatalf("unexpected since %s", d)
+	}
+}
+
+func TestFakeClockTimer(t *testing.T) {
+	c := NewFakeClock(time.Unix(1000, 0))
+	timer := c.NewTimer(10 * time.Second)
+
+	c.Advance(9 * time.Second)
+	if fired(timer) {
+		t.Fatal("timer fired early")
+	}
+	c.Advance(time.Second)
+	if !fired(timer) {
+		t.Fatal("timer should fire at the deadline")
+	}
+	if timer.Stop() {
+		t.Fatal("fired timer should not be active")
+	}
+
+	// reset schedules the timer again.
+	if timer.Reset(5 * time.Second) {
+		t.Fatal("fired timer should not be active on reset")
+	}
+	c.Advance(5 * time.Second)
+	if !fired(timer) {
+		t.Fatal("reset timer should fire")
+	}
+
+	// stopped timer never fires.
+	timer = c.NewTimer(time.Second)
+	if !timer.Stop() {
+		t.Fatal("pending timer should be active")
+	}
+	c.Advance(time.Hour)
+	if fired(timer) {
+		t.Fatal("stopped timer fired")
+	}
+
+	// non-positive duration fires immediately.
+	if !fired(c.NewTimer(0)) {
+		t.Fatal("zero timer should fire immediately")
+	}
+}
+
+func TestFakeClockAfter(t *testing.T) {
+	c := NewFakeClock(time.Unix(1000, 0))
+	ch := c.After(time.Second)
+	c.Advance(time.Second)
+	select {
+	case now := <-ch:
+		if !now.Equal(time.Unix(1001, 0)) {
+			t.Fatalf("unexpected fire time %s", now)
+		}
+	default:
+		t.Fatal("After should fire")
+	}
+}
+
+func TestOrDefault(t *testing.T) {
+	if OrDefault(nil) != Default {
+		t.Fatal("expected the default clock")
+	}
+	c := NewFakeClock(time.Now())
+	if OrDefault(c) != c {
+		t.Fatal("expected the given clock")
+	}
+
+	timer := Default.NewTimer(time.Millisecond)
+	select {
+	case <-timer.C():
+	case <-time.After(time.Second):
+		t.Fatal("real timer does not fire")
+	}
+}
@@ -0,0 +1,174 @@
+// Package coalesce implements the HTTP/2 connection coalescing (RFC 7540 section 9.1.1 and RFC 8336),
+// the requests for the different authorities share an upstream connection when it is authoritative for them.
+package coalesce
+
+import (
+	"crypto/tls"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Conn is an upstream HTTP/2 connection, e.g. an adapter of http2.ClientConn.
+type Conn interface {
+	// ConnectionState returns the TLS state of the connection.
+	ConnectionState() tls.ConnectionState
+	// RemoteAddr is the address the connection is established to.
+	RemoteAddr() net.Addr
+	// CanTakeNewRequest reports whether the connection can take a new stream.
+	CanTakeNewRequest() bool
+}
+
+type entry[C Conn] struct {
+	conn C
+	// authority is the authority the connection is established for.
+	authority string
+	// origins are the authorities (host:port) of the https origins from the ORIGIN frame (RFC 8336),
+	// nil if the frame is not received.
+	origins map[string]struct{}
+}
+
+// Pool is the pool of the upstream HTTP/2 connections shared across the authorities.
+type Pool[C Conn] struct {
+	entries []*entry[C]
+	mu      sync.Mutex
+}
+
+func NewPool[C Conn]() *Pool[C] {
+	return &Pool[C]{}
+}
+
+// Put adds the connection established for the authority (host:port).
+func (p *Pool[C]) Put(authority string, conn C) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.entries = append(p.entries, &entry[C]{
+		conn:      conn,
+		authority: authority,
+	})
+}
+
+// SetOrigins records the origin set of the connection from the ORIGIN frame,
+// after that the connection is only reused for the authorities in the set (RFC 8336 section 2.4).
+// The origins are in the ASCII serialization (RFC 6454), e.g. https://example.com, the default port 443 is omitted.
+func (p *Pool[C]) SetOrigins(conn C, origins ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, e := range p.entries {
+		if any(e.conn) == any(conn) {
+			e.origins = make(map[string]struct{}, len(origins))
+			for _, o := range origins {
+				if authority := originAuthority(o); authority != "" {
+					e.origins[authority] = struct{}{}
+				}
+			}
+		}
+	}
+}
+
+// originAuthority returns the authority (host:port) of the https origin, or empty if it is not an https origin.
+func originAuthority(origin string) string {
+	u, err := url.Parse(origin)
+	if err != nil || !strings.EqualFold(u.Scheme, "https") || u.Hostname() == "" {
+		return ""
+	}
+	port := u.Port()
+	if port == "" {
+		port = "443"
+	}
+	return strings.ToLower(net.JoinHostPort(u.Hostname(), port))
+}
+
+// Remove removes the connection, e.g. when it is closed.
+func (p *Pool[C]) Remove(conn C) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	entries := p.entries[:0]
+	for _, e := range p.entries {
+		if any(e.conn) != any(conn) {
+			entries = append(entries, e)
+		}
+	}
+	clear(p.entries[len(entries):])
+	p.entries = entries
+}
+
+// Get returns a connection which can serve the authority (host:port), the addrs are the resolved IPs of the host.
+// A connection established for another authority is reused if it can be coalesced, see Coalescable.
+// Without addrs a connection is only coalesced by its ORIGIN frame, the DNS check is not skipped.
+func (p *Pool[C]) Get(authority string, addrs []net.IP) (conn C, ok bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// prefer the connection established for the authority.
+	for _, e := range p.entries {
+		if e.authority == authority && e.conn.CanTakeNewRequest() {
+			return e.conn, true
+		}
+	}
+	for _, e := range p.entries {
+		if e.conn.CanTakeNewRequest() && p.coalescable(e, authority, addrs) {
+			return e.conn, true
+		}
+	}
+	return
+}
+
+func (p *Pool[C]) coalescable(e *entry[C], authority string, addrs []net.IP) bool {
+	if e.origins != nil {
+		if _, ok := e.origins[strings.ToLower(authority)]; !ok {
+			return false
+		}
+		// the origin set replaces the DNS check (RFC 8336 section 2.4), the certificate is still checked.
+		return Coalescable(e.conn, authority, nil)
+	}
+	// the connection must be established to a resolved address of the host (RFC 7540 section 9.1.1).
+	if len(addrs) == 0 {
+		return false
+	}
+	return Coalescable(e.conn, authority, addrs)
+}
+
+// Coalescable reports whether the connection conn can serve the authority (host:port) by RFC 7540 section 9.1.1:
+// the port is the same, the certificate of the connection is valid for the host, and the connection
+// is established to one of the addrs resolved for the host. If addrs is nil the address check is skipped.
+func Coalescable(conn Conn, authority string, addrs []net.IP) bool {
+	host, port, err := net.SplitHostPort(authority)
+	if err != nil {
+		return false
+	}
+
+	raddr, ok := conn.RemoteAddr().(*net.TCPAddr)
+	if !ok {
+		if ra := conn.RemoteAddr(); ra != nil {
+			raddr, _ = net.ResolveTCPAddr("tcp", ra.String())
+		}
+	}
+	if raddr == nil || port != strconv.Itoa(raddr.Port) {
+		return false
+	}
+
+	if addrs != nil {
+		matched := false
+		for _, ip := range addrs {
+			if ip.Equal(raddr.IP) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	state := conn.ConnectionState()
+	if !state.HandshakeComplete || len(state.PeerCertificates) == 0 {
+		return false
+	}
+	return state.PeerCertificates[0].VerifyHostname(host) == nil
+}
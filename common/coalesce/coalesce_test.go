@@ -0,0 +1,171 @@
+package coalesce
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+)
+
+// fakeConn is an upstream connection to addr with a certificate for the names.
+type fakeConn struct {
+	addr  *net.TCPAddr
+	state tls.ConnectionState
+	full  bool
+}
+
+func newFakeConn(t *testing.T, addr string, names ...string) *fakeConn {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: names[0]},
+		DNSNames:     names,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raddr, err := net.ResolveTCPAddr("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &fakeConn{
+		addr: raddr,
+		state: tls.ConnectionState{
+			HandshakeComplete: true,
+			PeerCertificates:  []*x509.Certificate{cert},
+		},
+	}
+}
+
+func (c *fakeConn) ConnectionState() tls.ConnectionState {
+	return c.state
+}
+
+func (c *fakeConn) RemoteAddr() net.Addr {
+	return c.addr
+}
+
+func (c *fakeConn) CanTakeNewRequest() bool {
+	return !c.full
+}
+
+func TestCoalescable(t *testing.T) {
+	conn := newFakeConn(t, "192.0.2.1:443", "a.example.com", "*.example.org")
+	addrs := []net.IP{net.ParseIP("192.0.2.9"), net.ParseIP("192.0.2.1")}
+
+	for _, tc := range []struct {
+		authority string
+		addrs     []net.IP
+		ok        bool
+	}{
+		{"a.example.com:443", addrs, true},
+		{"b.example.org:443", addrs, true},
+		{"b.example.org:443", nil, true},
+		// the certificate is not valid for the host.
+		{"b.example.com:443", addrs, false},
+		// another port.
+		{"a.example.com:8443", addrs, false},
+		// the host is not resolved to the address of the connection.
+		{"b.example.org:443", []net.IP{net.ParseIP("192.0.2.9")}, false},
+		{"b.example.org", addrs, false},
+	} {
+		if ok := Coalescable(conn, tc.authority, tc.addrs); ok != tc.ok {
+			t.Errorf("%s %v: got %v, want %v", tc.authority, tc.addrs, ok, tc.ok)
+		}
+	}
+
+	conn.state.HandshakeComplete = false
+	if Coalescable(conn, "a.example.com:443", addrs) {
+		t.Fatal("the connection without handshake should not be coalescable")
+	}
+}
+
+func TestPoolGet(t *testing.T) {
+	p := NewPool[*fakeConn]()
+	conn := newFakeConn(t, "192.0.2.1:443", "a.example.com", "b.example.com")
+	p.Put("a.example.com:443", conn)
+	addrs := []net.IP{net.ParseIP("192.0.2.1")}
+
+	// the requests to the same authority reuse the connection.
+	for i := 0; i < 2; i++ {
+		if c, ok := p.Get("a.example.com:443", addrs); !ok || c != conn {
+			t.Fatalf("request %d: expected the connection of the authority", i)
+		}
+	}
+	// the coalescable authority reuses the connection.
+	if c, ok := p.Get("b.example.com:443", addrs); !ok || c != conn {
+		t.Fatal("expected the coalesced connection")
+	}
+
+	// the ones not coalescable do not.
+	for _, tc := range []struct {
+		authority string
+		addrs     []net.IP
+	}{
+		{"c.example.com:443", addrs},
+		{"b.example.com:443", []net.IP{net.ParseIP("192.0.2.2")}},
+		// without the resolved addresses the DNS check is not skipped.
+		{"b.example.com:443", nil},
+	} {
+		if _, ok := p.Get(tc.authority, tc.addrs); ok {
+			t.Errorf("%s %v: the connection should not be coalesced", tc.authority, tc.addrs)
+		}
+	}
+
+	// the busy connection is not reused.
+	conn.full = true
+	if _, ok := p.Get("a.example.com:443", addrs); ok {
+		t.Fatal("the busy connection should not be reused")
+	}
+	conn.full = false
+
+	p.Remove(conn)
+	if _, ok := p.Get("a.example.com:443", addrs); ok {
+		t.Fatal("the removed connection should not be reused")
+	}
+}
+
+func TestPoolOrigins(t *testing.T) {
+	p := NewPool[*fakeConn]()
+	conn := newFakeConn(t, "192.0.2.1:443", "a.example.com", "b.example.com", "c.example.com", "d.example.com")
+	p.Put("a.example.com:443", conn)
+	p.SetOrigins(conn, "https://B.example.com", "https://c.example.com:443", "https://d.example.com:8443", "http://a.example.com")
+
+	// the origin set replaces the DNS check, the default port is omitted in the origin.
+	for _, authority := range []string{"b.example.com:443", "c.example.com:443"} {
+		if c, ok := p.Get(authority, nil); !ok || c != conn {
+			t.Errorf("%s: expected the connection by the origin set", authority)
+		}
+	}
+
+	// the authorities out of the set are not coalesced even if the DNS check passes.
+	for _, authority := range []string{"d.example.com:443", "x.example.com:443"} {
+		if _, ok := p.Get(authority, []net.IP{net.ParseIP("192.0.2.1")}); ok {
+			t.Errorf("%s: the authority out of the origin set should not be coalesced", authority)
+		}
+	}
+
+	// the certificate is still checked.
+	p.SetOrigins(conn, "https://x.example.com")
+	if _, ok := p.Get("x.example.com:443", nil); ok {
+		t.Fatal("the certificate not valid for the origin should not be coalesced")
+	}
+}
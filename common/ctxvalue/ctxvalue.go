@@ -0,0 +1,133 @@
+// Package ctxvalue defines the values carried in context across the components.
+package ctxvalue
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+type clientAddrKey struct{}
+
+// ContextWithClientAddr returns a context carrying the client address.
+func ContextWithClientAddr(ctx context.Context, addr string) context.Context {
+	return context.WithValue(ctx, clientAddrKey{}, addr)
+}
+
+func ClientAddrFromContext(ctx context.Context) string {
+	v, _ := ctx.Value(clientAddrKey{}).(string)
+	return v
+}
+
+type sniKey struct{}
+
+// ContextWithSNI returns a context carrying the server name from the TLS ClientHello.
+func ContextWithSNI(ctx context.Context, sni string) context.Context {
+	return context.WithValue(ctx, sniKey{}, sni)
+}
+
+func SNIFromContext(ctx context.Context) string {
+	v, _ := ctx.Value(sniKey{}).(string)
+	return v
+}
+
+type preferredNodeKey struct{}
+
+// ContextWithPreferredNode returns a context carrying the node name requested by the client,
+// e.g. from an HTTP header or SOCKS metadata.
+func ContextWithPreferredNode(ctx context.Context, node string) context.Context {
+	return context.WithValue(ctx, preferredNodeKey{}, node)
+}
+
+func PreferredNodeFromContext(ctx context.Context) string {
+	v, _ := ctx.Value(preferredNodeKey{}).(string)
+	return v
+}
+
+type priorityKey struct{}
+
+// ContextWithPriority returns a context carrying the priority class of the request,
+// the higher value is the more important request. The default priority is 0.
+func ContextWithPriority(ctx context.Context, priority int) context.Context {
+	return context.WithValue(ctx, priorityKey{}, priority)
+}
+
+func PriorityFromContext(ctx context.Context) int {
+	v, _ := ctx.Value(priorityKey{}).(int)
+	return v
+}
+
+type affinityKey struct{}
+
+// ContextWithAffinityKey returns a context carrying the key relating the connections for the selection,
+// e.g. a session ID, see selector.AntiAffinityStrategy.
+func ContextWithAffinityKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, affinityKey{}, key)
+}
+
+func AffinityKeyFromContext(ctx context.Context) string {
+	v, _ := ctx.Value(affinityKey{}).(string)
+	return v
+}
+
+type ja3Key struct{}
+
+// ContextWithJA3 returns a context carrying the JA3 fingerprint hash of the TLS client.
+func ContextWithJA3(ctx context.Context, ja3 string) context.Context {
+	return context.WithValue(ctx, ja3Key{}, ja3)
+}
+
+func JA3FromContext(ctx context.Context) string {
+	v, _ := ctx.Value(ja3Key{}).(string)
+	return v
+}
+
+type traceIDKey struct{}
+
+// NewTraceID generates a random trace ID of 16 hex characters.
+func NewTraceID() string {
+	var b [8]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// ContextWithTraceID returns a context carrying the trace ID of the connection,
+// if id is empty, a new one is generated. The ID is attached to the logs, records and observer events
+// of the connection throughout the chain.
+func ContextWithTraceID(ctx context.Context, id string) context.Context {
+	if id == "" {
+		id = NewTraceID()
+	}
+	return context.WithValue(ctx, traceIDKey{}, id)
+}
+
+func TraceIDFromContext(ctx context.Context) string {
+	v, _ := ctx.Value(traceIDKey{}).(string)
+	return v
+}
+
+type internalKey struct{}
+
+// ContextWithInternal returns a context flagging the request as the internal service-to-service traffic,
+// e.g. set by a middleware from a service mesh header.
+func ContextWithInternal(ctx context.Context, internal bool) context.Context {
+	return context.WithValue(ctx, internalKey{}, internal)
+}
+
+func InternalFromContext(ctx context.Context) bool {
+	v, _ := ctx.Value(internalKey{}).(bool)
+	return v
+}
+
+type protocolKey struct{}
+
+// ContextWithProtocol returns a context carrying the protocol of the request, e.g. tcp, udp or tls,
+// for selecting the compatible nodes only.
+func ContextWithProtocol(ctx context.Context, protocol string) context.Context {
+	return context.WithValue(ctx, protocolKey{}, protocol)
+}
+
+func ProtocolFromContext(ctx context.Context) string {
+	v, _ := ctx.Value(protocolKey{}).(string)
+	return v
+}
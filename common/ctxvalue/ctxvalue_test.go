@@ -0,0 +1,52 @@
+package ctxvalue
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+)
+
+func TestTraceID(t *testing.T) {
+	if id := TraceIDFromContext(context.Background()); id != "" {
+		t.Fatalf("unexpected trace ID %q", id)
+	}
+
+	// a new ID is generated if it is not given.
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		id := TraceIDFromContext(ContextWithTraceID(context.Background(), ""))
+		if b, err := hex.DecodeString(id); err != nil || len(b) != 8 {
+			t.Fatalf("invalid trace ID %q", id)
+		}
+		if seen[id] {
+			t.Fatalf("duplicate trace ID %q", id)
+		}
+		seen[id] = true
+	}
+
+	if id := TraceIDFromContext(ContextWithTraceID(context.Background(), "trace-1")); id != "trace-1" {
+		t.Fatalf("the given trace ID is not kept, got %q", id)
+	}
+}
+
+func TestInternal(t *testing.T) {
+	if InternalFromContext(context.Background()) {
+		t.Fatal("the request should not be internal by default")
+	}
+	ctx := ContextWithInternal(context.Background(), true)
+	if !InternalFromContext(ctx) {
+		t.Fatal("expected the internal flag")
+	}
+	if InternalFromContext(ContextWithInternal(ctx, false)) {
+		t.Fatal("the flag should be overridden")
+	}
+}
+
+func TestProtocol(t *testing.T) {
+	if p := ProtocolFromContext(context.Background()); p != "" {
+		t.Fatalf("unexpected protocol %q", p)
+	}
+	if p := ProtocolFromContext(ContextWithProtocol(context.Background(), "udp")); p != "udp" {
+		t.Fatalf("unexpected protocol %q", p)
+	}
+}
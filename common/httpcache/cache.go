@@ -0,0 +1,377 @@
+// Package httpcache implements a shared HTTP response cache for the idempotent GET requests
+// passing through the HTTP handlers.
+package httpcache
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-gost/core/common/clock"
+)
+
+const (
+	defaultMaxSize      = 64 * 1024 * 1024
+	defaultMaxEntrySize = 1024 * 1024
+)
+
+// RoundTripperFunc is an adapter to use a function as http.RoundTripper.
+type RoundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+type Options struct {
+	// MaxSize is the total size of the cached bodies.
+	MaxSize int64
+	// MaxEntrySize is the maximum size of a cached body, the larger responses are not cached.
+	MaxEntrySize int64
+	Clock        clock.Clock
+}
+
+type Option func(opts *Options)
+
+func MaxSizeOption(size int64) Option {
+	return func(opts *Options) {
+		opts.MaxSize = size
+	}
+}
+
+func MaxEntrySizeOption(size int64) Option {
+	return func(opts *Options) {
+		opts.MaxEntrySize = size
+	}
+}
+
+func ClockOption(c clock.Clock) Option {
+	return func(opts *Options) {
+		opts.Clock = c
+	}
+}
+
+type entry struct {
+	key        string
+	primary    string
+	vary       []string
+	status     int
+	proto      string
+	header     http.Header
+	body       []byte
+	stored     time.Time
+	expires    time.Time
+	staleUntil time.Time
+	elem       *list.Element
+}
+
+type cache struct {
+	transport http.RoundTripper
+	options   Options
+	clock     clock.Clock
+	// vary records the Vary header names by the primary key,
+	// it is kept while there are entries of the primary key, counted by variants.
+	vary       map[string][]string
+	variants   map[string]int
+	entries    map[string]*entry
+	lru        *list.List
+	size       int64
+	refreshing map[string]struct{}
+	mu         sync.Mutex
+}
+
+// Transport wraps the round tripper rt with a response cache keyed by method, URL and the Vary headers.
+// It honors Cache-Control (max-age, s-maxage, no-store, no-cache, private, stale-while-revalidate)
+// and Expires. Requests with Authorization and responses with Set-Cookie are never cached.
+func Transport(rt http.RoundTripper, opts ...Option) http.RoundTripper {
+	options := Options{
+		MaxSize:      defaultMaxSize,
+		MaxEntrySize: defaultMaxEntrySize,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+
+	return &cache{
+		transport:  rt,
+		options:    options,
+		clock:      clock.OrDefault(options.Clock),
+		vary:       make(map[string][]string),
+		variants:   make(map[string]int),
+		entries:    make(map[string]*entry),
+		lru:        list.New(),
+		refreshing: make(map[string]struct{}),
+	}
+}
+
+func (c *cache) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !isCacheableRequest(req) {
+		return c.transport.RoundTrip(req)
+	}
+
+	primary := primaryKey(req)
+	now := c.clock.Now()
+
+	c.mu.Lock()
+	e := c.entries[secondaryKey(primary, c.vary[primary], req)]
+	if e != nil {
+		c.lru.MoveToFront(e.elem)
+	}
+	c.mu.Unlock()
+
+	if e != nil {
+		if now.Before(e.expires) {
+			return e.response(req, now), nil
+		}
+		if now.Before(e.staleUntil) {
+			c.refresh(req, e.key)
+			return e.response(req, now), nil
+		}
+	}
+
+	resp, err := c.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	return c.store(req, resp, now), nil
+}
+
+// refresh revalidates the entry in the background for stale-while-revalidate.
+func (c *cache) refresh(req *http.Request, key string) {
+	c.mu.Lock()
+	if _, ok := c.refreshing[key]; ok {
+		c.mu.Unlock()
+		return
+	}
+	c.refreshing[key] = struct{}{}
+	c.mu.Unlock()
+
+	r := req.Clone(context.WithoutCancel(req.Context()))
+	go func() {
+		defer func() {
+			c.mu.Lock()
+			delete(c.refreshing, key)
+			c.mu.Unlock()
+		}()
+
+		resp, err := c.transport.RoundTrip(r)
+		if err != nil {
+			return
+		}
+		resp = c.store(r, resp, c.clock.Now())
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}()
+}
+
+// store caches the response if it is cacheable, the returned response should be used in place of resp.
+func (c *cache) store(req *http.Request, resp *http.Response, now time.Time) *http.Response {
+	expires, staleUntil, ok := c.freshness(resp, now)
+	if !ok {
+		return resp
+	}
+
+	vary := varyHeaders(resp.Header)
+	if vary == nil {
+		return resp
+	}
+
+	if resp.ContentLength > c.options.MaxEntrySize {
+		return resp
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, c.options.MaxEntrySize+1))
+	if err != nil || int64(len(body)) > c.options.MaxEntrySize {
+		// not cacheable, replay the bytes already read.
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		return resp
+	}
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	primary := primaryKey(req)
+	e := &entry{
+		key:        secondaryKey(primary, vary, req),
+		primary:    primary,
+		vary:       vary,
+		status:     resp.StatusCode,
+		proto:      resp.Proto,
+		header:     resp.Header.Clone(),
+		body:       body,
+		stored:     now,
+		expires:    expires,
+		staleUntil: staleUntil,
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if old := c.entries[e.key]; old != nil {
+		c.remove(old)
+	}
+	c.vary[primary] = vary
+	c.variants[primary]++
+	e.elem = c.lru.PushFront(e)
+	c.entries[e.key] = e
+	c.size += int64(len(body))
+	for c.size > c.options.MaxSize && c.lru.Len() > 0 {
+		c.remove(c.lru.Back().Value.(*entry))
+	}
+
+	return resp
+}
+
+// remove must be called with the lock held.
+func (c *cache) remove(e *entry) {
+	c.lru.Remove(e.elem)
+	delete(c.entries, e.key)
+	c.size -= int64(len(e.body))
+
+	if c.variants[e.primary]--; c.variants[e.primary] <= 0 {
+		delete(c.variants, e.primary)
+		delete(c.vary, e.primary)
+	}
+}
+
+// freshness returns the expiration and the end of the stale-while-revalidate window of resp.
+func (c *cache) freshness(resp *http.Response, now time.Time) (expires, staleUntil time.Time, ok bool) {
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNonAuthoritativeInfo, http.StatusMovedPermanently,
+		http.StatusNotFound, http.StatusGone:
+	default:
+		return
+	}
+	if resp.Header.Get("Set-Cookie") != "" {
+		return
+	}
+
+	cc := parseCacheControl(resp.Header.Get("Cache-Control"))
+	if cc.has("no-store") || cc.has("no-cache") || cc.has("private") {
+		return
+	}
+
+	switch {
+	case cc.has("s-maxage"):
+		expires = now.Add(parseSeconds(cc["s-maxage"]))
+	case cc.has("max-age"):
+		expires = now.Add(parseSeconds(cc["max-age"]))
+	case resp.Header.Get("Expires") != "":
+		t, err := http.ParseTime(resp.Header.Get("Expires"))
+		if err != nil {
+			return
+		}
+		if date, err := http.ParseTime(resp.Header.Get("Date")); err == nil {
+			expires = now.Add(t.Sub(date))
+		} else {
+			expires = t
+		}
+	default:
+		return
+	}
+	if !expires.After(now) {
+		return
+	}
+
+	staleUntil = expires
+	if cc.has("stale-while-revalidate") {
+		staleUntil = expires.Add(parseSeconds(cc["stale-while-revalidate"]))
+	}
+	return expires, staleUntil, true
+}
+
+func (e *entry) response(req *http.Request, now time.Time) *http.Response {
+	header := e.header.Clone()
+	header.Set("Age", strconv.Itoa(int(now.Sub(e.stored).Seconds())))
+	return &http.Response{
+		Status:        strconv.Itoa(e.status) + " " + http.StatusText(e.status),
+		StatusCode:    e.status,
+		Proto:         e.proto,
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(e.body)),
+		ContentLength: int64(len(e.body)),
+		Request:       req,
+	}
+}
+
+func isCacheableRequest(req *http.Request) bool {
+	if req.Method != http.MethodGet || req.Header.Get("Authorization") != "" {
+		return false
+	}
+	cc := parseCacheControl(req.Header.Get("Cache-Control"))
+	if cc.has("no-store") || cc.has("no-cache") {
+		return false
+	}
+	return req.Header.Get("Pragma") != "no-cache"
+}
+
+func primaryKey(req *http.Request) string {
+	return req.Method + " " + req.URL.String()
+}
+
+func secondaryKey(primary string, vary []string, req *http.Request) string {
+	var sb strings.Builder
+	sb.WriteString(primary)
+	for _, name := range vary {
+		sb.WriteString("\n")
+		sb.WriteString(name)
+		sb.WriteString(":")
+		sb.WriteString(strings.Join(req.Header.Values(name), ","))
+	}
+	return sb.String()
+}
+
+// varyHeaders returns the canonical header names in Vary, nil for "Vary: *" which is not cacheable.
+func varyHeaders(h http.Header) []string {
+	vary := []string{}
+	for _, v := range h.Values("Vary") {
+		for _, name := range strings.Split(v, ",") {
+			name = strings.TrimSpace(name)
+			if name == "*" {
+				return nil
+			}
+			if name != "" {
+				vary = append(vary, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	return vary
+}
+
+type cacheControl map[string]string
+
+func (cc cacheControl) has(directive string) bool {
+	_, ok := cc[directive]
+	return ok
+}
+
+func parseCacheControl(s string) cacheControl {
+	cc := make(cacheControl)
+	for _, v := range strings.Split(s, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		k, val, _ := strings.Cut(v, "=")
+		cc[strings.ToLower(strings.TrimSpace(k))] = strings.Trim(strings.TrimSpace(val), `"`)
+	}
+	return cc
+}
+
+func parseSeconds(s string) time.Duration {
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return time.Duration(n) * time.Second
+}
@@ -0,0 +1,197 @@
+package httpcache
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-gost/core/common/clock"
+)
+
+// upstream returns a round tripper responding with the header and body, counting the requests.
+func upstream(header http.Header, body string, n *atomic.Int64) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		n.Add(1)
+		return &http.Response{
+			Status:        "200 OK",
+			StatusCode:    http.StatusOK,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header.Clone(),
+			Body:          io.NopCloser(strings.NewReader(body)),
+			ContentLength: int64(len(body)),
+			Request:       req,
+		}, nil
+	})
+}
+
+func get(t *testing.T, rt http.RoundTripper, url string, header http.Header) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func readBody(t *testing.T, resp *http.Response) string {
+	t.Helper()
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestCacheHit(t *testing.T) {
+	c := clock.NewFakeClock(time.Unix(1000, 0))
+	var n atomic.Int64
+	rt := Transport(upstream(http.Header{"Cache-Control": {"max-age=60"}}, "hello", &n), ClockOption(c))
+
+	if body := readBody(t, get(t, rt, "http://example.com/a", nil)); body != "hello" {
+		t.Fatalf("unexpected body %q", body)
+	}
+	c.Advance(10 * time.Second)
+	resp := get(t, rt, "http://example.com/a", nil)
+	if body := readBody(t, resp); body != "hello" || resp.Header.Get("Age") != "10" {
+		t.Fatalf("unexpected cached response %q age %s", body, resp.Header.Get("Age"))
+	}
+	if n.Load() != 1 {
+		t.Fatalf("the second request should be served from cache, got %d upstream requests", n.Load())
+	}
+
+	// expired
+	c.Advance(time.Minute)
+	readBody(t, get(t, rt, "http://example.com/a", nil))
+	if n.Load() != 2 {
+		t.Fatalf("the expired response should be fetched again, got %d upstream requests", n.Load())
+	}
+}
+
+func TestCacheNotCached(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		resp   http.Header
+		req    http.Header
+		method string
+	}{
+		{name: "no-store", resp: http.Header{"Cache-Control": {"no-store, max-age=60"}}},
+		{name: "private", resp: http.Header{"Cache-Control": {"private, max-age=60"}}},
+		{name: "set-cookie", resp: http.Header{"Cache-Control": {"max-age=60"}, "Set-Cookie": {"a=b"}}},
+		{name: "vary-all", resp: http.Header{"Cache-Control": {"max-age=60"}, "Vary": {"*"}}},
+		{name: "no-freshness", resp: http.Header{}},
+		{name: "authorized", resp: http.Header{"Cache-Control": {"max-age=60"}}, req: http.Header{"Authorization": {"Bearer token"}}},
+		{name: "request-no-store", resp: http.Header{"Cache-Control": {"max-age=60"}}, req: http.Header{"Cache-Control": {"no-store"}}},
+	} {
+		var n atomic.Int64
+		rt := Transport(upstream(tc.resp, "hello", &n))
+		for i := 0; i < 2; i++ {
+			if body := readBody(t, get(t, rt, "http://example.com/a", tc.req)); body != "hello" {
+				t.Fatalf("%s: unexpected body %q", tc.name, body)
+			}
+		}
+		if n.Load() != 2 {
+			t.Errorf("%s: the response should not be cached", tc.name)
+		}
+	}
+}
+
+func TestCacheVary(t *testing.T) {
+	var n atomic.Int64
+	rt := Transport(upstream(http.Header{"Cache-Control": {"max-age=60"}, "Vary": {"accept-encoding"}}, "hello", &n))
+
+	gzip := http.Header{"Accept-Encoding": {"gzip"}}
+	readBody(t, get(t, rt, "http://example.com/a", gzip))
+	readBody(t, get(t, rt, "http://example.com/a", gzip))
+	if n.Load() != 1 {
+		t.Fatalf("the same variant should be cached, got %d upstream requests", n.Load())
+	}
+	readBody(t, get(t, rt, "http://example.com/a", http.Header{"Accept-Encoding": {"br"}}))
+	if n.Load() != 2 {
+		t.Fatalf("a different variant should not be served from cache, got %d upstream requests", n.Load())
+	}
+}
+
+func TestCacheStaleWhileRevalidate(t *testing.T) {
+	c := clock.NewFakeClock(time.Unix(1000, 0))
+	var n atomic.Int64
+	rt := Transport(upstream(http.Header{"Cache-Control": {"max-age=10, stale-while-revalidate=30"}}, "hello", &n), ClockOption(c))
+
+	readBody(t, get(t, rt, "http://example.com/a", nil))
+	c.Advance(20 * time.Second)
+
+	// the stale response is served and refreshed in the background.
+	if body := readBody(t, get(t, rt, "http://example.com/a", nil)); body != "hello" {
+		t.Fatalf("unexpected stale body %q", body)
+	}
+	deadline := time.Now().Add(time.Second)
+	for n.Load() != 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("the stale response is not revalidated")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// out of the stale window.
+	c.Advance(time.Minute)
+	readBody(t, get(t, rt, "http://example.com/a", nil))
+	if n.Load() != 3 {
+		t.Fatalf("expected a synchronous fetch out of the stale window, got %d", n.Load())
+	}
+}
+
+func TestCacheEviction(t *testing.T) {
+	var n atomic.Int64
+	body := strings.Repeat("x", 100)
+	rt := Transport(upstream(http.Header{"Cache-Control": {"max-age=60"}, "Vary": {"Accept"}}, body, &n), MaxSizeOption(150))
+	c := rt.(*cache)
+
+	readBody(t, get(t, rt, "http://example.com/a", nil))
+	readBody(t, get(t, rt, "http://example.com/b", nil))
+
+	c.mu.Lock()
+	_, varyA := c.vary["GET http://example.com/a"]
+	_, varyB := c.vary["GET http://example.com/b"]
+	entries, size := len(c.entries), c.size
+	c.mu.Unlock()
+
+	if entries != 1 || size != 100 {
+		t.Fatalf("expected the oldest entry evicted, got %d entries of %d bytes", entries, size)
+	}
+	// the Vary record of the evicted key is removed with its last entry.
+	if varyA || !varyB {
+		t.Fatalf("unexpected Vary records: a %v b %v", varyA, varyB)
+	}
+
+	readBody(t, get(t, rt, "http://example.com/a", nil))
+	if n.Load() != 3 {
+		t.Fatalf("the evicted response should be fetched again, got %d", n.Load())
+	}
+}
+
+func TestCacheMaxEntrySize(t *testing.T) {
+	var n atomic.Int64
+	body := strings.Repeat("x", 100)
+	rt := Transport(upstream(http.Header{"Cache-Control": {"max-age=60"}}, body, &n), MaxEntrySizeOption(50))
+
+	for i := 0; i < 2; i++ {
+		if got := readBody(t, get(t, rt, "http://example.com/a", nil)); got != body {
+			t.Fatalf("the large body is not replayed, got %d bytes", len(got))
+		}
+	}
+	if n.Load() != 2 {
+		t.Fatalf("the large response should not be cached")
+	}
+}
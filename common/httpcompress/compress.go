@@ -0,0 +1,319 @@
+// Package httpcompress implements an HTTP middleware compressing the responses
+// according to the Accept-Encoding of the requests.
+package httpcompress
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+const (
+	defaultMinSize = 1024
+)
+
+var (
+	defaultContentTypes = []string{
+		"text/",
+		"application/json",
+		"application/javascript",
+		"application/xml",
+		"application/xhtml+xml",
+		"image/svg+xml",
+	}
+)
+
+// EncoderFunc creates a compressing writer writing into w.
+type EncoderFunc func(w io.Writer) io.WriteCloser
+
+type Options struct {
+	// MinSize is the minimum body size to compress, the smaller bodies are sent as is.
+	MinSize int
+	// ContentTypes lists the media types to compress, the entries ending with '/' match by prefix.
+	ContentTypes []string
+	// Encoders are the supported encodings in the order of preference, br, gzip and deflate are built in.
+	// Other encodings such as zstd are registered by EncoderOption.
+	Encoders []string
+	encoders map[string]EncoderFunc
+}
+
+type Option func(opts *Options)
+
+func MinSizeOption(size int) Option {
+	return func(opts *Options) {
+		opts.MinSize = size
+	}
+}
+
+func ContentTypesOption(types ...string) Option {
+	return func(opts *Options) {
+		opts.ContentTypes = types
+	}
+}
+
+// EncoderOption registers the encoding with the highest preference, e.g. "zstd",
+// a built-in encoding is replaced.
+func EncoderOption(encoding string, fn EncoderFunc) Option {
+	return func(opts *Options) {
+		if opts.encoders == nil {
+			opts.encoders = make(map[string]EncoderFunc)
+		}
+		encoders := []string{encoding}
+		for _, v := range opts.Encoders {
+			if v != encoding {
+				encoders = append(encoders, v)
+			}
+		}
+		opts.Encoders = encoders
+		opts.encoders[encoding] = fn
+	}
+}
+
+// Handler wraps h to compress the eligible responses, which are the responses with an allowed content type,
+// at least MinSize bytes in body and not encoded already. The Vary header is set for the eligible content types.
+func Handler(h http.Handler, opts ...Option) http.Handler {
+	options := Options{
+		MinSize:      defaultMinSize,
+		ContentTypes: defaultContentTypes,
+		Encoders:     []string{"br", "gzip", "deflate"},
+		encoders: map[string]EncoderFunc{
+			"br": func(w io.Writer) io.WriteCloser {
+				return brotli.NewWriter(w)
+			},
+			"gzip": func(w io.Writer) io.WriteCloser {
+				return gzip.NewWriter(w)
+			},
+			"deflate": func(w io.Writer) io.WriteCloser {
+				fw, _ := flate.NewWriter(w, flate.DefaultCompression)
+				return fw
+			},
+		},
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding := options.negotiate(r.Header.Get("Accept-Encoding"))
+		if encoding == "" || r.Method == http.MethodHead {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &responseWriter{
+			ResponseWriter: w,
+			options:        &options,
+			encoding:       encoding,
+		}
+		defer cw.Close()
+
+		h.ServeHTTP(cw, r)
+	})
+}
+
+// negotiate returns the preferred encoding acceptable by the client.
+func (opts *Options) negotiate(accept string) string {
+	if accept == "" {
+		return ""
+	}
+
+	qs := make(map[string]float64)
+	for _, s := range strings.Split(accept, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(s), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		qs[strings.ToLower(strings.TrimSpace(name))] = q
+	}
+
+	var best string
+	var bestQ float64
+	for _, encoding := range opts.Encoders {
+		q, ok := qs[encoding]
+		if !ok {
+			q, ok = qs["*"]
+		}
+		if ok && q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+	return best
+}
+
+func (opts *Options) allowType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, t := range opts.ContentTypes {
+		if strings.HasSuffix(t, "/") && strings.HasPrefix(mediaType, t) || mediaType == t {
+			return true
+		}
+	}
+	return false
+}
+
+type responseWriter struct {
+	http.ResponseWriter
+	options  *Options
+	encoding string
+	status   int
+	buf      bytes.Buffer
+	// decided is set when the header is written, encoder is set if the body is compressed.
+	decided bool
+	encoder io.WriteCloser
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if w.decided || w.status != 0 {
+		return
+	}
+	if status < http.StatusOK && status != http.StatusSwitchingProtocols {
+		// informational responses are sent through.
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	w.status = status
+	if !w.eligible() {
+		w.decide(false)
+	}
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.decided {
+		if w.encoder != nil {
+			return w.encoder.Write(b)
+		}
+		return w.ResponseWriter.Write(b)
+	}
+
+	w.buf.Write(b)
+	if w.buf.Len() >= w.options.MinSize {
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// eligible reports whether the response may be compressed by its header.
+func (w *responseWriter) eligible() bool {
+	header := w.Header()
+	if w.status == http.StatusNoContent || w.status == http.StatusNotModified ||
+		w.status == http.StatusSwitchingProtocols || w.status == http.StatusPartialContent {
+		return false
+	}
+	if header.Get("Content-Encoding") != "" {
+		return false
+	}
+	if strings.Contains(header.Get("Cache-Control"), "no-transform") {
+		return false
+	}
+	if cl := header.Get("Content-Length"); cl != "" {
+		if n, err := strconv.Atoi(cl); err == nil && n < w.options.MinSize {
+			return false
+		}
+	}
+	if ct := header.Get("Content-Type"); ct != "" && !w.options.allowType(ct) {
+		return false
+	}
+	return true
+}
+
+// decide writes the header and the buffered body, the body is compressed if compress is true
+// and the content type is allowed.
+func (w *responseWriter) decide(compress bool) error {
+	if w.decided {
+		return nil
+	}
+	w.decided = true
+
+	header := w.Header()
+	if compress {
+		if header.Get("Content-Type") == "" {
+			header.Set("Content-Type", http.DetectContentType(w.buf.Bytes()))
+		}
+		compress = w.options.allowType(header.Get("Content-Type"))
+	}
+	if compress || (w.status != 0 && header.Get("Content-Encoding") == "" && w.options.allowType(header.Get("Content-Type"))) {
+		header.Add("Vary", "Accept-Encoding")
+	}
+	if compress {
+		header.Set("Content-Encoding", w.encoding)
+		header.Del("Content-Length")
+		w.encoder = w.options.encoders[w.encoding](w.ResponseWriter)
+	}
+
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+
+	if w.buf.Len() == 0 {
+		return nil
+	}
+	var err error
+	if w.encoder != nil {
+		_, err = w.encoder.Write(w.buf.Bytes())
+	} else {
+		_, err = w.ResponseWriter.Write(w.buf.Bytes())
+	}
+	w.buf.Reset()
+	return err
+}
+
+// Close flushes the buffered body, it is called when the handler returns.
+func (w *responseWriter) Close() error {
+	if !w.decided {
+		if w.status == 0 && w.buf.Len() == 0 {
+			// nothing is written by the handler.
+			return nil
+		}
+		// the body is smaller than the minimum size.
+		w.decide(false)
+	}
+	if w.encoder != nil {
+		return w.encoder.Close()
+	}
+	return nil
+}
+
+func (w *responseWriter) Flush() {
+	if !w.decided {
+		w.decide(w.buf.Len() > 0 && w.eligible())
+	}
+	if f, ok := w.encoder.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, http.ErrNotSupported
+}
+
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
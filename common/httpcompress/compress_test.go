@@ -0,0 +1,210 @@
+package httpcompress
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+var largeBody = strings.Repeat("hello, world. ", 200)
+
+// serve serves the request with the header and the body by the compressing handler.
+func serve(t *testing.T, acceptEncoding string, header http.Header, body string, opts ...Option) *httptest.ResponseRecorder {
+	t.Helper()
+
+	h := Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for k, vs := range header {
+			w.Header()[k] = vs
+		}
+		io.WriteString(w, body)
+	}), opts...)
+
+	r := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+	if acceptEncoding != "" {
+		r.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func textHeader() http.Header {
+	return http.Header{"Content-Type": {"text/plain; charset=utf-8"}}
+}
+
+func TestHandlerCompress(t *testing.T) {
+	w := serve(t, "gzip, deflate", textHeader(), largeBody)
+
+	if ce := w.Header().Get("Content-Encoding"); ce != "gzip" {
+		t.Fatalf("expected gzip encoding, got %q", ce)
+	}
+	if vary := w.Header().Get("Vary"); vary != "Accept-Encoding" {
+		t.Fatalf("unexpected Vary %q", vary)
+	}
+	if w.Body.Len() >= len(largeBody) {
+		t.Fatalf("the body is not compressed, %d bytes", w.Body.Len())
+	}
+
+	zr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(zr)
+	if err != nil || string(b) != largeBody {
+		t.Fatalf("unexpected decompressed body %d bytes, %v", len(b), err)
+	}
+}
+
+func TestHandlerNegotiate(t *testing.T) {
+	for _, tc := range []struct {
+		accept   string
+		encoding string
+	}{
+		{"deflate", "deflate"},
+		{"gzip;q=0.5, deflate", "deflate"},
+		{"gzip;q=0", ""},
+		{"*", "br"},
+		{"gzip, deflate, br", "br"},
+		{"br;q=0.5, gzip", "gzip"},
+		{"br", "br"},
+		{"identity", ""},
+		{"", ""},
+	} {
+		w := serve(t, tc.accept, textHeader(), largeBody)
+		if ce := w.Header().Get("Content-Encoding"); ce != tc.encoding {
+			t.Errorf("%q: expected encoding %q, got %q", tc.accept, tc.encoding, ce)
+		}
+	}
+
+	w := serve(t, "deflate", textHeader(), largeBody)
+	b, err := io.ReadAll(flate.NewReader(w.Body))
+	if err != nil || string(b) != largeBody {
+		t.Fatalf("unexpected deflated body %d bytes, %v", len(b), err)
+	}
+}
+
+func TestHandlerBrotli(t *testing.T) {
+	w := serve(t, "gzip, deflate, br", textHeader(), largeBody)
+	if ce := w.Header().Get("Content-Encoding"); ce != "br" {
+		t.Fatalf("expected br encoding, got %q", ce)
+	}
+	if w.Body.Len() >= len(largeBody) {
+		t.Fatalf("the body is not compressed, %d bytes", w.Body.Len())
+	}
+	b, err := io.ReadAll(brotli.NewReader(w.Body))
+	if err != nil || string(b) != largeBody {
+		t.Fatalf("unexpected decompressed body %d bytes, %v", len(b), err)
+	}
+}
+
+func TestHandlerMinSize(t *testing.T) {
+	w := serve(t, "gzip", textHeader(), "tiny")
+
+	if ce := w.Header().Get("Content-Encoding"); ce != "" {
+		t.Fatalf("the tiny body should not be compressed, got %q", ce)
+	}
+	if w.Body.String() != "tiny" {
+		t.Fatalf("unexpected body %q", w.Body.String())
+	}
+	// the response varies by the encoding even if it is not compressed.
+	if vary := w.Header().Get("Vary"); vary != "Accept-Encoding" {
+		t.Fatalf("unexpected Vary %q", vary)
+	}
+
+	w = serve(t, "gzip", textHeader(), "tiny", MinSizeOption(1))
+	if ce := w.Header().Get("Content-Encoding"); ce != "gzip" {
+		t.Fatalf("expected gzip with the lower threshold, got %q", ce)
+	}
+}
+
+func TestHandlerEncoded(t *testing.T) {
+	header := textHeader()
+	header.Set("Content-Encoding", "br")
+
+	// the already encoded response is passed through untouched.
+	w := serve(t, "gzip", header, largeBody)
+	if ce := w.Header().Get("Content-Encoding"); ce != "br" {
+		t.Fatalf("unexpected encoding %q", ce)
+	}
+	if w.Body.String() != largeBody {
+		t.Fatal("the encoded body should be untouched")
+	}
+}
+
+func TestHandlerContentType(t *testing.T) {
+	w := serve(t, "gzip", http.Header{"Content-Type": {"image/png"}}, largeBody)
+	if ce := w.Header().Get("Content-Encoding"); ce != "" || w.Body.String() != largeBody {
+		t.Fatalf("the disallowed type should not be compressed, got %q", ce)
+	}
+	if vary := w.Header().Get("Vary"); vary != "" {
+		t.Fatalf("unexpected Vary %q", vary)
+	}
+
+	// the content type is detected.
+	w = serve(t, "gzip", nil, "<html><body>"+largeBody+"</body></html>")
+	if ce := w.Header().Get("Content-Encoding"); ce != "gzip" || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") {
+		t.Fatalf("expected the detected html to be compressed, got %q %q", ce, w.Header().Get("Content-Type"))
+	}
+
+	w = serve(t, "gzip", http.Header{"Content-Type": {"application/wasm"}}, largeBody, ContentTypesOption("application/wasm"))
+	if ce := w.Header().Get("Content-Encoding"); ce != "gzip" {
+		t.Fatalf("expected the configured type to be compressed, got %q", ce)
+	}
+}
+
+func TestHandlerNoTransform(t *testing.T) {
+	header := textHeader()
+	header.Set("Cache-Control", "public, no-transform")
+	w := serve(t, "gzip", header, largeBody)
+	if ce := w.Header().Get("Content-Encoding"); ce != "" || w.Body.String() != largeBody {
+		t.Fatalf("the no-transform response should not be compressed, got %q", ce)
+	}
+}
+
+// upperEncoder is a fake encoding upper-casing the body.
+type upperEncoder struct {
+	w io.Writer
+}
+
+func (e *upperEncoder) Write(b []byte) (int, error) {
+	return e.w.Write([]byte(strings.ToUpper(string(b))))
+}
+
+func (e *upperEncoder) Close() error {
+	return nil
+}
+
+func TestHandlerEncoderOption(t *testing.T) {
+	upper := EncoderOption("zstd", func(w io.Writer) io.WriteCloser {
+		return &upperEncoder{w: w}
+	})
+
+	// the registered encoding is preferred.
+	w := serve(t, "gzip, br, zstd", textHeader(), largeBody, upper)
+	if ce := w.Header().Get("Content-Encoding"); ce != "zstd" {
+		t.Fatalf("expected zstd encoding, got %q", ce)
+	}
+	if w.Body.String() != strings.ToUpper(largeBody) {
+		t.Fatal("the body is not encoded by the registered encoder")
+	}
+
+	w = serve(t, "gzip", textHeader(), largeBody, upper)
+	if ce := w.Header().Get("Content-Encoding"); ce != "gzip" {
+		t.Fatalf("expected gzip encoding, got %q", ce)
+	}
+
+	// the built-in encoding is replaced and preferred.
+	gzipUpper := EncoderOption("gzip", func(w io.Writer) io.WriteCloser {
+		return &upperEncoder{w: w}
+	})
+	w = serve(t, "gzip, br", textHeader(), largeBody, gzipUpper)
+	if ce := w.Header().Get("Content-Encoding"); ce != "gzip" || w.Body.String() != strings.ToUpper(largeBody) {
+		t.Fatalf("the built-in gzip is not replaced, got %q", ce)
+	}
+}
@@ -0,0 +1,215 @@
+// Package httplimit implements an HTTP middleware limiting the request and response body sizes,
+// which protects the proxy against the resource exhaustion.
+package httplimit
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/go-gost/core/metadata"
+)
+
+const (
+	// MDKeyMaxRequestBody is the metadata key to override the maximum request body size per route.
+	MDKeyMaxRequestBody = "http.maxRequestBody"
+	// MDKeyMaxResponseBody is the metadata key to override the maximum response body size per route.
+	MDKeyMaxResponseBody = "http.maxResponseBody"
+)
+
+var (
+	ErrRequestBodyTooLarge  = errors.New("http: request body too large")
+	ErrResponseBodyTooLarge = errors.New("http: response body too large")
+)
+
+type Options struct {
+	// MaxRequestBody is the maximum request body size, 0 means unlimited.
+	MaxRequestBody int64
+	// MaxResponseBody is the maximum response body size, 0 means unlimited.
+	MaxResponseBody int64
+	// Metadata returns the route metadata of the request to override the limits, see MDKeyMaxRequestBody.
+	Metadata func(r *http.Request) metadata.Metadata
+}
+
+type Option func(opts *Options)
+
+func MaxRequestBodyOption(n int64) Option {
+	return func(opts *Options) {
+		opts.MaxRequestBody = n
+	}
+}
+
+func MaxResponseBodyOption(n int64) Option {
+	return func(opts *Options) {
+		opts.MaxResponseBody = n
+	}
+}
+
+func MetadataOption(fn func(r *http.Request) metadata.Metadata) Option {
+	return func(opts *Options) {
+		opts.Metadata = fn
+	}
+}
+
+// Handler wraps h to enforce the body size limits. The bodies are checked as they stream, not buffered.
+// A request with a body over the limit is answered with 413, reading beyond the limit
+// fails with ErrRequestBodyTooLarge, and the writes of the handler are discarded after that.
+// A response over the limit is rejected with 502 if the header is not sent yet (e.g. by Content-Length),
+// otherwise the connection is aborted.
+func Handler(h http.Handler, opts ...Option) http.Handler {
+	var options Options
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		maxReq, maxResp := options.MaxRequestBody, options.MaxResponseBody
+		if options.Metadata != nil {
+			md := options.Metadata(r)
+			if n, ok := intFromMetadata(md, MDKeyMaxRequestBody); ok {
+				maxReq = n
+			}
+			if n, ok := intFromMetadata(md, MDKeyMaxResponseBody); ok {
+				maxResp = n
+			}
+		}
+
+		if maxReq > 0 && r.ContentLength > maxReq {
+			http.Error(w, ErrRequestBodyTooLarge.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		if maxReq <= 0 && maxResp <= 0 {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		lw := &responseWriter{
+			ResponseWriter: w,
+			limit:          maxResp,
+		}
+		if maxReq > 0 && r.Body != nil && r.Body != http.NoBody {
+			r.Body = &bodyReader{
+				ReadCloser: r.Body,
+				remaining:  maxReq,
+				w:          lw,
+			}
+		}
+		h.ServeHTTP(lw, r)
+	})
+}
+
+type bodyReader struct {
+	io.ReadCloser
+	remaining int64
+	w         *responseWriter
+}
+
+func (r *bodyReader) Read(b []byte) (int, error) {
+	if r.remaining < 0 {
+		return 0, ErrRequestBodyTooLarge
+	}
+	// read one more byte to detect the body over the limit.
+	if int64(len(b)) > r.remaining+1 {
+		b = b[:r.remaining+1]
+	}
+	n, err := r.ReadCloser.Read(b)
+	r.remaining -= int64(n)
+	if r.remaining < 0 {
+		r.w.reject(http.StatusRequestEntityTooLarge, ErrRequestBodyTooLarge)
+		return n + int(r.remaining), ErrRequestBodyTooLarge
+	}
+	return n, err
+}
+
+type responseWriter struct {
+	http.ResponseWriter
+	limit       int64
+	written     int64
+	wroteHeader bool
+	// rejected is set when the response is replaced by an error, the writes of the handler are discarded.
+	rejected bool
+}
+
+func (w *responseWriter) reject(status int, err error) {
+	if w.rejected {
+		return
+	}
+	w.rejected = true
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		http.Error(w.ResponseWriter, err.Error(), status)
+	}
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if w.rejected || w.wroteHeader {
+		return
+	}
+	if w.limit > 0 {
+		if cl, err := strconv.ParseInt(w.Header().Get("Content-Length"), 10, 64); err == nil && cl > w.limit {
+			w.Header().Del("Content-Length")
+			w.reject(http.StatusBadGateway, ErrResponseBodyTooLarge)
+			return
+		}
+	}
+	if status >= http.StatusOK {
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.rejected {
+		return 0, ErrResponseBodyTooLarge
+	}
+	if w.limit > 0 && w.written+int64(len(b)) > w.limit {
+		// the header is sent already, abort the response.
+		panic(http.ErrAbortHandler)
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.written += int64(n)
+	return n, err
+}
+
+func (w *responseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok && !w.rejected {
+		f.Flush()
+	}
+}
+
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, http.ErrNotSupported
+}
+
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func intFromMetadata(md metadata.Metadata, key string) (int64, bool) {
+	if md == nil || !md.IsExists(key) {
+		return 0, false
+	}
+
+	switch v := md.Get(key).(type) {
+	case int:
+		return int64(v), true
+	case int64:
+		return v, true
+	case string:
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return n, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,182 @@
+package httplimit
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-gost/core/metadata"
+)
+
+type mapMetadata map[string]any
+
+func (m mapMetadata) IsExists(key string) bool {
+	_, ok := m[key]
+	return ok
+}
+
+func (m mapMetadata) Set(key string, value any) {
+	m[key] = value
+}
+
+func (m mapMetadata) Get(key string) any {
+	return m[key]
+}
+
+// countReader is a body of n bytes of unknown length, counting the bytes read from it.
+type countReader struct {
+	n    int
+	read int
+}
+
+func (r *countReader) Read(b []byte) (int, error) {
+	if r.read >= r.n {
+		return 0, io.EOF
+	}
+	n := min(len(b), r.n-r.read)
+	for i := range b[:n] {
+		b[i] = 'x'
+	}
+	r.read += n
+	return n, nil
+}
+
+// echoHandler reads the request body and answers with its size, or the read error.
+func echoHandler(readErr *error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(r.Body)
+		if readErr != nil {
+			*readErr = err
+		}
+		if err != nil {
+			// the writes after the rejection are discarded.
+			w.WriteHeader(http.StatusInternalServerError)
+			io.WriteString(w, err.Error())
+			return
+		}
+		io.WriteString(w, strconv.Itoa(len(b)))
+	})
+}
+
+func TestHandlerRequestBody(t *testing.T) {
+	var readErr error
+	h := Handler(echoHandler(&readErr), MaxRequestBodyOption(100))
+
+	// the body under the limit passes.
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(strings.Repeat("x", 100))))
+	if w.Code != http.StatusOK || w.Body.String() != "100" || readErr != nil {
+		t.Fatalf("unexpected response %d %q %v", w.Code, w.Body.String(), readErr)
+	}
+
+	// the body of unknown length over the limit is rejected mid-stream.
+	body := &countReader{n: 1 << 20}
+	r := httptest.NewRequest(http.MethodPost, "/", body)
+	r.ContentLength = -1
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusRequestEntityTooLarge || !strings.Contains(w.Body.String(), ErrRequestBodyTooLarge.Error()) {
+		t.Fatalf("expected 413, got %d %q", w.Code, w.Body.String())
+	}
+	if !errors.Is(readErr, ErrRequestBodyTooLarge) {
+		t.Fatalf("expected ErrRequestBodyTooLarge on read, got %v", readErr)
+	}
+	if body.read > 101 {
+		t.Fatalf("the body is read beyond the limit, %d bytes", body.read)
+	}
+}
+
+func TestHandlerRequestContentLength(t *testing.T) {
+	called := false
+	h := Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}), MaxRequestBodyOption(100))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(strings.Repeat("x", 101))))
+	if w.Code != http.StatusRequestEntityTooLarge || called {
+		t.Fatalf("the declared body over the limit should be rejected before the handler, got %d", w.Code)
+	}
+}
+
+func TestHandlerResponseBody(t *testing.T) {
+	h := Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, _ := strconv.Atoi(r.URL.Query().Get("n"))
+		if r.URL.Query().Get("length") != "" {
+			w.Header().Set("Content-Length", strconv.Itoa(n))
+		}
+		for i := 0; i < n; i += 10 {
+			if _, err := io.WriteString(w, strings.Repeat("x", 10)); err != nil {
+				return
+			}
+			w.(http.Flusher).Flush()
+		}
+	}), MaxResponseBodyOption(100))
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	get := func(query string) (*http.Response, []byte, error) {
+		resp, err := http.Get(srv.URL + "/?" + query)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		return resp, b, err
+	}
+
+	// the response under the limit passes.
+	if resp, b, err := get("n=100"); err != nil || resp.StatusCode != http.StatusOK || len(b) != 100 {
+		t.Fatalf("unexpected response %v %d bytes %v", resp, len(b), err)
+	}
+
+	// the response declared over the limit is rejected before the header is sent.
+	if resp, _, err := get("n=200&length=1"); err != nil || resp.StatusCode != http.StatusBadGateway {
+		t.Fatalf("expected 502, got %v %v", resp, err)
+	}
+
+	// the streamed response over the limit is aborted.
+	resp, b, err := get("n=1000")
+	if err == nil {
+		t.Fatalf("expected the aborted response, got %d bytes", len(b))
+	}
+	if resp.StatusCode != http.StatusOK || len(b) > 100 {
+		t.Fatalf("unexpected aborted response %d with %d bytes", resp.StatusCode, len(b))
+	}
+}
+
+func TestHandlerMetadata(t *testing.T) {
+	h := Handler(echoHandler(nil),
+		MaxRequestBodyOption(10),
+		MetadataOption(func(r *http.Request) metadata.Metadata {
+			switch r.URL.Path {
+			case "/upload":
+				return mapMetadata{MDKeyMaxRequestBody: "1000"}
+			case "/unlimited":
+				return mapMetadata{MDKeyMaxRequestBody: 0}
+			}
+			return nil
+		}))
+
+	for _, tc := range []struct {
+		path string
+		size int
+		code int
+	}{
+		{"/", 10, http.StatusOK},
+		{"/", 11, http.StatusRequestEntityTooLarge},
+		{"/upload", 1000, http.StatusOK},
+		{"/upload", 1001, http.StatusRequestEntityTooLarge},
+		{"/unlimited", 1 << 16, http.StatusOK},
+	} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, tc.path, strings.NewReader(strings.Repeat("x", tc.size))))
+		if w.Code != tc.code {
+			t.Errorf("%s with %d bytes: got %d, want %d", tc.path, tc.size, w.Code, tc.code)
+		}
+	}
+}
@@ -0,0 +1,278 @@
+package net
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-gost/core/common/ctxvalue"
+)
+
+const (
+	maxClientHelloSize = 16 * 1024
+	// clientHelloTimeout bounds the wait for the ClientHello of a silent client if ctx has no earlier deadline.
+	clientHelloTimeout = 10 * time.Second
+
+	tlsExtServerName      = 0
+	tlsExtSupportedGroups = 10
+	tlsExtECPointFormats  = 11
+)
+
+var (
+	ErrNotClientHello = errors.New("not a TLS ClientHello")
+)
+
+// ClientHello is the information parsed from a TLS ClientHello message.
+type ClientHello struct {
+	Version      uint16
+	ServerName   string
+	CipherSuites []uint16
+	// Extensions are the extension types in the order of the message.
+	Extensions []uint16
+	// Curves are the supported groups.
+	Curves       []uint16
+	PointFormats []uint8
+}
+
+// JA3 returns the JA3 fingerprint string of the ClientHello, the GREASE values (RFC 8701) are excluded.
+func (h *ClientHello) JA3() string {
+	var b strings.Builder
+	b.WriteString(strconv.Itoa(int(h.Version)))
+	for _, list := range [][]uint16{h.CipherSuites, h.Extensions, h.Curves} {
+		b.WriteByte(',')
+		writeJA3List(&b, list)
+	}
+	b.WriteByte(',')
+	formats := make([]uint16, len(h.PointFormats))
+	for i, v := range h.PointFormats {
+		formats[i] = uint16(v)
+	}
+	writeJA3List(&b, formats)
+	return b.String()
+}
+
+// JA3Hash returns the JA3 fingerprint in the MD5 hex form.
+func (h *ClientHello) JA3Hash() string {
+	sum := md5.Sum([]byte(h.JA3()))
+	return hex.EncodeToString(sum[:])
+}
+
+func writeJA3List(b *strings.Builder, list []uint16) {
+	first := true
+	for _, v := range list {
+		if isGREASE(v) {
+			continue
+		}
+		if !first {
+			b.WriteByte('-')
+		}
+		first = false
+		b.WriteString(strconv.Itoa(int(v)))
+	}
+}
+
+// isGREASE reports whether v is a GREASE value, such as 0x0a0a, 0x1a1a, ... 0xfafa.
+func isGREASE(v uint16) bool {
+	return v&0x0f0f == 0x0a0a && v>>8 == v&0xff
+}
+
+// PeekClientHello peeks and parses the TLS ClientHello from c without consuming it,
+// c should be created by NewPeekConnSize with the size for the largest ClientHello record, see SniffClientHello.
+func PeekClientHello(c *PeekConn) (*ClientHello, error) {
+	hdr, err := c.Peek(5)
+	if err != nil {
+		return nil, err
+	}
+	if hdr[0] != 0x16 || hdr[1] != 0x03 {
+		return nil, ErrNotClientHello
+	}
+	n := int(binary.BigEndian.Uint16(hdr[3:5]))
+	if n > maxClientHelloSize {
+		return nil, ErrNotClientHello
+	}
+
+	b, err := c.Peek(5 + n)
+	if err != nil {
+		return nil, err
+	}
+	return ParseClientHello(b[5:])
+}
+
+// ParseClientHello parses the ClientHello handshake message from the TLS record payload b.
+func ParseClientHello(b []byte) (*ClientHello, error) {
+	s := cryptobyte(b)
+
+	// handshake type(1) + length(3)
+	var typ uint8
+	var body cryptobyte
+	if !s.readUint8(&typ) || typ != 0x01 || !s.readUint24Bytes(&body) {
+		return nil, ErrNotClientHello
+	}
+
+	hello := &ClientHello{}
+	var random, sessionID, ciphers, compressions cryptobyte
+	if !body.readUint16(&hello.Version) ||
+		!body.readBytes(&random, 32) ||
+		!body.readUint8Bytes(&sessionID) ||
+		!body.readUint16Bytes(&ciphers) ||
+		!body.readUint8Bytes(&compressions) {
+		return nil, ErrNotClientHello
+	}
+	for len(ciphers) > 0 {
+		var v uint16
+		if !ciphers.readUint16(&v) {
+			return nil, ErrNotClientHello
+		}
+		hello.CipherSuites = append(hello.CipherSuites, v)
+	}
+	if len(body) == 0 {
+		// no extensions
+		return hello, nil
+	}
+
+	var exts cryptobyte
+	if !body.readUint16Bytes(&exts) {
+		return nil, ErrNotClientHello
+	}
+	for len(exts) > 0 {
+		var typ uint16
+		var data cryptobyte
+		if !exts.readUint16(&typ) || !exts.readUint16Bytes(&data) {
+			return nil, ErrNotClientHello
+		}
+		hello.Extensions = append(hello.Extensions, typ)
+
+		switch typ {
+		case tlsExtServerName:
+			var names cryptobyte
+			if !data.readUint16Bytes(&names) {
+				return nil, ErrNotClientHello
+			}
+			for len(names) > 0 {
+				var nameType uint8
+				var name cryptobyte
+				if !names.readUint8(&nameType) || !names.readUint16Bytes(&name) {
+					return nil, ErrNotClientHello
+				}
+				if nameType == 0 {
+					hello.ServerName = string(name)
+					break
+				}
+			}
+		case tlsExtSupportedGroups:
+			var groups cryptobyte
+			if !data.readUint16Bytes(&groups) {
+				return nil, ErrNotClientHello
+			}
+			for len(groups) > 0 {
+				var v uint16
+				if !groups.readUint16(&v) {
+					return nil, ErrNotClientHello
+				}
+				hello.Curves = append(hello.Curves, v)
+			}
+		case tlsExtECPointFormats:
+			var formats cryptobyte
+			if !data.readUint8Bytes(&formats) {
+				return nil, ErrNotClientHello
+			}
+			hello.PointFormats = append(hello.PointFormats, formats...)
+		}
+	}
+
+	return hello, nil
+}
+
+// cryptobyte is a minimal reader for the TLS wire format.
+type cryptobyte []byte
+
+func (s *cryptobyte) readBytes(out *cryptobyte, n int) bool {
+	if n < 0 || len(*s) < n {
+		return false
+	}
+	*out = (*s)[:n]
+	*s = (*s)[n:]
+	return true
+}
+
+func (s *cryptobyte) readUint8(out *uint8) bool {
+	var v cryptobyte
+	if !s.readBytes(&v, 1) {
+		return false
+	}
+	*out = v[0]
+	return true
+}
+
+func (s *cryptobyte) readUint16(out *uint16) bool {
+	var v cryptobyte
+	if !s.readBytes(&v, 2) {
+		return false
+	}
+	*out = binary.BigEndian.Uint16(v)
+	return true
+}
+
+func (s *cryptobyte) readUint8Bytes(out *cryptobyte) bool {
+	var n uint8
+	return s.readUint8(&n) && s.readBytes(out, int(n))
+}
+
+func (s *cryptobyte) readUint16Bytes(out *cryptobyte) bool {
+	var n uint16
+	return s.readUint16(&n) && s.readBytes(out, int(n))
+}
+
+func (s *cryptobyte) readUint24Bytes(out *cryptobyte) bool {
+	var v cryptobyte
+	if !s.readBytes(&v, 3) {
+		return false
+	}
+	n := int(v[0])<<16 | int(v[1])<<8 | int(v[2])
+	return s.readBytes(out, n)
+}
+
+// SniffSNI peeks the TLS ClientHello of conn and returns a context carrying the server name
+// (see ctxvalue.SNIFromContext) and the connection to be used in place of conn, which replays the peeked bytes.
+// If conn does not start with a ClientHello, ctx is returned unchanged.
+func SniffSNI(ctx context.Context, conn net.Conn) (context.Context, net.Conn) {
+	pc, hello, err := sniffClientHello(ctx, conn)
+	if err != nil || hello.ServerName == "" {
+		return ctx, pc
+	}
+	return ctxvalue.ContextWithSNI(ctx, hello.ServerName), pc
+}
+
+// SniffClientHello is like SniffSNI, the context also carries the JA3 fingerprint hash of the client
+// (see ctxvalue.JA3FromContext) for matching the client TLS stacks in the routing and bypass.
+func SniffClientHello(ctx context.Context, conn net.Conn) (context.Context, net.Conn) {
+	pc, hello, err := sniffClientHello(ctx, conn)
+	if err != nil {
+		return ctx, pc
+	}
+	if hello.ServerName != "" {
+		ctx = ctxvalue.ContextWithSNI(ctx, hello.ServerName)
+	}
+	return ctxvalue.ContextWithJA3(ctx, hello.JA3Hash()), pc
+}
+
+// sniffClientHello peeks the ClientHello of conn within the deadline of ctx or clientHelloTimeout.
+func sniffClientHello(ctx context.Context, conn net.Conn) (*PeekConn, *ClientHello, error) {
+	pc := NewPeekConnSize(conn, 5+maxClientHelloSize)
+
+	deadline := time.Now().Add(clientHelloTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetReadDeadline(deadline)
+	defer conn.SetReadDeadline(time.Time{})
+
+	hello, err := PeekClientHello(pc)
+	return pc, hello, err
+}
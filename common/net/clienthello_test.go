@@ -0,0 +1,227 @@
+package net
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-gost/core/common/ctxvalue"
+)
+
+// clientHelloConn returns the server side of a pipe on which a TLS client sends the ClientHello for serverName.
+func clientHelloConn(t *testing.T, serverName string) net.Conn {
+	t.Helper()
+
+	c1, c2 := net.Pipe()
+	t.Cleanup(func() {
+		c1.Close()
+		c2.Close()
+	})
+	go tls.Client(c1, &tls.Config{ServerName: serverName, InsecureSkipVerify: true}).Handshake()
+	return c2
+}
+
+func TestSniffSNI(t *testing.T) {
+	ctx, conn := SniffSNI(context.Background(), clientHelloConn(t, "example.com"))
+	if sni := ctxvalue.SNIFromContext(ctx); sni != "example.com" {
+		t.Fatalf("SNI is %q", sni)
+	}
+
+	// the ClientHello is replayed.
+	b := make([]byte, 5)
+	if _, err := io.ReadFull(conn, b); err != nil {
+		t.Fatal(err)
+	}
+	if b[0] != 0x16 || b[1] != 0x03 {
+		t.Fatalf("unexpected record header %x", b)
+	}
+}
+
+func TestSniffSNINotTLS(t *testing.T) {
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	go func() {
+		c2.Write([]byte("GET / HTTP/1.1\r\n\r\n"))
+		c2.Close()
+	}()
+
+	ctx, conn := SniffSNI(context.Background(), c1)
+	if sni := ctxvalue.SNIFromContext(ctx); sni != "" {
+		t.Fatalf("unexpected SNI %q", sni)
+	}
+	b, _ := io.ReadAll(conn)
+	if string(b) != "GET / HTTP/1.1\r\n\r\n" {
+		t.Fatalf("peeked bytes are not replayed: %q", b)
+	}
+}
+
+func TestParseClientHelloMalformed(t *testing.T) {
+	for _, b := range [][]byte{
+		nil,
+		{0x02, 0, 0, 0},
+		{0x01, 0, 0, 10, 3, 3},
+	} {
+		if _, err := ParseClientHello(b); err != ErrNotClientHello {
+			t.Errorf("%x: expected ErrNotClientHello, got %v", b, err)
+		}
+	}
+}
+
+const (
+	sampleJA3     = "771,4865-49199,0-10-11-35,29-23,0"
+	sampleJA3Hash = "0f375cda5fca92c20fe61d8590c24346"
+)
+
+// sampleClientHello returns the TLS record of a ClientHello with GREASE values,
+// its JA3 fingerprint is sampleJA3.
+func sampleClientHello(serverName string) []byte {
+	return paddedClientHello(serverName, 0)
+}
+
+// paddedClientHello is like sampleClientHello with a padding extension of n bytes if n > 0,
+// e.g. for the large key shares.
+func paddedClientHello(serverName string, n int) []byte {
+	u16 := func(b []byte, v int) []byte {
+		return binary.BigEndian.AppendUint16(b, uint16(v))
+	}
+	ext := func(b []byte, typ int, data []byte) []byte {
+		b = u16(b, typ)
+		b = u16(b, len(data))
+		return append(b, data...)
+	}
+
+	var sni []byte
+	sni = u16(sni, len(serverName)+3)
+	sni = append(sni, 0)
+	sni = u16(sni, len(serverName))
+	sni = append(sni, serverName...)
+
+	var exts []byte
+	exts = ext(exts, 0x0a0a, nil)
+	exts = ext(exts, tlsExtServerName, sni)
+	exts = ext(exts, tlsExtSupportedGroups, []byte{0, 6, 0x1a, 0x1a, 0x00, 0x1d, 0x00, 0x17})
+	exts = ext(exts, tlsExtECPointFormats, []byte{1, 0})
+	exts = ext(exts, 35, nil)
+	if n > 0 {
+		exts = ext(exts, 21, make([]byte, n))
+	}
+
+	var body []byte
+	body = u16(body, 0x0303)
+	body = append(body, make([]byte, 32)...)
+	body = append(body, 0)
+	body = append(body, 0, 6, 0x0a, 0x0a, 0x13, 0x01, 0xc0, 0x2f)
+	body = append(body, 1, 0)
+	body = u16(body, len(exts))
+	body = append(body, exts...)
+
+	msg := []byte{0x01, byte(len(body) >> 16), byte(len(body) >> 8), byte(len(body))}
+	msg = append(msg, body...)
+
+	record := []byte{0x16, 0x03, 0x01}
+	record = u16(record, len(msg))
+	return append(record, msg...)
+}
+
+func TestClientHelloJA3(t *testing.T) {
+	hello, err := ParseClientHello(sampleClientHello("example.com")[5:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hello.ServerName != "example.com" || hello.Version != 0x0303 {
+		t.Fatalf("unexpected ClientHello %+v", hello)
+	}
+	if s := hello.JA3(); s != sampleJA3 {
+		t.Fatalf("JA3 is %q, want %q", s, sampleJA3)
+	}
+	if h := hello.JA3Hash(); h != sampleJA3Hash {
+		t.Fatalf("JA3 hash is %q, want %q", h, sampleJA3Hash)
+	}
+}
+
+func TestSniffClientHello(t *testing.T) {
+	record := sampleClientHello("example.com")
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	go func() {
+		c2.Write(record)
+		c2.Close()
+	}()
+
+	ctx, conn := SniffClientHello(context.Background(), c1)
+	if ja3 := ctxvalue.JA3FromContext(ctx); ja3 != sampleJA3Hash {
+		t.Fatalf("JA3 is %q", ja3)
+	}
+	if sni := ctxvalue.SNIFromContext(ctx); sni != "example.com" {
+		t.Fatalf("SNI is %q", sni)
+	}
+
+	// the peek is non-destructive.
+	b, _ := io.ReadAll(conn)
+	if !bytes.Equal(b, record) {
+		t.Fatalf("the ClientHello is not replayed, got %d bytes", len(b))
+	}
+
+	// a real TLS client has a fingerprint.
+	ctx, _ = SniffClientHello(context.Background(), clientHelloConn(t, "example.com"))
+	if ja3 := ctxvalue.JA3FromContext(ctx); len(ja3) != 32 {
+		t.Fatalf("unexpected JA3 of the TLS client %q", ja3)
+	}
+}
+
+func TestSniffClientHelloLarge(t *testing.T) {
+	record := paddedClientHello("example.com", 6000)
+	if len(record) <= 4096 {
+		t.Fatalf("the record is not large, %d bytes", len(record))
+	}
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	go func() {
+		c2.Write(record)
+		c2.Close()
+	}()
+
+	// the pipe is wrapped by a small PeekConn already.
+	ctx, conn := SniffClientHello(context.Background(), NewPeekConn(c1))
+	if sni := ctxvalue.SNIFromContext(ctx); sni != "example.com" {
+		t.Fatalf("SNI of the large ClientHello is %q", sni)
+	}
+	if ja3 := ctxvalue.JA3FromContext(ctx); len(ja3) != 32 {
+		t.Fatalf("unexpected JA3 %q", ja3)
+	}
+	b, _ := io.ReadAll(conn)
+	if !bytes.Equal(b, record) {
+		t.Fatalf("the ClientHello is not replayed, got %d bytes", len(b))
+	}
+}
+
+func TestSniffSNITimeout(t *testing.T) {
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+
+	// the silent client does not pin the goroutine beyond the deadline of ctx.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		SniffSNI(ctx, c1)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the sniffing is not bounded by the deadline")
+	}
+
+	// the deadline is cleared afterwards.
+	go c2.Write([]byte("x"))
+	if _, err := c1.Read(make([]byte, 1)); err != nil {
+		t.Fatalf("unexpected read error %v", err)
+	}
+}
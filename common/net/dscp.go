@@ -0,0 +1,42 @@
+package net
+
+import (
+	"net"
+	"syscall"
+)
+
+// SetDSCP sets the DSCP value of the IP header (IPv4 TOS or IPv6 traffic class) for conn.
+// It is a no-op if conn is not a socket or the platform is unsupported.
+func SetDSCP(conn net.Conn, dscp int) error {
+	if dscp <= 0 {
+		return nil
+	}
+
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return nil
+	}
+	rc, err := sc.SyscallConn()
+	if err != nil {
+		return err
+	}
+	return DSCPControl(dscp)("", "", rc)
+}
+
+// DSCPControl returns a control function for net.Dialer or net.ListenConfig
+// which sets the DSCP value on the socket before connecting.
+func DSCPControl(dscp int) func(network, address string, c syscall.RawConn) error {
+	return func(network, address string, c syscall.RawConn) error {
+		if dscp <= 0 {
+			return nil
+		}
+
+		var serr error
+		if err := c.Control(func(fd uintptr) {
+			serr = setDSCP(fd, dscp)
+		}); err != nil {
+			return err
+		}
+		return serr
+	}
+}
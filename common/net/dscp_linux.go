@@ -0,0 +1,17 @@
+package net
+
+import (
+	"syscall"
+)
+
+func setDSCP(fd uintptr, dscp int) error {
+	tos := (dscp & 0x3f) << 2
+
+	err4 := syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_TOS, tos)
+	err6 := syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6, syscall.IPV6_TCLASS, tos)
+	// the socket is either IPv4 or IPv6, only one of them needs to succeed.
+	if err4 != nil && err6 != nil {
+		return err4
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package net
+
+import (
+	"net"
+	"syscall"
+	"testing"
+)
+
+func getTOS(t *testing.T, conn net.Conn) int {
+	rc, err := conn.(syscall.Conn).SyscallConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tos int
+	var serr error
+	rc.Control(func(fd uintptr) {
+		tos, serr = syscall.GetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_TOS)
+	})
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	return tos
+}
+
+func TestSetDSCP(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	conn, err := net.Dial("tcp4", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// EF (Expedited Forwarding)
+	if err := SetDSCP(conn, 46); err != nil {
+		t.Fatal(err)
+	}
+	if tos := getTOS(t, conn); tos != 46<<2 {
+		t.Fatalf("TOS is %#x, want %#x", tos, 46<<2)
+	}
+}
+
+func TestDSCPControl(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	d := net.Dialer{Control: DSCPControl(10)}
+	conn, err := d.Dial("tcp4", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if tos := getTOS(t, conn); tos != 10<<2 {
+		t.Fatalf("TOS is %#x, want %#x", tos, 10<<2)
+	}
+}
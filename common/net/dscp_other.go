@@ -0,0 +1,7 @@
+//go:build !linux
+
+package net
+
+func setDSCP(fd uintptr, dscp int) error {
+	return nil
+}
@@ -0,0 +1,26 @@
+//go:build !linux
+
+package net
+
+import (
+	"net"
+	"testing"
+)
+
+func TestSetDSCPNoop(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if err := SetDSCP(conn, 46); err != nil {
+		t.Fatalf("unsupported platform should be a no-op: %v", err)
+	}
+}
@@ -0,0 +1,19 @@
+package net
+
+import (
+	"net"
+	"testing"
+)
+
+func TestSetDSCPNotSocket(t *testing.T) {
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+
+	if err := SetDSCP(c1, 46); err != nil {
+		t.Fatalf("non-socket conn should be ignored: %v", err)
+	}
+	if err := SetDSCP(c1, 0); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,127 @@
+package net
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	ErrIdleTimeout = errors.New("idle timeout")
+	ErrMaxLifetime = errors.New("max lifetime exceeded")
+)
+
+type idleConn struct {
+	net.Conn
+	timeout    time.Duration
+	lifetime   time.Duration
+	created    time.Time
+	lastActive atomic.Int64
+	timer      *time.Timer
+	// mu guards the timer, which may fire before it is assigned.
+	mu sync.Mutex
+	// reason is the error the connection is closed with by the timer.
+	reason    atomic.Pointer[error]
+	closeOnce sync.Once
+}
+
+// IdleTimeoutConn wraps c so that it will be closed if no data is read or written
+// in either direction for the timeout duration.
+// After that, Read and Write return ErrIdleTimeout.
+func IdleTimeoutConn(c net.Conn, timeout time.Duration) net.Conn {
+	return DeadlineConn(c, timeout, 0)
+}
+
+// DeadlineConn is like IdleTimeoutConn, the connection is also closed after the lifetime regardless of the activity,
+// so the long-lived tunnels are recycled, e.g. for rebalancing and the certificate refresh.
+// Whichever cap fires first closes the connection, then Read and Write return ErrIdleTimeout or ErrMaxLifetime.
+// A zero cap is disabled.
+func DeadlineConn(c net.Conn, idle, lifetime time.Duration) net.Conn {
+	if idle <= 0 && lifetime <= 0 {
+		return c
+	}
+
+	conn := &idleConn{
+		Conn:     c,
+		timeout:  idle,
+		lifetime: lifetime,
+		created:  time.Now(),
+	}
+	conn.lastActive.Store(conn.created.UnixNano())
+	conn.mu.Lock()
+	conn.timer = time.AfterFunc(conn.next(conn.created), conn.check)
+	conn.mu.Unlock()
+	return conn
+}
+
+// next returns the duration until the earliest cap is due.
+func (c *idleConn) next(now time.Time) time.Duration {
+	var d time.Duration
+	if c.timeout > 0 {
+		d = c.timeout - now.Sub(time.Unix(0, c.lastActive.Load()))
+	}
+	if c.lifetime > 0 {
+		if v := c.lifetime - now.Sub(c.created); c.timeout <= 0 || v < d {
+			d = v
+		}
+	}
+	return d
+}
+
+func (c *idleConn) check() {
+	now := time.Now()
+	if c.lifetime > 0 && now.Sub(c.created) >= c.lifetime {
+		c.closeWith(ErrMaxLifetime)
+		return
+	}
+	if c.timeout > 0 && now.Sub(time.Unix(0, c.lastActive.Load())) >= c.timeout {
+		c.closeWith(ErrIdleTimeout)
+		return
+	}
+	c.mu.Lock()
+	c.timer.Reset(c.next(now))
+	c.mu.Unlock()
+}
+
+func (c *idleConn) closeWith(err error) {
+	c.reason.CompareAndSwap(nil, &err)
+	c.Close()
+}
+
+func (c *idleConn) Read(b []byte) (n int, err error) {
+	n, err = c.Conn.Read(b)
+	if n > 0 {
+		c.lastActive.Store(time.Now().UnixNano())
+	}
+	if reason := c.reason.Load(); err != nil && reason != nil {
+		err = *reason
+	}
+	return
+}
+
+func (c *idleConn) Write(b []byte) (n int, err error) {
+	n, err = c.Conn.Write(b)
+	if n > 0 {
+		c.lastActive.Store(time.Now().UnixNano())
+	}
+	if reason := c.reason.Load(); err != nil && reason != nil {
+		err = *reason
+	}
+	return
+}
+
+func (c *idleConn) Close() (err error) {
+	c.closeOnce.Do(func() {
+		c.mu.Lock()
+		c.timer.Stop()
+		c.mu.Unlock()
+		err = c.Conn.Close()
+	})
+	return
+}
+
+func (c *idleConn) CloseWrite() error {
+	return CloseWrite(c.Conn)
+}
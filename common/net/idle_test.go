@@ -0,0 +1,167 @@
+package net
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestIdleTimeoutConnClosesIdle(t *testing.T) {
+	c1, c2 := net.Pipe()
+	defer c2.Close()
+
+	conn := IdleTimeoutConn(c1, 50*time.Millisecond)
+	start := time.Now()
+	_, err := conn.Read(make([]byte, 1))
+	if !errors.Is(err, ErrIdleTimeout) {
+		t.Fatalf("expected ErrIdleTimeout, got %v", err)
+	}
+	if d := time.Since(start); d < 50*time.Millisecond {
+		t.Fatalf("closed too early after %s", d)
+	}
+	if _, err := conn.Write([]byte("x")); !errors.Is(err, ErrIdleTimeout) {
+		t.Fatalf("expected ErrIdleTimeout on write, got %v", err)
+	}
+}
+
+func TestIdleTimeoutConnActiveKeepsOpen(t *testing.T) {
+	c1, c2 := net.Pipe()
+	defer c2.Close()
+
+	conn := IdleTimeoutConn(c1, 100*time.Millisecond)
+	defer conn.Close()
+
+	go func() {
+		b := make([]byte, 1)
+		for {
+			if _, err := c2.Read(b); err != nil {
+				return
+			}
+		}
+	}()
+
+	// the traffic spans several timeouts.
+	for i := 0; i < 10; i++ {
+		if _, err := conn.Write([]byte("x")); err != nil {
+			t.Fatalf("write %d: %v", i, err)
+		}
+		time.Sleep(30 * time.Millisecond)
+	}
+}
+
+func TestIdleTimeoutConnConcurrent(t *testing.T) {
+	c1, c2 := net.Pipe()
+	conn := IdleTimeoutConn(c1, 50*time.Millisecond)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		b := make([]byte, 16)
+		for {
+			if _, err := conn.Read(b); err != nil {
+				return
+			}
+		}
+	}()
+	go func() {
+		for i := 0; i < 100; i++ {
+			if _, err := c2.Write([]byte("ping")); err != nil {
+				return
+			}
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("idle conn is not closed")
+	}
+	c2.Close()
+}
+
+func TestIdleTimeoutConnZero(t *testing.T) {
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+
+	if conn := IdleTimeoutConn(c1, 0); conn != c1 {
+		t.Fatal("zero timeout should not wrap the conn")
+	}
+}
+
+// drain reads c until it fails.
+func drain(c net.Conn) {
+	b := make([]byte, 16)
+	for {
+		if _, err := c.Read(b); err != nil {
+			return
+		}
+	}
+}
+
+func TestDeadlineConnIdle(t *testing.T) {
+	c1, c2 := net.Pipe()
+	defer c2.Close()
+
+	// the quiet conn is closed by the idle cap long before the lifetime.
+	conn := DeadlineConn(c1, 50*time.Millisecond, time.Hour)
+	start := time.Now()
+	_, err := conn.Read(make([]byte, 1))
+	if !errors.Is(err, ErrIdleTimeout) {
+		t.Fatalf("expected ErrIdleTimeout, got %v", err)
+	}
+	if d := time.Since(start); d < 50*time.Millisecond || d > time.Second {
+		t.Fatalf("closed after %s", d)
+	}
+}
+
+func TestDeadlineConnLifetime(t *testing.T) {
+	c1, c2 := net.Pipe()
+	defer c2.Close()
+	go drain(c2)
+
+	// the busy conn is never idle, it is closed by the lifetime cap.
+	conn := DeadlineConn(c1, 100*time.Millisecond, 200*time.Millisecond)
+	start := time.Now()
+	var err error
+	for time.Since(start) < 2*time.Second {
+		if _, err = conn.Write([]byte("x")); err != nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !errors.Is(err, ErrMaxLifetime) {
+		t.Fatalf("expected ErrMaxLifetime, got %v", err)
+	}
+	if d := time.Since(start); d < 200*time.Millisecond {
+		t.Fatalf("closed too early after %s", d)
+	}
+	if _, err := conn.Read(make([]byte, 1)); !errors.Is(err, ErrMaxLifetime) {
+		t.Fatalf("expected ErrMaxLifetime on read, got %v", err)
+	}
+}
+
+func TestDeadlineConnLifetimeOnly(t *testing.T) {
+	c1, c2 := net.Pipe()
+	defer c2.Close()
+
+	conn := DeadlineConn(c1, 0, 50*time.Millisecond)
+	if _, err := conn.Read(make([]byte, 1)); !errors.Is(err, ErrMaxLifetime) {
+		t.Fatalf("expected ErrMaxLifetime, got %v", err)
+	}
+
+	// the conn closed by the caller is not reported as expired.
+	c3, c4 := net.Pipe()
+	defer c4.Close()
+	conn = DeadlineConn(c3, 50*time.Millisecond, 50*time.Millisecond)
+	conn.Close()
+	time.Sleep(100 * time.Millisecond)
+	if _, err := conn.Read(make([]byte, 1)); errors.Is(err, ErrMaxLifetime) || errors.Is(err, ErrIdleTimeout) {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if conn := DeadlineConn(c1, 0, 0); conn != c1 {
+		t.Fatal("zero caps should not wrap the conn")
+	}
+}
@@ -0,0 +1,220 @@
+package net
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+)
+
+const (
+	defaultMigrateBufferSize = 64 * 1024
+	defaultMaxMigrations     = 3
+)
+
+var (
+	ErrMigrationExhausted = errors.New("connection migration exhausted")
+)
+
+// DialFunc dials a new upstream connection, it should select another node on each call.
+type DialFunc func(ctx context.Context) (net.Conn, error)
+
+type MigrateOptions struct {
+	// BufferSize is the size of the replay window of the written bytes,
+	// the connection can not be migrated once more unacknowledged bytes are written.
+	BufferSize int
+	// MaxMigrations is the maximum number of re-establishments.
+	MaxMigrations int
+}
+
+type MigrateOption func(opts *MigrateOptions)
+
+func BufferSizeMigrateOption(size int) MigrateOption {
+	return func(opts *MigrateOptions) {
+		opts.BufferSize = size
+	}
+}
+
+func MaxMigrationsMigrateOption(n int) MigrateOption {
+	return func(opts *MigrateOptions) {
+		opts.MaxMigrations = n
+	}
+}
+
+// Acknowledger is implemented by the migratable connection,
+// the protocol-aware caller acknowledges the bytes confirmed by the peer, which need not be replayed.
+type Acknowledger interface {
+	Ack(n int)
+}
+
+type migratableConn struct {
+	net.Conn
+	ctx     context.Context
+	dial    DialFunc
+	options MigrateOptions
+	buf     []byte
+	// dropped is the number of the unacknowledged bytes out of the replay window.
+	dropped int
+	gen     int
+	closed  bool
+	mu      sync.Mutex
+	// wmu serializes the writes and the migrations, so the buffer is not changed during a replay.
+	wmu        sync.Mutex
+	migrations int
+}
+
+// MigratableConn dials an upstream connection with dial and returns a connection which,
+// when the upstream drops, transparently re-establishes the upstream by calling dial again
+// and replays the unacknowledged bytes in the buffered window. If the unacknowledged bytes
+// overflow the window, the connection fails with ErrMigrationExhausted instead of replaying a partial stream.
+// It is only suitable for the protocols which tolerate the reconnection, e.g. the idempotent streams.
+func MigratableConn(ctx context.Context, dial DialFunc, opts ...MigrateOption) (net.Conn, error) {
+	options := MigrateOptions{
+		BufferSize:    defaultMigrateBufferSize,
+		MaxMigrations: defaultMaxMigrations,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+
+	conn, err := dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &migratableConn{
+		Conn:    conn,
+		ctx:     ctx,
+		dial:    dial,
+		options: options,
+	}, nil
+}
+
+func (c *migratableConn) current() (net.Conn, int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.Conn, c.gen
+}
+
+// migrate replaces the upstream of generation gen, it is a no-op if it has been replaced by others.
+// The caller must hold wmu. The new upstream is dialed without holding mu, so the reads and Close are not blocked.
+func (c *migratableConn) migrate(gen int) error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return net.ErrClosed
+	}
+	if c.gen != gen {
+		c.mu.Unlock()
+		return nil
+	}
+	if c.dropped > 0 {
+		// the stream can not be replayed in full.
+		c.mu.Unlock()
+		return ErrMigrationExhausted
+	}
+	old, buf := c.Conn, c.buf
+	c.mu.Unlock()
+
+	old.Close()
+	for c.migrations < c.options.MaxMigrations {
+		c.migrations++
+
+		conn, err := c.dial(c.ctx)
+		if err != nil {
+			continue
+		}
+		if len(buf) > 0 {
+			if _, err := conn.Write(buf); err != nil {
+				conn.Close()
+				continue
+			}
+		}
+
+		c.mu.Lock()
+		if c.closed {
+			c.mu.Unlock()
+			conn.Close()
+			return net.ErrClosed
+		}
+		c.Conn = conn
+		c.gen++
+		c.mu.Unlock()
+		return nil
+	}
+	return ErrMigrationExhausted
+}
+
+func (c *migratableConn) Read(b []byte) (int, error) {
+	for {
+		conn, gen := c.current()
+		n, err := conn.Read(b)
+		if err == nil || n > 0 || errors.Is(err, io.EOF) {
+			return n, err
+		}
+
+		c.wmu.Lock()
+		e := c.migrate(gen)
+		c.wmu.Unlock()
+		if e != nil {
+			return n, migrateError(err, e)
+		}
+	}
+}
+
+func (c *migratableConn) Write(b []byte) (int, error) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	c.mu.Lock()
+	if !c.closed {
+		c.buf = append(c.buf, b...)
+		if over := len(c.buf) - c.options.BufferSize; over > 0 {
+			c.buf = c.buf[over:]
+			c.dropped += over
+		}
+	}
+	conn, gen := c.Conn, c.gen
+	c.mu.Unlock()
+
+	n, err := conn.Write(b)
+	if err == nil {
+		return n, nil
+	}
+	// the whole buffer including b is replayed to the new upstream.
+	if e := c.migrate(gen); e != nil {
+		return n, migrateError(err, e)
+	}
+	return len(b), nil
+}
+
+// migrateError returns the error of the failed migration, or err if the connection is closed.
+func migrateError(err, e error) error {
+	if errors.Is(e, ErrMigrationExhausted) {
+		return e
+	}
+	return err
+}
+
+func (c *migratableConn) Ack(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n = max(n, 0)
+	// the dropped bytes are the oldest.
+	d := min(n, c.dropped)
+	c.dropped -= d
+	n = min(n-d, len(c.buf))
+	c.buf = c.buf[n:]
+}
+
+func (c *migratableConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closed = true
+	c.buf = nil
+	return c.Conn.Close()
+}
@@ -0,0 +1,292 @@
+package net
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+var errReset = errors.New("connection reset")
+
+// nodeConn is the upstream connection to a node, it records the written bytes
+// and fails all the operations after drop.
+type nodeConn struct {
+	net.Conn
+	mu      sync.Mutex
+	data    bytes.Buffer
+	resp    []byte
+	dropped chan struct{}
+	once    sync.Once
+}
+
+func newNodeConn(resp string) *nodeConn {
+	return &nodeConn{resp: []byte(resp), dropped: make(chan struct{})}
+}
+
+func (c *nodeConn) Read(b []byte) (int, error) {
+	c.mu.Lock()
+	if len(c.resp) > 0 {
+		n := copy(b, c.resp)
+		c.resp = c.resp[n:]
+		c.mu.Unlock()
+		return n, nil
+	}
+	c.mu.Unlock()
+
+	<-c.dropped
+	return 0, errReset
+}
+
+func (c *nodeConn) Write(b []byte) (int, error) {
+	select {
+	case <-c.dropped:
+		return 0, errReset
+	default:
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.data.Write(b)
+}
+
+func (c *nodeConn) String() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.data.String()
+}
+
+func (c *nodeConn) drop() {
+	c.once.Do(func() { close(c.dropped) })
+}
+
+func (c *nodeConn) Close() error {
+	c.drop()
+	return nil
+}
+
+// nodeDialer dials the nodes in order, it fails when the nodes are used up.
+type nodeDialer struct {
+	mu    sync.Mutex
+	nodes []*nodeConn
+	calls int
+}
+
+func (d *nodeDialer) dial(ctx context.Context) (net.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.calls++
+	if len(d.nodes) == 0 {
+		return nil, errors.New("no node available")
+	}
+	conn := d.nodes[0]
+	d.nodes = d.nodes[1:]
+	return conn, nil
+}
+
+func TestMigratableConnWrite(t *testing.T) {
+	a, b := newNodeConn(""), newNodeConn("")
+	d := &nodeDialer{nodes: []*nodeConn{a, b}}
+
+	conn, err := MigratableConn(context.Background(), d.dial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	// the upstream drops mid-stream, the session continues on the second node.
+	a.drop()
+	if n, err := conn.Write([]byte(" world")); err != nil || n != 6 {
+		t.Fatalf("write after the drop: %d %v", n, err)
+	}
+	if _, err := conn.Write([]byte("!")); err != nil {
+		t.Fatal(err)
+	}
+
+	if s := a.String(); s != "hello" {
+		t.Fatalf("unexpected data on the first node %q", s)
+	}
+	if s := b.String(); s != "hello world!" {
+		t.Fatalf("the buffered bytes should be replayed to the second node, got %q", s)
+	}
+	if d.calls != 2 {
+		t.Fatalf("expected 2 dials, got %d", d.calls)
+	}
+}
+
+func TestMigratableConnRead(t *testing.T) {
+	a, b := newNodeConn("hello"), newNodeConn(" world")
+	d := &nodeDialer{nodes: []*nodeConn{a, b}}
+
+	conn, err := MigratableConn(context.Background(), d.dial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("request")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 16)
+	n, err := conn.Read(buf)
+	if err != nil || string(buf[:n]) != "hello" {
+		t.Fatalf("unexpected read %q %v", buf[:n], err)
+	}
+
+	a.drop()
+	n, err = conn.Read(buf)
+	if err != nil || string(buf[:n]) != " world" {
+		t.Fatalf("unexpected read after the drop %q %v", buf[:n], err)
+	}
+	if s := b.String(); s != "request" {
+		t.Fatalf("the request should be replayed to the second node, got %q", s)
+	}
+}
+
+func TestMigratableConnAck(t *testing.T) {
+	a, b := newNodeConn(""), newNodeConn("")
+	d := &nodeDialer{nodes: []*nodeConn{a, b}}
+
+	conn, err := MigratableConn(context.Background(), d.dial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	conn.Write([]byte("hello"))
+	// the acknowledged bytes are not replayed.
+	conn.(Acknowledger).Ack(3)
+
+	a.drop()
+	if _, err := conn.Write([]byte(" world")); err != nil {
+		t.Fatal(err)
+	}
+	if s := b.String(); s != "lo world" {
+		t.Fatalf("unexpected replay %q", s)
+	}
+}
+
+func TestMigratableConnOverflow(t *testing.T) {
+	a, b, c := newNodeConn(""), newNodeConn(""), newNodeConn("")
+	d := &nodeDialer{nodes: []*nodeConn{a, b, c}}
+
+	conn, err := MigratableConn(context.Background(), d.dial, BufferSizeMigrateOption(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	// the first byte is out of the window, the stream can not be replayed in full.
+	a.drop()
+	if _, err := conn.Write([]byte("!")); !errors.Is(err, ErrMigrationExhausted) {
+		t.Fatalf("expected ErrMigrationExhausted, got %v", err)
+	}
+	if d.calls != 1 || b.String() != "" {
+		t.Fatalf("the partial stream should not be replayed, %d dials, %q", d.calls, b.String())
+	}
+}
+
+func TestMigratableConnOverflowAck(t *testing.T) {
+	a, b := newNodeConn(""), newNodeConn("")
+	d := &nodeDialer{nodes: []*nodeConn{a, b}}
+
+	conn, err := MigratableConn(context.Background(), d.dial, BufferSizeMigrateOption(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	conn.Write([]byte("hello"))
+	// the dropped byte is acknowledged, the window is complete again.
+	conn.(Acknowledger).Ack(2)
+
+	a.drop()
+	if _, err := conn.Write([]byte("!")); err != nil {
+		t.Fatal(err)
+	}
+	if s := b.String(); s != "llo!" {
+		t.Fatalf("unexpected replay %q", s)
+	}
+}
+
+func TestMigratableConnExhausted(t *testing.T) {
+	a := newNodeConn("")
+	d := &nodeDialer{nodes: []*nodeConn{a}}
+
+	conn, err := MigratableConn(context.Background(), d.dial, MaxMigrationsMigrateOption(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	a.drop()
+	if _, err := conn.Write([]byte("hello")); !errors.Is(err, ErrMigrationExhausted) {
+		t.Fatalf("expected ErrMigrationExhausted, got %v", err)
+	}
+	// the initial dial and the 2 migrations.
+	if d.calls != 3 {
+		t.Fatalf("expected 3 dials, got %d", d.calls)
+	}
+}
+
+func TestMigratableConnCloseWhileDialing(t *testing.T) {
+	a, b := newNodeConn(""), newNodeConn("")
+	dialing := make(chan struct{})
+	release := make(chan struct{})
+	first := true
+	dial := func(ctx context.Context) (net.Conn, error) {
+		if first {
+			first = false
+			return a, nil
+		}
+		close(dialing)
+		<-release
+		return b, nil
+	}
+
+	conn, err := MigratableConn(context.Background(), dial)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a.drop()
+	errc := make(chan error, 1)
+	go func() {
+		_, err := conn.Write([]byte("hello"))
+		errc <- err
+	}()
+	<-dialing
+
+	// the migration does not block Close.
+	closed := make(chan struct{})
+	go func() {
+		conn.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Close is blocked by the dial")
+	}
+
+	close(release)
+	if err := <-errc; err == nil {
+		t.Fatal("expected the write to fail after Close")
+	}
+	select {
+	case <-b.dropped:
+	default:
+		t.Fatal("the new upstream should be closed")
+	}
+}
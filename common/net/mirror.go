@@ -0,0 +1,122 @@
+package net
+
+import (
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/go-gost/core/common/bufpool"
+)
+
+const (
+	defaultMirrorQueueSize = 64
+)
+
+type mirrorConn struct {
+	net.Conn
+	shadow    net.Conn
+	dial      func() (net.Conn, error)
+	queue     chan []byte
+	timeout   time.Duration
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// MirrorConn wraps c and copies the data written to c to the shadow connection in best-effort,
+// the data read from shadow is discarded.
+// The shadow never blocks c: data is dropped if the shadow is too slow,
+// and the shadow is closed on the first error or if a write exceeds the timeout.
+func MirrorConn(c net.Conn, shadow net.Conn, timeout time.Duration) net.Conn {
+	if shadow == nil {
+		return c
+	}
+	return newMirrorConn(c, shadow, nil, timeout)
+}
+
+// MirrorDialConn is like MirrorConn, the shadow connection is created by dial asynchronously,
+// so c is never delayed by the shadow. The data written before the shadow is connected is queued,
+// and dropped if dial fails.
+func MirrorDialConn(c net.Conn, dial func() (net.Conn, error), timeout time.Duration) net.Conn {
+	if dial == nil {
+		return c
+	}
+	return newMirrorConn(c, nil, dial, timeout)
+}
+
+func newMirrorConn(c net.Conn, shadow net.Conn, dial func() (net.Conn, error), timeout time.Duration) net.Conn {
+	mc := &mirrorConn{
+		Conn:    c,
+		shadow:  shadow,
+		dial:    dial,
+		queue:   make(chan []byte, defaultMirrorQueueSize),
+		timeout: timeout,
+		closed:  make(chan struct{}),
+	}
+	go mc.writeLoop()
+	return mc
+}
+
+func (c *mirrorConn) Write(b []byte) (n int, err error) {
+	n, err = c.Conn.Write(b)
+	if n > 0 {
+		buf := bufpool.Get(n)
+		copy(buf, b[:n])
+		select {
+		case c.queue <- buf:
+		default:
+			bufpool.Put(buf)
+		}
+	}
+	return
+}
+
+func (c *mirrorConn) writeLoop() {
+	shadow := c.shadow
+	if shadow == nil {
+		var err error
+		if shadow, err = c.dial(); err != nil {
+			return
+		}
+	}
+	defer shadow.Close()
+	go io.Copy(io.Discard, shadow)
+
+	for {
+		select {
+		case b := <-c.queue:
+			if !c.writeShadow(shadow, b) {
+				return
+			}
+		case <-c.closed:
+			// flush the data queued before c is closed.
+			for {
+				select {
+				case b := <-c.queue:
+					if !c.writeShadow(shadow, b) {
+						return
+					}
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (c *mirrorConn) writeShadow(shadow net.Conn, b []byte) bool {
+	defer bufpool.Put(b)
+
+	if c.timeout > 0 {
+		shadow.SetWriteDeadline(time.Now().Add(c.timeout))
+	}
+	_, err := shadow.Write(b)
+	return err == nil
+}
+
+func (c *mirrorConn) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+	})
+	return c.Conn.Close()
+}
@@ -0,0 +1,151 @@
+package net
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestMirrorConn(t *testing.T) {
+	client, upstream := net.Pipe()
+	shadowLocal, shadowRemote := net.Pipe()
+	defer upstream.Close()
+
+	conn := MirrorConn(client, shadowLocal, time.Second)
+
+	shadowData := make(chan []byte, 1)
+	go func() {
+		b, _ := io.ReadAll(shadowRemote)
+		shadowData <- b
+	}()
+	go func() {
+		// the upstream echoes the request back.
+		b := make([]byte, 5)
+		io.ReadFull(upstream, b)
+		upstream.Write(b)
+	}()
+
+	if _, err := conn.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	b := make([]byte, 5)
+	if _, err := io.ReadFull(conn, b); err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "hello" {
+		t.Fatalf("primary response is changed: %q", b)
+	}
+
+	// wait for the mirrored data before closing.
+	time.Sleep(50 * time.Millisecond)
+	conn.Close()
+
+	select {
+	case b := <-shadowData:
+		if !bytes.Equal(b, []byte("hello")) {
+			t.Fatalf("shadow received %q", b)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("shadow is not closed")
+	}
+}
+
+func TestMirrorConnSlowShadow(t *testing.T) {
+	client, upstream := net.Pipe()
+	// the shadow never reads.
+	shadowLocal, shadowRemote := net.Pipe()
+	defer shadowRemote.Close()
+	defer upstream.Close()
+
+	conn := MirrorConn(client, shadowLocal, 10*time.Millisecond)
+	defer conn.Close()
+
+	go io.Copy(io.Discard, upstream)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 2*defaultMirrorQueueSize; i++ {
+			if _, err := conn.Write([]byte("data")); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("slow shadow blocks the primary")
+	}
+}
+
+func TestMirrorConnNoShadow(t *testing.T) {
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+
+	if conn := MirrorConn(c1, nil, 0); conn != c1 {
+		t.Fatal("nil shadow should not wrap the conn")
+	}
+}
+
+func TestMirrorDialConn(t *testing.T) {
+	client, upstream := net.Pipe()
+	defer upstream.Close()
+	go io.Copy(io.Discard, upstream)
+
+	// the shadow is connected after the data is written and the conn is closed.
+	shadowLocal, shadowRemote := net.Pipe()
+	gate := make(chan struct{})
+	conn := MirrorDialConn(client, func() (net.Conn, error) {
+		<-gate
+		return shadowLocal, nil
+	}, time.Second)
+
+	shadowData := make(chan []byte, 1)
+	go func() {
+		b, _ := io.ReadAll(shadowRemote)
+		shadowData <- b
+	}()
+
+	for _, s := range []string{"hello", " world"} {
+		if _, err := conn.Write([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	conn.Close()
+	close(gate)
+
+	select {
+	case b := <-shadowData:
+		if string(b) != "hello world" {
+			t.Fatalf("shadow received %q", b)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("shadow is not closed")
+	}
+
+	if conn := MirrorDialConn(client, nil, 0); conn != client {
+		t.Fatal("nil dial should not wrap the conn")
+	}
+}
+
+func TestMirrorDialConnFailed(t *testing.T) {
+	client, upstream := net.Pipe()
+	defer upstream.Close()
+	go io.Copy(io.Discard, upstream)
+
+	conn := MirrorDialConn(client, func() (net.Conn, error) {
+		return nil, io.ErrClosedPipe
+	}, time.Second)
+	defer conn.Close()
+
+	// the primary is not affected by the failed shadow.
+	for i := 0; i < 2*defaultMirrorQueueSize; i++ {
+		if _, err := conn.Write([]byte("data")); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
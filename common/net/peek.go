@@ -0,0 +1,53 @@
+package net
+
+import (
+	"bufio"
+	"net"
+)
+
+// PeekConn is a connection whose leading bytes can be peeked without consuming them,
+// the peeked bytes are replayed by the subsequent reads.
+type PeekConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func NewPeekConn(c net.Conn) *PeekConn {
+	if pc, ok := c.(*PeekConn); ok {
+		return pc
+	}
+	return &PeekConn{
+		Conn: c,
+		r:    bufio.NewReader(c),
+	}
+}
+
+// NewPeekConnSize is like NewPeekConn, the returned connection can peek at least size bytes.
+// If c is a PeekConn with a smaller buffer, it is wrapped and its buffered bytes are replayed.
+func NewPeekConnSize(c net.Conn, size int) *PeekConn {
+	if pc, ok := c.(*PeekConn); ok && pc.r.Size() >= size {
+		return pc
+	}
+	return &PeekConn{
+		Conn: c,
+		r:    bufio.NewReaderSize(c, size),
+	}
+}
+
+// Peek returns the next n bytes without advancing the reader.
+func (c *PeekConn) Peek(n int) ([]byte, error) {
+	return c.r.Peek(n)
+}
+
+// Buffered returns the number of bytes that can be read from the buffer.
+func (c *PeekConn) Buffered() int {
+	return c.r.Buffered()
+}
+
+func (c *PeekConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+func (c *PeekConn) CloseWrite() error {
+	return CloseWrite(c.Conn)
+}
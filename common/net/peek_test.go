@@ -0,0 +1,32 @@
+package net
+
+import (
+	"io"
+	"net"
+	"testing"
+)
+
+func TestPeekConn(t *testing.T) {
+	c1, c2 := net.Pipe()
+	go func() {
+		c2.Write([]byte("hello world"))
+		c2.Close()
+	}()
+
+	pc := NewPeekConn(c1)
+	b, err := pc.Peek(5)
+	if err != nil || string(b) != "hello" {
+		t.Fatalf("peek: %q %v", b, err)
+	}
+	if NewPeekConn(pc) != pc {
+		t.Fatal("PeekConn should not be wrapped twice")
+	}
+
+	all, err := io.ReadAll(pc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(all) != "hello world" {
+		t.Fatalf("peeked bytes are not replayed: %q", all)
+	}
+}
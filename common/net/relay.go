@@ -0,0 +1,181 @@
+package net
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/go-gost/core/common/bufpool"
+	"github.com/go-gost/core/limiter/traffic"
+	"github.com/go-gost/core/observer/stats"
+)
+
+const (
+	relayBufferSize = 32 * 1024
+)
+
+type closeWriter interface {
+	CloseWrite() error
+}
+
+// CloseWrite shuts down the writing side of c if it supports half-close,
+// otherwise c is closed.
+func CloseWrite(c net.Conn) error {
+	if cw, ok := c.(closeWriter); ok {
+		return cw.CloseWrite()
+	}
+	return c.Close()
+}
+
+type RelayOptions struct {
+	// Stats accounts the relayed bytes, the bytes from a to b are input and the bytes from b to a are output.
+	Stats stats.Stats
+	// InLimiter limits the bytes from a to b.
+	InLimiter traffic.Limiter
+	// OutLimiter limits the bytes from b to a.
+	OutLimiter traffic.Limiter
+	BufferSize int
+	// Inflight is called with the number of bytes relayed in either direction as they are transferred,
+	// and with the negative total when the relay is done, e.g. to feed the in-flight bytes gauge of a node.
+	Inflight func(n int64)
+}
+
+type RelayOption func(opts *RelayOptions)
+
+func StatsRelayOption(stats stats.Stats) RelayOption {
+	return func(opts *RelayOptions) {
+		opts.Stats = stats
+	}
+}
+
+func LimiterRelayOption(in, out traffic.Limiter) RelayOption {
+	return func(opts *RelayOptions) {
+		opts.InLimiter = in
+		opts.OutLimiter = out
+	}
+}
+
+func InflightRelayOption(fn func(n int64)) RelayOption {
+	return func(opts *RelayOptions) {
+		opts.Inflight = fn
+	}
+}
+
+func BufferSizeRelayOption(size int) RelayOption {
+	return func(opts *RelayOptions) {
+		opts.BufferSize = size
+	}
+}
+
+// Relay copies data between a and b in both directions until both directions are done.
+// When one side reaches EOF, the writing side of the other side is closed (half-close),
+// and the reverse direction keeps flowing.
+// Both a and b are closed when Relay returns, the first non-EOF error is returned.
+func Relay(ctx context.Context, a, b net.Conn) error {
+	_, _, err := RelayWithStats(ctx, a, b)
+	return err
+}
+
+// RelayWithStats is like Relay, it also accounts the bytes into the stats and enforces
+// the traffic limiters in the same pass, so the connections need not be wrapped for them.
+// rx is the number of bytes relayed from a to b, tx is that from b to a,
+// they are accurate even if the relay is aborted by an error.
+func RelayWithStats(ctx context.Context, a, b net.Conn, opts ...RelayOption) (rx, tx int64, err error) {
+	var options RelayOptions
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+	if options.BufferSize <= 0 {
+		options.BufferSize = relayBufferSize
+	}
+
+	var wg sync.WaitGroup
+	errc := make(chan error, 2)
+
+	stop := context.AfterFunc(ctx, func() {
+		a.Close()
+		b.Close()
+	})
+	defer stop()
+
+	copyHalf := func(dst, src net.Conn, limiter traffic.Limiter, kind stats.Kind, n *int64) {
+		defer wg.Done()
+
+		err := copyBuffer(ctx, dst, src, options.BufferSize, limiter, func(k int) {
+			*n += int64(k)
+			if options.Stats != nil {
+				options.Stats.Add(kind, int64(k))
+			}
+			if options.Inflight != nil {
+				options.Inflight(int64(k))
+			}
+		})
+		// the connection may be closed by the other direction if it does not support half-close.
+		if err == nil || errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) {
+			CloseWrite(dst)
+			return
+		}
+		// abort both directions on error.
+		a.Close()
+		b.Close()
+		errc <- err
+	}
+
+	wg.Add(2)
+	go copyHalf(b, a, options.InLimiter, stats.KindInputBytes, &rx)
+	go copyHalf(a, b, options.OutLimiter, stats.KindOutputBytes, &tx)
+	wg.Wait()
+
+	if options.Inflight != nil {
+		options.Inflight(-(rx + tx))
+	}
+
+	a.Close()
+	b.Close()
+
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	close(errc)
+	err = <-errc
+	return
+}
+
+func copyBuffer(ctx context.Context, dst io.Writer, src io.Reader, bufferSize int, limiter traffic.Limiter, written func(n int)) error {
+	buf := bufpool.Get(bufferSize)
+	defer bufpool.Put(buf)
+
+	for {
+		nr, er := src.Read(buf)
+		for b := buf[:nr]; len(b) > 0; {
+			n := len(b)
+			if limiter != nil {
+				if n = limiter.Wait(ctx, n); n <= 0 {
+					if err := ctx.Err(); err != nil {
+						return err
+					}
+					n = 1
+				}
+			}
+
+			nw, ew := dst.Write(b[:n])
+			if nw > 0 {
+				written(nw)
+			}
+			if ew != nil {
+				return ew
+			}
+			if nw != n {
+				return io.ErrShortWrite
+			}
+			b = b[n:]
+		}
+		if er != nil {
+			return er
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package net
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-gost/core/observer/stats"
+)
+
+// tcpPair returns the two ends of a TCP connection.
+func tcpPair(t *testing.T) (net.Conn, net.Conn) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, _ := ln.Accept()
+		accepted <- conn
+	}()
+	c1, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c2 := <-accepted
+	if c2 == nil {
+		t.Fatal("accept failed")
+	}
+	t.Cleanup(func() {
+		c1.Close()
+		c2.Close()
+	})
+	return c1, c2
+}
+
+func TestRelayHalfClose(t *testing.T) {
+	client, a := tcpPair(t)
+	b, upstream := tcpPair(t)
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- Relay(context.Background(), a, b)
+	}()
+
+	if _, err := client.Write([]byte("request")); err != nil {
+		t.Fatal(err)
+	}
+	CloseWrite(client)
+
+	// the EOF of the client is forwarded to the upstream as CloseWrite.
+	upstream.SetReadDeadline(time.Now().Add(time.Second))
+	req, err := io.ReadAll(upstream)
+	if err != nil {
+		t.Fatalf("upstream does not get EOF: %v", err)
+	}
+	if string(req) != "request" {
+		t.Fatalf("upstream got %q", req)
+	}
+
+	// the reverse direction keeps flowing after the half-close.
+	if _, err := upstream.Write([]byte("response")); err != nil {
+		t.Fatal(err)
+	}
+	upstream.Close()
+
+	client.SetReadDeadline(time.Now().Add(time.Second))
+	resp, err := io.ReadAll(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(resp) != "response" {
+		t.Fatalf("client got %q", resp)
+	}
+
+	select {
+	case err := <-errc:
+		if err != nil {
+			t.Fatalf("relay: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("relay does not return")
+	}
+}
+
+func TestRelayContext(t *testing.T) {
+	_, a := tcpPair(t)
+	b, _ := tcpPair(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() {
+		errc <- Relay(ctx, a, b)
+	}()
+	cancel()
+
+	select {
+	case err := <-errc:
+		if err != context.Canceled {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("relay is not aborted by the context")
+	}
+}
+
+func TestCloseWrite(t *testing.T) {
+	c1, c2 := net.Pipe()
+	defer c2.Close()
+
+	// no half-close support, the conn is closed.
+	CloseWrite(c1)
+	if _, err := c1.Write([]byte("x")); err == nil {
+		t.Fatal("conn without half-close should be closed")
+	}
+}
+
+// fakeStats is a stats.Stats counting by kind.
+type fakeStats struct {
+	mu     sync.Mutex
+	counts map[stats.Kind]int64
+}
+
+func (s *fakeStats) Add(kind stats.Kind, n int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.counts == nil {
+		s.counts = make(map[stats.Kind]int64)
+	}
+	s.counts[kind] += n
+}
+
+func (s *fakeStats) Get(kind stats.Kind) uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return uint64(s.counts[kind])
+}
+
+func (s *fakeStats) IsUpdated() bool {
+	return true
+}
+
+func (s *fakeStats) Reset() {}
+
+// chunkLimiter is a traffic.Limiter allowing at most limit bytes per Wait and recording the granted bytes.
+type chunkLimiter struct {
+	limit   int
+	granted atomic.Int64
+}
+
+func (l *chunkLimiter) Wait(ctx context.Context, n int) int {
+	n = min(n, l.limit)
+	l.granted.Add(int64(n))
+	return n
+}
+
+func (l *chunkLimiter) Limit() int {
+	return l.limit
+}
+
+func (l *chunkLimiter) Set(n int) {}
+
+func TestRelayWithStats(t *testing.T) {
+	client, a := tcpPair(t)
+	b, upstream := tcpPair(t)
+
+	st := &fakeStats{}
+	in, out := &chunkLimiter{limit: 4}, &chunkLimiter{limit: 1 << 20}
+	var inflight atomic.Int64
+	type result struct {
+		rx, tx int64
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		rx, tx, err := RelayWithStats(context.Background(), a, b,
+			StatsRelayOption(st),
+			LimiterRelayOption(in, out),
+			InflightRelayOption(func(n int64) { inflight.Add(n) }),
+		)
+		done <- result{rx, tx, err}
+	}()
+
+	request := bytes.Repeat([]byte("q"), 1000)
+	response := bytes.Repeat([]byte("r"), 3000)
+
+	client.Write(request)
+	CloseWrite(client)
+
+	upstream.SetReadDeadline(time.Now().Add(time.Second))
+	req, err := io.ReadAll(upstream)
+	if err != nil || !bytes.Equal(req, request) {
+		t.Fatalf("upstream got %d bytes, %v", len(req), err)
+	}
+	upstream.Write(response)
+	upstream.Close()
+
+	client.SetReadDeadline(time.Now().Add(time.Second))
+	if resp, err := io.ReadAll(client); err != nil || !bytes.Equal(resp, response) {
+		t.Fatalf("client got %d bytes, %v", len(resp), err)
+	}
+
+	var r result
+	select {
+	case r = <-done:
+	case <-time.After(time.Second):
+		t.Fatal("relay does not return")
+	}
+	if r.err != nil || r.rx != int64(len(request)) || r.tx != int64(len(response)) {
+		t.Fatalf("unexpected result %+v", r)
+	}
+	if st.Get(stats.KindInputBytes) != uint64(len(request)) || st.Get(stats.KindOutputBytes) != uint64(len(response)) {
+		t.Fatalf("unexpected stats %v", st.counts)
+	}
+	// every byte goes through the limiter of its direction.
+	if in.granted.Load() != int64(len(request)) || out.granted.Load() != int64(len(response)) {
+		t.Fatalf("limiters granted %d and %d bytes", in.granted.Load(), out.granted.Load())
+	}
+	if n := inflight.Load(); n != 0 {
+		t.Fatalf("in-flight bytes should be released, got %d", n)
+	}
+}
+
+func TestRelayWithStatsAbort(t *testing.T) {
+	client, a := tcpPair(t)
+	b, upstream := tcpPair(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	type result struct {
+		rx, tx int64
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		rx, tx, err := RelayWithStats(ctx, a, b)
+		done <- result{rx, tx, err}
+	}()
+
+	client.Write([]byte("partial"))
+	buf := make([]byte, 7)
+	upstream.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := io.ReadFull(upstream, buf); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+
+	select {
+	case r := <-done:
+		if !errors.Is(r.err, context.Canceled) || r.rx != 7 || r.tx != 0 {
+			t.Fatalf("unexpected result of the aborted relay %+v", r)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("relay is not aborted")
+	}
+}
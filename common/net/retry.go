@@ -0,0 +1,71 @@
+package net
+
+import (
+	"context"
+	"errors"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/go-gost/core/common/backoff"
+)
+
+type retryDialer struct {
+	dialer  Dialer
+	retries int
+	backoff backoff.Backoff
+}
+
+// RetryDialer wraps the dialer d to retry a failed dial up to retries times,
+// waiting for the interval given by b between attempts, see backoff.Retry.
+func RetryDialer(d Dialer, retries int, b backoff.Backoff) Dialer {
+	return &retryDialer{
+		dialer:  d,
+		retries: retries,
+		backoff: b,
+	}
+}
+
+func (d *retryDialer) Dial(ctx context.Context, network, addr string) (conn net.Conn, err error) {
+	err = backoff.Retry(ctx, d.retries, d.backoff, func(ctx context.Context) (err error) {
+		conn, err = d.dialer.Dial(ctx, network, addr)
+		return
+	})
+	return
+}
+
+const (
+	defaultFastRetryWindow = 200 * time.Millisecond
+)
+
+type fastRetryDialer struct {
+	dialer Dialer
+	window time.Duration
+}
+
+// FastRetryDialer wraps the dialer d to retry once without backoff when the dial fails
+// immediately (within window) with a connection reset or refused, which hides the transient flaps of a node.
+// If d selects the node on each dial, the retry goes through a fresh selection.
+// A second failure is returned as is for the normal failover.
+func FastRetryDialer(d Dialer, window time.Duration) Dialer {
+	if window <= 0 {
+		window = defaultFastRetryWindow
+	}
+	return &fastRetryDialer{
+		dialer: d,
+		window: window,
+	}
+}
+
+func (d *fastRetryDialer) Dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	start := time.Now()
+	conn, err := d.dialer.Dial(ctx, network, addr)
+	if err == nil || ctx.Err() != nil || time.Since(start) > d.window || !isResetError(err) {
+		return conn, err
+	}
+	return d.dialer.Dial(ctx, network, addr)
+}
+
+func isResetError(err error) bool {
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED)
+}
@@ -0,0 +1,103 @@
+package net
+
+import (
+	"context"
+	"errors"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/go-gost/core/common/backoff"
+)
+
+// scriptDialer returns the errors in order, then the successful conn.
+type scriptDialer struct {
+	errs  []error
+	calls int
+}
+
+func (d *scriptDialer) Dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	d.calls++
+	if len(d.errs) > 0 {
+		err := d.errs[0]
+		d.errs = d.errs[1:]
+		if err != nil {
+			return nil, err
+		}
+	}
+	c1, c2 := net.Pipe()
+	c2.Close()
+	return c1, nil
+}
+
+func TestRetryDialer(t *testing.T) {
+	errFail := errors.New("fail")
+	d := &scriptDialer{errs: []error{errFail, errFail}}
+
+	conn, err := RetryDialer(d, 2, backoff.Constant(0)).Dial(context.Background(), "tcp", "example.com:80")
+	if err != nil || conn == nil {
+		t.Fatalf("expected success after retries, got %v", err)
+	}
+	conn.Close()
+	if d.calls != 3 {
+		t.Fatalf("expected 3 dials, got %d", d.calls)
+	}
+
+	d = &scriptDialer{errs: []error{errFail, errFail, errFail}}
+	if _, err := RetryDialer(d, 2, nil).Dial(context.Background(), "tcp", "example.com:80"); !errors.Is(err, errFail) {
+		t.Fatalf("expected the last error, got %v", err)
+	}
+	if d.calls != 3 {
+		t.Fatalf("expected 3 dials, got %d", d.calls)
+	}
+}
+
+func TestFastRetryDialer(t *testing.T) {
+	errReset := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNRESET}
+
+	// one immediate reset triggers a single fast retry.
+	d := &scriptDialer{errs: []error{errReset}}
+	conn, err := FastRetryDialer(d, time.Second).Dial(context.Background(), "tcp", "example.com:80")
+	if err != nil {
+		t.Fatalf("expected success after the fast retry, got %v", err)
+	}
+	conn.Close()
+	if d.calls != 2 {
+		t.Fatalf("expected 2 dials, got %d", d.calls)
+	}
+
+	// a second reset falls through to the failover.
+	errRefused := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	d = &scriptDialer{errs: []error{errRefused, errReset}}
+	if _, err := FastRetryDialer(d, time.Second).Dial(context.Background(), "tcp", "example.com:80"); !errors.Is(err, syscall.ECONNRESET) {
+		t.Fatalf("expected the second error, got %v", err)
+	}
+	if d.calls != 2 {
+		t.Fatalf("expected 2 dials, got %d", d.calls)
+	}
+}
+
+func TestFastRetryDialerNoRetry(t *testing.T) {
+	errReset := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNRESET}
+
+	// the other errors are not retried.
+	d := &scriptDialer{errs: []error{errors.New("no route")}}
+	if _, err := FastRetryDialer(d, time.Second).Dial(context.Background(), "tcp", "example.com:80"); err == nil || d.calls != 1 {
+		t.Fatalf("expected no retry, %d dials, %v", d.calls, err)
+	}
+
+	// the slow failure is not a flap.
+	d = &scriptDialer{errs: []error{errReset}}
+	if _, err := FastRetryDialer(d, time.Nanosecond).Dial(context.Background(), "tcp", "example.com:80"); err == nil || d.calls != 1 {
+		t.Fatalf("expected no retry after the window, %d dials, %v", d.calls, err)
+	}
+
+	// the done context is not retried.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	d = &scriptDialer{errs: []error{errReset}}
+	if _, err := FastRetryDialer(d, time.Second).Dial(ctx, "tcp", "example.com:80"); err == nil || d.calls != 1 {
+		t.Fatalf("expected no retry with the done context, %d dials, %v", d.calls, err)
+	}
+}
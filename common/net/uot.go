@@ -0,0 +1,137 @@
+package net
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+)
+
+const (
+	// MaxDatagramSize is the maximum size of a datagram in the UDP-over-TCP framing.
+	MaxDatagramSize = 65535
+)
+
+var (
+	ErrDatagramTooLarge = errors.New("datagram too large")
+)
+
+// WriteDatagram writes the datagram b to w as one frame, which is the 2-byte big-endian length followed by the payload.
+func WriteDatagram(w io.Writer, b []byte) error {
+	if len(b) > MaxDatagramSize {
+		return ErrDatagramTooLarge
+	}
+
+	buf := make([]byte, 2+len(b))
+	binary.BigEndian.PutUint16(buf, uint16(len(b)))
+	copy(buf[2:], b)
+	_, err := w.Write(buf)
+	return err
+}
+
+// ReadDatagram reads one frame from r into b and returns the datagram size, the partial reads of the stream
+// are assembled. If b is smaller than the datagram, the datagram is truncated like UDP and io.ErrShortBuffer is returned.
+func ReadDatagram(r io.Reader, b []byte) (int, error) {
+	var hdr [2]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return 0, err
+	}
+	n := int(binary.BigEndian.Uint16(hdr[:]))
+
+	if n <= len(b) {
+		if _, err := io.ReadFull(r, b[:n]); err != nil {
+			return 0, noEOF(err)
+		}
+		return n, nil
+	}
+
+	if _, err := io.ReadFull(r, b); err != nil {
+		return 0, noEOF(err)
+	}
+	if _, err := io.CopyN(io.Discard, r, int64(n-len(b))); err != nil {
+		return 0, noEOF(err)
+	}
+	return len(b), io.ErrShortBuffer
+}
+
+// noEOF converts the EOF in the middle of a frame to io.ErrUnexpectedEOF.
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+type datagramConn struct {
+	net.Conn
+	rmu sync.Mutex
+	wmu sync.Mutex
+}
+
+// DatagramConn wraps the stream c to carry the UDP datagrams with the length-prefixed framing,
+// each Write sends one datagram and each Read receives one, so the datagram boundaries are preserved.
+func DatagramConn(c net.Conn) net.Conn {
+	return &datagramConn{Conn: c}
+}
+
+func (c *datagramConn) Read(b []byte) (int, error) {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+
+	return ReadDatagram(c.Conn, b)
+}
+
+func (c *datagramConn) Write(b []byte) (int, error) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	if err := WriteDatagram(c.Conn, b); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// RelayDatagrams relays the datagrams between the framed stream and the packet connection pc,
+// which is connected to the UDP target, until either side fails or ctx is done.
+func RelayDatagrams(ctx context.Context, stream net.Conn, pc net.Conn) error {
+	dc := DatagramConn(stream)
+
+	errc := make(chan error, 2)
+	stop := context.AfterFunc(ctx, func() {
+		stream.Close()
+		pc.Close()
+	})
+	defer stop()
+
+	copyPackets := func(dst, src net.Conn) {
+		buf := make([]byte, MaxDatagramSize)
+		for {
+			n, err := src.Read(buf)
+			if err != nil && !errors.Is(err, io.ErrShortBuffer) {
+				errc <- err
+				return
+			}
+			if _, err := dst.Write(buf[:n]); err != nil {
+				errc <- err
+				return
+			}
+		}
+	}
+	go copyPackets(pc, dc)
+	go copyPackets(dc, pc)
+
+	err := <-errc
+	stream.Close()
+	pc.Close()
+	<-errc
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,163 @@
+package net
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+// datagrams returns the datagrams of varying sizes, each filled with its own byte.
+func datagrams(sizes ...int) [][]byte {
+	var bs [][]byte
+	for i, n := range sizes {
+		bs = append(bs, bytes.Repeat([]byte{byte('a' + i)}, n))
+	}
+	return bs
+}
+
+// udpEcho starts a UDP server echoing the datagrams back.
+func udpEcho(t *testing.T) string {
+	t.Helper()
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pc.Close() })
+
+	go func() {
+		buf := make([]byte, MaxDatagramSize)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			pc.WriteTo(buf[:n], addr)
+		}
+	}()
+	return pc.LocalAddr().String()
+}
+
+func TestDatagramFraming(t *testing.T) {
+	var stream bytes.Buffer
+	sent := datagrams(0, 1, 100, 1500, 8192, MaxDatagramSize)
+	for _, b := range sent {
+		if err := WriteDatagram(&stream, b); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the stream is delivered byte by byte, the datagrams are reassembled.
+	r := iotest.OneByteReader(&stream)
+	buf := make([]byte, MaxDatagramSize)
+	for i, want := range sent {
+		n, err := ReadDatagram(r, buf)
+		if err != nil {
+			t.Fatalf("datagram %d: %v", i, err)
+		}
+		if !bytes.Equal(buf[:n], want) {
+			t.Fatalf("datagram %d: got %d bytes, want %d", i, n, len(want))
+		}
+	}
+	if _, err := ReadDatagram(r, buf); err != io.EOF {
+		t.Fatalf("expected EOF at the frame boundary, got %v", err)
+	}
+
+	if err := WriteDatagram(&stream, make([]byte, MaxDatagramSize+1)); err != ErrDatagramTooLarge {
+		t.Fatalf("expected ErrDatagramTooLarge, got %v", err)
+	}
+}
+
+func TestDatagramShortBuffer(t *testing.T) {
+	var stream bytes.Buffer
+	sent := datagrams(10, 3)
+	for _, b := range sent {
+		WriteDatagram(&stream, b)
+	}
+
+	// the datagram is truncated, the next one is still aligned.
+	buf := make([]byte, 4)
+	n, err := ReadDatagram(&stream, buf)
+	if !errors.Is(err, io.ErrShortBuffer) || !bytes.Equal(buf[:n], sent[0][:4]) {
+		t.Fatalf("unexpected truncated datagram %q %v", buf[:n], err)
+	}
+	n, err = ReadDatagram(&stream, buf)
+	if err != nil || !bytes.Equal(buf[:n], sent[1]) {
+		t.Fatalf("unexpected datagram %q %v", buf[:n], err)
+	}
+}
+
+func TestDatagramUnexpectedEOF(t *testing.T) {
+	var stream bytes.Buffer
+	WriteDatagram(&stream, []byte("hello"))
+
+	for _, size := range []int{1, 4} {
+		r := bytes.NewReader(stream.Bytes()[:size])
+		if _, err := ReadDatagram(r, make([]byte, 16)); err != io.ErrUnexpectedEOF {
+			t.Errorf("%d bytes: expected io.ErrUnexpectedEOF, got %v", size, err)
+		}
+	}
+}
+
+func TestDatagramConn(t *testing.T) {
+	c1, c2 := tcpPair(t)
+	dc1, dc2 := DatagramConn(c1), DatagramConn(c2)
+
+	sent := datagrams(1, 512, 1500, 4096, 1)
+	go func() {
+		for _, b := range sent {
+			dc1.Write(b)
+		}
+	}()
+
+	buf := make([]byte, MaxDatagramSize)
+	for i, want := range sent {
+		n, err := dc2.Read(buf)
+		if err != nil || !bytes.Equal(buf[:n], want) {
+			t.Fatalf("datagram %d: got %d bytes, want %d, %v", i, n, len(want), err)
+		}
+	}
+}
+
+func TestRelayDatagrams(t *testing.T) {
+	target := udpEcho(t)
+	pc, err := net.Dial("udp", target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, server := tcpPair(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() {
+		errc <- RelayDatagrams(ctx, server, pc)
+	}()
+
+	dc := DatagramConn(client)
+	dc.SetDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, MaxDatagramSize)
+	for i, b := range datagrams(1, 100, 1200, 8000) {
+		if _, err := dc.Write(b); err != nil {
+			t.Fatal(err)
+		}
+		n, err := dc.Read(buf)
+		if err != nil || !bytes.Equal(buf[:n], b) {
+			t.Fatalf("datagram %d: got %d bytes, want %d, %v", i, n, len(b), err)
+		}
+	}
+
+	cancel()
+	select {
+	case err := <-errc:
+		if err != context.Canceled {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the relay is not stopped by the context")
+	}
+}
@@ -0,0 +1,40 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	xnet "github.com/go-gost/core/common/net"
+	"github.com/go-gost/core/metadata"
+)
+
+var (
+	ErrUnsupportedNetwork = errors.New("connector: unsupported network")
+)
+
+type uotConnector struct{}
+
+// UDPOverTCPConnector is a connector tunneling the UDP datagrams over the reliable stream for the environments
+// where UDP is blocked. The target address is sent in the first frame, and the returned connection carries
+// one datagram per Read and Write. The peer is served by handler.UDPOverTCPHandler.
+func UDPOverTCPConnector() Connector {
+	return &uotConnector{}
+}
+
+func (c *uotConnector) Init(md metadata.Metadata) error {
+	return nil
+}
+
+func (c *uotConnector) Connect(ctx context.Context, conn net.Conn, network, address string, opts ...ConnectOption) (net.Conn, error) {
+	switch network {
+	case "udp", "udp4", "udp6":
+	default:
+		return nil, ErrUnsupportedNetwork
+	}
+
+	if err := xnet.WriteDatagram(conn, []byte(address)); err != nil {
+		return nil, err
+	}
+	return xnet.DatagramConn(conn), nil
+}
@@ -3,3 +3,16 @@ module github.com/go-gost/core
 go 1.22
 
 toolchain go1.22.2
+
+require (
+	github.com/andybalholm/brotli v1.1.1
+	github.com/fsnotify/fsnotify v1.8.0
+	github.com/hashicorp/yamux v0.1.2
+	github.com/xtaci/smux v1.5.24
+	golang.org/x/net v0.35.0
+)
+
+require (
+	golang.org/x/sys v0.30.0 // indirect
+	golang.org/x/text v0.22.0 // indirect
+)
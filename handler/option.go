@@ -16,18 +16,19 @@ import (
 )
 
 type Options struct {
-	Bypass      bypass.Bypass
-	Router      chain.Router
-	Auth        *url.Userinfo
-	Auther      auth.Authenticator
-	RateLimiter rate.RateLimiter
-	Limiter     traffic.TrafficLimiter
-	TLSConfig   *tls.Config
-	Logger      logger.Logger
-	Observer    observer.Observer
-	Recorders   []recorder.RecorderObject
-	Service     string
-	Netns       string
+	Bypass       bypass.Bypass
+	Router       chain.Router
+	Auth         *url.Userinfo
+	Auther       auth.Authenticator
+	RateLimiter  rate.RateLimiter
+	Limiter      traffic.TrafficLimiter
+	TLSConfig    *tls.Config
+	Logger       logger.Logger
+	Observer     observer.Observer
+	ConnObserver observer.ConnObserver
+	Recorders    []recorder.RecorderObject
+	Service      string
+	Netns        string
 }
 
 type Option func(opts *Options)
@@ -86,6 +87,12 @@ func ObserverOption(observer observer.Observer) Option {
 	}
 }
 
+func ConnObserverOption(observer observer.ConnObserver) Option {
+	return func(opts *Options) {
+		opts.ConnObserver = observer
+	}
+}
+
 func RecordersOption(recorders ...recorder.RecorderObject) Option {
 	return func(o *Options) {
 		o.Recorders = recorders
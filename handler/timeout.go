@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"time"
+
+	xnet "github.com/go-gost/core/common/net"
+	"github.com/go-gost/core/hop"
+	"github.com/go-gost/core/metadata"
+)
+
+const (
+	// MDKeyTimeout is the metadata key to override the request timeout per route.
+	MDKeyTimeout = "handler.timeout"
+)
+
+var (
+	ErrRequestTimeout = errors.New("handler: request timeout")
+)
+
+type timeoutHandler struct {
+	handler Handler
+	timeout time.Duration
+}
+
+// TimeoutHandler wraps the handler h to enforce a total deadline for handling a connection.
+// When the deadline is exceeded, the handler context is cancelled, the bytes already
+// written to the client are flushed by half-closing the connection before it is closed,
+// and ErrRequestTimeout is returned.
+// The timeout can be overridden by the metadata key MDKeyTimeout in the HandleOptions.
+func TimeoutHandler(h Handler, timeout time.Duration) Handler {
+	return &timeoutHandler{
+		handler: h,
+		timeout: timeout,
+	}
+}
+
+func (h *timeoutHandler) Init(md metadata.Metadata) error {
+	return h.handler.Init(md)
+}
+
+func (h *timeoutHandler) Forward(hop hop.Hop) {
+	if f, ok := h.handler.(Forwarder); ok {
+		f.Forward(hop)
+	}
+}
+
+func (h *timeoutHandler) Handle(ctx context.Context, conn net.Conn, opts ...HandleOption) error {
+	var options HandleOptions
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+
+	timeout := h.timeout
+	if d, ok := durationFromMetadata(options.Metadata, MDKeyTimeout); ok {
+		timeout = d
+	}
+	if timeout <= 0 {
+		return h.handler.Handle(ctx, conn, opts...)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- h.handler.Handle(ctx, conn, opts...)
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+		if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			conn.Close()
+			<-errc
+			return ctx.Err()
+		}
+	}
+
+	xnet.CloseWrite(conn)
+	conn.Close()
+	// wait for the handler to exit after the connection is closed.
+	<-errc
+	return ErrRequestTimeout
+}
+
+func durationFromMetadata(md metadata.Metadata, key string) (time.Duration, bool) {
+	if md == nil || !md.IsExists(key) {
+		return 0, false
+	}
+
+	switch v := md.Get(key).(type) {
+	case time.Duration:
+		return v, true
+	case int:
+		return time.Duration(v) * time.Second, true
+	case string:
+		if d, err := time.ParseDuration(v); err == nil {
+			return d, true
+		}
+		if n, err := strconv.Atoi(v); err == nil {
+			return time.Duration(n) * time.Second, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-gost/core/metadata"
+)
+
+// mapMetadata is a metadata.Metadata backed by a map.
+type mapMetadata map[string]any
+
+func (m mapMetadata) IsExists(key string) bool {
+	_, ok := m[key]
+	return ok
+}
+
+func (m mapMetadata) Set(key string, value any) {
+	m[key] = value
+}
+
+func (m mapMetadata) Get(key string) any {
+	return m[key]
+}
+
+// funcHandler is an adapter to use a function as Handler.
+type funcHandler func(ctx context.Context, conn net.Conn) error
+
+func (f funcHandler) Init(md metadata.Metadata) error {
+	return nil
+}
+
+func (f funcHandler) Handle(ctx context.Context, conn net.Conn, opts ...HandleOption) error {
+	return f(ctx, conn)
+}
+
+// slowHandler writes a partial response, then waits for the upstream until ctx is done.
+func slowHandler(upstream time.Duration) Handler {
+	return funcHandler(func(ctx context.Context, conn net.Conn) error {
+		if _, err := conn.Write([]byte("partial")); err != nil {
+			return err
+		}
+		select {
+		case <-time.After(upstream):
+			_, err := conn.Write([]byte(" done"))
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+}
+
+// tcpPair returns the server and client side of a TCP connection.
+func tcpPair(t *testing.T) (server, client net.Conn) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	client, err = net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err = ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		server.Close()
+		client.Close()
+	})
+	return
+}
+
+func TestTimeoutHandler(t *testing.T) {
+	server, client := tcpPair(t)
+	h := TimeoutHandler(slowHandler(time.Hour), 50*time.Millisecond)
+
+	start := time.Now()
+	if err := h.Handle(context.Background(), server); !errors.Is(err, ErrRequestTimeout) {
+		t.Fatalf("expected ErrRequestTimeout, got %v", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("timeout is not enforced, took %s", d)
+	}
+
+	// the partial response is flushed before the connection is closed.
+	client.SetReadDeadline(time.Now().Add(time.Second))
+	b, err := io.ReadAll(client)
+	if err != nil || string(b) != "partial" {
+		t.Fatalf("expected the partial response and EOF, got %q %v", b, err)
+	}
+}
+
+func TestTimeoutHandlerCompleted(t *testing.T) {
+	server, client := tcpPair(t)
+	h := TimeoutHandler(slowHandler(0), time.Second)
+
+	if err := h.Handle(context.Background(), server); err != nil {
+		t.Fatal(err)
+	}
+	server.Close()
+	b, _ := io.ReadAll(client)
+	if string(b) != "partial done" {
+		t.Fatalf("unexpected response %q", b)
+	}
+}
+
+func TestTimeoutHandlerMetadata(t *testing.T) {
+	for _, v := range []any{"50ms", 50 * time.Millisecond} {
+		server, _ := tcpPair(t)
+		h := TimeoutHandler(slowHandler(time.Hour), time.Hour)
+
+		md := mapMetadata{MDKeyTimeout: v}
+		start := time.Now()
+		if err := h.Handle(context.Background(), server, MetadataHandleOption(md)); !errors.Is(err, ErrRequestTimeout) {
+			t.Fatalf("%v: expected ErrRequestTimeout, got %v", v, err)
+		}
+		if d := time.Since(start); d > time.Second {
+			t.Fatalf("%v: route timeout is not applied, took %s", v, d)
+		}
+	}
+
+	// the route can disable the timeout.
+	server, _ := tcpPair(t)
+	h := TimeoutHandler(slowHandler(10*time.Millisecond), time.Millisecond)
+	if err := h.Handle(context.Background(), server, MetadataHandleOption(mapMetadata{MDKeyTimeout: "0"})); err != nil {
+		t.Fatalf("expected no timeout, got %v", err)
+	}
+}
+
+func TestTimeoutHandlerCanceled(t *testing.T) {
+	server, _ := tcpPair(t)
+	h := TimeoutHandler(slowHandler(time.Hour), time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	if err := h.Handle(ctx, server); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
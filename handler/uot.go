@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	xnet "github.com/go-gost/core/common/net"
+	"github.com/go-gost/core/metadata"
+)
+
+var (
+	ErrBypass = errors.New("handler: bypass")
+)
+
+type uotHandler struct {
+	options Options
+}
+
+// UDPOverTCPHandler is the handler serving connector.UDPOverTCPConnector, it reads the target address
+// from the first frame, connects to the UDP target through the Router if it is set, and relays the datagrams.
+func UDPOverTCPHandler(opts ...Option) Handler {
+	var options Options
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+	return &uotHandler{
+		options: options,
+	}
+}
+
+func (h *uotHandler) Init(md metadata.Metadata) error {
+	return nil
+}
+
+func (h *uotHandler) Handle(ctx context.Context, conn net.Conn, opts ...HandleOption) error {
+	defer conn.Close()
+
+	buf := make([]byte, 512)
+	n, err := xnet.ReadDatagram(conn, buf)
+	if err != nil {
+		return err
+	}
+	address := string(buf[:n])
+
+	if bp := h.options.Bypass; bp != nil && bp.Contains(ctx, "udp", address) {
+		return ErrBypass
+	}
+
+	var pc net.Conn
+	if r := h.options.Router; r != nil {
+		pc, err = r.Dial(ctx, "udp", address)
+	} else {
+		var d net.Dialer
+		pc, err = d.DialContext(ctx, "udp", address)
+	}
+	if err != nil {
+		return err
+	}
+	defer pc.Close()
+
+	return xnet.RelayDatagrams(ctx, conn, pc)
+}
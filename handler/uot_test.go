@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/go-gost/core/bypass"
+	"github.com/go-gost/core/connector"
+)
+
+// udpEcho starts a UDP server echoing the datagrams back.
+func udpEcho(t *testing.T) string {
+	t.Helper()
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pc.Close() })
+
+	go func() {
+		buf := make([]byte, 65535)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			pc.WriteTo(buf[:n], addr)
+		}
+	}()
+	return pc.LocalAddr().String()
+}
+
+func TestUDPOverTCP(t *testing.T) {
+	target := udpEcho(t)
+	client, server := net.Pipe()
+	defer client.Close()
+
+	h := UDPOverTCPHandler()
+	errc := make(chan error, 1)
+	go func() {
+		errc <- h.Handle(context.Background(), server)
+	}()
+
+	conn, err := connector.UDPOverTCPConnector().Connect(context.Background(), client, "udp", target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	// the boundaries of the datagrams of varying sizes are preserved through the tunnel.
+	buf := make([]byte, 65535)
+	for i, size := range []int{1, 64, 512, 1400, 9000, 32768} {
+		b := bytes.Repeat([]byte{byte('a' + i)}, size)
+		if _, err := conn.Write(b); err != nil {
+			t.Fatal(err)
+		}
+		n, err := conn.Read(buf)
+		if err != nil || !bytes.Equal(buf[:n], b) {
+			t.Fatalf("datagram %d: got %d bytes, want %d, %v", i, n, size, err)
+		}
+	}
+
+	conn.Close()
+	select {
+	case err := <-errc:
+		if err != nil {
+			t.Fatalf("unexpected error after the tunnel is closed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the handler is not stopped after the tunnel is closed")
+	}
+}
+
+func TestUDPOverTCPBypass(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+
+	bp := bypass.CIDRBypass([]netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")}, false)
+	h := UDPOverTCPHandler(BypassOption(bp))
+	errc := make(chan error, 1)
+	go func() {
+		errc <- h.Handle(context.Background(), server)
+	}()
+
+	if _, err := connector.UDPOverTCPConnector().Connect(context.Background(), client, "udp", "127.0.0.1:53"); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errc; err != ErrBypass {
+		t.Fatalf("expected ErrBypass, got %v", err)
+	}
+}
+
+func TestUDPOverTCPConnectorNetwork(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	if _, err := connector.UDPOverTCPConnector().Connect(context.Background(), client, "tcp", "127.0.0.1:80"); err != connector.ErrUnsupportedNetwork {
+		t.Fatalf("expected ErrUnsupportedNetwork, got %v", err)
+	}
+}
@@ -0,0 +1,184 @@
+package health
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"golang.org/x/net/http2"
+)
+
+const (
+	grpcHealthCheckPath = "/grpc.health.v1.Health/Check"
+	// grpc.health.v1.HealthCheckResponse.ServingStatus
+	grpcServing    = 1
+	grpcNotServing = 2
+	grpcMaxMsgSize = 4096
+)
+
+type grpcChecker struct {
+	client  *http.Client
+	options Options
+}
+
+// GRPCChecker is a health checker using the gRPC health checking protocol (grpc.health.v1) over HTTP/2 with TLS,
+// or the plaintext HTTP/2 with H2COption.
+func GRPCChecker(opts ...Option) Checker {
+	options := newOptions(opts...)
+	return &grpcChecker{
+		client:  newHTTP2Client(&options),
+		options: options,
+	}
+}
+
+func (c *grpcChecker) Check(ctx context.Context, addr string) (Status, error) {
+	if c.options.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.options.Timeout)
+		defer cancel()
+	}
+
+	// HealthCheckRequest{service = 1}
+	var msg []byte
+	if c.options.Service != "" {
+		msg = append(msg, 0x0a)
+		msg = binary.AppendUvarint(msg, uint64(len(c.options.Service)))
+		msg = append(msg, c.options.Service...)
+	}
+	body := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(body[1:], uint32(len(msg)))
+	body = append(body, msg...)
+
+	scheme := "https"
+	if c.options.H2C {
+		scheme = "http"
+	}
+	u := url.URL{Scheme: scheme, Host: addr, Path: grpcHealthCheckPath}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return StatusUnknown, err
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("TE", "trailers")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return StatusUnknown, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return StatusUnknown, fmt.Errorf("health: grpc: unexpected http status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, grpcMaxMsgSize))
+	if err != nil {
+		return StatusUnknown, err
+	}
+
+	// the grpc-status may be sent in headers for trailers-only responses.
+	code := resp.Trailer.Get("grpc-status")
+	if code == "" {
+		code = resp.Header.Get("grpc-status")
+	}
+	if code != "" && code != "0" {
+		if n, _ := strconv.Atoi(code); n == 5 { // NOT_FOUND
+			return StatusNotServing, fmt.Errorf("health: grpc: unknown service %q", c.options.Service)
+		}
+		return StatusUnknown, fmt.Errorf("health: grpc: status %s: %s", code, resp.Trailer.Get("grpc-message"))
+	}
+
+	status, err := parseGRPCHealthResponse(data)
+	if err != nil {
+		return StatusUnknown, err
+	}
+	switch status {
+	case grpcServing:
+		return StatusServing, nil
+	case grpcNotServing:
+		return StatusNotServing, ErrNotServing
+	default:
+		return StatusUnknown, nil
+	}
+}
+
+// parseGRPCHealthResponse decodes the length-prefixed HealthCheckResponse{status = 1} message.
+func parseGRPCHealthResponse(data []byte) (uint64, error) {
+	if len(data) < 5 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	if data[0] != 0 {
+		return 0, fmt.Errorf("health: grpc: compressed message is not supported")
+	}
+	n := binary.BigEndian.Uint32(data[1:5])
+	msg := data[5:]
+	if uint32(len(msg)) < n {
+		return 0, io.ErrUnexpectedEOF
+	}
+	msg = msg[:n]
+
+	// absent field means the default value UNKNOWN.
+	var status uint64
+	for len(msg) > 0 {
+		key, k := binary.Uvarint(msg)
+		if k <= 0 {
+			return 0, fmt.Errorf("health: grpc: malformed message")
+		}
+		msg = msg[k:]
+
+		switch key & 0x7 {
+		case 0: // varint
+			v, k := binary.Uvarint(msg)
+			if k <= 0 {
+				return 0, fmt.Errorf("health: grpc: malformed message")
+			}
+			msg = msg[k:]
+			if key>>3 == 1 {
+				status = v
+			}
+		case 2: // length-delimited
+			l, k := binary.Uvarint(msg)
+			if k <= 0 || uint64(len(msg)-k) < l {
+				return 0, fmt.Errorf("health: grpc: malformed message")
+			}
+			msg = msg[k+int(l):]
+		default:
+			return 0, fmt.Errorf("health: grpc: malformed message")
+		}
+	}
+	return status, nil
+}
+
+func newOptions(opts ...Option) Options {
+	var options Options
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+	return options
+}
+
+func newHTTP2Client(opts *Options) *http.Client {
+	tr := &http2.Transport{
+		TLSClientConfig: http2TLSConfig(opts),
+	}
+	if opts.H2C {
+		// the http scheme is dialed by DialTLSContext with AllowHTTP, which is the plaintext connection here.
+		tr.AllowHTTP = true
+		tr.DialTLSContext = func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, addr)
+		}
+	}
+	return &http.Client{
+		Transport: tr,
+	}
+}
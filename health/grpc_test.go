@@ -0,0 +1,165 @@
+package health
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-gost/core/selector"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// fakeHealthServer is a grpc.health.v1 server reporting the status of the service.
+type fakeHealthServer struct {
+	service string
+	status  atomic.Uint64
+	checks  atomic.Int64
+}
+
+func (s *fakeHealthServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.checks.Add(1)
+	if r.URL.Path != grpcHealthCheckPath || r.Header.Get("Content-Type") != "application/grpc" {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	body, _ := io.ReadAll(r.Body)
+	var service string
+	// HealthCheckRequest{service = 1}
+	if len(body) > 5 && body[5] == 0x0a {
+		n, k := binary.Uvarint(body[6:])
+		service = string(body[6+k : 6+k+int(n)])
+	}
+
+	w.Header().Set("Content-Type", "application/grpc")
+	w.Header().Set("Trailer", "grpc-status")
+	if service != s.service {
+		w.Header().Set("grpc-status", "5")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	// HealthCheckResponse{status = 1}
+	msg := binary.AppendUvarint([]byte{0x08}, s.status.Load())
+	frame := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(msg)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(append(frame, msg...))
+	w.Header().Set("grpc-status", "0")
+}
+
+func newTLSServer(t *testing.T, h http.Handler) (*httptest.Server, *tls.Config) {
+	srv := httptest.NewUnstartedServer(h)
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+	return srv, &tls.Config{RootCAs: pool, ServerName: "example.com"}
+}
+
+func newH2CServer(t *testing.T, h http.Handler) *httptest.Server {
+	srv := httptest.NewServer(h2c.NewHandler(h, &http2.Server{}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func serverAddr(srv *httptest.Server) string {
+	return strings.TrimPrefix(strings.TrimPrefix(srv.URL, "https://"), "http://")
+}
+
+func TestGRPCCheckerMarkerTransitions(t *testing.T) {
+	hs := &fakeHealthServer{service: "echo"}
+	hs.status.Store(grpcServing)
+	srv, tlsConfig := newTLSServer(t, hs)
+
+	checker := GRPCChecker(ServiceOption("echo"), TLSConfigOption(tlsConfig), TimeoutOption(time.Second))
+	marker := selector.NewFailMarker()
+	marker.Mark()
+
+	status, err := checker.Check(context.Background(), serverAddr(srv))
+	if err != nil || status != StatusServing {
+		t.Fatalf("expected SERVING, got %s %v", status, err)
+	}
+	Mark(marker, status, err)
+	if marker.Count() != 0 {
+		t.Fatalf("marker should be reset on SERVING, count %d", marker.Count())
+	}
+
+	hs.status.Store(grpcNotServing)
+	status, err = checker.Check(context.Background(), serverAddr(srv))
+	if status != StatusNotServing {
+		t.Fatalf("expected NOT_SERVING, got %s %v", status, err)
+	}
+	Mark(marker, status, err)
+	if marker.Count() != 1 {
+		t.Fatalf("marker should be marked on NOT_SERVING, count %d", marker.Count())
+	}
+
+	hs.status.Store(grpcServing)
+	status, err = checker.Check(context.Background(), serverAddr(srv))
+	Mark(marker, status, err)
+	if status != StatusServing || marker.Count() != 0 {
+		t.Fatalf("expected recovery, got %s count %d", status, marker.Count())
+	}
+}
+
+func TestGRPCCheckerUnknownService(t *testing.T) {
+	hs := &fakeHealthServer{service: "echo"}
+	hs.status.Store(grpcServing)
+	srv, tlsConfig := newTLSServer(t, hs)
+
+	checker := GRPCChecker(ServiceOption("other"), TLSConfigOption(tlsConfig))
+	status, err := checker.Check(context.Background(), serverAddr(srv))
+	if status != StatusNotServing || err == nil {
+		t.Fatalf("expected NOT_SERVING for unknown service, got %s %v", status, err)
+	}
+}
+
+func TestGRPCCheckerH2C(t *testing.T) {
+	hs := &fakeHealthServer{}
+	hs.status.Store(grpcServing)
+	srv := newH2CServer(t, hs)
+
+	checker := GRPCChecker(H2COption(true), TimeoutOption(time.Second))
+	status, err := checker.Check(context.Background(), serverAddr(srv))
+	if err != nil || status != StatusServing {
+		t.Fatalf("expected SERVING over h2c, got %s %v", status, err)
+	}
+
+	hs.status.Store(grpcNotServing)
+	if status, _ := checker.Check(context.Background(), serverAddr(srv)); status != StatusNotServing {
+		t.Fatalf("expected NOT_SERVING over h2c, got %s", status)
+	}
+}
+
+func TestParseGRPCHealthResponse(t *testing.T) {
+	for _, tc := range []struct {
+		data   []byte
+		status uint64
+		fail   bool
+	}{
+		{data: []byte{0, 0, 0, 0, 2, 0x08, 0x01}, status: grpcServing},
+		{data: []byte{0, 0, 0, 0, 2, 0x08, 0x02}, status: grpcNotServing},
+		// absent field is UNKNOWN.
+		{data: []byte{0, 0, 0, 0, 0}, status: 0},
+		{data: []byte{1, 0, 0, 0, 0}, fail: true},
+		{data: []byte{0, 0, 0, 0, 4, 0x08}, fail: true},
+		{data: []byte{0, 0}, fail: true},
+	} {
+		status, err := parseGRPCHealthResponse(tc.data)
+		if (err != nil) != tc.fail || status != tc.status {
+			t.Errorf("parse %v: got %d %v", tc.data, status, err)
+		}
+	}
+}
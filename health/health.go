@@ -0,0 +1,87 @@
+package health
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"time"
+
+	"github.com/go-gost/core/selector"
+)
+
+var (
+	ErrNotServing = errors.New("health: not serving")
+)
+
+// Status is the serving status reported by a health check.
+type Status int
+
+const (
+	StatusUnknown Status = iota
+	StatusServing
+	StatusNotServing
+)
+
+func (s Status) String() string {
+	switch s {
+	case StatusServing:
+		return "SERVING"
+	case StatusNotServing:
+		return "NOT_SERVING"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+type Options struct {
+	// Service is the service name for the gRPC health check, empty for the overall server health.
+	Service   string
+	Timeout   time.Duration
+	TLSConfig *tls.Config
+	// H2C probes over the plaintext HTTP/2 with prior knowledge instead of TLS, e.g. for the internal gRPC backends.
+	H2C bool
+}
+
+type Option func(opts *Options)
+
+func ServiceOption(service string) Option {
+	return func(opts *Options) {
+		opts.Service = service
+	}
+}
+
+func H2COption(h2c bool) Option {
+	return func(opts *Options) {
+		opts.H2C = h2c
+	}
+}
+
+func TimeoutOption(timeout time.Duration) Option {
+	return func(opts *Options) {
+		opts.Timeout = timeout
+	}
+}
+
+func TLSConfigOption(tlsConfig *tls.Config) Option {
+	return func(opts *Options) {
+		opts.TLSConfig = tlsConfig
+	}
+}
+
+// Checker probes the health of the server at addr.
+type Checker interface {
+	Check(ctx context.Context, addr string) (Status, error)
+}
+
+// Mark maps the check result to the marker:
+// the marker is reset on StatusServing and marked on failure or StatusNotServing.
+func Mark(m selector.Marker, status Status, err error) {
+	if m == nil {
+		return
+	}
+	if err == nil && status == StatusServing {
+		m.Reset()
+		return
+	}
+	m.Mark()
+}
@@ -0,0 +1,85 @@
+package health
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+
+	"golang.org/x/net/http2"
+)
+
+type http2Checker struct {
+	transport *http2.Transport
+	options   Options
+}
+
+// HTTP2Checker is a health checker which sends an HTTP/2 PING frame over a new connection,
+// the server is serving if it negotiates HTTP/2 and acknowledges the PING.
+// The connection is over TLS with ALPN h2, or the plaintext HTTP/2 with H2COption.
+func HTTP2Checker(opts ...Option) Checker {
+	options := newOptions(opts...)
+	return &http2Checker{
+		transport: &http2.Transport{},
+		options:   options,
+	}
+}
+
+func (c *http2Checker) Check(ctx context.Context, addr string) (Status, error) {
+	if c.options.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.options.Timeout)
+		defer cancel()
+	}
+
+	conn, err := dialHTTP2(ctx, addr, &c.options)
+	if err != nil {
+		return StatusUnknown, err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	cc, err := c.transport.NewClientConn(conn)
+	if err != nil {
+		return StatusNotServing, err
+	}
+	defer cc.Close()
+
+	if err := cc.Ping(ctx); err != nil {
+		return StatusNotServing, fmt.Errorf("health: http2: ping: %w", err)
+	}
+	return StatusServing, nil
+}
+
+// dialHTTP2 connects to addr for HTTP/2, the TLS connection must negotiate h2 by ALPN.
+func dialHTTP2(ctx context.Context, addr string, opts *Options) (net.Conn, error) {
+	if opts.H2C {
+		var d net.Dialer
+		return d.DialContext(ctx, "tcp", addr)
+	}
+
+	d := tls.Dialer{Config: http2TLSConfig(opts)}
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if proto := conn.(*tls.Conn).ConnectionState().NegotiatedProtocol; proto != http2.NextProtoTLS {
+		conn.Close()
+		return nil, fmt.Errorf("health: http2: unexpected protocol %q", proto)
+	}
+	return conn, nil
+}
+
+func http2TLSConfig(opts *Options) *tls.Config {
+	tlsConfig := opts.TLSConfig
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{}
+	} else {
+		tlsConfig = tlsConfig.Clone()
+	}
+	tlsConfig.NextProtos = []string{http2.NextProtoTLS}
+	return tlsConfig
+}
@@ -0,0 +1,71 @@
+package health
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHTTP2CheckerPing(t *testing.T) {
+	srv, tlsConfig := newTLSServer(t, http.NotFoundHandler())
+
+	checker := HTTP2Checker(TLSConfigOption(tlsConfig), TimeoutOption(time.Second))
+	status, err := checker.Check(context.Background(), serverAddr(srv))
+	if err != nil || status != StatusServing {
+		t.Fatalf("expected SERVING, got %s %v", status, err)
+	}
+}
+
+func TestHTTP2CheckerH2C(t *testing.T) {
+	srv := newH2CServer(t, http.NotFoundHandler())
+
+	checker := HTTP2Checker(H2COption(true), TimeoutOption(time.Second))
+	status, err := checker.Check(context.Background(), serverAddr(srv))
+	if err != nil || status != StatusServing {
+		t.Fatalf("expected SERVING over h2c, got %s %v", status, err)
+	}
+}
+
+func TestHTTP2CheckerNoHTTP2(t *testing.T) {
+	// a TLS server negotiating HTTP/1.1 only.
+	srv := httptest.NewUnstartedServer(http.NotFoundHandler())
+	srv.StartTLS()
+	defer srv.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+	checker := HTTP2Checker(TLSConfigOption(&tls.Config{RootCAs: pool, ServerName: "example.com"}), TimeoutOption(time.Second))
+	status, err := checker.Check(context.Background(), serverAddr(srv))
+	if err == nil || status == StatusServing {
+		t.Fatalf("expected failure without h2, got %s %v", status, err)
+	}
+}
+
+func TestHTTP2CheckerUnresponsive(t *testing.T) {
+	// accepts the connection but never speaks HTTP/2.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	checker := HTTP2Checker(H2COption(true), TimeoutOption(100*time.Millisecond))
+	status, err := checker.Check(context.Background(), ln.Addr().String())
+	if err == nil || status == StatusServing {
+		t.Fatalf("expected failure, got %s %v", status, err)
+	}
+}
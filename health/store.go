@@ -0,0 +1,265 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/go-gost/core/chain"
+	"github.com/go-gost/core/logger"
+	"github.com/go-gost/core/selector"
+)
+
+const (
+	defaultStateTTL = 10 * time.Minute
+	defaultDebounce = time.Second
+	defaultInterval = 30 * time.Second
+)
+
+// NodeState is the persisted health state of a node.
+type NodeState struct {
+	FailCount int64     `json:"failCount"`
+	FailTime  time.Time `json:"failTime"`
+	// BudgetUsed is the bytes used of the node budget in the window starting at BudgetWindow.
+	BudgetUsed   int64     `json:"budgetUsed,omitempty"`
+	BudgetWindow time.Time `json:"budgetWindow,omitempty"`
+	// Time is when the state is saved.
+	Time time.Time `json:"time"`
+}
+
+// Store persists the node health states by node name.
+type Store interface {
+	Load(ctx context.Context) (map[string]NodeState, error)
+	Save(ctx context.Context, states map[string]NodeState) error
+}
+
+type fileStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+// FileStore is a Store saving the states into the file in JSON.
+func FileStore(path string) Store {
+	return &fileStore{path: path}
+}
+
+func (s *fileStore) Load(ctx context.Context) (map[string]NodeState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, err := os.ReadFile(s.path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var states map[string]NodeState
+	if err := json.Unmarshal(b, &states); err != nil {
+		return nil, err
+	}
+	return states, nil
+}
+
+func (s *fileStore) Save(ctx context.Context, states map[string]NodeState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, err := json.Marshal(states)
+	if err != nil {
+		return err
+	}
+
+	// write to a temporary file and rename it, so a crash never leaves a partial file.
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+type PersistOptions struct {
+	// TTL is the maximum age of a persisted state to be restored.
+	TTL time.Duration
+	// Debounce is the delay to coalesce the saves on state changes.
+	Debounce time.Duration
+	// Interval is the period to save the budget usage of the nodes if it is changed.
+	Interval time.Duration
+	Logger   logger.Logger
+}
+
+type PersistOption func(opts *PersistOptions)
+
+func TTLPersistOption(ttl time.Duration) PersistOption {
+	return func(opts *PersistOptions) {
+		opts.TTL = ttl
+	}
+}
+
+func DebouncePersistOption(d time.Duration) PersistOption {
+	return func(opts *PersistOptions) {
+		opts.Debounce = d
+	}
+}
+
+func IntervalPersistOption(d time.Duration) PersistOption {
+	return func(opts *PersistOptions) {
+		opts.Interval = d
+	}
+}
+
+func LoggerPersistOption(logger logger.Logger) PersistOption {
+	return func(opts *PersistOptions) {
+		opts.Logger = logger
+	}
+}
+
+// Persister persists the marker states of the nodes across restarts,
+// so that the recently failed nodes are not flooded before they are re-probed.
+type Persister struct {
+	store   Store
+	options PersistOptions
+}
+
+func NewPersister(store Store, opts ...PersistOption) *Persister {
+	options := PersistOptions{
+		TTL:      defaultStateTTL,
+		Debounce: defaultDebounce,
+		Interval: defaultInterval,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+
+	return &Persister{
+		store:   store,
+		options: options,
+	}
+}
+
+// Restore loads the persisted states and restores the markers of the nodes,
+// the states older than the TTL are ignored.
+func (p *Persister) Restore(ctx context.Context, nodes ...*chain.Node) error {
+	states, err := p.store.Load(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, node := range nodes {
+		st, ok := states[node.Name]
+		if !ok {
+			continue
+		}
+		// the budget usage is kept for its window regardless of the TTL.
+		if st.BudgetUsed > 0 {
+			node.RestoreBudget(st.BudgetUsed, st.BudgetWindow)
+		}
+		if st.FailCount <= 0 || now.Sub(st.Time) > p.options.TTL {
+			continue
+		}
+		if r, ok := node.Marker().(selector.Restorable); ok {
+			r.Restore(st.FailCount, st.FailTime)
+		}
+	}
+	return nil
+}
+
+// Save saves the current states of the nodes.
+func (p *Persister) Save(ctx context.Context, nodes ...*chain.Node) error {
+	now := time.Now()
+	states := make(map[string]NodeState, len(nodes))
+	for _, node := range nodes {
+		st := NodeState{
+			Time: now,
+		}
+		if marker := node.Marker(); marker != nil && marker.Count() > 0 {
+			st.FailCount = marker.Count()
+			st.FailTime = marker.Time()
+		}
+		st.BudgetUsed, st.BudgetWindow = node.BudgetUsage()
+		if st.FailCount == 0 && st.BudgetUsed == 0 {
+			continue
+		}
+		states[node.Name] = st
+	}
+	return p.store.Save(ctx, states)
+}
+
+// Watch saves the states of the nodes on the marked and recovered events from bus,
+// the saves are debounced. The budget usage is saved every Interval if it is changed.
+// It blocks until ctx is done and saves the final states.
+func (p *Persister) Watch(ctx context.Context, bus *chain.NodeEventBus, nodes func() []*chain.Node) {
+	events, cancel := bus.Subscribe(0)
+	defer cancel()
+
+	timer := time.NewTimer(p.options.Debounce)
+	timer.Stop()
+	defer timer.Stop()
+
+	var tick <-chan time.Time
+	if p.options.Interval > 0 {
+		ticker := time.NewTicker(p.options.Interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	var usage int64
+	save := func(ctx context.Context) {
+		ns := nodes()
+		usage = budgetUsage(ns)
+		if err := p.Save(ctx, ns...); err != nil && p.options.Logger != nil {
+			p.options.Logger.Warnf("health: save states: %v", err)
+		}
+	}
+
+	pending := false
+	for {
+		select {
+		case ev := <-events:
+			if ev.Kind == chain.NodeMarked || ev.Kind == chain.NodeRecovered {
+				if !pending {
+					pending = true
+					timer.Reset(p.options.Debounce)
+				}
+			}
+		case <-timer.C:
+			pending = false
+			save(ctx)
+		case <-tick:
+			if budgetUsage(nodes()) != usage {
+				save(ctx)
+			}
+		case <-ctx.Done():
+			save(context.WithoutCancel(ctx))
+			return
+		}
+	}
+}
+
+// budgetUsage returns the total bytes used of the budgets of the nodes in the current windows.
+func budgetUsage(nodes []*chain.Node) int64 {
+	var total int64
+	for _, node := range nodes {
+		used, _ := node.BudgetUsage()
+		total += used
+	}
+	return total
+}
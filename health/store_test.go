@@ -0,0 +1,181 @@
+package health
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-gost/core/chain"
+	"github.com/go-gost/core/common/clock"
+)
+
+func TestPersisterRestore(t *testing.T) {
+	store := FileStore(filepath.Join(t.TempDir(), "health.json"))
+
+	a, b := chain.NewNode("a", "127.0.0.1:80"), chain.NewNode("b", "127.0.0.1:81")
+	a.Marker().Mark()
+	a.Marker().Mark()
+	if err := NewPersister(store).Save(context.Background(), a, b); err != nil {
+		t.Fatal(err)
+	}
+
+	// the nodes are created again after the restart.
+	a, b = chain.NewNode("a", "127.0.0.1:80"), chain.NewNode("b", "127.0.0.1:81")
+	if err := NewPersister(store).Restore(context.Background(), a, b); err != nil {
+		t.Fatal(err)
+	}
+	if n := a.Marker().Count(); n != 2 || a.Marker().Time().IsZero() {
+		t.Fatalf("the marked state is not restored, count %d", n)
+	}
+	if n := b.Marker().Count(); n != 0 {
+		t.Fatalf("the healthy node should stay healthy, count %d", n)
+	}
+}
+
+func TestPersisterRestoreStale(t *testing.T) {
+	store := FileStore(filepath.Join(t.TempDir(), "health.json"))
+	now := time.Now()
+	states := map[string]NodeState{
+		"stale": {FailCount: 3, FailTime: now.Add(-time.Hour), Time: now.Add(-time.Hour)},
+		"fresh": {FailCount: 3, FailTime: now.Add(-time.Minute), Time: now.Add(-time.Minute)},
+	}
+	if err := store.Save(context.Background(), states); err != nil {
+		t.Fatal(err)
+	}
+
+	stale, fresh := chain.NewNode("stale", "127.0.0.1:80"), chain.NewNode("fresh", "127.0.0.1:81")
+	if err := NewPersister(store, TTLPersistOption(10*time.Minute)).Restore(context.Background(), stale, fresh); err != nil {
+		t.Fatal(err)
+	}
+	if n := stale.Marker().Count(); n != 0 {
+		t.Fatalf("the state beyond the TTL should be ignored, count %d", n)
+	}
+	if n := fresh.Marker().Count(); n != 3 {
+		t.Fatalf("the state within the TTL should be restored, count %d", n)
+	}
+}
+
+func TestPersisterRestoreMissing(t *testing.T) {
+	store := FileStore(filepath.Join(t.TempDir(), "health.json"))
+	node := chain.NewNode("a", "127.0.0.1:80")
+	if err := NewPersister(store).Restore(context.Background(), node); err != nil {
+		t.Fatalf("a missing store should not fail, got %v", err)
+	}
+}
+
+func TestPersisterWatch(t *testing.T) {
+	store := FileStore(filepath.Join(t.TempDir(), "health.json"))
+	bus := chain.NewNodeEventBus()
+	node := chain.NewNode("a", "127.0.0.1:80", chain.EventsNodeOption(bus))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		NewPersister(store, DebouncePersistOption(10*time.Millisecond)).
+			Watch(ctx, bus, func() []*chain.Node { return []*chain.Node{node} })
+	}()
+
+	// the save follows the state change after the debounce,
+	// the subscription may not be ready yet, so the node fails again.
+	deadline := time.Now().Add(time.Second)
+	for {
+		node.Marker().Reset()
+		node.Marker().Mark()
+		states, err := store.Load(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if st, ok := states["a"]; ok && st.FailCount > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the state is not saved on change")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	cancel()
+	<-done
+}
+
+func TestPersisterBudget(t *testing.T) {
+	store := FileStore(filepath.Join(t.TempDir(), "health.json"))
+	c := clock.NewFakeClock(time.Now())
+	budget := chain.BudgetNodeOption(&chain.BudgetNodeSettings{Bytes: 1000, Clock: c})
+
+	a := chain.NewNode("a", "127.0.0.1:80", budget)
+	a.AddEgressBytes(1000)
+	if err := NewPersister(store).Save(context.Background(), a); err != nil {
+		t.Fatal(err)
+	}
+
+	// the usage is restored after the reload regardless of the TTL.
+	a = chain.NewNode("a", "127.0.0.1:80", budget)
+	if err := NewPersister(store, TTLPersistOption(time.Nanosecond)).Restore(context.Background(), a); err != nil {
+		t.Fatal(err)
+	}
+	if used, _ := a.BudgetUsage(); used != 1000 || !a.BudgetExceeded() {
+		t.Fatalf("the budget usage is not restored, used %d", used)
+	}
+	if n := a.Marker().Count(); n != 0 {
+		t.Fatalf("the node over budget should not be marked, count %d", n)
+	}
+
+	// the usage of the past window is not restored.
+	c.Advance(48 * time.Hour)
+	a = chain.NewNode("a", "127.0.0.1:80", budget)
+	if err := NewPersister(store).Restore(context.Background(), a); err != nil {
+		t.Fatal(err)
+	}
+	if a.BudgetExceeded() {
+		t.Fatal("the usage of the past window should not be restored")
+	}
+}
+
+func TestPersisterWatchBudget(t *testing.T) {
+	store := FileStore(filepath.Join(t.TempDir(), "health.json"))
+	bus := chain.NewNodeEventBus()
+	budget := chain.BudgetNodeOption(&chain.BudgetNodeSettings{Bytes: 1000})
+	node := chain.NewNode("a", "127.0.0.1:80", chain.EventsNodeOption(bus), budget)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		NewPersister(store, IntervalPersistOption(10*time.Millisecond)).
+			Watch(ctx, bus, func() []*chain.Node { return []*chain.Node{node} })
+	}()
+
+	// the usage is saved periodically without any marker event.
+	node.AddEgressBytes(500)
+	deadline := time.Now().Add(time.Second)
+	for {
+		states, err := store.Load(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if states["a"].BudgetUsed == 500 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the budget usage is not saved")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// the latest usage is saved at shutdown.
+	node.AddEgressBytes(200)
+	cancel()
+	<-done
+
+	// restarted.
+	node = chain.NewNode("a", "127.0.0.1:80", budget)
+	if err := NewPersister(store).Restore(context.Background(), node); err != nil {
+		t.Fatal(err)
+	}
+	if used, _ := node.BudgetUsage(); used != 700 {
+		t.Fatalf("the budget usage after the restart is %d, want 700", used)
+	}
+}
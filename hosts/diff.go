@@ -0,0 +1,37 @@
+package hosts
+
+import (
+	"net"
+	"slices"
+
+	"github.com/go-gost/core/reload"
+)
+
+// Mapping is a mapping from hostname to IP.
+type Mapping struct {
+	Hostname string
+	IP       net.IP
+}
+
+// Diff reports the hostnames added, removed and changed (mapped to a different set of IPs) from old to new.
+func Diff(old, new []Mapping) reload.Changes[string, []net.IP] {
+	return reload.Diff(group(old), group(new), func(a, b []net.IP) bool {
+		return slices.EqualFunc(a, b, net.IP.Equal)
+	})
+}
+
+// group groups the IPs by hostname, the IPs of a hostname are sorted.
+func group(mappings []Mapping) map[string][]net.IP {
+	m := make(map[string][]net.IP)
+	for _, mapping := range mappings {
+		if !slices.ContainsFunc(m[mapping.Hostname], mapping.IP.Equal) {
+			m[mapping.Hostname] = append(m[mapping.Hostname], mapping.IP)
+		}
+	}
+	for _, ips := range m {
+		slices.SortFunc(ips, func(a, b net.IP) int {
+			return slices.Compare(a.To16(), b.To16())
+		})
+	}
+	return m
+}
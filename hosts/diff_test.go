@@ -0,0 +1,47 @@
+package hosts
+
+import (
+	"net"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	old := []Mapping{
+		{Hostname: "a.local", IP: net.ParseIP("10.0.0.1")},
+		{Hostname: "b.local", IP: net.ParseIP("10.0.0.2")},
+		{Hostname: "c.local", IP: net.ParseIP("10.0.0.3")},
+	}
+	new := []Mapping{
+		{Hostname: "b.local", IP: net.ParseIP("10.0.0.2")},
+		{Hostname: "c.local", IP: net.ParseIP("10.0.0.3")},
+		{Hostname: "c.local", IP: net.ParseIP("10.0.0.4")},
+		{Hostname: "d.local", IP: net.ParseIP("10.0.0.5")},
+	}
+
+	c := Diff(old, new)
+	if len(c.Added) != 1 || c.Added[0].Key != "d.local" || !c.Added[0].New[0].Equal(net.ParseIP("10.0.0.5")) {
+		t.Fatalf("unexpected additions %v", c.Added)
+	}
+	if len(c.Removed) != 1 || c.Removed[0].Key != "a.local" {
+		t.Fatalf("unexpected removals %v", c.Removed)
+	}
+	if len(c.Changed) != 1 || c.Changed[0].Key != "c.local" || len(c.Changed[0].Old) != 1 || len(c.Changed[0].New) != 2 {
+		t.Fatalf("unexpected changes %v", c.Changed)
+	}
+}
+
+func TestDiffNoop(t *testing.T) {
+	old := []Mapping{
+		{Hostname: "a.local", IP: net.ParseIP("10.0.0.2")},
+		{Hostname: "a.local", IP: net.ParseIP("10.0.0.1")},
+	}
+	// the order of the IPs is ignored, IPv4 and IPv4-mapped forms are the same.
+	new := []Mapping{
+		{Hostname: "a.local", IP: net.ParseIP("10.0.0.1").To4()},
+		{Hostname: "a.local", IP: net.ParseIP("10.0.0.2")},
+		{Hostname: "a.local", IP: net.ParseIP("10.0.0.2")},
+	}
+	if c := Diff(old, new); !c.IsEmpty() {
+		t.Fatalf("expected empty diff, got %+v", c)
+	}
+}
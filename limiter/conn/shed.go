@@ -0,0 +1,119 @@
+package conn
+
+import (
+	"context"
+	"sync"
+
+	"github.com/go-gost/core/common/ctxvalue"
+)
+
+type ShedOptions struct {
+	// Thresholds maps the priority class to the utilization ratio in (0, 1],
+	// at and above which the new connections of the class are shed.
+	Thresholds map[int]float64
+	// DefaultThreshold is the threshold of the priority classes not in Thresholds, default is 1.
+	DefaultThreshold float64
+}
+
+type ShedOption func(opts *ShedOptions)
+
+func ThresholdShedOption(priority int, ratio float64) ShedOption {
+	return func(opts *ShedOptions) {
+		if opts.Thresholds == nil {
+			opts.Thresholds = make(map[int]float64)
+		}
+		opts.Thresholds[priority] = ratio
+	}
+}
+
+func DefaultThresholdShedOption(ratio float64) ShedOption {
+	return func(opts *ShedOptions) {
+		opts.DefaultThreshold = ratio
+	}
+}
+
+// Shedder is a connection limiter with graceful load shedding:
+// as the utilization grows, the low priority connections (see ctxvalue.ContextWithPriority)
+// are rejected before the high priority ones, which are only rejected at the hard limit.
+type Shedder struct {
+	limit   int
+	active  int
+	options ShedOptions
+	mu      sync.Mutex
+}
+
+// NewShedder creates a Shedder with the hard limit of concurrent connections.
+func NewShedder(limit int, opts ...ShedOption) *Shedder {
+	options := ShedOptions{
+		DefaultThreshold: 1,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+
+	return &Shedder{
+		limit:   limit,
+		options: options,
+	}
+}
+
+// Allow implements Limiter with the default priority, n is negative to release the connections.
+func (s *Shedder) Allow(n int) bool {
+	return s.allow(0, n)
+}
+
+func (s *Shedder) Limit() int {
+	return s.limit
+}
+
+// AllowContext is like Allow, the priority class is from ctx.
+func (s *Shedder) AllowContext(ctx context.Context, n int) bool {
+	return s.allow(ctxvalue.PriorityFromContext(ctx), n)
+}
+
+func (s *Shedder) allow(priority int, n int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if n <= 0 {
+		s.active = max(s.active+n, 0)
+		return true
+	}
+	if s.limit > 0 && s.shed(priority, s.active+n) {
+		return false
+	}
+	s.active += n
+	return true
+}
+
+// Shed reports whether a new connection of the priority class from ctx would be shed at the current utilization.
+func (s *Shedder) Shed(ctx context.Context) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.limit > 0 && s.shed(ctxvalue.PriorityFromContext(ctx), s.active+1)
+}
+
+func (s *Shedder) shed(priority int, active int) bool {
+	if active > s.limit {
+		return true
+	}
+	threshold, ok := s.options.Thresholds[priority]
+	if !ok {
+		threshold = s.options.DefaultThreshold
+	}
+	return threshold < 1 && float64(active) > threshold*float64(s.limit)
+}
+
+// Utilization returns the ratio of the active connections to the limit.
+func (s *Shedder) Utilization() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.limit <= 0 {
+		return 0
+	}
+	return float64(s.active) / float64(s.limit)
+}
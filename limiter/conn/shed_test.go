@@ -0,0 +1,92 @@
+package conn
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-gost/core/common/ctxvalue"
+	"github.com/go-gost/core/selector"
+)
+
+const (
+	lowPriority  = 0
+	highPriority = 10
+)
+
+func TestShedderPriority(t *testing.T) {
+	s := NewShedder(10, ThresholdShedOption(lowPriority, 0.5))
+	low := ctxvalue.ContextWithPriority(context.Background(), lowPriority)
+	high := ctxvalue.ContextWithPriority(context.Background(), highPriority)
+
+	for i := 0; i < 5; i++ {
+		if !s.AllowContext(low, 1) {
+			t.Fatalf("low priority connection %d should pass below the threshold", i)
+		}
+	}
+
+	// saturated for the low priority class.
+	if s.AllowContext(low, 1) || !s.Shed(low) {
+		t.Fatal("low priority connection should be shed above the threshold")
+	}
+	for i := 0; i < 5; i++ {
+		if s.Shed(high) || !s.AllowContext(high, 1) {
+			t.Fatalf("high priority connection %d should pass below the hard limit", i)
+		}
+	}
+
+	// the hard limit.
+	if s.AllowContext(high, 1) || !s.Shed(high) {
+		t.Fatal("high priority connection should be rejected at the hard limit")
+	}
+	if u := s.Utilization(); u != 1 {
+		t.Fatalf("unexpected utilization %v", u)
+	}
+
+	// released
+	s.Allow(-6)
+	if !s.AllowContext(low, 1) {
+		t.Fatal("low priority connection should pass after release")
+	}
+}
+
+func TestShedderDefaultThreshold(t *testing.T) {
+	s := NewShedder(4, DefaultThresholdShedOption(0.5), ThresholdShedOption(highPriority, 1))
+	high := ctxvalue.ContextWithPriority(context.Background(), highPriority)
+
+	// the classes not configured, including the default one, use the default threshold.
+	if !s.Allow(1) || !s.Allow(1) || s.Allow(1) {
+		t.Fatal("the default priority should be shed above the default threshold")
+	}
+	if !s.AllowContext(high, 1) || !s.AllowContext(high, 1) || s.AllowContext(high, 1) {
+		t.Fatal("the high priority should only be rejected at the hard limit")
+	}
+}
+
+func TestShedderUnlimited(t *testing.T) {
+	s := NewShedder(0, DefaultThresholdShedOption(0.1))
+	for i := 0; i < 100; i++ {
+		if !s.Allow(1) {
+			t.Fatal("zero limit should be unlimited")
+		}
+	}
+	if s.Shed(context.Background()) || s.Utilization() != 0 {
+		t.Fatal("zero limit should never shed")
+	}
+}
+
+func TestShedFilter(t *testing.T) {
+	s := NewShedder(2, ThresholdShedOption(lowPriority, 0.5))
+	s.Allow(1)
+
+	filter := selector.ShedFilter[string](s)
+	low := ctxvalue.ContextWithPriority(context.Background(), lowPriority)
+	high := ctxvalue.ContextWithPriority(context.Background(), highPriority)
+
+	// the shed request fails fast in the selection.
+	if vs := filter.Filter(low, "a", "b"); len(vs) != 0 {
+		t.Fatalf("low priority request should be shed, got %v", vs)
+	}
+	if vs := filter.Filter(high, "a", "b"); len(vs) != 2 {
+		t.Fatalf("high priority request should pass, got %v", vs)
+	}
+}
@@ -0,0 +1,119 @@
+package rate
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/go-gost/core/common/clock"
+)
+
+var (
+	ErrQueueFull = errors.New("rate: leaky bucket queue is full")
+)
+
+// Waiter is a Limiter which can delay the requests to shape the traffic.
+type Waiter interface {
+	Limiter
+	// Wait blocks until n units can be sent at the limit rate,
+	// it fails immediately if the extra delay would exceed the queue bound.
+	Wait(ctx context.Context, n int) error
+}
+
+type LeakyBucketOptions struct {
+	Clock clock.Clock
+}
+
+type LeakyBucketOption func(opts *LeakyBucketOptions)
+
+func ClockLeakyBucketOption(c clock.Clock) LeakyBucketOption {
+	return func(opts *LeakyBucketOptions) {
+		opts.Clock = c
+	}
+}
+
+type leakyBucket struct {
+	clock clock.Clock
+	rate  float64
+	// queue is the bound of the queued units.
+	queue float64
+	// next is the time when the queued units are all drained.
+	next time.Time
+	mu   sync.Mutex
+}
+
+// NewLeakyBucket creates a leaky bucket shaper which outputs at a steady rate of r units per second,
+// at most queue units can be delayed, excess requests are dropped.
+// Unlike a token bucket, it does not allow bursts.
+func NewLeakyBucket(r float64, queue int, opts ...LeakyBucketOption) Waiter {
+	var options LeakyBucketOptions
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+
+	return &leakyBucket{
+		clock: clock.OrDefault(options.Clock),
+		rate:  r,
+		queue: float64(max(queue, 0)),
+	}
+}
+
+// reserve reserves n units and returns the delay before they can be sent.
+func (b *leakyBucket) reserve(n int, maxDelay time.Duration) (time.Duration, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.clock.Now()
+	start := b.next
+	if start.Before(now) {
+		start = now
+	}
+
+	delay := start.Sub(now)
+	if delay > maxDelay {
+		return 0, false
+	}
+
+	b.next = start.Add(time.Duration(float64(n) / b.rate * float64(time.Second)))
+	return delay, true
+}
+
+// Allow reports whether n units can be sent without delay.
+func (b *leakyBucket) Allow(n int) bool {
+	if b.rate <= 0 {
+		return true
+	}
+	_, ok := b.reserve(n, 0)
+	return ok
+}
+
+func (b *leakyBucket) Wait(ctx context.Context, n int) error {
+	if b.rate <= 0 {
+		return nil
+	}
+
+	maxDelay := time.Duration(b.queue / b.rate * float64(time.Second))
+	delay, ok := b.reserve(n, maxDelay)
+	if !ok {
+		return ErrQueueFull
+	}
+	if delay <= 0 {
+		return nil
+	}
+
+	t := b.clock.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-t.C():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (b *leakyBucket) Limit() float64 {
+	return b.rate
+}
@@ -0,0 +1,122 @@
+package rate
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-gost/core/common/clock"
+)
+
+func TestLeakyBucketAllowNoBurst(t *testing.T) {
+	c := clock.NewFakeClock(time.Unix(1000, 0))
+	b := NewLeakyBucket(10, 100, ClockLeakyBucketOption(c))
+
+	if !b.Allow(1) {
+		t.Fatal("the first unit should be allowed")
+	}
+	// no burst even though the bucket was idle.
+	if b.Allow(1) {
+		t.Fatal("the second unit in the same instant should not be allowed")
+	}
+
+	c.Advance(50 * time.Millisecond)
+	if b.Allow(1) {
+		t.Fatal("unit should not be allowed before the interval")
+	}
+	c.Advance(50 * time.Millisecond)
+	if !b.Allow(1) {
+		t.Fatal("unit should be allowed after the interval")
+	}
+
+	// idle time does not accumulate.
+	c.Advance(time.Hour)
+	if !b.Allow(1) || b.Allow(1) {
+		t.Fatal("only one unit should be allowed after idle")
+	}
+}
+
+func TestLeakyBucketSmooth(t *testing.T) {
+	c := clock.NewFakeClock(time.Unix(1000, 0))
+	b := NewLeakyBucket(10, 100, ClockLeakyBucketOption(c)).(*leakyBucket)
+
+	// the units requested at once are spaced evenly by the rate.
+	for i := 0; i < 10; i++ {
+		delay, ok := b.reserve(1, time.Hour)
+		if !ok {
+			t.Fatalf("reservation %d failed", i)
+		}
+		if want := time.Duration(i) * 100 * time.Millisecond; delay != want {
+			t.Fatalf("reservation %d: delay %s, want %s", i, delay, want)
+		}
+	}
+}
+
+func TestLeakyBucketWait(t *testing.T) {
+	c := clock.NewFakeClock(time.Unix(1000, 0))
+	b := NewLeakyBucket(10, 10, ClockLeakyBucketOption(c))
+
+	if err := b.Wait(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- b.Wait(context.Background(), 1)
+	}()
+
+	// the waiter is released once the clock reaches its slot.
+	for {
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatal(err)
+			}
+			if now := c.Now(); now.Before(time.Unix(1000, 0).Add(100 * time.Millisecond)) {
+				t.Fatalf("waiter released early at %s", now)
+			}
+			return
+		default:
+			c.Advance(10 * time.Millisecond)
+			time.Sleep(time.Millisecond)
+		}
+	}
+}
+
+func TestLeakyBucketQueueFull(t *testing.T) {
+	c := clock.NewFakeClock(time.Unix(1000, 0))
+	// at most 5 units (500ms) are queued.
+	b := NewLeakyBucket(10, 5, ClockLeakyBucketOption(c))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := b.Wait(ctx, 1); err != nil {
+		t.Fatalf("the first unit should not wait, got %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		if err := b.Wait(ctx, 1); !errors.Is(err, context.Canceled) {
+			t.Fatalf("unit %d should be queued, got %v", i, err)
+		}
+	}
+	// overdriven
+	if err := b.Wait(ctx, 1); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("expected ErrQueueFull, got %v", err)
+	}
+
+	// the queue drains at the rate.
+	c.Advance(100 * time.Millisecond)
+	if err := b.Wait(ctx, 1); !errors.Is(err, context.Canceled) {
+		t.Fatalf("unit should be queued after draining, got %v", err)
+	}
+}
+
+func TestLeakyBucketUnlimited(t *testing.T) {
+	b := NewLeakyBucket(0, 0)
+	for i := 0; i < 100; i++ {
+		if !b.Allow(1) || b.Wait(context.Background(), 1) != nil {
+			t.Fatal("zero rate should be unlimited")
+		}
+	}
+}
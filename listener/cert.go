@@ -0,0 +1,205 @@
+package listener
+
+import (
+	"crypto/tls"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-gost/core/logger"
+)
+
+const (
+	defaultCertReloadInterval = 10 * time.Second
+	// certReloadDelay coalesces the events of one rotation, e.g. the certificate and key written in turn.
+	certReloadDelay = 100 * time.Millisecond
+)
+
+type certFileStat struct {
+	modTime time.Time
+	size    int64
+}
+
+// CertReloader serves the listener certificate loaded from the certificate and key files,
+// the directories of the files are watched for changes and the certificate is reloaded for the new handshakes,
+// while the established connections keep the old one. The directories rather than the files are watched,
+// so the rotation by swapping symlinks (e.g. by cert-manager) is seen. If the files can not be watched,
+// they are polled instead.
+// A new certificate failing to load is rejected, and the previous one stays active.
+type CertReloader struct {
+	certFile string
+	keyFile  string
+	interval time.Duration
+	logger   logger.Logger
+	cert     atomic.Pointer[tls.Certificate]
+	stats    [2]certFileStat
+	mu       sync.Mutex
+	done     chan struct{}
+	once     sync.Once
+}
+
+// NewCertReloader loads the certificate from the files and starts watching them,
+// interval is the polling interval if the files can not be watched. The logger can be nil.
+func NewCertReloader(certFile, keyFile string, interval time.Duration, log logger.Logger) (*CertReloader, error) {
+	if interval <= 0 {
+		interval = defaultCertReloadInterval
+	}
+
+	r := &CertReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		interval: interval,
+		logger:   log,
+		done:     make(chan struct{}),
+	}
+	stats, _ := r.stat()
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	r.stats = stats
+
+	// the watcher is set up before returning to not miss the changes right after.
+	w, err := r.newWatcher()
+	if err != nil {
+		if log != nil {
+			log.Warnf("watch certificate %s: %v, poll every %s", certFile, err, interval)
+		}
+		go r.poll()
+	} else {
+		go r.watch(w)
+	}
+	return r, nil
+}
+
+// GetCertificate is to be used as tls.Config.GetCertificate.
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.cert.Load(), nil
+}
+
+// TLSConfig returns a clone of base serving the reloaded certificate, base can be nil.
+func (r *CertReloader) TLSConfig(base *tls.Config) *tls.Config {
+	var cfg *tls.Config
+	if base != nil {
+		cfg = base.Clone()
+	} else {
+		cfg = &tls.Config{}
+	}
+	cfg.Certificates = nil
+	cfg.GetCertificate = r.GetCertificate
+	return cfg
+}
+
+// Reload checks the files and reloads the certificate if they are changed.
+func (r *CertReloader) Reload() error {
+	return r.reload(false)
+}
+
+// reload reloads the certificate, if force is false, only if the files are changed.
+func (r *CertReloader) reload(force bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stats, err := r.stat()
+	if err != nil {
+		return err
+	}
+	if !force && stats == r.stats {
+		return nil
+	}
+	if err := r.load(); err != nil {
+		return err
+	}
+	r.stats = stats
+	return nil
+}
+
+func (r *CertReloader) Close() error {
+	r.once.Do(func() {
+		close(r.done)
+	})
+	return nil
+}
+
+func (r *CertReloader) watch(w *fsnotify.Watcher) {
+	defer w.Close()
+
+	timer := time.NewTimer(certReloadDelay)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-w.Events:
+			timer.Reset(certReloadDelay)
+		case err := <-w.Errors:
+			if r.logger != nil {
+				r.logger.Warnf("watch certificate %s: %v", r.certFile, err)
+			}
+		case <-timer.C:
+			// the file stats can be unchanged by a rotation within the timestamp granularity.
+			r.tryReload(true)
+		case <-r.done:
+			return
+		}
+	}
+}
+
+func (r *CertReloader) newWatcher() (*fsnotify.Watcher, error) {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	for _, dir := range []string{filepath.Dir(r.certFile), filepath.Dir(r.keyFile)} {
+		if err := w.Add(dir); err != nil {
+			w.Close()
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+func (r *CertReloader) poll() {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.tryReload(false)
+		case <-r.done:
+			return
+		}
+	}
+}
+
+func (r *CertReloader) tryReload(force bool) {
+	if err := r.reload(force); err != nil && r.logger != nil {
+		r.logger.Warnf("reload certificate %s: %v, keep the current one", r.certFile, err)
+	}
+}
+
+func (r *CertReloader) stat() (stats [2]certFileStat, err error) {
+	for i, name := range []string{r.certFile, r.keyFile} {
+		fi, err := os.Stat(name)
+		if err != nil {
+			return stats, err
+		}
+		stats[i] = certFileStat{
+			modTime: fi.ModTime(),
+			size:    fi.Size(),
+		}
+	}
+	return
+}
+
+func (r *CertReloader) load() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	r.cert.Store(&cert)
+	return nil
+}
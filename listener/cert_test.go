@@ -0,0 +1,206 @@
+package listener
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCertFiles writes the certificate and key of cert as PEM files in dir.
+func writeCertFiles(t *testing.T, dir string, cert tls.Certificate) (certFile, keyFile string) {
+	t.Helper()
+
+	key, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile = filepath.Join(dir, "tls.crt")
+	keyFile = filepath.Join(dir, "tls.key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: key}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+// servedCert returns the leaf certificate served to a new handshake.
+func servedCert(t *testing.T, cfg *tls.Config) []byte {
+	t.Helper()
+
+	ln := tls.NewListener(listenTCP(t), cfg)
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.(*tls.Conn).Handshake()
+	}()
+
+	conn, err := tls.Dial("tcp", ln.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	return conn.ConnectionState().PeerCertificates[0].Raw
+}
+
+// waitServedCert waits until the certificate served to new handshakes is want.
+func waitServedCert(t *testing.T, cfg *tls.Config, want tls.Certificate) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !bytes.Equal(servedCert(t, cfg), want.Certificate[0]) {
+		if time.Now().After(deadline) {
+			t.Fatal("the rotated certificate is not served")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	old := newTestCert(t, "old.example.com")
+	certFile, keyFile := writeCertFiles(t, dir, old)
+
+	r, err := NewCertReloader(certFile, keyFile, time.Hour, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	cfg := r.TLSConfig(nil)
+
+	if !bytes.Equal(servedCert(t, cfg), old.Certificate[0]) {
+		t.Fatal("the loaded certificate is not served")
+	}
+
+	// the established connection keeps the old certificate.
+	ln := tls.NewListener(listenTCP(t), cfg)
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			conn.(*tls.Conn).Handshake()
+		}
+	}()
+	conn, err := tls.Dial("tcp", ln.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// the rotation is seen by the watcher long before the polling interval.
+	rotated := newTestCert(t, "new.example.com")
+	writeCertFiles(t, dir, rotated)
+	waitServedCert(t, cfg, rotated)
+
+	if !bytes.Equal(conn.ConnectionState().PeerCertificates[0].Raw, old.Certificate[0]) {
+		t.Fatal("the established connection should keep the old certificate")
+	}
+}
+
+func TestCertReloaderSymlink(t *testing.T) {
+	// the layout of a kubernetes secret volume updated by swapping the ..data symlink.
+	dir := t.TempDir()
+	old := newTestCert(t, "old.example.com")
+	if err := os.Mkdir(filepath.Join(dir, "v1"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeCertFiles(t, filepath.Join(dir, "v1"), old)
+	if err := os.Symlink("v1", filepath.Join(dir, "..data")); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"tls.crt", "tls.key"} {
+		if err := os.Symlink(filepath.Join("..data", name), filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r, err := NewCertReloader(filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key"), time.Hour, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	cfg := r.TLSConfig(nil)
+
+	rotated := newTestCert(t, "new.example.com")
+	if err := os.Mkdir(filepath.Join(dir, "v2"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeCertFiles(t, filepath.Join(dir, "v2"), rotated)
+	if err := os.Symlink("v2", filepath.Join(dir, "..data_tmp")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")); err != nil {
+		t.Fatal(err)
+	}
+	waitServedCert(t, cfg, rotated)
+}
+
+func TestCertReloaderInvalid(t *testing.T) {
+	dir := t.TempDir()
+	old := newTestCert(t, "old.example.com")
+	certFile, keyFile := writeCertFiles(t, dir, old)
+
+	r, err := NewCertReloader(certFile, keyFile, time.Hour, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	// the certificate not matching the key is rejected.
+	other := newTestCert(t, "other.example.com")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: other.Certificate[0]}), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Reload(); err == nil {
+		t.Fatal("expected error of the mismatched key")
+	}
+	if err := os.WriteFile(certFile, []byte("garbage"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Reload(); err == nil {
+		t.Fatal("expected error of the invalid certificate")
+	}
+
+	// the previous certificate stays active, also after the watcher sees the change.
+	time.Sleep(5 * certReloadDelay)
+	if !bytes.Equal(servedCert(t, r.TLSConfig(nil)), old.Certificate[0]) {
+		t.Fatal("the previous certificate should stay active")
+	}
+
+	if _, err := NewCertReloader(certFile, keyFile, time.Hour, nil); err == nil {
+		t.Fatal("expected error of loading the invalid certificate")
+	}
+}
+
+func TestCertReloaderPoll(t *testing.T) {
+	dir := t.TempDir()
+	old := newTestCert(t, "old.example.com")
+	certFile, keyFile := writeCertFiles(t, dir, old)
+
+	r := &CertReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		interval: 10 * time.Millisecond,
+		done:     make(chan struct{}),
+	}
+	r.stats, _ = r.stat()
+	if err := r.load(); err != nil {
+		t.Fatal(err)
+	}
+	go r.poll()
+	defer r.Close()
+
+	rotated := newTestCert(t, "new.example.com")
+	writeCertFiles(t, dir, rotated)
+	waitServedCert(t, r.TLSConfig(nil), rotated)
+}
@@ -28,6 +28,7 @@ type Options struct {
 	ProxyProtocol  int
 	Netns          string
 	Router         chain.Router
+	ReusePort      int
 }
 
 type Option func(opts *Options)
@@ -109,3 +110,10 @@ func RouterOption(router chain.Router) Option {
 		opts.Router = router
 	}
 }
+
+// ReusePortOption sets the number of sockets with SO_REUSEPORT to accept on, see ListenReusePort.
+func ReusePortOption(n int) Option {
+	return func(opts *Options) {
+		opts.ReusePort = n
+	}
+}
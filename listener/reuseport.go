@@ -0,0 +1,125 @@
+package listener
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"syscall"
+)
+
+type multiListener struct {
+	listeners []net.Listener
+	cc        chan acceptResult
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// ListenReusePort listens on the address with n sockets bound with SO_REUSEPORT,
+// each socket has its own acceptor goroutine and the accepted connections of all
+// the acceptors are returned by the Accept of the returned listener.
+// It falls back to a single socket if n <= 1 or SO_REUSEPORT is unsupported.
+func ListenReusePort(ctx context.Context, network, address string, n int) (net.Listener, error) {
+	if n <= 1 || !reusePortSupported {
+		var lc net.ListenConfig
+		return lc.Listen(ctx, network, address)
+	}
+
+	listeners, err := listenReusePort(ctx, network, address, n)
+	if err != nil {
+		return nil, err
+	}
+
+	ml := &multiListener{
+		listeners: listeners,
+		cc:        make(chan acceptResult, n),
+		closed:    make(chan struct{}),
+	}
+	for _, ln := range ml.listeners {
+		go ml.acceptLoop(ln)
+	}
+	return ml, nil
+}
+
+// listenReusePort opens n sockets bound to the same address with SO_REUSEPORT.
+func listenReusePort(ctx context.Context, network, address string, n int) ([]net.Listener, error) {
+	lc := net.ListenConfig{
+		Control: func(network, address string, c syscall.RawConn) error {
+			var serr error
+			if err := c.Control(func(fd uintptr) {
+				serr = setReusePort(fd)
+			}); err != nil {
+				return err
+			}
+			return serr
+		},
+	}
+
+	var listeners []net.Listener
+	for i := 0; i < n; i++ {
+		// the following sockets must bind to the same resolved address, e.g. for port 0.
+		if i > 0 {
+			address = listeners[0].Addr().String()
+		}
+		ln, err := lc.Listen(ctx, network, address)
+		if err != nil {
+			for _, ln := range listeners {
+				ln.Close()
+			}
+			return nil, err
+		}
+		listeners = append(listeners, ln)
+	}
+	return listeners, nil
+}
+
+func (l *multiListener) acceptLoop(ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		select {
+		case l.cc <- acceptResult{conn: conn, err: err}:
+		case <-l.closed:
+			if conn != nil {
+				conn.Close()
+			}
+			return
+		}
+		if err != nil && errors.Is(err, net.ErrClosed) {
+			return
+		}
+	}
+}
+
+func (l *multiListener) Accept() (net.Conn, error) {
+	select {
+	case r := <-l.cc:
+		// the result raced with Close, e.g. the error of the closed acceptor.
+		select {
+		case <-l.closed:
+			if r.conn != nil {
+				r.conn.Close()
+			}
+			return nil, ErrClosed
+		default:
+		}
+		return r.conn, r.err
+	case <-l.closed:
+		return nil, ErrClosed
+	}
+}
+
+func (l *multiListener) Addr() net.Addr {
+	return l.listeners[0].Addr()
+}
+
+func (l *multiListener) Close() (err error) {
+	l.closeOnce.Do(func() {
+		close(l.closed)
+		for _, ln := range l.listeners {
+			if e := ln.Close(); e != nil && err == nil {
+				err = e
+			}
+		}
+	})
+	return
+}
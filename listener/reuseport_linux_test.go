@@ -0,0 +1,73 @@
+package listener
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+)
+
+// countListener counts the accepted connections.
+type countListener struct {
+	net.Listener
+	n atomic.Int64
+}
+
+func (l *countListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err == nil {
+		l.n.Add(1)
+	}
+	return conn, err
+}
+
+func TestListenReusePort(t *testing.T) {
+	ln, err := ListenReusePort(context.Background(), "tcp", "127.0.0.1:0", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	ml, ok := ln.(*multiListener)
+	if !ok || len(ml.listeners) != 4 {
+		t.Fatalf("expected 4 sockets, got %T", ln)
+	}
+	for _, l := range ml.listeners {
+		if l.Addr().String() != ln.Addr().String() {
+			t.Fatalf("socket bound to %s, want %s", l.Addr(), ln.Addr())
+		}
+	}
+	acceptAll(t, ln, 32)
+}
+
+func TestListenReusePortShare(t *testing.T) {
+	listeners, err := listenReusePort(context.Background(), "tcp", "127.0.0.1:0", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ml := &multiListener{
+		cc:     make(chan acceptResult, 4),
+		closed: make(chan struct{}),
+	}
+	var counters []*countListener
+	for _, ln := range listeners {
+		cl := &countListener{Listener: ln}
+		counters = append(counters, cl)
+		ml.listeners = append(ml.listeners, cl)
+		go ml.acceptLoop(cl)
+	}
+	defer ml.Close()
+
+	acceptAll(t, ml, 256)
+
+	var used int
+	for _, cl := range counters {
+		if cl.n.Load() > 0 {
+			used++
+		}
+	}
+	if used < 2 {
+		t.Fatalf("the connections are not shared by the acceptors, %d of 4 used", used)
+	}
+}
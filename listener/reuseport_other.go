@@ -0,0 +1,9 @@
+//go:build !linux
+
+package listener
+
+const reusePortSupported = false
+
+func setReusePort(fd uintptr) error {
+	return nil
+}
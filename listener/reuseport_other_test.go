@@ -0,0 +1,21 @@
+//go:build !linux
+
+package listener
+
+import (
+	"context"
+	"testing"
+)
+
+func TestListenReusePortFallback(t *testing.T) {
+	ln, err := ListenReusePort(context.Background(), "tcp", "127.0.0.1:0", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	if _, ok := ln.(*multiListener); ok {
+		t.Fatal("expected a single socket listener without SO_REUSEPORT")
+	}
+	acceptAll(t, ln, 8)
+}
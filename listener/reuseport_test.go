@@ -0,0 +1,64 @@
+package listener
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+)
+
+// acceptAll accepts n connections dialed to ln.
+func acceptAll(t *testing.T, ln net.Listener, n int) {
+	t.Helper()
+	errc := make(chan error, 1)
+	go func() {
+		for i := 0; i < n; i++ {
+			conn, err := net.Dial("tcp", ln.Addr().String())
+			if err != nil {
+				errc <- err
+				return
+			}
+			defer conn.Close()
+		}
+		errc <- nil
+	}()
+
+	for i := 0; i < n; i++ {
+		conn, err := ln.Accept()
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.Close()
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestListenReusePortSingle(t *testing.T) {
+	ln, err := ListenReusePort(context.Background(), "tcp", "127.0.0.1:0", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	if _, ok := ln.(*multiListener); ok {
+		t.Fatal("expected a single socket listener")
+	}
+	acceptAll(t, ln, 8)
+}
+
+func TestListenReusePortClose(t *testing.T) {
+	ln, err := ListenReusePort(context.Background(), "tcp", "127.0.0.1:0", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	acceptAll(t, ln, 8)
+
+	ln.Close()
+	if _, err := ln.Accept(); err == nil {
+		t.Fatal("expected error on closed listener")
+	} else if _, ok := ln.(*multiListener); ok && !errors.Is(err, ErrClosed) {
+		t.Fatalf("expected ErrClosed, got %v", err)
+	}
+}
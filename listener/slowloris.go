@@ -0,0 +1,164 @@
+package listener
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	defaultHandshakeGrace = time.Second
+)
+
+var (
+	ErrHandshakeTimeout = errors.New("handshake timeout")
+	ErrHandshakeTooSlow = errors.New("handshake too slow")
+)
+
+type HandshakeOptions struct {
+	// Timeout is the deadline from the accept for completing the initial handshake, 0 means no deadline.
+	Timeout time.Duration
+	// MinRate is the minimum throughput in bytes per second during the handshake, 0 means no limit.
+	MinRate int64
+	// Grace is the initial period the MinRate is not enforced in, default is 1s.
+	Grace time.Duration
+}
+
+type HandshakeOption func(opts *HandshakeOptions)
+
+func TimeoutHandshakeOption(timeout time.Duration) HandshakeOption {
+	return func(opts *HandshakeOptions) {
+		opts.Timeout = timeout
+	}
+}
+
+func MinRateHandshakeOption(rate int64, grace time.Duration) HandshakeOption {
+	return func(opts *HandshakeOptions) {
+		opts.MinRate = rate
+		opts.Grace = grace
+	}
+}
+
+type handshakeListener struct {
+	net.Listener
+	options HandshakeOptions
+}
+
+// HandshakeListener wraps ln to mitigate the slow-loris attacks: the initial protocol handshake
+// (e.g. TLS ClientHello to Finished, or the full HTTP request headers) of the accepted connections
+// must complete within Timeout, and the connections dribbling the bytes slower than MinRate are closed.
+// Read returns ErrHandshakeTimeout or ErrHandshakeTooSlow after that.
+// The protections are lifted by HandshakeDone once the handshake is complete, see also TLSHandshake.
+func HandshakeListener(ln net.Listener, opts ...HandshakeOption) net.Listener {
+	var options HandshakeOptions
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+	if options.Timeout <= 0 && options.MinRate <= 0 {
+		return ln
+	}
+	if options.Grace <= 0 {
+		options.Grace = defaultHandshakeGrace
+	}
+
+	return &handshakeListener{
+		Listener: ln,
+		options:  options,
+	}
+}
+
+func (l *handshakeListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &handshakeConn{
+		Conn:    conn,
+		options: l.options,
+		start:   time.Now(),
+	}, nil
+}
+
+type handshakeConn struct {
+	net.Conn
+	options HandshakeOptions
+	start   time.Time
+	read    int64
+	done    bool
+	mu      sync.Mutex
+}
+
+func (c *handshakeConn) Read(b []byte) (n int, err error) {
+	c.mu.Lock()
+	if c.done {
+		c.mu.Unlock()
+		return c.Conn.Read(b)
+	}
+	deadline, slow := c.deadline()
+	c.Conn.SetReadDeadline(deadline)
+	c.mu.Unlock()
+
+	n, err = c.Conn.Read(b)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.read += int64(n)
+	if ne, ok := err.(net.Error); ok && ne.Timeout() && !c.done {
+		c.Conn.Close()
+		if slow {
+			return n, ErrHandshakeTooSlow
+		}
+		return n, ErrHandshakeTimeout
+	}
+	return
+}
+
+// deadline returns the read deadline for the current progress, slow is true if it is set by the MinRate.
+func (c *handshakeConn) deadline() (deadline time.Time, slow bool) {
+	if c.options.Timeout > 0 {
+		deadline = c.start.Add(c.options.Timeout)
+	}
+	if c.options.MinRate > 0 {
+		// the time the average throughput falls below the MinRate if no more bytes arrive.
+		d := max(c.options.Grace, time.Duration(c.read*int64(time.Second)/c.options.MinRate))
+		if t := c.start.Add(d); deadline.IsZero() || t.Before(deadline) {
+			deadline, slow = t, true
+		}
+	}
+	return
+}
+
+func (c *handshakeConn) HandshakeDone() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.done {
+		c.done = true
+		c.Conn.SetReadDeadline(time.Time{})
+	}
+}
+
+// HandshakeDone lifts the handshake protections of the connection accepted by HandshakeListener,
+// it should be called once the initial handshake is complete. It is a no-op for the other connections.
+func HandshakeDone(conn net.Conn) {
+	if c, ok := conn.(interface{ HandshakeDone() }); ok {
+		c.HandshakeDone()
+	}
+}
+
+// TLSHandshake does the TLS server handshake over the connection accepted by HandshakeListener,
+// and lifts the handshake protections on success.
+func TLSHandshake(ctx context.Context, conn net.Conn, config *tls.Config) (*tls.Conn, error) {
+	tc := tls.Server(conn, config)
+	if err := tc.HandshakeContext(ctx); err != nil {
+		return nil, err
+	}
+	HandshakeDone(conn)
+	return tc, nil
+}
@@ -0,0 +1,186 @@
+package listener
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+const httpHeader = "GET / HTTP/1.1\r\nHost: example.com\r\nUser-Agent: test\r\n\r\n"
+
+// drip writes s to the listener ln byte by byte with the interval, until it is done or the conn fails.
+func drip(t *testing.T, ln net.Listener, s string, interval time.Duration) {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	go func() {
+		for i := 0; i < len(s); i++ {
+			if _, err := conn.Write([]byte{s[i]}); err != nil {
+				return
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
+
+// readHeader reads conn until the end of the HTTP request headers.
+func readHeader(conn net.Conn) error {
+	var header []byte
+	b := make([]byte, 64)
+	for !strings.HasSuffix(string(header), "\r\n\r\n") {
+		n, err := conn.Read(b)
+		header = append(header, b[:n]...)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestHandshakeListenerSlowDrip(t *testing.T) {
+	ln := HandshakeListener(listenTCP(t), TimeoutHandshakeOption(10*time.Second), MinRateHandshakeOption(100, 100*time.Millisecond))
+	defer ln.Close()
+
+	// 20 bytes per second, below the minimum rate.
+	drip(t, ln, httpHeader, 50*time.Millisecond)
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	start := time.Now()
+	if err := readHeader(conn); !errors.Is(err, ErrHandshakeTooSlow) {
+		t.Fatalf("expected ErrHandshakeTooSlow, got %v", err)
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Fatalf("the slow conn is closed after %s", d)
+	}
+	// the conn is closed.
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Fatal("expected the conn to be closed")
+	}
+}
+
+func TestHandshakeListenerTimeout(t *testing.T) {
+	ln := HandshakeListener(listenTCP(t), TimeoutHandshakeOption(200*time.Millisecond))
+	defer ln.Close()
+
+	// fast enough for any rate, but the headers never complete within the deadline.
+	drip(t, ln, httpHeader, 20*time.Millisecond)
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	start := time.Now()
+	if err := readHeader(conn); !errors.Is(err, ErrHandshakeTimeout) {
+		t.Fatalf("expected ErrHandshakeTimeout, got %v", err)
+	}
+	if d := time.Since(start); d < 150*time.Millisecond || d > 2*time.Second {
+		t.Fatalf("the conn is closed after %s", d)
+	}
+}
+
+func TestHandshakeListenerNormal(t *testing.T) {
+	ln := HandshakeListener(listenTCP(t), TimeoutHandshakeOption(200*time.Millisecond), MinRateHandshakeOption(100, 100*time.Millisecond))
+	defer ln.Close()
+
+	client, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	client.Write([]byte(httpHeader))
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	br := bufio.NewReader(conn)
+	req, err := http.ReadRequest(br)
+	if err != nil || req.Host != "example.com" {
+		t.Fatalf("unexpected request %v %v", req, err)
+	}
+	HandshakeDone(conn)
+
+	// the protections are lifted, the quiet period beyond the deadline is fine.
+	time.Sleep(300 * time.Millisecond)
+	client.Write([]byte("body"))
+	b := make([]byte, 4)
+	if _, err := io.ReadFull(br, b); err != nil || string(b) != "body" {
+		t.Fatalf("unexpected read after the handshake %q %v", b, err)
+	}
+}
+
+func TestHandshakeListenerTLS(t *testing.T) {
+	config := &tls.Config{Certificates: []tls.Certificate{newTestCert(t, "example.com")}}
+	ln := HandshakeListener(listenTCP(t), TimeoutHandshakeOption(300*time.Millisecond))
+	defer ln.Close()
+
+	go func() {
+		conn, err := tls.Dial("tcp", ln.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		time.Sleep(500 * time.Millisecond)
+		conn.Write([]byte("ping"))
+		io.Copy(io.Discard, conn)
+	}()
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	tc, err := TLSHandshake(context.Background(), conn, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := make([]byte, 4)
+	if _, err := io.ReadFull(tc, b); err != nil || string(b) != "ping" {
+		t.Fatalf("unexpected read after the TLS handshake %q %v", b, err)
+	}
+
+	// the client never sends the ClientHello.
+	idle, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idle.Close()
+	conn, err = ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	start := time.Now()
+	if _, err := TLSHandshake(context.Background(), conn, config); !errors.Is(err, ErrHandshakeTimeout) {
+		t.Fatalf("expected ErrHandshakeTimeout, got %v", err)
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Fatalf("the handshake is abandoned after %s", d)
+	}
+}
+
+func TestHandshakeListenerDisabled(t *testing.T) {
+	ln := listenTCP(t)
+	defer ln.Close()
+	if HandshakeListener(ln) != ln {
+		t.Fatal("the listener without the protections should not be wrapped")
+	}
+}
@@ -0,0 +1,108 @@
+package listener
+
+import (
+	"crypto/tls"
+	"net"
+	"sync"
+	"time"
+
+	xnet "github.com/go-gost/core/common/net"
+)
+
+const (
+	defaultPeekTimeout = 10 * time.Second
+	// TLS record type handshake
+	tlsRecordTypeHandshake = 0x16
+)
+
+type acceptResult struct {
+	conn net.Conn
+	err  error
+}
+
+type autoTLSListener struct {
+	net.Listener
+	tlsConfig *tls.Config
+	timeout   time.Duration
+	cc        chan acceptResult
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// AutoTLSListener wraps ln to accept both TLS and plaintext connections on the same port.
+// The first bytes of each connection are peeked to detect a TLS ClientHello,
+// TLS connections are terminated with tlsConfig, others are returned as is.
+// The peeked bytes are replayed to the reader, connections which send nothing
+// within the timeout are closed.
+func AutoTLSListener(ln net.Listener, tlsConfig *tls.Config, timeout time.Duration) net.Listener {
+	if timeout <= 0 {
+		timeout = defaultPeekTimeout
+	}
+	l := &autoTLSListener{
+		Listener:  ln,
+		tlsConfig: tlsConfig,
+		timeout:   timeout,
+		cc:        make(chan acceptResult, 128),
+		closed:    make(chan struct{}),
+	}
+	go l.acceptLoop()
+	return l
+}
+
+func (l *autoTLSListener) acceptLoop() {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			select {
+			case l.cc <- acceptResult{err: err}:
+			case <-l.closed:
+				return
+			}
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				continue
+			}
+			return
+		}
+		go l.detect(conn)
+	}
+}
+
+func (l *autoTLSListener) detect(conn net.Conn) {
+	pc := xnet.NewPeekConn(conn)
+
+	conn.SetReadDeadline(time.Now().Add(l.timeout))
+	b, err := pc.Peek(3)
+	conn.SetReadDeadline(time.Time{})
+	if err != nil {
+		conn.Close()
+		return
+	}
+
+	var c net.Conn = pc
+	// TLS record header: type(1) + version(2), the major version is always 3.
+	if b[0] == tlsRecordTypeHandshake && b[1] == 0x03 && l.tlsConfig != nil {
+		c = tls.Server(pc, l.tlsConfig)
+	}
+
+	select {
+	case l.cc <- acceptResult{conn: c}:
+	case <-l.closed:
+		conn.Close()
+	}
+}
+
+func (l *autoTLSListener) Accept() (net.Conn, error) {
+	select {
+	case r := <-l.cc:
+		return r.conn, r.err
+	case <-l.closed:
+		return nil, ErrClosed
+	}
+}
+
+func (l *autoTLSListener) Close() error {
+	l.closeOnce.Do(func() {
+		close(l.closed)
+	})
+	return l.Listener.Close()
+}
@@ -0,0 +1,148 @@
+package listener
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+)
+
+// newTestCert generates a self-signed certificate for the names.
+func newTestCert(t *testing.T, names ...string) tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: names[0]},
+		DNSNames:     names,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func listenTCP(t *testing.T) net.Listener {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ln
+}
+
+func TestAutoTLSListenerPlaintext(t *testing.T) {
+	ln := AutoTLSListener(listenTCP(t), &tls.Config{Certificates: []tls.Certificate{newTestCert(t, "example.com")}}, time.Second)
+	defer ln.Close()
+
+	payload := []byte("GET / HTTP/1.1\r\nHost: example.com\r\n\r\n")
+	go func() {
+		conn, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write(payload)
+	}()
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, ok := conn.(*tls.Conn); ok {
+		t.Fatal("plaintext conn is routed to TLS")
+	}
+
+	b, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != string(payload) {
+		t.Fatalf("peeked bytes are not replayed: %q", b)
+	}
+}
+
+func TestAutoTLSListenerTLS(t *testing.T) {
+	ln := AutoTLSListener(listenTCP(t), &tls.Config{Certificates: []tls.Certificate{newTestCert(t, "example.com")}}, time.Second)
+	defer ln.Close()
+
+	go func() {
+		conn, err := tls.Dial("tcp", ln.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write([]byte("hello"))
+	}()
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, ok := conn.(*tls.Conn); !ok {
+		t.Fatalf("TLS conn is not terminated: %T", conn)
+	}
+
+	b, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "hello" {
+		t.Fatalf("unexpected data %q", b)
+	}
+}
+
+func TestAutoTLSListenerPeekTimeout(t *testing.T) {
+	ln := AutoTLSListener(listenTCP(t), nil, 50*time.Millisecond)
+	defer ln.Close()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// the silent conn is closed by the listener without being accepted.
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("expected the conn to be closed, got %v", err)
+	}
+
+	accepted := make(chan struct{})
+	go func() {
+		if c, err := ln.Accept(); err == nil {
+			c.Close()
+			close(accepted)
+		}
+	}()
+	select {
+	case <-accepted:
+		t.Fatal("silent conn is accepted")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestAutoTLSListenerClose(t *testing.T) {
+	ln := AutoTLSListener(listenTCP(t), nil, time.Second)
+	ln.Close()
+
+	if _, err := ln.Accept(); err == nil {
+		t.Fatal("accept on closed listener should fail")
+	}
+}
@@ -1,6 +1,11 @@
 package logger
 
-import "sync/atomic"
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/go-gost/core/common/ctxvalue"
+)
 
 // LogFormat is format type
 type LogFormat string
@@ -164,3 +169,15 @@ func (l *loggerGroup) IsLevelEnabled(level LogLevel) bool {
 	}
 	return false
 }
+
+// WithTraceID returns the logger l with the field "tid" of the trace ID carried in ctx (see ctxvalue.ContextWithTraceID),
+// l is returned as is if there is none.
+func WithTraceID(ctx context.Context, l Logger) Logger {
+	if l == nil {
+		return l
+	}
+	if id := ctxvalue.TraceIDFromContext(ctx); id != "" {
+		return l.WithFields(map[string]any{"tid": id})
+	}
+	return l
+}
@@ -0,0 +1,75 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/go-gost/core/common/ctxvalue"
+)
+
+// fieldLogger records the messages with its fields.
+type fieldLogger struct {
+	fields  map[string]any
+	entries *[]string
+}
+
+func newFieldLogger() *fieldLogger {
+	return &fieldLogger{entries: new([]string)}
+}
+
+func (l *fieldLogger) WithFields(m map[string]any) Logger {
+	fields := make(map[string]any, len(l.fields)+len(m))
+	for k, v := range l.fields {
+		fields[k] = v
+	}
+	for k, v := range m {
+		fields[k] = v
+	}
+	return &fieldLogger{fields: fields, entries: l.entries}
+}
+
+func (l *fieldLogger) log(msg string) {
+	*l.entries = append(*l.entries, fmt.Sprintf("%s tid=%v", msg, l.fields["tid"]))
+}
+
+func (l *fieldLogger) Trace(args ...any)                 { l.log(fmt.Sprint(args...)) }
+func (l *fieldLogger) Tracef(format string, args ...any) { l.log(fmt.Sprintf(format, args...)) }
+func (l *fieldLogger) Debug(args ...any)                 { l.log(fmt.Sprint(args...)) }
+func (l *fieldLogger) Debugf(format string, args ...any) { l.log(fmt.Sprintf(format, args...)) }
+func (l *fieldLogger) Info(args ...any)                  { l.log(fmt.Sprint(args...)) }
+func (l *fieldLogger) Infof(format string, args ...any)  { l.log(fmt.Sprintf(format, args...)) }
+func (l *fieldLogger) Warn(args ...any)                  { l.log(fmt.Sprint(args...)) }
+func (l *fieldLogger) Warnf(format string, args ...any)  { l.log(fmt.Sprintf(format, args...)) }
+func (l *fieldLogger) Error(args ...any)                 { l.log(fmt.Sprint(args...)) }
+func (l *fieldLogger) Errorf(format string, args ...any) { l.log(fmt.Sprintf(format, args...)) }
+func (l *fieldLogger) Fatal(args ...any)                 { l.log(fmt.Sprint(args...)) }
+func (l *fieldLogger) Fatalf(format string, args ...any) { l.log(fmt.Sprintf(format, args...)) }
+func (l *fieldLogger) GetLevel() LogLevel                { return DebugLevel }
+func (l *fieldLogger) IsLevelEnabled(level LogLevel) bool {
+	return level != TraceLevel
+}
+
+func TestWithTraceID(t *testing.T) {
+	l := newFieldLogger()
+	ctx := ctxvalue.ContextWithTraceID(context.Background(), "trace-1")
+
+	WithTraceID(ctx, l).Info("accepted")
+	WithTraceID(ctx, LoggerGroup(l, l)).Infof("dial %s", "example.com:443")
+	// without the trace ID the logger is returned as is.
+	if lg := WithTraceID(context.Background(), l); lg != Logger(l) {
+		t.Fatal("the logger without trace ID should be returned as is")
+	}
+	if lg := WithTraceID(ctx, nil); lg != nil {
+		t.Fatalf("expected nil logger, got %v", lg)
+	}
+
+	want := []string{
+		"accepted tid=trace-1",
+		"dial example.com:443 tid=trace-1",
+		"dial example.com:443 tid=trace-1",
+	}
+	if fmt.Sprint(*l.entries) != fmt.Sprint(want) {
+		t.Fatalf("got %q, want %q", *l.entries, want)
+	}
+}
@@ -0,0 +1,56 @@
+package metadata
+
+import (
+	"hash/fnv"
+	"strconv"
+	"strings"
+)
+
+// GetFlag reports whether the feature flag key is enabled for the client identified by clientKey.
+//
+// The flag value can be a boolean ("true", "false") or a rollout percentage ("50%"),
+// for a percentage, the clients are bucketed by the hash of the flag key and clientKey,
+// so that a client gets a stable result for the same flag.
+// An absent or invalid flag is disabled.
+func GetFlag(md Metadata, key, clientKey string) bool {
+	if md == nil || !md.IsExists(key) {
+		return false
+	}
+
+	var percent float64
+	switch v := md.Get(key).(type) {
+	case bool:
+		return v
+	case int:
+		percent = float64(v)
+	case float64:
+		percent = v
+	case string:
+		v = strings.TrimSpace(v)
+		if s, ok := strings.CutSuffix(v, "%"); ok {
+			p, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+			if err != nil {
+				return false
+			}
+			percent = p
+		} else {
+			b, _ := strconv.ParseBool(v)
+			return b
+		}
+	default:
+		return false
+	}
+
+	if percent <= 0 {
+		return false
+	}
+	if percent >= 100 {
+		return true
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	h.Write([]byte{0})
+	h.Write([]byte(clientKey))
+	return float64(h.Sum32()%10000) < percent*100
+}
@@ -0,0 +1,82 @@
+package metadata
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestGetFlagRollout(t *testing.T) {
+	md := mapMetadata{"feature.newrelay": "50%"}
+
+	const n = 10000
+	var enabled int
+	for i := 0; i < n; i++ {
+		client := fmt.Sprintf("client-%d", i)
+		on := GetFlag(md, "feature.newrelay", client)
+		if on {
+			enabled++
+		}
+		// stable for the same client.
+		for j := 0; j < 3; j++ {
+			if GetFlag(md, "feature.newrelay", client) != on {
+				t.Fatalf("unstable flag for %s", client)
+			}
+		}
+	}
+	if share := float64(enabled) / n; math.Abs(share-0.5) > 0.03 {
+		t.Fatalf("50%% flag is enabled for %.3f of the clients", share)
+	}
+}
+
+func TestGetFlagBucketsByKey(t *testing.T) {
+	md := mapMetadata{"feature.a": "50%", "feature.b": "50%"}
+
+	// the clients are bucketed independently for each flag.
+	var same int
+	const n = 1000
+	for i := 0; i < n; i++ {
+		client := fmt.Sprintf("client-%d", i)
+		if GetFlag(md, "feature.a", client) == GetFlag(md, "feature.b", client) {
+			same++
+		}
+	}
+	if same == n {
+		t.Fatal("the flags have the same buckets")
+	}
+}
+
+func TestGetFlagValues(t *testing.T) {
+	md := mapMetadata{
+		"bool.true":   true,
+		"bool.false":  false,
+		"str.true":    "true",
+		"str.false":   "false",
+		"percent.0":   "0%",
+		"percent.100": " 100 % ",
+		"int.100":     100,
+		"float.0":     0.0,
+		"invalid":     "50x%",
+		"other":       []string{"true"},
+	}
+	for key, want := range map[string]bool{
+		"bool.true":   true,
+		"bool.false":  false,
+		"str.true":    true,
+		"str.false":   false,
+		"percent.0":   false,
+		"percent.100": true,
+		"int.100":     true,
+		"float.0":     false,
+		"invalid":     false,
+		"other":       false,
+		"absent":      false,
+	} {
+		if got := GetFlag(md, key, "client"); got != want {
+			t.Errorf("%s: got %v, want %v", key, got, want)
+		}
+	}
+	if GetFlag(nil, "bool.true", "client") {
+		t.Error("nil metadata should be disabled")
+	}
+}
@@ -0,0 +1,171 @@
+package metadata
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrMissingKey   = errors.New("missing required key")
+	ErrInvalidType  = errors.New("invalid type")
+	ErrInvalidValue = errors.New("invalid value")
+	ErrUnknownKey   = errors.New("unknown key")
+)
+
+// Keyer is a Metadata which can list its keys.
+type Keyer interface {
+	Keys() []string
+}
+
+type FieldType int
+
+const (
+	AnyType FieldType = iota
+	StringType
+	IntType
+	FloatType
+	BoolType
+	DurationType
+)
+
+func (t FieldType) String() string {
+	switch t {
+	case StringType:
+		return "string"
+	case IntType:
+		return "int"
+	case FloatType:
+		return "float"
+	case BoolType:
+		return "bool"
+	case DurationType:
+		return "duration"
+	default:
+		return "any"
+	}
+}
+
+// Field declares a metadata key.
+type Field struct {
+	Key      string
+	Type     FieldType
+	Required bool
+	// Values is the allowed values in string form, empty means any value.
+	Values []string
+}
+
+// Schema declares the metadata keys of an object.
+type Schema struct {
+	Fields []Field
+	// Strict rejects the keys not declared in Fields, it requires the metadata to implement Keyer.
+	Strict bool
+}
+
+// SchemaError is the error of a schema validation for a key.
+type SchemaError struct {
+	Key string
+	Err error
+}
+
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("metadata: %s: %v", e.Key, e.Err)
+}
+
+func (e *SchemaError) Unwrap() error {
+	return e.Err
+}
+
+// Validate checks md against the schema, the errors of all the fields are joined.
+func (s *Schema) Validate(md Metadata) error {
+	if s == nil {
+		return nil
+	}
+
+	var errs []error
+	for _, f := range s.Fields {
+		if md == nil || !md.IsExists(f.Key) {
+			if f.Required {
+				errs = append(errs, &SchemaError{Key: f.Key, Err: ErrMissingKey})
+			}
+			continue
+		}
+		if err := f.validate(md.Get(f.Key)); err != nil {
+			errs = append(errs, &SchemaError{Key: f.Key, Err: err})
+		}
+	}
+
+	if s.Strict {
+		if keyer, ok := md.(Keyer); ok {
+			for _, k := range keyer.Keys() {
+				if !slices.ContainsFunc(s.Fields, func(f Field) bool { return f.Key == k }) {
+					errs = append(errs, &SchemaError{Key: k, Err: ErrUnknownKey})
+				}
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (f *Field) validate(v any) error {
+	var s string
+	switch vv := v.(type) {
+	case string:
+		s = vv
+	case fmt.Stringer:
+		s = vv.String()
+	default:
+		s = fmt.Sprint(v)
+	}
+
+	var err error
+	switch f.Type {
+	case StringType:
+		if _, ok := v.(string); !ok {
+			err = ErrInvalidType
+		}
+	case IntType:
+		switch v.(type) {
+		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		case string:
+			_, err = strconv.ParseInt(s, 10, 64)
+		default:
+			err = ErrInvalidType
+		}
+	case FloatType:
+		switch v.(type) {
+		case float32, float64, int, int64, uint, uint64:
+		case string:
+			_, err = strconv.ParseFloat(s, 64)
+		default:
+			err = ErrInvalidType
+		}
+	case BoolType:
+		switch v.(type) {
+		case bool:
+		case string:
+			_, err = strconv.ParseBool(s)
+		default:
+			err = ErrInvalidType
+		}
+	case DurationType:
+		switch v.(type) {
+		case time.Duration, int, int64:
+		case string:
+			_, err = time.ParseDuration(s)
+		default:
+			err = ErrInvalidType
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("%w: expected %s, got %v", ErrInvalidType, f.Type, v)
+	}
+
+	if len(f.Values) > 0 && !slices.Contains(f.Values, s) {
+		return fmt.Errorf("%w: %q, allowed values %v", ErrInvalidValue, s, f.Values)
+	}
+	return nil
+}
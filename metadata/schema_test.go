@@ -0,0 +1,108 @@
+package metadata
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// mapMetadata is a Metadata backed by a map.
+type mapMetadata map[string]any
+
+func (m mapMetadata) IsExists(key string) bool {
+	_, ok := m[key]
+	return ok
+}
+
+func (m mapMetadata) Set(key string, value any) {
+	m[key] = value
+}
+
+func (m mapMetadata) Get(key string) any {
+	return m[key]
+}
+
+func (m mapMetadata) Keys() []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+var testSchema = &Schema{
+	Fields: []Field{
+		{Key: "weight", Type: IntType},
+		{Key: "timeout", Type: DurationType},
+		{Key: "host", Type: StringType, Required: true},
+		{Key: "mode", Type: StringType, Values: []string{"tcp", "udp"}},
+		{Key: "debug", Type: BoolType},
+	},
+}
+
+func TestSchemaValid(t *testing.T) {
+	md := mapMetadata{
+		"weight":  "10",
+		"timeout": 5 * time.Second,
+		"host":    "example.com",
+		"mode":    "udp",
+		"debug":   true,
+	}
+	if err := testSchema.Validate(md); err != nil {
+		t.Fatal(err)
+	}
+
+	var s *Schema
+	if err := s.Validate(md); err != nil {
+		t.Fatalf("nil schema should accept any metadata: %v", err)
+	}
+}
+
+func TestSchemaMissingKey(t *testing.T) {
+	err := testSchema.Validate(mapMetadata{"weight": 1})
+	if !errors.Is(err, ErrMissingKey) {
+		t.Fatalf("expected ErrMissingKey, got %v", err)
+	}
+	var se *SchemaError
+	if !errors.As(err, &se) || se.Key != "host" {
+		t.Fatalf("expected the error of host, got %v", err)
+	}
+
+	if err := testSchema.Validate(nil); !errors.Is(err, ErrMissingKey) {
+		t.Fatalf("expected ErrMissingKey for nil metadata, got %v", err)
+	}
+}
+
+func TestSchemaWrongType(t *testing.T) {
+	for _, md := range []mapMetadata{
+		{"host": "example.com", "weight": "ten"},
+		{"host": "example.com", "timeout": "5 seconds"},
+		{"host": 1},
+		{"host": "example.com", "debug": "yes please"},
+		{"host": "example.com", "weight": 1.5},
+	} {
+		if err := testSchema.Validate(md); !errors.Is(err, ErrInvalidType) {
+			t.Errorf("%v: expected ErrInvalidType, got %v", md, err)
+		}
+	}
+}
+
+func TestSchemaAllowedValues(t *testing.T) {
+	err := testSchema.Validate(mapMetadata{"host": "example.com", "mode": "sctp"})
+	if !errors.Is(err, ErrInvalidValue) {
+		t.Fatalf("expected ErrInvalidValue, got %v", err)
+	}
+}
+
+func TestSchemaStrict(t *testing.T) {
+	s := &Schema{
+		Fields: testSchema.Fields,
+		Strict: true,
+	}
+	// the typo of weight.
+	err := s.Validate(mapMetadata{"host": "example.com", "weigth": 10})
+	var se *SchemaError
+	if !errors.Is(err, ErrUnknownKey) || !errors.As(err, &se) || se.Key != "weigth" {
+		t.Fatalf("expected ErrUnknownKey for weigth, got %v", err)
+	}
+}
@@ -0,0 +1,93 @@
+package metadata
+
+import (
+	"fmt"
+	"strings"
+)
+
+type subMetadata struct {
+	md     Metadata
+	prefix string
+}
+
+// GetSub returns the view of the namespace ns in md, e.g. GetSub(md, "tls").Get("server_name")
+// looks up the key "tls.server_name". The namespaces can be nested by the dots, e.g. GetSub(md, "tls.client").
+// The keys missing in the flat map are also looked up in the nested maps, e.g. md.Get("tls") is a map
+// containing the key "server_name". A missing namespace yields an empty view, and Set writes the dotted keys into md.
+func GetSub(md Metadata, ns string) Metadata {
+	if md == nil {
+		return nil
+	}
+	ns = strings.Trim(ns, ".")
+	if sub, ok := md.(*subMetadata); ok {
+		return &subMetadata{
+			md:     sub.md,
+			prefix: sub.prefix + ns + ".",
+		}
+	}
+	return &subMetadata{
+		md:     md,
+		prefix: ns + ".",
+	}
+}
+
+func (m *subMetadata) IsExists(key string) bool {
+	_, ok := m.lookup(key)
+	return ok
+}
+
+func (m *subMetadata) Set(key string, value any) {
+	m.md.Set(m.prefix+key, value)
+}
+
+func (m *subMetadata) Get(key string) any {
+	v, _ := m.lookup(key)
+	return v
+}
+
+func (m *subMetadata) lookup(key string) (any, bool) {
+	full := m.prefix + key
+	if m.md.IsExists(full) {
+		return m.md.Get(full), true
+	}
+
+	// look up the nested maps from the longest dotted prefix in the flat map.
+	parts := strings.Split(full, ".")
+	for i := len(parts) - 1; i > 0; i-- {
+		k := strings.Join(parts[:i], ".")
+		if !m.md.IsExists(k) {
+			continue
+		}
+		v := m.md.Get(k)
+		for _, p := range parts[i:] {
+			var ok bool
+			if v, ok = mapValue(v, p); !ok {
+				break
+			}
+		}
+		if v != nil {
+			return v, true
+		}
+		return nil, false
+	}
+	return nil, false
+}
+
+func mapValue(v any, key string) (any, bool) {
+	switch m := v.(type) {
+	case map[string]any:
+		v, ok := m[key]
+		return v, ok
+	case map[any]any:
+		for k, v := range m {
+			if fmt.Sprint(k) == key {
+				return v, true
+			}
+		}
+	case Metadata:
+		if m.IsExists(key) {
+			return m.Get(key), true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,92 @@
+package metadata
+
+import "testing"
+
+func TestGetSub(t *testing.T) {
+	md := mapMetadata{
+		"tls.server_name":      "example.com",
+		"tls.client.cert_file": "client.crt",
+		"timeout":              "5s",
+		"quic": map[string]any{
+			"keepalive": true,
+			"stream": map[any]any{
+				"max": 100,
+			},
+		},
+		"tls.client": mapMetadata{"key_file": "client.key"},
+	}
+
+	for _, tc := range []struct {
+		ns    string
+		key   string
+		value any
+	}{
+		{"tls", "server_name", "example.com"},
+		{"tls", "client.cert_file", "client.crt"},
+		{"tls.client", "cert_file", "client.crt"},
+		{".tls.", "server_name", "example.com"},
+		// the nested maps.
+		{"quic", "keepalive", true},
+		{"quic.stream", "max", 100},
+		{"quic", "stream.max", 100},
+		{"tls.client", "key_file", "client.key"},
+	} {
+		sub := GetSub(md, tc.ns)
+		if !sub.IsExists(tc.key) || sub.Get(tc.key) != tc.value {
+			t.Errorf("%s/%s: got %v, want %v", tc.ns, tc.key, sub.Get(tc.key), tc.value)
+		}
+	}
+
+	// the nested namespaces of a view.
+	if v := GetSub(GetSub(md, "tls"), "client").Get("cert_file"); v != "client.crt" {
+		t.Fatalf("unexpected nested view value %v", v)
+	}
+
+	// the flat keys are still accessible.
+	if md.Get("tls.server_name") != "example.com" || md.Get("timeout") != "5s" {
+		t.Fatal("unexpected flat key value")
+	}
+}
+
+func TestGetSubMissing(t *testing.T) {
+	md := mapMetadata{
+		"tls.server_name": "example.com",
+		"quic":            map[string]any{"keepalive": true},
+		"timeout":         "5s",
+	}
+
+	for _, tc := range []struct {
+		ns  string
+		key string
+	}{
+		{"http", "header"},
+		{"http.proxy", "auth"},
+		{"tls", "client.cert_file"},
+		{"quic", "stream.max"},
+		{"quic.stream", "max"},
+		// the scalar value is not a namespace.
+		{"timeout", "value"},
+	} {
+		sub := GetSub(md, tc.ns)
+		if sub.IsExists(tc.key) || sub.Get(tc.key) != nil {
+			t.Errorf("%s/%s: expected missing, got %v", tc.ns, tc.key, sub.Get(tc.key))
+		}
+	}
+
+	if GetSub(nil, "tls") != nil {
+		t.Fatal("expected nil view of nil metadata")
+	}
+}
+
+func TestGetSubSet(t *testing.T) {
+	md := mapMetadata{}
+	sub := GetSub(GetSub(md, "tls"), "client")
+	sub.Set("cert_file", "client.crt")
+
+	if v := md.Get("tls.client.cert_file"); v != "client.crt" {
+		t.Fatalf("expected the dotted key in the flat map, got %v", md)
+	}
+	if v := GetSub(md, "tls").Get("client.cert_file"); v != "client.crt" {
+		t.Fatalf("unexpected value %v", v)
+	}
+}
@@ -25,3 +25,18 @@ type Metrics interface {
 	Gauge(name MetricName, labels Labels) Gauge
 	Observer(name MetricName, labels Labels) Observer
 }
+
+const (
+	// Labels: upstream, qtype, rcode.
+	MetricResolverRequestsCounter MetricName = "gost_resolver_requests_total"
+	// Labels: upstream, qtype.
+	MetricResolverRequestDurationObserver MetricName = "gost_resolver_request_duration_seconds"
+	// Labels: upstream, qtype.
+	MetricResolverTimeoutsCounter MetricName = "gost_resolver_timeouts_total"
+	// Labels: upstream.
+	MetricResolverCacheHitsCounter MetricName = "gost_resolver_cache_hits_total"
+	// Labels: upstream.
+	MetricResolverCacheMissesCounter MetricName = "gost_resolver_cache_misses_total"
+	// Labels: upstream.
+	MetricResolverCacheStaleCounter MetricName = "gost_resolver_cache_stale_total"
+)
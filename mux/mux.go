@@ -0,0 +1,270 @@
+// Package mux defines the stream multiplexing abstraction (e.g. yamux, smux) over a single connection.
+//
+// The yamux and smux protocols are built in, the other protocols can be registered by name with Register.
+package mux
+
+import (
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"sync"
+	"time"
+
+	xnet "github.com/go-gost/core/common/net"
+)
+
+var (
+	ErrUnknownProtocol = errors.New("mux: unknown protocol")
+)
+
+// The names of the built-in protocols.
+const (
+	ProtocolYamux = "yamux"
+	ProtocolSmux  = "smux"
+)
+
+// Session is a client multiplexing session over a connection.
+type Session interface {
+	// OpenStream opens a new logical stream in the session.
+	OpenStream() (net.Conn, error)
+	NumStreams() int
+	IsClosed() bool
+	// Close closes the session and all its streams, the open streams get errors on read and write.
+	Close() error
+}
+
+type Options struct {
+	// Protocol is the name of the multiplexing protocol, e.g. yamux or smux.
+	Protocol          string
+	KeepAliveInterval time.Duration
+	KeepAliveTimeout  time.Duration
+	// MaxStreams is the maximum number of streams per session, 0 means unlimited.
+	MaxStreams int
+}
+
+type Option func(opts *Options)
+
+func ProtocolOption(protocol string) Option {
+	return func(opts *Options) {
+		opts.Protocol = protocol
+	}
+}
+
+func KeepAliveOption(interval, timeout time.Duration) Option {
+	return func(opts *Options) {
+		opts.KeepAliveInterval = interval
+		opts.KeepAliveTimeout = timeout
+	}
+}
+
+func MaxStreamsOption(n int) Option {
+	return func(opts *Options) {
+		opts.MaxStreams = n
+	}
+}
+
+// ClientFunc creates a client session over conn.
+type ClientFunc func(conn net.Conn, opts *Options) (Session, error)
+
+var (
+	protocols   = map[string]ClientFunc{}
+	protocolsMu sync.RWMutex
+)
+
+func init() {
+	Register(ProtocolYamux, YamuxClient)
+	Register(ProtocolSmux, SmuxClient)
+}
+
+// Register registers the multiplexing protocol with name, a registered name is replaced.
+func Register(name string, fn ClientFunc) {
+	protocolsMu.Lock()
+	defer protocolsMu.Unlock()
+	protocols[name] = fn
+}
+
+func Get(name string) ClientFunc {
+	protocolsMu.RLock()
+	defer protocolsMu.RUnlock()
+	return protocols[name]
+}
+
+type muxDialer struct {
+	dialer   xnet.Dialer
+	client   ClientFunc
+	options  Options
+	sessions map[string][]*muxSession
+	mu       sync.Mutex
+}
+
+// muxSession is a session with the stream slots reserved by the dialer, which are guarded by the dialer lock.
+type muxSession struct {
+	Session
+	streams int
+}
+
+// Dialer wraps the dialer d to multiplex the connections to the same address over shared sessions.
+// A session is reused until it is closed or reaches MaxStreams, a live session failing to open a stream
+// is skipped but not closed, so its open streams are not torn down. The dead sessions are dropped
+// and a new base connection is dialed with d on demand.
+// The stream slot is reserved before the stream is opened and released when the stream is closed,
+// so the concurrent dials never exceed MaxStreams.
+// The returned dialer implements io.Closer to tear down all the sessions.
+func Dialer(d xnet.Dialer, opts ...Option) (xnet.Dialer, error) {
+	var options Options
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+
+	client := Get(options.Protocol)
+	if client == nil {
+		return nil, ErrUnknownProtocol
+	}
+
+	return &muxDialer{
+		dialer:   d,
+		client:   client,
+		options:  options,
+		sessions: make(map[string][]*muxSession),
+	}, nil
+}
+
+func (d *muxDialer) Dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	key := network + "://" + addr
+
+	for _, session := range d.getSessions(key) {
+		if !d.reserve(session) {
+			continue
+		}
+		stream, err := session.OpenStream()
+		if err == nil {
+			return d.wrapStream(session, stream), nil
+		}
+		d.release(session)
+		// the session may still carry the open streams, it is only dropped once dead.
+		if session.IsClosed() {
+			d.removeSession(key, session)
+		}
+	}
+
+	conn, err := d.dialer.Dial(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	s, err := d.client(conn, &d.options)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	stream, err := s.OpenStream()
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+
+	session := &muxSession{Session: s, streams: 1}
+	d.mu.Lock()
+	d.sessions[key] = append(d.sessions[key], session)
+	d.mu.Unlock()
+
+	return d.wrapStream(session, stream), nil
+}
+
+// getSessions returns the live sessions with free stream slots, the closed sessions are removed.
+func (d *muxDialer) getSessions(key string) []*muxSession {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var live, found []*muxSession
+	for _, s := range d.sessions[key] {
+		if s.IsClosed() {
+			continue
+		}
+		live = append(live, s)
+		if d.free(s) {
+			found = append(found, s)
+		}
+	}
+	if len(live) == 0 {
+		delete(d.sessions, key)
+	} else {
+		d.sessions[key] = live
+	}
+	return found
+}
+
+// free reports whether the session has a free stream slot, it is called with the lock held.
+func (d *muxDialer) free(s *muxSession) bool {
+	return d.options.MaxStreams <= 0 || s.streams < d.options.MaxStreams
+}
+
+// reserve reserves a stream slot of the session, false is returned if the session is full.
+func (d *muxDialer) reserve(s *muxSession) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !d.free(s) {
+		return false
+	}
+	s.streams++
+	return true
+}
+
+func (d *muxDialer) release(s *muxSession) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	s.streams--
+}
+
+func (d *muxDialer) wrapStream(s *muxSession, stream net.Conn) net.Conn {
+	return &muxStream{
+		Conn:    stream,
+		release: func() { d.release(s) },
+	}
+}
+
+func (d *muxDialer) removeSession(key string, session *muxSession) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	sessions := slices.DeleteFunc(d.sessions[key], func(s *muxSession) bool {
+		return s == session
+	})
+	if len(sessions) == 0 {
+		delete(d.sessions, key)
+	} else {
+		d.sessions[key] = sessions
+	}
+}
+
+// muxStream releases the stream slot of its session once closed.
+type muxStream struct {
+	net.Conn
+	release   func()
+	closeOnce sync.Once
+}
+
+func (c *muxStream) Close() error {
+	c.closeOnce.Do(c.release)
+	return c.Conn.Close()
+}
+
+func (c *muxStream) CloseWrite() error {
+	return xnet.CloseWrite(c.Conn)
+}
+
+func (d *muxDialer) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for key, sessions := range d.sessions {
+		for _, s := range sessions {
+			s.Close()
+		}
+		delete(d.sessions, key)
+	}
+	return nil
+}
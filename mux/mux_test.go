@@ -0,0 +1,423 @@
+package mux
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/yamux"
+	"github.com/xtaci/smux"
+)
+
+// acceptFunc accepts the streams of a server session.
+type acceptFunc func() (net.Conn, error)
+
+// servers creates the server sessions of the built-in protocols.
+var servers = map[string]func(conn net.Conn) (acceptFunc, error){
+	ProtocolYamux: func(conn net.Conn) (acceptFunc, error) {
+		config := yamux.DefaultConfig()
+		config.LogOutput = io.Discard
+		s, err := yamux.Server(conn, config)
+		if err != nil {
+			return nil, err
+		}
+		return s.Accept, nil
+	},
+	ProtocolSmux: func(conn net.Conn) (acceptFunc, error) {
+		s, err := smux.Server(conn, smux.DefaultConfig())
+		if err != nil {
+			return nil, err
+		}
+		return func() (net.Conn, error) {
+			return s.AcceptStream()
+		}, nil
+	},
+}
+
+// muxServer serves the multiplexing sessions of protocol, echoing on each stream.
+// The accepted base connections are sent to conns.
+func muxServer(t *testing.T, protocol string) (addr string, conns chan net.Conn) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	conns = make(chan net.Conn, 16)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conns <- conn
+			go func() {
+				defer conn.Close()
+				accept, err := servers[protocol](conn)
+				if err != nil {
+					return
+				}
+				for {
+					stream, err := accept()
+					if err != nil {
+						return
+					}
+					go func() {
+						defer stream.Close()
+						io.Copy(stream, stream)
+					}()
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String(), conns
+}
+
+// countDialer is a xnet.Dialer counting the base connections.
+type countDialer struct {
+	n atomic.Int64
+}
+
+func (d *countDialer) Dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	d.n.Add(1)
+	var nd net.Dialer
+	return nd.DialContext(ctx, network, addr)
+}
+
+func echo(t *testing.T, conn net.Conn, msg string) {
+	t.Helper()
+	conn.SetDeadline(time.Now().Add(time.Second))
+	if _, err := conn.Write([]byte(msg)); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, len(msg))
+	if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != msg {
+		t.Fatalf("echo %q: got %q %v", msg, buf, err)
+	}
+}
+
+func TestDialerStreams(t *testing.T) {
+	for _, protocol := range []string{ProtocolYamux, ProtocolSmux} {
+		t.Run(protocol, func(t *testing.T) {
+			addr, _ := muxServer(t, protocol)
+			base := &countDialer{}
+			d, err := Dialer(base, ProtocolOption(protocol))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer d.(io.Closer).Close()
+
+			var wg sync.WaitGroup
+			for i := 0; i < 10; i++ {
+				stream, err := d.Dial(context.Background(), "tcp", addr)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer stream.Close()
+
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					echo(t, stream, fmt.Sprintf("stream %d", i))
+				}(i)
+			}
+			wg.Wait()
+
+			if n := base.n.Load(); n != 1 {
+				t.Fatalf("expected the streams over one connection, got %d", n)
+			}
+		})
+	}
+}
+
+func TestDialerMaxStreams(t *testing.T) {
+	for _, protocol := range []string{ProtocolYamux, ProtocolSmux} {
+		t.Run(protocol, func(t *testing.T) {
+			addr, _ := muxServer(t, protocol)
+			base := &countDialer{}
+			d, err := Dialer(base, ProtocolOption(protocol), MaxStreamsOption(2))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer d.(io.Closer).Close()
+
+			for i := 0; i < 5; i++ {
+				stream, err := d.Dial(context.Background(), "tcp", addr)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer stream.Close()
+				echo(t, stream, "hello")
+			}
+			if n := base.n.Load(); n != 3 {
+				t.Fatalf("expected 3 sessions for 5 streams, got %d", n)
+			}
+		})
+	}
+}
+
+func TestDialerMaxStreamsConcurrent(t *testing.T) {
+	for _, protocol := range []string{ProtocolYamux, ProtocolSmux} {
+		t.Run(protocol, func(t *testing.T) {
+			addr, conns := muxServer(t, protocol)
+			// the concurrent dials may create more sessions than the buffer of conns.
+			go func() {
+				for range conns {
+				}
+			}()
+			base := &countDialer{}
+			d, err := Dialer(base, ProtocolOption(protocol), MaxStreamsOption(2))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer d.(io.Closer).Close()
+
+			const n = 20
+			streams := make(chan net.Conn, n)
+			start := make(chan struct{})
+			var wg sync.WaitGroup
+			for i := 0; i < n; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					<-start
+					stream, err := d.Dial(context.Background(), "tcp", addr)
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					streams <- stream
+				}()
+			}
+			close(start)
+			wg.Wait()
+			close(streams)
+
+			md := d.(*muxDialer)
+			md.mu.Lock()
+			for _, s := range md.sessions["tcp://"+addr] {
+				if s.streams > 2 || s.NumStreams() > 2 {
+					t.Errorf("the session exceeds the stream limit: %d reserved, %d open", s.streams, s.NumStreams())
+				}
+			}
+			md.mu.Unlock()
+			if nb := base.n.Load(); nb < n/2 {
+				t.Fatalf("expected at least %d sessions for %d streams, got %d", n/2, n, nb)
+			}
+
+			// the closed stream releases its slot.
+			var first net.Conn
+			for stream := range streams {
+				if first == nil {
+					first = stream
+					continue
+				}
+				defer stream.Close()
+			}
+			dials := base.n.Load()
+			first.Close()
+			first.Close()
+			stream, err := d.Dial(context.Background(), "tcp", addr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer stream.Close()
+			echo(t, stream, "hello")
+			if nb := base.n.Load(); nb != dials {
+				t.Fatalf("the released slot is not reused, %d dials", nb-dials)
+			}
+		})
+	}
+}
+
+func TestDialerSessionDeath(t *testing.T) {
+	for _, protocol := range []string{ProtocolYamux, ProtocolSmux} {
+		t.Run(protocol, func(t *testing.T) {
+			addr, conns := muxServer(t, protocol)
+			base := &countDialer{}
+			d, err := Dialer(base, ProtocolOption(protocol))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer d.(io.Closer).Close()
+
+			stream, err := d.Dial(context.Background(), "tcp", addr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			echo(t, stream, "hello")
+
+			// the base connection dies.
+			(<-conns).Close()
+
+			stream.SetReadDeadline(time.Now().Add(time.Second))
+			if _, err := stream.Read(make([]byte, 1)); err == nil || errors.Is(err, os.ErrDeadlineExceeded) {
+				t.Fatalf("the open stream should get the session error, got %v", err)
+			}
+
+			// a new session is dialed for the dead one.
+			stream, err = d.Dial(context.Background(), "tcp", addr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer stream.Close()
+			echo(t, stream, "again")
+			if n := base.n.Load(); n != 2 {
+				t.Fatalf("expected a new connection after the session death, got %d", n)
+			}
+		})
+	}
+}
+
+func TestDialerClose(t *testing.T) {
+	addr, _ := muxServer(t, ProtocolYamux)
+	d, err := Dialer(&countDialer{}, ProtocolOption(ProtocolYamux))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream, err := d.Dial(context.Background(), "tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d.(io.Closer).Close()
+	stream.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := stream.Read(make([]byte, 1)); err == nil {
+		t.Fatal("the streams should be closed with the dialer")
+	}
+}
+
+func TestDialerUnknownProtocol(t *testing.T) {
+	if _, err := Dialer(&countDialer{}, ProtocolOption("unknown")); !errors.Is(err, ErrUnknownProtocol) {
+		t.Fatalf("expected ErrUnknownProtocol, got %v", err)
+	}
+}
+
+// fakeSession is a Session failing to open streams while it is alive.
+type fakeSession struct {
+	closed atomic.Bool
+	closes atomic.Int64
+	fail   bool
+}
+
+func (s *fakeSession) OpenStream() (net.Conn, error) {
+	if s.fail {
+		return nil, errors.New("streams exhausted")
+	}
+	c, _ := net.Pipe()
+	return c, nil
+}
+
+func (s *fakeSession) NumStreams() int {
+	return 0
+}
+
+func (s *fakeSession) IsClosed() bool {
+	return s.closed.Load()
+}
+
+func (s *fakeSession) Close() error {
+	s.closes.Add(1)
+	s.closed.Store(true)
+	return nil
+}
+
+func TestDialerOpenStreamFailure(t *testing.T) {
+	var sessions []*fakeSession
+	Register("fake", func(conn net.Conn, opts *Options) (Session, error) {
+		s := &fakeSession{}
+		sessions = append(sessions, s)
+		return s, nil
+	})
+
+	addr, _ := muxServer(t, ProtocolYamux)
+	base := &countDialer{}
+	d, err := Dialer(base, ProtocolOption("fake"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Dial(context.Background(), "tcp", addr); err != nil {
+		t.Fatal(err)
+	}
+	// the first session fails to open the following streams.
+	sessions[0].fail = true
+
+	if _, err := d.Dial(context.Background(), "tcp", addr); err != nil {
+		t.Fatal(err)
+	}
+	if n := sessions[0].closes.Load(); n != 0 {
+		t.Fatal("the live session should not be closed on the stream failure")
+	}
+	if len(sessions) != 2 || base.n.Load() != 2 {
+		t.Fatalf("expected a new session, got %d sessions", len(sessions))
+	}
+
+	// the live session is kept for its open streams.
+	md := d.(*muxDialer)
+	if n := len(md.getSessions("tcp://" + addr)); n != 2 {
+		t.Fatalf("expected 2 sessions kept, got %d", n)
+	}
+
+	// the dead session is dropped.
+	sessions[0].closed.Store(true)
+	if _, err := d.Dial(context.Background(), "tcp", addr); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(md.getSessions("tcp://" + addr)); n != 1 {
+		t.Fatalf("expected the dead session dropped, got %d", n)
+	}
+}
+
+// silentServer accepts the connections and never answers.
+func silentServer(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { conn.Close() })
+			go io.Copy(io.Discard, conn)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// testKeepAlive asserts the session of client over a connection to a silent peer is closed by the keepalive.
+func testKeepAlive(t *testing.T, client ClientFunc) {
+	conn, err := net.Dial("tcp", silentServer(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, err := client(conn, &Options{
+		KeepAliveInterval: 20 * time.Millisecond,
+		KeepAliveTimeout:  100 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !session.IsClosed() {
+		if time.Now().After(deadline) {
+			t.Fatal("the session to the dead peer is not closed by the keepalive")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
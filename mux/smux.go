@@ -0,0 +1,45 @@
+package mux
+
+import (
+	"net"
+
+	"github.com/xtaci/smux"
+)
+
+type smuxSession struct {
+	session *smux.Session
+}
+
+// SmuxClient creates a smux client session over conn,
+// the session sends a keepalive every KeepAliveInterval and dies if nothing is received in KeepAliveTimeout.
+func SmuxClient(conn net.Conn, opts *Options) (Session, error) {
+	config := smux.DefaultConfig()
+	if opts.KeepAliveInterval > 0 {
+		config.KeepAliveInterval = opts.KeepAliveInterval
+	}
+	if opts.KeepAliveTimeout > 0 {
+		config.KeepAliveTimeout = opts.KeepAliveTimeout
+	}
+
+	session, err := smux.Client(conn, config)
+	if err != nil {
+		return nil, err
+	}
+	return &smuxSession{session: session}, nil
+}
+
+func (s *smuxSession) OpenStream() (net.Conn, error) {
+	return s.session.OpenStream()
+}
+
+func (s *smuxSession) NumStreams() int {
+	return s.session.NumStreams()
+}
+
+func (s *smuxSession) IsClosed() bool {
+	return s.session.IsClosed()
+}
+
+func (s *smuxSession) Close() error {
+	return s.session.Close()
+}
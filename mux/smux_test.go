@@ -0,0 +1,22 @@
+package mux
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestSmuxKeepAlive(t *testing.T) {
+	testKeepAlive(t, SmuxClient)
+}
+
+func TestSmuxInvalidKeepAlive(t *testing.T) {
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+
+	// the timeout must not be shorter than the interval.
+	if _, err := SmuxClient(c1, &Options{KeepAliveInterval: time.Second, KeepAliveTimeout: time.Millisecond}); err == nil {
+		t.Fatal("expected error for invalid keepalive")
+	}
+}
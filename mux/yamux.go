@@ -0,0 +1,47 @@
+package mux
+
+import (
+	"io"
+	"net"
+
+	"github.com/hashicorp/yamux"
+)
+
+type yamuxSession struct {
+	session *yamux.Session
+}
+
+// YamuxClient creates a yamux client session over conn,
+// the session pings the peer every KeepAliveInterval and dies if a ping is not answered in KeepAliveTimeout.
+func YamuxClient(conn net.Conn, opts *Options) (Session, error) {
+	config := yamux.DefaultConfig()
+	config.LogOutput = io.Discard
+	if opts.KeepAliveInterval > 0 {
+		config.KeepAliveInterval = opts.KeepAliveInterval
+	}
+	if opts.KeepAliveTimeout > 0 {
+		config.ConnectionWriteTimeout = opts.KeepAliveTimeout
+	}
+
+	session, err := yamux.Client(conn, config)
+	if err != nil {
+		return nil, err
+	}
+	return &yamuxSession{session: session}, nil
+}
+
+func (s *yamuxSession) OpenStream() (net.Conn, error) {
+	return s.session.OpenStream()
+}
+
+func (s *yamuxSession) NumStreams() int {
+	return s.session.NumStreams()
+}
+
+func (s *yamuxSession) IsClosed() bool {
+	return s.session.IsClosed()
+}
+
+func (s *yamuxSession) Close() error {
+	return s.session.Close()
+}
@@ -0,0 +1,9 @@
+package mux
+
+import (
+	"testing"
+)
+
+func TestYamuxKeepAlive(t *testing.T) {
+	testKeepAlive(t, YamuxClient)
+}
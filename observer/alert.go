@@ -0,0 +1,181 @@
+package observer
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-gost/core/common/clock"
+)
+
+// The well-known metric names of the MetricEvent.
+const (
+	MetricErrorRate    = "error_rate"
+	MetricLatency      = "latency"
+	MetricHealthyNodes = "healthy_nodes"
+)
+
+// MetricEvent is a sample of a metric, e.g. the error rate of a service.
+type MetricEvent struct {
+	Name  string
+	Value float64
+}
+
+func (e MetricEvent) Type() EventType {
+	return EventMetric
+}
+
+// AlertState is the state of an alert.
+type AlertState string
+
+const (
+	AlertFiring   AlertState = "firing"
+	AlertResolved AlertState = "resolved"
+)
+
+// AlertRule is a threshold on a metric.
+type AlertRule struct {
+	Name   string
+	Metric string
+	// Threshold is breached when the value is above it, or below it if Below is set (e.g. for the healthy nodes).
+	Threshold float64
+	Below     bool
+	// For is the grace period the breach must persist for before the alert fires.
+	For time.Duration
+	// Hysteresis is the margin past the threshold the value must recover by to resolve the alert, which prevents flapping.
+	Hysteresis float64
+	// ResolveFor is the period the recovery must persist for before the alert resolves, default is For.
+	ResolveFor time.Duration
+}
+
+// AlertEvent is the event of an alert state transition.
+type AlertEvent struct {
+	Rule  string
+	State AlertState
+	// Value is the metric value at the transition.
+	Value float64
+	Time  time.Time
+}
+
+func (e AlertEvent) Type() EventType {
+	return EventAlert
+}
+
+type alertState struct {
+	firing bool
+	// since is the start of the pending transition, zero if none.
+	since time.Time
+}
+
+// Alerter watches the metrics against the alert rules and emits the debounced AlertEvent to an observer
+// when a breach or a recovery persists beyond the grace period.
+// It implements Observer, so it can be fed with the MetricEvent directly.
+type Alerter struct {
+	rules    []AlertRule
+	states   []alertState
+	observer Observer
+	clock    clock.Clock
+	mu       sync.Mutex
+}
+
+// NewAlerter creates an Alerter emitting the alert events to o, c is the clock, nil means the real clock.
+func NewAlerter(o Observer, c clock.Clock, rules ...AlertRule) *Alerter {
+	return &Alerter{
+		rules:    rules,
+		states:   make([]alertState, len(rules)),
+		observer: o,
+		clock:    clock.OrDefault(c),
+	}
+}
+
+// Observe implements Observer, the MetricEvent are evaluated and the other events are ignored.
+func (a *Alerter) Observe(ctx context.Context, events []Event, opts ...Option) error {
+	for _, ev := range events {
+		if m, ok := ev.(MetricEvent); ok {
+			a.Update(ctx, m.Name, m.Value)
+		}
+	}
+	return nil
+}
+
+// Update evaluates the value of the metric against the rules.
+func (a *Alerter) Update(ctx context.Context, metric string, value float64) {
+	now := a.clock.Now()
+
+	var events []Event
+	a.mu.Lock()
+	for i := range a.rules {
+		rule := &a.rules[i]
+		if rule.Metric != metric {
+			continue
+		}
+		if ev, ok := a.evaluate(rule, &a.states[i], value, now); ok {
+			events = append(events, ev)
+		}
+	}
+	a.mu.Unlock()
+
+	if len(events) > 0 && a.observer != nil {
+		a.observer.Observe(ctx, events)
+	}
+}
+
+// Firing returns the names of the firing alerts.
+func (a *Alerter) Firing() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var names []string
+	for i := range a.rules {
+		if a.states[i].firing {
+			names = append(names, a.rules[i].Name)
+		}
+	}
+	return names
+}
+
+func (a *Alerter) evaluate(rule *AlertRule, state *alertState, value float64, now time.Time) (AlertEvent, bool) {
+	// the condition to move away from the current state.
+	var transit bool
+	period := rule.For
+	if state.firing {
+		if rule.Below {
+			transit = value >= rule.Threshold+rule.Hysteresis
+		} else {
+			transit = value <= rule.Threshold-rule.Hysteresis
+		}
+		if rule.ResolveFor > 0 {
+			period = rule.ResolveFor
+		}
+	} else {
+		if rule.Below {
+			transit = value < rule.Threshold
+		} else {
+			transit = value > rule.Threshold
+		}
+	}
+
+	if !transit {
+		state.since = time.Time{}
+		return AlertEvent{}, false
+	}
+	if state.since.IsZero() {
+		state.since = now
+	}
+	if now.Sub(state.since) < period {
+		return AlertEvent{}, false
+	}
+
+	state.firing = !state.firing
+	state.since = time.Time{}
+	ev := AlertEvent{
+		Rule:  rule.Name,
+		State: AlertResolved,
+		Value: value,
+		Time:  now,
+	}
+	if state.firing {
+		ev.State = AlertFiring
+	}
+	return ev, true
+}
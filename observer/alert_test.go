@@ -0,0 +1,127 @@
+package observer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-gost/core/common/clock"
+)
+
+// alertObserver keeps the alert events.
+type alertObserver struct {
+	events []AlertEvent
+}
+
+func (o *alertObserver) Observe(ctx context.Context, events []Event, opts ...Option) error {
+	for _, ev := range events {
+		if ae, ok := ev.(AlertEvent); ok {
+			o.events = append(o.events, ae)
+		}
+	}
+	return nil
+}
+
+func (o *alertObserver) last() AlertEvent {
+	if len(o.events) == 0 {
+		return AlertEvent{}
+	}
+	return o.events[len(o.events)-1]
+}
+
+func TestAlerterDebounce(t *testing.T) {
+	c := clock.NewFakeClock(time.Unix(1000, 0))
+	o := &alertObserver{}
+	a := NewAlerter(o, c, AlertRule{
+		Name:      "high-errors",
+		Metric:    MetricErrorRate,
+		Threshold: 0.1,
+		For:       time.Minute,
+	})
+	update := func(d time.Duration, value float64) {
+		c.Advance(d)
+		a.Observe(context.Background(), []Event{MetricEvent{Name: MetricErrorRate, Value: value}})
+	}
+
+	// a transient spike under the grace period does not alert.
+	update(0, 0.5)
+	update(30*time.Second, 0.6)
+	update(20*time.Second, 0.01)
+	update(20*time.Second, 0.5)
+	update(50*time.Second, 0.5)
+	if len(o.events) != 0 || len(a.Firing()) != 0 {
+		t.Fatalf("the transient spikes should not alert, got %v", o.events)
+	}
+
+	// a sustained breach fires.
+	update(10*time.Second, 0.4)
+	if len(o.events) != 1 || o.last().State != AlertFiring || o.last().Rule != "high-errors" || o.last().Value != 0.4 {
+		t.Fatalf("expected the firing alert, got %v", o.events)
+	}
+	if !o.last().Time.Equal(c.Now()) || o.last().Type() != EventAlert {
+		t.Fatalf("unexpected alert event %+v", o.last())
+	}
+	if firing := a.Firing(); len(firing) != 1 || firing[0] != "high-errors" {
+		t.Fatalf("unexpected firing alerts %v", firing)
+	}
+	// no repeated event while firing.
+	update(time.Minute, 0.9)
+	if len(o.events) != 1 {
+		t.Fatalf("unexpected events %v", o.events)
+	}
+
+	// the sustained recovery resolves it.
+	update(0, 0.05)
+	update(59*time.Second, 0.05)
+	if len(o.events) != 1 {
+		t.Fatalf("the recovery is not sustained yet, got %v", o.events)
+	}
+	update(time.Second, 0.05)
+	if len(o.events) != 2 || o.last().State != AlertResolved || len(a.Firing()) != 0 {
+		t.Fatalf("expected the resolved alert, got %v", o.events)
+	}
+}
+
+func TestAlerterHysteresis(t *testing.T) {
+	c := clock.NewFakeClock(time.Unix(1000, 0))
+	o := &alertObserver{}
+	a := NewAlerter(o, c, AlertRule{
+		Name:       "few-nodes",
+		Metric:     MetricHealthyNodes,
+		Threshold:  3,
+		Below:      true,
+		Hysteresis: 2,
+		ResolveFor: 10 * time.Second,
+	})
+	update := func(d time.Duration, value float64) {
+		c.Advance(d)
+		a.Update(context.Background(), MetricHealthyNodes, value)
+	}
+
+	// without the grace period the breach fires at once.
+	update(0, 2)
+	if len(o.events) != 1 || o.last().State != AlertFiring {
+		t.Fatalf("expected the firing alert, got %v", o.events)
+	}
+
+	// the value oscillating around the threshold does not resolve it.
+	update(time.Minute, 3)
+	update(time.Minute, 4)
+	if len(o.events) != 1 {
+		t.Fatalf("the recovery within the hysteresis should not resolve, got %v", o.events)
+	}
+
+	// recovered past the hysteresis for the resolve period.
+	update(0, 5)
+	update(10*time.Second, 6)
+	if len(o.events) != 2 || o.last().State != AlertResolved || o.last().Value != 6 {
+		t.Fatalf("expected the resolved alert, got %v", o.events)
+	}
+
+	// the other metrics and events are ignored.
+	a.Update(context.Background(), MetricLatency, 0)
+	a.Observe(context.Background(), []Event{AlertEvent{}})
+	if len(o.events) != 2 {
+		t.Fatalf("unexpected events %v", o.events)
+	}
+}
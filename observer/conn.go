@@ -0,0 +1,122 @@
+package observer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-gost/core/common/ctxvalue"
+)
+
+// ConnInfo describes a connection in the lifecycle callbacks.
+type ConnInfo struct {
+	// TraceID is the trace ID of the connection, it is taken from the context if not set.
+	TraceID    string
+	Service    string
+	Node       string
+	ClientAddr string
+	// Duration is the lifetime of the connection, it is set on close.
+	Duration time.Duration
+	// InputBytes is the number of bytes read from the connection.
+	InputBytes int64
+	// OutputBytes is the number of bytes written to the connection.
+	OutputBytes int64
+	Err         error
+}
+
+// ConnObserver receives the lifecycle events of the connections.
+// The callbacks are invoked synchronously on the connection path,
+// so they must be cheap and must not block.
+type ConnObserver interface {
+	OnConnOpen(ctx context.Context, info *ConnInfo)
+	OnConnClose(ctx context.Context, info *ConnInfo)
+	OnError(ctx context.Context, info *ConnInfo)
+}
+
+type observedConn struct {
+	net.Conn
+	ctx       context.Context
+	observer  ConnObserver
+	info      ConnInfo
+	start     time.Time
+	inputs    atomic.Int64
+	outputs   atomic.Int64
+	err       atomic.Value
+	errOnce   sync.Once
+	closeOnce sync.Once
+}
+
+// ObserveConn wraps c to report its lifecycle to o: OnConnOpen is called immediately,
+// OnError on the first read or write error other than EOF, and OnConnClose once on close.
+func ObserveConn(ctx context.Context, c net.Conn, o ConnObserver, info ConnInfo) net.Conn {
+	if o == nil {
+		return c
+	}
+	if info.TraceID == "" {
+		info.TraceID = ctxvalue.TraceIDFromContext(ctx)
+	}
+
+	conn := &observedConn{
+		Conn:     c,
+		ctx:      ctx,
+		observer: o,
+		info:     info,
+		start:    time.Now(),
+	}
+	o.OnConnOpen(ctx, conn.snapshot())
+	return conn
+}
+
+func (c *observedConn) snapshot() *ConnInfo {
+	info := c.info
+	info.Duration = time.Since(c.start)
+	info.InputBytes = c.inputs.Load()
+	info.OutputBytes = c.outputs.Load()
+	if err, _ := c.err.Load().(error); err != nil {
+		info.Err = err
+	}
+	return &info
+}
+
+func (c *observedConn) onError(err error) {
+	if err == nil || errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) {
+		return
+	}
+	c.errOnce.Do(func() {
+		c.err.Store(err)
+		c.observer.OnError(c.ctx, c.snapshot())
+	})
+}
+
+func (c *observedConn) Read(b []byte) (n int, err error) {
+	n, err = c.Conn.Read(b)
+	c.inputs.Add(int64(n))
+	c.onError(err)
+	return
+}
+
+func (c *observedConn) Write(b []byte) (n int, err error) {
+	n, err = c.Conn.Write(b)
+	c.outputs.Add(int64(n))
+	c.onError(err)
+	return
+}
+
+func (c *observedConn) Close() error {
+	err := c.Conn.Close()
+	c.closeOnce.Do(func() {
+		c.observer.OnConnClose(c.ctx, c.snapshot())
+	})
+	return err
+}
+
+func (c *observedConn) CloseWrite() error {
+	if cw, ok := c.Conn.(interface{ CloseWrite() error }); ok {
+		return cw.CloseWrite()
+	}
+	return c.Close()
+}
@@ -0,0 +1,201 @@
+package observer
+
+import (
+	"context"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-gost/core/common/ctxvalue"
+	"github.com/go-gost/core/logger"
+	"github.com/go-gost/core/recorder"
+)
+
+type connEvent struct {
+	kind string
+	info ConnInfo
+}
+
+// recordObserver records the lifecycle callbacks in order.
+type recordObserver struct {
+	events []connEvent
+	mu     sync.Mutex
+}
+
+func (o *recordObserver) record(kind string, info *ConnInfo) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.events = append(o.events, connEvent{kind: kind, info: *info})
+}
+
+func (o *recordObserver) OnConnOpen(ctx context.Context, info *ConnInfo) {
+	o.record("open", info)
+}
+
+func (o *recordObserver) OnConnClose(ctx context.Context, info *ConnInfo) {
+	o.record("close", info)
+}
+
+func (o *recordObserver) OnError(ctx context.Context, info *ConnInfo) {
+	o.record("error", info)
+}
+
+func (o *recordObserver) kinds() []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	var kinds []string
+	for _, ev := range o.events {
+		kinds = append(kinds, ev.kind)
+	}
+	return kinds
+}
+
+func TestObserveConnLifecycle(t *testing.T) {
+	c1, c2 := net.Pipe()
+	defer c2.Close()
+
+	o := &recordObserver{}
+	ctx := ctxvalue.ContextWithTraceID(context.Background(), "trace-1")
+	conn := ObserveConn(ctx, c1, o, ConnInfo{
+		Service:    "svc",
+		Node:       "node-1",
+		ClientAddr: "192.168.1.10:1234",
+	})
+
+	go func() {
+		c2.Write([]byte("hello"))
+		io.ReadFull(c2, make([]byte, 3))
+	}()
+	if _, err := io.ReadFull(conn, make([]byte, 5)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Write([]byte("bye")); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	conn.Close()
+	conn.Close()
+
+	if kinds := o.kinds(); len(kinds) != 2 || kinds[0] != "open" || kinds[1] != "close" {
+		t.Fatalf("unexpected callbacks %v", kinds)
+	}
+	open, closed := o.events[0].info, o.events[1].info
+	if open.TraceID != "trace-1" || open.Service != "svc" || open.Node != "node-1" || open.ClientAddr != "192.168.1.10:1234" {
+		t.Fatalf("unexpected open info %+v", open)
+	}
+	if closed.InputBytes != 5 || closed.OutputBytes != 3 || closed.Err != nil {
+		t.Fatalf("unexpected close info %+v", closed)
+	}
+	if closed.Duration < 10*time.Millisecond {
+		t.Fatalf("duration %s", closed.Duration)
+	}
+}
+
+func TestObserveConnError(t *testing.T) {
+	c1, c2 := net.Pipe()
+	c2.Close()
+
+	o := &recordObserver{}
+	conn := ObserveConn(context.Background(), c1, o, ConnInfo{Node: "node-1"})
+	if _, err := conn.Write([]byte("x")); err == nil {
+		t.Fatal("expected write error")
+	}
+	// only the first error is reported.
+	conn.Write([]byte("x"))
+	conn.Close()
+
+	kinds := o.kinds()
+	if len(kinds) != 3 || kinds[1] != "error" || kinds[2] != "close" {
+		t.Fatalf("unexpected callbacks %v", kinds)
+	}
+	if o.events[1].info.Err == nil || o.events[2].info.Err == nil {
+		t.Fatal("error is not carried in the info")
+	}
+}
+
+func TestObserveConnEOF(t *testing.T) {
+	c1, c2 := net.Pipe()
+	c2.Close()
+
+	o := &recordObserver{}
+	conn := ObserveConn(context.Background(), c1, o, ConnInfo{})
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+	conn.Close()
+
+	if kinds := o.kinds(); len(kinds) != 2 {
+		t.Fatalf("EOF should not be reported as error: %v", kinds)
+	}
+}
+
+func TestObserveConnNil(t *testing.T) {
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+
+	if ObserveConn(context.Background(), c1, nil, ConnInfo{}) != c1 {
+		t.Fatal("nil observer should not wrap the conn")
+	}
+}
+
+// traceLogger records the trace ID field of the logged messages.
+type traceLogger struct {
+	logger.Logger
+	tid  any
+	tids *[]any
+}
+
+func (l *traceLogger) WithFields(m map[string]any) logger.Logger {
+	return &traceLogger{tid: m["tid"], tids: l.tids}
+}
+
+func (l *traceLogger) Infof(format string, args ...any) {
+	*l.tids = append(*l.tids, l.tid)
+}
+
+type bufRecorder struct {
+	records []string
+}
+
+func (r *bufRecorder) Record(ctx context.Context, b []byte, opts ...recorder.RecordOption) error {
+	r.records = append(r.records, string(b))
+	return nil
+}
+
+func TestTraceIDAcrossTelemetry(t *testing.T) {
+	// the trace ID is generated at accept time.
+	ctx := ctxvalue.ContextWithTraceID(context.Background(), "")
+	id := ctxvalue.TraceIDFromContext(ctx)
+
+	log := &traceLogger{tids: new([]any)}
+	logger.WithTraceID(ctx, log).Infof("accepted")
+
+	r := &bufRecorder{}
+	recorder.NewAccessLogger(r, recorder.FormatAccessLogOption(recorder.AccessLogFormatJSON),
+		recorder.FieldsAccessLogOption(recorder.AccessLogFieldTraceID)).
+		Record(ctx, &recorder.AccessLogEntry{Host: "example.com:443"})
+
+	c1, c2 := net.Pipe()
+	defer c2.Close()
+	o := &recordObserver{}
+	ObserveConn(ctx, c1, o, ConnInfo{Service: "svc"}).Close()
+
+	if len(*log.tids) != 1 || (*log.tids)[0] != id {
+		t.Fatalf("the log entry has trace ID %v, want %s", *log.tids, id)
+	}
+	if len(r.records) != 1 || !strings.Contains(r.records[0], `"tid":"`+id+`"`) {
+		t.Fatalf("the record %q has no trace ID %s", r.records, id)
+	}
+	if len(o.events) != 2 {
+		t.Fatalf("expected the open and close events, got %v", o.kinds())
+	}
+	for _, ev := range o.events {
+		if ev.info.TraceID != id {
+			t.Fatalf("the %s event has trace ID %q, want %s", ev.kind, ev.info.TraceID, id)
+		}
+	}
+}
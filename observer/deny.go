@@ -0,0 +1,114 @@
+package observer
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultDenyQueueSize = 128
+	defaultDenyTimeout   = 5 * time.Second
+)
+
+// DenyReason is the reason code of a denied connection.
+type DenyReason string
+
+const (
+	// DenyBypass means the destination is matched by a bypass rule.
+	DenyBypass DenyReason = "bypass"
+	// DenyWhitelist means the destination is not in the whitelist.
+	DenyWhitelist DenyReason = "whitelist"
+	// DenyRebinding means the destination resolves to the internal addresses only.
+	DenyRebinding DenyReason = "rebinding"
+	// DenyAuth means the client failed the authentication.
+	DenyAuth DenyReason = "auth"
+)
+
+// DenyEvent is the event of a connection denied by the bypass, the resolver filter or the authenticator.
+type DenyEvent struct {
+	Time    time.Time
+	Reason  DenyReason
+	Service string
+	// Client is the client address.
+	Client string
+	// User is the client identity if known, e.g. the username on authentication failure.
+	User    string
+	Network string
+	// Addr is the destination address.
+	Addr string
+	// Rule is the matched rule if known.
+	Rule    string
+	TraceID string
+}
+
+func (e DenyEvent) Type() EventType {
+	return EventDeny
+}
+
+// DenyNotifier delivers the deny events to an observer asynchronously,
+// so the connection path is never blocked by the observer. The events are dropped when the queue is full.
+type DenyNotifier struct {
+	observer  Observer
+	events    chan Event
+	done      chan struct{}
+	closeOnce sync.Once
+	dropped   atomic.Int64
+}
+
+// NewDenyNotifier creates a DenyNotifier delivering to the observer o with the queue size.
+func NewDenyNotifier(o Observer, size int) *DenyNotifier {
+	if size <= 0 {
+		size = defaultDenyQueueSize
+	}
+	n := &DenyNotifier{
+		observer: o,
+		events:   make(chan Event, size),
+		done:     make(chan struct{}),
+	}
+	go n.run()
+	return n
+}
+
+func (n *DenyNotifier) run() {
+	for {
+		select {
+		case ev := <-n.events:
+			ctx, cancel := context.WithTimeout(context.Background(), defaultDenyTimeout)
+			n.observer.Observe(ctx, []Event{ev})
+			cancel()
+		case <-n.done:
+			return
+		}
+	}
+}
+
+// Notify queues the event, the time of the event is set if it is zero. It is safe to call on a nil notifier.
+func (n *DenyNotifier) Notify(ev DenyEvent) {
+	if n == nil || n.observer == nil {
+		return
+	}
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+
+	select {
+	case n.events <- ev:
+	default:
+		n.dropped.Add(1)
+	}
+}
+
+// Dropped returns the number of the events dropped due to the full queue.
+func (n *DenyNotifier) Dropped() int64 {
+	return n.dropped.Load()
+}
+
+// Close stops the delivery, the events still in the queue are discarded.
+func (n *DenyNotifier) Close() error {
+	n.closeOnce.Do(func() {
+		close(n.done)
+	})
+	return nil
+}
@@ -0,0 +1,100 @@
+package observer
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// chanObserver sends the events to a channel, it blocks until release is closed if set.
+type chanObserver struct {
+	events  chan Event
+	release chan struct{}
+}
+
+func (o *chanObserver) Observe(ctx context.Context, events []Event, opts ...Option) error {
+	if o.release != nil {
+		<-o.release
+	}
+	for _, ev := range events {
+		o.events <- ev
+	}
+	return nil
+}
+
+func TestDenyNotifier(t *testing.T) {
+	o := &chanObserver{events: make(chan Event, 1)}
+	n := NewDenyNotifier(o, 0)
+	defer n.Close()
+
+	n.Notify(DenyEvent{Reason: DenyBypass, Addr: "example.com:443", Rule: "*.example.com"})
+
+	select {
+	case ev := <-o.events:
+		de, ok := ev.(DenyEvent)
+		if !ok || ev.Type() != EventDeny {
+			t.Fatalf("unexpected event %#v", ev)
+		}
+		if de.Reason != DenyBypass || de.Addr != "example.com:443" || de.Rule != "*.example.com" || de.Time.IsZero() {
+			t.Fatalf("unexpected deny event %+v", de)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no event is delivered")
+	}
+}
+
+func TestDenyNotifierNonBlocking(t *testing.T) {
+	o := &chanObserver{events: make(chan Event, 10), release: make(chan struct{})}
+	n := NewDenyNotifier(o, 2)
+	defer n.Close()
+
+	// the observer is stuck, the notifications never block.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 10; i++ {
+			n.Notify(DenyEvent{Reason: DenyAuth})
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Notify is blocked by the observer")
+	}
+
+	// one event in the observer and two queued at most.
+	if d := n.Dropped(); d < 7 {
+		t.Fatalf("expected at least 7 dropped events, got %d", d)
+	}
+	close(o.release)
+}
+
+func TestDenyNotifierNil(t *testing.T) {
+	var n *DenyNotifier
+	n.Notify(DenyEvent{Reason: DenyAuth})
+
+	n = NewDenyNotifier(nil, 1)
+	defer n.Close()
+	n.Notify(DenyEvent{Reason: DenyAuth})
+	if d := n.Dropped(); d != 0 {
+		t.Fatalf("the events should be ignored without observer, got %d dropped", d)
+	}
+}
+
+func TestDenyNotifierConcurrentClose(t *testing.T) {
+	n := NewDenyNotifier(&chanObserver{events: make(chan Event, 1)}, 0)
+
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			n.Close()
+		}()
+	}
+	close(start)
+	wg.Wait()
+}
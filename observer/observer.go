@@ -15,6 +15,11 @@ type EventType string
 const (
 	EventStatus EventType = "status"
 	EventStats  EventType = "stats"
+	EventNode   EventType = "node"
+	EventDeny   EventType = "deny"
+	EventPhase  EventType = "phase"
+	EventMetric EventType = "metric"
+	EventAlert  EventType = "alert"
 )
 
 type Event interface {
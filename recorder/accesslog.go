@@ -0,0 +1,274 @@
+package recorder
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/go-gost/core/common/ctxvalue"
+)
+
+// AccessLogFormat is the output format of the access log.
+type AccessLogFormat string
+
+const (
+	// AccessLogFormatCombined renders the fields separated by space in the style of the combined log format,
+	// the spaces and control characters of the values are escaped as \xHH.
+	AccessLogFormatCombined AccessLogFormat = "combined"
+	// AccessLogFormatJSON renders the fields as a JSON object, one object per line.
+	AccessLogFormatJSON AccessLogFormat = "json"
+)
+
+// AccessLogField is a field in the access log.
+type AccessLogField string
+
+const (
+	AccessLogFieldTime     AccessLogField = "time"
+	AccessLogFieldClientIP AccessLogField = "client"
+	AccessLogFieldUser     AccessLogField = "user"
+	AccessLogFieldMethod   AccessLogField = "method"
+	AccessLogFieldHost     AccessLogField = "host"
+	AccessLogFieldNode     AccessLogField = "node"
+	AccessLogFieldStatus   AccessLogField = "status"
+	AccessLogFieldBytes    AccessLogField = "bytes"
+	AccessLogFieldDuration AccessLogField = "duration"
+	AccessLogFieldTraceID  AccessLogField = "tid"
+)
+
+var (
+	defaultAccessLogFields = []AccessLogField{
+		AccessLogFieldClientIP,
+		AccessLogFieldUser,
+		AccessLogFieldTime,
+		AccessLogFieldMethod,
+		AccessLogFieldHost,
+		AccessLogFieldStatus,
+		AccessLogFieldBytes,
+		AccessLogFieldDuration,
+		AccessLogFieldNode,
+	}
+)
+
+const (
+	accessLogTimeFormat = "02/Jan/2006:15:04:05 -0700"
+)
+
+// AccessLogEntry is an access log record of a connection or request.
+type AccessLogEntry struct {
+	Time     time.Time
+	ClientIP string
+	User     string
+	// Method is the request method, e.g. the HTTP method or CONNECT.
+	Method   string
+	Host     string
+	Node     string
+	Status   int
+	Bytes    int64
+	Duration time.Duration
+	TraceID  string
+}
+
+type accessLogKey struct{}
+
+// ContextWithAccessLog returns a context carrying the entry, which is filled in by the handler
+// as the connection is processed and rendered by AccessLogger.Log when it is done.
+func ContextWithAccessLog(ctx context.Context, entry *AccessLogEntry) context.Context {
+	return context.WithValue(ctx, accessLogKey{}, entry)
+}
+
+func AccessLogFromContext(ctx context.Context) *AccessLogEntry {
+	v, _ := ctx.Value(accessLogKey{}).(*AccessLogEntry)
+	return v
+}
+
+type AccessLogOptions struct {
+	Format AccessLogFormat
+	// Fields is the fields to render in order.
+	Fields []AccessLogField
+}
+
+type AccessLogOption func(opts *AccessLogOptions)
+
+func FormatAccessLogOption(format AccessLogFormat) AccessLogOption {
+	return func(opts *AccessLogOptions) {
+		opts.Format = format
+	}
+}
+
+func FieldsAccessLogOption(fields ...AccessLogField) AccessLogOption {
+	return func(opts *AccessLogOptions) {
+		opts.Fields = fields
+	}
+}
+
+// AccessLogger renders the access log entries and records them through a Recorder.
+type AccessLogger struct {
+	recorder Recorder
+	options  AccessLogOptions
+}
+
+func NewAccessLogger(r Recorder, opts ...AccessLogOption) *AccessLogger {
+	var options AccessLogOptions
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+	if options.Format == "" {
+		options.Format = AccessLogFormatCombined
+	}
+	if len(options.Fields) == 0 {
+		options.Fields = defaultAccessLogFields
+	}
+
+	return &AccessLogger{
+		recorder: r,
+		options:  options,
+	}
+}
+
+// Log records the entry carried in ctx, it does nothing if there is none.
+func (l *AccessLogger) Log(ctx context.Context, opts ...RecordOption) error {
+	entry := AccessLogFromContext(ctx)
+	if entry == nil {
+		return nil
+	}
+	return l.Record(ctx, entry, opts...)
+}
+
+// Record records the entry.
+func (l *AccessLogger) Record(ctx context.Context, entry *AccessLogEntry, opts ...RecordOption) error {
+	if l.recorder == nil || entry == nil {
+		return nil
+	}
+	if entry.TraceID == "" {
+		entry.TraceID = ctxvalue.TraceIDFromContext(ctx)
+	}
+	return l.recorder.Record(ctx, l.Format(entry), opts...)
+}
+
+// Format renders the entry in the configured format and fields, the line is terminated by a newline.
+func (l *AccessLogger) Format(entry *AccessLogEntry) []byte {
+	var buf bytes.Buffer
+
+	if l.options.Format == AccessLogFormatJSON {
+		buf.WriteByte('{')
+		for i, field := range l.options.Fields {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			k, _ := json.Marshal(string(field))
+			buf.Write(k)
+			buf.WriteByte(':')
+			buf.Write(jsonValue(entry, field))
+		}
+		buf.WriteString("}\n")
+		return buf.Bytes()
+	}
+
+	for i, field := range l.options.Fields {
+		if i > 0 {
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(combinedValue(entry, field))
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes()
+}
+
+func combinedValue(entry *AccessLogEntry, field AccessLogField) string {
+	orDash := func(s string) string {
+		if s == "" {
+			return "-"
+		}
+		return s
+	}
+	// the unquoted fields may come from the clients, e.g. the user of the basic auth.
+	escape := func(s string) string {
+		return escapeCombined(orDash(s))
+	}
+
+	switch field {
+	case AccessLogFieldTime:
+		return "[" + entry.Time.Format(accessLogTimeFormat) + "]"
+	case AccessLogFieldClientIP:
+		return escape(entry.ClientIP)
+	case AccessLogFieldUser:
+		return escape(entry.User)
+	case AccessLogFieldMethod:
+		return strconv.Quote(orDash(entry.Method))
+	case AccessLogFieldHost:
+		return escape(entry.Host)
+	case AccessLogFieldNode:
+		return escape(entry.Node)
+	case AccessLogFieldStatus:
+		return strconv.Itoa(entry.Status)
+	case AccessLogFieldBytes:
+		return strconv.FormatInt(entry.Bytes, 10)
+	case AccessLogFieldDuration:
+		return strconv.FormatInt(entry.Duration.Milliseconds(), 10) + "ms"
+	case AccessLogFieldTraceID:
+		return escape(entry.TraceID)
+	default:
+		return "-"
+	}
+}
+
+// escapeCombined escapes the spaces, control characters, quotes and backslashes of s as \xHH,
+// so a value never splits into more fields or lines.
+func escapeCombined(s string) string {
+	i := 0
+	for i < len(s) && !needsEscape(s[i]) {
+		i++
+	}
+	if i == len(s) {
+		return s
+	}
+
+	const hex = "0123456789ABCDEF"
+	b := make([]byte, 0, len(s)+8)
+	b = append(b, s[:i]...)
+	for ; i < len(s); i++ {
+		c := s[i]
+		if needsEscape(c) {
+			b = append(b, '\\', 'x', hex[c>>4], hex[c&0xf])
+		} else {
+			b = append(b, c)
+		}
+	}
+	return string(b)
+}
+
+func needsEscape(c byte) bool {
+	return c <= ' ' || c == 0x7f || c == '"' || c == '\\'
+}
+
+func jsonValue(entry *AccessLogEntry, field AccessLogField) []byte {
+	var v any
+	switch field {
+	case AccessLogFieldTime:
+		v = entry.Time.Format(time.RFC3339Nano)
+	case AccessLogFieldClientIP:
+		v = entry.ClientIP
+	case AccessLogFieldUser:
+		v = entry.User
+	case AccessLogFieldMethod:
+		v = entry.Method
+	case AccessLogFieldHost:
+		v = entry.Host
+	case AccessLogFieldNode:
+		v = entry.Node
+	case AccessLogFieldStatus:
+		v = entry.Status
+	case AccessLogFieldBytes:
+		v = entry.Bytes
+	case AccessLogFieldDuration:
+		v = entry.Duration.Milliseconds()
+	case AccessLogFieldTraceID:
+		v = entry.TraceID
+	}
+	b, _ := json.Marshal(v)
+	return b
+}
@@ -0,0 +1,124 @@
+package recorder
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-gost/core/common/ctxvalue"
+)
+
+// bufRecorder keeps the records.
+type bufRecorder struct {
+	mu      sync.Mutex
+	records []string
+}
+
+func (r *bufRecorder) Record(ctx context.Context, b []byte, opts ...RecordOption) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.records = append(r.records, string(b))
+	return nil
+}
+
+func sampleEntry() *AccessLogEntry {
+	return &AccessLogEntry{
+		Time:     time.Date(2024, 3, 1, 8, 30, 15, 0, time.FixedZone("", 8*3600)),
+		ClientIP: "192.0.2.10",
+		User:     "alice",
+		Method:   "CONNECT",
+		Host:     "example.com:443",
+		Node:     "node-1",
+		Status:   200,
+		Bytes:    1024,
+		Duration: 1500 * time.Millisecond,
+	}
+}
+
+func TestAccessLoggerCombined(t *testing.T) {
+	r := &bufRecorder{}
+	l := NewAccessLogger(r)
+
+	if err := l.Record(context.Background(), sampleEntry()); err != nil {
+		t.Fatal(err)
+	}
+	want := `192.0.2.10 alice [01/Mar/2024:08:30:15 +0800] "CONNECT" example.com:443 200 1024 1500ms node-1` + "\n"
+	if len(r.records) != 1 || r.records[0] != want {
+		t.Fatalf("unexpected record %q, want %q", r.records, want)
+	}
+
+	// the empty fields are rendered as dashes.
+	line := string(l.Format(&AccessLogEntry{Time: sampleEntry().Time, Status: 502}))
+	want = `- - [01/Mar/2024:08:30:15 +0800] "-" - 502 0 0ms -` + "\n"
+	if line != want {
+		t.Fatalf("unexpected line %q, want %q", line, want)
+	}
+}
+
+func TestAccessLoggerJSON(t *testing.T) {
+	l := NewAccessLogger(&bufRecorder{},
+		FormatAccessLogOption(AccessLogFormatJSON),
+		FieldsAccessLogOption(AccessLogFieldHost, AccessLogFieldStatus, AccessLogFieldTime, AccessLogFieldDuration, AccessLogFieldUser))
+
+	line := string(l.Format(sampleEntry()))
+	want := `{"host":"example.com:443","status":200,"time":"2024-03-01T08:30:15+08:00","duration":1500,"user":"alice"}` + "\n"
+	if line != want {
+		t.Fatalf("unexpected line %q, want %q", line, want)
+	}
+}
+
+func TestAccessLoggerFields(t *testing.T) {
+	l := NewAccessLogger(&bufRecorder{}, FieldsAccessLogOption(AccessLogFieldStatus, AccessLogFieldNode, AccessLogFieldClientIP))
+	if line := string(l.Format(sampleEntry())); line != "200 node-1 192.0.2.10\n" {
+		t.Fatalf("unexpected line %q", line)
+	}
+}
+
+func TestAccessLoggerLog(t *testing.T) {
+	r := &bufRecorder{}
+	l := NewAccessLogger(r, FieldsAccessLogOption(AccessLogFieldTraceID, AccessLogFieldHost))
+
+	// no entry in the context.
+	if err := l.Log(context.Background()); err != nil || len(r.records) != 0 {
+		t.Fatalf("expected no record, got %v %v", r.records, err)
+	}
+
+	// the entry is filled in through the context by the handler.
+	entry := &AccessLogEntry{}
+	ctx := ctxvalue.ContextWithTraceID(ContextWithAccessLog(context.Background(), entry), "trace-1")
+	AccessLogFromContext(ctx).Host = "example.com:80"
+
+	if err := l.Log(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.records) != 1 || r.records[0] != "trace-1 example.com:80\n" {
+		t.Fatalf("unexpected records %q", r.records)
+	}
+}
+
+func TestAccessLoggerCombinedEscape(t *testing.T) {
+	l := NewAccessLogger(&bufRecorder{})
+	entry := sampleEntry()
+	// the hostile values from the client never forge the fields or lines.
+	entry.User = "alice 200 1024\n192.0.2.66 - forged"
+	entry.Host = "example.com:443\r\n\"x\""
+	entry.Node = "node\\1\t"
+
+	line := string(l.Format(entry))
+	want := `192.0.2.10 alice\x20200\x201024\x0A192.0.2.66\x20-\x20forged [01/Mar/2024:08:30:15 +0800] "CONNECT" ` +
+		`example.com:443\x0D\x0A\x22x\x22 200 1024 1500ms node\x5C1\x09` + "\n"
+	if line != want {
+		t.Fatalf("unexpected line %q, want %q", line, want)
+	}
+	if n, want := len(strings.Fields(line)), len(strings.Fields(string(l.Format(sampleEntry())))); n != want {
+		t.Fatalf("expected %d fields, got %d", want, n)
+	}
+
+	// the printable values are kept as is.
+	entry.User = "ålice@example.com"
+	if line := string(NewAccessLogger(&bufRecorder{}, FieldsAccessLogOption(AccessLogFieldUser)).Format(entry)); line != "ålice@example.com\n" {
+		t.Fatalf("unexpected line %q", line)
+	}
+}
@@ -0,0 +1,348 @@
+package recorder
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-gost/core/logger"
+)
+
+const (
+	defaultWALRetryInterval = time.Second
+	// defaultWALCompactSize is the size of the fully acknowledged log to be truncated.
+	defaultWALCompactSize = 16 * 1024 * 1024
+	// walHeaderSize is the frame header: the payload length (4) and the CRC32 of the payload (4).
+	walHeaderSize    = 8
+	maxWALRecordSize = 64 * 1024 * 1024
+)
+
+var (
+	ErrWALClosed         = errors.New("recorder: wal is closed")
+	ErrWALRecordTooLarge = errors.New("recorder: wal record is too large")
+	ErrWALCorrupt        = errors.New("recorder: wal record is corrupt")
+)
+
+type WALOptions struct {
+	// RetryInterval is the delay before re-delivering a record the sink failed to record.
+	RetryInterval time.Duration
+	// CompactSize is the size of the log to be truncated once all its records are acknowledged.
+	CompactSize int64
+	// NoSync skips the fsync of each record, trading the durability on a power loss for the throughput.
+	NoSync bool
+	Logger logger.Logger
+}
+
+type WALOption func(opts *WALOptions)
+
+func RetryIntervalWALOption(d time.Duration) WALOption {
+	return func(opts *WALOptions) {
+		opts.RetryInterval = d
+	}
+}
+
+func CompactSizeWALOption(size int64) WALOption {
+	return func(opts *WALOptions) {
+		opts.CompactSize = size
+	}
+}
+
+func NoSyncWALOption() WALOption {
+	return func(opts *WALOptions) {
+		opts.NoSync = true
+	}
+}
+
+func LoggerWALOption(logger logger.Logger) WALOption {
+	return func(opts *WALOptions) {
+		opts.Logger = logger
+	}
+}
+
+// WALRecorder is a Recorder buffering the records in a local append-only log and shipping them to the sink in order,
+// a record is acknowledged only after the sink records it without error, then the next one is shipped.
+// The unacknowledged records are replayed after a restart, which gives the at-least-once delivery.
+// The acknowledged offset is kept in the file with the ".ack" suffix next to the log.
+// The record options are not persisted, so the records are shipped to the sink without options.
+type WALRecorder struct {
+	sink    Recorder
+	options WALOptions
+	file    *os.File
+	ackPath string
+	// ack is the offset of the first unacknowledged record, end is the offset of the log end.
+	ack    int64
+	end    int64
+	notify chan struct{}
+	done   chan struct{}
+	closed bool
+	wg     sync.WaitGroup
+	mu     sync.Mutex
+}
+
+// NewWALRecorder opens or creates the log at path and starts shipping the unacknowledged records to sink.
+// A corrupt or partial record at the tail of the log (e.g. from a crash during the write) is truncated.
+func NewWALRecorder(path string, sink Recorder, opts ...WALOption) (*WALRecorder, error) {
+	options := WALOptions{
+		RetryInterval: defaultWALRetryInterval,
+		CompactSize:   defaultWALCompactSize,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &WALRecorder{
+		sink:    sink,
+		options: options,
+		file:    f,
+		ackPath: path + ".ack",
+		notify:  make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	if err := w.recover(); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	w.wg.Add(1)
+	go w.ship()
+	return w, nil
+}
+
+// recover loads the acknowledged offset and truncates the log after the last valid record.
+func (w *WALRecorder) recover() error {
+	b, err := os.ReadFile(w.ackPath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if len(b) > 0 {
+		w.ack, _ = strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+	}
+
+	info, err := w.file.Stat()
+	if err != nil {
+		return err
+	}
+	size := info.Size()
+	// the log is truncated before the offset is reset on compaction, so the offset can exceed the size after a crash.
+	w.ack = min(max(w.ack, 0), size)
+
+	offset := w.ack
+	for offset < size {
+		_, n, err := w.readAt(offset)
+		if err != nil {
+			w.warnf("wal: truncate the corrupt tail at %d of %d bytes: %v", offset, size, err)
+			break
+		}
+		offset += n
+	}
+	if offset < size {
+		if err := w.file.Truncate(offset); err != nil {
+			return err
+		}
+	}
+	w.end = offset
+	return nil
+}
+
+// Record appends b to the log, it returns after the record is durable, not after it is shipped.
+func (w *WALRecorder) Record(ctx context.Context, b []byte, opts ...RecordOption) error {
+	if len(b) > maxWALRecordSize {
+		return ErrWALRecordTooLarge
+	}
+
+	frame := make([]byte, walHeaderSize+len(b))
+	binary.BigEndian.PutUint32(frame[0:4], uint32(len(b)))
+	binary.BigEndian.PutUint32(frame[4:8], crc32.ChecksumIEEE(b))
+	copy(frame[walHeaderSize:], b)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return ErrWALClosed
+	}
+	if _, err := w.file.WriteAt(frame, w.end); err != nil {
+		return err
+	}
+	if !w.options.NoSync {
+		if err := w.file.Sync(); err != nil {
+			return err
+		}
+	}
+	w.end += int64(len(frame))
+
+	select {
+	case w.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Pending returns the number of bytes of the unacknowledged records.
+func (w *WALRecorder) Pending() int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.end - w.ack
+}
+
+// Close stops shipping, the unacknowledged records are kept in the log for the next start.
+func (w *WALRecorder) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	close(w.done)
+	w.mu.Unlock()
+
+	w.wg.Wait()
+	return w.file.Close()
+}
+
+func (w *WALRecorder) ship() {
+	defer w.wg.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-w.done
+		cancel()
+	}()
+
+	for {
+		w.mu.Lock()
+		offset, end := w.ack, w.end
+		w.mu.Unlock()
+
+		if offset >= end {
+			select {
+			case <-w.notify:
+				continue
+			case <-w.done:
+				return
+			}
+		}
+
+		b, n, err := w.readAt(offset)
+		if err != nil {
+			// the record is verified on recovery or written by Record, it is unexpected.
+			w.warnf("wal: read the record at %d: %v", offset, err)
+			if !w.wait() {
+				return
+			}
+			continue
+		}
+
+		if err := w.sink.Record(ctx, b); err != nil {
+			w.warnf("wal: ship the record at %d: %v", offset, err)
+			if !w.wait() {
+				return
+			}
+			continue
+		}
+
+		if err := w.acknowledge(offset + n); err != nil {
+			w.warnf("wal: acknowledge the record at %d: %v", offset, err)
+		}
+	}
+}
+
+func (w *WALRecorder) warnf(format string, args ...any) {
+	if w.options.Logger != nil {
+		w.options.Logger.Warnf(format, args...)
+	}
+}
+
+// wait waits the retry interval, it returns false if the recorder is closed.
+func (w *WALRecorder) wait() bool {
+	t := time.NewTimer(w.options.RetryInterval)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return true
+	case <-w.done:
+		return false
+	}
+}
+
+// acknowledge persists the acknowledged offset, and truncates the log if it is fully acknowledged and large.
+func (w *WALRecorder) acknowledge(offset int64) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.ack = offset
+	if w.ack == w.end && w.end >= w.options.CompactSize {
+		// truncate before resetting the offset, a crash in between leaves the offset beyond the end, which is clamped on recovery.
+		if err := w.file.Truncate(0); err != nil {
+			return err
+		}
+		w.ack, w.end = 0, 0
+	}
+	return w.saveAck()
+}
+
+// saveAck writes the acknowledged offset atomically, it is called with the lock held.
+func (w *WALRecorder) saveAck() error {
+	tmp, err := os.CreateTemp(filepath.Dir(w.ackPath), filepath.Base(w.ackPath)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(strconv.FormatInt(w.ack, 10)); err != nil {
+		tmp.Close()
+		return err
+	}
+	if !w.options.NoSync {
+		if err := tmp.Sync(); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), w.ackPath)
+}
+
+// readAt reads the record at offset, n is the size of the frame.
+func (w *WALRecorder) readAt(offset int64) (b []byte, n int64, err error) {
+	var hdr [walHeaderSize]byte
+	if _, err := w.file.ReadAt(hdr[:], offset); err != nil {
+		return nil, 0, err
+	}
+	size := binary.BigEndian.Uint32(hdr[0:4])
+	if size > maxWALRecordSize {
+		return nil, 0, ErrWALRecordTooLarge
+	}
+
+	b = make([]byte, size)
+	if _, err := w.file.ReadAt(b, offset+walHeaderSize); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, 0, err
+	}
+	if crc32.ChecksumIEEE(b) != binary.BigEndian.Uint32(hdr[4:8]) {
+		return nil, 0, ErrWALCorrupt
+	}
+	return b, int64(walHeaderSize + size), nil
+}
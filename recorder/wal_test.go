@@ -0,0 +1,235 @@
+package recorder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var errSinkDown = errors.New("sink is down")
+
+// flakySink accepts up to limit records (unlimited if negative), then fails.
+type flakySink struct {
+	mu      sync.Mutex
+	limit   int
+	records []string
+}
+
+func (s *flakySink) Record(ctx context.Context, b []byte, opts ...RecordOption) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.limit >= 0 && len(s.records) >= s.limit {
+		return errSinkDown
+	}
+	s.records = append(s.records, string(b))
+	return nil
+}
+
+func (s *flakySink) get() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.records...)
+}
+
+// waitRecords waits until the sink has n records.
+func waitRecords(t *testing.T, s *flakySink, n int) []string {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		records := s.get()
+		if len(records) >= n {
+			return records
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d records, got %v", n, records)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// waitPending waits until the pending bytes of w drop to 0.
+func waitPending(t *testing.T, w *WALRecorder) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for w.Pending() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d bytes are still pending", w.Pending())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func openWAL(t *testing.T, path string, sink Recorder, opts ...WALOption) *WALRecorder {
+	t.Helper()
+	opts = append([]WALOption{RetryIntervalWALOption(10 * time.Millisecond)}, opts...)
+	w, err := NewWALRecorder(path, sink, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w
+}
+
+func recordN(t *testing.T, w *WALRecorder, from, to int) {
+	t.Helper()
+	for i := from; i <= to; i++ {
+		if err := w.Record(context.Background(), []byte(fmt.Sprintf("record-%d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestWALRecorderReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.wal")
+
+	// the sink acknowledges 3 records, then the process crashes.
+	sink := &flakySink{limit: 3}
+	w := openWAL(t, path, sink)
+	recordN(t, w, 1, 5)
+	waitRecords(t, sink, 3)
+	time.Sleep(30 * time.Millisecond)
+	if w.Pending() == 0 {
+		t.Fatal("the unacknowledged records should be pending")
+	}
+	w.Close()
+
+	// the unacknowledged records are re-delivered after the restart, the acknowledged ones are not duplicated.
+	sink = &flakySink{limit: -1}
+	w = openWAL(t, path, sink)
+	defer w.Close()
+	recordN(t, w, 6, 6)
+	waitRecords(t, sink, 3)
+	waitPending(t, w)
+	if got := strings.Join(sink.get(), " "); got != "record-4 record-5 record-6" {
+		t.Fatalf("unexpected replayed records %q", got)
+	}
+}
+
+func TestWALRecorderRetry(t *testing.T) {
+	sink := &flakySink{limit: 0}
+	w := openWAL(t, filepath.Join(t.TempDir(), "audit.wal"), sink)
+	defer w.Close()
+
+	recordN(t, w, 1, 2)
+	time.Sleep(50 * time.Millisecond)
+
+	// the sink recovers, the records are shipped in order.
+	sink.mu.Lock()
+	sink.limit = -1
+	sink.mu.Unlock()
+	waitPending(t, w)
+	if got := strings.Join(sink.get(), " "); got != "record-1 record-2" {
+		t.Fatalf("unexpected records %q", got)
+	}
+}
+
+func TestWALRecorderCorruptTail(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		tail func(path string) error
+	}{
+		{"partial header", func(path string) error {
+			return appendFile(path, []byte{0, 0})
+		}},
+		{"partial payload", func(path string) error {
+			return appendFile(path, []byte{0, 0, 0, 100, 1, 2, 3, 4, 'x'})
+		}},
+		{"bad checksum", func(path string) error {
+			return appendFile(path, []byte{0, 0, 0, 1, 1, 2, 3, 4, 'x'})
+		}},
+	} {
+		path := filepath.Join(t.TempDir(), "audit.wal")
+		w := openWAL(t, path, &flakySink{limit: 0})
+		recordN(t, w, 1, 2)
+		w.Close()
+		info, _ := os.Stat(path)
+
+		// a crash in the middle of a write leaves a corrupt tail.
+		if err := tc.tail(path); err != nil {
+			t.Fatal(err)
+		}
+
+		sink := &flakySink{limit: -1}
+		w = openWAL(t, path, sink)
+		if size := fileSize(t, path); size != info.Size() {
+			t.Errorf("%s: the corrupt tail is not truncated, %d bytes, want %d", tc.name, size, info.Size())
+		}
+		// the records after the truncation are kept.
+		recordN(t, w, 3, 3)
+		waitPending(t, w)
+		if got := strings.Join(sink.get(), " "); got != "record-1 record-2 record-3" {
+			t.Errorf("%s: unexpected records %q", tc.name, got)
+		}
+		w.Close()
+	}
+}
+
+func TestWALRecorderCompact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.wal")
+	sink := &flakySink{limit: -1}
+	w := openWAL(t, path, sink, CompactSizeWALOption(1), NoSyncWALOption())
+
+	recordN(t, w, 1, 3)
+	waitRecords(t, sink, 3)
+	waitPending(t, w)
+	w.Close()
+
+	// the fully acknowledged log is truncated.
+	if size := fileSize(t, path); size != 0 {
+		t.Fatalf("expected the log to be truncated, got %d bytes", size)
+	}
+	if b, err := os.ReadFile(path + ".ack"); err != nil || string(b) != "0" {
+		t.Fatalf("unexpected acknowledged offset %q %v", b, err)
+	}
+
+	// nothing is replayed, even if the crash happened before the offset is reset.
+	if err := os.WriteFile(path+".ack", []byte("1000"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	sink = &flakySink{limit: -1}
+	w = openWAL(t, path, sink)
+	defer w.Close()
+	recordN(t, w, 4, 4)
+	waitPending(t, w)
+	if got := strings.Join(sink.get(), " "); got != "record-4" {
+		t.Fatalf("unexpected records %q", got)
+	}
+}
+
+func TestWALRecorderClosed(t *testing.T) {
+	w := openWAL(t, filepath.Join(t.TempDir(), "audit.wal"), &flakySink{limit: -1})
+	if err := w.Record(context.Background(), make([]byte, maxWALRecordSize+1)); err != ErrWALRecordTooLarge {
+		t.Fatalf("expected ErrWALRecordTooLarge, got %v", err)
+	}
+	w.Close()
+	if err := w.Record(context.Background(), []byte("late")); err != ErrWALClosed {
+		t.Fatalf("expected ErrWALClosed, got %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func appendFile(path string, b []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(b)
+	return err
+}
+
+func fileSize(t *testing.T, path string) int64 {
+	t.Helper()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info.Size()
+}
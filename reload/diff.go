@@ -0,0 +1,55 @@
+package reload
+
+import (
+	"cmp"
+	"slices"
+)
+
+// Change is a changed entry in a Diff.
+type Change[K comparable, V any] struct {
+	Key K
+	Old V
+	New V
+}
+
+// Changes is the result of Diff, the entries are sorted by key if K is ordered.
+type Changes[K comparable, V any] struct {
+	Added   []Change[K, V]
+	Removed []Change[K, V]
+	Changed []Change[K, V]
+}
+
+// IsEmpty reports whether nothing is changed.
+func (c *Changes[K, V]) IsEmpty() bool {
+	return len(c.Added) == 0 && len(c.Removed) == 0 && len(c.Changed) == 0
+}
+
+// Diff reports the entries added, removed and changed from old to new,
+// the values of the same key are compared by equal.
+func Diff[K cmp.Ordered, V any](old, new map[K]V, equal func(a, b V) bool) Changes[K, V] {
+	var c Changes[K, V]
+	for k, nv := range new {
+		ov, ok := old[k]
+		if !ok {
+			c.Added = append(c.Added, Change[K, V]{Key: k, New: nv})
+			continue
+		}
+		if equal != nil && !equal(ov, nv) {
+			c.Changed = append(c.Changed, Change[K, V]{Key: k, Old: ov, New: nv})
+		}
+	}
+	for k, ov := range old {
+		if _, ok := new[k]; !ok {
+			c.Removed = append(c.Removed, Change[K, V]{Key: k, Old: ov})
+		}
+	}
+
+	byKey := func(a, b Change[K, V]) int {
+		return cmp.Compare(a.Key, b.Key)
+	}
+	slices.SortFunc(c.Added, byKey)
+	slices.SortFunc(c.Removed, byKey)
+	slices.SortFunc(c.Changed, byKey)
+
+	return c
+}
@@ -0,0 +1,38 @@
+package reload
+
+import (
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	old := map[string]int{"a": 1, "b": 2, "c": 3}
+	new := map[string]int{"b": 2, "c": 30, "e": 5, "d": 4}
+
+	c := Diff(old, new, func(a, b int) bool { return a == b })
+	if c.IsEmpty() {
+		t.Fatal("expected changes")
+	}
+	if len(c.Added) != 2 || c.Added[0] != (Change[string, int]{Key: "d", New: 4}) || c.Added[1] != (Change[string, int]{Key: "e", New: 5}) {
+		t.Fatalf("unexpected additions %v", c.Added)
+	}
+	if len(c.Removed) != 1 || c.Removed[0] != (Change[string, int]{Key: "a", Old: 1}) {
+		t.Fatalf("unexpected removals %v", c.Removed)
+	}
+	if len(c.Changed) != 1 || c.Changed[0] != (Change[string, int]{Key: "c", Old: 3, New: 30}) {
+		t.Fatalf("unexpected changes %v", c.Changed)
+	}
+}
+
+func TestDiffNoop(t *testing.T) {
+	m := map[string]int{"a": 1, "b": 2}
+	if c := Diff(m, map[string]int{"b": 2, "a": 1}, func(a, b int) bool { return a == b }); !c.IsEmpty() {
+		t.Fatalf("expected empty diff, got %+v", c)
+	}
+	if c := Diff[string, int](nil, nil, nil); !c.IsEmpty() {
+		t.Fatalf("expected empty diff, got %+v", c)
+	}
+	// the values are not compared without equal.
+	if c := Diff(m, map[string]int{"a": 10, "b": 20}, nil); !c.IsEmpty() {
+		t.Fatalf("expected empty diff without equal, got %+v", c)
+	}
+}
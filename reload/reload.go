@@ -0,0 +1,151 @@
+package reload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	ErrNotPreparable = errors.New("reload: component does not support prepare")
+)
+
+// Reloadable is a component which can reload its config, e.g. bypass, hosts, resolver and node set.
+// Reload should apply the new config atomically, a failed reload keeps the previous config.
+type Reloadable interface {
+	Reload(ctx context.Context) error
+}
+
+// Preparable is a Reloadable which supports two-phase reloading.
+// Prepare loads and validates the new config without applying it,
+// the returned commit function applies the prepared config.
+type Preparable interface {
+	Reloadable
+	Prepare(ctx context.Context) (commit func(), err error)
+}
+
+type Options struct {
+	// AllOrNothing applies the new configs only if all the components are prepared successfully,
+	// all the registered components must implement Preparable interface in this mode.
+	AllOrNothing bool
+}
+
+type Option func(opts *Options)
+
+func AllOrNothingOption(b bool) Option {
+	return func(opts *Options) {
+		opts.AllOrNothing = b
+	}
+}
+
+// Coordinator fans out one reload event to all the registered components.
+type Coordinator interface {
+	Register(name string, r Reloadable)
+	Unregister(name string)
+	Reload(ctx context.Context) error
+}
+
+type entry struct {
+	name string
+	r    Reloadable
+}
+
+type coordinator struct {
+	entries []entry
+	mu      sync.Mutex
+	options Options
+}
+
+func NewCoordinator(opts ...Option) Coordinator {
+	var options Options
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+
+	return &coordinator{
+		options: options,
+	}
+}
+
+// Register adds or replaces the component r with name, the components are reloaded in registration order.
+func (c *coordinator) Register(name string, r Reloadable) {
+	if r == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i := range c.entries {
+		if c.entries[i].name == name {
+			c.entries[i].r = r
+			return
+		}
+	}
+	c.entries = append(c.entries, entry{name: name, r: r})
+}
+
+func (c *coordinator) Unregister(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i := range c.entries {
+		if c.entries[i].name == name {
+			c.entries = append(c.entries[:i], c.entries[i+1:]...)
+			return
+		}
+	}
+}
+
+// Reload reloads all the components.
+// In the default mode, every component is reloaded independently and the errors are joined.
+// In all-or-nothing mode, no component is changed if any of them fails to prepare.
+func (c *coordinator) Reload(ctx context.Context) error {
+	c.mu.Lock()
+	entries := make([]entry, len(c.entries))
+	copy(entries, c.entries)
+	c.mu.Unlock()
+
+	if c.options.AllOrNothing {
+		return c.reloadAll(ctx, entries)
+	}
+
+	var errs []error
+	for _, e := range entries {
+		if err := e.r.Reload(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", e.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (c *coordinator) reloadAll(ctx context.Context, entries []entry) error {
+	var errs []error
+	var commits []func()
+	for _, e := range entries {
+		p, ok := e.r.(Preparable)
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: %w", e.name, ErrNotPreparable))
+			continue
+		}
+		commit, err := p.Prepare(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", e.name, err))
+			continue
+		}
+		if commit != nil {
+			commits = append(commits, commit)
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	for _, commit := range commits {
+		commit()
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package reload
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// fakeReloadable holds a config version, the reload moves it to next unless err is set.
+type fakeReloadable struct {
+	version int
+	next    int
+	err     error
+	reloads int
+}
+
+func (r *fakeReloadable) Reload(ctx context.Context) error {
+	r.reloads++
+	if r.err != nil {
+		return r.err
+	}
+	r.version = r.next
+	return nil
+}
+
+func (r *fakeReloadable) Prepare(ctx context.Context) (func(), error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	next := r.next
+	return func() { r.version = next }, nil
+}
+
+// plainReloadable does not support the two-phase reloading.
+type plainReloadable struct {
+	reloads int
+}
+
+func (r *plainReloadable) Reload(ctx context.Context) error {
+	r.reloads++
+	return nil
+}
+
+func TestCoordinatorPartialFailure(t *testing.T) {
+	errBad := errors.New("bad config")
+	bypass := &fakeReloadable{version: 1, next: 2}
+	hosts := &fakeReloadable{version: 1, next: 2, err: errBad}
+	resolver := &fakeReloadable{version: 1, next: 2}
+
+	c := NewCoordinator()
+	c.Register("bypass", bypass)
+	c.Register("hosts", hosts)
+	c.Register("resolver", resolver)
+
+	err := c.Reload(context.Background())
+	if !errors.Is(err, errBad) {
+		t.Fatalf("expected the hosts error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "hosts") {
+		t.Fatalf("error should name the component: %v", err)
+	}
+	// the failure of hosts does not abort the others.
+	if bypass.version != 2 || resolver.version != 2 {
+		t.Fatalf("healthy components should be reloaded: bypass=%d resolver=%d", bypass.version, resolver.version)
+	}
+	if hosts.version != 1 {
+		t.Fatalf("failed component should keep the previous config, got %d", hosts.version)
+	}
+}
+
+func TestCoordinatorAllOrNothing(t *testing.T) {
+	errBad := errors.New("bad config")
+	bypass := &fakeReloadable{version: 1, next: 2}
+	hosts := &fakeReloadable{version: 1, next: 2, err: errBad}
+
+	c := NewCoordinator(AllOrNothingOption(true))
+	c.Register("bypass", bypass)
+	c.Register("hosts", hosts)
+
+	if err := c.Reload(context.Background()); !errors.Is(err, errBad) {
+		t.Fatalf("expected the hosts error, got %v", err)
+	}
+	if bypass.version != 1 || hosts.version != 1 {
+		t.Fatalf("no component should be changed: bypass=%d hosts=%d", bypass.version, hosts.version)
+	}
+
+	hosts.err = nil
+	if err := c.Reload(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if bypass.version != 2 || hosts.version != 2 {
+		t.Fatalf("all components should be changed: bypass=%d hosts=%d", bypass.version, hosts.version)
+	}
+}
+
+func TestCoordinatorAllOrNothingNotPreparable(t *testing.T) {
+	bypass := &fakeReloadable{version: 1, next: 2}
+	plain := &plainReloadable{}
+
+	c := NewCoordinator(AllOrNothingOption(true))
+	c.Register("bypass", bypass)
+	c.Register("plain", plain)
+
+	if err := c.Reload(context.Background()); !errors.Is(err, ErrNotPreparable) {
+		t.Fatalf("expected ErrNotPreparable, got %v", err)
+	}
+	if bypass.version != 1 || plain.reloads != 0 {
+		t.Fatal("no component should be changed")
+	}
+}
+
+func TestCoordinatorRegister(t *testing.T) {
+	a := &fakeReloadable{next: 1}
+	b := &fakeReloadable{next: 1}
+
+	c := NewCoordinator()
+	c.Register("a", a)
+	// replaces a.
+	c.Register("a", b)
+	c.Register("nil", nil)
+
+	if err := c.Reload(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if a.reloads != 0 || b.reloads != 1 {
+		t.Fatalf("unexpected reloads a=%d b=%d", a.reloads, b.reloads)
+	}
+
+	c.Unregister("a")
+	if err := c.Reload(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if b.reloads != 1 {
+		t.Fatalf("unregistered component is reloaded")
+	}
+}
@@ -0,0 +1,241 @@
+package resolver
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/go-gost/core/common/clock"
+	"github.com/go-gost/core/metrics"
+)
+
+const (
+	defaultCacheTTL = 60 * time.Second
+	// defaultStaleRefresh is the interval to serve the stale answers without waiting for the upstream
+	// after a failed refresh, as recommended by RFC 8767.
+	defaultStaleRefresh = 30 * time.Second
+	staleRefreshTimeout = 5 * time.Second
+)
+
+type CacheOptions struct {
+	// TTL is the duration the answers are fresh for.
+	TTL time.Duration
+	// StaleTTL is the maximum duration after expiry the answers are served while the upstream is failing (RFC 8767),
+	// 0 disables serving stale.
+	StaleTTL time.Duration
+	// StaleRefresh is the interval after a failed refresh, during which the stale answers are served immediately
+	// and the upstream is retried in the background.
+	StaleRefresh time.Duration
+	// CachedFirst enables the cached-first mode: the expired answers up to CachedFirst after expiry are served immediately
+	// and refreshed in the background, only the cache misses wait for the upstream. 0 disables the mode.
+	CachedFirst time.Duration
+	Clock       clock.Clock
+	// Metrics receives the cache hits, misses and stale answers, labeled by Upstream.
+	Metrics  metrics.Metrics
+	Upstream string
+}
+
+type CacheOption func(opts *CacheOptions)
+
+func TTLCacheOption(ttl time.Duration) CacheOption {
+	return func(opts *CacheOptions) {
+		opts.TTL = ttl
+	}
+}
+
+func StaleTTLCacheOption(ttl time.Duration) CacheOption {
+	return func(opts *CacheOptions) {
+		opts.StaleTTL = ttl
+	}
+}
+
+func StaleRefreshCacheOption(d time.Duration) CacheOption {
+	return func(opts *CacheOptions) {
+		opts.StaleRefresh = d
+	}
+}
+
+func CachedFirstCacheOption(maxStale time.Duration) CacheOption {
+	return func(opts *CacheOptions) {
+		opts.CachedFirst = maxStale
+	}
+}
+
+func ClockCacheOption(c clock.Clock) CacheOption {
+	return func(opts *CacheOptions) {
+		opts.Clock = c
+	}
+}
+
+// MetricsCacheOption reports the cache lookups to m for the hit/miss ratio of the upstream,
+// see ObserveCache and ObserveCacheStale.
+func MetricsCacheOption(m metrics.Metrics, upstream string) CacheOption {
+	return func(opts *CacheOptions) {
+		opts.Metrics = m
+		opts.Upstream = upstream
+	}
+}
+
+type cacheEntry struct {
+	ips     []net.IP
+	expires time.Time
+	// retryAt is the time until which the stale answers are served without querying the upstream.
+	retryAt    time.Time
+	refreshing bool
+}
+
+type cacheResolver struct {
+	resolver Resolver
+	options  CacheOptions
+	entries  map[string]*cacheEntry
+	swept    time.Time
+	mu       sync.Mutex
+}
+
+// CacheResolver wraps the resolver r and caches the answers for the TTL.
+// With the StaleTTL set, the expired answers are still served when the upstream fails,
+// up to StaleTTL after expiry, while the refresh is retried in the background.
+// With the CachedFirst set, the slightly stale answers are served even if the upstream is healthy but slow,
+// trading the freshness for the latency.
+func CacheResolver(r Resolver, opts ...CacheOption) Resolver {
+	options := CacheOptions{
+		TTL:          defaultCacheTTL,
+		StaleRefresh: defaultStaleRefresh,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+	options.Clock = clock.OrDefault(options.Clock)
+
+	return &cacheResolver{
+		resolver: r,
+		options:  options,
+		entries:  make(map[string]*cacheEntry),
+	}
+}
+
+func (r *cacheResolver) Resolve(ctx context.Context, network, host string, opts ...Option) ([]net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}, nil
+	}
+
+	key := network + "/" + host
+	now := r.options.Clock.Now()
+
+	r.mu.Lock()
+	entry := r.entries[key]
+	if entry != nil {
+		if now.Before(entry.expires) {
+			ips := entry.ips
+			r.mu.Unlock()
+			ObserveCache(r.options.Metrics, r.options.Upstream, true)
+			return copyIPs(ips), nil
+		}
+		if (r.options.CachedFirst > 0 && now.Before(entry.expires.Add(r.options.CachedFirst))) ||
+			(r.isStale(entry, now) && now.Before(entry.retryAt)) {
+			ips := entry.ips
+			if !entry.refreshing {
+				entry.refreshing = true
+				go r.refresh(key, network, host, opts...)
+			}
+			r.mu.Unlock()
+			ObserveCache(r.options.Metrics, r.options.Upstream, true)
+			ObserveCacheStale(r.options.Metrics, r.options.Upstream)
+			return copyIPs(ips), nil
+		}
+	}
+	r.mu.Unlock()
+	ObserveCache(r.options.Metrics, r.options.Upstream, false)
+
+	ips, err := r.resolver.Resolve(ctx, network, host, opts...)
+	if err == nil && len(ips) > 0 {
+		r.store(key, ips)
+		return copyIPs(ips), nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if entry := r.entries[key]; entry != nil {
+		if r.isStale(entry, r.options.Clock.Now()) {
+			entry.retryAt = r.options.Clock.Now().Add(r.options.StaleRefresh)
+			ObserveCacheStale(r.options.Metrics, r.options.Upstream)
+			return copyIPs(entry.ips), nil
+		}
+		delete(r.entries, key)
+	}
+	return ips, err
+}
+
+// isStale reports whether the expired entry can still be served.
+func (r *cacheResolver) isStale(entry *cacheEntry, now time.Time) bool {
+	return r.options.StaleTTL > 0 && now.Before(entry.expires.Add(r.options.StaleTTL))
+}
+
+func (r *cacheResolver) refresh(key, network, host string, opts ...Option) {
+	ctx, cancel := context.WithTimeout(context.Background(), staleRefreshTimeout)
+	defer cancel()
+
+	ips, err := r.resolver.Resolve(ctx, network, host, opts...)
+	if err == nil && len(ips) > 0 {
+		r.store(key, ips)
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if entry := r.entries[key]; entry != nil {
+		entry.refreshing = false
+		entry.retryAt = r.options.Clock.Now().Add(r.options.StaleRefresh)
+	}
+}
+
+func (r *cacheResolver) store(key string, ips []net.IP) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.entries[key] = &cacheEntry{
+		ips:     copyIPs(ips),
+		expires: r.options.Clock.Now().Add(r.options.TTL),
+	}
+	r.sweep()
+}
+
+// sweep removes the entries beyond the stale and cached-first windows at most once per TTL, it is called with the lock held.
+func (r *cacheResolver) sweep() {
+	now := r.options.Clock.Now()
+	if now.Sub(r.swept) < r.options.TTL {
+		return
+	}
+	r.swept = now
+
+	retention := max(r.options.StaleTTL, r.options.CachedFirst)
+	for k, entry := range r.entries {
+		if !now.Before(entry.expires.Add(retention)) && !entry.refreshing {
+			delete(r.entries, k)
+		}
+	}
+}
+
+func copyIPs(ips []net.IP) []net.IP {
+	result := make([]net.IP, len(ips))
+	copy(result, ips)
+	return result
+}
+
+// LookupSRV implements RecordLookuper, the records beyond A/AAAA are not cached.
+func (r *cacheResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	return recordLookuper(r.resolver).LookupSRV(ctx, service, proto, name)
+}
+
+func (r *cacheResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	return recordLookuper(r.resolver).LookupTXT(ctx, name)
+}
+
+func (r *cacheResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	return recordLookuper(r.resolver).LookupMX(ctx, name)
+}
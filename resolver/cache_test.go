@@ -0,0 +1,250 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-gost/core/common/clock"
+)
+
+// switchResolver answers with ips, or fails when it is down, and counts the queries.
+type switchResolver struct {
+	mu    sync.Mutex
+	ips   []net.IP
+	down  bool
+	count int
+}
+
+func (r *switchResolver) Resolve(ctx context.Context, network, host string, opts ...Option) ([]net.IP, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.count++
+	if r.down {
+		return nil, &net.DNSError{Err: "server misbehaving", Name: host}
+	}
+	return r.ips, nil
+}
+
+func (r *switchResolver) set(down bool, ips ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.down = down
+	if len(ips) > 0 {
+		r.ips = parseIPs(ips...)
+	}
+}
+
+func (r *switchResolver) queries() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.count
+}
+
+func TestCacheResolver(t *testing.T) {
+	c := clock.NewFakeClock(time.Unix(1000, 0))
+	upstream := &switchResolver{ips: parseIPs("192.0.2.1")}
+	r := CacheResolver(upstream, TTLCacheOption(time.Minute), ClockCacheOption(c))
+
+	for i := 0; i < 3; i++ {
+		ips, err := r.Resolve(context.Background(), "ip", "example.com")
+		if err != nil || len(ips) != 1 || !ips[0].Equal(net.ParseIP("192.0.2.1")) {
+			t.Fatalf("unexpected answer %v %v", ips, err)
+		}
+	}
+	if n := upstream.queries(); n != 1 {
+		t.Fatalf("the fresh answer should be cached, got %d queries", n)
+	}
+
+	// the IP is answered as is.
+	if ips, _ := r.Resolve(context.Background(), "ip", "198.51.100.1"); len(ips) != 1 || upstream.queries() != 1 {
+		t.Fatalf("unexpected answer of the IP %v", ips)
+	}
+
+	// expired without serve-stale.
+	c.Advance(time.Minute)
+	upstream.set(true)
+	if _, err := r.Resolve(context.Background(), "ip", "example.com"); err == nil {
+		t.Fatal("expected error without serve-stale")
+	}
+}
+
+func TestCacheResolverServeStale(t *testing.T) {
+	c := clock.NewFakeClock(time.Unix(1000, 0))
+	upstream := &switchResolver{ips: parseIPs("192.0.2.1")}
+	r := CacheResolver(upstream,
+		TTLCacheOption(time.Minute),
+		StaleTTLCacheOption(time.Hour),
+		StaleRefreshCacheOption(30*time.Second),
+		ClockCacheOption(c))
+
+	if _, err := r.Resolve(context.Background(), "ip", "example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the upstreams are down, the expired answer within the stale window is still served.
+	upstream.set(true)
+	c.Advance(2 * time.Minute)
+	ips, err := r.Resolve(context.Background(), "ip", "example.com")
+	if err != nil || len(ips) != 1 || !ips[0].Equal(net.ParseIP("192.0.2.1")) {
+		t.Fatalf("expected the stale answer, got %v %v", ips, err)
+	}
+
+	// within the stale refresh interval, the stale answer is served without waiting
+	// and the upstream is retried in the background.
+	upstream.set(false, "192.0.2.2")
+	if ips, err := r.Resolve(context.Background(), "ip", "example.com"); err != nil || !ips[0].Equal(net.ParseIP("192.0.2.1")) {
+		t.Fatalf("expected the stale answer, got %v %v", ips, err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for {
+		ips, err := r.Resolve(context.Background(), "ip", "example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ips[0].Equal(net.ParseIP("192.0.2.2")) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the stale answer is not refreshed in the background")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestCacheResolverStaleExpired(t *testing.T) {
+	c := clock.NewFakeClock(time.Unix(1000, 0))
+	upstream := &switchResolver{ips: parseIPs("192.0.2.1")}
+	r := CacheResolver(upstream,
+		TTLCacheOption(time.Minute),
+		StaleTTLCacheOption(10*time.Minute),
+		ClockCacheOption(c))
+
+	if _, err := r.Resolve(context.Background(), "ip", "example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	// beyond the stale window.
+	upstream.set(true)
+	c.Advance(11 * time.Minute)
+	_, err := r.Resolve(context.Background(), "ip", "example.com")
+	var dnsErr *net.DNSError
+	if !errors.As(err, &dnsErr) {
+		t.Fatalf("expected the upstream error beyond the stale window, got %v", err)
+	}
+}
+
+func TestCacheResolverCachedFirst(t *testing.T) {
+	c := clock.NewFakeClock(time.Unix(1000, 0))
+	upstream := &switchResolver{ips: parseIPs("192.0.2.1")}
+	r := CacheResolver(upstream,
+		TTLCacheOption(time.Minute),
+		CachedFirstCacheOption(time.Minute),
+		ClockCacheOption(c))
+
+	r.Resolve(context.Background(), "ip", "example.com")
+	upstream.set(false, "192.0.2.2")
+	c.Advance(90 * time.Second)
+
+	// the slightly stale answer is served immediately even though the upstream is healthy.
+	if ips, err := r.Resolve(context.Background(), "ip", "example.com"); err != nil || !ips[0].Equal(net.ParseIP("192.0.2.1")) {
+		t.Fatalf("expected the cached answer, got %v %v", ips, err)
+	}
+
+	// beyond the cached-first window the upstream is waited for.
+	c.Advance(time.Hour)
+	if ips, err := r.Resolve(context.Background(), "ip", "example.com"); err != nil || !ips[0].Equal(net.ParseIP("192.0.2.2")) {
+		t.Fatalf("expected the upstream answer, got %v %v", ips, err)
+	}
+}
+
+// gateResolver answers with ips once the gate is opened, and counts the queries started.
+type gateResolver struct {
+	switchResolver
+	gate chan struct{}
+}
+
+func (r *gateResolver) Resolve(ctx context.Context, network, host string, opts ...Option) ([]net.IP, error) {
+	r.mu.Lock()
+	r.count++
+	r.mu.Unlock()
+
+	select {
+	case <-r.gate:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.ips, nil
+}
+
+func TestCacheResolverCachedFirstSlow(t *testing.T) {
+	c := clock.NewFakeClock(time.Unix(1000, 0))
+	upstream := &gateResolver{gate: make(chan struct{})}
+	upstream.ips = parseIPs("192.0.2.1")
+	r := CacheResolver(upstream,
+		TTLCacheOption(time.Minute),
+		CachedFirstCacheOption(time.Hour),
+		ClockCacheOption(c))
+
+	// the cache miss blocks on the upstream.
+	done := make(chan []net.IP, 1)
+	go func() {
+		ips, _ := r.Resolve(context.Background(), "ip", "example.com")
+		done <- ips
+	}()
+	select {
+	case ips := <-done:
+		t.Fatalf("the cache miss returned without the upstream: %v", ips)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(upstream.gate)
+	if ips := <-done; len(ips) != 1 || !ips[0].Equal(net.ParseIP("192.0.2.1")) {
+		t.Fatalf("unexpected answer of the miss %v", ips)
+	}
+
+	// the upstream is slow, the expired answer is returned at once without an upstream call.
+	upstream.gate = make(chan struct{})
+	upstream.set(false, "192.0.2.2")
+	c.Advance(2 * time.Minute)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	ips, err := r.Resolve(ctx, "ip", "example.com")
+	if err != nil || !ips[0].Equal(net.ParseIP("192.0.2.1")) {
+		t.Fatalf("expected the cached answer, got %v %v", ips, err)
+	}
+
+	// the refresh is triggered in the background once.
+	deadline := time.Now().Add(time.Second)
+	for upstream.queries() != 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("the background refresh is not triggered, %d queries", upstream.queries())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	r.Resolve(ctx, "ip", "example.com")
+	if n := upstream.queries(); n != 2 {
+		t.Fatalf("expected one refresh in flight, got %d queries", n)
+	}
+
+	close(upstream.gate)
+	for {
+		ips, err := r.Resolve(ctx, "ip", "example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ips[0].Equal(net.ParseIP("192.0.2.2")) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the cached answer is not refreshed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
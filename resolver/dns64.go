@@ -0,0 +1,121 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"net"
+)
+
+var (
+	// WellKnownNAT64Prefix is the well-known prefix 64:ff9b::/96 of RFC 6052.
+	WellKnownNAT64Prefix = &net.IPNet{
+		IP:   net.ParseIP("64:ff9b::"),
+		Mask: net.CIDRMask(96, 128),
+	}
+
+	ErrInvalidNAT64Prefix = errors.New("invalid NAT64 prefix")
+)
+
+type dns64Resolver struct {
+	resolver Resolver
+	prefix   *net.IPNet
+}
+
+// DNS64Resolver wraps the resolver r to synthesize IPv6 addresses from the IPv4 addresses
+// with the NAT64 prefix (RFC 6147) if the name has no native IPv6 address.
+// The prefix length must be one of 32, 40, 48, 56, 64 or 96 (RFC 6052).
+func DNS64Resolver(r Resolver, prefix *net.IPNet) (Resolver, error) {
+	if prefix == nil {
+		prefix = WellKnownNAT64Prefix
+	}
+	ones, bits := prefix.Mask.Size()
+	if bits != 128 || prefix.IP.To4() != nil {
+		return nil, ErrInvalidNAT64Prefix
+	}
+	switch ones {
+	case 32, 40, 48, 56, 64, 96:
+	default:
+		return nil, ErrInvalidNAT64Prefix
+	}
+
+	return &dns64Resolver{
+		resolver: r,
+		prefix:   prefix,
+	}, nil
+}
+
+func (r *dns64Resolver) Resolve(ctx context.Context, network, host string, opts ...Option) ([]net.IP, error) {
+	switch network {
+	case "ip4":
+		return r.resolver.Resolve(ctx, network, host, opts...)
+	case "ip6":
+		ips, err := r.resolver.Resolve(ctx, network, host, opts...)
+		if hasIPv6(ips) {
+			return ips, nil
+		}
+		v4, err4 := r.resolver.Resolve(ctx, "ip4", host, opts...)
+		if err4 != nil || len(v4) == 0 {
+			if err == nil {
+				err = err4
+			}
+			return ips, err
+		}
+		return r.synthesize(v4), nil
+	default:
+		ips, err := r.resolver.Resolve(ctx, network, host, opts...)
+		if err != nil || hasIPv6(ips) {
+			return ips, err
+		}
+		return append(ips, r.synthesize(ips)...), nil
+	}
+}
+
+func hasIPv6(ips []net.IP) bool {
+	for _, ip := range ips {
+		if ip.To4() == nil && len(ip) == net.IPv6len {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *dns64Resolver) synthesize(ips []net.IP) []net.IP {
+	var result []net.IP
+	for _, ip := range ips {
+		if ip4 := ip.To4(); ip4 != nil {
+			result = append(result, SynthesizeIPv6(r.prefix, ip4))
+		}
+	}
+	return result
+}
+
+// SynthesizeIPv6 embeds the IPv4 address ip4 into the NAT64 prefix as specified in RFC 6052 section 2.2,
+// skipping the reserved bits 64 to 71.
+func SynthesizeIPv6(prefix *net.IPNet, ip4 net.IP) net.IP {
+	ones, _ := prefix.Mask.Size()
+	ip := make(net.IP, net.IPv6len)
+	copy(ip, prefix.IP.To16()[:ones/8])
+
+	pos := ones / 8
+	for _, b := range ip4.To4() {
+		if pos == 8 {
+			// u-octet
+			pos++
+		}
+		ip[pos] = b
+		pos++
+	}
+	return ip
+}
+
+func (r *dns64Resolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	return recordLookuper(r.resolver).LookupSRV(ctx, service, proto, name)
+}
+
+func (r *dns64Resolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	return recordLookuper(r.resolver).LookupTXT(ctx, name)
+}
+
+func (r *dns64Resolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	return recordLookuper(r.resolver).LookupMX(ctx, name)
+}
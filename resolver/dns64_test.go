@@ -0,0 +1,117 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+)
+
+// recordResolver resolves the IPs of network and host from the records.
+type recordResolver map[string][]net.IP
+
+func (r recordResolver) Resolve(ctx context.Context, network, host string, opts ...Option) ([]net.IP, error) {
+	var ips []net.IP
+	for _, ip := range r[host] {
+		switch {
+		case network == "ip4" && ip.To4() != nil,
+			network == "ip6" && ip.To4() == nil,
+			network == "ip":
+			ips = append(ips, ip)
+		}
+	}
+	if len(ips) == 0 {
+		return nil, errors.New("no such host")
+	}
+	return ips, nil
+}
+
+func TestDNS64Resolver(t *testing.T) {
+	_, prefix, _ := net.ParseCIDR("2001:db8:122:344::/96")
+	r, err := DNS64Resolver(recordResolver{
+		"v4.example.com":   parseIPs("192.0.2.33"),
+		"dual.example.com": parseIPs("192.0.2.1", "2001:db8::1"),
+		"v6.example.com":   parseIPs("2001:db8::2"),
+	}, prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A-only name yields the synthesized AAAA.
+	ips, err := r.Resolve(context.Background(), "ip6", "v4.example.com")
+	if err != nil || len(ips) != 1 || !ips[0].Equal(net.ParseIP("2001:db8:122:344::c000:221")) {
+		t.Fatalf("unexpected synthesized IPs %v %v", ips, err)
+	}
+	ips, err = r.Resolve(context.Background(), "ip", "v4.example.com")
+	if err != nil || len(ips) != 2 || !ips[1].Equal(net.ParseIP("2001:db8:122:344::c000:221")) {
+		t.Fatalf("unexpected IPs %v %v", ips, err)
+	}
+
+	// native AAAA is left alone.
+	for _, host := range []string{"dual.example.com", "v6.example.com"} {
+		ips, err := r.Resolve(context.Background(), "ip6", host)
+		if err != nil || len(ips) != 1 || !prefixOf(ips[0], "2001:db8::/64") {
+			t.Fatalf("%s: native AAAA should pass through, got %v %v", host, ips, err)
+		}
+	}
+	ips, err = r.Resolve(context.Background(), "ip", "dual.example.com")
+	if err != nil || len(ips) != 2 {
+		t.Fatalf("dual-stack name should be left alone, got %v %v", ips, err)
+	}
+
+	// IPv4 lookup is never synthesized.
+	ips, err = r.Resolve(context.Background(), "ip4", "v4.example.com")
+	if err != nil || len(ips) != 1 || ips[0].To4() == nil {
+		t.Fatalf("unexpected IPv4 result %v %v", ips, err)
+	}
+
+	if _, err := r.Resolve(context.Background(), "ip6", "missing.example.com"); err == nil {
+		t.Fatal("expected error for unknown name")
+	}
+}
+
+func prefixOf(ip net.IP, cidr string) bool {
+	_, n, _ := net.ParseCIDR(cidr)
+	return n.Contains(ip)
+}
+
+func TestDNS64ResolverWellKnownPrefix(t *testing.T) {
+	r, err := DNS64Resolver(recordResolver{"v4.example.com": parseIPs("192.0.2.33")}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ips, err := r.Resolve(context.Background(), "ip6", "v4.example.com")
+	if err != nil || len(ips) != 1 || !ips[0].Equal(net.ParseIP("64:ff9b::192.0.2.33")) {
+		t.Fatalf("unexpected IPs %v %v", ips, err)
+	}
+}
+
+func TestDNS64ResolverInvalidPrefix(t *testing.T) {
+	for _, cidr := range []string{"2001:db8::/33", "192.0.2.0/24"} {
+		_, prefix, _ := net.ParseCIDR(cidr)
+		if _, err := DNS64Resolver(recordResolver{}, prefix); !errors.Is(err, ErrInvalidNAT64Prefix) {
+			t.Errorf("%s: expected ErrInvalidNAT64Prefix, got %v", cidr, err)
+		}
+	}
+}
+
+func TestSynthesizeIPv6(t *testing.T) {
+	// the examples of RFC 6052 section 2.4.
+	ip4 := net.ParseIP("192.0.2.33")
+	for _, tc := range []struct {
+		prefix string
+		want   string
+	}{
+		{prefix: "2001:db8::/32", want: "2001:db8:c000:221::"},
+		{prefix: "2001:db8:100::/40", want: "2001:db8:1c0:2:21::"},
+		{prefix: "2001:db8:122::/48", want: "2001:db8:122:c000:2:2100::"},
+		{prefix: "2001:db8:122:300::/56", want: "2001:db8:122:3c0:0:221::"},
+		{prefix: "2001:db8:122:344::/64", want: "2001:db8:122:344:c0:2:2100:0"},
+		{prefix: "2001:db8:122:344::/96", want: "2001:db8:122:344::192.0.2.33"},
+	} {
+		_, prefix, _ := net.ParseCIDR(tc.prefix)
+		if got := SynthesizeIPv6(prefix, ip4); !got.Equal(net.ParseIP(tc.want)) {
+			t.Errorf("%s: got %s, want %s", tc.prefix, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+)
+
+var (
+	ErrNotAuthenticated = errors.New("resolver: answer is not DNSSEC authenticated")
+)
+
+// AuthenticatedResolver is a Resolver reporting whether the answer is DNSSEC authenticated,
+// e.g. by the AD (Authenticated Data) bit in the response of a validating upstream.
+type AuthenticatedResolver interface {
+	Resolver
+	ResolveAuthenticated(ctx context.Context, network, host string, opts ...Option) (ips []net.IP, authenticated bool, err error)
+}
+
+// Validator validates the answer locally, e.g. against the DNSSEC trust anchors.
+type Validator interface {
+	Validate(ctx context.Context, host string, ips []net.IP) error
+}
+
+type DNSSECOptions struct {
+	// Validator validates the answers without the AD bit locally, instead of rejecting them.
+	Validator Validator
+}
+
+type DNSSECOption func(opts *DNSSECOptions)
+
+func ValidatorDNSSECOption(v Validator) DNSSECOption {
+	return func(opts *DNSSECOptions) {
+		opts.Validator = v
+	}
+}
+
+type dnssecResolver struct {
+	resolver Resolver
+	zones    []string
+	options  DNSSECOptions
+}
+
+// DNSSECResolver wraps the resolver r to require the DNSSEC authenticated answers for the names under
+// the secure zones (e.g. example.com, or "." for all names), which prevents the spoofed answers.
+// An answer for a secure name fails with ErrNotAuthenticated if it is not authenticated by r (see AuthenticatedResolver)
+// and not validated by the Validator. The names outside the zones are resolved by r as is.
+// It should wrap the upstream directly, e.g. inside CacheResolver, as the other wrappers do not report the authentication.
+func DNSSECResolver(r Resolver, zones []string, opts ...DNSSECOption) Resolver {
+	var options DNSSECOptions
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+
+	p := &dnssecResolver{
+		resolver: r,
+		options:  options,
+	}
+	for _, zone := range zones {
+		if strings.TrimSpace(zone) == "" {
+			continue
+		}
+		p.zones = append(p.zones, normalizeZone(zone))
+	}
+	return p
+}
+
+func (r *dnssecResolver) Resolve(ctx context.Context, network, host string, opts ...Option) ([]net.IP, error) {
+	if net.ParseIP(host) != nil || !r.isSecure(host) {
+		return r.resolver.Resolve(ctx, network, host, opts...)
+	}
+
+	var ips []net.IP
+	var authenticated bool
+	var err error
+	if ar, ok := r.resolver.(AuthenticatedResolver); ok {
+		ips, authenticated, err = ar.ResolveAuthenticated(ctx, network, host, opts...)
+	} else {
+		ips, err = r.resolver.Resolve(ctx, network, host, opts...)
+	}
+	if err != nil || authenticated {
+		return ips, err
+	}
+
+	if v := r.options.Validator; v != nil {
+		if err := v.Validate(ctx, host, ips); err != nil {
+			return nil, errors.Join(ErrNotAuthenticated, err)
+		}
+		return ips, nil
+	}
+	return nil, ErrNotAuthenticated
+}
+
+// isSecure reports whether host is under any of the secure zones.
+func (r *dnssecResolver) isSecure(host string) bool {
+	host = normalizeZone(host)
+	for _, zone := range r.zones {
+		if zone == "" || host == zone || strings.HasSuffix(host, "."+zone) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeZone returns the lower-cased name without the trailing dot, the root zone is empty.
+func normalizeZone(name string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), ".")
+}
+
+// LookupSRV implements RecordLookuper, the records of the secure zones can not be authenticated
+// and fail with ErrNotAuthenticated.
+func (r *dnssecResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	if r.isSecure(srvName(service, proto, name)) {
+		return "", nil, ErrNotAuthenticated
+	}
+	return recordLookuper(r.resolver).LookupSRV(ctx, service, proto, name)
+}
+
+func (r *dnssecResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	if r.isSecure(name) {
+		return nil, ErrNotAuthenticated
+	}
+	return recordLookuper(r.resolver).LookupTXT(ctx, name)
+}
+
+func (r *dnssecResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	if r.isSecure(name) {
+		return nil, ErrNotAuthenticated
+	}
+	return recordLookuper(r.resolver).LookupMX(ctx, name)
+}
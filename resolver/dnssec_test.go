@@ -0,0 +1,105 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+)
+
+// adResolver answers with ips and sets the AD bit for the authenticated names.
+type adResolver struct {
+	ips           []net.IP
+	authenticated map[string]bool
+}
+
+func (r *adResolver) Resolve(ctx context.Context, network, host string, opts ...Option) ([]net.IP, error) {
+	ips, _, err := r.ResolveAuthenticated(ctx, network, host, opts...)
+	return ips, err
+}
+
+func (r *adResolver) ResolveAuthenticated(ctx context.Context, network, host string, opts ...Option) ([]net.IP, bool, error) {
+	return r.ips, r.authenticated[host], nil
+}
+
+// funcValidator is an adapter to use a function as Validator.
+type funcValidator func(host string, ips []net.IP) error
+
+func (f funcValidator) Validate(ctx context.Context, host string, ips []net.IP) error {
+	return f(host, ips)
+}
+
+func TestDNSSECResolver(t *testing.T) {
+	upstream := &adResolver{
+		ips:           parseIPs("192.0.2.1"),
+		authenticated: map[string]bool{"signed.example.com": true, "www.example.org": true},
+	}
+	r := DNSSECResolver(upstream, []string{"Example.COM.", " ", "bank.example"})
+
+	for _, tc := range []struct {
+		host string
+		err  error
+	}{
+		// the AD-set answer is accepted.
+		{"signed.example.com", nil},
+		// the answer without AD for a secure-zone name is rejected.
+		{"spoofed.example.com", ErrNotAuthenticated},
+		{"EXAMPLE.com.", ErrNotAuthenticated},
+		{"www.bank.example", ErrNotAuthenticated},
+		// the names outside the zones are resolved as is.
+		{"www.example.org", nil},
+		{"unsigned.example.net", nil},
+		{"notexample.com", nil},
+		{"192.0.2.9", nil},
+	} {
+		ips, err := r.Resolve(context.Background(), "ip", tc.host)
+		if !errors.Is(err, tc.err) {
+			t.Errorf("%s: got error %v, want %v", tc.host, err, tc.err)
+		}
+		if tc.err == nil && len(ips) != 1 {
+			t.Errorf("%s: unexpected answer %v", tc.host, ips)
+		}
+		if tc.err != nil && ips != nil {
+			t.Errorf("%s: the rejected answer should not be returned, got %v", tc.host, ips)
+		}
+	}
+}
+
+func TestDNSSECResolverRootZone(t *testing.T) {
+	upstream := &adResolver{ips: parseIPs("192.0.2.1"), authenticated: map[string]bool{"a.example": true}}
+	r := DNSSECResolver(upstream, []string{"."})
+
+	if _, err := r.Resolve(context.Background(), "ip", "a.example"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Resolve(context.Background(), "ip", "b.example"); !errors.Is(err, ErrNotAuthenticated) {
+		t.Fatalf("all the names are secure under the root zone, got %v", err)
+	}
+
+	// the upstream not reporting the authentication can not serve the secure names.
+	r = DNSSECResolver(&staticResolver{ips: parseIPs("192.0.2.1")}, []string{"."})
+	if _, err := r.Resolve(context.Background(), "ip", "a.example"); !errors.Is(err, ErrNotAuthenticated) {
+		t.Fatalf("expected ErrNotAuthenticated, got %v", err)
+	}
+}
+
+func TestDNSSECResolverValidator(t *testing.T) {
+	upstream := &adResolver{ips: parseIPs("192.0.2.1")}
+	errBogus := errors.New("bogus signature")
+	validator := funcValidator(func(host string, ips []net.IP) error {
+		if host == "valid.example.com" && len(ips) == 1 {
+			return nil
+		}
+		return errBogus
+	})
+	r := DNSSECResolver(upstream, []string{"example.com"}, ValidatorDNSSECOption(validator))
+
+	// the answer without AD is validated locally.
+	if ips, err := r.Resolve(context.Background(), "ip", "valid.example.com"); err != nil || len(ips) != 1 {
+		t.Fatalf("expected the locally validated answer, got %v %v", ips, err)
+	}
+	_, err := r.Resolve(context.Background(), "ip", "bogus.example.com")
+	if !errors.Is(err, ErrNotAuthenticated) || !errors.Is(err, errBogus) {
+		t.Fatalf("expected the validation error, got %v", err)
+	}
+}
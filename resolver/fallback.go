@@ -0,0 +1,91 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"net"
+)
+
+type fallbackResolver struct {
+	resolver Resolver
+	system   *net.Resolver
+	enabled  bool
+}
+
+// FallbackResolver wraps the custom resolver r and falls back to the system resolver (net.DefaultResolver)
+// when r fails or times out, so the names are still resolved if the configured DNS is unreachable.
+// The fallback may leak the queries to the system DNS, so it takes effect only if enabled is true.
+// A not found answer from r is authoritative and is not retried.
+func FallbackResolver(r Resolver, enabled bool) Resolver {
+	return &fallbackResolver{
+		resolver: r,
+		system:   net.DefaultResolver,
+		enabled:  enabled,
+	}
+}
+
+func (r *fallbackResolver) Resolve(ctx context.Context, network, host string, opts ...Option) ([]net.IP, error) {
+	ips, err := r.resolver.Resolve(ctx, network, host, opts...)
+	if !r.fallback(ctx, err) {
+		return ips, err
+	}
+
+	if network == "" {
+		network = "ip"
+	}
+	sysIPs, sysErr := r.system.LookupIP(ctx, network, host)
+	if sysErr != nil {
+		return nil, errors.Join(err, sysErr)
+	}
+	return sysIPs, nil
+}
+
+// fallback reports whether the system resolver is queried after the resolver fails with err.
+func (r *fallbackResolver) fallback(ctx context.Context, err error) bool {
+	if err == nil || !r.enabled {
+		return false
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		return false
+	}
+	// the caller gives up, there is no time for the fallback.
+	return ctx.Err() == nil
+}
+
+func (r *fallbackResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	cname, srvs, err := recordLookuper(r.resolver).LookupSRV(ctx, service, proto, name)
+	if !r.fallback(ctx, err) {
+		return cname, srvs, err
+	}
+	cname, srvs, sysErr := r.system.LookupSRV(ctx, service, proto, name)
+	if sysErr != nil {
+		return "", nil, errors.Join(err, sysErr)
+	}
+	return cname, srvs, nil
+}
+
+func (r *fallbackResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	txts, err := recordLookuper(r.resolver).LookupTXT(ctx, name)
+	if !r.fallback(ctx, err) {
+		return txts, err
+	}
+	txts, sysErr := r.system.LookupTXT(ctx, name)
+	if sysErr != nil {
+		return nil, errors.Join(err, sysErr)
+	}
+	return txts, nil
+}
+
+func (r *fallbackResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	mxs, err := recordLookuper(r.resolver).LookupMX(ctx, name)
+	if !r.fallback(ctx, err) {
+		return mxs, err
+	}
+	mxs, sysErr := r.system.LookupMX(ctx, name)
+	if sysErr != nil {
+		return nil, errors.Join(err, sysErr)
+	}
+	return mxs, nil
+}
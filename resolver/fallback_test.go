@@ -0,0 +1,95 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+)
+
+var errUnreachable = errors.New("dial: network is unreachable")
+
+// unreachableSystem is a system resolver whose DNS servers are unreachable, the hosts file is still used.
+func unreachableSystem() *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			return nil, errUnreachable
+		},
+	}
+}
+
+func TestFallbackResolver(t *testing.T) {
+	down := &switchResolver{down: true}
+
+	// the failing custom resolver triggers the system fallback, localhost is in the hosts file.
+	r := FallbackResolver(down, true)
+	r.(*fallbackResolver).system = unreachableSystem()
+	ips, err := r.Resolve(context.Background(), "ip4", "localhost")
+	if err != nil || len(ips) == 0 || !ips[0].IsLoopback() {
+		t.Fatalf("expected the system answer, got %v %v", ips, err)
+	}
+	if n := down.queries(); n != 1 {
+		t.Fatalf("the custom resolver should be queried first, got %d queries", n)
+	}
+
+	// the error is returned when the fallback is disabled.
+	r = FallbackResolver(down, false)
+	var dnsErr *net.DNSError
+	if _, err := r.Resolve(context.Background(), "ip4", "localhost"); !errors.As(err, &dnsErr) || dnsErr.Err != "server misbehaving" {
+		t.Fatalf("expected the custom resolver error, got %v", err)
+	}
+
+	// the answer of the healthy custom resolver is used as is.
+	r = FallbackResolver(&switchResolver{ips: parseIPs("192.0.2.1")}, true)
+	if ips, err := r.Resolve(context.Background(), "ip", "localhost"); err != nil || !ips[0].Equal(net.ParseIP("192.0.2.1")) {
+		t.Fatalf("unexpected answer %v %v", ips, err)
+	}
+}
+
+func TestFallbackResolverNoFallback(t *testing.T) {
+	notFound := funcResolver(func(ctx context.Context, network, host string) ([]net.IP, error) {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	})
+	r := FallbackResolver(notFound, true)
+	r.(*fallbackResolver).system = unreachableSystem()
+
+	// the not found answer is authoritative.
+	var dnsErr *net.DNSError
+	if _, err := r.Resolve(context.Background(), "ip4", "localhost"); !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+		t.Fatalf("expected not found without fallback, got %v", err)
+	}
+
+	// the caller gives up.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r = FallbackResolver(funcResolver(func(ctx context.Context, network, host string) ([]net.IP, error) {
+		return nil, ctx.Err()
+	}), true)
+	r.(*fallbackResolver).system = unreachableSystem()
+	if _, err := r.Resolve(ctx, "ip4", "localhost"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the canceled error, got %v", err)
+	}
+}
+
+func TestFallbackResolverSystemFailure(t *testing.T) {
+	r := FallbackResolver(&switchResolver{down: true}, true)
+	r.(*fallbackResolver).system = unreachableSystem()
+
+	// both errors are reported if the system resolver fails too.
+	_, err := r.Resolve(context.Background(), "ip4", "example.invalid")
+	if err == nil || !strings.Contains(err.Error(), "server misbehaving") || !strings.Contains(err.Error(), errUnreachable.Error()) {
+		t.Fatalf("expected the joined errors, got %v", err)
+	}
+
+	// the record lookups fall back too.
+	l := FallbackResolver(newRecordServer(), true).(RecordLookuper)
+	l.(*fallbackResolver).system = unreachableSystem()
+	if _, err := l.LookupTXT(context.Background(), "example.invalid"); err == nil || !strings.Contains(err.Error(), errUnreachable.Error()) {
+		t.Fatalf("expected the system lookup after the failure, got %v", err)
+	}
+	if txts, err := l.LookupTXT(context.Background(), "example.com"); err != nil || len(txts) != 1 {
+		t.Fatalf("unexpected records %v %v", txts, err)
+	}
+}
@@ -0,0 +1,166 @@
+package resolver
+
+import (
+	"context"
+	"net"
+)
+
+var (
+	bogonNets = parseCIDRs(
+		"0.0.0.0/8",
+		"10.0.0.0/8",
+		"100.64.0.0/10",
+		"127.0.0.0/8",
+		"169.254.0.0/16",
+		"172.16.0.0/12",
+		"192.0.0.0/24",
+		"192.0.2.0/24",
+		"192.168.0.0/16",
+		"198.18.0.0/15",
+		"198.51.100.0/24",
+		"203.0.113.0/24",
+		"224.0.0.0/4",
+		"240.0.0.0/4",
+		"::/128",
+		"::1/128",
+		"100::/64",
+		"2001:db8::/32",
+		"fc00::/7",
+		"fe80::/10",
+		"ff00::/8",
+	)
+)
+
+func parseCIDRs(cidrs ...string) []*net.IPNet {
+	var nets []*net.IPNet
+	for _, s := range cidrs {
+		if _, ipNet, err := net.ParseCIDR(s); err == nil {
+			nets = append(nets, ipNet)
+		}
+	}
+	return nets
+}
+
+// IsBogon reports whether ip is in a private, loopback, link-local,
+// multicast or otherwise non-routable (bogon) range.
+func IsBogon(ip net.IP) bool {
+	return containsIP(bogonNets, ip)
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	for _, ipNet := range nets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+type FilterOptions struct {
+	// Allow lists the ranges that are always accepted, this takes precedence over Deny and bogon ranges.
+	Allow []*net.IPNet
+	// Deny lists the extra ranges that are rejected in addition to bogon ranges.
+	Deny []*net.IPNet
+}
+
+type FilterOption func(opts *FilterOptions)
+
+func AllowFilterOption(nets ...*net.IPNet) FilterOption {
+	return func(opts *FilterOptions) {
+		opts.Allow = nets
+	}
+}
+
+func DenyFilterOption(nets ...*net.IPNet) FilterOption {
+	return func(opts *FilterOptions) {
+		opts.Deny = nets
+	}
+}
+
+// IPFilter decides whether an address in the answers is acceptable.
+type IPFilter interface {
+	Allowed(ip net.IP) bool
+}
+
+type ipFilter struct {
+	options FilterOptions
+}
+
+// NewIPFilter creates an IPFilter rejecting the bogon and denied ranges.
+func NewIPFilter(opts ...FilterOption) IPFilter {
+	var options FilterOptions
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+
+	return &ipFilter{
+		options: options,
+	}
+}
+
+func (f *ipFilter) Allowed(ip net.IP) bool {
+	if containsIP(f.options.Allow, ip) {
+		return true
+	}
+	return !containsIP(f.options.Deny, ip) && !IsBogon(ip)
+}
+
+type filterResolver struct {
+	resolver Resolver
+	filter   IPFilter
+}
+
+// FilterResolver wraps the resolver r and strips the answers in bogon or denied ranges,
+// which protects against DNS rebinding when resolving public names.
+// If no address is left, a not found error is returned.
+func FilterResolver(r Resolver, opts ...FilterOption) Resolver {
+	return &filterResolver{
+		resolver: r,
+		filter:   NewIPFilter(opts...),
+	}
+}
+
+func (r *filterResolver) Resolve(ctx context.Context, network, host string, opts ...Option) ([]net.IP, error) {
+	ips, err := r.resolver.Resolve(ctx, network, host, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	// explicit IP addresses are not subject to rebinding.
+	if net.ParseIP(host) != nil {
+		return ips, nil
+	}
+
+	var result []net.IP
+	for _, ip := range ips {
+		if r.filter.Allowed(ip) {
+			result = append(result, ip)
+		}
+	}
+	if len(result) == 0 {
+		return nil, &net.DNSError{
+			Err:        "no such host",
+			Name:       host,
+			IsNotFound: true,
+		}
+	}
+
+	return result, nil
+}
+
+func (r *filterResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	return recordLookuper(r.resolver).LookupSRV(ctx, service, proto, name)
+}
+
+func (r *filterResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	return recordLookuper(r.resolver).LookupTXT(ctx, name)
+}
+
+func (r *filterResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	return recordLookuper(r.resolver).LookupMX(ctx, name)
+}
@@ -0,0 +1,100 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+)
+
+// staticResolver answers every name with the fixed addresses.
+type staticResolver struct {
+	ips []net.IP
+	err error
+}
+
+func (r *staticResolver) Resolve(ctx context.Context, network, host string, opts ...Option) ([]net.IP, error) {
+	return r.ips, r.err
+}
+
+func parseIPs(ss ...string) []net.IP {
+	var ips []net.IP
+	for _, s := range ss {
+		ips = append(ips, net.ParseIP(s))
+	}
+	return ips
+}
+
+func TestFilterResolverRejectsLoopback(t *testing.T) {
+	r := FilterResolver(&staticResolver{ips: parseIPs("127.0.0.1")})
+
+	ips, err := r.Resolve(context.Background(), "ip", "rebind.example.com")
+	var dnsErr *net.DNSError
+	if !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+	if len(ips) != 0 {
+		t.Fatalf("expected no answer, got %v", ips)
+	}
+}
+
+func TestFilterResolverPassesPublic(t *testing.T) {
+	r := FilterResolver(&staticResolver{ips: parseIPs("93.184.216.34", "10.0.0.1", "fe80::1", "2606:2800:220:1::1")})
+
+	ips, err := r.Resolve(context.Background(), "ip", "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := parseIPs("93.184.216.34", "2606:2800:220:1::1")
+	if len(ips) != len(want) {
+		t.Fatalf("got %v, want %v", ips, want)
+	}
+	for i := range want {
+		if !ips[i].Equal(want[i]) {
+			t.Fatalf("got %v, want %v", ips, want)
+		}
+	}
+}
+
+func TestFilterResolverAllowDeny(t *testing.T) {
+	_, allow, _ := net.ParseCIDR("10.1.0.0/16")
+	_, deny, _ := net.ParseCIDR("93.184.0.0/16")
+	r := FilterResolver(&staticResolver{ips: parseIPs("10.1.2.3", "10.2.0.1", "93.184.216.34", "1.1.1.1")},
+		AllowFilterOption(allow), DenyFilterOption(deny))
+
+	ips, err := r.Resolve(context.Background(), "ip", "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ips) != 2 || !ips[0].Equal(net.ParseIP("10.1.2.3")) || !ips[1].Equal(net.ParseIP("1.1.1.1")) {
+		t.Fatalf("unexpected answer %v", ips)
+	}
+}
+
+func TestFilterResolverLiteralIP(t *testing.T) {
+	r := FilterResolver(&staticResolver{ips: parseIPs("127.0.0.1")})
+
+	ips, err := r.Resolve(context.Background(), "ip", "127.0.0.1")
+	if err != nil || len(ips) != 1 {
+		t.Fatalf("literal IP should not be filtered: %v %v", ips, err)
+	}
+}
+
+func TestIsBogon(t *testing.T) {
+	for _, tc := range []struct {
+		ip    string
+		bogon bool
+	}{
+		{"127.0.0.1", true},
+		{"192.168.1.1", true},
+		{"169.254.169.254", true},
+		{"::1", true},
+		{"::ffff:10.0.0.1", true},
+		{"8.8.8.8", false},
+		{"2001:4860:4860::8888", false},
+	} {
+		if got := IsBogon(net.ParseIP(tc.ip)); got != tc.bogon {
+			t.Errorf("IsBogon(%s) = %v, want %v", tc.ip, got, tc.bogon)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package resolver
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+type hedgeResolver struct {
+	primary   Resolver
+	secondary Resolver
+	delay     time.Duration
+}
+
+// HedgeResolver resolves with primary, and issues a hedged query to secondary if primary
+// has not answered within delay (e.g. the p95 latency of primary), the first answer wins
+// and the other query is cancelled. If primary fails before the delay, secondary is queried immediately.
+func HedgeResolver(primary, secondary Resolver, delay time.Duration) Resolver {
+	return &hedgeResolver{
+		primary:   primary,
+		secondary: secondary,
+		delay:     delay,
+	}
+}
+
+type resolveResult struct {
+	ips []net.IP
+	err error
+}
+
+func (r *hedgeResolver) Resolve(ctx context.Context, network, host string, opts ...Option) ([]net.IP, error) {
+	if r.secondary == nil {
+		return r.primary.Resolve(ctx, network, host, opts...)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan resolveResult, 2)
+	query := func(resolver Resolver) {
+		ips, err := resolver.Resolve(ctx, network, host, opts...)
+		results <- resolveResult{ips: ips, err: err}
+	}
+
+	go query(r.primary)
+	pending := 1
+	hedged := false
+	hedge := func() {
+		if !hedged {
+			hedged = true
+			pending++
+			go query(r.secondary)
+		}
+	}
+
+	timer := time.NewTimer(r.delay)
+	defer timer.Stop()
+
+	var err error
+	for pending > 0 {
+		select {
+		case res := <-results:
+			pending--
+			if res.err == nil {
+				return res.ips, nil
+			}
+			err = res.err
+			hedge()
+		case <-timer.C:
+			hedge()
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return nil, err
+}
+
+// LookupSRV implements RecordLookuper, the lookups are not hedged, the secondary is queried if the primary fails.
+func (r *hedgeResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	cname, srvs, err := recordLookuper(r.primary).LookupSRV(ctx, service, proto, name)
+	if err != nil && r.secondary != nil && ctx.Err() == nil {
+		return recordLookuper(r.secondary).LookupSRV(ctx, service, proto, name)
+	}
+	return cname, srvs, err
+}
+
+func (r *hedgeResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	txts, err := recordLookuper(r.primary).LookupTXT(ctx, name)
+	if err != nil && r.secondary != nil && ctx.Err() == nil {
+		return recordLookuper(r.secondary).LookupTXT(ctx, name)
+	}
+	return txts, err
+}
+
+func (r *hedgeResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	mxs, err := recordLookuper(r.primary).LookupMX(ctx, name)
+	if err != nil && r.secondary != nil && ctx.Err() == nil {
+		return recordLookuper(r.secondary).LookupMX(ctx, name)
+	}
+	return mxs, err
+}
@@ -0,0 +1,80 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHedgeResolver(t *testing.T) {
+	cancelled := make(chan struct{})
+	slow := funcResolver(func(ctx context.Context, network, host string) ([]net.IP, error) {
+		<-ctx.Done()
+		close(cancelled)
+		return nil, ctx.Err()
+	})
+	var hedged atomic.Int64
+	fast := funcResolver(func(ctx context.Context, network, host string) ([]net.IP, error) {
+		hedged.Add(1)
+		return parseIPs("192.0.2.1"), nil
+	})
+
+	delay := 50 * time.Millisecond
+	start := time.Now()
+	ips, err := HedgeResolver(slow, fast, delay).Resolve(context.Background(), "ip", "example.com")
+	if err != nil || len(ips) != 1 || !ips[0].Equal(net.ParseIP("192.0.2.1")) {
+		t.Fatalf("expected the answer of the hedged query, got %v %v", ips, err)
+	}
+	if elapsed := time.Since(start); elapsed < delay {
+		t.Fatalf("the hedge fired before the delay, after %s", elapsed)
+	}
+	if n := hedged.Load(); n != 1 {
+		t.Fatalf("expected 1 hedged query, got %d", n)
+	}
+
+	// the slower query is cancelled.
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("the primary query is not cancelled")
+	}
+}
+
+func TestHedgeResolverFast(t *testing.T) {
+	var hedged atomic.Int64
+	secondary := funcResolver(func(ctx context.Context, network, host string) ([]net.IP, error) {
+		hedged.Add(1)
+		return parseIPs("192.0.2.2"), nil
+	})
+	r := HedgeResolver(&staticResolver{ips: parseIPs("192.0.2.1")}, secondary, 20*time.Millisecond)
+
+	for i := 0; i < 10; i++ {
+		ips, err := r.Resolve(context.Background(), "ip", "example.com")
+		if err != nil || !ips[0].Equal(net.ParseIP("192.0.2.1")) {
+			t.Fatalf("expected the primary answer, got %v %v", ips, err)
+		}
+	}
+	time.Sleep(40 * time.Millisecond)
+	if n := hedged.Load(); n != 0 {
+		t.Fatalf("a fast response should never trigger a hedge, got %d hedged queries", n)
+	}
+}
+
+func TestHedgeResolverPrimaryFailed(t *testing.T) {
+	errFail := errors.New("server misbehaving")
+	r := HedgeResolver(&staticResolver{err: errFail}, &staticResolver{ips: parseIPs("192.0.2.2")}, time.Hour)
+
+	// the secondary is queried immediately without waiting for the delay.
+	ips, err := r.Resolve(context.Background(), "ip", "example.com")
+	if err != nil || !ips[0].Equal(net.ParseIP("192.0.2.2")) {
+		t.Fatalf("expected the secondary answer, got %v %v", ips, err)
+	}
+
+	r = HedgeResolver(&staticResolver{err: errFail}, &staticResolver{err: errors.New("refused")}, time.Hour)
+	if _, err := r.Resolve(context.Background(), "ip", "example.com"); err == nil {
+		t.Fatal("expected error if both queries fail")
+	}
+}
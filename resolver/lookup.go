@@ -0,0 +1,111 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sort"
+)
+
+var (
+	ErrRecordLookupUnsupported = errors.New("resolver: record lookup is not supported")
+)
+
+// RecordLookuper is a resolver supporting the lookups of the record types beyond A/AAAA,
+// e.g. for the service discovery by DNS SRV. The wrapper resolvers of this package
+// (e.g. CacheResolver and FilterResolver) forward the lookups to the wrapped resolver,
+// they fail with ErrRecordLookupUnsupported if it is not a RecordLookuper.
+type RecordLookuper interface {
+	// LookupSRV returns the SRV records of the service, see net.Resolver.LookupSRV.
+	// The records are sorted by priority and randomized by weight within a priority.
+	LookupSRV(ctx context.Context, service, proto, name string) (cname string, srvs []*net.SRV, err error)
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+	LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+}
+
+type systemLookuper struct {
+	resolver *net.Resolver
+}
+
+// SystemLookuper is a RecordLookuper backed by the Go resolver r, default is net.DefaultResolver.
+func SystemLookuper(r *net.Resolver) RecordLookuper {
+	if r == nil {
+		r = net.DefaultResolver
+	}
+	return &systemLookuper{
+		resolver: r,
+	}
+}
+
+func (l *systemLookuper) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	return l.resolver.LookupSRV(ctx, service, proto, name)
+}
+
+func (l *systemLookuper) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	return l.resolver.LookupTXT(ctx, name)
+}
+
+func (l *systemLookuper) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	return l.resolver.LookupMX(ctx, name)
+}
+
+// recordLookuper returns r as a RecordLookuper, the lookups fail with ErrRecordLookupUnsupported
+// if r does not support them.
+func recordLookuper(r Resolver) RecordLookuper {
+	if l, ok := r.(RecordLookuper); ok {
+		return l
+	}
+	return unsupportedLookuper{}
+}
+
+type unsupportedLookuper struct{}
+
+func (unsupportedLookuper) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	return "", nil, ErrRecordLookupUnsupported
+}
+
+func (unsupportedLookuper) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	return nil, ErrRecordLookupUnsupported
+}
+
+func (unsupportedLookuper) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	return nil, ErrRecordLookupUnsupported
+}
+
+// srvName returns the queried name of the SRV lookup, see net.Resolver.LookupSRV.
+func srvName(service, proto, name string) string {
+	if service == "" && proto == "" {
+		return name
+	}
+	return "_" + service + "._" + proto + "." + name
+}
+
+// LookupSRV looks up the SRV records of the service by r, if r is nil the system resolver is used.
+// ErrRecordLookupUnsupported is returned if r is not a RecordLookuper, so the lookup does not bypass
+// the upstreams of r silently. The records are ordered by the priority ascending
+// and then by the weight descending, so the result is deterministic.
+func LookupSRV(ctx context.Context, r Resolver, service, proto, name string) ([]*net.SRV, error) {
+	var l RecordLookuper
+	if r == nil {
+		l = SystemLookuper(nil)
+	} else {
+		l = recordLookuper(r)
+	}
+
+	_, srvs, err := l.LookupSRV(ctx, service, proto, name)
+	if err != nil {
+		return nil, err
+	}
+	SortSRV(srvs)
+	return srvs, nil
+}
+
+// SortSRV sorts the SRV records by the priority ascending and then by the weight descending.
+func SortSRV(srvs []*net.SRV) {
+	sort.SliceStable(srvs, func(i, j int) bool {
+		if srvs[i].Priority != srvs[j].Priority {
+			return srvs[i].Priority < srvs[j].Priority
+		}
+		return srvs[i].Weight > srvs[j].Weight
+	})
+}
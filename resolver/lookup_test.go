@@ -0,0 +1,197 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recordServer is a resolver answering the records of a fake zone, the names out of the zone fail.
+type recordServer struct {
+	staticResolver
+	srvs []*net.SRV
+	txts []string
+	mxs  []*net.MX
+}
+
+func (s *recordServer) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	if name != "example.com" {
+		return "", nil, &net.DNSError{Err: "server misbehaving", Name: name}
+	}
+	// a copy, so the sorting does not change the zone.
+	return "_" + service + "._" + proto + ".example.com.", append([]*net.SRV(nil), s.srvs...), nil
+}
+
+func (s *recordServer) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	if name != "example.com" {
+		return nil, &net.DNSError{Err: "server misbehaving", Name: name}
+	}
+	return s.txts, nil
+}
+
+func (s *recordServer) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	if name != "example.com" {
+		return nil, &net.DNSError{Err: "server misbehaving", Name: name}
+	}
+	return s.mxs, nil
+}
+
+func newRecordServer() *recordServer {
+	return &recordServer{
+		srvs: []*net.SRV{
+			{Target: "c.example.com.", Port: 8080, Priority: 20, Weight: 5},
+			{Target: "a.example.com.", Port: 8080, Priority: 10, Weight: 10},
+			{Target: "d.example.com.", Port: 8081, Priority: 10, Weight: 60},
+			{Target: "b.example.com.", Port: 8080, Priority: 10, Weight: 30},
+		},
+		txts: []string{"v=spf1 -all"},
+		mxs:  []*net.MX{{Host: "mx.example.com.", Pref: 10}},
+	}
+}
+
+func srvTargets(srvs []*net.SRV) string {
+	var targets []string
+	for _, srv := range srvs {
+		targets = append(targets, srv.Target)
+	}
+	return strings.Join(targets, " ")
+}
+
+func TestLookupSRV(t *testing.T) {
+	srvs, err := LookupSRV(context.Background(), newRecordServer(), "http", "tcp", "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// ordered by the priority ascending and then by the weight descending.
+	if got, want := srvTargets(srvs), "d.example.com. b.example.com. a.example.com. c.example.com."; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	for _, srv := range srvs {
+		if srv.Target == "d.example.com." && (srv.Port != 8081 || srv.Priority != 10 || srv.Weight != 60) {
+			t.Fatalf("unexpected record %+v", srv)
+		}
+	}
+
+	// the resolver without the record lookups does not fall back to the system resolver.
+	if _, err := LookupSRV(context.Background(), &staticResolver{}, "http", "tcp", "example.com"); !errors.Is(err, ErrRecordLookupUnsupported) {
+		t.Fatalf("expected ErrRecordLookupUnsupported, got %v", err)
+	}
+}
+
+// wrappers returns the wrapper resolvers of r.
+func wrappers(t *testing.T, r Resolver) map[string]Resolver {
+	t.Helper()
+	dns64, err := DNS64Resolver(r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return map[string]Resolver{
+		"cache":    CacheResolver(r),
+		"dns64":    dns64,
+		"dnssec":   DNSSECResolver(r, []string{"secure.example"}),
+		"fallback": FallbackResolver(r, false),
+		"filter":   FilterResolver(r),
+		"hedge":    HedgeResolver(r, nil, time.Second),
+		"metrics":  MetricsResolver(r, newFakeMetrics(), "dns1"),
+		"order":    OrderResolver(r, OrderRFC6724),
+		"search":   SearchResolver(r, SearchDomainsOption("example.org"), NdotsOption(1)),
+		"chained":  CacheResolver(FilterResolver(MetricsResolver(r, newFakeMetrics(), "dns1"))),
+	}
+}
+
+func TestRecordLookupForwarded(t *testing.T) {
+	for name, r := range wrappers(t, newRecordServer()) {
+		srvs, err := LookupSRV(context.Background(), r, "http", "tcp", "example.com")
+		if err != nil || len(srvs) != 4 || srvs[0].Target != "d.example.com." {
+			t.Errorf("%s: unexpected SRV records %v %v", name, srvTargets(srvs), err)
+		}
+		l := r.(RecordLookuper)
+		if txts, err := l.LookupTXT(context.Background(), "example.com"); err != nil || len(txts) != 1 || txts[0] != "v=spf1 -all" {
+			t.Errorf("%s: unexpected TXT records %v %v", name, txts, err)
+		}
+		if mxs, err := l.LookupMX(context.Background(), "example.com"); err != nil || len(mxs) != 1 || mxs[0].Host != "mx.example.com." {
+			t.Errorf("%s: unexpected MX records %v %v", name, mxs, err)
+		}
+	}
+
+	// the wrapped resolver without the record lookups fails.
+	for name, r := range wrappers(t, &staticResolver{}) {
+		if _, err := LookupSRV(context.Background(), r, "http", "tcp", "example.com"); !errors.Is(err, ErrRecordLookupUnsupported) {
+			t.Errorf("%s: expected ErrRecordLookupUnsupported, got %v", name, err)
+		}
+		if _, err := r.(RecordLookuper).LookupTXT(context.Background(), "example.com"); !errors.Is(err, ErrRecordLookupUnsupported) {
+			t.Errorf("%s: expected ErrRecordLookupUnsupported, got %v", name, err)
+		}
+	}
+}
+
+func TestRecordLookupDNSSEC(t *testing.T) {
+	r := DNSSECResolver(newRecordServer(), []string{"example.com"}).(RecordLookuper)
+
+	// the records of the secure zone can not be authenticated.
+	if _, _, err := r.LookupSRV(context.Background(), "http", "tcp", "example.com"); !errors.Is(err, ErrNotAuthenticated) {
+		t.Fatalf("expected ErrNotAuthenticated, got %v", err)
+	}
+	if _, err := r.LookupTXT(context.Background(), "example.com"); !errors.Is(err, ErrNotAuthenticated) {
+		t.Fatalf("expected ErrNotAuthenticated, got %v", err)
+	}
+	if _, err := r.LookupMX(context.Background(), "EXAMPLE.com."); !errors.Is(err, ErrNotAuthenticated) {
+		t.Fatalf("expected ErrNotAuthenticated, got %v", err)
+	}
+}
+
+func TestRecordLookupHedge(t *testing.T) {
+	// the secondary is queried if the primary fails.
+	r := HedgeResolver(&staticResolver{}, newRecordServer(), time.Hour)
+	if srvs, err := LookupSRV(context.Background(), r, "http", "tcp", "example.com"); err != nil || len(srvs) != 4 {
+		t.Fatalf("expected the records of the secondary, got %v %v", srvTargets(srvs), err)
+	}
+	if mxs, err := r.(RecordLookuper).LookupMX(context.Background(), "example.com"); err != nil || len(mxs) != 1 {
+		t.Fatalf("expected the records of the secondary, got %v %v", mxs, err)
+	}
+
+	r = HedgeResolver(newRecordServer(), newRecordServer(), time.Hour)
+	var dnsErr *net.DNSError
+	if _, err := r.(RecordLookuper).LookupTXT(context.Background(), "example.org"); !errors.As(err, &dnsErr) {
+		t.Fatalf("expected the error of the secondary, got %v", err)
+	}
+}
+
+func TestRecordLookupMetrics(t *testing.T) {
+	m := newFakeMetrics()
+	r := MetricsResolver(newRecordServer(), m, "dns1").(RecordLookuper)
+
+	r.LookupSRV(context.Background(), "http", "tcp", "example.com")
+	r.LookupTXT(context.Background(), "example.org")
+	r.LookupMX(context.Background(), "example.com")
+
+	for key, want := range map[string]float64{
+		"gost_resolver_requests_total{qtype=SRV,rcode=NOERROR,upstream=dns1}":  1,
+		"gost_resolver_requests_total{qtype=TXT,rcode=SERVFAIL,upstream=dns1}": 1,
+		"gost_resolver_requests_total{qtype=MX,rcode=NOERROR,upstream=dns1}":   1,
+	} {
+		if got := m.counter(key); got != want {
+			t.Errorf("%s = %v, want %v", key, got, want)
+		}
+	}
+}
+
+func TestRecordLookupFallbackDisabled(t *testing.T) {
+	// the failure is returned as is without the fallback.
+	r := FallbackResolver(newRecordServer(), false).(RecordLookuper)
+	var dnsErr *net.DNSError
+	if _, err := r.LookupTXT(context.Background(), "example.org"); !errors.As(err, &dnsErr) || dnsErr.Name != "example.org" {
+		t.Fatalf("expected the upstream error, got %v", err)
+	}
+
+	// the caller gives up, there is no fallback either.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r = FallbackResolver(&staticResolver{}, true).(RecordLookuper)
+	if _, err := r.LookupMX(ctx, "example.com"); !errors.Is(err, ErrRecordLookupUnsupported) {
+		t.Fatalf("expected the upstream error, got %v", err)
+	}
+}
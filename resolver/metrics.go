@@ -0,0 +1,146 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/go-gost/core/metrics"
+)
+
+const (
+	RcodeNoError  = "NOERROR"
+	RcodeNXDomain = "NXDOMAIN"
+	RcodeServFail = "SERVFAIL"
+)
+
+type metricsResolver struct {
+	resolver Resolver
+	metrics  metrics.Metrics
+	upstream string
+}
+
+// MetricsResolver wraps the resolver r to report the query count by rcode, latency and timeouts to m.
+// The labels are bounded to the upstream name and query type.
+func MetricsResolver(r Resolver, m metrics.Metrics, upstream string) Resolver {
+	if m == nil {
+		return r
+	}
+	return &metricsResolver{
+		resolver: r,
+		metrics:  m,
+		upstream: upstream,
+	}
+}
+
+func (r *metricsResolver) Resolve(ctx context.Context, network, host string, opts ...Option) ([]net.IP, error) {
+	start := time.Now()
+	ips, err := r.resolver.Resolve(ctx, network, host, opts...)
+	r.observe(QueryType(network), start, err)
+	return ips, err
+}
+
+// observe records the metrics of the query of qtype started at start, err is the result of the query.
+func (r *metricsResolver) observe(qtype string, start time.Time, err error) {
+	labels := metrics.Labels{
+		"upstream": r.upstream,
+		"qtype":    qtype,
+	}
+	if v := r.metrics.Observer(metrics.MetricResolverRequestDurationObserver, labels); v != nil {
+		v.Observe(time.Since(start).Seconds())
+	}
+	if isTimeout(err) {
+		if v := r.metrics.Counter(metrics.MetricResolverTimeoutsCounter, labels); v != nil {
+			v.Inc()
+		}
+	}
+	if v := r.metrics.Counter(metrics.MetricResolverRequestsCounter, metrics.Labels{
+		"upstream": r.upstream,
+		"qtype":    qtype,
+		"rcode":    Rcode(err),
+	}); v != nil {
+		v.Inc()
+	}
+}
+
+func (r *metricsResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	start := time.Now()
+	cname, srvs, err := recordLookuper(r.resolver).LookupSRV(ctx, service, proto, name)
+	r.observe("SRV", start, err)
+	return cname, srvs, err
+}
+
+func (r *metricsResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	start := time.Now()
+	txts, err := recordLookuper(r.resolver).LookupTXT(ctx, name)
+	r.observe("TXT", start, err)
+	return txts, err
+}
+
+func (r *metricsResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	start := time.Now()
+	mxs, err := recordLookuper(r.resolver).LookupMX(ctx, name)
+	r.observe("MX", start, err)
+	return mxs, err
+}
+
+// QueryType maps the resolve network to the DNS query type.
+func QueryType(network string) string {
+	switch network {
+	case "ip4":
+		return "A"
+	case "ip6":
+		return "AAAA"
+	default:
+		return "A+AAAA"
+	}
+}
+
+// Rcode maps the resolve error to the DNS response code.
+func Rcode(err error) string {
+	if err == nil {
+		return RcodeNoError
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		return RcodeNXDomain
+	}
+	return RcodeServFail
+}
+
+func isTimeout(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
+
+// ObserveCache reports a cache lookup result of a caching resolver to m, see MetricsCacheOption.
+func ObserveCache(m metrics.Metrics, upstream string, hit bool) {
+	if m == nil {
+		return
+	}
+
+	name := metrics.MetricResolverCacheMissesCounter
+	if hit {
+		name = metrics.MetricResolverCacheHitsCounter
+	}
+	if v := m.Counter(name, metrics.Labels{"upstream": upstream}); v != nil {
+		v.Inc()
+	}
+}
+
+// ObserveCacheStale reports an expired answer served by a caching resolver to m.
+func ObserveCacheStale(m metrics.Metrics, upstream string) {
+	if m == nil {
+		return
+	}
+	if v := m.Counter(metrics.MetricResolverCacheStaleCounter, metrics.Labels{"upstream": upstream}); v != nil {
+		v.Inc()
+	}
+}
@@ -0,0 +1,179 @@
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-gost/core/common/clock"
+	"github.com/go-gost/core/metrics"
+)
+
+// fakeMetrics records the counters and observations by the metric name and labels.
+type fakeMetrics struct {
+	counters     map[string]float64
+	observations map[string][]float64
+	mu           sync.Mutex
+}
+
+func newFakeMetrics() *fakeMetrics {
+	return &fakeMetrics{
+		counters:     make(map[string]float64),
+		observations: make(map[string][]float64),
+	}
+}
+
+func metricKey(name metrics.MetricName, labels metrics.Labels) string {
+	var kvs []string
+	for k, v := range labels {
+		kvs = append(kvs, k+"="+v)
+	}
+	sort.Strings(kvs)
+	return fmt.Sprintf("%s{%s}", name, strings.Join(kvs, ","))
+}
+
+func (m *fakeMetrics) Counter(name metrics.MetricName, labels metrics.Labels) metrics.Counter {
+	return &fakeCounter{m: m, key: metricKey(name, labels)}
+}
+
+func (m *fakeMetrics) Gauge(name metrics.MetricName, labels metrics.Labels) metrics.Gauge {
+	return nil
+}
+
+func (m *fakeMetrics) Observer(name metrics.MetricName, labels metrics.Labels) metrics.Observer {
+	return &fakeObserver{m: m, key: metricKey(name, labels)}
+}
+
+func (m *fakeMetrics) counter(key string) float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.counters[key]
+}
+
+type fakeCounter struct {
+	m   *fakeMetrics
+	key string
+}
+
+func (c *fakeCounter) Inc() {
+	c.Add(1)
+}
+
+func (c *fakeCounter) Add(v float64) {
+	c.m.mu.Lock()
+	defer c.m.mu.Unlock()
+	c.m.counters[c.key] += v
+}
+
+type fakeObserver struct {
+	m   *fakeMetrics
+	key string
+}
+
+func (o *fakeObserver) Observe(v float64) {
+	o.m.mu.Lock()
+	defer o.m.mu.Unlock()
+	o.m.observations[o.key] = append(o.m.observations[o.key], v)
+}
+
+// funcResolver resolves by the function.
+type funcResolver func(ctx context.Context, network, host string) ([]net.IP, error)
+
+func (f funcResolver) Resolve(ctx context.Context, network, host string, opts ...Option) ([]net.IP, error) {
+	return f(ctx, network, host)
+}
+
+func TestMetricsResolver(t *testing.T) {
+	upstream := funcResolver(func(ctx context.Context, network, host string) ([]net.IP, error) {
+		switch host {
+		case "example.com":
+			return parseIPs("93.184.216.34"), nil
+		case "slow.example.com":
+			<-ctx.Done()
+			return nil, ctx.Err()
+		case "missing.example.com":
+			return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+		default:
+			return nil, &net.DNSError{Err: "server misbehaving", Name: host}
+		}
+	})
+	m := newFakeMetrics()
+	r := MetricsResolver(upstream, m, "dns1")
+
+	ctx := context.Background()
+	r.Resolve(ctx, "ip4", "example.com")
+	r.Resolve(ctx, "ip4", "example.com")
+	r.Resolve(ctx, "ip6", "missing.example.com")
+	r.Resolve(ctx, "ip", "broken.example.com")
+	tctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	r.Resolve(tctx, "ip4", "slow.example.com")
+	cancel()
+
+	for key, want := range map[string]float64{
+		"gost_resolver_requests_total{qtype=A,rcode=NOERROR,upstream=dns1}":       2,
+		"gost_resolver_requests_total{qtype=AAAA,rcode=NXDOMAIN,upstream=dns1}":   1,
+		"gost_resolver_requests_total{qtype=A+AAAA,rcode=SERVFAIL,upstream=dns1}": 1,
+		"gost_resolver_requests_total{qtype=A,rcode=SERVFAIL,upstream=dns1}":      1,
+		"gost_resolver_timeouts_total{qtype=A,upstream=dns1}":                     1,
+	} {
+		if got := m.counter(key); got != want {
+			t.Errorf("%s = %v, want %v", key, got, want)
+		}
+	}
+
+	latencies := m.observations["gost_resolver_request_duration_seconds{qtype=A,upstream=dns1}"]
+	if len(latencies) != 3 {
+		t.Fatalf("expected 3 latency observations, got %v", latencies)
+	}
+	if latencies[2] < 0.01 {
+		t.Fatalf("latency of the timed out query is %v", latencies[2])
+	}
+}
+
+func TestCacheResolverMetrics(t *testing.T) {
+	m := newFakeMetrics()
+	c := clock.NewFakeClock(time.Unix(1000, 0))
+	upstream := &switchResolver{ips: parseIPs("192.0.2.1")}
+	r := CacheResolver(upstream,
+		TTLCacheOption(time.Minute),
+		StaleTTLCacheOption(time.Hour),
+		ClockCacheOption(c),
+		MetricsCacheOption(m, "dns1"))
+
+	// a miss and two hits.
+	for i := 0; i < 3; i++ {
+		r.Resolve(context.Background(), "ip", "example.com")
+	}
+	// the IP is not a cache lookup.
+	r.Resolve(context.Background(), "ip", "192.0.2.10")
+
+	// the upstream is down, the expired answer is served as stale after the miss,
+	// then from the cache within the stale refresh interval.
+	upstream.set(true)
+	c.Advance(2 * time.Minute)
+	r.Resolve(context.Background(), "ip", "example.com")
+	r.Resolve(context.Background(), "ip", "example.com")
+
+	hits := m.counter("gost_resolver_cache_hits_total{upstream=dns1}")
+	misses := m.counter("gost_resolver_cache_misses_total{upstream=dns1}")
+	stale := m.counter("gost_resolver_cache_stale_total{upstream=dns1}")
+	if hits != 3 || misses != 2 || stale != 2 {
+		t.Fatalf("hits %v misses %v stale %v", hits, misses, stale)
+	}
+
+	// nil metrics is a no-op.
+	r = CacheResolver(upstream, MetricsCacheOption(nil, "dns1"))
+	r.Resolve(context.Background(), "ip", "example.com")
+}
+
+func TestMetricsResolverNil(t *testing.T) {
+	r := &staticResolver{}
+	if MetricsResolver(r, nil, "dns1") != Resolver(r) {
+		t.Fatal("nil metrics should not wrap the resolver")
+	}
+}
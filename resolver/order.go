@@ -0,0 +1,183 @@
+package resolver
+
+import (
+	"context"
+	"math/rand"
+	"net"
+	"net/netip"
+	"sort"
+	"sync"
+)
+
+// OrderPolicy is the ordering policy of the multi-address answers,
+// which decides the address the dialers try first.
+type OrderPolicy string
+
+const (
+	// OrderAsReceived keeps the answers in the order from the upstream.
+	OrderAsReceived OrderPolicy = "received"
+	// OrderShuffle randomizes the answers per query for a crude load balancing.
+	OrderShuffle OrderPolicy = "shuffle"
+	// OrderRFC6724 sorts the answers by the destination address selection rules of RFC 6724.
+	OrderRFC6724 OrderPolicy = "rfc6724"
+)
+
+type OrderOptions struct {
+	// Rand is the source of the shuffling, it can be seeded for a deterministic order.
+	Rand *rand.Rand
+}
+
+type OrderOption func(opts *OrderOptions)
+
+func RandOrderOption(r *rand.Rand) OrderOption {
+	return func(opts *OrderOptions) {
+		opts.Rand = r
+	}
+}
+
+type orderResolver struct {
+	resolver Resolver
+	policy   OrderPolicy
+	rand     *rand.Rand
+	mu       sync.Mutex
+}
+
+// OrderResolver wraps the resolver r and orders the answers by the policy.
+func OrderResolver(r Resolver, policy OrderPolicy, opts ...OrderOption) Resolver {
+	var options OrderOptions
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+
+	return &orderResolver{
+		resolver: r,
+		policy:   policy,
+		rand:     options.Rand,
+	}
+}
+
+func (r *orderResolver) Resolve(ctx context.Context, network, host string, opts ...Option) ([]net.IP, error) {
+	ips, err := r.resolver.Resolve(ctx, network, host, opts...)
+	if err != nil || len(ips) < 2 {
+		return ips, err
+	}
+
+	result := copyIPs(ips)
+	switch r.policy {
+	case OrderShuffle:
+		r.shuffle(result)
+	case OrderRFC6724:
+		SortRFC6724(result)
+	}
+	return result, nil
+}
+
+func (r *orderResolver) shuffle(ips []net.IP) {
+	swap := func(i, j int) {
+		ips[i], ips[j] = ips[j], ips[i]
+	}
+	if r.rand == nil {
+		rand.Shuffle(len(ips), swap)
+		return
+	}
+
+	// rand.Rand is not safe for concurrent use.
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rand.Shuffle(len(ips), swap)
+}
+
+type policyEntry struct {
+	prefix     netip.Prefix
+	precedence int
+}
+
+// policyTable is the default policy table of RFC 6724 section 2.1, in the descending order of the prefix length.
+var policyTable = []policyEntry{
+	{netip.MustParsePrefix("::1/128"), 50},
+	{netip.MustParsePrefix("::ffff:0:0/96"), 35},
+	{netip.MustParsePrefix("::/96"), 1},
+	{netip.MustParsePrefix("2001::/32"), 5},
+	{netip.MustParsePrefix("2002::/16"), 30},
+	{netip.MustParsePrefix("3ffe::/16"), 1},
+	{netip.MustParsePrefix("fec0::/10"), 1},
+	{netip.MustParsePrefix("fc00::/7"), 3},
+	{netip.MustParsePrefix("::/0"), 40},
+}
+
+const (
+	scopeLinkLocal = 0x2
+	scopeSiteLocal = 0x5
+	scopeGlobal    = 0xe
+)
+
+func precedence(addr netip.Addr) int {
+	if addr.Is4() {
+		addr = netip.AddrFrom16(addr.As16())
+	}
+	for _, e := range policyTable {
+		if e.prefix.Contains(addr) {
+			return e.precedence
+		}
+	}
+	return 0
+}
+
+func scope(addr netip.Addr) int {
+	switch {
+	case addr.IsLoopback(), addr.IsLinkLocalUnicast():
+		return scopeLinkLocal
+	case addr.Is6() && addr.IsMulticast():
+		return int(addr.As16()[1] & 0xf)
+	case addr.Is6() && addr.As16()[0] == 0xfe && addr.As16()[1]&0xc0 == 0xc0:
+		return scopeSiteLocal
+	default:
+		return scopeGlobal
+	}
+}
+
+// SortRFC6724 sorts the destination addresses in place by the rules of RFC 6724 section 6
+// which do not depend on the source addresses: the higher precedence (rule 6) and the smaller scope (rule 8) first,
+// otherwise the order is left unchanged (rule 10).
+func SortRFC6724(ips []net.IP) {
+	type item struct {
+		ip         net.IP
+		precedence int
+		scope      int
+	}
+
+	items := make([]item, len(ips))
+	for i, ip := range ips {
+		addr, _ := netip.AddrFromSlice(ip)
+		addr = addr.Unmap()
+		items[i] = item{
+			ip:         ip,
+			precedence: precedence(addr),
+			scope:      scope(addr),
+		}
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].precedence != items[j].precedence {
+			return items[i].precedence > items[j].precedence
+		}
+		return items[i].scope < items[j].scope
+	})
+	for i := range items {
+		ips[i] = items[i].ip
+	}
+}
+
+func (r *orderResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	return recordLookuper(r.resolver).LookupSRV(ctx, service, proto, name)
+}
+
+func (r *orderResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	return recordLookuper(r.resolver).LookupTXT(ctx, name)
+}
+
+func (r *orderResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	return recordLookuper(r.resolver).LookupMX(ctx, name)
+}
@@ -0,0 +1,102 @@
+package resolver
+
+import (
+	"context"
+	"math/rand"
+	"net"
+	"sort"
+	"strings"
+	"testing"
+)
+
+var orderAnswer = []string{"192.0.2.1", "2001:0:4136:e378::1", "2606:4700::1", "::1", "fe80::1", "10.0.0.1"}
+
+func ipStrings(ips []net.IP) string {
+	var ss []string
+	for _, ip := range ips {
+		ss = append(ss, ip.String())
+	}
+	return strings.Join(ss, " ")
+}
+
+func TestOrderResolverAsReceived(t *testing.T) {
+	upstream := &staticResolver{ips: parseIPs(orderAnswer...)}
+	r := OrderResolver(upstream, OrderAsReceived)
+
+	ips, err := r.Resolve(context.Background(), "ip", "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ipStrings(ips), strings.Join(orderAnswer, " "); got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+func TestOrderResolverShuffle(t *testing.T) {
+	upstream := &staticResolver{ips: parseIPs(orderAnswer...)}
+	r1 := OrderResolver(upstream, OrderShuffle, RandOrderOption(rand.New(rand.NewSource(1))))
+	r2 := OrderResolver(upstream, OrderShuffle, RandOrderOption(rand.New(rand.NewSource(1))))
+
+	orders := make(map[string]bool)
+	for i := 0; i < 20; i++ {
+		ips1, _ := r1.Resolve(context.Background(), "ip", "example.com")
+		ips2, _ := r2.Resolve(context.Background(), "ip", "example.com")
+
+		// deterministic under the seeded source.
+		if ipStrings(ips1) != ipStrings(ips2) {
+			t.Fatalf("query %d: %s != %s", i, ipStrings(ips1), ipStrings(ips2))
+		}
+		orders[ipStrings(ips1)] = true
+
+		// the same addresses in any order.
+		got := strings.Fields(ipStrings(ips1))
+		want := append([]string(nil), orderAnswer...)
+		sort.Strings(got)
+		sort.Strings(want)
+		if strings.Join(got, " ") != strings.Join(want, " ") {
+			t.Fatalf("query %d: unexpected addresses %v", i, got)
+		}
+	}
+	if len(orders) < 2 {
+		t.Fatal("the answers should be shuffled per query")
+	}
+
+	// the upstream answer is not modified.
+	if ipStrings(upstream.ips) != strings.Join(orderAnswer, " ") {
+		t.Fatalf("the upstream answer is modified: %s", ipStrings(upstream.ips))
+	}
+}
+
+func TestOrderResolverRFC6724(t *testing.T) {
+	upstream := &staticResolver{ips: parseIPs(orderAnswer...)}
+	r := OrderResolver(upstream, OrderRFC6724)
+
+	ips, err := r.Resolve(context.Background(), "ip", "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// loopback (precedence 50), then the native IPv6 (40) with the smaller scope first,
+	// the IPv4 (35) in the received order, and Teredo (5) last.
+	want := "::1 fe80::1 2606:4700::1 192.0.2.1 10.0.0.1 2001:0:4136:e378::1"
+	if got := ipStrings(ips); got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+func TestSortRFC6724(t *testing.T) {
+	for _, tc := range []struct {
+		ips  []string
+		want string
+	}{
+		{[]string{"192.0.2.1", "2606:4700::1"}, "2606:4700::1 192.0.2.1"},
+		{[]string{"fd00::1", "192.0.2.1"}, "192.0.2.1 fd00::1"},
+		{[]string{"2002:c000:201::1", "192.0.2.1"}, "192.0.2.1 2002:c000:201::1"},
+		{[]string{"2606:4700::1", "169.254.0.1", "127.0.0.1"}, "2606:4700::1 169.254.0.1 127.0.0.1"},
+	} {
+		ips := parseIPs(tc.ips...)
+		SortRFC6724(ips)
+		if got := ipStrings(ips); got != tc.want {
+			t.Errorf("%v: got %s, want %s", tc.ips, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package resolver
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultResolvConf = "/etc/resolv.conf"
+	defaultNdots      = 1
+)
+
+type SearchOptions struct {
+	// Search is the search domain list, default is loaded from /etc/resolv.conf.
+	Search []string
+	// Ndots is the threshold of dots in a name to be tried as absolute first, default is loaded from /etc/resolv.conf.
+	Ndots int
+}
+
+type SearchOption func(opts *SearchOptions)
+
+func SearchDomainsOption(domains ...string) SearchOption {
+	return func(opts *SearchOptions) {
+		opts.Search = domains
+	}
+}
+
+func NdotsOption(ndots int) SearchOption {
+	return func(opts *SearchOptions) {
+		opts.Ndots = ndots
+	}
+}
+
+type searchResolver struct {
+	resolver Resolver
+	options  SearchOptions
+}
+
+// SearchResolver wraps the resolver r to apply the search domains and ndots rules
+// of resolv.conf when resolving short names.
+// The search domains and ndots are loaded from /etc/resolv.conf if not set by options.
+func SearchResolver(r Resolver, opts ...SearchOption) Resolver {
+	options := SearchOptions{
+		Ndots: -1,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+
+	if options.Search == nil || options.Ndots < 0 {
+		search, ndots := loadResolvConf(defaultResolvConf)
+		if options.Search == nil {
+			options.Search = search
+		}
+		if options.Ndots < 0 {
+			options.Ndots = ndots
+		}
+	}
+
+	return &searchResolver{
+		resolver: r,
+		options:  options,
+	}
+}
+
+func (r *searchResolver) Resolve(ctx context.Context, network, host string, opts ...Option) (ips []net.IP, err error) {
+	for _, name := range r.Candidates(host) {
+		ips, err = r.resolver.Resolve(ctx, network, name, opts...)
+		if err == nil && len(ips) > 0 {
+			return
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+	}
+	return
+}
+
+// Candidates returns the fully-qualified names to try for host in order.
+func (r *searchResolver) Candidates(host string) []string {
+	if host == "" || net.ParseIP(host) != nil {
+		return []string{host}
+	}
+
+	// absolute name
+	if strings.HasSuffix(host, ".") {
+		return []string{strings.TrimSuffix(host, ".")}
+	}
+
+	var names []string
+	for _, domain := range r.options.Search {
+		domain = strings.Trim(domain, ".")
+		if domain != "" {
+			names = append(names, host+"."+domain)
+		}
+	}
+
+	if strings.Count(host, ".") >= r.options.Ndots {
+		return append([]string{host}, names...)
+	}
+	return append(names, host)
+}
+
+func loadResolvConf(filename string) (search []string, ndots int) {
+	ndots = defaultNdots
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexAny(line, "#;"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+
+		switch fields[0] {
+		case "domain":
+			// the last of domain and search wins.
+			search = []string{fields[1]}
+		case "search":
+			search = append([]string{}, fields[1:]...)
+		case "options":
+			for _, opt := range fields[1:] {
+				if s, ok := strings.CutPrefix(opt, "ndots:"); ok {
+					if n, err := strconv.Atoi(s); err == nil && n >= 0 {
+						ndots = min(n, 15)
+					}
+				}
+			}
+		}
+	}
+	return
+}
+
+func (r *searchResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	return recordLookuper(r.resolver).LookupSRV(ctx, service, proto, name)
+}
+
+func (r *searchResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	return recordLookuper(r.resolver).LookupTXT(ctx, name)
+}
+
+func (r *searchResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	return recordLookuper(r.resolver).LookupMX(ctx, name)
+}
@@ -0,0 +1,99 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestSearchResolverCandidates(t *testing.T) {
+	r := SearchResolver(nil,
+		SearchDomainsOption("default.svc.cluster.local", "svc.cluster.local.", "cluster.local"),
+		NdotsOption(5),
+	).(*searchResolver)
+
+	for _, tc := range []struct {
+		host string
+		want []string
+	}{
+		{host: "api", want: []string{
+			"api.default.svc.cluster.local", "api.svc.cluster.local", "api.cluster.local", "api",
+		}},
+		{host: "api.prod", want: []string{
+			"api.prod.default.svc.cluster.local", "api.prod.svc.cluster.local", "api.prod.cluster.local", "api.prod",
+		}},
+		{host: "example.com.", want: []string{"example.com"}},
+		{host: "192.0.2.1", want: []string{"192.0.2.1"}},
+	} {
+		if got := r.Candidates(tc.host); !slices.Equal(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.host, got, tc.want)
+		}
+	}
+
+	// the names with at least ndots dots are tried as is first.
+	r = SearchResolver(nil, SearchDomainsOption("corp.example"), NdotsOption(1)).(*searchResolver)
+	if got := r.Candidates("www.example.com"); !slices.Equal(got, []string{"www.example.com", "www.example.com.corp.example"}) {
+		t.Errorf("got %v", got)
+	}
+	if got := r.Candidates("intranet"); !slices.Equal(got, []string{"intranet.corp.example", "intranet"}) {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestSearchResolverResolve(t *testing.T) {
+	var tried []string
+	inner := funcResolver(func(ctx context.Context, network, host string) ([]net.IP, error) {
+		tried = append(tried, host)
+		if host == "api.svc.cluster.local" {
+			return parseIPs("10.0.0.1"), nil
+		}
+		return nil, errors.New("no such host")
+	})
+	r := SearchResolver(inner,
+		SearchDomainsOption("default.svc.cluster.local", "svc.cluster.local", "cluster.local"),
+		NdotsOption(5),
+	)
+
+	ips, err := r.Resolve(context.Background(), "ip", "api")
+	if err != nil || len(ips) != 1 || !ips[0].Equal(net.ParseIP("10.0.0.1")) {
+		t.Fatalf("unexpected result %v %v", ips, err)
+	}
+	if !slices.Equal(tried, []string{"api.default.svc.cluster.local", "api.svc.cluster.local"}) {
+		t.Fatalf("unexpected candidates tried %v", tried)
+	}
+
+	tried = nil
+	if _, err := r.Resolve(context.Background(), "ip", "missing"); err == nil {
+		t.Fatal("expected error")
+	}
+	if len(tried) != 4 || tried[3] != "missing" {
+		t.Fatalf("all the candidates should be tried, got %v", tried)
+	}
+}
+
+func TestLoadResolvConf(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "resolv.conf")
+	data := `# generated
+nameserver 10.96.0.10
+domain example.com
+search default.svc.cluster.local svc.cluster.local ; comment
+options ndots:5 timeout:2
+`
+	if err := os.WriteFile(filename, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	search, ndots := loadResolvConf(filename)
+	if !slices.Equal(search, []string{"default.svc.cluster.local", "svc.cluster.local"}) || ndots != 5 {
+		t.Fatalf("unexpected search %v ndots %d", search, ndots)
+	}
+
+	search, ndots = loadResolvConf(filepath.Join(t.TempDir(), "missing"))
+	if search != nil || ndots != defaultNdots {
+		t.Fatalf("unexpected defaults %v %d", search, ndots)
+	}
+}
@@ -0,0 +1,48 @@
+package routing
+
+// Rule is a named routing rule with the action taken when its matcher matches.
+type Rule struct {
+	Name    string
+	Matcher Matcher
+	Action  string
+}
+
+// RuleTrace is the evaluation result of a rule in an Explanation.
+type RuleTrace struct {
+	Name    string `json:"name"`
+	Action  string `json:"action"`
+	Matched bool   `json:"matched"`
+}
+
+// Explanation is the decision trace of the routing rules for a request.
+type Explanation struct {
+	// Rules are all the evaluated rules in order.
+	Rules []RuleTrace `json:"rules"`
+	// Rule is the name of the first matched rule, which decides the action.
+	Rule string `json:"rule,omitempty"`
+	// Action is the final action, it is empty if no rule matches.
+	Action  string `json:"action,omitempty"`
+	Matched bool   `json:"matched"`
+}
+
+// Explain evaluates all the rules against the sample request without performing any connection,
+// the first matched rule wins, as the routing does. It is for validating the rule changes.
+func Explain(req *Request, rules ...Rule) Explanation {
+	ex := Explanation{
+		Rules: make([]RuleTrace, 0, len(rules)),
+	}
+	for _, rule := range rules {
+		matched := rule.Matcher != nil && rule.Matcher.Match(req)
+		ex.Rules = append(ex.Rules, RuleTrace{
+			Name:    rule.Name,
+			Action:  rule.Action,
+			Matched: matched,
+		})
+		if matched && !ex.Matched {
+			ex.Matched = true
+			ex.Rule = rule.Name
+			ex.Action = rule.Action
+		}
+	}
+	return ex
+}
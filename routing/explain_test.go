@@ -0,0 +1,42 @@
+package routing
+
+import "testing"
+
+func TestExplain(t *testing.T) {
+	rules := []Rule{
+		{Name: "broken", Action: "reject"},
+		{Name: "internal", Matcher: SNIMatcher("*.internal.example.com"), Action: "direct"},
+		{Name: "api", Matcher: SNIMatcher("api.example.com", ".example.com"), Action: "proxy-a"},
+		{Name: "example", Matcher: SNIMatcher(".example.com"), Action: "proxy-b"},
+	}
+
+	for _, tc := range []struct {
+		req     Request
+		matched []bool
+		rule    string
+		action  string
+	}{
+		// the first matched rule wins, the later matches are still listed.
+		{Request{SNI: "api.example.com"}, []bool{false, false, true, true}, "api", "proxy-a"},
+		{Request{SNI: "db.internal.example.com", Host: "10.0.0.1:443"}, []bool{false, true, true, true}, "internal", "direct"},
+		{Request{Host: "www.example.com:443", Protocol: "http"}, []bool{false, false, true, true}, "api", "proxy-a"},
+		{Request{SNI: "example.org"}, []bool{false, false, false, false}, "", ""},
+	} {
+		ex := Explain(&tc.req, rules...)
+		if len(ex.Rules) != len(rules) {
+			t.Fatalf("%+v: expected all the rules listed, got %+v", tc.req, ex.Rules)
+		}
+		for i, rt := range ex.Rules {
+			if rt.Name != rules[i].Name || rt.Action != rules[i].Action || rt.Matched != tc.matched[i] {
+				t.Errorf("%+v: unexpected trace %+v, want matched %v", tc.req, rt, tc.matched[i])
+			}
+		}
+		if ex.Rule != tc.rule || ex.Action != tc.action || ex.Matched != (tc.rule != "") {
+			t.Errorf("%+v: got rule %q action %q, want %q %q", tc.req, ex.Rule, ex.Action, tc.rule, tc.action)
+		}
+	}
+
+	if ex := Explain(&Request{SNI: "api.example.com"}); ex.Matched || len(ex.Rules) != 0 {
+		t.Fatalf("unexpected explanation without rules %+v", ex)
+	}
+}
@@ -0,0 +1,26 @@
+package routing
+
+import "strings"
+
+type ja3Matcher struct {
+	fingerprints map[string]struct{}
+}
+
+// JA3Matcher matches the requests from the TLS clients with any of the JA3 fingerprint hashes.
+func JA3Matcher(fingerprints ...string) Matcher {
+	m := make(map[string]struct{}, len(fingerprints))
+	for _, s := range fingerprints {
+		m[strings.ToLower(s)] = struct{}{}
+	}
+	return &ja3Matcher{
+		fingerprints: m,
+	}
+}
+
+func (m *ja3Matcher) Match(req *Request) bool {
+	if req == nil || req.JA3 == "" {
+		return false
+	}
+	_, ok := m.fingerprints[strings.ToLower(req.JA3)]
+	return ok
+}
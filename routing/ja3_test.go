@@ -0,0 +1,29 @@
+package routing
+
+import "testing"
+
+const (
+	botJA3   = "0f375cda5fca92c20fe61d8590c24346"
+	otherJA3 = "e7d705a3286e19ea42f587b344ee6865"
+)
+
+func TestJA3Matcher(t *testing.T) {
+	m := JA3Matcher("0F375CDA5FCA92C20FE61D8590C24346")
+	for _, tc := range []struct {
+		req  Request
+		want bool
+	}{
+		{req: Request{JA3: botJA3}, want: true},
+		// the fingerprints are case-insensitive.
+		{req: Request{JA3: "0F375cda5fca92c20fe61d8590c24346"}, want: true},
+		{req: Request{JA3: otherJA3}, want: false},
+		{req: Request{SNI: "example.com"}, want: false},
+	} {
+		if got := m.Match(&tc.req); got != tc.want {
+			t.Errorf("%+v: got %v, want %v", tc.req, got, tc.want)
+		}
+	}
+	if m.Match(nil) {
+		t.Error("nil request should not match")
+	}
+}
@@ -14,6 +14,10 @@ type Request struct {
 	Path     string
 	Query    url.Values
 	Header   http.Header
+	// SNI is the server name from the TLS ClientHello, it is set for the TLS pass-through connections.
+	SNI string
+	// JA3 is the JA3 fingerprint hash of the TLS client.
+	JA3 string
 }
 
 type Matcher interface {
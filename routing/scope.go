@@ -0,0 +1,36 @@
+package routing
+
+// LabelSelector selects the objects whose labels contain all the key-value pairs.
+type LabelSelector map[string]string
+
+// Matches reports whether labels satisfy the selector, an empty selector matches everything.
+func (s LabelSelector) Matches(labels map[string]string) bool {
+	for k, v := range s {
+		if lv, ok := labels[k]; !ok || lv != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Scoper is implemented by a Matcher whose route is balanced over a subset of the nodes.
+type Scoper interface {
+	Scope() LabelSelector
+}
+
+type scopedMatcher struct {
+	Matcher
+	selector LabelSelector
+}
+
+// ScopedMatcher associates the route matched by m with the nodes selected by sel.
+func ScopedMatcher(m Matcher, sel LabelSelector) Matcher {
+	return &scopedMatcher{
+		Matcher:  m,
+		selector: sel,
+	}
+}
+
+func (m *scopedMatcher) Scope() LabelSelector {
+	return m.selector
+}
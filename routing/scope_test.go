@@ -0,0 +1,45 @@
+package routing
+
+import "testing"
+
+// matcherFunc is an adapter to use a function as Matcher.
+type matcherFunc func(*Request) bool
+
+func (f matcherFunc) Match(req *Request) bool {
+	return f(req)
+}
+
+func TestLabelSelector(t *testing.T) {
+	labels := map[string]string{"pool": "a", "zone": "us"}
+	for _, tc := range []struct {
+		sel  LabelSelector
+		want bool
+	}{
+		{sel: nil, want: true},
+		{sel: LabelSelector{"pool": "a"}, want: true},
+		{sel: LabelSelector{"pool": "a", "zone": "us"}, want: true},
+		{sel: LabelSelector{"pool": "b"}, want: false},
+		{sel: LabelSelector{"pool": "a", "tier": "gold"}, want: false},
+	} {
+		if got := tc.sel.Matches(labels); got != tc.want {
+			t.Errorf("%v: got %v, want %v", tc.sel, got, tc.want)
+		}
+	}
+	if (LabelSelector{"pool": "a"}).Matches(nil) {
+		t.Error("non-empty selector should not match no labels")
+	}
+}
+
+func TestScopedMatcher(t *testing.T) {
+	m := ScopedMatcher(matcherFunc(func(req *Request) bool {
+		return req.Host == "a.example.com"
+	}), LabelSelector{"pool": "a"})
+
+	if !m.Match(&Request{Host: "a.example.com"}) || m.Match(&Request{Host: "b.example.com"}) {
+		t.Fatal("scoped matcher should match as the inner matcher")
+	}
+	scoper, ok := m.(Scoper)
+	if !ok || scoper.Scope()["pool"] != "a" {
+		t.Fatalf("expected the scope of pool a, got %v", scoper)
+	}
+}
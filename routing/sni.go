@@ -0,0 +1,62 @@
+package routing
+
+import (
+	"net"
+	"strings"
+)
+
+type sniMatcher struct {
+	exact  map[string]struct{}
+	suffix []string
+}
+
+// SNIMatcher matches the requests by the TLS server name against the domain patterns:
+// example.com matches the name exactly, *.example.com matches the subdomains of example.com,
+// and .example.com matches example.com and its subdomains. The names are case-insensitive.
+// If the request has no SNI, e.g. not a TLS connection, the host of the request is matched instead.
+func SNIMatcher(patterns ...string) Matcher {
+	m := &sniMatcher{
+		exact: make(map[string]struct{}),
+	}
+	for _, p := range patterns {
+		p = strings.ToLower(strings.TrimSpace(p))
+		switch {
+		case strings.HasPrefix(p, "*."):
+			m.suffix = append(m.suffix, p[1:])
+		case strings.HasPrefix(p, "."):
+			m.exact[p[1:]] = struct{}{}
+			m.suffix = append(m.suffix, p)
+		case p != "":
+			m.exact[p] = struct{}{}
+		}
+	}
+	return m
+}
+
+func (m *sniMatcher) Match(req *Request) bool {
+	if req == nil {
+		return false
+	}
+
+	name := req.SNI
+	if name == "" {
+		name = req.Host
+		if host, _, err := net.SplitHostPort(name); err == nil {
+			name = host
+		}
+	}
+	name = strings.TrimSuffix(strings.ToLower(name), ".")
+	if name == "" {
+		return false
+	}
+
+	if _, ok := m.exact[name]; ok {
+		return true
+	}
+	for _, suffix := range m.suffix {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,32 @@
+package routing
+
+import "testing"
+
+func TestSNIMatcher(t *testing.T) {
+	m := SNIMatcher("api.example.com", "*.internal.example.com", ".example.org")
+	for _, tc := range []struct {
+		req  Request
+		want bool
+	}{
+		{req: Request{SNI: "api.example.com", Host: "203.0.113.1:443"}, want: true},
+		{req: Request{SNI: "API.Example.COM."}, want: true},
+		{req: Request{SNI: "www.example.com", Host: "api.example.com"}, want: false},
+		{req: Request{SNI: "db.internal.example.com"}, want: true},
+		{req: Request{SNI: "internal.example.com"}, want: false},
+		{req: Request{SNI: "example.org"}, want: true},
+		{req: Request{SNI: "www.example.org"}, want: true},
+		{req: Request{SNI: "badexample.org"}, want: false},
+		// no SNI, the host is matched.
+		{req: Request{Host: "api.example.com:443"}, want: true},
+		{req: Request{Host: "www.example.org"}, want: true},
+		{req: Request{Host: "203.0.113.1:443"}, want: false},
+		{req: Request{}, want: false},
+	} {
+		if got := m.Match(&tc.req); got != tc.want {
+			t.Errorf("%+v: got %v, want %v", tc.req, got, tc.want)
+		}
+	}
+	if m.Match(nil) {
+		t.Error("nil request should not match")
+	}
+}
@@ -0,0 +1,127 @@
+package selector
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/go-gost/core/common/clock"
+	"github.com/go-gost/core/common/ctxvalue"
+)
+
+const (
+	defaultAntiAffinityTTL = time.Minute
+)
+
+type antiAffinityEntry struct {
+	used    map[string]struct{}
+	expires time.Time
+}
+
+type antiAffinityStrategy[T any] struct {
+	strategy Strategy[T]
+	ttl      time.Duration
+	clock    clock.Clock
+	entries  map[string]*antiAffinityEntry
+	swept    time.Time
+	mu       sync.Mutex
+}
+
+// AntiAffinityStrategy is a strategy to spread the related connections across distinct objects,
+// the inverse of the sticky selection. The connections are related by the affinity key in the context
+// (see ctxvalue.ContextWithAffinityKey), or the client IP if it is absent.
+// The strategy is applied to the candidates not yet used by the key,
+// when they are exhausted the objects are reused from the beginning.
+// The usage of a key is forgotten after ttl since its last selection.
+// The candidates should be filtered by liveness beforehand, the objects are identified by the Keyed interface.
+func AntiAffinityStrategy[T any](strategy Strategy[T], ttl time.Duration, opts ...StrategyOption) Strategy[T] {
+	if strategy == nil {
+		strategy = WeightedStrategy[T]()
+	}
+	if ttl <= 0 {
+		ttl = defaultAntiAffinityTTL
+	}
+	options := newStrategyOptions(opts...)
+	return &antiAffinityStrategy[T]{
+		strategy: strategy,
+		ttl:      ttl,
+		clock:    options.Clock,
+		entries:  make(map[string]*antiAffinityEntry),
+	}
+}
+
+func (s *antiAffinityStrategy[T]) Apply(ctx context.Context, vs ...T) (v T) {
+	if len(vs) == 0 {
+		return
+	}
+
+	key := ctxvalue.AffinityKeyFromContext(ctx)
+	if key == "" {
+		key = ctxvalue.ClientAddrFromContext(ctx)
+		if host, _, err := net.SplitHostPort(key); err == nil {
+			key = host
+		}
+	}
+	if key == "" {
+		return s.strategy.Apply(ctx, vs...)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.clock.Now()
+	s.expire(now)
+
+	entry := s.entries[key]
+	if entry != nil && !now.Before(entry.expires) {
+		clear(entry.used)
+	}
+	if entry == nil {
+		entry = &antiAffinityEntry{used: make(map[string]struct{})}
+		s.entries[key] = entry
+	}
+	entry.expires = now.Add(s.ttl)
+
+	candidates := s.unused(entry, vs)
+	if len(candidates) == 0 {
+		// all the candidates are used, start over.
+		clear(entry.used)
+		candidates = vs
+	}
+
+	v = s.strategy.Apply(ctx, candidates...)
+	if kv, _ := any(v).(Keyed); kv != nil {
+		entry.used[kv.Key()] = struct{}{}
+	}
+	return
+}
+
+func (s *antiAffinityStrategy[T]) unused(entry *antiAffinityEntry, vs []T) []T {
+	var result []T
+	for _, v := range vs {
+		kv, _ := any(v).(Keyed)
+		if kv == nil {
+			result = append(result, v)
+			continue
+		}
+		if _, ok := entry.used[kv.Key()]; !ok {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// expire removes the expired entries, the entries are swept at most once per ttl.
+func (s *antiAffinityStrategy[T]) expire(now time.Time) {
+	if now.Sub(s.swept) < s.ttl {
+		return
+	}
+	s.swept = now
+
+	for k, entry := range s.entries {
+		if !now.Before(entry.expires) {
+			delete(s.entries, k)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package selector
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-gost/core/common/clock"
+	"github.com/go-gost/core/common/ctxvalue"
+)
+
+func testNodes(n int) []*testNode {
+	var nodes []*testNode
+	for i := 0; i < n; i++ {
+		nodes = append(nodes, &testNode{name: fmt.Sprintf("node%d", i), weight: 1, marker: NewFailMarker()})
+	}
+	return nodes
+}
+
+func TestAntiAffinityStrategy(t *testing.T) {
+	nodes := testNodes(5)
+	s := AntiAffinityStrategy[*testNode](WeightedStrategy[*testNode](RandStrategyOption(NewRand(1))), time.Minute)
+	ctx := ctxvalue.ContextWithAffinityKey(context.Background(), "session-1")
+
+	// N connections of one key land on N distinct nodes.
+	seen := make(map[string]bool)
+	for i := 0; i < len(nodes); i++ {
+		v := s.Apply(ctx, nodes...)
+		if seen[v.name] {
+			t.Fatalf("connection %d reused %s before the pool is exhausted", i, v.name)
+		}
+		seen[v.name] = true
+	}
+
+	// the pool is exhausted, the nodes are reused.
+	if v := s.Apply(ctx, nodes...); v == nil {
+		t.Fatal("expected a reused node after the pool is exhausted")
+	}
+
+	// another key is independent.
+	other := ctxvalue.ContextWithAffinityKey(context.Background(), "session-2")
+	seen = make(map[string]bool)
+	for i := 0; i < len(nodes); i++ {
+		seen[s.Apply(other, nodes...).name] = true
+	}
+	if len(seen) != len(nodes) {
+		t.Fatalf("expected %d distinct nodes for another key, got %v", len(nodes), seen)
+	}
+}
+
+func TestAntiAffinityStrategyHealth(t *testing.T) {
+	nodes := testNodes(3)
+	nodes[1].marker.Mark()
+	filter := FailFilter[*testNode](1, 0)
+	s := AntiAffinityStrategy[*testNode](nil, time.Minute)
+	ctx := ctxvalue.ContextWithClientAddr(context.Background(), "192.0.2.1:12345")
+
+	// the down node is never selected, the healthy ones are reused once exhausted.
+	counts := make(map[string]int)
+	for i := 0; i < 6; i++ {
+		v := s.Apply(ctx, filter.Filter(ctx, nodes...)...)
+		counts[v.name]++
+	}
+	if counts["node1"] != 0 || counts["node0"] != 3 || counts["node2"] != 3 {
+		t.Fatalf("unexpected selections %v", counts)
+	}
+}
+
+// firstStrategy selects the first candidate.
+type firstStrategy struct{}
+
+func (firstStrategy) Apply(ctx context.Context, vs ...*testNode) *testNode {
+	if len(vs) == 0 {
+		return nil
+	}
+	return vs[0]
+}
+
+func TestAntiAffinityStrategyTTL(t *testing.T) {
+	c := clock.NewFakeClock(time.Unix(1000, 0))
+	nodes := testNodes(2)
+	s := AntiAffinityStrategy[*testNode](firstStrategy{}, time.Minute, ClockStrategyOption(c))
+	ctx := ctxvalue.ContextWithAffinityKey(context.Background(), "session-1")
+
+	if v := s.Apply(ctx, nodes...); v.name != "node0" {
+		t.Fatalf("unexpected selection %s", v.name)
+	}
+
+	// the usage is forgotten after the ttl since the last selection.
+	c.Advance(time.Minute)
+	if v := s.Apply(ctx, nodes...); v.name != "node0" {
+		t.Fatalf("the usage should be forgotten after the ttl, got %s", v.name)
+	}
+
+	// within the ttl the used node is avoided.
+	c.Advance(59 * time.Second)
+	if v := s.Apply(ctx, nodes...); v.name != "node1" {
+		t.Fatalf("node0 is reused within the ttl")
+	}
+}
+
+func TestAntiAffinityStrategyNoKey(t *testing.T) {
+	nodes := testNodes(3)
+	s := AntiAffinityStrategy[*testNode](nil, 0)
+	// without key the inner strategy is applied as is.
+	if counts := count(s, 300, nodes...); len(counts) != 3 {
+		t.Fatalf("unexpected selections %v", counts)
+	}
+	if v := s.Apply(context.Background()); v != nil {
+		t.Fatalf("expected nil without candidate, got %v", v)
+	}
+}
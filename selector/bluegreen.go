@@ -0,0 +1,116 @@
+package selector
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	ErrUnknownPool = errors.New("selector: unknown pool")
+)
+
+// The well-known pool names of the BlueGreenSelector.
+const (
+	PoolBlue  = "blue"
+	PoolGreen = "green"
+)
+
+// activePool is an immutable snapshot of the active pool.
+type activePool[T any] struct {
+	name    string
+	objects []T
+}
+
+// BlueGreenSelector is a Selector over the named pools (e.g. blue and green) of which only one is active at a time,
+// for the blue/green deployments: the new version is deployed to the inactive pool by Update,
+// then all the new selections are cut over to it at once by Switch.
+// The objects of the previous pool are left untouched, so the existing connections to them drain by themselves,
+// and switching back is instant as well.
+//
+// The selections read the active pool by an atomic load and never block on the updates.
+type BlueGreenSelector[T any] struct {
+	selector Selector[T]
+	active   atomic.Pointer[activePool[T]]
+	pools    map[string][]T
+	mu       sync.Mutex
+}
+
+// NewBlueGreenSelector creates a BlueGreenSelector selecting by the selector from the active pool,
+// the pools are initially empty and the pool named active is active.
+// If selector is nil, the objects are selected by the WeightedStrategy.
+func NewBlueGreenSelector[T any](selector Selector[T], active string, pools ...string) *BlueGreenSelector[T] {
+	if selector == nil {
+		selector = strategySelector[T]{strategy: WeightedStrategy[T]()}
+	}
+
+	s := &BlueGreenSelector[T]{
+		selector: selector,
+		pools:    map[string][]T{active: nil},
+	}
+	for _, name := range pools {
+		s.pools[name] = nil
+	}
+	s.active.Store(&activePool[T]{name: active})
+	return s
+}
+
+// Select selects from the objects of the active pool, the objects passed in are ignored.
+func (s *BlueGreenSelector[T]) Select(ctx context.Context, _ ...T) T {
+	return s.selector.Select(ctx, s.active.Load().objects...)
+}
+
+// Active returns the name of the active pool.
+func (s *BlueGreenSelector[T]) Active() string {
+	return s.active.Load().name
+}
+
+// Pool returns the objects of the named pool.
+func (s *BlueGreenSelector[T]) Pool(name string) ([]T, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	objects, ok := s.pools[name]
+	if !ok {
+		return nil, ErrUnknownPool
+	}
+	return objects, nil
+}
+
+// Update replaces the objects of the named pool, it takes effect immediately if the pool is active.
+func (s *BlueGreenSelector[T]) Update(name string, objects ...T) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.pools[name]; !ok {
+		return ErrUnknownPool
+	}
+	s.pools[name] = objects
+	if s.active.Load().name == name {
+		s.active.Store(&activePool[T]{name: name, objects: objects})
+	}
+	return nil
+}
+
+// Switch makes the named pool active, all the new selections are from it since then.
+// The previous pool is returned for tracking its draining, e.g. by the active connections of the nodes.
+func (s *BlueGreenSelector[T]) Switch(name string) (previous string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	objects, ok := s.pools[name]
+	if !ok {
+		return "", ErrUnknownPool
+	}
+	previous = s.active.Swap(&activePool[T]{name: name, objects: objects}).name
+	return previous, nil
+}
+
+type strategySelector[T any] struct {
+	strategy Strategy[T]
+}
+
+func (s strategySelector[T]) Select(ctx context.Context, vs ...T) T {
+	return s.strategy.Apply(ctx, vs...)
+}
@@ -0,0 +1,169 @@
+package selector
+
+import (
+	"context"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// poolNodes returns n nodes named by the pool.
+func poolNodes(pool string, n int) []*testNode {
+	nodes := testNodes(n)
+	for _, node := range nodes {
+		node.name = pool + "-" + node.name
+	}
+	return nodes
+}
+
+// assertPool selects n times and fails if any selection is not from the pool.
+func assertPool(t *testing.T, s *BlueGreenSelector[*testNode], pool string, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		v := s.Select(context.Background())
+		if v == nil || !strings.HasPrefix(v.name, pool+"-") {
+			t.Fatalf("selection %d is not from the %s pool: %v", i, pool, v)
+		}
+	}
+}
+
+func TestBlueGreenSelector(t *testing.T) {
+	s := NewBlueGreenSelector[*testNode](nil, PoolBlue, PoolGreen)
+	if s.Active() != PoolBlue {
+		t.Fatalf("unexpected active pool %s", s.Active())
+	}
+	// the pools are initially empty.
+	if v := s.Select(context.Background()); v != nil {
+		t.Fatalf("expected nil from the empty pool, got %v", v)
+	}
+
+	if err := s.Update(PoolBlue, poolNodes(PoolBlue, 3)...); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Update(PoolGreen, poolNodes(PoolGreen, 2)...); err != nil {
+		t.Fatal(err)
+	}
+	// the objects passed in are ignored.
+	if v := s.Select(context.Background(), poolNodes(PoolGreen, 1)...); !strings.HasPrefix(v.name, "blue-") {
+		t.Fatalf("unexpected selection %s", v.name)
+	}
+	assertPool(t, s, PoolBlue, 100)
+
+	previous, err := s.Switch(PoolGreen)
+	if err != nil || previous != PoolBlue || s.Active() != PoolGreen {
+		t.Fatalf("unexpected switch %s %v, active %s", previous, err, s.Active())
+	}
+	assertPool(t, s, PoolGreen, 100)
+
+	// the update of the active pool takes effect immediately.
+	s.Update(PoolGreen, poolNodes(PoolGreen, 1)...)
+	if v := s.Select(context.Background()); v.name != "green-node0" {
+		t.Fatalf("unexpected selection %s", v.name)
+	}
+
+	// switching back is instant.
+	if previous, _ := s.Switch(PoolBlue); previous != PoolGreen {
+		t.Fatalf("unexpected previous pool %s", previous)
+	}
+	assertPool(t, s, PoolBlue, 100)
+}
+
+func TestBlueGreenSelectorUnknownPool(t *testing.T) {
+	s := NewBlueGreenSelector[*testNode](nil, PoolBlue, PoolGreen)
+	if err := s.Update("canary", poolNodes("canary", 1)...); err != ErrUnknownPool {
+		t.Fatalf("expected ErrUnknownPool, got %v", err)
+	}
+	if _, err := s.Switch("canary"); err != ErrUnknownPool {
+		t.Fatalf("expected ErrUnknownPool, got %v", err)
+	}
+	if _, err := s.Pool("canary"); err != ErrUnknownPool {
+		t.Fatalf("expected ErrUnknownPool, got %v", err)
+	}
+	if s.Active() != PoolBlue {
+		t.Fatalf("the failed switch changed the active pool to %s", s.Active())
+	}
+}
+
+func TestBlueGreenSelectorDrain(t *testing.T) {
+	s := NewBlueGreenSelector[*testNode](nil, PoolBlue, PoolGreen)
+	s.Update(PoolBlue, poolNodes(PoolBlue, 1)...)
+	s.Update(PoolGreen, poolNodes(PoolGreen, 1)...)
+
+	// a connection to the blue node is established before the switch.
+	node := s.Select(context.Background())
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+	node.active++
+
+	previous, _ := s.Switch(PoolGreen)
+	assertPool(t, s, PoolGreen, 10)
+
+	// the previous pool is left untouched for tracking its draining.
+	objects, err := s.Pool(previous)
+	if err != nil || len(objects) != 1 || objects[0] != node || objects[0].ActiveConns() != 1 {
+		t.Fatalf("unexpected previous pool %v %v", objects, err)
+	}
+
+	// the existing connection still works.
+	go c2.Write([]byte("ping"))
+	b := make([]byte, 4)
+	c1.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := io.ReadFull(c1, b); err != nil || string(b) != "ping" {
+		t.Fatalf("the existing connection is broken after the switch: %q %v", b, err)
+	}
+}
+
+func TestBlueGreenSelectorLockFree(t *testing.T) {
+	s := NewBlueGreenSelector[*testNode](nil, PoolBlue, PoolGreen)
+	s.Update(PoolBlue, poolNodes(PoolBlue, 2)...)
+
+	// the selections never wait for the writers.
+	s.mu.Lock()
+	done := make(chan *testNode, 1)
+	go func() {
+		done <- s.Select(context.Background())
+	}()
+	select {
+	case v := <-done:
+		if v == nil || s.Active() != PoolBlue {
+			t.Errorf("unexpected selection %v", v)
+		}
+	case <-time.After(time.Second):
+		t.Error("the selection blocks on the writer lock")
+	}
+	s.mu.Unlock()
+
+	s.Update(PoolGreen, poolNodes(PoolGreen, 2)...)
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if v := s.Select(context.Background()); v == nil {
+					t.Error("nil selection during the switches")
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < 100; i++ {
+		if i%2 == 0 {
+			s.Switch(PoolGreen)
+		} else {
+			s.Switch(PoolBlue)
+		}
+	}
+	close(stop)
+	wg.Wait()
+}
@@ -0,0 +1,216 @@
+package selector
+
+import (
+	"sync"
+	"time"
+
+	"github.com/go-gost/core/common/clock"
+)
+
+const (
+	defaultCircuitThreshold   = 5
+	defaultCircuitOpenTimeout = 30 * time.Second
+)
+
+// CircuitState is the state of a circuit breaker.
+type CircuitState int
+
+const (
+	// CircuitClosed passes the requests, the failures are counted.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen rejects the requests until the open timeout elapses.
+	CircuitOpen
+	// CircuitHalfOpen passes the trial requests, a success closes the circuit and a failure opens it again.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+func (s CircuitState) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// CircuitStats is the exported state of a circuit breaker.
+type CircuitStats struct {
+	State CircuitState `json:"state"`
+	// Trips is the number of times the circuit is opened.
+	Trips int64 `json:"trips"`
+	// OpenDuration is the total time spent in the open state, including the current open period.
+	OpenDuration time.Duration `json:"openDuration"`
+	// Since is the time of the last state transition.
+	Since time.Time `json:"since"`
+}
+
+// Breaker is a Marker acting as a circuit breaker.
+type Breaker interface {
+	Marker
+	CircuitStats() CircuitStats
+}
+
+type CircuitOptions struct {
+	// Threshold is the number of consecutive failures to open the circuit.
+	Threshold int
+	// OpenTimeout is the duration the circuit stays open before a trial is allowed.
+	OpenTimeout time.Duration
+	// OnStateChange is called on each state transition, it must not block.
+	OnStateChange func(from, to CircuitState)
+	Clock         clock.Clock
+}
+
+type CircuitOption func(opts *CircuitOptions)
+
+func ThresholdCircuitOption(n int) CircuitOption {
+	return func(opts *CircuitOptions) {
+		opts.Threshold = n
+	}
+}
+
+func OpenTimeoutCircuitOption(d time.Duration) CircuitOption {
+	return func(opts *CircuitOptions) {
+		opts.OpenTimeout = d
+	}
+}
+
+func StateChangeCircuitOption(fn func(from, to CircuitState)) CircuitOption {
+	return func(opts *CircuitOptions) {
+		opts.OnStateChange = fn
+	}
+}
+
+func ClockCircuitOption(c clock.Clock) CircuitOption {
+	return func(opts *CircuitOptions) {
+		opts.Clock = c
+	}
+}
+
+type circuitMarker struct {
+	options   CircuitOptions
+	state     CircuitState
+	failCount int64
+	failTime  time.Time
+	trips     int64
+	openTotal time.Duration
+	since     time.Time
+	mu        sync.Mutex
+}
+
+// NewCircuitMarker creates a Marker acting as a circuit breaker. The circuit is opened after Threshold
+// consecutive failures and Count reports the failures while it is open, so the FailFilter excludes the object.
+// After the OpenTimeout the circuit is half-open and Count reports 0 to let a trial through.
+func NewCircuitMarker(opts ...CircuitOption) Breaker {
+	options := CircuitOptions{
+		Threshold:   defaultCircuitThreshold,
+		OpenTimeout: defaultCircuitOpenTimeout,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+	if options.Threshold <= 0 {
+		options.Threshold = defaultCircuitThreshold
+	}
+	if options.OpenTimeout <= 0 {
+		options.OpenTimeout = defaultCircuitOpenTimeout
+	}
+	options.Clock = clock.OrDefault(options.Clock)
+
+	return &circuitMarker{
+		options: options,
+		since:   options.Clock.Now(),
+	}
+}
+
+func (m *circuitMarker) Time() time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.failTime
+}
+
+func (m *circuitMarker) Count() int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.update() == CircuitHalfOpen {
+		return 0
+	}
+	return m.failCount
+}
+
+func (m *circuitMarker) Mark() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.failCount++
+	m.failTime = m.options.Clock.Now()
+
+	switch m.update() {
+	case CircuitHalfOpen:
+		m.transit(CircuitOpen)
+	case CircuitClosed:
+		if m.failCount >= int64(m.options.Threshold) {
+			m.transit(CircuitOpen)
+		}
+	}
+}
+
+func (m *circuitMarker) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.failCount = 0
+	if m.update() != CircuitClosed {
+		m.transit(CircuitClosed)
+	}
+}
+
+func (m *circuitMarker) CircuitStats() CircuitStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats := CircuitStats{
+		State:        m.update(),
+		Trips:        m.trips,
+		OpenDuration: m.openTotal,
+		Since:        m.since,
+	}
+	if stats.State == CircuitOpen {
+		stats.OpenDuration += m.options.Clock.Since(m.since)
+	}
+	return stats
+}
+
+// update moves the open circuit to half-open after the open timeout and returns the current state.
+func (m *circuitMarker) update() CircuitState {
+	if m.state == CircuitOpen && m.options.Clock.Since(m.since) >= m.options.OpenTimeout {
+		m.transit(CircuitHalfOpen)
+	}
+	return m.state
+}
+
+func (m *circuitMarker) transit(to CircuitState) {
+	from := m.state
+	now := m.options.Clock.Now()
+	if from == CircuitOpen {
+		m.openTotal += now.Sub(m.since)
+	}
+	if to == CircuitOpen {
+		m.trips++
+	}
+	m.state = to
+	m.since = now
+
+	if m.options.OnStateChange != nil {
+		m.options.OnStateChange(from, to)
+	}
+}
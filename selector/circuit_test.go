@@ -0,0 +1,114 @@
+package selector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-gost/core/common/clock"
+)
+
+type transition struct {
+	from, to CircuitState
+}
+
+func TestCircuitMarker(t *testing.T) {
+	c := clock.NewFakeClock(time.Unix(1000, 0))
+	var transitions []transition
+	m := NewCircuitMarker(
+		ThresholdCircuitOption(3),
+		OpenTimeoutCircuitOption(30*time.Second),
+		StateChangeCircuitOption(func(from, to CircuitState) {
+			transitions = append(transitions, transition{from, to})
+		}),
+		ClockCircuitOption(c),
+	)
+
+	m.Mark()
+	m.Mark()
+	if s := m.CircuitStats(); s.State != CircuitClosed || s.Trips != 0 {
+		t.Fatalf("the circuit should be closed below the threshold, %+v", s)
+	}
+
+	// tripped
+	m.Mark()
+	s := m.CircuitStats()
+	if s.State != CircuitOpen || s.Trips != 1 || !s.Since.Equal(c.Now()) {
+		t.Fatalf("the circuit should be open, %+v", s)
+	}
+	if m.Count() != 3 || IsAlive(m, 1, 0, c.Now()) {
+		t.Fatal("the open circuit should be excluded by the fail filter")
+	}
+
+	c.Advance(10 * time.Second)
+	if s := m.CircuitStats(); s.State != CircuitOpen || s.OpenDuration != 10*time.Second {
+		t.Fatalf("unexpected open duration %+v", s)
+	}
+
+	// half-open after the open timeout, a trial is let through.
+	c.Advance(20 * time.Second)
+	if s := m.CircuitStats(); s.State != CircuitHalfOpen || s.OpenDuration != 30*time.Second {
+		t.Fatalf("the circuit should be half-open, %+v", s)
+	}
+	if m.Count() != 0 {
+		t.Fatal("the half-open circuit should let a trial through")
+	}
+
+	// the trial fails, open again.
+	m.Mark()
+	if s := m.CircuitStats(); s.State != CircuitOpen || s.Trips != 2 {
+		t.Fatalf("the failed trial should open the circuit, %+v", s)
+	}
+
+	// the trial succeeds, closed.
+	c.Advance(30 * time.Second)
+	m.Reset()
+	s = m.CircuitStats()
+	if s.State != CircuitClosed || s.Trips != 2 || s.OpenDuration != 60*time.Second || m.Count() != 0 {
+		t.Fatalf("the circuit should be closed, %+v", s)
+	}
+	c.Advance(time.Hour)
+	if s := m.CircuitStats(); s.OpenDuration != 60*time.Second {
+		t.Fatalf("the closed time should not be counted, %+v", s)
+	}
+
+	want := []transition{
+		{CircuitClosed, CircuitOpen},
+		{CircuitOpen, CircuitHalfOpen},
+		{CircuitHalfOpen, CircuitOpen},
+		{CircuitOpen, CircuitHalfOpen},
+		{CircuitHalfOpen, CircuitClosed},
+	}
+	if len(transitions) != len(want) {
+		t.Fatalf("unexpected transitions %v", transitions)
+	}
+	for i := range want {
+		if transitions[i] != want[i] {
+			t.Fatalf("transition %d: got %v, want %v", i, transitions[i], want[i])
+		}
+	}
+}
+
+func TestCircuitMarkerReset(t *testing.T) {
+	c := clock.NewFakeClock(time.Unix(1000, 0))
+	m := NewCircuitMarker(ThresholdCircuitOption(2), ClockCircuitOption(c))
+
+	// the failures must be consecutive.
+	m.Mark()
+	m.Reset()
+	m.Mark()
+	if s := m.CircuitStats(); s.State != CircuitClosed {
+		t.Fatalf("the circuit should be closed, %+v", s)
+	}
+}
+
+func TestCircuitStateText(t *testing.T) {
+	for state, want := range map[CircuitState]string{
+		CircuitClosed:   "closed",
+		CircuitOpen:     "open",
+		CircuitHalfOpen: "half-open",
+	} {
+		if b, _ := state.MarshalText(); string(b) != want {
+			t.Errorf("got %s, want %s", b, want)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package selector
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-gost/core/metadata"
+)
+
+const (
+	// MDKeyCost is the default metadata key of the object cost, e.g. the egress price per GB.
+	MDKeyCost = "cost"
+)
+
+// Latent is an object with the recent latency.
+type Latent interface {
+	Latency() time.Duration
+}
+
+type CostOptions struct {
+	// Key is the metadata key of the cost, default is MDKeyCost.
+	Key string
+	// Tradeoff is the weight of the latency against the cost in range [0, 1],
+	// 0 minimizes the cost only and 1 minimizes the latency only.
+	Tradeoff float64
+	// LatencyBudget excludes the objects slower than it as long as any object is within it, 0 means no budget.
+	LatencyBudget time.Duration
+}
+
+type CostOption func(opts *CostOptions)
+
+func KeyCostOption(key string) CostOption {
+	return func(opts *CostOptions) {
+		opts.Key = key
+	}
+}
+
+func TradeoffCostOption(tradeoff float64) CostOption {
+	return func(opts *CostOptions) {
+		opts.Tradeoff = tradeoff
+	}
+}
+
+func LatencyBudgetCostOption(budget time.Duration) CostOption {
+	return func(opts *CostOptions) {
+		opts.LatencyBudget = budget
+	}
+}
+
+type costStrategy[T any] struct {
+	options CostOptions
+}
+
+// CostStrategy is a strategy minimizing the objective combining the cost from the object metadata
+// (see metadata.Metadatable) and the latency (see Latent), both normalized by their maximum among the candidates
+// and weighted by the tradeoff. The cheap objects are preferred while they are within the latency budget,
+// and the selection spills to the pricier faster ones under the latency pressure.
+// The objects without the cost are free, and the objects without the latency measured are not penalized.
+func CostStrategy[T any](opts ...CostOption) Strategy[T] {
+	options := CostOptions{
+		Key: MDKeyCost,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+	options.Tradeoff = min(max(options.Tradeoff, 0), 1)
+
+	return &costStrategy[T]{
+		options: options,
+	}
+}
+
+func (s *costStrategy[T]) Apply(ctx context.Context, vs ...T) (v T) {
+	if len(vs) == 0 {
+		return
+	}
+
+	costs := make([]float64, len(vs))
+	latencies := make([]time.Duration, len(vs))
+	var maxCost float64
+	var maxLatency time.Duration
+	withinBudget := false
+	for i := range vs {
+		costs[i] = s.cost(vs[i])
+		if lv, _ := any(vs[i]).(Latent); lv != nil {
+			latencies[i] = lv.Latency()
+		}
+		maxCost = max(maxCost, costs[i])
+		maxLatency = max(maxLatency, latencies[i])
+		if s.inBudget(latencies[i]) {
+			withinBudget = true
+		}
+	}
+
+	best := math.Inf(1)
+	for i := range vs {
+		if withinBudget && !s.inBudget(latencies[i]) {
+			continue
+		}
+
+		var score float64
+		if maxCost > 0 {
+			score += (1 - s.options.Tradeoff) * costs[i] / maxCost
+		}
+		if maxLatency > 0 {
+			score += s.options.Tradeoff * float64(latencies[i]) / float64(maxLatency)
+		}
+		if !withinBudget {
+			// no object is within the budget, the fastest one wins.
+			score = float64(latencies[i])
+		}
+		if score < best {
+			v, best = vs[i], score
+		}
+	}
+	return
+}
+
+func (s *costStrategy[T]) inBudget(latency time.Duration) bool {
+	return s.options.LatencyBudget <= 0 || latency <= s.options.LatencyBudget
+}
+
+func (s *costStrategy[T]) cost(v any) float64 {
+	mv, _ := v.(metadata.Metadatable)
+	if mv == nil {
+		return 0
+	}
+	md := mv.Metadata()
+	if md == nil || !md.IsExists(s.options.Key) {
+		return 0
+	}
+
+	var cost float64
+	switch c := md.Get(s.options.Key).(type) {
+	case float64:
+		cost = c
+	case int:
+		cost = float64(c)
+	case string:
+		cost, _ = strconv.ParseFloat(strings.TrimSpace(c), 64)
+	}
+	return max(cost, 0)
+}
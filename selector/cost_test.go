@@ -0,0 +1,113 @@
+package selector
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-gost/core/metadata"
+)
+
+// mapMetadata is a metadata.Metadata backed by a map.
+type mapMetadata map[string]any
+
+func (m mapMetadata) IsExists(key string) bool {
+	_, ok := m[key]
+	return ok
+}
+
+func (m mapMetadata) Set(key string, value any) {
+	m[key] = value
+}
+
+func (m mapMetadata) Get(key string) any {
+	return m[key]
+}
+
+// costNode is a node with the cost metadata and the latency.
+type costNode struct {
+	name    string
+	md      mapMetadata
+	latency time.Duration
+}
+
+func (n *costNode) Metadata() metadata.Metadata {
+	return n.md
+}
+
+func (n *costNode) Latency() time.Duration {
+	return n.latency
+}
+
+func TestCostStrategyBudget(t *testing.T) {
+	cheap := &costNode{name: "cheap", md: mapMetadata{MDKeyCost: "0.01"}, latency: 80 * time.Millisecond}
+	fast := &costNode{name: "fast", md: mapMetadata{MDKeyCost: 0.09}, latency: 10 * time.Millisecond}
+
+	// the slack latency budget, the cheap node wins.
+	s := CostStrategy[*costNode](LatencyBudgetCostOption(200*time.Millisecond), TradeoffCostOption(0.2))
+	if v := s.Apply(context.Background(), cheap, fast); v != cheap {
+		t.Fatalf("expected the cheap node with the slack budget, got %s", v.name)
+	}
+
+	// the tight latency budget, the fast node wins.
+	s = CostStrategy[*costNode](LatencyBudgetCostOption(50*time.Millisecond), TradeoffCostOption(0.2))
+	if v := s.Apply(context.Background(), cheap, fast); v != fast {
+		t.Fatalf("expected the fast node with the tight budget, got %s", v.name)
+	}
+
+	// no node is within the budget, the fastest one wins.
+	s = CostStrategy[*costNode](LatencyBudgetCostOption(time.Millisecond))
+	if v := s.Apply(context.Background(), cheap, fast); v != fast {
+		t.Fatalf("expected the fastest node beyond the budget, got %s", v.name)
+	}
+
+	if v := s.Apply(context.Background()); v != nil {
+		t.Fatalf("expected nil for no candidate, got %v", v)
+	}
+}
+
+func TestCostStrategyTradeoff(t *testing.T) {
+	cheap := &costNode{name: "cheap", md: mapMetadata{MDKeyCost: 1}, latency: 100 * time.Millisecond}
+	fast := &costNode{name: "fast", md: mapMetadata{MDKeyCost: 3}, latency: 20 * time.Millisecond}
+
+	for _, tc := range []struct {
+		tradeoff float64
+		want     *costNode
+	}{
+		// cost 1/3 vs 1.
+		{0, cheap},
+		// 0.7*1/3 + 0.3*1 = 0.533 vs 0.7*1 + 0.3*0.2 = 0.76.
+		{0.3, cheap},
+		// 0.3*1/3 + 0.7*1 = 0.8 vs 0.3*1 + 0.7*0.2 = 0.44.
+		{0.7, fast},
+		{1, fast},
+		// clamped to 1.
+		{5, fast},
+	} {
+		s := CostStrategy[*costNode](TradeoffCostOption(tc.tradeoff))
+		if v := s.Apply(context.Background(), cheap, fast); v != tc.want {
+			t.Errorf("tradeoff %v: got %s, want %s", tc.tradeoff, v.name, tc.want.name)
+		}
+	}
+}
+
+func TestCostStrategyKey(t *testing.T) {
+	a := &costNode{name: "a", md: mapMetadata{"egress": "0.05", MDKeyCost: 1}}
+	b := &costNode{name: "b", md: mapMetadata{"egress": "0.02", MDKeyCost: 0}}
+	// the node without the cost is free.
+	free := &costNode{name: "free", md: mapMetadata{}}
+
+	s := CostStrategy[*costNode](KeyCostOption("egress"))
+	if v := s.Apply(context.Background(), a, b); v != b {
+		t.Fatalf("expected the cheaper node by the custom key, got %s", v.name)
+	}
+	if v := s.Apply(context.Background(), a, b, free); v != free {
+		t.Fatalf("expected the free node, got %s", v.name)
+	}
+
+	// the objects without the metadata and latency are selected as well.
+	nodes := testNodes(2)
+	if v := CostStrategy[*testNode]().Apply(context.Background(), nodes...); v != nodes[0] {
+		t.Fatalf("unexpected selection %v", v)
+	}
+}
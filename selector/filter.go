@@ -0,0 +1,216 @@
+package selector
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-gost/core/common/clock"
+	"github.com/go-gost/core/common/ctxvalue"
+)
+
+type pipeline[T any] struct {
+	filters []Filter[T]
+}
+
+// Pipeline chains the filters, the candidates are passed through each filter in order.
+// An empty pipeline passes all the candidates.
+func Pipeline[T any](filters ...Filter[T]) Filter[T] {
+	var fs []Filter[T]
+	for _, f := range filters {
+		if f != nil {
+			fs = append(fs, f)
+		}
+	}
+	return &pipeline[T]{filters: fs}
+}
+
+func (p *pipeline[T]) Filter(ctx context.Context, vs ...T) []T {
+	for _, f := range p.filters {
+		if len(vs) == 0 {
+			break
+		}
+		vs = f.Filter(ctx, vs...)
+	}
+	return vs
+}
+
+type filterFunc[T any] func(ctx context.Context, v T) bool
+
+func (fn filterFunc[T]) Filter(ctx context.Context, vs ...T) []T {
+	var result []T
+	for _, v := range vs {
+		if fn(ctx, v) {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+type FilterOptions struct {
+	Clock clock.Clock
+}
+
+type FilterOption func(opts *FilterOptions)
+
+func ClockFilterOption(c clock.Clock) FilterOption {
+	return func(opts *FilterOptions) {
+		opts.Clock = c
+	}
+}
+
+// FailFilter filters out the objects marked as failed more than maxFails times
+// in the last failTimeout. The objects are kept if they are not Markable.
+func FailFilter[T any](maxFails int, failTimeout time.Duration, opts ...FilterOption) Filter[T] {
+	var options FilterOptions
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+	c := clock.OrDefault(options.Clock)
+
+	return filterFunc[T](func(ctx context.Context, v T) bool {
+		mv, _ := any(v).(Markable)
+		if mv == nil {
+			return true
+		}
+		return IsAlive(mv.Marker(), maxFails, failTimeout, c.Now())
+	})
+}
+
+// IsAlive reports whether the marker state is alive at now by the criteria of FailFilter:
+// it is marked less than maxFails (default 1) times, or the last failure is at least failTimeout ago.
+// A nil marker is alive.
+func IsAlive(marker Marker, maxFails int, failTimeout time.Duration, now time.Time) bool {
+	if marker == nil {
+		return true
+	}
+	if maxFails <= 0 {
+		maxFails = 1
+	}
+	return marker.Count() < int64(maxFails) ||
+		(failTimeout > 0 && now.Sub(marker.Time()) >= failTimeout)
+}
+
+// Drainable is an object which can be drained from the selection.
+type Drainable interface {
+	IsDraining() bool
+}
+
+// DrainFilter filters out the draining objects.
+func DrainFilter[T any]() Filter[T] {
+	return filterFunc[T](func(ctx context.Context, v T) bool {
+		dv, _ := any(v).(Drainable)
+		return dv == nil || !dv.IsDraining()
+	})
+}
+
+// Labeled is an object with labels.
+type Labeled interface {
+	Labels() map[string]string
+}
+
+// LabelFilter keeps the objects whose labels contain all the key-value pairs in labels.
+func LabelFilter[T any](labels map[string]string) Filter[T] {
+	return filterFunc[T](func(ctx context.Context, v T) bool {
+		if len(labels) == 0 {
+			return true
+		}
+		lv, _ := any(v).(Labeled)
+		if lv == nil {
+			return false
+		}
+		nl := lv.Labels()
+		for k, v := range labels {
+			if s, ok := nl[k]; !ok || s != v {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// The labels of the topology, see TopologyFilter.
+const (
+	LabelZone   = "zone"
+	LabelRegion = "region"
+)
+
+type topologyFilter[T any] struct {
+	key   string
+	value string
+}
+
+// TopologyFilter prefers the objects in the topology of the caller, e.g. TopologyFilter(LabelZone, "us-east-1a"),
+// the objects whose label key is value are kept if there is any, otherwise all the objects are kept,
+// so the selection spills over to the other zones or regions rather than failing.
+// Unlike LabelFilter, which keeps the matched objects only, it is a preference and should follow the liveness filters.
+func TopologyFilter[T any](key, value string) Filter[T] {
+	return &topologyFilter[T]{
+		key:   key,
+		value: value,
+	}
+}
+
+func (f *topologyFilter[T]) Filter(ctx context.Context, vs ...T) []T {
+	if f.key == "" || f.value == "" {
+		return vs
+	}
+
+	var result []T
+	for _, v := range vs {
+		if lv, _ := any(v).(Labeled); lv != nil && lv.Labels()[f.key] == f.value {
+			result = append(result, v)
+		}
+	}
+	if len(result) == 0 {
+		return vs
+	}
+	return result
+}
+
+// Shedder decides whether the request should be shed under the current load, e.g. conn.Shedder.
+type Shedder interface {
+	Shed(ctx context.Context) bool
+}
+
+// ShedFilter filters out all the candidates if the request is shed by s,
+// so the low priority requests fail fast in the selection under load.
+func ShedFilter[T any](s Shedder) Filter[T] {
+	return filterFunc[T](func(ctx context.Context, v T) bool {
+		return s == nil || !s.Shed(ctx)
+	})
+}
+
+// Budgeted is an object with a usage budget.
+type Budgeted interface {
+	BudgetExceeded() bool
+}
+
+// BudgetFilter filters out the objects which used up their budgets.
+func BudgetFilter[T any]() Filter[T] {
+	return filterFunc[T](func(ctx context.Context, v T) bool {
+		bv, _ := any(v).(Budgeted)
+		return bv == nil || !bv.BudgetExceeded()
+	})
+}
+
+// ProtocolCapable is an object declaring the request protocols it supports.
+type ProtocolCapable interface {
+	// SupportsProtocol reports whether the object supports the request protocol, e.g. udp or tls.
+	SupportsProtocol(protocol string) bool
+}
+
+// ProtocolFilter keeps the objects supporting the protocol of the request (see ctxvalue.ContextWithProtocol),
+// so e.g. a UDP request never selects a TCP-only object. The objects which are not ProtocolCapable are kept,
+// as are all the objects if the request has no protocol. If no object is compatible, no object is selected.
+func ProtocolFilter[T any]() Filter[T] {
+	return filterFunc[T](func(ctx context.Context, v T) bool {
+		protocol := ctxvalue.ProtocolFromContext(ctx)
+		if protocol == "" {
+			return true
+		}
+		pv, _ := any(v).(ProtocolCapable)
+		return pv == nil || pv.SupportsProtocol(protocol)
+	})
+}
@@ -0,0 +1,223 @@
+package selector
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/go-gost/core/common/clock"
+)
+
+// filterNode is an object implementing the optional interfaces of the filters.
+type filterNode struct {
+	name     string
+	labels   map[string]string
+	draining bool
+	marker   Marker
+}
+
+func (n *filterNode) Labels() map[string]string {
+	return n.labels
+}
+
+func (n *filterNode) IsDraining() bool {
+	return n.draining
+}
+
+func (n *filterNode) Marker() Marker {
+	return n.marker
+}
+
+func names(vs []*filterNode) []string {
+	var s []string
+	for _, v := range vs {
+		s = append(s, v.name)
+	}
+	return s
+}
+
+// firstFilter keeps the first n candidates.
+type firstFilter int
+
+func (f firstFilter) Filter(ctx context.Context, vs ...*filterNode) []*filterNode {
+	return vs[:min(int(f), len(vs))]
+}
+
+// recordFilter records its name to order and passes all the candidates.
+type recordFilter struct {
+	name  string
+	order *[]string
+}
+
+func (f recordFilter) Filter(ctx context.Context, vs ...*filterNode) []*filterNode {
+	*f.order = append(*f.order, f.name)
+	return vs
+}
+
+func TestPipelineEmpty(t *testing.T) {
+	vs := []*filterNode{{name: "a"}, {name: "b"}}
+	if got := names(Pipeline[*filterNode]().Filter(context.Background(), vs...)); !slices.Equal(got, []string{"a", "b"}) {
+		t.Fatalf("empty pipeline should pass all, got %v", got)
+	}
+	if got := names(Pipeline[*filterNode](nil, nil).Filter(context.Background(), vs...)); !slices.Equal(got, []string{"a", "b"}) {
+		t.Fatalf("nil filters should be skipped, got %v", got)
+	}
+}
+
+func TestPipelineOrder(t *testing.T) {
+	var order []string
+	p := Pipeline[*filterNode](
+		recordFilter{name: "liveness", order: &order},
+		recordFilter{name: "labels", order: &order},
+		recordFilter{name: "budget", order: &order},
+	)
+	p.Filter(context.Background(), &filterNode{name: "a"})
+	if !slices.Equal(order, []string{"liveness", "labels", "budget"}) {
+		t.Fatalf("filters apply out of order: %v", order)
+	}
+
+	// the pipeline stops once no candidate is left.
+	order = nil
+	Pipeline[*filterNode](
+		recordFilter{name: "first", order: &order},
+		firstFilter(0),
+		recordFilter{name: "last", order: &order},
+	).Filter(context.Background(), &filterNode{name: "a"})
+	if !slices.Equal(order, []string{"first"}) {
+		t.Fatalf("pipeline should stop without candidates: %v", order)
+	}
+}
+
+func TestPipelineOrderInteraction(t *testing.T) {
+	vs := []*filterNode{
+		{name: "a", labels: map[string]string{"zone": "us"}},
+		{name: "b", labels: map[string]string{"zone": "eu"}},
+		{name: "c", labels: map[string]string{"zone": "eu"}},
+	}
+	label := LabelFilter[*filterNode](map[string]string{"zone": "eu"})
+
+	got := names(Pipeline[*filterNode](label, firstFilter(1)).Filter(context.Background(), vs...))
+	if !slices.Equal(got, []string{"b"}) {
+		t.Fatalf("label then first: got %v", got)
+	}
+	got = names(Pipeline[*filterNode](firstFilter(1), label).Filter(context.Background(), vs...))
+	if len(got) != 0 {
+		t.Fatalf("first then label: got %v", got)
+	}
+}
+
+func TestPipelineTopologyOrder(t *testing.T) {
+	failed := NewFailMarker()
+	failed.Mark()
+	vs := []*filterNode{
+		{name: "a", labels: map[string]string{LabelZone: "us-east-1a"}, marker: failed},
+		{name: "b", labels: map[string]string{LabelZone: "us-east-1b"}, marker: NewFailMarker()},
+		{name: "c", labels: map[string]string{LabelZone: "us-east-1b"}, marker: NewFailMarker()},
+	}
+	live := FailFilter[*filterNode](1, 0)
+	topology := TopologyFilter[*filterNode](LabelZone, "us-east-1a")
+
+	// the local zone is down, the selection spills over to the other zone.
+	got := names(Pipeline[*filterNode](live, topology).Filter(context.Background(), vs...))
+	if !slices.Equal(got, []string{"b", "c"}) {
+		t.Fatalf("liveness then topology: got %v", got)
+	}
+	// the failed local node is preferred before the liveness is checked.
+	got = names(Pipeline[*filterNode](topology, live).Filter(context.Background(), vs...))
+	if len(got) != 0 {
+		t.Fatalf("topology then liveness: got %v", got)
+	}
+}
+
+func TestTopologyFilter(t *testing.T) {
+	vs := []*filterNode{
+		{name: "a", labels: map[string]string{LabelRegion: "us", LabelZone: "us-1"}},
+		{name: "b", labels: map[string]string{LabelRegion: "eu", LabelZone: "eu-1"}},
+		{name: "c"},
+	}
+	cases := []struct {
+		key, value string
+		want       []string
+	}{
+		{LabelZone, "us-1", []string{"a"}},
+		{LabelRegion, "eu", []string{"b"}},
+		// no object in the topology, all are kept.
+		{LabelZone, "ap-1", []string{"a", "b", "c"}},
+		{LabelZone, "", []string{"a", "b", "c"}},
+	}
+	for _, tc := range cases {
+		got := names(TopologyFilter[*filterNode](tc.key, tc.value).Filter(context.Background(), vs...))
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("%s=%s: expected %v, got %v", tc.key, tc.value, tc.want, got)
+		}
+	}
+}
+
+func TestFailFilter(t *testing.T) {
+	failed := &filterNode{name: "failed", marker: NewFailMarker()}
+	failed.marker.Mark()
+	failed.marker.Mark()
+	once := &filterNode{name: "once", marker: NewFailMarker()}
+	once.marker.Mark()
+	vs := []*filterNode{{name: "healthy", marker: NewFailMarker()}, once, failed, {name: "nomarker"}}
+
+	if got := names(FailFilter[*filterNode](0, 0).Filter(context.Background(), vs...)); !slices.Equal(got, []string{"healthy", "nomarker"}) {
+		t.Fatalf("default maxFails: got %v", got)
+	}
+	if got := names(FailFilter[*filterNode](2, 0).Filter(context.Background(), vs...)); !slices.Equal(got, []string{"healthy", "once", "nomarker"}) {
+		t.Fatalf("maxFails 2: got %v", got)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if got := names(FailFilter[*filterNode](1, time.Millisecond).Filter(context.Background(), vs...)); len(got) != len(vs) {
+		t.Fatalf("failures out of the fail timeout: got %v", got)
+	}
+}
+
+func TestDrainFilter(t *testing.T) {
+	vs := []*filterNode{{name: "a"}, {name: "b", draining: true}}
+	if got := names(DrainFilter[*filterNode]().Filter(context.Background(), vs...)); !slices.Equal(got, []string{"a"}) {
+		t.Fatalf("got %v", got)
+	}
+}
+
+func TestLabelFilter(t *testing.T) {
+	vs := []*filterNode{
+		{name: "a", labels: map[string]string{"zone": "us", "tier": "gold"}},
+		{name: "b", labels: map[string]string{"zone": "us"}},
+		{name: "c"},
+	}
+	if got := names(LabelFilter[*filterNode](map[string]string{"zone": "us", "tier": "gold"}).Filter(context.Background(), vs...)); !slices.Equal(got, []string{"a"}) {
+		t.Fatalf("got %v", got)
+	}
+	if got := names(LabelFilter[*filterNode](nil).Filter(context.Background(), vs...)); len(got) != len(vs) {
+		t.Fatalf("empty labels should keep all, got %v", got)
+	}
+}
+
+func TestFailFilterClock(t *testing.T) {
+	c := clock.NewFakeClock(time.Unix(1000, 0))
+	node := &filterNode{name: "a", marker: NewFailMarker(ClockMarkerOption(c))}
+	filter := FailFilter[*filterNode](1, 10*time.Second, ClockFilterOption(c))
+
+	node.marker.Mark()
+	if got := filter.Filter(context.Background(), node); len(got) != 0 {
+		t.Fatal("the failed node should be filtered out")
+	}
+
+	// the node is back after the fail timeout.
+	c.Advance(9 * time.Second)
+	if got := filter.Filter(context.Background(), node); len(got) != 0 {
+		t.Fatal("the node should be filtered out in the fail timeout")
+	}
+	c.Advance(time.Second)
+	if got := filter.Filter(context.Background(), node); len(got) != 1 {
+		t.Fatal("the node should be back after the fail timeout")
+	}
+
+	// a new failure restarts the cooldown.
+	node.marker.Mark()
+	if got := filter.Filter(context.Background(), node); len(got) != 0 {
+		t.Fatal("the node should be filtered out after a new failure")
+	}
+}
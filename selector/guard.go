@@ -0,0 +1,108 @@
+package selector
+
+import (
+	"context"
+	"sync"
+
+	"github.com/go-gost/core/common/ctxvalue"
+)
+
+type GuardOptions struct {
+	// Protective rejects the requests whose priority (see ctxvalue.ContextWithPriority) is below MinPriority
+	// while the healthy objects are below the floor, so the surviving objects serve the important traffic only.
+	Protective  bool
+	MinPriority int
+	// Refuse rejects all the requests while the healthy objects are below the floor.
+	Refuse bool
+	// OnChange is called when the guard enters (below is true) or leaves the protective state,
+	// healthy is the number of the healthy objects. It must not block.
+	OnChange func(below bool, healthy int)
+}
+
+type GuardOption func(opts *GuardOptions)
+
+func ProtectiveGuardOption(minPriority int) GuardOption {
+	return func(opts *GuardOptions) {
+		opts.Protective = true
+		opts.MinPriority = minPriority
+	}
+}
+
+func RefuseGuardOption() GuardOption {
+	return func(opts *GuardOptions) {
+		opts.Refuse = true
+	}
+}
+
+func OnChangeGuardOption(fn func(below bool, healthy int)) GuardOption {
+	return func(opts *GuardOptions) {
+		opts.OnChange = fn
+	}
+}
+
+// HealthGuard is a Filter guarding the minimum number of the healthy objects,
+// which prevents the cascading overload onto the last surviving objects.
+// It should be placed after the health filters (e.g. FailFilter) in a Pipeline,
+// the objects it receives are counted as healthy.
+type HealthGuard[T any] struct {
+	min     int
+	options GuardOptions
+	below   bool
+	healthy int
+	mu      sync.Mutex
+}
+
+func NewHealthGuard[T any](min int, opts ...GuardOption) *HealthGuard[T] {
+	var options GuardOptions
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+	return &HealthGuard[T]{
+		min:     min,
+		options: options,
+		healthy: -1,
+	}
+}
+
+func (g *HealthGuard[T]) Filter(ctx context.Context, vs ...T) []T {
+	below := g.update(len(vs))
+	if !below {
+		return vs
+	}
+	if g.options.Refuse {
+		return nil
+	}
+	if g.options.Protective && ctxvalue.PriorityFromContext(ctx) < g.options.MinPriority {
+		return nil
+	}
+	return vs
+}
+
+func (g *HealthGuard[T]) update(healthy int) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	below := healthy < g.min
+	if below != g.below && g.options.OnChange != nil {
+		g.options.OnChange(below, healthy)
+	}
+	g.below = below
+	g.healthy = healthy
+	return below
+}
+
+// Below reports whether the healthy objects are below the floor in the last selection.
+func (g *HealthGuard[T]) Below() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.below
+}
+
+// Healthy returns the number of the healthy objects in the last selection, -1 if no selection yet.
+func (g *HealthGuard[T]) Healthy() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.healthy
+}
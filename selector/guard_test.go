@@ -0,0 +1,90 @@
+package selector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-gost/core/common/ctxvalue"
+)
+
+// guardEvent is a state change reported by HealthGuard.
+type guardEvent struct {
+	below   bool
+	healthy int
+}
+
+func TestHealthGuardProtective(t *testing.T) {
+	var events []guardEvent
+	guard := NewHealthGuard[*testNode](2,
+		ProtectiveGuardOption(10),
+		OnChangeGuardOption(func(below bool, healthy int) {
+			events = append(events, guardEvent{below, healthy})
+		}))
+	filter := Pipeline[*testNode](FailFilter[*testNode](1, 0), guard)
+	nodes := testNodes(3)
+	low := context.Background()
+	high := ctxvalue.ContextWithPriority(context.Background(), 10)
+
+	if guard.Healthy() != -1 {
+		t.Fatalf("expected no selection yet, got %d", guard.Healthy())
+	}
+	if vs := filter.Filter(low, nodes...); len(vs) != 3 || guard.Below() || len(events) != 0 {
+		t.Fatalf("unexpected selection above the floor %d %v %v", len(vs), guard.Below(), events)
+	}
+
+	// dropping below the floor enters the protective mode, the low priority requests are rejected.
+	nodes[0].marker.Mark()
+	nodes[1].marker.Mark()
+	if vs := filter.Filter(low, nodes...); len(vs) != 0 {
+		t.Fatalf("the low priority request should be rejected, got %d nodes", len(vs))
+	}
+	if vs := filter.Filter(high, nodes...); len(vs) != 1 || vs[0] != nodes[2] {
+		t.Fatalf("the high priority request should be served, got %d nodes", len(vs))
+	}
+	if !guard.Below() || guard.Healthy() != 1 {
+		t.Fatalf("unexpected guard state %v %d", guard.Below(), guard.Healthy())
+	}
+	if len(events) != 1 || events[0] != (guardEvent{true, 1}) {
+		t.Fatalf("expected one event entering the protective mode, got %v", events)
+	}
+
+	// recovering above the floor clears it.
+	nodes[1].marker.Reset()
+	if vs := filter.Filter(low, nodes...); len(vs) != 2 || guard.Below() {
+		t.Fatalf("unexpected selection after recovery %d %v", len(vs), guard.Below())
+	}
+	if len(events) != 2 || events[1] != (guardEvent{false, 2}) {
+		t.Fatalf("expected the event leaving the protective mode, got %v", events)
+	}
+}
+
+func TestHealthGuardRefuse(t *testing.T) {
+	guard := NewHealthGuard[*testNode](2, RefuseGuardOption())
+	nodes := testNodes(2)
+	high := ctxvalue.ContextWithPriority(context.Background(), 100)
+
+	if vs := guard.Filter(high, nodes[:1]...); len(vs) != 0 || !guard.Below() {
+		t.Fatalf("all the requests should be refused below the floor, got %d nodes", len(vs))
+	}
+	if vs := guard.Filter(high, nodes...); len(vs) != 2 || guard.Below() {
+		t.Fatalf("unexpected selection at the floor %d", len(vs))
+	}
+}
+
+func TestHealthGuardAlertOnly(t *testing.T) {
+	var events int
+	guard := NewHealthGuard[*testNode](3, OnChangeGuardOption(func(below bool, healthy int) {
+		events++
+	}))
+	nodes := testNodes(2)
+
+	// without the protective mode the requests are still routed, only the event is raised once.
+	for i := 0; i < 3; i++ {
+		if vs := guard.Filter(context.Background(), nodes...); len(vs) != 2 {
+			t.Fatalf("unexpected selection %d", len(vs))
+		}
+	}
+	if events != 1 || !guard.Below() {
+		t.Fatalf("expected one event, got %d", events)
+	}
+}
@@ -0,0 +1,106 @@
+package selector
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-gost/core/common/clock"
+)
+
+// PinFilter is a Filter to temporarily pin all the selections to the specified objects,
+// overriding the strategy, e.g. for maintenance or debugging.
+// It should be placed after the health filters in the pipeline (see Pipeline),
+// so that when the pinned objects are down there is no candidate and the selection fails
+// instead of silently falling back to the others.
+// The objects are matched by the Keyed interface.
+type PinFilter[T any] struct {
+	keys  map[string]struct{}
+	until time.Time
+	clock clock.Clock
+	mu    sync.RWMutex
+}
+
+// NewPinFilter creates a PinFilter, the clock c is used for the auto-release and can be nil.
+func NewPinFilter[T any](c clock.Clock) *PinFilter[T] {
+	return &PinFilter[T]{
+		clock: clock.OrDefault(c),
+	}
+}
+
+// Pin pins the selections to the objects with the keys for the duration d,
+// after that the pin is released automatically. If d is not positive, the pin is kept until Release.
+func (p *PinFilter[T]) Pin(d time.Duration, keys ...string) {
+	m := make(map[string]struct{}, len(keys))
+	for _, k := range keys {
+		m[k] = struct{}{}
+	}
+
+	var until time.Time
+	if d > 0 {
+		until = p.clock.Now().Add(d)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.keys = m
+	p.until = until
+}
+
+// Release releases the pin.
+func (p *PinFilter[T]) Release() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.keys = nil
+	p.until = time.Time{}
+}
+
+// Pinned returns the keys of the pinned objects, it is empty if there is no active pin.
+func (p *PinFilter[T]) Pinned() []string {
+	keys := p.active()
+	result := make([]string, 0, len(keys))
+	for k := range keys {
+		result = append(result, k)
+	}
+	return result
+}
+
+func (p *PinFilter[T]) active() map[string]struct{} {
+	p.mu.RLock()
+	keys, until := p.keys, p.until
+	p.mu.RUnlock()
+
+	if len(keys) == 0 {
+		return nil
+	}
+	if !until.IsZero() && !p.clock.Now().Before(until) {
+		p.mu.Lock()
+		// the pin may be replaced in the meantime.
+		if p.until.Equal(until) {
+			p.keys = nil
+			p.until = time.Time{}
+		}
+		p.mu.Unlock()
+		return nil
+	}
+	return keys
+}
+
+func (p *PinFilter[T]) Filter(ctx context.Context, vs ...T) []T {
+	keys := p.active()
+	if keys == nil {
+		return vs
+	}
+
+	var result []T
+	for _, v := range vs {
+		if kv, _ := any(v).(Keyed); kv != nil {
+			if _, ok := keys[kv.Key()]; ok {
+				result = append(result, v)
+			}
+		}
+	}
+	return result
+}
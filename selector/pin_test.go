@@ -0,0 +1,94 @@
+package selector
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/go-gost/core/common/clock"
+)
+
+func TestPinFilter(t *testing.T) {
+	c := clock.NewFakeClock(time.Unix(1000, 0))
+	pin := NewPinFilter[*testNode](c)
+	filter := Pipeline[*testNode](FailFilter[*testNode](1, 0), pin)
+	strategy := WeightedStrategy[*testNode](RandStrategyOption(NewRand(1)))
+
+	var nodes []*testNode
+	for _, name := range []string{"a", "b", "c"} {
+		nodes = append(nodes, &testNode{name: name, weight: 1, marker: NewFailMarker()})
+	}
+
+	pin.Pin(time.Minute, "b")
+	if keys := pin.Pinned(); len(keys) != 1 || keys[0] != "b" {
+		t.Fatalf("unexpected pinned keys %v", keys)
+	}
+	for i := 0; i < 100; i++ {
+		v := strategy.Apply(context.Background(), filter.Filter(context.Background(), nodes...)...)
+		if v == nil || v.name != "b" {
+			t.Fatalf("selection %d is not pinned: %v", i, v)
+		}
+	}
+
+	// auto-released after the duration.
+	c.Advance(time.Minute)
+	if keys := pin.Pinned(); len(keys) != 0 {
+		t.Fatalf("the pin should be released, got %v", keys)
+	}
+	counts := count(strategy, 300, filter.Filter(context.Background(), nodes...)...)
+	if len(counts) != 3 {
+		t.Fatalf("all the nodes should be selected after release, got %v", counts)
+	}
+}
+
+func TestPinFilterDown(t *testing.T) {
+	pin := NewPinFilter[*testNode](nil)
+	filter := Pipeline[*testNode](FailFilter[*testNode](1, 0), pin)
+
+	a := &testNode{name: "a", weight: 1, marker: NewFailMarker()}
+	b := &testNode{name: "b", weight: 1, marker: NewFailMarker()}
+	pin.Pin(0, "a")
+
+	// the pinned node is down, the selection fails instead of falling back to b.
+	a.marker.Mark()
+	if vs := filter.Filter(context.Background(), a, b); len(vs) != 0 {
+		t.Fatalf("expected no candidate, got %v", vs)
+	}
+
+	a.marker.Reset()
+	if vs := filter.Filter(context.Background(), a, b); len(vs) != 1 || vs[0] != a {
+		t.Fatalf("expected the pinned node, got %v", vs)
+	}
+}
+
+func TestPinFilterRelease(t *testing.T) {
+	c := clock.NewFakeClock(time.Unix(1000, 0))
+	pin := NewPinFilter[*testNode](c)
+	nodes := []*testNode{{name: "a"}, {name: "b"}, {name: "c"}}
+
+	pin.Pin(0, "a", "c")
+	keys := pin.Pinned()
+	sort.Strings(keys)
+	if len(keys) != 2 || keys[0] != "a" || keys[1] != "c" {
+		t.Fatalf("unexpected pinned keys %v", keys)
+	}
+	// the pin without duration is kept.
+	c.Advance(24 * time.Hour)
+	if vs := pin.Filter(context.Background(), nodes...); len(vs) != 2 {
+		t.Fatalf("expected the pinned nodes, got %v", vs)
+	}
+
+	pin.Release()
+	if vs := pin.Filter(context.Background(), nodes...); len(vs) != 3 {
+		t.Fatalf("expected all the nodes after release, got %v", vs)
+	}
+
+	// a new pin replaces the old one with its own duration.
+	pin.Pin(time.Second, "a")
+	pin.Pin(time.Hour, "b")
+	c.Advance(time.Minute)
+	if vs := pin.Filter(context.Background(), nodes...); len(vs) != 1 || vs[0].name != "b" {
+		t.Fatalf("expected the latest pin, got %v", vs)
+	}
+}
@@ -0,0 +1,49 @@
+package selector
+
+import (
+	"math/rand"
+	"sync"
+)
+
+// Rand is the source of randomness of the strategies.
+type Rand interface {
+	// Float64 returns a number in [0.0, 1.0).
+	Float64() float64
+	// Intn returns a number in [0, n).
+	Intn(n int) int
+}
+
+type globalRand struct{}
+
+func (globalRand) Float64() float64 {
+	return rand.Float64()
+}
+
+func (globalRand) Intn(n int) int {
+	return rand.Intn(n)
+}
+
+type lockedRand struct {
+	rand *rand.Rand
+	mu   sync.Mutex
+}
+
+// NewRand returns a Rand safe for concurrent use, the same seed yields the same sequence,
+// which makes the selections reproducible in tests and incident analysis.
+func NewRand(seed int64) Rand {
+	return &lockedRand{
+		rand: rand.New(rand.NewSource(seed)),
+	}
+}
+
+func (r *lockedRand) Float64() float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rand.Float64()
+}
+
+func (r *lockedRand) Intn(n int) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rand.Intn(n)
+}
@@ -0,0 +1,97 @@
+package selector
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// sequence applies the strategy n times and returns the selected node names.
+func sequence(s Strategy[*testNode], n int, vs ...*testNode) string {
+	var names []string
+	for i := 0; i < n; i++ {
+		names = append(names, s.Apply(context.Background(), vs...).name)
+	}
+	return strings.Join(names, " ")
+}
+
+func TestRandStrategyOption(t *testing.T) {
+	nodes := testNodes(5)
+
+	for _, tc := range []struct {
+		name     string
+		strategy func(seed int64) Strategy[*testNode]
+	}{
+		{"weighted", func(seed int64) Strategy[*testNode] {
+			return WeightedStrategy[*testNode](RandStrategyOption(NewRand(seed)))
+		}},
+		{"inflight", func(seed int64) Strategy[*testNode] {
+			return InflightStrategy[*testNode](RandStrategyOption(NewRand(seed)))
+		}},
+		{"sni hash", func(seed int64) Strategy[*testNode] {
+			return SNIHashStrategy[*testNode](RandStrategyOption(NewRand(seed)))
+		}},
+	} {
+		// the same seed yields the same selection sequence across runs.
+		want := sequence(tc.strategy(42), 50, nodes...)
+		for i := 0; i < 3; i++ {
+			if got := sequence(tc.strategy(42), 50, nodes...); got != want {
+				t.Fatalf("%s: the sequence of the same seed differs:\n%s\n%s", tc.name, got, want)
+			}
+		}
+		if got := sequence(tc.strategy(43), 50, nodes...); got == want {
+			t.Errorf("%s: another seed yields the same sequence", tc.name)
+		}
+	}
+}
+
+// fixedRand always returns the last choice.
+type fixedRand struct{}
+
+func (fixedRand) Float64() float64 {
+	return 0.999
+}
+
+func (fixedRand) Intn(n int) int {
+	return n - 1
+}
+
+func TestRandStrategyOptionInjected(t *testing.T) {
+	nodes := testNodes(3)
+	for _, s := range []Strategy[*testNode]{
+		WeightedStrategy[*testNode](RandStrategyOption(fixedRand{})),
+		InflightStrategy[*testNode](RandStrategyOption(fixedRand{})),
+	} {
+		if counts := count(s, 10, nodes...); counts["node2"] != 10 {
+			t.Fatalf("the injected randomness is not used, got %v", counts)
+		}
+	}
+
+	// without the option the global source is used.
+	if counts := count(WeightedStrategy[*testNode](), 300, nodes...); len(counts) != 3 {
+		t.Fatalf("unexpected selections %v", counts)
+	}
+}
+
+func TestNewRandConcurrent(t *testing.T) {
+	r := NewRand(1)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				if n := r.Intn(10); n < 0 || n >= 10 {
+					t.Errorf("Intn out of range: %d", n)
+					return
+				}
+				if f := r.Float64(); f < 0 || f >= 1 {
+					t.Errorf("Float64 out of range: %f", f)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
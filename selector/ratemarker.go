@@ -0,0 +1,178 @@
+package selector
+
+import (
+	"sync"
+	"time"
+
+	"github.com/go-gost/core/common/clock"
+)
+
+const (
+	defaultErrorRateWindow      = 100
+	defaultErrorRateThreshold   = 0.5
+	defaultErrorRateMinRequests = 10
+)
+
+type ErrorRateOptions struct {
+	// Window is the number of the recent outcomes to compute the error rate over.
+	Window int
+	// Period limits the outcomes to the recent period if it is positive.
+	Period time.Duration
+	// Threshold is the error rate in (0, 1] above which the object is marked as failed.
+	Threshold float64
+	// MinRequests is the minimum number of the outcomes in the window to judge the error rate.
+	MinRequests int
+	Clock       clock.Clock
+}
+
+type ErrorRateOption func(opts *ErrorRateOptions)
+
+func WindowErrorRateOption(n int) ErrorRateOption {
+	return func(opts *ErrorRateOptions) {
+		opts.Window = n
+	}
+}
+
+func PeriodErrorRateOption(d time.Duration) ErrorRateOption {
+	return func(opts *ErrorRateOptions) {
+		opts.Period = d
+	}
+}
+
+func ThresholdErrorRateOption(rate float64) ErrorRateOption {
+	return func(opts *ErrorRateOptions) {
+		opts.Threshold = rate
+	}
+}
+
+func MinRequestsErrorRateOption(n int) ErrorRateOption {
+	return func(opts *ErrorRateOptions) {
+		opts.MinRequests = n
+	}
+}
+
+func ClockErrorRateOption(c clock.Clock) ErrorRateOption {
+	return func(opts *ErrorRateOptions) {
+		opts.Clock = c
+	}
+}
+
+type outcome struct {
+	time   time.Time
+	failed bool
+}
+
+type errorRateMarker struct {
+	options  ErrorRateOptions
+	outcomes []outcome
+	// next is the index of the next outcome in the ring.
+	next     int
+	full     bool
+	failTime time.Time
+	mu       sync.Mutex
+}
+
+// NewErrorRateMarker creates a Marker tripping on the rolling error rate instead of the consecutive failures,
+// which handles the intermittent flakiness better. Mark records a failed outcome and Reset records a successful one,
+// so the marker is fed by the connection outcomes. Count reports the failures in the window while
+// the error rate is above the threshold, otherwise 0.
+func NewErrorRateMarker(opts ...ErrorRateOption) Marker {
+	options := ErrorRateOptions{
+		Window:      defaultErrorRateWindow,
+		Threshold:   defaultErrorRateThreshold,
+		MinRequests: defaultErrorRateMinRequests,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+	if options.Window <= 0 {
+		options.Window = defaultErrorRateWindow
+	}
+	options.Clock = clock.OrDefault(options.Clock)
+
+	return &errorRateMarker{
+		options:  options,
+		outcomes: make([]outcome, options.Window),
+	}
+}
+
+func (m *errorRateMarker) Time() time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.failTime
+}
+
+func (m *errorRateMarker) Count() int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	total, failures := m.stats()
+	if total == 0 || total < m.options.MinRequests {
+		return 0
+	}
+	if float64(failures)/float64(total) > m.options.Threshold {
+		return int64(failures)
+	}
+	return 0
+}
+
+// SuccessRate implements SuccessRater interface, ok is false if there are fewer than MinRequests outcomes in the window.
+func (m *errorRateMarker) SuccessRate() (rate float64, ok bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	total, failures := m.stats()
+	if total == 0 || total < m.options.MinRequests {
+		return 0, false
+	}
+	return float64(total-failures) / float64(total), true
+}
+
+func (m *errorRateMarker) Mark() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.failTime = m.options.Clock.Now()
+	m.record(true)
+}
+
+func (m *errorRateMarker) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.record(false)
+}
+
+func (m *errorRateMarker) record(failed bool) {
+	m.outcomes[m.next] = outcome{
+		time:   m.options.Clock.Now(),
+		failed: failed,
+	}
+	m.next = (m.next + 1) % len(m.outcomes)
+	if m.next == 0 {
+		m.full = true
+	}
+}
+
+// stats returns the number of the outcomes and the failures in the window.
+func (m *errorRateMarker) stats() (total, failures int) {
+	n := m.next
+	if m.full {
+		n = len(m.outcomes)
+	}
+
+	now := m.options.Clock.Now()
+	for _, o := range m.outcomes[:n] {
+		if m.options.Period > 0 && now.Sub(o.time) > m.options.Period {
+			continue
+		}
+		total++
+		if o.failed {
+			failures++
+		}
+	}
+	return
+}
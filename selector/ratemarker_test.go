@@ -0,0 +1,100 @@
+package selector
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-gost/core/common/clock"
+)
+
+// feed records the outcomes of the pattern n times into m, 'F' is a failure and 'S' a success.
+func feed(m Marker, pattern string, n int) {
+	for i := 0; i < n; i++ {
+		for _, c := range pattern {
+			if c == 'F' {
+				m.Mark()
+			} else {
+				m.Reset()
+			}
+		}
+	}
+}
+
+func TestErrorRateMarker(t *testing.T) {
+	flaky := &testNode{name: "flaky", marker: NewErrorRateMarker()}
+	rare := &testNode{name: "rare", marker: NewErrorRateMarker()}
+	consecutive := &testNode{name: "consecutive", marker: NewFailMarker()}
+	filter := FailFilter[*testNode](3, 0)
+
+	// sporadic but high error rate, never 2 failures in a row.
+	feed(flaky.marker, "FSFSF", 20)
+	feed(consecutive.marker, "FSFSF", 20)
+	// rare isolated failures.
+	feed(rare.marker, "FSSSSSSSSSSSSSSSSSSS", 5)
+
+	if n := flaky.marker.Count(); n != 60 {
+		t.Fatalf("expected the failures in the window as the count, got %d", n)
+	}
+	if n := rare.marker.Count(); n != 0 {
+		t.Fatalf("the rare failures should not trip, got %d", n)
+	}
+	vs := filter.Filter(context.Background(), flaky, rare, consecutive)
+	if len(vs) != 2 || vs[0] != rare || vs[1] != consecutive {
+		t.Fatalf("expected the flaky node to be excluded only, got %d nodes", len(vs))
+	}
+
+	if rate, ok := flaky.marker.(SuccessRater).SuccessRate(); !ok || rate != 0.4 {
+		t.Fatalf("unexpected success rate %v %v", rate, ok)
+	}
+	if rate, ok := rare.marker.(SuccessRater).SuccessRate(); !ok || rate != 0.95 {
+		t.Fatalf("unexpected success rate %v %v", rate, ok)
+	}
+}
+
+func TestErrorRateMarkerWindow(t *testing.T) {
+	m := NewErrorRateMarker(WindowErrorRateOption(10), MinRequestsErrorRateOption(5), ThresholdErrorRateOption(0.3))
+
+	// too few outcomes to judge.
+	feed(m, "F", 4)
+	if n := m.Count(); n != 0 {
+		t.Fatalf("expected no failure under MinRequests, got %d", n)
+	}
+	if _, ok := m.(SuccessRater).SuccessRate(); ok {
+		t.Fatal("the success rate should not be known under MinRequests")
+	}
+	feed(m, "F", 1)
+	if n := m.Count(); n != 5 {
+		t.Fatalf("expected 5 failures, got %d", n)
+	}
+
+	// the old outcomes roll out of the window.
+	feed(m, "S", 8)
+	if n := m.Count(); n != 0 {
+		t.Fatalf("expected 2 of 10 under the threshold, got %d", n)
+	}
+	feed(m, "F", 4)
+	if n := m.Count(); n != 4 {
+		t.Fatalf("expected 4 of 10 above the threshold, got %d", n)
+	}
+}
+
+func TestErrorRateMarkerPeriod(t *testing.T) {
+	c := clock.NewFakeClock(time.Unix(1000, 0))
+	m := NewErrorRateMarker(PeriodErrorRateOption(time.Minute), MinRequestsErrorRateOption(2), ClockErrorRateOption(c))
+
+	feed(m, "F", 10)
+	if n := m.Count(); n != 10 || !m.Time().Equal(c.Now()) {
+		t.Fatalf("unexpected count %d at %s", n, m.Time())
+	}
+
+	// the outcomes beyond the period are not counted.
+	c.Advance(2 * time.Minute)
+	feed(m, "S", 2)
+	if n := m.Count(); n != 0 {
+		t.Fatalf("the old failures should be forgotten, got %d", n)
+	}
+	if rate, ok := m.(SuccessRater).SuccessRate(); !ok || rate != 1 {
+		t.Fatalf("unexpected success rate %v %v", rate, ok)
+	}
+}
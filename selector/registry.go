@@ -0,0 +1,99 @@
+package selector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+var (
+	ErrUnknownStrategy = errors.New("selector: unknown strategy")
+)
+
+// StrategyFactory creates a strategy for the objects of any type. The capability interfaces
+// (e.g. Weighted, Markable) of the objects are preserved, as the strategies assert them on the dynamic values.
+type StrategyFactory func(opts ...StrategyOption) Strategy[any]
+
+// The names of the built-in strategies.
+const (
+	StrategyWeighted     = "weighted"
+	StrategySNIHash      = "sni_hash"
+	StrategyInflight     = "inflight"
+	StrategyLeastRequest = "least_request"
+	StrategySuccessRate  = "success_rate"
+	StrategyCost         = "cost"
+)
+
+var (
+	strategies   = map[string]StrategyFactory{}
+	strategiesMu sync.RWMutex
+)
+
+func init() {
+	RegisterStrategy(StrategyWeighted, WeightedStrategy[any])
+	RegisterStrategy(StrategySNIHash, SNIHashStrategy[any])
+	RegisterStrategy(StrategyInflight, InflightStrategy[any])
+	RegisterStrategy(StrategyLeastRequest, WeightedLeastRequestStrategy[any])
+	RegisterStrategy(StrategySuccessRate, SuccessRateStrategy[any])
+	RegisterStrategy(StrategyCost, costStrategyFactory)
+}
+
+// costStrategyFactory creates CostStrategy with the options set by CostStrategyOption.
+func costStrategyFactory(opts ...StrategyOption) Strategy[any] {
+	options := newStrategyOptions(opts...)
+	return CostStrategy[any](options.Cost...)
+}
+
+// RegisterStrategy registers the strategy factory with name, so the custom strategy can be chosen by name from config.
+// A registered name is replaced.
+func RegisterStrategy(name string, factory StrategyFactory) {
+	strategiesMu.Lock()
+	defer strategiesMu.Unlock()
+	strategies[name] = factory
+}
+
+// Strategies returns the sorted names of the registered strategies.
+func Strategies() []string {
+	strategiesMu.RLock()
+	defer strategiesMu.RUnlock()
+
+	names := make([]string, 0, len(strategies))
+	for name := range strategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewStrategy creates the strategy registered with name for the objects of type T,
+// ErrUnknownStrategy is returned if name is not registered.
+func NewStrategy[T any](name string, opts ...StrategyOption) (Strategy[T], error) {
+	strategiesMu.RLock()
+	factory := strategies[name]
+	strategiesMu.RUnlock()
+
+	if factory == nil {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownStrategy, name)
+	}
+	return &typedStrategy[T]{strategy: factory(opts...)}, nil
+}
+
+// typedStrategy adapts a Strategy[any] to the objects of type T.
+type typedStrategy[T any] struct {
+	strategy Strategy[any]
+}
+
+func (s *typedStrategy[T]) Apply(ctx context.Context, vs ...T) (v T) {
+	if len(vs) == 0 {
+		return
+	}
+
+	values := make([]any, len(vs))
+	for i := range vs {
+		values[i] = vs[i]
+	}
+	v, _ = s.strategy.Apply(ctx, values...).(T)
+	return
+}
@@ -0,0 +1,130 @@
+package selector
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// heaviestStrategy selects the object with the largest weight.
+type heaviestStrategy struct{}
+
+func (heaviestStrategy) Apply(ctx context.Context, vs ...any) (v any) {
+	best := -1
+	for _, o := range vs {
+		if w, ok := o.(interface{ Weight() int }); ok && w.Weight() > best {
+			best = w.Weight()
+			v = o
+		}
+	}
+	return
+}
+
+// lastStrategy selects the last object.
+type lastStrategy struct{}
+
+func (lastStrategy) Apply(ctx context.Context, vs ...any) any {
+	if len(vs) == 0 {
+		return nil
+	}
+	return vs[len(vs)-1]
+}
+
+func registerTestStrategy(t *testing.T, name string, factory StrategyFactory) {
+	t.Helper()
+	RegisterStrategy(name, factory)
+	t.Cleanup(func() {
+		strategiesMu.Lock()
+		defer strategiesMu.Unlock()
+		delete(strategies, name)
+	})
+}
+
+func TestRegisterStrategy(t *testing.T) {
+	registerTestStrategy(t, "heaviest", func(opts ...StrategyOption) Strategy[any] {
+		return heaviestStrategy{}
+	})
+
+	found := false
+	for _, name := range Strategies() {
+		found = found || name == "heaviest"
+	}
+	if !found {
+		t.Fatalf("the custom strategy is not registered: %v", Strategies())
+	}
+
+	s, err := NewStrategy[*testNode]("heaviest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes := []*testNode{
+		{name: "a", weight: 1, marker: NewFailMarker()},
+		{name: "b", weight: 5, marker: NewFailMarker()},
+		{name: "c", weight: 3, marker: NewFailMarker()},
+	}
+
+	// the custom strategy is used by the selector, after the filters.
+	sel := TracedSelector[*testNode](s, []Filter[*testNode]{FailFilter[*testNode](1, 0)})
+	if v := sel.Select(context.Background(), nodes...); v != nodes[1] {
+		t.Fatalf("expected the heaviest node, got %v", v)
+	}
+	nodes[1].marker.Mark()
+	if v := sel.Select(context.Background(), nodes...); v != nodes[2] {
+		t.Fatalf("expected the heaviest healthy node, got %v", v)
+	}
+	if v := s.Apply(context.Background()); v != nil {
+		t.Fatalf("expected nil for no candidate, got %v", v)
+	}
+
+	// a registered name is replaced.
+	registerTestStrategy(t, "heaviest", func(opts ...StrategyOption) Strategy[any] {
+		return lastStrategy{}
+	})
+	s, _ = NewStrategy[*testNode]("heaviest")
+	if v := s.Apply(context.Background(), nodes...); v != nodes[2] {
+		t.Fatalf("expected the replaced strategy, got %v", v)
+	}
+}
+
+func TestNewStrategyBuiltin(t *testing.T) {
+	nodes := testNodes(3)
+	for _, name := range []string{StrategyWeighted, StrategySNIHash, StrategyInflight, StrategyLeastRequest, StrategySuccessRate, StrategyCost} {
+		s, err := NewStrategy[*testNode](name, RandStrategyOption(NewRand(1)))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if v := s.Apply(context.Background(), nodes...); v == nil {
+			t.Errorf("%s: expected a selection", name)
+		}
+	}
+}
+
+func TestNewStrategyCost(t *testing.T) {
+	cheap := &costNode{name: "cheap", md: mapMetadata{MDKeyCost: "0.01"}, latency: 80 * time.Millisecond}
+	fast := &costNode{name: "fast", md: mapMetadata{MDKeyCost: 0.09}, latency: 10 * time.Millisecond}
+
+	// the cost options are passed through the strategy options.
+	s, err := NewStrategy[*costNode](StrategyCost, CostStrategyOption(LatencyBudgetCostOption(200*time.Millisecond), TradeoffCostOption(0.2)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := s.Apply(context.Background(), cheap, fast); v != cheap {
+		t.Fatalf("slack budget: expected the cheap node, got %v", v)
+	}
+	s, _ = NewStrategy[*costNode](StrategyCost, CostStrategyOption(LatencyBudgetCostOption(50*time.Millisecond)))
+	if v := s.Apply(context.Background(), cheap, fast); v != fast {
+		t.Fatalf("tight budget: expected the fast node, got %v", v)
+	}
+}
+
+func TestNewStrategyUnknown(t *testing.T) {
+	s, err := NewStrategy[*testNode]("no_such_strategy")
+	if !errors.Is(err, ErrUnknownStrategy) || s != nil {
+		t.Fatalf("expected ErrUnknownStrategy, got %v", err)
+	}
+	if !strings.Contains(err.Error(), `"no_such_strategy"`) {
+		t.Fatalf("the error should name the strategy: %v", err)
+	}
+}
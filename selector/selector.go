@@ -4,6 +4,8 @@ import (
 	"context"
 	"sync/atomic"
 	"time"
+
+	"github.com/go-gost/core/common/clock"
 )
 
 type Selector[T any] interface {
@@ -29,13 +31,35 @@ type Marker interface {
 	Reset()
 }
 
+type MarkerOptions struct {
+	Clock clock.Clock
+}
+
+type MarkerOption func(opts *MarkerOptions)
+
+func ClockMarkerOption(c clock.Clock) MarkerOption {
+	return func(opts *MarkerOptions) {
+		opts.Clock = c
+	}
+}
+
 type failMarker struct {
 	failTime  int64
 	failCount int64
+	clock     clock.Clock
 }
 
-func NewFailMarker() Marker {
-	return &failMarker{}
+func NewFailMarker(opts ...MarkerOption) Marker {
+	var options MarkerOptions
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+
+	return &failMarker{
+		clock: clock.OrDefault(options.Clock),
+	}
 }
 
 func (m *failMarker) Time() time.Time {
@@ -60,7 +84,7 @@ func (m *failMarker) Mark() {
 	}
 
 	atomic.AddInt64(&m.failCount, 1)
-	atomic.StoreInt64(&m.failTime, time.Now().Unix())
+	atomic.StoreInt64(&m.failTime, m.clock.Now().Unix())
 }
 
 func (m *failMarker) Reset() {
@@ -70,3 +94,17 @@ func (m *failMarker) Reset() {
 
 	atomic.StoreInt64(&m.failCount, 0)
 }
+
+// Restorable is a Marker whose state can be restored, e.g. from the persisted state after a restart.
+type Restorable interface {
+	Restore(count int64, t time.Time)
+}
+
+func (m *failMarker) Restore(count int64, t time.Time) {
+	if m == nil {
+		return
+	}
+
+	atomic.StoreInt64(&m.failCount, count)
+	atomic.StoreInt64(&m.failTime, t.Unix())
+}
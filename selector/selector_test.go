@@ -0,0 +1,43 @@
+package selector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-gost/core/common/clock"
+)
+
+func TestFailMarkerClock(t *testing.T) {
+	c := clock.NewFakeClock(time.Unix(1000, 0))
+	m := NewFailMarker(ClockMarkerOption(c))
+
+	m.Mark()
+	if m.Count() != 1 || !m.Time().Equal(time.Unix(1000, 0)) {
+		t.Fatalf("unexpected marker state %d %s", m.Count(), m.Time())
+	}
+
+	c.Advance(30 * time.Second)
+	m.Mark()
+	if m.Count() != 2 || !m.Time().Equal(time.Unix(1030, 0)) {
+		t.Fatalf("the fail time should follow the clock, got %d %s", m.Count(), m.Time())
+	}
+
+	m.Reset()
+	if m.Count() != 0 {
+		t.Fatalf("unexpected count after reset %d", m.Count())
+	}
+}
+
+func TestFailMarkerRestore(t *testing.T) {
+	m := NewFailMarker()
+	m.(Restorable).Restore(3, time.Unix(2000, 0))
+	if m.Count() != 3 || !m.Time().Equal(time.Unix(2000, 0)) {
+		t.Fatalf("unexpected restored state %d %s", m.Count(), m.Time())
+	}
+
+	var nilMarker *failMarker
+	nilMarker.Mark()
+	if nilMarker.Count() != 0 || !nilMarker.Time().IsZero() {
+		t.Fatal("nil marker should be zero")
+	}
+}
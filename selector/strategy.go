@@ -0,0 +1,505 @@
+package selector
+
+import (
+	"context"
+	"hash/crc32"
+	"math"
+	"net"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/go-gost/core/common/clock"
+	"github.com/go-gost/core/common/ctxvalue"
+)
+
+const (
+	// slowStartMinFactor is the initial fraction of the weight for a newly joined object in slow start.
+	slowStartMinFactor = 0.1
+)
+
+// Weighted is an object with a selection weight.
+type Weighted interface {
+	Weight() int
+}
+
+// Joinable is an object which knows when it joins the selection.
+type Joinable interface {
+	JoinTime() time.Time
+}
+
+// DecayFunc maps the elapsed fraction of the cooldown period in range [0, 1)
+// to the fraction of the initial penalty remaining.
+type DecayFunc func(elapsed float64) float64
+
+// LinearDecay decays the penalty linearly to zero over the cooldown period.
+func LinearDecay(elapsed float64) float64 {
+	return 1 - elapsed
+}
+
+// ExponentialDecay halves the penalty every tenth of the cooldown period.
+func ExponentialDecay(elapsed float64) float64 {
+	return math.Pow(0.5, elapsed*10)
+}
+
+type StrategyOptions struct {
+	// SlowStart is the warm-up duration, during which the effective weight of
+	// a newly joined object ramps up linearly to its full weight.
+	SlowStart time.Duration
+	// Cooldown is the duration after the last failure of a marked object,
+	// during which its effective weight is reduced by the penalty decaying over time.
+	Cooldown time.Duration
+	// Penalty is the initial weight reduction in range (0, 1] applied right after a failure.
+	Penalty float64
+	// Decay is the decay curve of the penalty, default is LinearDecay.
+	Decay DecayFunc
+	Clock clock.Clock
+	// Rand is the source of randomness, default is the global source of math/rand.
+	Rand Rand
+	// Deterministic switches WeightedStrategy to the smooth weighted round-robin.
+	Deterministic bool
+	// Cost is the options of CostStrategy created by name, see NewStrategy.
+	Cost []CostOption
+}
+
+type StrategyOption func(opts *StrategyOptions)
+
+func SlowStartStrategyOption(d time.Duration) StrategyOption {
+	return func(opts *StrategyOptions) {
+		opts.SlowStart = d
+	}
+}
+
+func CooldownStrategyOption(cooldown time.Duration, penalty float64) StrategyOption {
+	return func(opts *StrategyOptions) {
+		opts.Cooldown = cooldown
+		opts.Penalty = penalty
+	}
+}
+
+func DecayStrategyOption(decay DecayFunc) StrategyOption {
+	return func(opts *StrategyOptions) {
+		opts.Decay = decay
+	}
+}
+
+func ClockStrategyOption(c clock.Clock) StrategyOption {
+	return func(opts *StrategyOptions) {
+		opts.Clock = c
+	}
+}
+
+// RandStrategyOption sets the source of randomness, e.g. NewRand with a fixed seed for a reproducible selection sequence.
+func RandStrategyOption(r Rand) StrategyOption {
+	return func(opts *StrategyOptions) {
+		opts.Rand = r
+	}
+}
+
+// DeterministicStrategyOption switches WeightedStrategy from the weighted random to
+// the smooth weighted round-robin, which produces a strictly smooth and reproducible distribution.
+func DeterministicStrategyOption(deterministic bool) StrategyOption {
+	return func(opts *StrategyOptions) {
+		opts.Deterministic = deterministic
+	}
+}
+
+// CostStrategyOption sets the options of CostStrategy created by name, see NewStrategy.
+func CostStrategyOption(costOpts ...CostOption) StrategyOption {
+	return func(opts *StrategyOptions) {
+		opts.Cost = append(opts.Cost, costOpts...)
+	}
+}
+
+func newStrategyOptions(opts ...StrategyOption) StrategyOptions {
+	var options StrategyOptions
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+	options.Clock = clock.OrDefault(options.Clock)
+	if options.Rand == nil {
+		options.Rand = globalRand{}
+	}
+	return options
+}
+
+// weight returns the effective weight of v, the weight is at least 1.
+func (opts *StrategyOptions) weight(v any) float64 {
+	w := 1.0
+	if wv, _ := v.(Weighted); wv != nil && wv.Weight() > 0 {
+		w = float64(wv.Weight())
+	}
+
+	if opts.SlowStart > 0 {
+		if jv, _ := v.(Joinable); jv != nil && !jv.JoinTime().IsZero() {
+			if d := opts.Clock.Since(jv.JoinTime()); d < opts.SlowStart {
+				w *= max(slowStartMinFactor, float64(d)/float64(opts.SlowStart))
+			}
+		}
+	}
+
+	if opts.Cooldown > 0 && opts.Penalty > 0 {
+		w *= 1 - opts.penalty(v)
+	}
+
+	return max(w, math.SmallestNonzeroFloat64)
+}
+
+// penalty returns the current weight reduction of v in cooldown.
+func (opts *StrategyOptions) penalty(v any) float64 {
+	mv, _ := v.(Markable)
+	if mv == nil {
+		return 0
+	}
+	marker := mv.Marker()
+	if marker == nil || marker.Count() == 0 {
+		return 0
+	}
+
+	d := opts.Clock.Since(marker.Time())
+	if d < 0 || d >= opts.Cooldown {
+		return 0
+	}
+
+	decay := opts.Decay
+	if decay == nil {
+		decay = LinearDecay
+	}
+	p := opts.Penalty * decay(float64(d)/float64(opts.Cooldown))
+	return min(max(p, 0), 1)
+}
+
+type weightedStrategy[T any] struct {
+	options StrategyOptions
+	// current is the current weights of the smooth weighted round-robin by the object key.
+	current map[any]float64
+	mu      sync.Mutex
+}
+
+// WeightedStrategy is a strategy for weighted random selection. With DeterministicStrategyOption,
+// it is the smooth weighted round-robin (as nginx) over the same effective weights, e.g. the weights 5, 1, 1
+// produce the sequence a, a, b, a, c, a, a. The objects are identified by their keys if they are Keyed.
+func WeightedStrategy[T any](opts ...StrategyOption) Strategy[T] {
+	return &weightedStrategy[T]{
+		options: newStrategyOptions(opts...),
+		current: make(map[any]float64),
+	}
+}
+
+func (s *weightedStrategy[T]) Apply(ctx context.Context, vs ...T) (v T) {
+	if len(vs) == 0 {
+		return
+	}
+
+	weights := make([]float64, len(vs))
+	var total float64
+	for i := range vs {
+		weights[i] = s.options.weight(vs[i])
+		total += weights[i]
+	}
+
+	if s.options.Deterministic {
+		return vs[s.smooth(vs, weights, total)]
+	}
+
+	r := s.options.Rand.Float64() * total
+	for i := range vs {
+		if r < weights[i] {
+			return vs[i]
+		}
+		r -= weights[i]
+	}
+	return vs[len(vs)-1]
+}
+
+// smooth returns the index of the object selected by the smooth weighted round-robin,
+// the states of the objects no longer in the candidates are dropped.
+func (s *weightedStrategy[T]) smooth(vs []T, weights []float64, total float64) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]any, len(vs))
+	current := make(map[any]float64, len(vs))
+	best := 0
+	for i := range vs {
+		keys[i] = objectKey(vs[i], i)
+		current[keys[i]] = s.current[keys[i]] + weights[i]
+		if current[keys[i]] > current[keys[best]] {
+			best = i
+		}
+	}
+	current[keys[best]] -= total
+	s.current = current
+	return best
+}
+
+// objectKey returns the identity of v in the selection, which is the key if v is Keyed,
+// v itself if it is comparable, otherwise the index i.
+func objectKey(v any, i int) any {
+	if kv, _ := v.(Keyed); kv != nil {
+		return kv.Key()
+	}
+	if t := reflect.TypeOf(v); t != nil && t.Comparable() {
+		return v
+	}
+	return i
+}
+
+type sniHashStrategy[T any] struct {
+	options StrategyOptions
+}
+
+// SNIHashStrategy is a strategy for affinity selection, the hash key is the TLS server name
+// carried in the context, if it is absent, the client IP is used instead.
+func SNIHashStrategy[T any](opts ...StrategyOption) Strategy[T] {
+	return &sniHashStrategy[T]{
+		options: newStrategyOptions(opts...),
+	}
+}
+
+func (s *sniHashStrategy[T]) Apply(ctx context.Context, vs ...T) (v T) {
+	if len(vs) == 0 {
+		return
+	}
+
+	key := ctxvalue.SNIFromContext(ctx)
+	if key == "" {
+		key = ctxvalue.ClientAddrFromContext(ctx)
+		if host, _, err := net.SplitHostPort(key); err == nil {
+			key = host
+		}
+	}
+	if key == "" {
+		return vs[s.options.Rand.Intn(len(vs))]
+	}
+
+	return vs[crc32.ChecksumIEEE([]byte(key))%uint32(len(vs))]
+}
+
+// Tiered is an object belonging to a failover tier.
+type Tiered interface {
+	Tier() int
+}
+
+type tieredStrategy[T any] struct {
+	strategy Strategy[T]
+}
+
+// TieredStrategy is a strategy for active/standby failover, it applies the strategy only to
+// the candidates in the lowest tier, so the higher tiers are never selected while any candidate
+// in a lower tier is available. The candidates should be filtered by liveness beforehand.
+// Objects which are not Tiered are in tier 0.
+func TieredStrategy[T any](strategy Strategy[T]) Strategy[T] {
+	if strategy == nil {
+		strategy = WeightedStrategy[T]()
+	}
+	return &tieredStrategy[T]{
+		strategy: strategy,
+	}
+}
+
+func (s *tieredStrategy[T]) Apply(ctx context.Context, vs ...T) (v T) {
+	if len(vs) == 0 {
+		return
+	}
+
+	tier := func(v T) int {
+		if tv, _ := any(v).(Tiered); tv != nil {
+			return tv.Tier()
+		}
+		return 0
+	}
+
+	lowest := tier(vs[0])
+	for _, v := range vs[1:] {
+		lowest = min(lowest, tier(v))
+	}
+
+	var candidates []T
+	for _, v := range vs {
+		if tier(v) == lowest {
+			candidates = append(candidates, v)
+		}
+	}
+	return s.strategy.Apply(ctx, candidates...)
+}
+
+// Keyed is an object with a unique key in the selection, e.g. the node name.
+type Keyed interface {
+	Key() string
+}
+
+type preferredStrategy[T any] struct {
+	strategy Strategy[T]
+	allowed  map[string]struct{}
+}
+
+// PreferredStrategy honors the object requested by the client in the context (see ctxvalue.ContextWithPreferredNode)
+// if it is in the allowed list and among the candidates, otherwise it falls back to strategy.
+// The allowed list "*" allows any object, an empty list disables the preference.
+// The candidates should be filtered by liveness beforehand, so an unhealthy preferred object falls back.
+func PreferredStrategy[T any](strategy Strategy[T], allowed ...string) Strategy[T] {
+	if strategy == nil {
+		strategy = WeightedStrategy[T]()
+	}
+	m := make(map[string]struct{}, len(allowed))
+	for _, s := range allowed {
+		m[s] = struct{}{}
+	}
+	return &preferredStrategy[T]{
+		strategy: strategy,
+		allowed:  m,
+	}
+}
+
+func (s *preferredStrategy[T]) Apply(ctx context.Context, vs ...T) (v T) {
+	if pref := ctxvalue.PreferredNodeFromContext(ctx); pref != "" && s.isAllowed(pref) {
+		for _, v := range vs {
+			if kv, _ := any(v).(Keyed); kv != nil && kv.Key() == pref {
+				return v
+			}
+		}
+	}
+	return s.strategy.Apply(ctx, vs...)
+}
+
+func (s *preferredStrategy[T]) isAllowed(key string) bool {
+	if _, ok := s.allowed["*"]; ok {
+		return true
+	}
+	_, ok := s.allowed[key]
+	return ok
+}
+
+// Inflight is an object with the bytes in flight on its active connections.
+type Inflight interface {
+	InflightBytes() int64
+}
+
+type inflightStrategy[T any] struct {
+	options StrategyOptions
+}
+
+// InflightStrategy is a strategy selecting the object with the least in-flight bytes,
+// so an object carrying a huge transfer is not treated as light by its connection count.
+// The ties are broken randomly, the objects which are not Inflight have no in-flight bytes.
+func InflightStrategy[T any](opts ...StrategyOption) Strategy[T] {
+	return &inflightStrategy[T]{
+		options: newStrategyOptions(opts...),
+	}
+}
+
+func (s *inflightStrategy[T]) Apply(ctx context.Context, vs ...T) (v T) {
+	if len(vs) == 0 {
+		return
+	}
+
+	inflight := func(v T) int64 {
+		if iv, _ := any(v).(Inflight); iv != nil {
+			return iv.InflightBytes()
+		}
+		return 0
+	}
+
+	var least []T
+	var lowest int64
+	for i, v := range vs {
+		n := inflight(v)
+		switch {
+		case i == 0 || n < lowest:
+			lowest = n
+			least = append(least[:0], v)
+		case n == lowest:
+			least = append(least, v)
+		}
+	}
+	return least[s.options.Rand.Intn(len(least))]
+}
+
+// Active is an object with active connections.
+type Active interface {
+	ActiveConns() int64
+}
+
+type weightedLeastRequestStrategy[T any] struct {
+	options StrategyOptions
+}
+
+// WeightedLeastRequestStrategy is a strategy selecting the object with the least active connections
+// per unit of the effective weight (see WeightedStrategy), so an object with a higher weight takes proportionally more connections.
+// The ties are broken by the order of the candidates.
+func WeightedLeastRequestStrategy[T any](opts ...StrategyOption) Strategy[T] {
+	return &weightedLeastRequestStrategy[T]{
+		options: newStrategyOptions(opts...),
+	}
+}
+
+func (s *weightedLeastRequestStrategy[T]) Apply(ctx context.Context, vs ...T) (v T) {
+	if len(vs) == 0 {
+		return
+	}
+
+	best := math.Inf(1)
+	for _, c := range vs {
+		var active int64
+		if av, _ := any(c).(Active); av != nil {
+			active = av.ActiveConns()
+		}
+		if score := float64(active) / s.options.weight(c); score < best {
+			v, best = c, score
+		}
+	}
+	return
+}
+
+// SuccessRater is an object with the recent success ratio in range [0, 1],
+// ok is false if there are not enough outcomes to judge.
+type SuccessRater interface {
+	SuccessRate() (rate float64, ok bool)
+}
+
+type successRateStrategy[T any] struct {
+	options StrategyOptions
+}
+
+// SuccessRateStrategy is a weighted random strategy (see WeightedStrategy) multiplying the effective weight
+// by the recent success ratio of the object, so a flaky object receives less traffic in proportion to
+// its reliability without being removed. The objects without enough outcomes keep their full weight.
+func SuccessRateStrategy[T any](opts ...StrategyOption) Strategy[T] {
+	return &successRateStrategy[T]{
+		options: newStrategyOptions(opts...),
+	}
+}
+
+func (s *successRateStrategy[T]) Apply(ctx context.Context, vs ...T) (v T) {
+	if len(vs) == 0 {
+		return
+	}
+
+	weights := make([]float64, len(vs))
+	var total float64
+	for i := range vs {
+		w := s.options.weight(vs[i])
+		if sv, _ := any(vs[i]).(SuccessRater); sv != nil {
+			if rate, ok := sv.SuccessRate(); ok {
+				w *= max(rate, 0)
+			}
+		}
+		weights[i] = w
+		total += w
+	}
+	if total <= 0 {
+		return vs[s.options.Rand.Intn(len(vs))]
+	}
+
+	r := s.options.Rand.Float64() * total
+	for i := range vs {
+		if r < weights[i] {
+			return vs[i]
+		}
+		r -= weights[i]
+	}
+	return vs[len(vs)-1]
+}
@@ -0,0 +1,535 @@
+package selector
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/go-gost/core/common/clock"
+	"github.com/go-gost/core/common/ctxvalue"
+)
+
+// testNode is a selectable object implementing the optional interfaces of the strategies.
+type testNode struct {
+	name     string
+	weight   int
+	joinTime time.Time
+	marker   Marker
+	tier     int
+	inflight int64
+	active   int64
+}
+
+func (n *testNode) Key() string {
+	return n.name
+}
+
+func (n *testNode) Weight() int {
+	return n.weight
+}
+
+func (n *testNode) JoinTime() time.Time {
+	return n.joinTime
+}
+
+func (n *testNode) Marker() Marker {
+	return n.marker
+}
+
+func (n *testNode) Tier() int {
+	return n.tier
+}
+
+func (n *testNode) InflightBytes() int64 {
+	return n.inflight
+}
+
+func (n *testNode) ActiveConns() int64 {
+	return n.active
+}
+
+func (n *testNode) SuccessRate() (float64, bool) {
+	if sr, ok := n.marker.(SuccessRater); ok {
+		return sr.SuccessRate()
+	}
+	return 0, false
+}
+
+// count applies the strategy n times and returns the selection counts by the node name.
+func count(s Strategy[*testNode], n int, vs ...*testNode) map[string]int {
+	counts := make(map[string]int)
+	for i := 0; i < n; i++ {
+		if v := s.Apply(context.Background(), vs...); v != nil {
+			counts[v.name]++
+		}
+	}
+	return counts
+}
+
+func assertShare(t *testing.T, counts map[string]int, name string, total int, want, delta float64) {
+	t.Helper()
+	if got := float64(counts[name]) / float64(total); math.Abs(got-want) > delta {
+		t.Fatalf("share of %s is %.3f, want %.3f±%.3f (%v)", name, got, want, delta, counts)
+	}
+}
+
+func TestWeightedStrategy(t *testing.T) {
+	a := &testNode{name: "a", weight: 3}
+	b := &testNode{name: "b", weight: 1}
+	s := WeightedStrategy[*testNode](RandStrategyOption(NewRand(1)))
+
+	counts := count(s, 10000, a, b)
+	assertShare(t, counts, "a", 10000, 0.75, 0.03)
+
+	if v := s.Apply(context.Background()); v != nil {
+		t.Fatalf("expected nil for no candidate, got %v", v)
+	}
+}
+
+func TestWeightedStrategySlowStart(t *testing.T) {
+	c := clock.NewFakeClock(time.Unix(1000, 0))
+	old := &testNode{name: "old", weight: 1, joinTime: c.Now().Add(-time.Hour)}
+	fresh := &testNode{name: "fresh", weight: 1, joinTime: c.Now()}
+	s := WeightedStrategy[*testNode](
+		SlowStartStrategyOption(10*time.Second),
+		ClockStrategyOption(c),
+		RandStrategyOption(NewRand(1)),
+	)
+
+	const n = 10000
+	// the fresh node starts at the minimum factor of its weight.
+	counts := count(s, n, old, fresh)
+	assertShare(t, counts, "fresh", n, slowStartMinFactor/(1+slowStartMinFactor), 0.02)
+
+	// half way through the warm-up.
+	c.Advance(5 * time.Second)
+	counts = count(s, n, old, fresh)
+	assertShare(t, counts, "fresh", n, 0.5/1.5, 0.03)
+
+	// parity after the warm-up window.
+	c.Advance(5 * time.Second)
+	counts = count(s, n, old, fresh)
+	assertShare(t, counts, "fresh", n, 0.5, 0.03)
+}
+
+func TestWeightedStrategySlowStartNoJoinTime(t *testing.T) {
+	c := clock.NewFakeClock(time.Unix(1000, 0))
+	a := &testNode{name: "a", weight: 1}
+	b := &testNode{name: "b", weight: 1}
+	s := WeightedStrategy[*testNode](
+		SlowStartStrategyOption(10*time.Second),
+		ClockStrategyOption(c),
+		RandStrategyOption(NewRand(1)),
+	)
+
+	counts := count(s, 10000, a, b)
+	assertShare(t, counts, "b", 10000, 0.5, 0.03)
+}
+
+func TestWeightedStrategyCooldown(t *testing.T) {
+	c := clock.NewFakeClock(time.Unix(1000, 0))
+	healthy := &testNode{name: "healthy", weight: 1, marker: NewFailMarker(ClockMarkerOption(c))}
+	failed := &testNode{name: "failed", weight: 1, marker: NewFailMarker(ClockMarkerOption(c))}
+	s := WeightedStrategy[*testNode](
+		CooldownStrategyOption(10*time.Second, 0.8),
+		ClockStrategyOption(c),
+		RandStrategyOption(NewRand(1)),
+	)
+
+	const n = 10000
+	counts := count(s, n, healthy, failed)
+	assertShare(t, counts, "failed", n, 0.5, 0.03)
+
+	failed.marker.Mark()
+	counts = count(s, n, healthy, failed)
+	assertShare(t, counts, "failed", n, 0.2/1.2, 0.02)
+
+	// the penalty decays linearly.
+	c.Advance(5 * time.Second)
+	counts = count(s, n, healthy, failed)
+	assertShare(t, counts, "failed", n, 0.6/1.6, 0.03)
+
+	// restored after the cooldown.
+	c.Advance(5 * time.Second)
+	counts = count(s, n, healthy, failed)
+	assertShare(t, counts, "failed", n, 0.5, 0.03)
+}
+
+func TestWeightedStrategyCooldownDecay(t *testing.T) {
+	c := clock.NewFakeClock(time.Unix(1000, 0))
+	failed := &testNode{name: "failed", weight: 1, marker: NewFailMarker(ClockMarkerOption(c))}
+	failed.marker.Mark()
+
+	opts := newStrategyOptions(
+		CooldownStrategyOption(10*time.Second, 1),
+		DecayStrategyOption(ExponentialDecay),
+		ClockStrategyOption(c),
+	)
+	if p := opts.penalty(failed); p != 1 {
+		t.Fatalf("initial penalty is %f", p)
+	}
+	c.Advance(time.Second)
+	if p := opts.penalty(failed); math.Abs(p-0.5) > 1e-9 {
+		t.Fatalf("penalty should be halved, got %f", p)
+	}
+	c.Advance(9 * time.Second)
+	if p := opts.penalty(failed); p != 0 {
+		t.Fatalf("penalty should be cleared, got %f", p)
+	}
+
+	// reset marker has no penalty.
+	failed.marker.Mark()
+	failed.marker.Reset()
+	if p := opts.penalty(failed); p != 0 {
+		t.Fatalf("reset marker should have no penalty, got %f", p)
+	}
+}
+
+func TestSNIHashStrategy(t *testing.T) {
+	var nodes []*testNode
+	for i := 0; i < 8; i++ {
+		nodes = append(nodes, &testNode{name: fmt.Sprintf("node%d", i)})
+	}
+	s := SNIHashStrategy[*testNode]()
+
+	ctx := ctxvalue.ContextWithSNI(context.Background(), "api.example.com")
+	first := s.Apply(ctx, nodes...)
+	for i := 0; i < 100; i++ {
+		if v := s.Apply(ctx, nodes...); v != first {
+			t.Fatalf("same SNI maps to %s and %s", first.name, v.name)
+		}
+	}
+
+	spread := make(map[*testNode]struct{})
+	for i := 0; i < 100; i++ {
+		ctx := ctxvalue.ContextWithSNI(context.Background(), fmt.Sprintf("host%d.example.com", i))
+		spread[s.Apply(ctx, nodes...)] = struct{}{}
+	}
+	if len(spread) < len(nodes)/2 {
+		t.Fatalf("SNIs are not spread, only %d nodes selected", len(spread))
+	}
+}
+
+func TestSNIHashStrategyFallback(t *testing.T) {
+	var nodes []*testNode
+	for i := 0; i < 8; i++ {
+		nodes = append(nodes, &testNode{name: fmt.Sprintf("node%d", i)})
+	}
+	s := SNIHashStrategy[*testNode]()
+
+	// the client IP is the key without SNI, regardless of the port.
+	first := s.Apply(ctxvalue.ContextWithClientAddr(context.Background(), "192.168.1.10:10000"), nodes...)
+	for port := 10001; port < 10100; port++ {
+		ctx := ctxvalue.ContextWithClientAddr(context.Background(), fmt.Sprintf("192.168.1.10:%d", port))
+		if v := s.Apply(ctx, nodes...); v != first {
+			t.Fatalf("same client IP maps to %s and %s", first.name, v.name)
+		}
+	}
+
+	// the SNI takes precedence over the client IP.
+	ctx := ctxvalue.ContextWithClientAddr(context.Background(), "192.168.1.10:10000")
+	ctx = ctxvalue.ContextWithSNI(ctx, "api.example.com")
+	want := s.Apply(ctxvalue.ContextWithSNI(context.Background(), "api.example.com"), nodes...)
+	if v := s.Apply(ctx, nodes...); v != want {
+		t.Fatalf("SNI should take precedence, got %s want %s", v.name, want.name)
+	}
+
+	// random without any key.
+	if v := s.Apply(context.Background(), nodes...); v == nil {
+		t.Fatal("expected a node without key")
+	}
+}
+
+func TestTieredStrategy(t *testing.T) {
+	var nodes []*testNode
+	for i, name := range []string{"a0", "b0", "c1", "d1"} {
+		nodes = append(nodes, &testNode{name: name, weight: 1, tier: i / 2, marker: NewFailMarker()})
+	}
+	filter := FailFilter[*testNode](1, 0)
+	s := TieredStrategy[*testNode](WeightedStrategy[*testNode](RandStrategyOption(NewRand(1))))
+
+	apply := func() map[string]int {
+		return count(s, 1000, filter.Filter(context.Background(), nodes...)...)
+	}
+	assertTier := func(counts map[string]int, names ...string) {
+		t.Helper()
+		var total int
+		for _, name := range names {
+			total += counts[name]
+		}
+		if total != 1000 {
+			t.Fatalf("expected only %v selected, got %v", names, counts)
+		}
+	}
+
+	// tier 0 takes all the traffic while any of its nodes is healthy.
+	assertTier(apply(), "a0", "b0")
+	nodes[0].marker.Mark()
+	assertTier(apply(), "b0")
+
+	// spill to tier 1 when tier 0 is fully down.
+	nodes[1].marker.Mark()
+	counts := apply()
+	assertTier(counts, "c1", "d1")
+	if counts["c1"] == 0 || counts["d1"] == 0 {
+		t.Fatalf("tier 1 should be balanced by the inner strategy, got %v", counts)
+	}
+
+	// back to tier 0 once it recovers.
+	nodes[0].marker.Reset()
+	assertTier(apply(), "a0")
+}
+
+func TestTieredStrategyNotTiered(t *testing.T) {
+	s := TieredStrategy[string](nil)
+	if v := s.Apply(context.Background(), "a", "b"); v != "a" && v != "b" {
+		t.Fatalf("unexpected selection %q", v)
+	}
+	if v := s.Apply(context.Background()); v != "" {
+		t.Fatalf("expected zero value for no candidate, got %q", v)
+	}
+}
+
+func TestPreferredStrategy(t *testing.T) {
+	var nodes []*testNode
+	for _, name := range []string{"a", "b", "canary"} {
+		nodes = append(nodes, &testNode{name: name, weight: 1, marker: NewFailMarker()})
+	}
+	filter := FailFilter[*testNode](1, 0)
+	s := PreferredStrategy[*testNode](WeightedStrategy[*testNode](RandStrategyOption(NewRand(1))), "canary")
+	apply := func(ctx context.Context) map[string]int {
+		counts := make(map[string]int)
+		for i := 0; i < 1000; i++ {
+			counts[s.Apply(ctx, filter.Filter(ctx, nodes...)...).name]++
+		}
+		return counts
+	}
+
+	// a valid preference pins the node.
+	ctx := ctxvalue.ContextWithPreferredNode(context.Background(), "canary")
+	if counts := apply(ctx); counts["canary"] != 1000 {
+		t.Fatalf("the preferred node should be pinned, got %v", counts)
+	}
+
+	// an unhealthy preferred node falls back.
+	nodes[2].marker.Mark()
+	if counts := apply(ctx); counts["canary"] != 0 || counts["a"] == 0 || counts["b"] == 0 {
+		t.Fatalf("expected fallback for the unhealthy preferred node, got %v", counts)
+	}
+	nodes[2].marker.Reset()
+
+	// a disallowed preference is ignored.
+	ctx = ctxvalue.ContextWithPreferredNode(context.Background(), "a")
+	if counts := apply(ctx); counts["b"] == 0 || counts["canary"] == 0 {
+		t.Fatalf("the disallowed preference should be ignored, got %v", counts)
+	}
+
+	// an unknown preference falls back.
+	ctx = ctxvalue.ContextWithPreferredNode(context.Background(), "unknown")
+	if counts := apply(ctx); len(counts) != 3 {
+		t.Fatalf("expected fallback for the unknown node, got %v", counts)
+	}
+}
+
+func TestPreferredStrategyAllowAll(t *testing.T) {
+	nodes := []*testNode{{name: "a", weight: 1}, {name: "b", weight: 1}}
+	ctx := ctxvalue.ContextWithPreferredNode(context.Background(), "b")
+
+	if v := PreferredStrategy[*testNode](nil, "*").Apply(ctx, nodes...); v.name != "b" {
+		t.Fatalf("any node should be allowed with *, got %s", v.name)
+	}
+
+	// the preference is disabled without the allowed list.
+	s := PreferredStrategy[*testNode](WeightedStrategy[*testNode](RandStrategyOption(NewRand(1))))
+	counts := make(map[string]int)
+	for i := 0; i < 1000; i++ {
+		counts[s.Apply(ctx, nodes...).name]++
+	}
+	if counts["a"] == 0 {
+		t.Fatalf("the preference should be disabled, got %v", counts)
+	}
+}
+
+func TestInflightStrategy(t *testing.T) {
+	// heavy has one huge transfer, light has many small ones.
+	heavy := &testNode{name: "heavy", inflight: 1 << 30}
+	light := &testNode{name: "light", inflight: 100 * 1024}
+	s := InflightStrategy[*testNode](RandStrategyOption(NewRand(1)))
+
+	if counts := count(s, 100, heavy, light); counts["light"] != 100 {
+		t.Fatalf("the less loaded node should be preferred, got %v", counts)
+	}
+
+	// the ties are broken randomly.
+	other := &testNode{name: "other", inflight: light.inflight}
+	counts := count(s, 1000, heavy, light, other)
+	if counts["heavy"] != 0 {
+		t.Fatalf("the heavy node should not be selected, got %v", counts)
+	}
+	assertShare(t, counts, "light", 1000, 0.5, 0.1)
+
+	if v := s.Apply(context.Background()); v != nil {
+		t.Fatalf("expected nil without candidate, got %v", v)
+	}
+}
+
+func TestWeightedLeastRequestStrategy(t *testing.T) {
+	a := &testNode{name: "a", weight: 3}
+	b := &testNode{name: "b", weight: 1}
+	s := WeightedLeastRequestStrategy[*testNode]()
+
+	// the node with the higher weight tolerates proportionally more active conns.
+	for i := 0; i < 40; i++ {
+		s.Apply(context.Background(), a, b).active++
+	}
+	if a.active != 30 || b.active != 10 {
+		t.Fatalf("unexpected active conns a=%d b=%d", a.active, b.active)
+	}
+
+	for _, tc := range []struct {
+		a, b int64
+		want string
+	}{
+		{2, 1, "a"},
+		// the tie is broken by the order of the candidates.
+		{3, 1, "a"},
+		{4, 1, "b"},
+		{0, 0, "a"},
+	} {
+		a.active, b.active = tc.a, tc.b
+		if v := s.Apply(context.Background(), a, b); v.name != tc.want {
+			t.Errorf("a=%d b=%d: got %s, want %s", tc.a, tc.b, v.name, tc.want)
+		}
+	}
+	a.active, b.active = 1, 1
+	if v := s.Apply(context.Background(), b, a); v.name != "a" {
+		t.Fatalf("expected a with the lower score, got %s", v.name)
+	}
+	a.active = 3
+	if v := s.Apply(context.Background(), b, a); v.name != "b" {
+		t.Fatalf("expected the first of the tie, got %s", v.name)
+	}
+
+	if v := s.Apply(context.Background()); v != nil {
+		t.Fatalf("expected nil for no candidate, got %v", v)
+	}
+}
+
+func TestWeightedLeastRequestStrategySlowStart(t *testing.T) {
+	c := clock.NewFakeClock(time.Unix(1000, 0))
+	a := &testNode{name: "a", weight: 1, joinTime: c.Now()}
+	b := &testNode{name: "b", weight: 1, active: 1}
+	s := WeightedLeastRequestStrategy[*testNode](SlowStartStrategyOption(time.Minute), ClockStrategyOption(c))
+
+	// the effective weight of the warming node is reduced.
+	a.active = 1
+	c.Advance(30 * time.Second)
+	if v := s.Apply(context.Background(), a, b); v.name != "b" {
+		t.Fatalf("expected b while a is warming up, got %s", v.name)
+	}
+	c.Advance(time.Minute)
+	if v := s.Apply(context.Background(), a, b); v.name != "a" {
+		t.Fatalf("expected the first of the tie after the slow start, got %s", v.name)
+	}
+}
+
+func TestSuccessRateStrategy(t *testing.T) {
+	flaky := &testNode{name: "flaky", weight: 1, marker: NewErrorRateMarker()}
+	healthy := &testNode{name: "healthy", weight: 1, marker: NewErrorRateMarker()}
+	heavy := &testNode{name: "heavy", weight: 2, marker: NewErrorRateMarker()}
+	feed(flaky.marker, "SSSSSSSFFF", 10)
+	feed(healthy.marker, "S", 100)
+	feed(heavy.marker, "S", 100)
+	if rate, ok := flaky.SuccessRate(); !ok || math.Abs(rate-0.7) > 1e-9 {
+		t.Fatalf("unexpected success rate %v %v", rate, ok)
+	}
+
+	s := SuccessRateStrategy[*testNode](RandStrategyOption(NewRand(1)))
+	counts := count(s, 20000, flaky, healthy)
+	// the flaky node receives 70% of the share of the healthy peer, 0.7 / 1.7.
+	assertShare(t, counts, "flaky", 20000, 0.7/1.7, 0.02)
+	if ratio := float64(counts["flaky"]) / float64(counts["healthy"]); math.Abs(ratio-0.7) > 0.05 {
+		t.Fatalf("the flaky node gets %.3f of the healthy share, want 0.7 (%v)", ratio, counts)
+	}
+
+	// the base weight is kept.
+	counts = count(s, 20000, flaky, heavy)
+	assertShare(t, counts, "flaky", 20000, 0.7/2.7, 0.02)
+
+	if v := s.Apply(context.Background()); v != nil {
+		t.Fatalf("expected nil for no candidate, got %v", v)
+	}
+}
+
+func TestSuccessRateStrategyUnknown(t *testing.T) {
+	// too few outcomes to judge, the full weight is kept.
+	fresh := &testNode{name: "fresh", weight: 1, marker: NewErrorRateMarker()}
+	plain := &testNode{name: "plain", weight: 1, marker: NewFailMarker()}
+	feed(fresh.marker, "F", 5)
+
+	s := SuccessRateStrategy[*testNode](RandStrategyOption(NewRand(1)))
+	counts := count(s, 10000, fresh, plain)
+	assertShare(t, counts, "fresh", 10000, 0.5, 0.03)
+
+	// all the nodes have zero success rate, the selection falls back to random.
+	a := &testNode{name: "a", weight: 1, marker: NewErrorRateMarker()}
+	b := &testNode{name: "b", weight: 1, marker: NewErrorRateMarker()}
+	feed(a.marker, "F", 20)
+	feed(b.marker, "F", 20)
+	if counts := count(s, 1000, a, b); len(counts) != 2 {
+		t.Fatalf("unexpected selections %v", counts)
+	}
+}
+
+func TestWeightedStrategyDeterministic(t *testing.T) {
+	a := &testNode{name: "a", weight: 5}
+	b := &testNode{name: "b", weight: 1}
+	c := &testNode{name: "c", weight: 1}
+	s := WeightedStrategy[*testNode](DeterministicStrategyOption(true))
+
+	// the exact smooth weighted round-robin sequence, repeated.
+	want := "aabacaa"
+	for round := 0; round < 3; round++ {
+		var got string
+		for i := 0; i < len(want); i++ {
+			got += s.Apply(context.Background(), a, b, c).name
+		}
+		if got != want {
+			t.Fatalf("round %d: got sequence %s, want %s", round, got, want)
+		}
+	}
+
+	// the state of the removed node is dropped, the others continue smoothly.
+	got := ""
+	for i := 0; i < 4; i++ {
+		got += s.Apply(context.Background(), b, c).name
+	}
+	if got != "bcbc" {
+		t.Fatalf("unexpected sequence without a %s", got)
+	}
+
+	// the objects without keys are identified by themselves.
+	ss := WeightedStrategy[string](DeterministicStrategyOption(true))
+	got = ""
+	for i := 0; i < 4; i++ {
+		got += ss.Apply(context.Background(), "x", "y")
+	}
+	if got != "xyxy" {
+		t.Fatalf("unexpected sequence of the unkeyed objects %s", got)
+	}
+}
+
+func TestWeightedStrategyRandomMode(t *testing.T) {
+	a := &testNode{name: "a", weight: 5}
+	b := &testNode{name: "b", weight: 1}
+	c := &testNode{name: "c", weight: 1}
+	s := WeightedStrategy[*testNode](DeterministicStrategyOption(false), RandStrategyOption(NewRand(7)))
+
+	// the long-run distribution matches the weights.
+	counts := count(s, 14000, a, b, c)
+	assertShare(t, counts, "a", 14000, 5.0/7, 0.02)
+	assertShare(t, counts, "b", 14000, 1.0/7, 0.02)
+	assertShare(t, counts, "c", 14000, 1.0/7, 0.02)
+}
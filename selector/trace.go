@@ -0,0 +1,163 @@
+package selector
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-gost/core/logger"
+)
+
+// FilterTrace is the result of a filter in a DecisionTrace.
+type FilterTrace[T any] struct {
+	// Filter is the name of the filter, which is from its String method if it is a fmt.Stringer,
+	// otherwise the position in the filters, e.g. filter0.
+	Filter string
+	Output []T
+}
+
+// DecisionTrace is the full trace of a selection.
+type DecisionTrace[T any] struct {
+	Time       time.Time
+	Candidates []T
+	Filters    []FilterTrace[T]
+	Selected   T
+	Duration   time.Duration
+}
+
+func (t *DecisionTrace[T]) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "candidates=%s", traceList(t.Candidates))
+	for _, f := range t.Filters {
+		fmt.Fprintf(&b, " %s=%s", f.Filter, traceList(f.Output))
+	}
+	fmt.Fprintf(&b, " selected=%v duration=%s", traceName(t.Selected), t.Duration)
+	return b.String()
+}
+
+func traceList[T any](vs []T) string {
+	names := make([]string, len(vs))
+	for i := range vs {
+		names[i] = traceName(vs[i])
+	}
+	return "[" + strings.Join(names, ",") + "]"
+}
+
+func traceName(v any) string {
+	if kv, _ := v.(Keyed); kv != nil {
+		return kv.Key()
+	}
+	return fmt.Sprint(v)
+}
+
+type TraceOptions[T any] struct {
+	// SampleRate is the fraction of the selections in range [0, 1] to be traced.
+	SampleRate float64
+	// OnTrace is called with the trace of each sampled selection.
+	OnTrace func(ctx context.Context, trace *DecisionTrace[T])
+	// Logger logs the trace of each sampled selection at the info level.
+	Logger logger.Logger
+	Rand   Rand
+}
+
+type TraceOption[T any] func(opts *TraceOptions[T])
+
+func SampleRateTraceOption[T any](rate float64) TraceOption[T] {
+	return func(opts *TraceOptions[T]) {
+		opts.SampleRate = rate
+	}
+}
+
+func OnTraceTraceOption[T any](fn func(ctx context.Context, trace *DecisionTrace[T])) TraceOption[T] {
+	return func(opts *TraceOptions[T]) {
+		opts.OnTrace = fn
+	}
+}
+
+func LoggerTraceOption[T any](logger logger.Logger) TraceOption[T] {
+	return func(opts *TraceOptions[T]) {
+		opts.Logger = logger
+	}
+}
+
+func RandTraceOption[T any](r Rand) TraceOption[T] {
+	return func(opts *TraceOptions[T]) {
+		opts.Rand = r
+	}
+}
+
+type tracedSelector[T any] struct {
+	strategy Strategy[T]
+	filters  []Filter[T]
+	options  TraceOptions[T]
+}
+
+// TracedSelector is a Selector applying the filters in order and then the strategy,
+// a sampled fraction of the selections is traced in full (the candidates, the output of each filter and the selection)
+// for the observability without flooding the logs at high QPS.
+// The selections not sampled skip building the trace entirely.
+func TracedSelector[T any](strategy Strategy[T], filters []Filter[T], opts ...TraceOption[T]) Selector[T] {
+	var options TraceOptions[T]
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+	if options.Rand == nil {
+		options.Rand = globalRand{}
+	}
+	if strategy == nil {
+		strategy = WeightedStrategy[T]()
+	}
+
+	return &tracedSelector[T]{
+		strategy: strategy,
+		filters:  filters,
+		options:  options,
+	}
+}
+
+func (s *tracedSelector[T]) Select(ctx context.Context, vs ...T) T {
+	if !s.sample() {
+		for _, f := range s.filters {
+			vs = f.Filter(ctx, vs...)
+		}
+		return s.strategy.Apply(ctx, vs...)
+	}
+
+	trace := &DecisionTrace[T]{
+		Time:       time.Now(),
+		Candidates: vs,
+		Filters:    make([]FilterTrace[T], 0, len(s.filters)),
+	}
+	for i, f := range s.filters {
+		vs = f.Filter(ctx, vs...)
+		name := fmt.Sprintf("filter%d", i)
+		if sv, ok := f.(fmt.Stringer); ok {
+			name = sv.String()
+		}
+		trace.Filters = append(trace.Filters, FilterTrace[T]{
+			Filter: name,
+			Output: vs,
+		})
+	}
+	trace.Selected = s.strategy.Apply(ctx, vs...)
+	trace.Duration = time.Since(trace.Time)
+
+	if s.options.OnTrace != nil {
+		s.options.OnTrace(ctx, trace)
+	}
+	if s.options.Logger != nil {
+		logger.WithTraceID(ctx, s.options.Logger).Infof("selector: %s", trace)
+	}
+	return trace.Selected
+}
+
+func (s *tracedSelector[T]) sample() bool {
+	rate := s.options.SampleRate
+	if rate <= 0 || (s.options.OnTrace == nil && s.options.Logger == nil) {
+		return false
+	}
+	return rate >= 1 || s.options.Rand.Float64() < rate
+}
@@ -0,0 +1,124 @@
+package selector
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/go-gost/core/logger"
+)
+
+// infoLogger records the info messages.
+type infoLogger struct {
+	logger.Logger
+	entries []string
+}
+
+func (l *infoLogger) WithFields(map[string]any) logger.Logger {
+	return l
+}
+
+func (l *infoLogger) Infof(format string, args ...any) {
+	l.entries = append(l.entries, fmt.Sprintf(format, args...))
+}
+
+// passFilter passes the candidates as is.
+type passFilter struct{}
+
+func (passFilter) Filter(ctx context.Context, vs ...*testNode) []*testNode {
+	return vs
+}
+
+func (passFilter) String() string {
+	return "pass"
+}
+
+func TestTracedSelector(t *testing.T) {
+	nodes := testNodes(3)
+	nodes[1].marker.Mark()
+
+	var traces []*DecisionTrace[*testNode]
+	l := &infoLogger{}
+	sel := TracedSelector[*testNode](firstStrategy{},
+		[]Filter[*testNode]{passFilter{}, FailFilter[*testNode](1, 0)},
+		SampleRateTraceOption[*testNode](1),
+		LoggerTraceOption[*testNode](l),
+		OnTraceTraceOption[*testNode](func(ctx context.Context, trace *DecisionTrace[*testNode]) {
+			traces = append(traces, trace)
+		}))
+
+	if v := sel.Select(context.Background(), nodes...); v != nodes[0] {
+		t.Fatalf("unexpected selection %v", v)
+	}
+	if len(traces) != 1 {
+		t.Fatalf("expected one trace, got %d", len(traces))
+	}
+	trace := traces[0]
+	if len(trace.Candidates) != 3 || len(trace.Filters) != 2 || trace.Selected != nodes[0] {
+		t.Fatalf("unexpected trace %s", trace)
+	}
+	if trace.Filters[0].Filter != "pass" || trace.Filters[1].Filter != "filter1" || len(trace.Filters[1].Output) != 2 {
+		t.Fatalf("unexpected filter traces %s", trace)
+	}
+
+	want := "selector: candidates=[node0,node1,node2] pass=[node0,node1,node2] filter1=[node0,node2] selected=node0"
+	if len(l.entries) != 1 || !strings.HasPrefix(l.entries[0], want) {
+		t.Fatalf("unexpected log %q", l.entries)
+	}
+}
+
+func TestTracedSelectorSampleRate(t *testing.T) {
+	nodes := testNodes(3)
+	var n int
+	sel := TracedSelector[*testNode](firstStrategy{}, []Filter[*testNode]{passFilter{}},
+		SampleRateTraceOption[*testNode](0.05),
+		RandTraceOption[*testNode](NewRand(1)),
+		OnTraceTraceOption[*testNode](func(ctx context.Context, trace *DecisionTrace[*testNode]) {
+			n++
+		}))
+
+	// the traces are emitted at approximately the configured rate.
+	const total = 20000
+	for i := 0; i < total; i++ {
+		if v := sel.Select(context.Background(), nodes...); v != nodes[0] {
+			t.Fatalf("unexpected selection %v", v)
+		}
+	}
+	if rate := float64(n) / total; rate < 0.04 || rate > 0.06 {
+		t.Fatalf("traced %d of %d selections, want rate 0.05", n, total)
+	}
+}
+
+func TestTracedSelectorNotSampled(t *testing.T) {
+	nodes := testNodes(3)
+	traced := false
+	onTrace := OnTraceTraceOption[*testNode](func(ctx context.Context, trace *DecisionTrace[*testNode]) {
+		traced = true
+	})
+	filters := []Filter[*testNode]{passFilter{}}
+
+	// the selections not sampled skip building the trace entirely.
+	sel := TracedSelector[*testNode](firstStrategy{}, filters, SampleRateTraceOption[*testNode](0), onTrace)
+	allocs := testing.AllocsPerRun(100, func() {
+		sel.Select(context.Background(), nodes...)
+	})
+	if allocs != 0 || traced {
+		t.Fatalf("the not sampled selection allocates %v, traced %v", allocs, traced)
+	}
+
+	sampled := TracedSelector[*testNode](firstStrategy{}, filters, SampleRateTraceOption[*testNode](1), onTrace)
+	if allocs := testing.AllocsPerRun(100, func() {
+		sampled.Select(context.Background(), nodes...)
+	}); allocs == 0 || !traced {
+		t.Fatalf("the sampled selection should build the trace, allocs %v, traced %v", allocs, traced)
+	}
+
+	// without any sink nothing is traced, even at the full rate.
+	sel = TracedSelector[*testNode](firstStrategy{}, filters, SampleRateTraceOption[*testNode](1))
+	if allocs := testing.AllocsPerRun(100, func() {
+		sel.Select(context.Background(), nodes...)
+	}); allocs != 0 {
+		t.Fatalf("the selection without sink allocates %v", allocs)
+	}
+}